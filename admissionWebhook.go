@@ -0,0 +1,250 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// annotationCloudflarePrefix is the prefix of the annotations of the controller, to tell misspelled ones from those of other tools
+const annotationCloudflarePrefix string = "estafette.io/cloudflare-"
+
+// knownAnnotations are the annotations the controller reads from services and ingresses
+var knownAnnotations = map[string]bool{
+	annotationCloudflareDNS:                  true,
+	annotationCloudflareHostnames:            true,
+	annotationCloudflareInternalHostnames:    true,
+	annotationCloudflareInternalDNS:          true,
+	annotationCloudflareInternalIPAddress:    true,
+	annotationCloudflareProxy:                true,
+	annotationCloudflareProxyAfter:           true,
+	annotationCloudflareProxyPlans:           true,
+	annotationCloudflareUseOriginRecord:      true,
+	annotationCloudflareOriginRecordHostname: true,
+	annotationCloudflareSRVRecords:           true,
+	annotationCloudflareNSRecords:            true,
+	annotationCloudflareCAARecords:           true,
+	annotationCloudflareLOCRecords:           true,
+	annotationCloudflareComment:              true,
+	annotationCloudflareCredentialsSecret:    true,
+	annotationCloudflareCNAMETarget:          true,
+	annotationCloudflareForceUpdate:          true,
+	annotationCloudflareTTL:                  true,
+	annotationCloudflareRegion:               true,
+	annotationCloudflareSSLMode:              true,
+	annotationCloudflareZoneSettings:         true,
+	annotationCloudflareUseNodeExternalIP:    true,
+	annotationCloudflareRecordName:           true,
+	annotationCloudflareState:                true,
+}
+
+// initAdmissionWebhook serves a /validate endpoint over tls for a validating admission webhook, so malformed annotations get rejected on apply instead of at reconcile time
+func initAdmissionWebhook(certFile, keyFile string) {
+
+	// fail fast on a missing or invalid certificate instead of on the first admission request
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		log.Fatal().Err(err).Msgf("Loading admission webhook certificate %v failed", certFile)
+	}
+
+	go func() {
+		log.Debug().Msgf("Serving /validate admission webhook on port %v...", admissionWebhookPort)
+
+		serverMux := http.NewServeMux()
+		serverMux.HandleFunc("/validate", newAdmissionHandler())
+
+		server := &http.Server{
+			Addr:      fmt.Sprintf(":%v", admissionWebhookPort),
+			Handler:   serverMux,
+			TLSConfig: &tls.Config{Certificates: []tls.Certificate{certificate}, MinVersion: tls.VersionTLS12},
+		}
+		if err := server.ListenAndServeTLS("", ""); err != nil {
+			log.Fatal().Err(err).Msg("Starting /validate listener failed")
+		}
+	}()
+}
+
+// newAdmissionHandler returns a handler that responds to admission reviews of services and ingresses
+func newAdmissionHandler() http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var review admissionv1.AdmissionReview
+		if err := json.NewDecoder(r.Body).Decode(&review); err != nil || review.Request == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, "Expected an AdmissionReview with a request\n")
+			return
+		}
+
+		review.Response = reviewAdmissionRequest(review.Request)
+		review.Request = nil
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(review)
+	}
+}
+
+// reviewAdmissionRequest allows the object of the request, unless it's a service or ingress with malformed cloudflare annotations
+func reviewAdmissionRequest(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+
+	response := &admissionv1.AdmissionResponse{UID: request.UID, Allowed: true}
+	if request.Operation == admissionv1.Delete {
+		return response
+	}
+
+	var problems []string
+	switch request.Kind.Kind {
+	case "Service":
+		var service v1.Service
+		if err := json.Unmarshal(request.Object.Raw, &service); err != nil {
+			log.Warn().Err(err).Msgf("Decoding service %v.%v for admission review failed, allowing it", request.Name, request.Namespace)
+			return response
+		}
+		problems = getAnnotationProblems(service.Annotations, getServiceHostnamePlaceholders(&service))
+	case "Ingress":
+		var ingress networkingv1.Ingress
+		if err := json.Unmarshal(request.Object.Raw, &ingress); err != nil {
+			log.Warn().Err(err).Msgf("Decoding ingress %v.%v for admission review failed, allowing it", request.Name, request.Namespace)
+			return response
+		}
+		problems = getAnnotationProblems(ingress.Annotations, nil)
+	default:
+		return response
+	}
+
+	if len(problems) > 0 {
+		log.Info().Msgf("Rejecting %v %v.%v with invalid annotations: %v", request.Kind.Kind, request.Name, request.Namespace, strings.Join(problems, "; "))
+		response.Allowed = false
+		response.Result = &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Reason:  metav1.StatusReasonInvalid,
+			Code:    http.StatusUnprocessableEntity,
+			Message: fmt.Sprintf("Invalid %v annotations: %v", annotationCloudflarePrefix+"*", strings.Join(problems, "; ")),
+		}
+	}
+
+	return response
+}
+
+// getAnnotationProblems returns what's wrong with the cloudflare annotations, using the same parsers as the reconcile; placeholders in the hostnames get replaced by the given values, hostnames of objects without placeholders are taken as they are
+func getAnnotationProblems(annotations map[string]string, placeholders map[string]string) (problems []string) {
+
+	// report misspelled annotations in a stable order
+	unknownAnnotations := []string{}
+	for annotation := range annotations {
+		if strings.HasPrefix(annotation, annotationCloudflarePrefix) && !knownAnnotations[annotation] {
+			unknownAnnotations = append(unknownAnnotations, annotation)
+		}
+	}
+	sort.Strings(unknownAnnotations)
+	for _, annotation := range unknownAnnotations {
+		problems = append(problems, fmt.Sprintf("%v is not a known annotation", annotation))
+	}
+
+	for _, annotation := range []string{annotationCloudflareDNS, annotationCloudflareInternalDNS, annotationCloudflareUseOriginRecord, annotationCloudflareUseNodeExternalIP} {
+		if value, ok := annotations[annotation]; ok && !isBooleanAnnotationValue(value) {
+			problems = append(problems, fmt.Sprintf("%v has value '%v', expected true, false, 1 or 0", annotation, value))
+		}
+	}
+	if value, ok := annotations[annotationCloudflareProxy]; ok && !isBooleanAnnotationValue(value) && !strings.EqualFold(strings.TrimSpace(value), "auto") {
+		problems = append(problems, fmt.Sprintf("%v has value '%v', expected true, false, 1, 0 or auto", annotationCloudflareProxy, value))
+	}
+	if value, ok := annotations[annotationCloudflareProxyAfter]; ok {
+		if proxyAfter, err := time.ParseDuration(strings.TrimSpace(value)); err != nil || proxyAfter <= 0 {
+			problems = append(problems, fmt.Sprintf("%v has value '%v', expected a positive duration like 30m", annotationCloudflareProxyAfter, value))
+		}
+	}
+	if value, ok := annotations[annotationCloudflareTTL]; ok {
+		ttl, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || ttl < 1 {
+			problems = append(problems, fmt.Sprintf("%v has value '%v', expected a number of seconds or 1 for automatic", annotationCloudflareTTL, value))
+		} else if proxy := strings.ToLower(strings.TrimSpace(annotations[annotationCloudflareProxy])); ttl > 1 && (proxy == "true" || proxy == "1") {
+			// cloudflare forces the ttl of proxied records to automatic
+			problems = append(problems, fmt.Sprintf("%v of %v conflicts with %v, since proxied records always get an automatic ttl", annotationCloudflareTTL, ttl, annotationCloudflareProxy))
+		}
+	}
+	if value, ok := annotations[annotationCloudflareSSLMode]; ok {
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "off", "flexible", "full", "strict":
+		default:
+			problems = append(problems, fmt.Sprintf("%v has value '%v', expected off, flexible, full or strict", annotationCloudflareSSLMode, value))
+		}
+	}
+	if value, ok := annotations[annotationCloudflareInternalIPAddress]; ok {
+		if _, err := normalizeIPAddress(value); err != nil {
+			problems = append(problems, fmt.Sprintf("%v is invalid: %v", annotationCloudflareInternalIPAddress, err))
+		}
+	}
+
+	// hostnames
+	for _, annotation := range []string{annotationCloudflareHostnames, annotationCloudflareInternalHostnames, annotationCloudflareOriginRecordHostname, annotationCloudflareCNAMETarget} {
+		hostnames := annotations[annotation]
+		if placeholders != nil && (annotation == annotationCloudflareHostnames || annotation == annotationCloudflareInternalHostnames) {
+			var unresolvedHostnames []string
+			hostnames, unresolvedHostnames = expandHostnames(hostnames, placeholders)
+			if len(unresolvedHostnames) > 0 {
+				problems = append(problems, fmt.Sprintf("%v has hostnames %v with placeholders without a value", annotation, strings.Join(unresolvedHostnames, ",")))
+			}
+		}
+		for _, hostname := range splitHostnames(hostnames) {
+			if reason := validateHostname(toASCIIHostname(strings.TrimSuffix(hostname, "."))); reason != "" {
+				problems = append(problems, fmt.Sprintf("%v has invalid hostname '%v' (%v)", annotation, hostname, reason))
+			}
+		}
+	}
+	for _, entry := range strings.Split(annotations[annotationCloudflareRecordName], ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		recordName := strings.TrimSpace(parts[len(parts)-1])
+		if reason := validateHostname(toASCIIHostname(recordName)); reason != "" {
+			problems = append(problems, fmt.Sprintf("%v has invalid record name '%v' (%v)", annotationCloudflareRecordName, recordName, reason))
+		}
+	}
+
+	// structured records and settings
+	if _, err := parseSRVRecords(annotations[annotationCloudflareSRVRecords]); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if _, err := parseCAARecords(annotations[annotationCloudflareCAARecords]); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if _, err := parseLOCRecords(annotations[annotationCloudflareLOCRecords]); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if _, err := parseNSRecords(annotations[annotationCloudflareNSRecords]); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if _, err := parseZoneSettings(strings.TrimSpace(annotations[annotationCloudflareZoneSettings])); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	return problems
+}
+
+// isBooleanAnnotationValue returns whether getBooleanAnnotation recognizes the value
+func isBooleanAnnotationValue(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "1", "false", "0":
+		return true
+	}
+
+	return false
+}
@@ -5,16 +5,47 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/rs/zerolog/log"
 )
 
+// listPageSize is the number of zones or records to request per page when listing all of them
+const listPageSize = 50
+
 // Cloudflare is the object to perform Cloudflare api calls with
 type Cloudflare struct {
 	restClient     restClient
 	authentication APIAuthentication
 	baseURL        string
+	accountID      string
+
+	// if set, requests rejected for the primary credentials are retried with these, to rotate credentials without downtime
+	secondaryAuthentication *APIAuthentication
+
+	// if set, all records are managed in this zone without looking it up, for tokens that aren't allowed to list zones
+	zone *Zone
+
+	// if set, records in zones other than these are left alone
+	allowedZones []string
+
+	// if set, only records with this marker in their comment get modified
+	ownershipMarker string
+
+	// if set, dns record lookups only return records with the ownership marker in their comment, so records of other tools aren't even seen
+	scopeRecordLookups bool
+
+	// if set, records in paused zones are left alone, since cloudflare doesn't serve them
+	skipPausedZones bool
+
+	// the paused zones a warning has been logged for, to only warn once per zone
+	pausedZones sync.Map
+
+	// if set, dns record lookups are cached until they expire or the records get modified
+	dnsRecordsCache *dnsRecordsCache
 }
 
 // New returns an initialized APIClient
@@ -27,13 +58,100 @@ func New(authentication APIAuthentication) *Cloudflare {
 	}
 }
 
+// withAuthentication returns a client with other credentials that manages records the same way, for objects that belong to another account;
+// the account and zone the global credentials are restricted to don't apply to it
+func (cf *Cloudflare) withAuthentication(authentication APIAuthentication) *Cloudflare {
+
+	client := &Cloudflare{
+		restClient:         cf.restClient,
+		authentication:     authentication,
+		baseURL:            cf.baseURL,
+		allowedZones:       cf.allowedZones,
+		ownershipMarker:    cf.ownershipMarker,
+		scopeRecordLookups: cf.scopeRecordLookups,
+		skipPausedZones:    cf.skipPausedZones,
+	}
+	if cf.dnsRecordsCache != nil {
+		client.dnsRecordsCache = newDNSRecordsCache(cf.dnsRecordsCache.ttl)
+	}
+
+	return client
+}
+
+// get calls the cloudflare api, failing over to the secondary credentials if the primary ones get rejected
+func (cf *Cloudflare) get(cloudflareAPIURL string) ([]byte, error) {
+	return cf.withFailover(func(authentication APIAuthentication) ([]byte, error) {
+		return cf.restClient.Get(cloudflareAPIURL, authentication)
+	})
+}
+
+// post calls the cloudflare api, failing over to the secondary credentials if the primary ones get rejected
+func (cf *Cloudflare) post(cloudflareAPIURL string, params interface{}) ([]byte, error) {
+	return cf.withFailover(func(authentication APIAuthentication) ([]byte, error) {
+		return cf.restClient.Post(cloudflareAPIURL, params, authentication)
+	})
+}
+
+// put calls the cloudflare api, failing over to the secondary credentials if the primary ones get rejected
+func (cf *Cloudflare) put(cloudflareAPIURL string, params interface{}) ([]byte, error) {
+	return cf.withFailover(func(authentication APIAuthentication) ([]byte, error) {
+		return cf.restClient.Put(cloudflareAPIURL, params, authentication)
+	})
+}
+
+// patch calls the cloudflare api, failing over to the secondary credentials if the primary ones get rejected
+func (cf *Cloudflare) patch(cloudflareAPIURL string, params interface{}) ([]byte, error) {
+	return cf.withFailover(func(authentication APIAuthentication) ([]byte, error) {
+		return cf.restClient.Patch(cloudflareAPIURL, params, authentication)
+	})
+}
+
+// delete calls the cloudflare api, failing over to the secondary credentials if the primary ones get rejected
+func (cf *Cloudflare) delete(cloudflareAPIURL string) ([]byte, error) {
+	return cf.withFailover(func(authentication APIAuthentication) ([]byte, error) {
+		return cf.restClient.Delete(cloudflareAPIURL, authentication)
+	})
+}
+
+// postFile uploads a file to the cloudflare api, failing over to the secondary credentials if the primary ones get rejected
+func (cf *Cloudflare) postFile(cloudflareAPIURL string, fields map[string]string, fileName string, file []byte) ([]byte, error) {
+	return cf.withFailover(func(authentication APIAuthentication) ([]byte, error) {
+		return cf.restClient.PostFile(cloudflareAPIURL, fields, fileName, file, authentication)
+	})
+}
+
+func (cf *Cloudflare) withFailover(request func(authentication APIAuthentication) ([]byte, error)) (body []byte, err error) {
+
+	body, err = request(cf.authentication)
+	if cf.secondaryAuthentication == nil || !isAuthenticationFailure(body, err) {
+		return
+	}
+
+	log.Warn().Msgf("Cloudflare rejected the primary credentials for %v, retrying with the secondary credentials...", cf.authentication.Email)
+
+	body, err = request(*cf.secondaryAuthentication)
+	if err != nil || isAuthenticationFailure(body, err) {
+		log.Warn().Msgf("Cloudflare rejected the secondary credentials for %v as well", cf.secondaryAuthentication.Email)
+		return
+	}
+
+	log.Info().Msgf("Request succeeded with the secondary credentials for %v", cf.secondaryAuthentication.Email)
+
+	return
+}
+
 func (cf *Cloudflare) getZonesByName(zoneName string) (r zonesResult, err error) {
 
 	// create api url
 	findZoneURI := fmt.Sprintf("%v/zones/?name=%v", cf.baseURL, zoneName)
 
+	// only look for zones in a specific account if configured
+	if cf.accountID != "" {
+		findZoneURI += fmt.Sprintf("&account.id=%v", cf.accountID)
+	}
+
 	// fetch result from cloudflare api
-	body, err := cf.restClient.Get(findZoneURI, cf.authentication)
+	body, err := cf.get(findZoneURI)
 	if err != nil {
 		return r, err
 	}
@@ -48,9 +166,76 @@ func (cf *Cloudflare) getZonesByName(zoneName string) (r zonesResult, err error)
 	return
 }
 
+// CheckConnectivity verifies that the Cloudflare api can be reached and the credentials are accepted by listing a single zone, or fetching the configured one.
+func (cf *Cloudflare) CheckConnectivity() (err error) {
+
+	// create api url
+	listZonesURI := fmt.Sprintf("%v/zones/?per_page=1", cf.baseURL)
+
+	// fetch the configured zone instead, since listing zones might not be allowed
+	if cf.zone != nil {
+		listZonesURI = fmt.Sprintf("%v/zones/%v", cf.baseURL, cf.zone.ID)
+	}
+
+	// fetch result from cloudflare api
+	body, err := cf.get(listZonesURI)
+	if err != nil {
+		return err
+	}
+
+	var r apiResult
+
+	json.NewDecoder(bytes.NewReader(body)).Decode(&r)
+
+	if !r.Success {
+		err = fmt.Errorf("Listing cloudflare zones failed | %v | %v", r.Errors, r.Messages)
+		return
+	}
+
+	return
+}
+
+// VerifyCredentials checks that the configured api key and email address are accepted, by fetching the user they belong to.
+func (cf *Cloudflare) VerifyCredentials() (err error) {
+
+	// create api url
+	userURI := fmt.Sprintf("%v/user", cf.baseURL)
+
+	// fetch result from cloudflare api
+	body, err := cf.get(userURI)
+	if err != nil {
+		return err
+	}
+
+	var r apiResult
+
+	json.NewDecoder(bytes.NewReader(body)).Decode(&r)
+
+	if !r.Success {
+		err = fmt.Errorf("Verifying cloudflare credentials failed | %v | %v", r.Errors, r.Messages)
+		return
+	}
+
+	return
+}
+
 // GetZoneByDNSName returns the Cloudflare zone by looking it up with a dnsName, possibly including subdomains; also works for TLDs like .co.uk.
 func (cf *Cloudflare) GetZoneByDNSName(dnsName string) (r Zone, err error) {
 
+	// zones are named in punycode at cloudflare
+	dnsName = toASCIIHostname(dnsName)
+
+	// skip the lookup if the zone has been configured
+	if cf.zone != nil {
+		if cf.zone.Name != "" && !isDNSNameInZone(dnsName, cf.zone.Name) {
+			return r, errZoneNotFound
+		}
+		if cf.zone.Name != "" && !isZoneAllowed(cf.zone.Name, cf.allowedZones) {
+			return r, fmt.Errorf("%w: %v", errZoneNotAllowed, cf.zone.Name)
+		}
+		return *cf.zone, nil
+	}
+
 	// split dnsName
 	dnsNameParts := strings.Split(dnsName, ".")
 
@@ -76,22 +261,165 @@ func (cf *Cloudflare) GetZoneByDNSName(dnsName string) (r Zone, err error) {
 
 		if (zonesResult.ResultInfo.Count > 0) && (zonesResult.ResultInfo.Count <= zonesResult.ResultInfo.PerPage) {
 			r, err := getMatchingZoneFromZones(zonesResult.Zones, zoneName)
+			if err == nil && !isZoneAllowed(r.Name, cf.allowedZones) {
+				return Zone{}, fmt.Errorf("%w: %v", errZoneNotAllowed, r.Name)
+			}
+			if err == nil {
+				return cf.checkPausedZone(r)
+			}
 			return r, err
 		}
 		numberOfZoneItems--
 	}
 
-	err = errors.New("cloudflare: no matching zone has been found")
+	err = errZoneNotFound
 	return r, err
 }
 
-func (cf *Cloudflare) getDNSRecordsByZoneAndName(zone Zone, dnsRecordName string) (r dNSRecordsResult, err error) {
+// GetZones returns all zones the credentials have access to, limited to the configured account, zone and allowed zones.
+func (cf *Cloudflare) GetZones() (r []Zone, err error) {
+
+	// skip listing if the zone has been configured
+	if cf.zone != nil {
+		if cf.zone.Name != "" && !isZoneAllowed(cf.zone.Name, cf.allowedZones) {
+			return r, nil
+		}
+		return []Zone{*cf.zone}, nil
+	}
+
+	for page := 1; ; page++ {
+
+		// create api url
+		listZonesURI := fmt.Sprintf("%v/zones/?page=%v&per_page=%v", cf.baseURL, page, listPageSize)
+
+		// only list zones in a specific account if configured
+		if cf.accountID != "" {
+			listZonesURI += fmt.Sprintf("&account.id=%v", cf.accountID)
+		}
+
+		// fetch result from cloudflare api
+		body, err := cf.get(listZonesURI)
+		if err != nil {
+			return r, err
+		}
+
+		var zonesResult zonesResult
+		json.NewDecoder(bytes.NewReader(body)).Decode(&zonesResult)
+
+		if !zonesResult.Success {
+			return r, fmt.Errorf("Listing cloudflare zones failed | %v | %v", zonesResult.Errors, zonesResult.Messages)
+		}
+
+		for _, zone := range zonesResult.Zones {
+			if isZoneAllowed(zone.Name, cf.allowedZones) {
+				r = append(r, zone)
+			}
+		}
+
+		if len(zonesResult.Zones) == 0 || page*listPageSize >= zonesResult.ResultInfo.TotalCount {
+			return r, nil
+		}
+	}
+}
+
+// GetOwnedDNSRecords returns all records in a zone that carry the ownership marker in their comment.
+func (cf *Cloudflare) GetOwnedDNSRecords(zone Zone) (r []DNSRecord, err error) {
+
+	// without a marker there's no telling which records are owned by the controller
+	if cf.ownershipMarker == "" {
+		return r, errors.New("Listing owned dns records requires an ownership marker")
+	}
+
+	for page := 1; ; page++ {
+
+		// create api url
+		listDNSRecordsURI := fmt.Sprintf("%v/zones/%v/dns_records/?comment.contains=%v&page=%v&per_page=%v", cf.baseURL, zone.ID, url.QueryEscape(cf.ownershipMarker), page, listPageSize)
+
+		// fetch result from cloudflare api
+		body, err := cf.get(listDNSRecordsURI)
+		if err != nil {
+			return r, err
+		}
+
+		var dnsRecordsResult dNSRecordsResult
+		json.NewDecoder(bytes.NewReader(body)).Decode(&dnsRecordsResult)
+
+		if !dnsRecordsResult.Success {
+			return r, fmt.Errorf("Listing cloudflare dns records failed | %v | %v", dnsRecordsResult.Errors, dnsRecordsResult.Messages)
+		}
+
+		// the api filter matches a substring, so check the ownership again to be on the safe side
+		for _, dnsRecord := range dnsRecordsResult.DNSRecords {
+			if isOwnedDNSRecord(dnsRecord, cf.ownershipMarker) {
+				if dnsRecord.ZoneID == "" {
+					dnsRecord.ZoneID = zone.ID
+				}
+				r = append(r, dnsRecord)
+			}
+		}
+
+		if len(dnsRecordsResult.DNSRecords) == 0 || page*listPageSize >= dnsRecordsResult.ResultInfo.TotalCount {
+			return r, nil
+		}
+	}
+}
+
+// DeleteOwnedDNSRecord deletes a single record, as long as it carries the ownership marker in its comment.
+func (cf *Cloudflare) DeleteOwnedDNSRecord(dnsRecord DNSRecord) (err error) {
+
+	if cf.ownershipMarker == "" || !isOwnedDNSRecord(dnsRecord, cf.ownershipMarker) {
+		return fmt.Errorf("Dns record %v (%v) lacks ownership marker '%v' in its comment", dnsRecord.Name, dnsRecord.Type, cf.ownershipMarker)
+	}
+
+	_, err = cf.deleteDNSRecordByDNSRecord(dnsRecord)
+
+	return
+}
+
+// checkPausedZone warns once per zone that cloudflare doesn't serve the records of a paused zone, and returns errZonePaused for it if paused zones get skipped
+func (cf *Cloudflare) checkPausedZone(zone Zone) (Zone, error) {
+
+	if !zone.Paused {
+		cf.pausedZones.Delete(zone.ID)
+		return zone, nil
+	}
+
+	if _, alreadyLogged := cf.pausedZones.LoadOrStore(zone.ID, true); !alreadyLogged {
+		log.Warn().Msgf("Zone %v is paused, its records won't be served by Cloudflare until it's resumed", zone.Name)
+	}
+
+	if cf.skipPausedZones {
+		return Zone{}, fmt.Errorf("%w: %v", errZonePaused, zone.Name)
+	}
+
+	return zone, nil
+}
+
+// IsZoneApex returns true if the dns name is the name of the zone it's in; it returns false if the zone can't be found or its name isn't known.
+func (cf *Cloudflare) IsZoneApex(dnsName string) bool {
+
+	zone, err := cf.GetZoneByDNSName(dnsName)
+	if err != nil || zone.Name == "" {
+		return false
+	}
+
+	return strings.EqualFold(toASCIIHostname(dnsName), zone.Name)
+}
+
+// getDNSRecordsByZoneAndName lists the dns records by name, only the ones of the type unless it's empty
+func (cf *Cloudflare) getDNSRecordsByZoneAndName(zone Zone, dnsRecordName, dnsRecordType string) (r dNSRecordsResult, err error) {
+
+	if cf.dnsRecordsCache != nil {
+		if cachedResult, ok := cf.dnsRecordsCache.get(zone.ID, dnsRecordName, dnsRecordType); ok {
+			return cachedResult, nil
+		}
+	}
 
 	// create api url
-	findDNSRecordURI := fmt.Sprintf("%v/zones/%v/dns_records/?name=%v", cf.baseURL, zone.ID, dnsRecordName)
+	findDNSRecordURI := cf.getDNSRecordsURI(zone, dnsRecordName, dnsRecordType)
 
 	// fetch result from cloudflare api
-	body, err := cf.restClient.Get(findDNSRecordURI, cf.authentication)
+	body, err := cf.get(findDNSRecordURI)
 	if err != nil {
 		return r, err
 	}
@@ -103,9 +431,34 @@ func (cf *Cloudflare) getDNSRecordsByZoneAndName(zone Zone, dnsRecordName string
 		return
 	}
 
+	if cf.dnsRecordsCache != nil {
+		cf.dnsRecordsCache.set(zone.ID, dnsRecordName, dnsRecordType, r)
+	}
+
 	return
 }
 
+// getDNSRecordsURI returns the url to list the dns records by name, filtered on the type if set and on the ownership marker by the api if lookups are scoped to it
+func (cf *Cloudflare) getDNSRecordsURI(zone Zone, dnsRecordName, dnsRecordType string) string {
+
+	findDNSRecordURI := fmt.Sprintf("%v/zones/%v/dns_records/?name=%v", cf.baseURL, zone.ID, dnsRecordName)
+	if dnsRecordType != "" {
+		findDNSRecordURI += "&type=" + dnsRecordType
+	}
+	if cf.scopeRecordLookups && cf.ownershipMarker != "" {
+		findDNSRecordURI += "&comment.contains=" + url.QueryEscape(cf.ownershipMarker)
+	}
+
+	return findDNSRecordURI
+}
+
+// invalidateDNSRecords drops the cached lookup for a name after its records have been modified, whether the modification succeeded or not.
+func (cf *Cloudflare) invalidateDNSRecords(zoneID, dnsRecordName string) {
+	if cf.dnsRecordsCache != nil {
+		cf.dnsRecordsCache.invalidate(zoneID, dnsRecordName)
+	}
+}
+
 // GetDNSRecordByDNSName returns the Cloudflare dns record by looking it up with a dnsName.
 func (cf *Cloudflare) GetDNSRecordByDNSName(dnsName string) (r DNSRecord, err error) {
 
@@ -115,14 +468,20 @@ func (cf *Cloudflare) GetDNSRecordByDNSName(dnsName string) (r DNSRecord, err er
 		return r, err
 	}
 
+	return cf.GetDNSRecordByZone(zone, dnsName)
+}
+
+// GetDNSRecordByZone returns the first dns record by the name in a zone that has been looked up already.
+func (cf *Cloudflare) GetDNSRecordByZone(zone Zone, dnsName string) (r DNSRecord, err error) {
+
 	// get dns record
-	dnsRecordsResult, err := cf.getDNSRecordsByZoneAndName(zone, dnsName)
+	dnsRecordsResult, err := cf.getDNSRecordsByZoneAndName(zone, dnsName, "")
 	if err != nil {
 		return r, err
 	}
 
 	if dnsRecordsResult.ResultInfo.Count == 0 {
-		err = errors.New("No matching dns record has been found")
+		err = errDNSRecordNotFound
 		return
 	}
 
@@ -131,14 +490,38 @@ func (cf *Cloudflare) GetDNSRecordByDNSName(dnsName string) (r DNSRecord, err er
 	return
 }
 
-func (cf *Cloudflare) createDNSRecordByZone(zone Zone, dnsRecordType, dnsRecordName, dnsRecordContent string) (r createResult, err error) {
+// GetDNSRecordsByDNSName returns all dns records by the name, for names with a record per ip address.
+func (cf *Cloudflare) GetDNSRecordsByDNSName(dnsName string) (r []DNSRecord, err error) {
 
-	// create record at cloudflare api
-	newDNSRecord := DNSRecord{Type: dnsRecordType, Name: dnsRecordName, Content: dnsRecordContent}
+	// get zone
+	zone, err := cf.GetZoneByDNSName(dnsName)
+	if err != nil {
+		return r, err
+	}
+
+	// get dns records
+	dnsRecordsResult, err := cf.getDNSRecordsByZoneAndName(zone, dnsName, "")
+	if err != nil {
+		return r, err
+	}
+
+	if dnsRecordsResult.ResultInfo.Count == 0 {
+		err = errDNSRecordNotFound
+		return
+	}
+
+	return dnsRecordsResult.DNSRecords, nil
+}
+
+func (cf *Cloudflare) createDNSRecordByZone(zone Zone, dnsRecordType, dnsRecordName, dnsRecordContent string, proxy bool, dnsRecordComment, dnsRecordRegion string, ttl int, dnsRecordData interface{}) (r createResult, err error) {
+
+	// create record at cloudflare api, with the proxy setting and ttl applied right away so it never exists with the wrong ones
+	newDNSRecord := DNSRecord{Type: dnsRecordType, Name: dnsRecordName, Content: dnsRecordContent, Proxied: proxy, TTL: getTTLForProxySetting(dnsRecordName, ttl, proxy), Comment: addOwnershipMarker(dnsRecordComment, cf.ownershipMarker), Region: dnsRecordRegion, Data: dnsRecordData}
 
 	createDNSRecordURI := fmt.Sprintf("%v/zones/%v/dns_records", cf.baseURL, zone.ID)
 
-	body, err := cf.restClient.Post(createDNSRecordURI, newDNSRecord, cf.authentication)
+	body, err := cf.post(createDNSRecordURI, newDNSRecord)
+	cf.invalidateDNSRecords(zone.ID, dnsRecordName)
 	if err != nil {
 		return r, err
 	}
@@ -164,7 +547,7 @@ func (cf *Cloudflare) CreateDNSRecord(dnsRecordType, dnsRecordName, dnsRecordCon
 
 	// create record at cloudflare api
 	var cloudflareDNSRecordsCreateResult createResult
-	cloudflareDNSRecordsCreateResult, err = cf.createDNSRecordByZone(zone, dnsRecordType, dnsRecordName, dnsRecordContent)
+	cloudflareDNSRecordsCreateResult, err = cf.createDNSRecordByZone(zone, dnsRecordType, dnsRecordName, dnsRecordContent, false, "", "", 0, nil)
 	if err != nil {
 		return
 	}
@@ -178,7 +561,8 @@ func (cf *Cloudflare) deleteDNSRecordByDNSRecord(dnsRecord DNSRecord) (r deleteR
 
 	// delete dns record
 	deleteDNSRecordURI := fmt.Sprintf("%v/zones/%v/dns_records/%v", cf.baseURL, dnsRecord.ZoneID, dnsRecord.ID)
-	body, err := cf.restClient.Delete(deleteDNSRecordURI, cf.authentication)
+	body, err := cf.delete(deleteDNSRecordURI)
+	cf.invalidateDNSRecords(dnsRecord.ZoneID, dnsRecord.Name)
 	if err != nil {
 		return r, err
 	}
@@ -195,29 +579,42 @@ func (cf *Cloudflare) deleteDNSRecordByDNSRecord(dnsRecord DNSRecord) (r deleteR
 
 func (cf *Cloudflare) deleteDNSRecordByZone(zone Zone, dnsRecordName string) (r bool, err error) {
 
-	// get dns record
-	dnsRecordsResult, err := cf.getDNSRecordsByZoneAndName(zone, dnsRecordName)
+	// get dns records of all types
+	dnsRecordsResult, err := cf.getDNSRecordsByZoneAndName(zone, dnsRecordName, "")
 	if err != nil {
 		return r, err
 	}
 	if dnsRecordsResult.ResultInfo.Count == 0 {
-		err = errors.New("No matching dns record has been found")
+		err = errDNSRecordNotFound
 		return
 	}
-	dnsRecord := dnsRecordsResult.DNSRecords[0]
 
-	// delete dns record
-	_, err = cf.deleteDNSRecordByDNSRecord(dnsRecord)
-	if err != nil {
-		return
+	// delete all records, a hostname can accidentally have more than one
+	for _, dnsRecord := range dnsRecordsResult.DNSRecords {
+
+		// check if the record is owned by this controller
+		if !isOwnedDNSRecord(dnsRecord, cf.ownershipMarker) {
+			log.Warn().Msgf("Skipping deletion of dns record %v (%v), because it lacks ownership marker '%v' in its comment", dnsRecordName, dnsRecord.Type, cf.ownershipMarker)
+			continue
+		}
+
+		// delete dns record
+		_, err = cf.deleteDNSRecordByDNSRecord(dnsRecord)
+		if err != nil {
+			return
+		}
+
+		r = true
 	}
 
-	r = true
+	if !r {
+		err = errDNSRecordNotOwned
+	}
 
 	return
 }
 
-// DeleteDNSRecord deletes a dns record.
+// DeleteDNSRecord deletes all dns records by that name.
 func (cf *Cloudflare) DeleteDNSRecord(dnsRecordName string) (r bool, err error) {
 
 	// get zone
@@ -229,7 +626,7 @@ func (cf *Cloudflare) DeleteDNSRecord(dnsRecordName string) (r bool, err error)
 	return cf.deleteDNSRecordByZone(zone, dnsRecordName)
 }
 
-// DeleteDNSRecordIfMatching deletes a dns record only if the type and content match.
+// DeleteDNSRecordIfMatching deletes all dns records by that name whose type and content match.
 func (cf *Cloudflare) DeleteDNSRecordIfMatching(dnsRecordName, dnsRecordType, dnsRecordContent string) (r bool, err error) {
 
 	// get zone
@@ -238,35 +635,53 @@ func (cf *Cloudflare) DeleteDNSRecordIfMatching(dnsRecordName, dnsRecordType, dn
 		return r, err
 	}
 
-	// get dns record
-	dnsRecordsResult, err := cf.getDNSRecordsByZoneAndName(zone, dnsRecordName)
+	// get dns records of the type
+	dnsRecordsResult, err := cf.getDNSRecordsByZoneAndName(zone, dnsRecordName, dnsRecordType)
 	if err != nil {
 		return r, err
 	}
 	if dnsRecordsResult.ResultInfo.Count == 0 {
-		err = errors.New("No matching dns record has been found")
+		err = errDNSRecordNotFound
 		return
 	}
-	dnsRecord := dnsRecordsResult.DNSRecords[0]
 
-	// check if type and content match
-	if dnsRecord.Type != dnsRecordType || dnsRecord.Content != dnsRecordContent {
-		err = errors.New("Type or content does not match")
-		return
+	// delete all records with matching type and content, a hostname can accidentally have more than one
+	matched := false
+	for _, dnsRecord := range dnsRecordsResult.DNSRecords {
+
+		// check if type and content match
+		if dnsRecord.Type != dnsRecordType || dnsRecord.Content != dnsRecordContent {
+			continue
+		}
+		matched = true
+
+		// check if the record is owned by this controller
+		if !isOwnedDNSRecord(dnsRecord, cf.ownershipMarker) {
+			log.Warn().Msgf("Skipping deletion of dns record %v (%v), because it lacks ownership marker '%v' in its comment", dnsRecordName, dnsRecord.Type, cf.ownershipMarker)
+			continue
+		}
+
+		// delete dns record
+		_, err = cf.deleteDNSRecordByDNSRecord(dnsRecord)
+		if err != nil {
+			return
+		}
+
+		r = true
 	}
 
-	// delete dns record
-	_, err = cf.deleteDNSRecordByDNSRecord(dnsRecord)
-	if err != nil {
+	if !matched {
+		err = errDNSRecordNotMatching
 		return
 	}
-
-	r = true
+	if !r {
+		err = errDNSRecordNotOwned
+	}
 
 	return
 }
 
-func (cf *Cloudflare) updateDNSRecordByDNSRecord(dnsRecord DNSRecord, dnsRecordType, dnsRecordContent string) (r updateResult, err error) {
+func (cf *Cloudflare) updateDNSRecordByDNSRecord(dnsRecord DNSRecord, dnsRecordType, dnsRecordContent, dnsRecordComment string) (r updateResult, err error) {
 
 	// check dnsRecordType
 	if dnsRecord.Type != dnsRecordType {
@@ -275,10 +690,14 @@ func (cf *Cloudflare) updateDNSRecordByDNSRecord(dnsRecord DNSRecord, dnsRecordT
 	}
 
 	dnsRecord.Content = dnsRecordContent
+	if dnsRecordComment != "" {
+		dnsRecord.Comment = addOwnershipMarker(dnsRecordComment, cf.ownershipMarker)
+	}
 
 	updateDNSRecordURI := fmt.Sprintf("%v/zones/%v/dns_records/%v", cf.baseURL, dnsRecord.ZoneID, dnsRecord.ID)
 
-	body, err := cf.restClient.Put(updateDNSRecordURI, dnsRecord, cf.authentication)
+	body, err := cf.put(updateDNSRecordURI, dnsRecord)
+	cf.invalidateDNSRecords(dnsRecord.ZoneID, dnsRecord.Name)
 	if err != nil {
 		return r, err
 	}
@@ -295,19 +714,19 @@ func (cf *Cloudflare) updateDNSRecordByDNSRecord(dnsRecord DNSRecord, dnsRecordT
 
 func (cf *Cloudflare) updateDNSRecordByZone(zone Zone, dnsRecordType, dnsRecordName, dnsRecordContent string) (r DNSRecord, err error) {
 
-	// get dns record
-	dnsRecordsResult, err := cf.getDNSRecordsByZoneAndName(zone, dnsRecordName)
+	// get dns record of the type
+	dnsRecordsResult, err := cf.getDNSRecordsByZoneAndName(zone, dnsRecordName, dnsRecordType)
 	if err != nil {
 		return r, err
 	}
 	if dnsRecordsResult.ResultInfo.Count == 0 {
-		err = errors.New("No matching dns record has been found")
+		err = errDNSRecordNotFound
 		return
 	}
 
 	r = dnsRecordsResult.DNSRecords[0]
 
-	cloudflareDNSRecordsUpdateResult, err := cf.updateDNSRecordByDNSRecord(r, dnsRecordType, dnsRecordContent)
+	cloudflareDNSRecordsUpdateResult, err := cf.updateDNSRecordByDNSRecord(r, dnsRecordType, dnsRecordContent, "")
 	if err != nil {
 		return r, err
 	}
@@ -329,24 +748,37 @@ func (cf *Cloudflare) UpdateDNSRecord(dnsRecordType, dnsRecordName, dnsRecordCon
 	return cf.updateDNSRecordByZone(zone, dnsRecordType, dnsRecordName, dnsRecordContent)
 }
 
-// UpsertDNSRecord either updates or creates a dns record.
-func (cf *Cloudflare) UpsertDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent string, proxy bool) (r DNSRecord, err error) {
+// UpsertDNSRecord either updates or creates a dns record; an empty region leaves the record without regional services and a ttl of 0 leaves it automatic for new records and as is for existing ones. It reports whether anything got written, which isn't the case for a record that's up to date already.
+func (cf *Cloudflare) UpsertDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent string, proxy bool, dnsRecordComment, dnsRecordRegion string, ttl int) (r DNSRecord, changed bool, err error) {
 
 	// get zone
 	zone, err := cf.GetZoneByDNSName(dnsRecordName)
 	if err != nil {
-		return r, err
+		return r, false, err
 	}
 
+	return cf.UpsertDNSRecordByZone(zone, dnsRecordType, dnsRecordName, dnsRecordContent, proxy, dnsRecordComment, dnsRecordRegion, ttl)
+}
+
+// UpsertDNSRecordByZone either creates or updates a dns record in a zone that has been looked up already.
+func (cf *Cloudflare) UpsertDNSRecordByZone(zone Zone, dnsRecordType, dnsRecordName, dnsRecordContent string, proxy bool, dnsRecordComment, dnsRecordRegion string, ttl int) (r DNSRecord, changed bool, err error) {
+
 	log.Debug().Msgf("Retrieved zone for %v name: %v, id: %v", dnsRecordName, zone.Name, zone.ID)
 
-	// get dns record
-	dnsRecordsResult, err := cf.getDNSRecordsByZoneAndName(zone, dnsRecordName)
+	// not every api response includes the zone name, so fill it in from the zone the record is upserted in
+	defer func() {
+		if err == nil && r.ZoneName == "" {
+			r.ZoneName = zone.Name
+		}
+	}()
+
+	// get dns record of the type, so records of other types by the same name like TXT records don't get in the way
+	dnsRecordsResult, err := cf.getDNSRecordsByZoneAndName(zone, dnsRecordName, dnsRecordType)
 	if err != nil {
-		return r, err
+		return r, false, err
 	}
 
-	log.Debug().Msgf("Retrieved %v dns record(s) for %v: %v", dnsRecordsResult.ResultInfo.Count, dnsRecordName, dnsRecordsResult)
+	log.Debug().Msgf("Retrieved %v %v dns record(s) for %v: %v", dnsRecordsResult.ResultInfo.Count, dnsRecordType, dnsRecordName, dnsRecordsResult)
 
 	if dnsRecordsResult.ResultInfo.Count > 1 {
 		err = errors.New("Cannot upsert, there's more than 1 record by that name")
@@ -355,73 +787,656 @@ func (cf *Cloudflare) UpsertDNSRecord(dnsRecordType, dnsRecordName, dnsRecordCon
 
 		r = dnsRecordsResult.DNSRecords[0]
 
-		if dnsRecordType != r.Type {
+		// leave records created by others alone
+		if !isOwnedDNSRecord(r, cf.ownershipMarker) {
+			log.Warn().Msgf("Skipping upsert of dns record %v, because it lacks ownership marker '%v' in its comment", dnsRecordName, cf.ownershipMarker)
+			return r, false, errDNSRecordNotOwned
+		}
 
-			// delete record of old type
-			_, err = cf.deleteDNSRecordByDNSRecord(r)
-			if err != nil {
-				return
-			}
+		observeDNSRecordAge(r, zone.Name, timeNow())
 
-			// create record of new type
-			var cloudflareDNSRecordsCreateResult createResult
-			cloudflareDNSRecordsCreateResult, err = cf.createDNSRecordByZone(zone, dnsRecordType, dnsRecordName, dnsRecordContent)
-			if err != nil {
-				return
-			}
+		// cloudflare refuses to proxy some records, for example on restricted plans or for private ip addresses, so keep those dns only instead of failing
+		if proxy && !r.Proxiable && !r.Proxied {
+			log.Warn().Msgf("Dns record %v (%v) in zone %v isn't proxiable, leaving it dns only", dnsRecordName, dnsRecordType, zone.Name)
+			proxy = false
+		}
+
+		desiredTTL := getTTLForProxySetting(dnsRecordName, r.TTL, proxy)
+		if ttl > 0 {
+			desiredTTL = getTTLForProxySetting(dnsRecordName, ttl, proxy)
+		}
+
+		// leave a record that matches already alone, so repeated reconciles don't update it over and over
+		if isDNSRecordUpToDate(r, dnsRecordContent, proxy, desiredTTL, addOwnershipMarker(dnsRecordComment, cf.ownershipMarker), dnsRecordRegion) {
+			log.Debug().Msgf("Dns record %v is up to date, skipping update", dnsRecordName)
+			return
+		}
+
+		// current record is proxied, but is desired not to be proxied; change first because the new record might not allow proxying
+		if r.Proxied && !proxy {
+			r.Proxied = proxy
+		}
+
+		r.TTL = desiredTTL
+		r.Region = dnsRecordRegion
+
+		// update record
+		var cloudflareDNSRecordsUpdateResult updateResult
+		cloudflareDNSRecordsUpdateResult, err = cf.updateDNSRecordByDNSRecord(r, dnsRecordType, dnsRecordContent, dnsRecordComment)
+		if err != nil {
+			return
+		}
+
+		r = cloudflareDNSRecordsUpdateResult.DNSRecord
+		changed = true
+
+		return
+	}
 
-			r = cloudflareDNSRecordsCreateResult.DNSRecord
+	// a name can only have a single address record type, so replace a record of another address type when changing between them
+	if isAddressDNSRecordType(dnsRecordType) {
+		var addressDNSRecord DNSRecord
+		addressDNSRecord, err = cf.getOtherAddressDNSRecordByZone(zone, dnsRecordType, dnsRecordName)
+		if err != nil {
+			return
+		}
 
-		} else {
+		if addressDNSRecord.ID != "" {
 
-			// current record is proxied, but is desired not to be proxied; change first because the new record might not allow proxying
-			if r.Proxied && !proxy {
-				r.Proxied = proxy
+			// leave records created by others alone
+			if !isOwnedDNSRecord(addressDNSRecord, cf.ownershipMarker) {
+				log.Warn().Msgf("Skipping upsert of dns record %v, because its %v record lacks ownership marker '%v' in its comment", dnsRecordName, addressDNSRecord.Type, cf.ownershipMarker)
+				return addressDNSRecord, false, errDNSRecordNotOwned
 			}
 
-			// update record
-			var cloudflareDNSRecordsUpdateResult updateResult
-			cloudflareDNSRecordsUpdateResult, err = cf.updateDNSRecordByDNSRecord(r, dnsRecordType, dnsRecordContent)
+			// delete record of old type
+			_, err = cf.deleteDNSRecordByDNSRecord(addressDNSRecord)
 			if err != nil {
 				return
 			}
-
-			r = cloudflareDNSRecordsUpdateResult.DNSRecord
-
+			changed = true
 		}
+	}
 
-		return
+	// only address records can be proxied, creating any other record with proxying enabled fails
+	if proxy && !isAddressDNSRecordType(dnsRecordType) {
+		log.Warn().Msgf("Dns record %v (%v) in zone %v can't be proxied, creating it dns only", dnsRecordName, dnsRecordType, zone.Name)
+		proxy = false
 	}
 
 	// create record
 	var cloudflareDNSRecordsCreateResult createResult
-	cloudflareDNSRecordsCreateResult, err = cf.createDNSRecordByZone(zone, dnsRecordType, dnsRecordName, dnsRecordContent)
+	cloudflareDNSRecordsCreateResult, err = cf.createDNSRecordByZone(zone, dnsRecordType, dnsRecordName, dnsRecordContent, proxy, dnsRecordComment, dnsRecordRegion, ttl, nil)
 	if err != nil {
 		return
 	}
 
 	r = cloudflareDNSRecordsCreateResult.DNSRecord
 
+	changed = true
+
 	return
 }
 
-// UpdateProxySetting updates the proxied setting for an existing dns record.
-func (cf *Cloudflare) UpdateProxySetting(dnsRecordName string, proxy bool) (r DNSRecord, err error) {
+// getOtherAddressDNSRecordByZone returns the A, AAAA or CNAME record by the name that's of another type than the one given, or an empty record if there's none
+func (cf *Cloudflare) getOtherAddressDNSRecordByZone(zone Zone, dnsRecordType, dnsRecordName string) (r DNSRecord, err error) {
 
-	// get zone
-	zone, err := cf.GetZoneByDNSName(dnsRecordName)
+	// get dns records of all types
+	dnsRecordsResult, err := cf.getDNSRecordsByZoneAndName(zone, dnsRecordName, "")
 	if err != nil {
 		return r, err
 	}
 
+	found := false
+	for _, dnsRecord := range dnsRecordsResult.DNSRecords {
+		if dnsRecord.Type == dnsRecordType || !isAddressDNSRecordType(dnsRecord.Type) {
+			continue
+		}
+		if found {
+			err = errors.New("Cannot upsert, there's more than 1 record by that name")
+			return
+		}
+		r = dnsRecord
+		found = true
+	}
+
+	return
+}
+
+// UpsertDNSRecordSet makes sure a name has a record of the type for each of the contents, for round-robin dns; records of this controller for other contents are deleted, as are ones of other address types. The ttl is left automatic if 0.
+func (cf *Cloudflare) UpsertDNSRecordSet(dnsRecordType, dnsRecordName string, dnsRecordContents []string, proxy bool, dnsRecordComment, dnsRecordRegion string, ttl int) (r []DNSRecord, changed bool, err error) {
+
+	// get zone
+	zone, err := cf.GetZoneByDNSName(dnsRecordName)
+	if err != nil {
+		return r, false, err
+	}
+
+	return cf.UpsertDNSRecordSetByZone(zone, dnsRecordType, dnsRecordName, dnsRecordContents, proxy, dnsRecordComment, dnsRecordRegion, ttl)
+}
+
+// UpsertDNSRecordSetByZone makes sure a name has a record of the type for each of the contents, in a zone that has been looked up already.
+func (cf *Cloudflare) UpsertDNSRecordSetByZone(zone Zone, dnsRecordType, dnsRecordName string, dnsRecordContents []string, proxy bool, dnsRecordComment, dnsRecordRegion string, ttl int) (r []DNSRecord, changed bool, err error) {
+
+	// not every api response includes the zone name, so fill it in from the zone the records are upserted in
+	defer func() {
+		for i := range r {
+			if r[i].ZoneName == "" {
+				r[i].ZoneName = zone.Name
+			}
+		}
+	}()
+
+	// get dns records of all types, to replace the ones of other address types
+	dnsRecordsResult, err := cf.getDNSRecordsByZoneAndName(zone, dnsRecordName, "")
+	if err != nil {
+		return r, false, err
+	}
+
+	desiredContents := map[string]bool{}
+	for _, dnsRecordContent := range dnsRecordContents {
+		desiredContents[strings.ToLower(dnsRecordContent)] = true
+	}
+
+	// keep the records for desired contents, updating them if needed, and delete the other address records
+	existingContents := map[string]bool{}
+	for _, dnsRecord := range dnsRecordsResult.DNSRecords {
+
+		if !isAddressDNSRecordType(dnsRecord.Type) {
+			continue
+		}
+
+		// leave records created by others alone
+		if !isOwnedDNSRecord(dnsRecord, cf.ownershipMarker) {
+			log.Warn().Msgf("Skipping dns record %v (%v) to %v, because it lacks ownership marker '%v' in its comment", dnsRecordName, dnsRecord.Type, dnsRecord.Content, cf.ownershipMarker)
+			continue
+		}
+
+		dnsRecordContent := strings.ToLower(dnsRecord.Content)
+		if dnsRecord.Type != dnsRecordType || !desiredContents[dnsRecordContent] || existingContents[dnsRecordContent] {
+			_, err = cf.deleteDNSRecordByDNSRecord(dnsRecord)
+			if err != nil {
+				return
+			}
+			changed = true
+			continue
+		}
+		existingContents[dnsRecordContent] = true
+
+		observeDNSRecordAge(dnsRecord, zone.Name, timeNow())
+
+		desiredTTL := getTTLForProxySetting(dnsRecordName, dnsRecord.TTL, proxy)
+		if ttl > 0 {
+			desiredTTL = getTTLForProxySetting(dnsRecordName, ttl, proxy)
+		}
+
+		// leave a record that matches already alone, so repeated reconciles don't update it over and over
+		if dnsRecord.Proxied == (proxy && dnsRecord.Proxiable) && isDNSRecordUpToDate(dnsRecord, dnsRecord.Content, proxy, desiredTTL, addOwnershipMarker(dnsRecordComment, cf.ownershipMarker), dnsRecordRegion) {
+			r = append(r, dnsRecord)
+			continue
+		}
+
+		dnsRecord.Proxied = proxy && dnsRecord.Proxiable
+		dnsRecord.TTL = desiredTTL
+		dnsRecord.Region = dnsRecordRegion
+
+		var cloudflareDNSRecordsUpdateResult updateResult
+		cloudflareDNSRecordsUpdateResult, err = cf.updateDNSRecordByDNSRecord(dnsRecord, dnsRecordType, dnsRecord.Content, dnsRecordComment)
+		if err != nil {
+			return
+		}
+
+		changed = true
+
+		r = append(r, cloudflareDNSRecordsUpdateResult.DNSRecord)
+	}
+
+	// create the records for contents that don't have one yet
+	for _, dnsRecordContent := range dnsRecordContents {
+		if existingContents[strings.ToLower(dnsRecordContent)] {
+			continue
+		}
+
+		var cloudflareDNSRecordsCreateResult createResult
+		cloudflareDNSRecordsCreateResult, err = cf.createDNSRecordByZone(zone, dnsRecordType, dnsRecordName, dnsRecordContent, proxy, dnsRecordComment, dnsRecordRegion, ttl, nil)
+		if err != nil {
+			return
+		}
+		changed = true
+		existingContents[strings.ToLower(dnsRecordContent)] = true
+
+		r = append(r, cloudflareDNSRecordsCreateResult.DNSRecord)
+	}
+
+	return
+}
+
+// UpsertStructuredDNSRecords makes the records of a type whose content cloudflare derives from its structured data, like srv and loc records, match the data for a name; a name can have more than one of them, so like caa records they're reconciled as a set. The data has to be comparable structs like SRVRecordData.
+func (cf *Cloudflare) UpsertStructuredDNSRecords(dnsRecordType, dnsRecordName string, dnsRecordsData []interface{}, dnsRecordComment string) (r []DNSRecord, err error) {
+
+	// get zone
+	zone, err := cf.GetZoneByDNSName(dnsRecordName)
+	if err != nil {
+		return r, err
+	}
+
+	return cf.UpsertStructuredDNSRecordsByZone(zone, dnsRecordType, dnsRecordName, dnsRecordsData, dnsRecordComment)
+}
+
+// UpsertStructuredDNSRecordsByZone makes the srv or loc records for a name match the structured data in a zone that has been looked up already.
+func (cf *Cloudflare) UpsertStructuredDNSRecordsByZone(zone Zone, dnsRecordType, dnsRecordName string, dnsRecordsData []interface{}, dnsRecordComment string) (r []DNSRecord, err error) {
+
+	// get dns records
+	dnsRecordsResult, err := cf.getDNSRecordsByZoneAndName(zone, dnsRecordName, dnsRecordType)
+	if err != nil {
+		return r, err
+	}
+
+	// keep the records with desired data, the other owned ones can be reused for missing data
+	existingDNSRecordsData := make([]bool, len(dnsRecordsData))
+	superfluousDNSRecords := []DNSRecord{}
+	for _, dnsRecord := range dnsRecordsResult.DNSRecords {
+		if dnsRecord.Type != dnsRecordType {
+			continue
+		}
+
+		index := getMissingDNSRecordDataIndex(dnsRecordsData, existingDNSRecordsData, dnsRecord.Data)
+		if index >= 0 {
+			existingDNSRecordsData[index] = true
+
+			// update the comment of an owned record if it changed
+			if isOwnedDNSRecord(dnsRecord, cf.ownershipMarker) && dnsRecordComment != "" && dnsRecord.Comment != addOwnershipMarker(dnsRecordComment, cf.ownershipMarker) {
+				var cloudflareDNSRecordsUpdateResult updateResult
+				cloudflareDNSRecordsUpdateResult, err = cf.updateDNSRecordByDNSRecord(dnsRecord, dnsRecordType, "", dnsRecordComment)
+				if err != nil {
+					return
+				}
+				dnsRecord = cloudflareDNSRecordsUpdateResult.DNSRecord
+			}
+
+			r = append(r, dnsRecord)
+			continue
+		}
+
+		// leave records created by others alone
+		if !isOwnedDNSRecord(dnsRecord, cf.ownershipMarker) {
+			log.Warn().Msgf("Skipping update of dns record %v (%v) with data %v, because it lacks ownership marker '%v' in its comment", dnsRecordName, dnsRecordType, dnsRecord.Data, cf.ownershipMarker)
+			continue
+		}
+
+		superfluousDNSRecords = append(superfluousDNSRecords, dnsRecord)
+	}
+
+	for i, dnsRecordData := range dnsRecordsData {
+		if existingDNSRecordsData[i] {
+			continue
+		}
+
+		// update a superfluous record with the missing data
+		if len(superfluousDNSRecords) > 0 {
+			dnsRecord := superfluousDNSRecords[0]
+			superfluousDNSRecords = superfluousDNSRecords[1:]
+
+			// the content is derived from the structured data by cloudflare
+			dnsRecord.Data = dnsRecordData
+
+			var cloudflareDNSRecordsUpdateResult updateResult
+			cloudflareDNSRecordsUpdateResult, err = cf.updateDNSRecordByDNSRecord(dnsRecord, dnsRecordType, "", dnsRecordComment)
+			if err != nil {
+				return
+			}
+
+			r = append(r, cloudflareDNSRecordsUpdateResult.DNSRecord)
+			continue
+		}
+
+		// or create a new one
+		var cloudflareDNSRecordsCreateResult createResult
+		cloudflareDNSRecordsCreateResult, err = cf.createDNSRecordByZone(zone, dnsRecordType, dnsRecordName, "", false, dnsRecordComment, "", 0, dnsRecordData)
+		if err != nil {
+			return
+		}
+
+		r = append(r, cloudflareDNSRecordsCreateResult.DNSRecord)
+	}
+
+	// delete the records that weren't reused
+	for _, dnsRecord := range superfluousDNSRecords {
+		_, err = cf.deleteDNSRecordByDNSRecord(dnsRecord)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// DeleteStructuredDNSRecordIfMatching deletes a record of a type with structured data, like srv and loc records, only if its data matches; other records by that name are left alone.
+func (cf *Cloudflare) DeleteStructuredDNSRecordIfMatching(dnsRecordType, dnsRecordName string, dnsRecordData interface{}) (r bool, err error) {
+
+	// get zone
+	zone, err := cf.GetZoneByDNSName(dnsRecordName)
+	if err != nil {
+		return r, err
+	}
+
+	// get dns records
+	dnsRecordsResult, err := cf.getDNSRecordsByZoneAndName(zone, dnsRecordName, dnsRecordType)
+	if err != nil {
+		return r, err
+	}
+	if dnsRecordsResult.ResultInfo.Count == 0 {
+		err = errDNSRecordNotFound
+		return
+	}
+
+	for _, dnsRecord := range dnsRecordsResult.DNSRecords {
+
+		// check if type and data match
+		if dnsRecord.Type != dnsRecordType || !isDNSRecordDataMatching(dnsRecord.Data, dnsRecordData) {
+			continue
+		}
+
+		// check if the record is owned by this controller
+		if !isOwnedDNSRecord(dnsRecord, cf.ownershipMarker) {
+			return r, errDNSRecordNotOwned
+		}
+
+		// delete dns record
+		_, err = cf.deleteDNSRecordByDNSRecord(dnsRecord)
+		if err != nil {
+			return r, err
+		}
+
+		return true, nil
+	}
+
+	err = errors.New("Type or data does not match")
+
+	return
+}
+
+// UpsertCAARecords makes the caa records for a name match the structured data, by updating, creating or deleting records; like ns records there's usually more than one per name, so they're reconciled as a set.
+func (cf *Cloudflare) UpsertCAARecords(dnsRecordName string, caaRecordsData []CAARecordData, dnsRecordComment string) (r []DNSRecord, err error) {
+
+	// get zone
+	zone, err := cf.GetZoneByDNSName(dnsRecordName)
+	if err != nil {
+		return r, err
+	}
+
+	return cf.UpsertCAARecordsByZone(zone, dnsRecordName, caaRecordsData, dnsRecordComment)
+}
+
+// UpsertCAARecordsByZone makes the caa records for a name match the structured data in a zone that has been looked up already.
+func (cf *Cloudflare) UpsertCAARecordsByZone(zone Zone, dnsRecordName string, caaRecordsData []CAARecordData, dnsRecordComment string) (r []DNSRecord, err error) {
+
+	// get dns records
+	dnsRecordsResult, err := cf.getDNSRecordsByZoneAndName(zone, dnsRecordName, "CAA")
+	if err != nil {
+		return r, err
+	}
+
+	// keep the records with desired data, the other owned ones can be reused for missing data
+	existingCAARecordsData := map[CAARecordData]bool{}
+	superfluousDNSRecords := []DNSRecord{}
+	for _, dnsRecord := range dnsRecordsResult.DNSRecords {
+		if dnsRecord.Type != "CAA" {
+			continue
+		}
+
+		currentCAARecordData, err := getCAARecordData(dnsRecord.Data)
+		if err != nil {
+			return r, err
+		}
+
+		if containsCAARecordData(caaRecordsData, currentCAARecordData) && !existingCAARecordsData[currentCAARecordData] {
+			existingCAARecordsData[currentCAARecordData] = true
+			r = append(r, dnsRecord)
+			continue
+		}
+
+		// leave records created by others alone
+		if !isOwnedDNSRecord(dnsRecord, cf.ownershipMarker) {
+			log.Warn().Msgf("Skipping update of dns record %v (CAA) with data %v, because it lacks ownership marker '%v' in its comment", dnsRecordName, currentCAARecordData, cf.ownershipMarker)
+			continue
+		}
+
+		superfluousDNSRecords = append(superfluousDNSRecords, dnsRecord)
+	}
+
+	for _, caaRecordData := range caaRecordsData {
+		if existingCAARecordsData[caaRecordData] {
+			continue
+		}
+		existingCAARecordsData[caaRecordData] = true
+
+		// update a superfluous record with the missing data
+		if len(superfluousDNSRecords) > 0 {
+			dnsRecord := superfluousDNSRecords[0]
+			superfluousDNSRecords = superfluousDNSRecords[1:]
+
+			// the content of caa records is derived from the structured data by cloudflare
+			dnsRecord.Data = caaRecordData
+
+			var cloudflareDNSRecordsUpdateResult updateResult
+			cloudflareDNSRecordsUpdateResult, err = cf.updateDNSRecordByDNSRecord(dnsRecord, "CAA", "", dnsRecordComment)
+			if err != nil {
+				return
+			}
+
+			r = append(r, cloudflareDNSRecordsUpdateResult.DNSRecord)
+			continue
+		}
+
+		// or create a new one
+		var cloudflareDNSRecordsCreateResult createResult
+		cloudflareDNSRecordsCreateResult, err = cf.createDNSRecordByZone(zone, "CAA", dnsRecordName, "", false, dnsRecordComment, "", 0, caaRecordData)
+		if err != nil {
+			return
+		}
+
+		r = append(r, cloudflareDNSRecordsCreateResult.DNSRecord)
+	}
+
+	// delete the records that weren't reused
+	for _, dnsRecord := range superfluousDNSRecords {
+		_, err = cf.deleteDNSRecordByDNSRecord(dnsRecord)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// DeleteCAARecordIfMatching deletes a caa record only if its structured data matches.
+func (cf *Cloudflare) DeleteCAARecordIfMatching(dnsRecordName string, caaRecordData CAARecordData) (r bool, err error) {
+
+	// get zone
+	zone, err := cf.GetZoneByDNSName(dnsRecordName)
+	if err != nil {
+		return r, err
+	}
+
+	// get dns records
+	dnsRecordsResult, err := cf.getDNSRecordsByZoneAndName(zone, dnsRecordName, "CAA")
+	if err != nil {
+		return r, err
+	}
+
+	for _, dnsRecord := range dnsRecordsResult.DNSRecords {
+		if dnsRecord.Type != "CAA" {
+			continue
+		}
+
+		// check if data matches
+		currentCAARecordData, err := getCAARecordData(dnsRecord.Data)
+		if err != nil || currentCAARecordData != caaRecordData {
+			continue
+		}
+
+		// check if the record is owned by this controller
+		if !isOwnedDNSRecord(dnsRecord, cf.ownershipMarker) {
+			return r, errDNSRecordNotOwned
+		}
+
+		// delete dns record
+		_, err = cf.deleteDNSRecordByDNSRecord(dnsRecord)
+		if err != nil {
+			return r, err
+		}
+
+		return true, nil
+	}
+
+	err = errors.New("Type or data does not match")
+
+	return
+}
+
+// UpsertNSRecords makes the ns records for a delegated name match the nameservers, by creating missing and deleting superfluous records; multiple ns records per name are normal, so unlike other types these are reconciled as a set.
+func (cf *Cloudflare) UpsertNSRecords(dnsRecordName string, nameservers []string, dnsRecordComment string) (r []DNSRecord, err error) {
+
+	// get zone
+	zone, err := cf.GetZoneByDNSName(dnsRecordName)
+	if err != nil {
+		return r, err
+	}
+
+	return cf.UpsertNSRecordsByZone(zone, dnsRecordName, nameservers, dnsRecordComment)
+}
+
+// UpsertNSRecordsByZone makes the ns records for a delegated name match the nameservers in a zone that has been looked up already.
+func (cf *Cloudflare) UpsertNSRecordsByZone(zone Zone, dnsRecordName string, nameservers []string, dnsRecordComment string) (r []DNSRecord, err error) {
+
+	// get dns records
+	dnsRecordsResult, err := cf.getDNSRecordsByZoneAndName(zone, dnsRecordName, "")
+	if err != nil {
+		return r, err
+	}
+
+	// a delegated name can't have any other records
+	for _, dnsRecord := range dnsRecordsResult.DNSRecords {
+		if dnsRecord.Type != "NS" {
+			err = fmt.Errorf("Cannot upsert ns records, there's already a record of type %v by that name", dnsRecord.Type)
+			return
+		}
+	}
+
+	desiredNameservers := map[string]bool{}
+	for _, nameserver := range nameservers {
+		desiredNameservers[strings.ToLower(nameserver)] = true
+	}
+
+	// keep the records for desired nameservers and delete the others
+	existingNameservers := map[string]bool{}
+	for _, dnsRecord := range dnsRecordsResult.DNSRecords {
+		if desiredNameservers[strings.ToLower(dnsRecord.Content)] {
+			existingNameservers[strings.ToLower(dnsRecord.Content)] = true
+			r = append(r, dnsRecord)
+			continue
+		}
+
+		// leave records created by others alone
+		if !isOwnedDNSRecord(dnsRecord, cf.ownershipMarker) {
+			log.Warn().Msgf("Skipping deletion of dns record %v (NS) to %v, because it lacks ownership marker '%v' in its comment", dnsRecordName, dnsRecord.Content, cf.ownershipMarker)
+			continue
+		}
+
+		_, err = cf.deleteDNSRecordByDNSRecord(dnsRecord)
+		if err != nil {
+			return
+		}
+	}
+
+	// create the records for nameservers that don't have one yet
+	for _, nameserver := range nameservers {
+		if existingNameservers[strings.ToLower(nameserver)] {
+			continue
+		}
+
+		var cloudflareDNSRecordsCreateResult createResult
+		cloudflareDNSRecordsCreateResult, err = cf.createDNSRecordByZone(zone, "NS", dnsRecordName, nameserver, false, dnsRecordComment, "", 0, nil)
+		if err != nil {
+			return
+		}
+		existingNameservers[strings.ToLower(nameserver)] = true
+
+		r = append(r, cloudflareDNSRecordsCreateResult.DNSRecord)
+	}
+
+	return
+}
+
+// DeleteNSRecords deletes all ns records for a delegated name.
+func (cf *Cloudflare) DeleteNSRecords(dnsRecordName string) (r bool, err error) {
+
+	// get zone
+	zone, err := cf.GetZoneByDNSName(dnsRecordName)
+	if err != nil {
+		return r, err
+	}
+
+	// get dns records
+	dnsRecordsResult, err := cf.getDNSRecordsByZoneAndName(zone, dnsRecordName, "NS")
+	if err != nil {
+		return r, err
+	}
+
+	found := false
+	for _, dnsRecord := range dnsRecordsResult.DNSRecords {
+		if dnsRecord.Type != "NS" {
+			continue
+		}
+		found = true
+
+		// leave records created by others alone
+		if !isOwnedDNSRecord(dnsRecord, cf.ownershipMarker) {
+			log.Warn().Msgf("Skipping deletion of dns record %v (NS) to %v, because it lacks ownership marker '%v' in its comment", dnsRecordName, dnsRecord.Content, cf.ownershipMarker)
+			continue
+		}
+
+		_, err = cf.deleteDNSRecordByDNSRecord(dnsRecord)
+		if err != nil {
+			return
+		}
+
+		r = true
+	}
+
+	if !found {
+		err = errors.New("No matching ns records have been found")
+	} else if !r {
+		err = errDNSRecordNotOwned
+	}
+
+	return
+}
+
+// UpdateProxySetting updates the proxied setting for an existing dns record of the type.
+func (cf *Cloudflare) UpdateProxySetting(dnsRecordType, dnsRecordName string, proxy bool) (r DNSRecord, err error) {
+
+	// get zone
+	zone, err := cf.GetZoneByDNSName(dnsRecordName)
+	if err != nil {
+		return r, err
+	}
+
+	return cf.UpdateProxySettingByZone(zone, dnsRecordType, dnsRecordName, proxy)
+}
+
+// UpdateProxySettingByZone updates the proxied setting for an existing dns record in a zone that has been looked up already.
+func (cf *Cloudflare) UpdateProxySettingByZone(zone Zone, dnsRecordType, dnsRecordName string, proxy bool) (r DNSRecord, err error) {
+
 	// get dns record
-	dnsRecordsResult, err := cf.getDNSRecordsByZoneAndName(zone, dnsRecordName)
+	dnsRecordsResult, err := cf.getDNSRecordsByZoneAndName(zone, dnsRecordName, dnsRecordType)
 	if err != nil {
 		return r, err
 	}
 
 	if dnsRecordsResult.ResultInfo.Count == 0 {
-		err = errors.New("No matching dns record has been found")
+		err = errDNSRecordNotFound
 		return
 	} else if dnsRecordsResult.ResultInfo.Count > 1 {
 		err = errors.New("Cannot update proxy setting, there's more than 1 record by that name")
@@ -430,18 +1445,37 @@ func (cf *Cloudflare) UpdateProxySetting(dnsRecordName string, proxy bool) (r DN
 
 		r = dnsRecordsResult.DNSRecords[0]
 
+		// leave records created by others alone
+		if !isOwnedDNSRecord(r, cf.ownershipMarker) {
+			log.Warn().Msgf("Skipping proxy setting update of dns record %v, because it lacks ownership marker '%v' in its comment", dnsRecordName, cf.ownershipMarker)
+			return r, errDNSRecordNotOwned
+		}
+
+		// records created by UpsertDNSRecord already have the desired proxy setting
+		if r.Proxied == proxy {
+			return
+		}
+
+		if proxy && !r.Proxiable {
+			log.Warn().Msgf("Dns record %v (%v) in zone %v isn't proxiable, leaving it dns only", dnsRecordName, dnsRecordType, zone.Name)
+			return
+		}
+
 		if r.Proxiable {
 
-			if proxy {
-				r.Proxied = true
-			} else {
-				r.Proxied = false
+			// only send the changed fields, so changes made to other fields in the meantime don't get overwritten
+			proxyPatch := dnsRecordProxyPatch{Proxied: proxy}
+			if ttl := getTTLForProxySetting(dnsRecordName, r.TTL, proxy); ttl != r.TTL {
+				proxyPatch.TTL = ttl
+				r.TTL = ttl
 			}
+			r.Proxied = proxy
 
 			updateDNSRecordURI := fmt.Sprintf("%v/zones/%v/dns_records/%v", cf.baseURL, r.ZoneID, r.ID)
 
 			var body []byte
-			body, err = cf.restClient.Put(updateDNSRecordURI, r, cf.authentication)
+			body, err = cf.patch(updateDNSRecordURI, proxyPatch)
+			cf.invalidateDNSRecords(zone.ID, dnsRecordName)
 			if err != nil {
 				return
 			}
@@ -459,3 +1493,177 @@ func (cf *Cloudflare) UpdateProxySetting(dnsRecordName string, proxy bool) (r DN
 
 	return
 }
+
+// UpdateTTL updates the ttl for an existing dns record of the type, leaving its content and proxy setting alone.
+func (cf *Cloudflare) UpdateTTL(dnsRecordType, dnsRecordName string, ttl int) (r DNSRecord, err error) {
+
+	// get zone
+	zone, err := cf.GetZoneByDNSName(dnsRecordName)
+	if err != nil {
+		return r, err
+	}
+
+	return cf.UpdateTTLByZone(zone, dnsRecordType, dnsRecordName, ttl)
+}
+
+// UpdateTTLByZone updates the ttl for an existing dns record in a zone that has been looked up already.
+func (cf *Cloudflare) UpdateTTLByZone(zone Zone, dnsRecordType, dnsRecordName string, ttl int) (r DNSRecord, err error) {
+
+	// get dns record
+	dnsRecordsResult, err := cf.getDNSRecordsByZoneAndName(zone, dnsRecordName, dnsRecordType)
+	if err != nil {
+		return r, err
+	}
+
+	if dnsRecordsResult.ResultInfo.Count == 0 {
+		err = errDNSRecordNotFound
+		return
+	} else if dnsRecordsResult.ResultInfo.Count > 1 {
+		err = errors.New("Cannot update ttl, there's more than 1 record by that name")
+		return
+	}
+
+	r = dnsRecordsResult.DNSRecords[0]
+
+	// leave records created by others alone
+	if !isOwnedDNSRecord(r, cf.ownershipMarker) {
+		log.Warn().Msgf("Skipping ttl update of dns record %v, because it lacks ownership marker '%v' in its comment", dnsRecordName, cf.ownershipMarker)
+		return r, errDNSRecordNotOwned
+	}
+
+	ttl = getTTLForProxySetting(dnsRecordName, ttl, r.Proxied)
+	if r.TTL == ttl {
+		return
+	}
+
+	r.TTL = ttl
+
+	updateDNSRecordURI := fmt.Sprintf("%v/zones/%v/dns_records/%v", cf.baseURL, r.ZoneID, r.ID)
+
+	body, err := cf.put(updateDNSRecordURI, r)
+	cf.invalidateDNSRecords(zone.ID, dnsRecordName)
+	if err != nil {
+		return
+	}
+
+	var ur updateResult
+
+	json.NewDecoder(bytes.NewReader(body)).Decode(&ur)
+
+	if !ur.Success {
+		err = fmt.Errorf("Updating cloudflare dns record failed | %v | %v", ur.Errors, ur.Messages)
+		return
+	}
+
+	r = ur.DNSRecord
+
+	return
+}
+
+// GetZoneSetting returns a setting of a zone by its name, like ssl or browser_cache_ttl (https://api.cloudflare.com/#zone-settings-properties).
+func (cf *Cloudflare) GetZoneSetting(zone Zone, settingName string) (r ZoneSetting, err error) {
+
+	zoneSettingURI := fmt.Sprintf("%v/zones/%v/settings/%v", cf.baseURL, zone.ID, settingName)
+
+	body, err := cf.get(zoneSettingURI)
+	if err != nil {
+		return
+	}
+
+	var gr zoneSettingResult
+
+	json.NewDecoder(bytes.NewReader(body)).Decode(&gr)
+
+	if !gr.Success {
+		err = fmt.Errorf("Retrieving cloudflare zone setting %v failed | %v | %v", settingName, gr.Errors, gr.Messages)
+		return
+	}
+
+	return gr.ZoneSetting, nil
+}
+
+// UpdateZoneSetting sets a setting of a zone by its name, which applies to all records in the zone.
+func (cf *Cloudflare) UpdateZoneSetting(zone Zone, settingName string, value interface{}) (r ZoneSetting, err error) {
+
+	zoneSettingURI := fmt.Sprintf("%v/zones/%v/settings/%v", cf.baseURL, zone.ID, settingName)
+
+	body, err := cf.patch(zoneSettingURI, ZoneSetting{Value: value})
+	if err != nil {
+		return
+	}
+
+	var ur zoneSettingResult
+
+	json.NewDecoder(bytes.NewReader(body)).Decode(&ur)
+
+	if !ur.Success {
+		err = fmt.Errorf("Updating cloudflare zone setting %v failed | %v | %v", settingName, ur.Errors, ur.Messages)
+		return
+	}
+
+	return ur.ZoneSetting, nil
+}
+
+// GetZoneSSLSetting returns the ssl mode of a zone, which is either off, flexible, full or strict.
+func (cf *Cloudflare) GetZoneSSLSetting(zone Zone) (sslMode string, err error) {
+
+	setting, err := cf.GetZoneSetting(zone, "ssl")
+	if err != nil {
+		return
+	}
+
+	sslMode, _ = setting.Value.(string)
+
+	return sslMode, nil
+}
+
+// UpdateZoneSSLSetting sets the ssl mode of a zone, which applies to all proxied records in the zone.
+func (cf *Cloudflare) UpdateZoneSSLSetting(zone Zone, sslMode string) (r ZoneSetting, err error) {
+	return cf.UpdateZoneSetting(zone, "ssl", sslMode)
+}
+
+// ExportZoneRecords returns all records in a zone in BIND format, to back them up.
+func (cf *Cloudflare) ExportZoneRecords(zone Zone) (data []byte, err error) {
+
+	// create api url
+	exportURI := fmt.Sprintf("%v/zones/%v/dns_records/export", cf.baseURL, zone.ID)
+
+	// fetch result from cloudflare api
+	body, err := cf.get(exportURI)
+	if err != nil {
+		return
+	}
+
+	// the export is returned as plain text, errors are returned as json
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+		var r apiResult
+		json.NewDecoder(bytes.NewReader(body)).Decode(&r)
+		if !r.Success {
+			err = fmt.Errorf("Exporting cloudflare dns records failed | %v | %v", r.Errors, r.Messages)
+			return
+		}
+	}
+
+	return body, nil
+}
+
+// ImportZoneRecords creates the records in BIND format in a zone, to restore a backup.
+func (cf *Cloudflare) ImportZoneRecords(zone Zone, data []byte, proxy bool) (r importResult, err error) {
+
+	// create api url
+	importURI := fmt.Sprintf("%v/zones/%v/dns_records/import", cf.baseURL, zone.ID)
+
+	body, err := cf.postFile(importURI, map[string]string{"proxied": strconv.FormatBool(proxy)}, zone.Name+".txt", data)
+	if err != nil {
+		return
+	}
+
+	json.NewDecoder(bytes.NewReader(body)).Decode(&r)
+
+	if !r.Success {
+		err = fmt.Errorf("Importing cloudflare dns records failed | %v | %v", r.Errors, r.Messages)
+		return
+	}
+
+	return
+}
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestGetZoneByDNSName(t *testing.T) {
@@ -39,6 +44,41 @@ func TestGetZoneByDNSName(t *testing.T) {
 		assert.NotNil(t, err)
 	})
 
+	t.Run("ReturnsZoneWhenUnicodeDnsNameEqualsAnExistingPunycodeZone", func(t *testing.T) {
+
+		dnsName := "bücher.com"
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=xn--bcher-kva.com", authentication).Return([]byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{
+					"id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"name": "xn--bcher-kva.com"
+				}
+			],
+			"result_info": {
+				"page": 1,
+				"per_page": 20,
+				"count": 1,
+				"total_count": 1
+			}
+		}
+		`), nil)
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		zone, err := apiClient.GetZoneByDNSName(dnsName)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "xn--bcher-kva.com", zone.Name)
+	})
+
 	t.Run("ReturnsZoneWhenDnsNameEqualsAnExistingZone", func(t *testing.T) {
 
 		dnsName := "server.com"
@@ -120,47 +160,48 @@ func TestGetZoneByDNSName(t *testing.T) {
 		assert.Nil(t, err)
 		assert.Equal(t, "023e105f4ecef8ad9ca31a8372d0c353", zone.ID)
 		assert.Equal(t, "server.com", zone.Name)
+		assert.Equal(t, "pro", zone.Plan.LegacyID)
+		assert.Equal(t, "Pro Plan", zone.Plan.Name)
 	})
 
-}
+	t.Run("ReturnsConfiguredZoneWithoutLookingItUp", func(t *testing.T) {
 
-func TestGetZonesByName(t *testing.T) {
+		dnsName := "www.server.com"
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
 
-	t.Run("ReturnsEmptyArrayIfNoZoneMatchesName", func(t *testing.T) {
+		fakeRESTClient := new(fakeRESTClient)
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+		apiClient.zone = &Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353", Name: "server.com"}
 
-		zoneName := "server.com"
+		// act
+		zone, err := apiClient.GetZoneByDNSName(dnsName)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "023e105f4ecef8ad9ca31a8372d0c353", zone.ID)
+		fakeRESTClient.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+	})
+
+	t.Run("ReturnsZoneNotFoundErrorWhenDnsNameIsOutsideConfiguredZone", func(t *testing.T) {
+
+		dnsName := "www.domain.com"
 		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
 
 		fakeRESTClient := new(fakeRESTClient)
-		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=server.com", authentication).Return([]byte(`
-			{
-				"success": true,
-				"errors": [],
-				"messages": [],
-				"result": [
-				],
-				"result_info": {
-					"page": 1,
-					"per_page": 20,
-					"count": 0,
-					"total_count": 0
-				}
-			}
-		`), nil)
-
 		apiClient := New(authentication)
 		apiClient.restClient = fakeRESTClient
+		apiClient.zone = &Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353", Name: "server.com"}
 
 		// act
-		zonesResult, err := apiClient.getZonesByName(zoneName)
+		_, err := apiClient.GetZoneByDNSName(dnsName)
 
-		assert.Nil(t, err)
-		assert.Equal(t, 0, len(zonesResult.Zones))
+		assert.ErrorIs(t, err, errZoneNotFound)
+		fakeRESTClient.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
 	})
 
-	t.Run("ReturnsSingleZoneIfZoneMatchesName", func(t *testing.T) {
+	t.Run("ReturnsZoneWhenZoneIsInAllowedZones", func(t *testing.T) {
 
-		zoneName := "server.com"
+		dnsName := "server.com"
 		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
 
 		fakeRESTClient := new(fakeRESTClient)
@@ -172,53 +213,7 @@ func TestGetZonesByName(t *testing.T) {
 			"result": [
 				{
 					"id": "023e105f4ecef8ad9ca31a8372d0c353",
-					"name": "server.com",
-					"development_mode": 7200,
-					"original_name_servers": [
-						"ns1.originaldnshost.com",
-						"ns2.originaldnshost.com"
-					],
-					"original_registrar": "GoDaddy",
-					"original_dnshost": "NameCheap",
-					"created_on": "2014-01-01T05:20:00.12345Z",
-					"modified_on": "2014-01-01T05:20:00.12345Z",
-					"name_servers": [
-						"tony.ns.cloudflare.com",
-						"woz.ns.cloudflare.com"
-					],
-					"owner": {
-						"id": "7c5dae5552338874e5053f2534d2767a",
-						"email": "user@example.com",
-						"owner_type": "user"
-					},
-					"permissions": [
-						"#zone:read",
-						"#zone:edit"
-					],
-					"plan": {
-						"id": "e592fd9519420ba7405e1307bff33214",
-						"name": "Pro Plan",
-						"price": 20,
-						"currency": "USD",
-						"frequency": "monthly",
-						"legacy_id": "pro",
-						"is_subscribed": true,
-						"can_subscribe": true
-					},
-					"plan_pending": {
-						"id": "e592fd9519420ba7405e1307bff33214",
-						"name": "Pro Plan",
-						"price": 20,
-						"currency": "USD",
-						"frequency": "monthly",
-						"legacy_id": "pro",
-						"is_subscribed": true,
-						"can_subscribe": true
-					},
-					"status": "active",
-					"paused": false,
-					"type": "full",
-					"checked_on": "2014-01-01T05:20:00.12345Z"
+					"name": "server.com"
 				}
 			],
 			"result_info": {
@@ -229,130 +224,32 @@ func TestGetZonesByName(t *testing.T) {
 			}
 		}
 		`), nil)
-
 		apiClient := New(authentication)
 		apiClient.restClient = fakeRESTClient
+		apiClient.allowedZones = []string{"example.com", "Server.com"}
 
 		// act
-		zonesResult, err := apiClient.getZonesByName(zoneName)
+		zone, err := apiClient.GetZoneByDNSName(dnsName)
 
 		assert.Nil(t, err)
-		assert.Equal(t, 1, len(zonesResult.Zones))
-		assert.Equal(t, "023e105f4ecef8ad9ca31a8372d0c353", zonesResult.Zones[0].ID)
-		assert.Equal(t, "server.com", zonesResult.Zones[0].Name)
+		assert.Equal(t, "023e105f4ecef8ad9ca31a8372d0c353", zone.ID)
 	})
 
-	t.Run("ReturnsMultipleZonesIfMoreThanOneZoneMatchesName", func(t *testing.T) {
+	t.Run("ReturnsZoneNotAllowedErrorWhenZoneIsNotInAllowedZones", func(t *testing.T) {
 
-		zoneName := "co.uk"
+		dnsName := "server.com"
 		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
 
 		fakeRESTClient := new(fakeRESTClient)
-		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=co.uk", authentication).Return([]byte(`
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=server.com", authentication).Return([]byte(`
 		{
 			"success": true,
 			"errors": [],
 			"messages": [],
 			"result": [
-				{
-					"id": "023e105f48ad9ca31a8372d0c353ecef",
-					"name": "domain.co.uk",
-					"development_mode": 7200,
-					"original_name_servers": [
-						"ns1.originaldnshost.com",
-						"ns2.originaldnshost.com"
-					],
-					"original_registrar": "GoDaddy",
-					"original_dnshost": "NameCheap",
-					"created_on": "2014-01-01T05:20:00.12345Z",
-					"modified_on": "2014-01-01T05:20:00.12345Z",
-					"name_servers": [
-						"tony.ns.cloudflare.com",
-						"woz.ns.cloudflare.com"
-					],
-					"owner": {
-						"id": "7c5dae5552338874e5053f2534d2767a",
-						"email": "user@example.com",
-						"owner_type": "user"
-					},
-					"permissions": [
-						"#zone:read",
-						"#zone:edit"
-					],
-					"plan": {
-						"id": "e592fd9519420ba7405e1307bff33214",
-						"name": "Pro Plan",
-						"price": 20,
-						"currency": "USD",
-						"frequency": "monthly",
-						"legacy_id": "pro",
-						"is_subscribed": true,
-						"can_subscribe": true
-					},
-					"plan_pending": {
-						"id": "e592fd9519420ba7405e1307bff33214",
-						"name": "Pro Plan",
-						"price": 20,
-						"currency": "USD",
-						"frequency": "monthly",
-						"legacy_id": "pro",
-						"is_subscribed": true,
-						"can_subscribe": true
-					},
-					"status": "active",
-					"paused": false,
-					"type": "full",
-					"checked_on": "2014-01-01T05:20:00.12345Z"
-				},
 				{
 					"id": "023e105f4ecef8ad9ca31a8372d0c353",
-					"name": "server.co.uk",
-					"development_mode": 7200,
-					"original_name_servers": [
-						"ns1.originaldnshost.com",
-						"ns2.originaldnshost.com"
-					],
-					"original_registrar": "GoDaddy",
-					"original_dnshost": "NameCheap",
-					"created_on": "2014-01-01T05:20:00.12345Z",
-					"modified_on": "2014-01-01T05:20:00.12345Z",
-					"name_servers": [
-						"tony.ns.cloudflare.com",
-						"woz.ns.cloudflare.com"
-					],
-					"owner": {
-						"id": "7c5dae5552338874e5053f2534d2767a",
-						"email": "user@example.com",
-						"owner_type": "user"
-					},
-					"permissions": [
-						"#zone:read",
-						"#zone:edit"
-					],
-					"plan": {
-						"id": "e592fd9519420ba7405e1307bff33214",
-						"name": "Pro Plan",
-						"price": 20,
-						"currency": "USD",
-						"frequency": "monthly",
-						"legacy_id": "pro",
-						"is_subscribed": true,
-						"can_subscribe": true
-					},
-					"plan_pending": {
-						"id": "e592fd9519420ba7405e1307bff33214",
-						"name": "Pro Plan",
-						"price": 20,
-						"currency": "USD",
-						"frequency": "monthly",
-						"legacy_id": "pro",
-						"is_subscribed": true,
-						"can_subscribe": true
-					},
-					"status": "active",
-					"paused": false,
-					"type": "full",
-					"checked_on": "2014-01-01T05:20:00.12345Z"
+					"name": "server.com"
 				}
 			],
 			"result_info": {
@@ -363,118 +260,178 @@ func TestGetZonesByName(t *testing.T) {
 			}
 		}
 		`), nil)
-
 		apiClient := New(authentication)
 		apiClient.restClient = fakeRESTClient
+		apiClient.allowedZones = []string{"example.com"}
 
 		// act
-		zonesResult, err := apiClient.getZonesByName(zoneName)
+		zone, err := apiClient.GetZoneByDNSName(dnsName)
 
-		assert.Nil(t, err)
-		assert.Equal(t, 2, len(zonesResult.Zones))
-		assert.Equal(t, "023e105f48ad9ca31a8372d0c353ecef", zonesResult.Zones[0].ID)
-		assert.Equal(t, "domain.co.uk", zonesResult.Zones[0].Name)
-		assert.Equal(t, "023e105f4ecef8ad9ca31a8372d0c353", zonesResult.Zones[1].ID)
-		assert.Equal(t, "server.co.uk", zonesResult.Zones[1].Name)
+		assert.ErrorIs(t, err, errZoneNotAllowed)
+		assert.Equal(t, "", zone.ID)
 	})
-}
-
-func TestGetDNSRecordsByZoneAndName(t *testing.T) {
 
-	t.Run("ReturnsEmptyArrayIfNoDnsRecordsMatchesName", func(t *testing.T) {
+	t.Run("ReturnsZoneNotAllowedErrorWhenConfiguredZoneIsNotInAllowedZones", func(t *testing.T) {
 
-		zone := Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353"}
-		dnsRecordName := "www"
+		dnsName := "www.server.com"
 		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
 
 		fakeRESTClient := new(fakeRESTClient)
-		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www", authentication).Return([]byte(`
-			{
-				"success": true,
-				"errors": [],
-				"messages": [],
-				"result": [
-				],
-				"result_info": {
-					"page": 1,
-					"per_page": 20,
-					"count": 0,
-					"total_count": 0
-				}
-			}
-		`), nil)
-
 		apiClient := New(authentication)
 		apiClient.restClient = fakeRESTClient
+		apiClient.zone = &Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353", Name: "server.com"}
+		apiClient.allowedZones = []string{"example.com"}
 
 		// act
-		dnsRecordsResult, err := apiClient.getDNSRecordsByZoneAndName(zone, dnsRecordName)
+		_, err := apiClient.GetZoneByDNSName(dnsName)
+
+		assert.ErrorIs(t, err, errZoneNotAllowed)
+		fakeRESTClient.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+	})
+
+	pausedZonesResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{
+					"id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"name": "server.com",
+					"status": "active",
+					"paused": true
+				}
+			],
+			"result_info": {
+				"page": 1,
+				"per_page": 20,
+				"count": 1,
+				"total_count": 1
+			}
+		}
+	`)
+
+	t.Run("ReturnsPausedZone", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=server.com", authentication).Return(pausedZonesResult, nil)
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		zone, err := apiClient.GetZoneByDNSName("server.com")
 
 		assert.Nil(t, err)
-		assert.Equal(t, 0, len(dnsRecordsResult.DNSRecords))
+		assert.True(t, zone.Paused)
+		_, warned := apiClient.pausedZones.Load("023e105f4ecef8ad9ca31a8372d0c353")
+		assert.True(t, warned)
 	})
 
-	t.Run("ReturnsDnsRecordIfADnsRecordMatchesName", func(t *testing.T) {
+	t.Run("ReturnsZonePausedErrorWhenSkippingPausedZones", func(t *testing.T) {
 
-		zone := Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353"}
-		dnsRecordName := "www"
 		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
 
 		fakeRESTClient := new(fakeRESTClient)
-		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www", authentication).Return([]byte(`
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=server.com", authentication).Return(pausedZonesResult, nil)
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+		apiClient.skipPausedZones = true
+
+		// act
+		zone, err := apiClient.GetZoneByDNSName("server.com")
+
+		assert.ErrorIs(t, err, errZonePaused)
+		assert.Equal(t, "", zone.ID)
+	})
+}
+
+func TestIsZoneApex(t *testing.T) {
+
+	t.Run("ReturnsTrueWhenDnsNameIsZoneName", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		isApex := apiClient.IsZoneApex("example.com")
+
+		assert.True(t, isApex)
+	})
+
+	t.Run("ReturnsFalseWhenDnsNameIsSubdomainOfZone", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		isApex := apiClient.IsZoneApex("www.example.com")
+
+		assert.False(t, isApex)
+	})
+
+	t.Run("ReturnsFalseWhenPinnedZoneHasNoName", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+		apiClient.zone = &Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353"}
+
+		// act
+		isApex := apiClient.IsZoneApex("example.com")
+
+		assert.False(t, isApex)
+		fakeRESTClient.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+	})
+}
+
+func TestCheckConnectivity(t *testing.T) {
+
+	t.Run("FetchesConfiguredZoneInsteadOfListingZones", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353", authentication).Return([]byte(`
 			{
 				"success": true,
 				"errors": [],
 				"messages": [],
-				"result": [
-					{
-					"id": "372e67954025e0ba6aaa6d586b9e0b59",
-					"type": "A",
-					"name": "example.com",
-					"content": "1.2.3.4",
-					"proxiable": true,
-					"proxied": false,
-					"ttl": 120,
-					"locked": false,
-					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
-					"zone_name": "example.com",
-					"created_on": "2014-01-01T05:20:00.12345Z",
-					"modified_on": "2014-01-01T05:20:00.12345Z",
-					"data": {}
-					}
-				],
-				"result_info": {
-					"page": 1,
-					"per_page": 20,
-					"count": 1,
-					"total_count": 1
+				"result": {
+					"id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"name": "server.com"
 				}
 			}
 		`), nil)
 
 		apiClient := New(authentication)
 		apiClient.restClient = fakeRESTClient
+		apiClient.zone = &Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353"}
 
 		// act
-		dnsRecordsResult, err := apiClient.getDNSRecordsByZoneAndName(zone, dnsRecordName)
+		err := apiClient.CheckConnectivity()
 
 		assert.Nil(t, err)
-		assert.Equal(t, 1, len(dnsRecordsResult.DNSRecords))
 	})
 
-}
-
-func TestCreateDNSRecord(t *testing.T) {
-
-	t.Run("ReturnsErrorIfZoneDoesNotExist", func(t *testing.T) {
+	t.Run("ReturnsNilIfListingZonesSucceeds", func(t *testing.T) {
 
-		dnsRecordType := "A"
-		dnsRecordName := "example.com"
-		dnsRecordContent := "1.2.3.4"
 		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
 
 		fakeRESTClient := new(fakeRESTClient)
-		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?per_page=1", authentication).Return([]byte(`
 			{
 				"success": true,
 				"errors": [],
@@ -483,7 +440,7 @@ func TestCreateDNSRecord(t *testing.T) {
 				],
 				"result_info": {
 					"page": 1,
-					"per_page": 20,
+					"per_page": 1,
 					"count": 0,
 					"total_count": 0
 				}
@@ -494,106 +451,51 @@ func TestCreateDNSRecord(t *testing.T) {
 		apiClient.restClient = fakeRESTClient
 
 		// act
-		_, err := apiClient.CreateDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent)
+		err := apiClient.CheckConnectivity()
 
-		assert.NotNil(t, err)
+		assert.Nil(t, err)
 	})
 
-	t.Run("ReturnsRecordIfCreated", func(t *testing.T) {
+	t.Run("ReturnsErrorIfCredentialsAreRejected", func(t *testing.T) {
 
-		dnsRecordType := "A"
-		dnsRecordName := "example.com"
-		dnsRecordContent := "1.2.3.4"
 		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
 
 		fakeRESTClient := new(fakeRESTClient)
-		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`
-		{
-			"success": true,
-			"errors": [],
-			"messages": [],
-			"result": [
-				{
-					"id": "023e105f4ecef8ad9ca31a8372d0c353",
-					"name": "example.com",
-					"development_mode": 7200,
-					"original_name_servers": [
-						"ns1.originaldnshost.com",
-						"ns2.originaldnshost.com"
-					],
-					"original_registrar": "GoDaddy",
-					"original_dnshost": "NameCheap",
-					"created_on": "2014-01-01T05:20:00.12345Z",
-					"modified_on": "2014-01-01T05:20:00.12345Z",
-					"name_servers": [
-						"tony.ns.cloudflare.com",
-						"woz.ns.cloudflare.com"
-					],
-					"owner": {
-						"id": "7c5dae5552338874e5053f2534d2767a",
-						"email": "user@example.com",
-						"owner_type": "user"
-					},
-					"permissions": [
-						"#zone:read",
-						"#zone:edit"
-					],
-					"plan": {
-						"id": "e592fd9519420ba7405e1307bff33214",
-						"name": "Pro Plan",
-						"price": 20,
-						"currency": "USD",
-						"frequency": "monthly",
-						"legacy_id": "pro",
-						"is_subscribed": true,
-						"can_subscribe": true
-					},
-					"plan_pending": {
-						"id": "e592fd9519420ba7405e1307bff33214",
-						"name": "Pro Plan",
-						"price": 20,
-						"currency": "USD",
-						"frequency": "monthly",
-						"legacy_id": "pro",
-						"is_subscribed": true,
-						"can_subscribe": true
-					},
-					"status": "active",
-					"paused": false,
-					"type": "full",
-					"checked_on": "2014-01-01T05:20:00.12345Z"
-				}
-			],
-			"result_info": {
-				"page": 1,
-				"per_page": 20,
-				"count": 1,
-				"total_count": 1
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?per_page=1", authentication).Return([]byte(`
+			{
+				"success": false,
+				"errors": [{"code": 9103, "message": "Unknown X-Auth-Key or X-Auth-Email"}],
+				"messages": [],
+				"result": null
 			}
-		}
 		`), nil)
 
-		newDNSRecord := DNSRecord{Type: dnsRecordType, Name: dnsRecordName, Content: dnsRecordContent}
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
 
-		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", newDNSRecord, authentication).Return([]byte(`
+		// act
+		err := apiClient.CheckConnectivity()
+
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "9103")
+	})
+}
+
+func TestVerifyCredentials(t *testing.T) {
+
+	t.Run("ReturnsNilIfCredentialsAreAccepted", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/user", authentication).Return([]byte(`
 			{
 				"success": true,
 				"errors": [],
 				"messages": [],
 				"result": {
-					"id": "372e67954025e0ba6aaa6d586b9e0b59",
-					"type": "A",
-					"name": "example.com",
-					"content": "1.2.3.4",
-					"proxiable": true,
-					"proxied": false,
-					"ttl": 120,
-					"locked": false,
-					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
-					"zone_name": "example.com",
-					"created_on": "2014-01-01T05:20:00.12345Z",
-					"modified_on": "2014-01-01T05:20:00.12345Z",
-					"data": {}
+					"id": "7c5dae5552338874e5053f2534d2767a",
+					"email": "name@server.com"
 				}
 			}
 		`), nil)
@@ -602,39 +504,22 @@ func TestCreateDNSRecord(t *testing.T) {
 		apiClient.restClient = fakeRESTClient
 
 		// act
-		dnsRecord, err := apiClient.CreateDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent)
+		err := apiClient.VerifyCredentials()
 
 		assert.Nil(t, err)
-		assert.Equal(t, "372e67954025e0ba6aaa6d586b9e0b59", dnsRecord.ID)
-		assert.Equal(t, "A", dnsRecord.Type)
-		assert.Equal(t, "example.com", dnsRecord.Name)
-		assert.Equal(t, "1.2.3.4", dnsRecord.Content)
-		assert.Equal(t, "023e105f4ecef8ad9ca31a8372d0c353", dnsRecord.ZoneID)
 	})
 
-}
-
-func TestDeleteDNSRecord(t *testing.T) {
-
-	t.Run("ReturnsErrorIfZoneDoesNotExist", func(t *testing.T) {
+	t.Run("ReturnsErrorIfCredentialsAreRejected", func(t *testing.T) {
 
-		dnsRecordName := "example.com"
 		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
 
 		fakeRESTClient := new(fakeRESTClient)
-		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/user", authentication).Return([]byte(`
 			{
-				"success": true,
-				"errors": [],
+				"success": false,
+				"errors": [{"code": 9103, "message": "Unknown X-Auth-Key or X-Auth-Email"}],
 				"messages": [],
-				"result": [
-				],
-				"result_info": {
-					"page": 1,
-					"per_page": 20,
-					"count": 0,
-					"total_count": 0
-				}
+				"result": null
 			}
 		`), nil)
 
@@ -642,34 +527,162 @@ func TestDeleteDNSRecord(t *testing.T) {
 		apiClient.restClient = fakeRESTClient
 
 		// act
-		_, err := apiClient.DeleteDNSRecord(dnsRecordName)
+		err := apiClient.VerifyCredentials()
 
 		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "9103")
 	})
 
-	t.Run("ReturnsErrorIfDnsRecordDoesNotExist", func(t *testing.T) {
+	t.Run("ReturnsErrorIfApiIsUnreachable", func(t *testing.T) {
 
-		dnsRecordName := "www.example.com"
 		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
 
 		fakeRESTClient := new(fakeRESTClient)
-		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return([]byte(`
-		{
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/user", authentication).Return([]byte{}, errors.New("connection refused"))
 
-				"success": true,
-				"errors": [],
-				"messages": [],
-				"result": [
-				],
-				"result_info": {
-					"page": 1,
-					"per_page": 20,
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		err := apiClient.VerifyCredentials()
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("RetriesWithSecondaryCredentialsIfPrimaryOnesAreRejected", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+		secondaryAuthentication := APIAuthentication{Key: "8afbe6dea02407989af4dd4c97bb6e25", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/user", authentication).Return([]byte(`{"success": false, "errors": [{"code": 10000, "message": "Authentication error"}], "messages": [], "result": null}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/user", secondaryAuthentication).Return([]byte(`{"success": true, "errors": [], "messages": [], "result": {"id": "7c5dae5552338874e5053f2534d2767a", "email": "name@server.com"}}`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+		apiClient.secondaryAuthentication = &secondaryAuthentication
+
+		// act
+		err := apiClient.VerifyCredentials()
+
+		assert.Nil(t, err)
+		fakeRESTClient.AssertCalled(t, "Get", "https://api.cloudflare.com/client/v4/user", authentication)
+		fakeRESTClient.AssertCalled(t, "Get", "https://api.cloudflare.com/client/v4/user", secondaryAuthentication)
+	})
+
+	t.Run("ReturnsErrorIfPrimaryAndSecondaryCredentialsAreRejected", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+		secondaryAuthentication := APIAuthentication{Key: "8afbe6dea02407989af4dd4c97bb6e25", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/user", mock.Anything).Return([]byte(`{"success": false, "errors": [{"code": 9103, "message": "Unknown X-Auth-Key or X-Auth-Email"}], "messages": [], "result": null}`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+		apiClient.secondaryAuthentication = &secondaryAuthentication
+
+		// act
+		err := apiClient.VerifyCredentials()
+
+		assert.NotNil(t, err)
+		fakeRESTClient.AssertNumberOfCalls(t, "Get", 2)
+	})
+
+	t.Run("DoesNotRetryWithSecondaryCredentialsForOtherErrors", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+		secondaryAuthentication := APIAuthentication{Key: "8afbe6dea02407989af4dd4c97bb6e25", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/user", authentication).Return([]byte(`{"success": false, "errors": [{"code": 1000, "message": "Internal error"}], "messages": [], "result": null}`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+		apiClient.secondaryAuthentication = &secondaryAuthentication
+
+		// act
+		err := apiClient.VerifyCredentials()
+
+		assert.NotNil(t, err)
+		fakeRESTClient.AssertNotCalled(t, "Get", "https://api.cloudflare.com/client/v4/user", secondaryAuthentication)
+	})
+}
+
+func TestGetZonesByName(t *testing.T) {
+
+	t.Run("ReturnsEmptyArrayIfNoZoneMatchesName", func(t *testing.T) {
+
+		zoneName := "server.com"
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=server.com", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
 					"count": 0,
 					"total_count": 0
 				}
-    }
+			}
 		`), nil)
-		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		zonesResult, err := apiClient.getZonesByName(zoneName)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(zonesResult.Zones))
+	})
+
+	t.Run("FiltersZonesByAccountIDIfSet", func(t *testing.T) {
+
+		zoneName := "server.com"
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=server.com&account.id=01a7362d577a6c3019a474fd6f485823", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 0,
+					"total_count": 0
+				}
+			}
+		`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+		apiClient.accountID = "01a7362d577a6c3019a474fd6f485823"
+
+		// act
+		_, err := apiClient.getZonesByName(zoneName)
+
+		assert.Nil(t, err)
+		fakeRESTClient.AssertExpectations(t)
+	})
+
+	t.Run("ReturnsSingleZoneIfZoneMatchesName", func(t *testing.T) {
+
+		zoneName := "server.com"
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=server.com", authentication).Return([]byte(`
 		{
 			"success": true,
 			"errors": [],
@@ -677,7 +690,7 @@ func TestDeleteDNSRecord(t *testing.T) {
 			"result": [
 				{
 					"id": "023e105f4ecef8ad9ca31a8372d0c353",
-					"name": "example.com",
+					"name": "server.com",
 					"development_mode": 7200,
 					"original_name_servers": [
 						"ns1.originaldnshost.com",
@@ -735,61 +748,33 @@ func TestDeleteDNSRecord(t *testing.T) {
 		}
 		`), nil)
 
-		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return([]byte(`
-			{
-				"success": true,
-				"errors": [],
-				"messages": [],
-				"result": [],
-				"result_info": {
-					"page": 1,
-					"per_page": 20,
-					"count": 0,
-					"total_count": 0
-				}
-			}
-		`), nil)
-
 		apiClient := New(authentication)
 		apiClient.restClient = fakeRESTClient
 
 		// act
-		_, err := apiClient.DeleteDNSRecord(dnsRecordName)
+		zonesResult, err := apiClient.getZonesByName(zoneName)
 
-		assert.NotNil(t, err)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(zonesResult.Zones))
+		assert.Equal(t, "023e105f4ecef8ad9ca31a8372d0c353", zonesResult.Zones[0].ID)
+		assert.Equal(t, "server.com", zonesResult.Zones[0].Name)
 	})
 
-	t.Run("ReturnsErrorIfDeletingFailed", func(t *testing.T) {
+	t.Run("ReturnsMultipleZonesIfMoreThanOneZoneMatchesName", func(t *testing.T) {
 
-		dnsRecordName := "www.example.com"
+		zoneName := "co.uk"
 		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
 
 		fakeRESTClient := new(fakeRESTClient)
-		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return([]byte(`
-		{
-
-				"success": true,
-				"errors": [],
-				"messages": [],
-				"result": [
-				],
-				"result_info": {
-					"page": 1,
-					"per_page": 20,
-					"count": 0,
-					"total_count": 0
-				}
-    }
-		`), nil)
-		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=co.uk", authentication).Return([]byte(`
 		{
 			"success": true,
 			"errors": [],
 			"messages": [],
 			"result": [
 				{
-					"id": "023e105f4ecef8ad9ca31a8372d0c353",
-					"name": "example.com",
+					"id": "023e105f48ad9ca31a8372d0c353ecef",
+					"name": "domain.co.uk",
 					"development_mode": 7200,
 					"original_name_servers": [
 						"ns1.originaldnshost.com",
@@ -836,95 +821,10 @@ func TestDeleteDNSRecord(t *testing.T) {
 					"paused": false,
 					"type": "full",
 					"checked_on": "2014-01-01T05:20:00.12345Z"
-				}
-			],
-			"result_info": {
-				"page": 1,
-				"per_page": 20,
-				"count": 1,
-				"total_count": 1
-			}
-		}
-		`), nil)
-
-		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return([]byte(`
-			{
-				"success": true,
-				"errors": [],
-				"messages": [],
-				"result": [
-					{
-					"id": "372e67954025e0ba6aaa6d586b9e0b59",
-					"type": "A",
-					"name": "www.example.com",
-					"content": "1.2.3.4",
-					"proxiable": true,
-					"proxied": false,
-					"ttl": 120,
-					"locked": false,
-					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
-					"zone_name": "example.com",
-					"created_on": "2014-01-01T05:20:00.12345Z",
-					"modified_on": "2014-01-01T05:20:00.12345Z",
-					"data": {}
-					}
-				],
-				"result_info": {
-					"page": 1,
-					"per_page": 20,
-					"count": 1,
-					"total_count": 1
-				}
-			}
-		`), nil)
-
-		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return([]byte(`
-			{
-				"success": false,
-				"errors": [],
-				"messages": []
-			}
-		`), nil)
-		apiClient := New(authentication)
-		apiClient.restClient = fakeRESTClient
-
-		// act
-		_, err := apiClient.DeleteDNSRecord(dnsRecordName)
-
-		assert.NotNil(t, err)
-	})
-
-	t.Run("ReturnsTrueIfDeletingSucceeded", func(t *testing.T) {
-
-		dnsRecordName := "www.example.com"
-		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
-
-		fakeRESTClient := new(fakeRESTClient)
-		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return([]byte(`
-		{
-
-				"success": true,
-				"errors": [],
-				"messages": [],
-				"result": [
-				],
-				"result_info": {
-					"page": 1,
-					"per_page": 20,
-					"count": 0,
-					"total_count": 0
-				}
-    }
-		`), nil)
-		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`
-		{
-			"success": true,
-			"errors": [],
-			"messages": [],
-			"result": [
+				},
 				{
 					"id": "023e105f4ecef8ad9ca31a8372d0c353",
-					"name": "example.com",
+					"name": "server.co.uk",
 					"development_mode": 7200,
 					"original_name_servers": [
 						"ns1.originaldnshost.com",
@@ -982,8 +882,65 @@ func TestDeleteDNSRecord(t *testing.T) {
 		}
 		`), nil)
 
-		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return([]byte(`
-			{
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		zonesResult, err := apiClient.getZonesByName(zoneName)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(zonesResult.Zones))
+		assert.Equal(t, "023e105f48ad9ca31a8372d0c353ecef", zonesResult.Zones[0].ID)
+		assert.Equal(t, "domain.co.uk", zonesResult.Zones[0].Name)
+		assert.Equal(t, "023e105f4ecef8ad9ca31a8372d0c353", zonesResult.Zones[1].ID)
+		assert.Equal(t, "server.co.uk", zonesResult.Zones[1].Name)
+	})
+}
+
+func TestGetDNSRecordsByZoneAndName(t *testing.T) {
+
+	t.Run("ReturnsEmptyArrayIfNoDnsRecordsMatchesName", func(t *testing.T) {
+
+		zone := Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353"}
+		dnsRecordName := "www"
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 0,
+					"total_count": 0
+				}
+			}
+		`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		dnsRecordsResult, err := apiClient.getDNSRecordsByZoneAndName(zone, dnsRecordName, "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(dnsRecordsResult.DNSRecords))
+	})
+
+	t.Run("ReturnsDnsRecordIfADnsRecordMatchesName", func(t *testing.T) {
+
+		zone := Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353"}
+		dnsRecordName := "www"
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www", authentication).Return([]byte(`
+			{
 				"success": true,
 				"errors": [],
 				"messages": [],
@@ -991,7 +948,7 @@ func TestDeleteDNSRecord(t *testing.T) {
 					{
 					"id": "372e67954025e0ba6aaa6d586b9e0b59",
 					"type": "A",
-					"name": "www.example.com",
+					"name": "example.com",
 					"content": "1.2.3.4",
 					"proxiable": true,
 					"proxied": false,
@@ -1013,29 +970,100 @@ func TestDeleteDNSRecord(t *testing.T) {
 			}
 		`), nil)
 
-		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return([]byte(`
-			{
-				"success": true,
-				"errors": [],
-				"messages": [],
-				"result": {
-					"id": "372e67954025e0ba6aaa6d586b9e0b59"
-				}
-			}
-		`), nil)
 		apiClient := New(authentication)
 		apiClient.restClient = fakeRESTClient
 
 		// act
-		success, err := apiClient.DeleteDNSRecord(dnsRecordName)
+		dnsRecordsResult, err := apiClient.getDNSRecordsByZoneAndName(zone, dnsRecordName, "")
 
 		assert.Nil(t, err)
-		assert.True(t, success)
+		assert.Equal(t, 1, len(dnsRecordsResult.DNSRecords))
 	})
 
+	t.Run("FiltersOnOwnershipMarkerIfLookupsAreScoped", func(t *testing.T) {
+
+		zone := Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353"}
+		dnsRecordName := "www.example.com"
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&comment.contains=managed+by+estafette-cloudflare-dns", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+		apiClient.ownershipMarker = defaultCloudflareComment
+		apiClient.scopeRecordLookups = true
+
+		// act
+		_, err := apiClient.getDNSRecordsByZoneAndName(zone, dnsRecordName, "")
+
+		assert.Nil(t, err)
+		fakeRESTClient.AssertNumberOfCalls(t, "Get", 1)
+	})
 }
 
-func TestUpdateDNSRecord(t *testing.T) {
+func TestGetDNSRecordsURI(t *testing.T) {
+
+	zone := Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353"}
+
+	t.Run("ReturnsUnfilteredURIByDefault", func(t *testing.T) {
+
+		apiClient := New(APIAuthentication{})
+		apiClient.ownershipMarker = defaultCloudflareComment
+
+		// act
+		uri := apiClient.getDNSRecordsURI(zone, "www.example.com", "")
+
+		assert.Equal(t, "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", uri)
+	})
+
+	t.Run("AppendsTypeFilterIfTypeIsSet", func(t *testing.T) {
+
+		apiClient := New(APIAuthentication{})
+
+		// act
+		uri := apiClient.getDNSRecordsURI(zone, "www.example.com", "TXT")
+
+		assert.Equal(t, "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=TXT", uri)
+	})
+
+	t.Run("AppendsTypeFilterBeforeCommentFilterIfLookupsAreScoped", func(t *testing.T) {
+
+		apiClient := New(APIAuthentication{})
+		apiClient.ownershipMarker = defaultCloudflareComment
+		apiClient.scopeRecordLookups = true
+
+		// act
+		uri := apiClient.getDNSRecordsURI(zone, "www.example.com", "A")
+
+		assert.Equal(t, "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A&comment.contains=managed+by+estafette-cloudflare-dns", uri)
+	})
+
+	t.Run("AppendsEscapedCommentFilterIfLookupsAreScoped", func(t *testing.T) {
+
+		apiClient := New(APIAuthentication{})
+		apiClient.ownershipMarker = "owned by team a&b"
+		apiClient.scopeRecordLookups = true
+
+		// act
+		uri := apiClient.getDNSRecordsURI(zone, "www.example.com", "")
+
+		assert.Equal(t, "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&comment.contains=owned+by+team+a%26b", uri)
+	})
+
+	t.Run("ReturnsUnfilteredURIIfThereIsNoOwnershipMarker", func(t *testing.T) {
+
+		apiClient := New(APIAuthentication{})
+		apiClient.scopeRecordLookups = true
+
+		// act
+		uri := apiClient.getDNSRecordsURI(zone, "www.example.com", "")
+
+		assert.Equal(t, "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", uri)
+	})
+}
+
+func TestCreateDNSRecord(t *testing.T) {
 
 	t.Run("ReturnsErrorIfZoneDoesNotExist", func(t *testing.T) {
 
@@ -1065,36 +1093,19 @@ func TestUpdateDNSRecord(t *testing.T) {
 		apiClient.restClient = fakeRESTClient
 
 		// act
-		_, err := apiClient.UpdateDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent)
+		_, err := apiClient.CreateDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent)
 
 		assert.NotNil(t, err)
 	})
 
-	t.Run("ReturnsErrorIfDnsRecordDoesNotExist", func(t *testing.T) {
+	t.Run("ReturnsRecordIfCreated", func(t *testing.T) {
 
 		dnsRecordType := "A"
-		dnsRecordName := "www.example.com"
+		dnsRecordName := "example.com"
 		dnsRecordContent := "1.2.3.4"
-
 		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
 
 		fakeRESTClient := new(fakeRESTClient)
-		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return([]byte(`
-		{
-
-				"success": true,
-				"errors": [],
-				"messages": [],
-				"result": [
-				],
-				"result_info": {
-					"page": 1,
-					"per_page": 20,
-					"count": 0,
-					"total_count": 0
-				}
-    }
-		`), nil)
 		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`
 		{
 			"success": true,
@@ -1161,12 +1172,62 @@ func TestUpdateDNSRecord(t *testing.T) {
 		}
 		`), nil)
 
-		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return([]byte(`
+		newDNSRecord := DNSRecord{Type: dnsRecordType, Name: dnsRecordName, Content: dnsRecordContent, TTL: 1}
+
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", newDNSRecord, authentication).Return([]byte(`
 			{
 				"success": true,
 				"errors": [],
 				"messages": [],
-				"result": [],
+				"result": {
+					"id": "372e67954025e0ba6aaa6d586b9e0b59",
+					"type": "A",
+					"name": "example.com",
+					"content": "1.2.3.4",
+					"proxiable": true,
+					"proxied": false,
+					"ttl": 120,
+					"locked": false,
+					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"zone_name": "example.com",
+					"created_on": "2014-01-01T05:20:00.12345Z",
+					"modified_on": "2014-01-01T05:20:00.12345Z",
+					"data": {}
+				}
+			}
+		`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		dnsRecord, err := apiClient.CreateDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "372e67954025e0ba6aaa6d586b9e0b59", dnsRecord.ID)
+		assert.Equal(t, "A", dnsRecord.Type)
+		assert.Equal(t, "example.com", dnsRecord.Name)
+		assert.Equal(t, "1.2.3.4", dnsRecord.Content)
+		assert.Equal(t, "023e105f4ecef8ad9ca31a8372d0c353", dnsRecord.ZoneID)
+	})
+
+}
+
+func TestDeleteDNSRecord(t *testing.T) {
+
+	t.Run("ReturnsErrorIfZoneDoesNotExist", func(t *testing.T) {
+
+		dnsRecordName := "example.com"
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+				],
 				"result_info": {
 					"page": 1,
 					"per_page": 20,
@@ -1180,16 +1241,14 @@ func TestUpdateDNSRecord(t *testing.T) {
 		apiClient.restClient = fakeRESTClient
 
 		// act
-		_, err := apiClient.UpdateDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent)
+		_, err := apiClient.DeleteDNSRecord(dnsRecordName)
 
 		assert.NotNil(t, err)
 	})
 
-	t.Run("ReturnsErrorIfTypeIsDifferent", func(t *testing.T) {
+	t.Run("ReturnsErrorIfDnsRecordDoesNotExist", func(t *testing.T) {
 
-		dnsRecordType := "A"
 		dnsRecordName := "www.example.com"
-		dnsRecordContent := "1.2.3.4"
 		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
 
 		fakeRESTClient := new(fakeRESTClient)
@@ -1280,28 +1339,12 @@ func TestUpdateDNSRecord(t *testing.T) {
 				"success": true,
 				"errors": [],
 				"messages": [],
-				"result": [
-					{
-					"id": "372e67954025e0ba6aaa6d586b9e0b59",
-					"type": "CNAME",
-					"name": "www.example.com",
-					"content": "example.com",
-					"proxiable": true,
-					"proxied": false,
-					"ttl": 120,
-					"locked": false,
-					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
-					"zone_name": "example.com",
-					"created_on": "2014-01-01T05:20:00.12345Z",
-					"modified_on": "2014-01-01T05:20:00.12345Z",
-					"data": {}
-					}
-				],
+				"result": [],
 				"result_info": {
 					"page": 1,
 					"per_page": 20,
-					"count": 1,
-					"total_count": 1
+					"count": 0,
+					"total_count": 0
 				}
 			}
 		`), nil)
@@ -1310,16 +1353,14 @@ func TestUpdateDNSRecord(t *testing.T) {
 		apiClient.restClient = fakeRESTClient
 
 		// act
-		_, err := apiClient.UpdateDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent)
+		_, err := apiClient.DeleteDNSRecord(dnsRecordName)
 
 		assert.NotNil(t, err)
 	})
 
-	t.Run("ReturnsErrorIfUpdateFailed", func(t *testing.T) {
+	t.Run("ReturnsErrorIfDeletingFailed", func(t *testing.T) {
 
-		dnsRecordType := "A"
 		dnsRecordName := "www.example.com"
-		dnsRecordContent := "1.2.3.5"
 		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
 
 		fakeRESTClient := new(fakeRESTClient)
@@ -1436,47 +1477,26 @@ func TestUpdateDNSRecord(t *testing.T) {
 			}
 		`), nil)
 
-		createdOn, err := time.Parse("2006-01-02T15:04:05.00000Z", "2014-01-01T05:20:00.12345Z")
-		modifiedOn, err := time.Parse("2006-01-02T15:04:05.00000Z", "2014-01-01T05:20:00.12345Z")
-
-		updatedDNSRecord := DNSRecord{
-			ID:         "372e67954025e0ba6aaa6d586b9e0b59",
-			Type:       dnsRecordType,
-			Name:       dnsRecordName,
-			Content:    dnsRecordContent,
-			Proxiable:  true,
-			Proxied:    false,
-			TTL:        120,
-			Locked:     false,
-			ZoneID:     "023e105f4ecef8ad9ca31a8372d0c353",
-			ZoneName:   "example.com",
-			CreatedOn:  createdOn,
-			ModifiedOn: modifiedOn,
-			Data:       map[string]interface{}{},
-		}
-
-		fakeRESTClient.On("Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", updatedDNSRecord, authentication).Return([]byte(`
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return([]byte(`
 			{
 				"success": false,
-				"errors": [],
-				"messages": [],
-				"result": {}
-		`), nil)
-
+				"errors": [{"code": 1032, "message": "Invalid DNS record identifier"}],
+				"messages": []
+			}
+		`), nil)
 		apiClient := New(authentication)
 		apiClient.restClient = fakeRESTClient
 
 		// act
-		_, err = apiClient.UpdateDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent)
+		_, err := apiClient.DeleteDNSRecord(dnsRecordName)
 
 		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "1032: Invalid DNS record identifier")
 	})
 
-	t.Run("ReturnsUpdatedDnsRecordIfUpdateSucceeded", func(t *testing.T) {
+	t.Run("ReturnsTrueIfDeletingSucceeded", func(t *testing.T) {
 
-		dnsRecordType := "A"
 		dnsRecordName := "www.example.com"
-		dnsRecordContent := "1.2.3.5"
 		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
 
 		fakeRESTClient := new(fakeRESTClient)
@@ -1593,61 +1613,29 @@ func TestUpdateDNSRecord(t *testing.T) {
 			}
 		`), nil)
 
-		createdOn, err := time.Parse("2006-01-02T15:04:05.00000Z", "2014-01-01T05:20:00.12345Z")
-		modifiedOn, err := time.Parse("2006-01-02T15:04:05.00000Z", "2014-01-01T05:20:00.12345Z")
-
-		updatedDNSRecord := DNSRecord{
-			ID:         "372e67954025e0ba6aaa6d586b9e0b59",
-			Type:       dnsRecordType,
-			Name:       dnsRecordName,
-			Content:    dnsRecordContent,
-			Proxiable:  true,
-			Proxied:    false,
-			TTL:        120,
-			Locked:     false,
-			ZoneID:     "023e105f4ecef8ad9ca31a8372d0c353",
-			ZoneName:   "example.com",
-			CreatedOn:  createdOn,
-			ModifiedOn: modifiedOn,
-			Data:       map[string]interface{}{},
-		}
-
-		fakeRESTClient.On("Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", updatedDNSRecord, authentication).Return([]byte(`
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return([]byte(`
 			{
 				"success": true,
 				"errors": [],
 				"messages": [],
 				"result": {
-					"id": "372e67954025e0ba6aaa6d586b9e0b59",
-					"type": "A",
-					"name": "www.example.com",
-					"content": "1.2.3.5",
-					"proxiable": true,
-					"proxied": false,
-					"ttl": 120,
-					"locked": false,
-					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
-					"zone_name": "example.com",
-					"created_on": "2014-01-01T05:20:00.12345Z",
-					"modified_on": "2016-01-01T05:20:00.12345Z",
-					"data": {}
+					"id": "372e67954025e0ba6aaa6d586b9e0b59"
 				}
 			}
 		`), nil)
-
 		apiClient := New(authentication)
 		apiClient.restClient = fakeRESTClient
 
 		// act
-		returnedDNSRecord, err := apiClient.UpdateDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent)
+		success, err := apiClient.DeleteDNSRecord(dnsRecordName)
 
 		assert.Nil(t, err)
-		assert.Equal(t, "1.2.3.5", returnedDNSRecord.Content)
+		assert.True(t, success)
 	})
 
 }
 
-func TestUpsertDNSRecord(t *testing.T) {
+func TestUpdateDNSRecord(t *testing.T) {
 
 	t.Run("ReturnsErrorIfZoneDoesNotExist", func(t *testing.T) {
 
@@ -1677,12 +1665,12 @@ func TestUpsertDNSRecord(t *testing.T) {
 		apiClient.restClient = fakeRESTClient
 
 		// act
-		_, err := apiClient.UpsertDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent, false)
+		_, err := apiClient.UpdateDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent)
 
 		assert.NotNil(t, err)
 	})
 
-	t.Run("ReturnsErrorIfDnsRecordDoesNotExistAndCreateFails", func(t *testing.T) {
+	t.Run("ReturnsErrorIfDnsRecordDoesNotExist", func(t *testing.T) {
 
 		dnsRecordType := "A"
 		dnsRecordName := "www.example.com"
@@ -1693,6 +1681,7 @@ func TestUpsertDNSRecord(t *testing.T) {
 		fakeRESTClient := new(fakeRESTClient)
 		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return([]byte(`
 		{
+
 				"success": true,
 				"errors": [],
 				"messages": [],
@@ -1772,7 +1761,7 @@ func TestUpsertDNSRecord(t *testing.T) {
 		}
 		`), nil)
 
-		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return([]byte(`
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return([]byte(`
 			{
 				"success": true,
 				"errors": [],
@@ -1787,37 +1776,26 @@ func TestUpsertDNSRecord(t *testing.T) {
 			}
 		`), nil)
 
-		newDNSRecord := DNSRecord{Type: dnsRecordType, Name: dnsRecordName, Content: dnsRecordContent}
-
-		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", newDNSRecord, authentication).Return([]byte(`
-			{
-				"success": false,
-				"errors": [],
-				"messages": [],
-				"result": {}
-			}
-		`), nil)
-
 		apiClient := New(authentication)
 		apiClient.restClient = fakeRESTClient
 
 		// act
-		_, err := apiClient.UpsertDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent, false)
+		_, err := apiClient.UpdateDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent)
 
 		assert.NotNil(t, err)
 	})
 
-	t.Run("ReturnsDnsRecordIfDnsRecordDoesNotExistAndCreateSucceeds", func(t *testing.T) {
+	t.Run("ReturnsErrorIfOnlyARecordOfAnotherTypeExists", func(t *testing.T) {
 
 		dnsRecordType := "A"
 		dnsRecordName := "www.example.com"
 		dnsRecordContent := "1.2.3.4"
-
 		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
 
 		fakeRESTClient := new(fakeRESTClient)
 		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return([]byte(`
 		{
+
 				"success": true,
 				"errors": [],
 				"messages": [],
@@ -1897,66 +1875,28 @@ func TestUpsertDNSRecord(t *testing.T) {
 		}
 		`), nil)
 
-		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return([]byte(`
-			{
-				"success": true,
-				"errors": [],
-				"messages": [],
-				"result": [],
-				"result_info": {
-					"page": 1,
-					"per_page": 20,
-					"count": 0,
-					"total_count": 0
-				}
-			}
-		`), nil)
-
-		newDNSRecord := DNSRecord{Type: dnsRecordType, Name: dnsRecordName, Content: dnsRecordContent}
-
-		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", newDNSRecord, authentication).Return([]byte(`
-			{
-				"success": true,
-				"errors": [],
-				"messages": [],
-				"result": {
-					"id": "372e67954025e0ba6aaa6d586b9e0b59",
-					"type": "A",
-					"name": "www.example.com",
-					"content": "1.2.3.4",
-					"proxiable": true,
-					"proxied": false,
-					"ttl": 120,
-					"locked": false,
-					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
-					"zone_name": "example.com",
-					"created_on": "2014-01-01T05:20:00.12345Z",
-					"modified_on": "2014-01-01T05:20:00.12345Z",
-					"data": {}
-				}
-			}
-		`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
 
 		apiClient := New(authentication)
 		apiClient.restClient = fakeRESTClient
 
 		// act
-		createdDNSRecord, err := apiClient.UpsertDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent, false)
+		_, err := apiClient.UpdateDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent)
 
-		assert.Nil(t, err)
-		assert.Equal(t, "372e67954025e0ba6aaa6d586b9e0b59", createdDNSRecord.ID)
+		assert.NotNil(t, err)
 	})
 
-	t.Run("ReturnsNewCertificateIfDnsRecordExistsAndTypeIsDifferent", func(t *testing.T) {
+	t.Run("ReturnsErrorIfUpdateFailed", func(t *testing.T) {
 
 		dnsRecordType := "A"
 		dnsRecordName := "www.example.com"
-		dnsRecordContent := "1.2.3.4"
+		dnsRecordContent := "1.2.3.5"
 		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
 
 		fakeRESTClient := new(fakeRESTClient)
 		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return([]byte(`
 		{
+
 				"success": true,
 				"errors": [],
 				"messages": [],
@@ -2036,7 +1976,7 @@ func TestUpsertDNSRecord(t *testing.T) {
 		}
 		`), nil)
 
-		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return([]byte(`
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return([]byte(`
 			{
 				"success": true,
 				"errors": [],
@@ -2044,9 +1984,9 @@ func TestUpsertDNSRecord(t *testing.T) {
 				"result": [
 					{
 					"id": "372e67954025e0ba6aaa6d586b9e0b59",
-					"type": "CNAME",
+					"type": "A",
 					"name": "www.example.com",
-					"content": "example.com",
+					"content": "1.2.3.4",
 					"proxiable": true,
 					"proxied": false,
 					"ttl": 120,
@@ -2067,39 +2007,31 @@ func TestUpsertDNSRecord(t *testing.T) {
 			}
 		`), nil)
 
-		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return([]byte(`
-			{
-				"success": true,
-				"errors": [],
-				"messages": [],
-				"result": {
-					"id": "372e67954025e0ba6aaa6d586b9e0b59"
-				}
-			}
-		`), nil)
+		createdOn, err := time.Parse("2006-01-02T15:04:05.00000Z", "2014-01-01T05:20:00.12345Z")
+		modifiedOn, err := time.Parse("2006-01-02T15:04:05.00000Z", "2014-01-01T05:20:00.12345Z")
 
-		newDNSRecord := DNSRecord{Type: dnsRecordType, Name: dnsRecordName, Content: dnsRecordContent}
+		updatedDNSRecord := DNSRecord{
+			ID:         "372e67954025e0ba6aaa6d586b9e0b59",
+			Type:       dnsRecordType,
+			Name:       dnsRecordName,
+			Content:    dnsRecordContent,
+			Proxiable:  true,
+			Proxied:    false,
+			TTL:        120,
+			Locked:     false,
+			ZoneID:     "023e105f4ecef8ad9ca31a8372d0c353",
+			ZoneName:   "example.com",
+			CreatedOn:  createdOn,
+			ModifiedOn: modifiedOn,
+			Data:       map[string]interface{}{},
+		}
 
-		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", newDNSRecord, authentication).Return([]byte(`
+		fakeRESTClient.On("Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", updatedDNSRecord, authentication).Return([]byte(`
 			{
-				"success": true,
-				"errors": [],
+				"success": false,
+				"errors": [{"code": 1004, "message": "DNS Validation Error"}],
 				"messages": [],
-				"result": {
-					"id": "6aaa6d586b9e0b59372e67954025e0ba",
-					"type": "A",
-					"name": "www.example.com",
-					"content": "1.2.3.4",
-					"proxiable": true,
-					"proxied": false,
-					"ttl": 120,
-					"locked": false,
-					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
-					"zone_name": "example.com",
-					"created_on": "2014-01-01T05:20:00.12345Z",
-					"modified_on": "2014-01-01T05:20:00.12345Z",
-					"data": {}
-				}
+				"result": {}
 			}
 		`), nil)
 
@@ -2107,13 +2039,13 @@ func TestUpsertDNSRecord(t *testing.T) {
 		apiClient.restClient = fakeRESTClient
 
 		// act
-		createdDNSRecord, err := apiClient.UpsertDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent, false)
+		_, err = apiClient.UpdateDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent)
 
-		assert.Nil(t, err)
-		assert.Equal(t, "6aaa6d586b9e0b59372e67954025e0ba", createdDNSRecord.ID)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "1004: DNS Validation Error")
 	})
 
-	t.Run("ReturnsErrorIfDnsRecordExistsAndUpdateFailed", func(t *testing.T) {
+	t.Run("ReturnsUpdatedDnsRecordIfUpdateSucceeded", func(t *testing.T) {
 
 		dnsRecordType := "A"
 		dnsRecordName := "www.example.com"
@@ -2123,6 +2055,7 @@ func TestUpsertDNSRecord(t *testing.T) {
 		fakeRESTClient := new(fakeRESTClient)
 		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return([]byte(`
 		{
+
 				"success": true,
 				"errors": [],
 				"messages": [],
@@ -2202,7 +2135,7 @@ func TestUpsertDNSRecord(t *testing.T) {
 		}
 		`), nil)
 
-		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return([]byte(`
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return([]byte(`
 			{
 				"success": true,
 				"errors": [],
@@ -2254,26 +2187,80 @@ func TestUpsertDNSRecord(t *testing.T) {
 
 		fakeRESTClient.On("Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", updatedDNSRecord, authentication).Return([]byte(`
 			{
-				"success": false,
+				"success": true,
 				"errors": [],
 				"messages": [],
-				"result": {}
+				"result": {
+					"id": "372e67954025e0ba6aaa6d586b9e0b59",
+					"type": "A",
+					"name": "www.example.com",
+					"content": "1.2.3.5",
+					"proxiable": true,
+					"proxied": false,
+					"ttl": 120,
+					"locked": false,
+					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"zone_name": "example.com",
+					"created_on": "2014-01-01T05:20:00.12345Z",
+					"modified_on": "2016-01-01T05:20:00.12345Z",
+					"data": {}
+				}
+			}
+		`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		returnedDNSRecord, err := apiClient.UpdateDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "1.2.3.5", returnedDNSRecord.Content)
+	})
+
+}
+
+func TestUpsertDNSRecord(t *testing.T) {
+
+	t.Run("ReturnsErrorIfZoneDoesNotExist", func(t *testing.T) {
+
+		dnsRecordType := "A"
+		dnsRecordName := "example.com"
+		dnsRecordContent := "1.2.3.4"
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 0,
+					"total_count": 0
+				}
+			}
 		`), nil)
 
 		apiClient := New(authentication)
 		apiClient.restClient = fakeRESTClient
 
 		// act
-		_, err = apiClient.UpsertDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent, false)
+		_, _, err := apiClient.UpsertDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent, false, "", "", 0)
 
 		assert.NotNil(t, err)
 	})
 
-	t.Run("ReturnsUpdatedDnsRecordIfDnsRecordExistsAndUpdateSucceeded", func(t *testing.T) {
+	t.Run("ReturnsErrorIfDnsRecordDoesNotExistAndCreateFails", func(t *testing.T) {
 
 		dnsRecordType := "A"
 		dnsRecordName := "www.example.com"
-		dnsRecordContent := "1.2.3.5"
+		dnsRecordContent := "1.2.3.4"
+
 		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
 
 		fakeRESTClient := new(fakeRESTClient)
@@ -2358,76 +2345,44 @@ func TestUpsertDNSRecord(t *testing.T) {
 		}
 		`), nil)
 
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 0,
+					"total_count": 0
+				}
+			}
+		`), nil)
+
 		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return([]byte(`
 			{
 				"success": true,
 				"errors": [],
 				"messages": [],
-				"result": [
-					{
-					"id": "372e67954025e0ba6aaa6d586b9e0b59",
-					"type": "A",
-					"name": "www.example.com",
-					"content": "1.2.3.4",
-					"proxiable": true,
-					"proxied": false,
-					"ttl": 120,
-					"locked": false,
-					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
-					"zone_name": "example.com",
-					"created_on": "2014-01-01T05:20:00.12345Z",
-					"modified_on": "2014-01-01T05:20:00.12345Z",
-					"data": {}
-					}
-				],
+				"result": [],
 				"result_info": {
 					"page": 1,
 					"per_page": 20,
-					"count": 1,
-					"total_count": 1
+					"count": 0,
+					"total_count": 0
 				}
 			}
 		`), nil)
 
-		createdOn, err := time.Parse("2006-01-02T15:04:05.00000Z", "2014-01-01T05:20:00.12345Z")
-		modifiedOn, err := time.Parse("2006-01-02T15:04:05.00000Z", "2014-01-01T05:20:00.12345Z")
-
-		updatedDNSRecord := DNSRecord{
-			ID:         "372e67954025e0ba6aaa6d586b9e0b59",
-			Type:       dnsRecordType,
-			Name:       dnsRecordName,
-			Content:    dnsRecordContent,
-			Proxiable:  true,
-			Proxied:    false,
-			TTL:        120,
-			Locked:     false,
-			ZoneID:     "023e105f4ecef8ad9ca31a8372d0c353",
-			ZoneName:   "example.com",
-			CreatedOn:  createdOn,
-			ModifiedOn: modifiedOn,
-			Data:       map[string]interface{}{},
-		}
+		newDNSRecord := DNSRecord{Type: dnsRecordType, Name: dnsRecordName, Content: dnsRecordContent, TTL: 1}
 
-		fakeRESTClient.On("Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", updatedDNSRecord, authentication).Return([]byte(`
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", newDNSRecord, authentication).Return([]byte(`
 			{
-				"success": true,
-				"errors": [],
+				"success": false,
+				"errors": [{"code": 81057, "message": "Record already exists."}],
 				"messages": [],
-				"result": {
-					"id": "372e67954025e0ba6aaa6d586b9e0b59",
-					"type": "A",
-					"name": "www.example.com",
-					"content": "1.2.3.5",
-					"proxiable": true,
-					"proxied": false,
-					"ttl": 120,
-					"locked": false,
-					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
-					"zone_name": "example.com",
-					"created_on": "2014-01-01T05:20:00.12345Z",
-					"modified_on": "2016-01-01T05:20:00.12345Z",
-					"data": {}
-				}
+				"result": {}
 			}
 		`), nil)
 
@@ -2435,25 +2390,23 @@ func TestUpsertDNSRecord(t *testing.T) {
 		apiClient.restClient = fakeRESTClient
 
 		// act
-		returnedDNSRecord, err := apiClient.UpsertDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent, false)
+		_, _, err := apiClient.UpsertDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent, false, "", "", 0)
 
-		assert.Nil(t, err)
-		assert.Equal(t, "1.2.3.5", returnedDNSRecord.Content)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "81057: Record already exists.")
 	})
 
-}
-
-func TestUpdateProxySetting(t *testing.T) {
+	t.Run("ReturnsDnsRecordIfDnsRecordDoesNotExistAndCreateSucceeds", func(t *testing.T) {
 
-	t.Run("ReturnsErrorIfZoneDoesNotExist", func(t *testing.T) {
+		dnsRecordType := "A"
+		dnsRecordName := "www.example.com"
+		dnsRecordContent := "1.2.3.4"
 
-		dnsRecordName := "example.com"
-		proxy := true
 		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
 
 		fakeRESTClient := new(fakeRESTClient)
-		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`
-			{
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return([]byte(`
+		{
 				"success": true,
 				"errors": [],
 				"messages": [],
@@ -2465,6 +2418,126 @@ func TestUpdateProxySetting(t *testing.T) {
 					"count": 0,
 					"total_count": 0
 				}
+    }
+		`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{
+					"id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"name": "example.com",
+					"development_mode": 7200,
+					"original_name_servers": [
+						"ns1.originaldnshost.com",
+						"ns2.originaldnshost.com"
+					],
+					"original_registrar": "GoDaddy",
+					"original_dnshost": "NameCheap",
+					"created_on": "2014-01-01T05:20:00.12345Z",
+					"modified_on": "2014-01-01T05:20:00.12345Z",
+					"name_servers": [
+						"tony.ns.cloudflare.com",
+						"woz.ns.cloudflare.com"
+					],
+					"owner": {
+						"id": "7c5dae5552338874e5053f2534d2767a",
+						"email": "user@example.com",
+						"owner_type": "user"
+					},
+					"permissions": [
+						"#zone:read",
+						"#zone:edit"
+					],
+					"plan": {
+						"id": "e592fd9519420ba7405e1307bff33214",
+						"name": "Pro Plan",
+						"price": 20,
+						"currency": "USD",
+						"frequency": "monthly",
+						"legacy_id": "pro",
+						"is_subscribed": true,
+						"can_subscribe": true
+					},
+					"plan_pending": {
+						"id": "e592fd9519420ba7405e1307bff33214",
+						"name": "Pro Plan",
+						"price": 20,
+						"currency": "USD",
+						"frequency": "monthly",
+						"legacy_id": "pro",
+						"is_subscribed": true,
+						"can_subscribe": true
+					},
+					"status": "active",
+					"paused": false,
+					"type": "full",
+					"checked_on": "2014-01-01T05:20:00.12345Z"
+				}
+			],
+			"result_info": {
+				"page": 1,
+				"per_page": 20,
+				"count": 1,
+				"total_count": 1
+			}
+		}
+		`), nil)
+
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 0,
+					"total_count": 0
+				}
+			}
+		`), nil)
+
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 0,
+					"total_count": 0
+				}
+			}
+		`), nil)
+
+		newDNSRecord := DNSRecord{Type: dnsRecordType, Name: dnsRecordName, Content: dnsRecordContent, TTL: 1}
+
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", newDNSRecord, authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": {
+					"id": "372e67954025e0ba6aaa6d586b9e0b59",
+					"type": "A",
+					"name": "www.example.com",
+					"content": "1.2.3.4",
+					"proxiable": true,
+					"proxied": false,
+					"ttl": 120,
+					"locked": false,
+					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"zone_name": "example.com",
+					"created_on": "2014-01-01T05:20:00.12345Z",
+					"modified_on": "2014-01-01T05:20:00.12345Z",
+					"data": {}
+				}
 			}
 		`), nil)
 
@@ -2472,16 +2545,17 @@ func TestUpdateProxySetting(t *testing.T) {
 		apiClient.restClient = fakeRESTClient
 
 		// act
-		_, err := apiClient.UpdateProxySetting(dnsRecordName, proxy)
+		createdDNSRecord, _, err := apiClient.UpsertDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent, false, "", "", 0)
 
-		assert.NotNil(t, err)
+		assert.Nil(t, err)
+		assert.Equal(t, "372e67954025e0ba6aaa6d586b9e0b59", createdDNSRecord.ID)
 	})
 
-	t.Run("ReturnsErrorIfDnsRecordDoesNotExist", func(t *testing.T) {
+	t.Run("ReturnsNewCertificateIfDnsRecordExistsAndTypeIsDifferent", func(t *testing.T) {
 
+		dnsRecordType := "A"
 		dnsRecordName := "www.example.com"
-		proxy := true
-
+		dnsRecordContent := "1.2.3.4"
 		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
 
 		fakeRESTClient := new(fakeRESTClient)
@@ -2506,78 +2580,131 @@ func TestUpdateProxySetting(t *testing.T) {
 			"errors": [],
 			"messages": [],
 			"result": [
-			{
-				"id": "023e105f4ecef8ad9ca31a8372d0c353",
-				"name": "example.com",
-				"development_mode": 7200,
-				"original_name_servers": [
-				"ns1.originaldnshost.com",
-				"ns2.originaldnshost.com"
-				],
-				"original_registrar": "GoDaddy",
-				"original_dnshost": "NameCheap",
-				"created_on": "2014-01-01T05:20:00.12345Z",
-				"modified_on": "2014-01-01T05:20:00.12345Z",
-				"name_servers": [
-				"tony.ns.cloudflare.com",
-				"woz.ns.cloudflare.com"
-				],
-				"owner": {
-				"id": "7c5dae5552338874e5053f2534d2767a",
-				"email": "user@example.com",
-				"owner_type": "user"
-				},
-				"permissions": [
-				"#zone:read",
-				"#zone:edit"
-				],
-				"plan": {
-				"id": "e592fd9519420ba7405e1307bff33214",
-				"name": "Pro Plan",
-				"price": 20,
-				"currency": "USD",
-				"frequency": "monthly",
-				"legacy_id": "pro",
-				"is_subscribed": true,
-				"can_subscribe": true
-				},
-				"plan_pending": {
-				"id": "e592fd9519420ba7405e1307bff33214",
-				"name": "Pro Plan",
-				"price": 20,
-				"currency": "USD",
-				"frequency": "monthly",
-				"legacy_id": "pro",
-				"is_subscribed": true,
-				"can_subscribe": true
-				},
-				"status": "active",
-				"paused": false,
-				"type": "full",
-				"checked_on": "2014-01-01T05:20:00.12345Z"
-			}
-			],
-			"result_info": {
-			"page": 1,
-			"per_page": 20,
-			"count": 1,
-			"total_count": 1
+				{
+					"id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"name": "example.com",
+					"development_mode": 7200,
+					"original_name_servers": [
+						"ns1.originaldnshost.com",
+						"ns2.originaldnshost.com"
+					],
+					"original_registrar": "GoDaddy",
+					"original_dnshost": "NameCheap",
+					"created_on": "2014-01-01T05:20:00.12345Z",
+					"modified_on": "2014-01-01T05:20:00.12345Z",
+					"name_servers": [
+						"tony.ns.cloudflare.com",
+						"woz.ns.cloudflare.com"
+					],
+					"owner": {
+						"id": "7c5dae5552338874e5053f2534d2767a",
+						"email": "user@example.com",
+						"owner_type": "user"
+					},
+					"permissions": [
+						"#zone:read",
+						"#zone:edit"
+					],
+					"plan": {
+						"id": "e592fd9519420ba7405e1307bff33214",
+						"name": "Pro Plan",
+						"price": 20,
+						"currency": "USD",
+						"frequency": "monthly",
+						"legacy_id": "pro",
+						"is_subscribed": true,
+						"can_subscribe": true
+					},
+					"plan_pending": {
+						"id": "e592fd9519420ba7405e1307bff33214",
+						"name": "Pro Plan",
+						"price": 20,
+						"currency": "USD",
+						"frequency": "monthly",
+						"legacy_id": "pro",
+						"is_subscribed": true,
+						"can_subscribe": true
+					},
+					"status": "active",
+					"paused": false,
+					"type": "full",
+					"checked_on": "2014-01-01T05:20:00.12345Z"
+				}
+			],
+			"result_info": {
+				"page": 1,
+				"per_page": 20,
+				"count": 1,
+				"total_count": 1
 			}
 		}
 		`), nil)
 
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
 		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return([]byte(`
 			{
-			"success": true,
-			"errors": [],
-			"messages": [],
-			"result": [],
-			"result_info": {
-				"page": 1,
-				"per_page": 20,
-				"count": 0,
-				"total_count": 0
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+					{
+					"id": "372e67954025e0ba6aaa6d586b9e0b59",
+					"type": "CNAME",
+					"name": "www.example.com",
+					"content": "example.com",
+					"proxiable": true,
+					"proxied": false,
+					"ttl": 120,
+					"locked": false,
+					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"zone_name": "example.com",
+					"created_on": "2014-01-01T05:20:00.12345Z",
+					"modified_on": "2014-01-01T05:20:00.12345Z",
+					"data": {}
+					}
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 1,
+					"total_count": 1
+				}
+			}
+		`), nil)
+
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": {
+					"id": "372e67954025e0ba6aaa6d586b9e0b59"
+				}
 			}
+		`), nil)
+
+		newDNSRecord := DNSRecord{Type: dnsRecordType, Name: dnsRecordName, Content: dnsRecordContent, TTL: 1}
+
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", newDNSRecord, authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": {
+					"id": "6aaa6d586b9e0b59372e67954025e0ba",
+					"type": "A",
+					"name": "www.example.com",
+					"content": "1.2.3.4",
+					"proxiable": true,
+					"proxied": false,
+					"ttl": 120,
+					"locked": false,
+					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"zone_name": "example.com",
+					"created_on": "2014-01-01T05:20:00.12345Z",
+					"modified_on": "2014-01-01T05:20:00.12345Z",
+					"data": {}
+				}
 			}
 		`), nil)
 
@@ -2585,15 +2712,17 @@ func TestUpdateProxySetting(t *testing.T) {
 		apiClient.restClient = fakeRESTClient
 
 		// act
-		_, err := apiClient.UpdateProxySetting(dnsRecordName, proxy)
+		createdDNSRecord, _, err := apiClient.UpsertDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent, false, "", "", 0)
 
-		assert.NotNil(t, err)
+		assert.Nil(t, err)
+		assert.Equal(t, "6aaa6d586b9e0b59372e67954025e0ba", createdDNSRecord.ID)
 	})
 
-	t.Run("ReturnsErrorIfUpdateFailed", func(t *testing.T) {
+	t.Run("ReturnsErrorIfDnsRecordExistsAndUpdateFailed", func(t *testing.T) {
 
+		dnsRecordType := "A"
 		dnsRecordName := "www.example.com"
-		proxy := true
+		dnsRecordContent := "1.2.3.5"
 		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
 
 		fakeRESTClient := new(fakeRESTClient)
@@ -2614,99 +2743,99 @@ func TestUpdateProxySetting(t *testing.T) {
 		`), nil)
 		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`
 		{
-		"success": true,
-		"errors": [],
-		"messages": [],
-		"result": [
-			{
-			"id": "023e105f4ecef8ad9ca31a8372d0c353",
-			"name": "example.com",
-			"development_mode": 7200,
-			"original_name_servers": [
-				"ns1.originaldnshost.com",
-				"ns2.originaldnshost.com"
-			],
-			"original_registrar": "GoDaddy",
-			"original_dnshost": "NameCheap",
-			"created_on": "2014-01-01T05:20:00.12345Z",
-			"modified_on": "2014-01-01T05:20:00.12345Z",
-			"name_servers": [
-				"tony.ns.cloudflare.com",
-				"woz.ns.cloudflare.com"
-			],
-			"owner": {
-				"id": "7c5dae5552338874e5053f2534d2767a",
-				"email": "user@example.com",
-				"owner_type": "user"
-			},
-			"permissions": [
-				"#zone:read",
-				"#zone:edit"
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{
+					"id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"name": "example.com",
+					"development_mode": 7200,
+					"original_name_servers": [
+						"ns1.originaldnshost.com",
+						"ns2.originaldnshost.com"
+					],
+					"original_registrar": "GoDaddy",
+					"original_dnshost": "NameCheap",
+					"created_on": "2014-01-01T05:20:00.12345Z",
+					"modified_on": "2014-01-01T05:20:00.12345Z",
+					"name_servers": [
+						"tony.ns.cloudflare.com",
+						"woz.ns.cloudflare.com"
+					],
+					"owner": {
+						"id": "7c5dae5552338874e5053f2534d2767a",
+						"email": "user@example.com",
+						"owner_type": "user"
+					},
+					"permissions": [
+						"#zone:read",
+						"#zone:edit"
+					],
+					"plan": {
+						"id": "e592fd9519420ba7405e1307bff33214",
+						"name": "Pro Plan",
+						"price": 20,
+						"currency": "USD",
+						"frequency": "monthly",
+						"legacy_id": "pro",
+						"is_subscribed": true,
+						"can_subscribe": true
+					},
+					"plan_pending": {
+						"id": "e592fd9519420ba7405e1307bff33214",
+						"name": "Pro Plan",
+						"price": 20,
+						"currency": "USD",
+						"frequency": "monthly",
+						"legacy_id": "pro",
+						"is_subscribed": true,
+						"can_subscribe": true
+					},
+					"status": "active",
+					"paused": false,
+					"type": "full",
+					"checked_on": "2014-01-01T05:20:00.12345Z"
+				}
 			],
-			"plan": {
-				"id": "e592fd9519420ba7405e1307bff33214",
-				"name": "Pro Plan",
-				"price": 20,
-				"currency": "USD",
-				"frequency": "monthly",
-				"legacy_id": "pro",
-				"is_subscribed": true,
-				"can_subscribe": true
-			},
-			"plan_pending": {
-				"id": "e592fd9519420ba7405e1307bff33214",
-				"name": "Pro Plan",
-				"price": 20,
-				"currency": "USD",
-				"frequency": "monthly",
-				"legacy_id": "pro",
-				"is_subscribed": true,
-				"can_subscribe": true
-			},
-			"status": "active",
-			"paused": false,
-			"type": "full",
-			"checked_on": "2014-01-01T05:20:00.12345Z"
+			"result_info": {
+				"page": 1,
+				"per_page": 20,
+				"count": 1,
+				"total_count": 1
 			}
-		],
-		"result_info": {
-			"page": 1,
-			"per_page": 20,
-			"count": 1,
-			"total_count": 1
-		}
 		}
 		`), nil)
 
-		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return([]byte(`
-		{
-			"success": true,
-			"errors": [],
-			"messages": [],
-			"result": [
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return([]byte(`
 			{
-			"id": "372e67954025e0ba6aaa6d586b9e0b59",
-			"type": "A",
-			"name": "www.example.com",
-			"content": "1.2.3.4",
-			"proxiable": true,
-			"proxied": false,
-			"ttl": 120,
-			"locked": false,
-			"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
-			"zone_name": "example.com",
-			"created_on": "2014-01-01T05:20:00.12345Z",
-			"modified_on": "2014-01-01T05:20:00.12345Z",
-			"data": {}
-			}
-			],
-			"result_info": {
-			"page": 1,
-			"per_page": 20,
-			"count": 1,
-			"total_count": 1
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+					{
+					"id": "372e67954025e0ba6aaa6d586b9e0b59",
+					"type": "A",
+					"name": "www.example.com",
+					"content": "1.2.3.4",
+					"proxiable": true,
+					"proxied": false,
+					"ttl": 120,
+					"locked": false,
+					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"zone_name": "example.com",
+					"created_on": "2014-01-01T05:20:00.12345Z",
+					"modified_on": "2014-01-01T05:20:00.12345Z",
+					"data": {}
+					}
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 1,
+					"total_count": 1
+				}
 			}
-		}
 		`), nil)
 
 		createdOn, err := time.Parse("2006-01-02T15:04:05.00000Z", "2014-01-01T05:20:00.12345Z")
@@ -2714,11 +2843,11 @@ func TestUpdateProxySetting(t *testing.T) {
 
 		updatedDNSRecord := DNSRecord{
 			ID:         "372e67954025e0ba6aaa6d586b9e0b59",
-			Type:       "A",
+			Type:       dnsRecordType,
 			Name:       dnsRecordName,
-			Content:    "1.2.3.4",
+			Content:    dnsRecordContent,
 			Proxiable:  true,
-			Proxied:    true,
+			Proxied:    false,
 			TTL:        120,
 			Locked:     false,
 			ZoneID:     "023e105f4ecef8ad9ca31a8372d0c353",
@@ -2729,26 +2858,29 @@ func TestUpdateProxySetting(t *testing.T) {
 		}
 
 		fakeRESTClient.On("Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", updatedDNSRecord, authentication).Return([]byte(`
-		{
-			"success": false,
-			"errors": [],
-			"messages": [],
-			"result": {}
+			{
+				"success": false,
+				"errors": [{"code": 1004, "message": "DNS Validation Error"}],
+				"messages": [],
+				"result": {}
+			}
 		`), nil)
 
 		apiClient := New(authentication)
 		apiClient.restClient = fakeRESTClient
 
 		// act
-		_, err = apiClient.UpdateProxySetting(dnsRecordName, proxy)
+		_, _, err = apiClient.UpsertDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent, false, "", "", 0)
 
 		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "1004: DNS Validation Error")
 	})
 
-	t.Run("ReturnsUpdatedDnsRecordIfUpdateSucceeded", func(t *testing.T) {
+	t.Run("ReturnsUpdatedDnsRecordIfDnsRecordExistsAndUpdateSucceeded", func(t *testing.T) {
 
+		dnsRecordType := "A"
 		dnsRecordName := "www.example.com"
-		proxy := true
+		dnsRecordContent := "1.2.3.5"
 		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
 
 		fakeRESTClient := new(fakeRESTClient)
@@ -2768,112 +2900,112 @@ func TestUpdateProxySetting(t *testing.T) {
     }
 		`), nil)
 		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`
-		{
-		"success": true,
-		"errors": [],
-		"messages": [],
-		"result": [
-			{
-			"id": "023e105f4ecef8ad9ca31a8372d0c353",
-			"name": "example.com",
-			"development_mode": 7200,
-			"original_name_servers": [
-				"ns1.originaldnshost.com",
-				"ns2.originaldnshost.com"
-			],
-			"original_registrar": "GoDaddy",
-			"original_dnshost": "NameCheap",
-			"created_on": "2014-01-01T05:20:00.12345Z",
-			"modified_on": "2014-01-01T05:20:00.12345Z",
-			"name_servers": [
-				"tony.ns.cloudflare.com",
-				"woz.ns.cloudflare.com"
-			],
-			"owner": {
-				"id": "7c5dae5552338874e5053f2534d2767a",
-				"email": "user@example.com",
-				"owner_type": "user"
-			},
-			"permissions": [
-				"#zone:read",
-				"#zone:edit"
-			],
-			"plan": {
-				"id": "e592fd9519420ba7405e1307bff33214",
-				"name": "Pro Plan",
-				"price": 20,
-				"currency": "USD",
-				"frequency": "monthly",
-				"legacy_id": "pro",
-				"is_subscribed": true,
-				"can_subscribe": true
-			},
-			"plan_pending": {
-				"id": "e592fd9519420ba7405e1307bff33214",
-				"name": "Pro Plan",
-				"price": 20,
-				"currency": "USD",
-				"frequency": "monthly",
-				"legacy_id": "pro",
-				"is_subscribed": true,
-				"can_subscribe": true
-			},
-			"status": "active",
-			"paused": false,
-			"type": "full",
-			"checked_on": "2014-01-01T05:20:00.12345Z"
-			}
-		],
-		"result_info": {
-			"page": 1,
-			"per_page": 20,
-			"count": 1,
-			"total_count": 1
-		}
-		}
-		`), nil)
-
-		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return([]byte(`
 		{
 			"success": true,
 			"errors": [],
 			"messages": [],
 			"result": [
-			{
-			"id": "372e67954025e0ba6aaa6d586b9e0b59",
-			"type": "A",
-			"name": "www.example.com",
-			"content": "1.2.3.4",
-			"proxiable": true,
-			"proxied": false,
-			"ttl": 120,
-			"locked": false,
-			"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
-			"zone_name": "example.com",
-			"created_on": "2014-01-01T05:20:00.12345Z",
-			"modified_on": "2014-01-01T05:20:00.12345Z",
-			"data": {}
-			}
+				{
+					"id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"name": "example.com",
+					"development_mode": 7200,
+					"original_name_servers": [
+						"ns1.originaldnshost.com",
+						"ns2.originaldnshost.com"
+					],
+					"original_registrar": "GoDaddy",
+					"original_dnshost": "NameCheap",
+					"created_on": "2014-01-01T05:20:00.12345Z",
+					"modified_on": "2014-01-01T05:20:00.12345Z",
+					"name_servers": [
+						"tony.ns.cloudflare.com",
+						"woz.ns.cloudflare.com"
+					],
+					"owner": {
+						"id": "7c5dae5552338874e5053f2534d2767a",
+						"email": "user@example.com",
+						"owner_type": "user"
+					},
+					"permissions": [
+						"#zone:read",
+						"#zone:edit"
+					],
+					"plan": {
+						"id": "e592fd9519420ba7405e1307bff33214",
+						"name": "Pro Plan",
+						"price": 20,
+						"currency": "USD",
+						"frequency": "monthly",
+						"legacy_id": "pro",
+						"is_subscribed": true,
+						"can_subscribe": true
+					},
+					"plan_pending": {
+						"id": "e592fd9519420ba7405e1307bff33214",
+						"name": "Pro Plan",
+						"price": 20,
+						"currency": "USD",
+						"frequency": "monthly",
+						"legacy_id": "pro",
+						"is_subscribed": true,
+						"can_subscribe": true
+					},
+					"status": "active",
+					"paused": false,
+					"type": "full",
+					"checked_on": "2014-01-01T05:20:00.12345Z"
+				}
 			],
 			"result_info": {
-			"page": 1,
-			"per_page": 20,
-			"count": 1,
-			"total_count": 1
+				"page": 1,
+				"per_page": 20,
+				"count": 1,
+				"total_count": 1
 			}
 		}
 		`), nil)
 
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+					{
+					"id": "372e67954025e0ba6aaa6d586b9e0b59",
+					"type": "A",
+					"name": "www.example.com",
+					"content": "1.2.3.4",
+					"proxiable": true,
+					"proxied": false,
+					"ttl": 120,
+					"locked": false,
+					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"zone_name": "example.com",
+					"created_on": "2014-01-01T05:20:00.12345Z",
+					"modified_on": "2014-01-01T05:20:00.12345Z",
+					"data": {}
+					}
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 1,
+					"total_count": 1
+				}
+			}
+		`), nil)
+
 		createdOn, err := time.Parse("2006-01-02T15:04:05.00000Z", "2014-01-01T05:20:00.12345Z")
 		modifiedOn, err := time.Parse("2006-01-02T15:04:05.00000Z", "2014-01-01T05:20:00.12345Z")
 
 		updatedDNSRecord := DNSRecord{
 			ID:         "372e67954025e0ba6aaa6d586b9e0b59",
-			Type:       "A",
+			Type:       dnsRecordType,
 			Name:       dnsRecordName,
-			Content:    "1.2.3.4",
+			Content:    dnsRecordContent,
 			Proxiable:  true,
-			Proxied:    true,
+			Proxied:    false,
 			TTL:        120,
 			Locked:     false,
 			ZoneID:     "023e105f4ecef8ad9ca31a8372d0c353",
@@ -2884,35 +3016,2997 @@ func TestUpdateProxySetting(t *testing.T) {
 		}
 
 		fakeRESTClient.On("Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", updatedDNSRecord, authentication).Return([]byte(`
-		{
-			"success": true,
-			"errors": [],
-			"messages": [],
-			"result": {
-			"id": "372e67954025e0ba6aaa6d586b9e0b59",
-			"type": "A",
-			"name": "www.example.com",
-			"content": "1.2.3.4",
-			"proxiable": true,
-			"proxied": true,
-			"ttl": 120,
-			"locked": false,
-			"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": {
+					"id": "372e67954025e0ba6aaa6d586b9e0b59",
+					"type": "A",
+					"name": "www.example.com",
+					"content": "1.2.3.5",
+					"proxiable": true,
+					"proxied": false,
+					"ttl": 120,
+					"locked": false,
+					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"zone_name": "example.com",
+					"created_on": "2014-01-01T05:20:00.12345Z",
+					"modified_on": "2016-01-01T05:20:00.12345Z",
+					"data": {}
+				}
+			}
+		`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		returnedDNSRecord, _, err := apiClient.UpsertDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent, false, "", "", 0)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "1.2.3.5", returnedDNSRecord.Content)
+	})
+
+	t.Run("CreatesDnsRecordWithProxySettingApplied", func(t *testing.T) {
+
+		dnsRecordType := "A"
+		dnsRecordName := "example.com"
+		dnsRecordContent := "1.2.3.4"
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+					{
+						"id": "023e105f4ecef8ad9ca31a8372d0c353",
+						"name": "example.com"
+					}
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 1,
+					"total_count": 1
+				}
+			}
+		`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=A", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 0,
+					"total_count": 0
+				}
+			}
+		`), nil)
+
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 0,
+					"total_count": 0
+				}
+			}
+		`), nil)
+
+		newDNSRecord := DNSRecord{Type: dnsRecordType, Name: dnsRecordName, Content: dnsRecordContent, Proxied: true, TTL: 1}
+
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", newDNSRecord, authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": {
+					"id": "6aaa6d586b9e0b59372e67954025e0ba",
+					"type": "A",
+					"name": "example.com",
+					"content": "1.2.3.4",
+					"proxiable": true,
+					"proxied": true,
+					"ttl": 1,
+					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"zone_name": "example.com"
+				}
+			}
+		`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		createdDNSRecord, _, err := apiClient.UpsertDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent, true, "", "", 0)
+
+		assert.Nil(t, err)
+		assert.True(t, createdDNSRecord.Proxied)
+		fakeRESTClient.AssertCalled(t, "Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", newDNSRecord, authentication)
+	})
+
+	t.Run("RecreatesDnsRecordOfNewTypeWithProxySettingApplied", func(t *testing.T) {
+
+		dnsRecordType := "A"
+		dnsRecordName := "example.com"
+		dnsRecordContent := "1.2.3.4"
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+					{
+						"id": "023e105f4ecef8ad9ca31a8372d0c353",
+						"name": "example.com"
+					}
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 1,
+					"total_count": 1
+				}
+			}
+		`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=A", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+					{
+						"id": "372e67954025e0ba6aaa6d586b9e0b59",
+						"type": "CNAME",
+						"name": "example.com",
+						"content": "lb.example.net",
+						"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+						"zone_name": "example.com"
+					}
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 1,
+					"total_count": 1
+				}
+			}
+		`), nil)
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": {
+					"id": "372e67954025e0ba6aaa6d586b9e0b59"
+				}
+			}
+		`), nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": {
+					"id": "6aaa6d586b9e0b59372e67954025e0ba",
+					"type": "A",
+					"name": "example.com",
+					"content": "1.2.3.4",
+					"proxiable": true,
+					"proxied": true,
+					"ttl": 1,
+					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"zone_name": "example.com"
+				}
+			}
+		`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		_, _, err := apiClient.UpsertDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent, true, "", "", 0)
+
+		assert.Nil(t, err)
+		fakeRESTClient.AssertCalled(t, "Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.MatchedBy(func(params interface{}) bool {
+			payload, _ := json.Marshal(params)
+			return strings.Contains(string(payload), `"proxied":true`)
+		}), authentication)
+	})
+
+	t.Run("UpdatesARecordNextToTxtRecordByTheSameName", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=A", authentication).Return([]byte(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "example.com", "content": "1.2.3.4", "ttl": 1, "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "example.com", "content": "1.2.3.4", "ttl": 1, "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}, {"id": "6aaa6d586b9e0b59372e67954025e0ba", "type": "TXT", "name": "example.com", "content": "v=spf1 -all", "ttl": 1, "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}], "result_info": {"per_page": 20, "count": 2}}`), nil)
+		fakeRESTClient.On("Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", mock.Anything, authentication).Return([]byte(`{"success": true, "result": {"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "example.com", "content": "5.6.7.8", "ttl": 1, "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}}`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		updatedDNSRecord, _, err := apiClient.UpsertDNSRecord("A", "example.com", "5.6.7.8", false, "", "", 0)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "5.6.7.8", updatedDNSRecord.Content)
+		fakeRESTClient.AssertNotCalled(t, "Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com", authentication)
+		fakeRESTClient.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("CreatesARecordNextToTxtRecordByTheSameName", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=A", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "6aaa6d586b9e0b59372e67954025e0ba", "type": "TXT", "name": "example.com", "content": "v=spf1 -all", "ttl": 1, "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return([]byte(`{"success": true, "result": {"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "example.com", "content": "1.2.3.4", "ttl": 1, "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}}`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		createdDNSRecord, _, err := apiClient.UpsertDNSRecord("A", "example.com", "1.2.3.4", false, "", "", 0)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "372e67954025e0ba6aaa6d586b9e0b59", createdDNSRecord.ID)
+		fakeRESTClient.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("LeavesRecordOfOtherAddressTypeAloneIfNotOwned", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=A", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "CNAME", "name": "example.com", "content": "lb.example.net", "comment": "created by hand", "ttl": 1, "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+		apiClient.ownershipMarker = defaultCloudflareComment
+
+		// act
+		dnsRecord, _, err := apiClient.UpsertDNSRecord("A", "example.com", "1.2.3.4", false, "", "", 0)
+
+		assert.True(t, errors.Is(err, errDNSRecordNotOwned))
+		assert.Equal(t, "CNAME", dnsRecord.Type)
+		fakeRESTClient.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+		fakeRESTClient.AssertNotCalled(t, "Post", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("ObservesAgeOfExistingDnsRecord", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=age.example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "age.example.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=age.example.com&type=A", authentication).Return([]byte(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "age.example.com", "content": "1.2.3.4", "ttl": 1, "zone_id": "023e105f4ecef8ad9ca31a8372d0c353", "modified_on": "2014-01-01T05:20:00.12345Z"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		observer := dnsRecordAgeSeconds.With(prometheus.Labels{"zone": "age.example.com"})
+		before := getHistogramSampleCount(t, observer)
+
+		// act
+		_, _, err := apiClient.UpsertDNSRecord("A", "age.example.com", "1.2.3.4", false, "", "", 0)
+
+		assert.Nil(t, err)
+		assert.Equal(t, before+1, getHistogramSampleCount(t, observer))
+	})
+
+	t.Run("CreatesDnsRecordWithRegion", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=A", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+
+		newDNSRecord := DNSRecord{Type: "A", Name: "example.com", Content: "1.2.3.4", Proxied: true, TTL: 1, Region: "eu"}
+
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", newDNSRecord, authentication).Return([]byte(`{"success": true, "result": {"id": "6aaa6d586b9e0b59372e67954025e0ba", "type": "A", "name": "example.com", "content": "1.2.3.4", "proxied": true, "ttl": 1, "region": "eu", "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}}`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		createdDNSRecord, _, err := apiClient.UpsertDNSRecord("A", "example.com", "1.2.3.4", true, "", "eu", 0)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "eu", createdDNSRecord.Region)
+		fakeRESTClient.AssertCalled(t, "Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.MatchedBy(func(params interface{}) bool {
+			payload, _ := json.Marshal(params)
+			return strings.Contains(string(payload), `"region":"eu"`)
+		}), authentication)
+	})
+
+	t.Run("UpdatesRegionOfExistingDnsRecord", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=A", authentication).Return([]byte(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "example.com", "content": "1.2.3.4", "ttl": 1, "region": "us", "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", mock.Anything, authentication).Return([]byte(`{"success": true, "result": {"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "example.com", "content": "1.2.3.4", "ttl": 1, "region": "eu", "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}}`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		_, _, err := apiClient.UpsertDNSRecord("A", "example.com", "1.2.3.4", false, "", "eu", 0)
+
+		assert.Nil(t, err)
+		fakeRESTClient.AssertCalled(t, "Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", mock.MatchedBy(func(params interface{}) bool {
+			payload, _ := json.Marshal(params)
+			return strings.Contains(string(payload), `"region":"eu"`)
+		}), authentication)
+	})
+
+	t.Run("CreatesDnsRecordWithTTL", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=A", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return([]byte(`{"success": true, "result": {"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "example.com", "content": "1.2.3.4", "ttl": 300, "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}}`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		createdDNSRecord, _, err := apiClient.UpsertDNSRecord("A", "example.com", "1.2.3.4", false, "", "", 300)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 300, createdDNSRecord.TTL)
+		fakeRESTClient.AssertCalled(t, "Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.MatchedBy(func(dnsRecord DNSRecord) bool { return dnsRecord.TTL == 300 }), authentication)
+		fakeRESTClient.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("UpdatesTTLOfExistingDnsRecord", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=A", authentication).Return([]byte(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "example.com", "content": "1.2.3.4", "ttl": 1, "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", mock.Anything, authentication).Return([]byte(`{"success": true, "result": {"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "example.com", "content": "1.2.3.4", "ttl": 300, "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}}`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		updatedDNSRecord, updated, err := apiClient.UpsertDNSRecord("A", "example.com", "1.2.3.4", false, "", "", 300)
+
+		assert.Nil(t, err)
+		assert.True(t, updated)
+		assert.Equal(t, 300, updatedDNSRecord.TTL)
+		fakeRESTClient.AssertCalled(t, "Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", mock.MatchedBy(func(dnsRecord DNSRecord) bool { return dnsRecord.TTL == 300 }), authentication)
+	})
+
+	t.Run("DoesNotUpdateRecordOnSecondUpsertIfUnchanged", func(t *testing.T) {
+
+		dnsRecordType := "A"
+		dnsRecordName := "example.com"
+		dnsRecordContent := "1.2.3.4"
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+		createdDNSRecord := `{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "example.com", "content": "1.2.3.4", "comment": "managed by estafette-cloudflare-dns", "proxiable": true, "proxied": false, "ttl": 1, "zone_id": "023e105f4ecef8ad9ca31a8372d0c353", "zone_name": "example.com"}`
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=A", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil).Once()
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=A", authentication).Return([]byte(`{"success": true, "result": [`+createdDNSRecord+`], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return([]byte(`{"success": true, "result": `+createdDNSRecord+`}`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		createdRecord, created, err := apiClient.UpsertDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent, false, defaultCloudflareComment, "", 0)
+		assert.Nil(t, err)
+		upsertedRecord, updated, err := apiClient.UpsertDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent, false, defaultCloudflareComment, "", 0)
+
+		assert.Nil(t, err)
+		assert.True(t, created)
+		assert.False(t, updated)
+		assert.Equal(t, 1, createdRecord.TTL)
+		assert.Equal(t, createdRecord.TTL, upsertedRecord.TTL)
+		fakeRESTClient.AssertNumberOfCalls(t, "Post", 1)
+		fakeRESTClient.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("LeavesNonProxiableDnsRecordDnsOnlyInsteadOfForcingAutomaticTTL", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+		zone := Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353", Name: "example.com", Plan: ZonePlan{LegacyID: "free"}}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return([]byte(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "www.example.com", "content": "10.0.0.1", "comment": "managed by estafette-cloudflare-dns", "proxiable": false, "proxied": false, "ttl": 120, "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		dnsRecord, _, err := apiClient.UpsertDNSRecordByZone(zone, "A", "www.example.com", "10.0.0.1", true, defaultCloudflareComment, "", 0)
+
+		assert.Nil(t, err)
+		assert.False(t, dnsRecord.Proxied)
+		assert.Equal(t, 120, dnsRecord.TTL)
+		fakeRESTClient.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("CreatesDnsRecordOfTypeThatCannotBeProxiedDnsOnly", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+		zone := Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353", Name: "example.com", Plan: ZonePlan{LegacyID: "free"}}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=TXT", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.MatchedBy(func(dnsRecord DNSRecord) bool { return !dnsRecord.Proxied }), authentication).Return([]byte(`{"success": true, "result": {"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "TXT", "name": "www.example.com", "content": "verification", "proxiable": false, "proxied": false, "ttl": 1}}`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		dnsRecord, _, err := apiClient.UpsertDNSRecordByZone(zone, "TXT", "www.example.com", "verification", true, defaultCloudflareComment, "", 0)
+
+		assert.Nil(t, err)
+		assert.False(t, dnsRecord.Proxied)
+		fakeRESTClient.AssertNumberOfCalls(t, "Post", 1)
+	})
+}
+
+func TestUpdateProxySetting(t *testing.T) {
+
+	t.Run("ReturnsErrorIfZoneDoesNotExist", func(t *testing.T) {
+
+		dnsRecordName := "example.com"
+		proxy := true
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 0,
+					"total_count": 0
+				}
+			}
+		`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		_, err := apiClient.UpdateProxySetting("A", dnsRecordName, proxy)
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorIfDnsRecordDoesNotExist", func(t *testing.T) {
+
+		dnsRecordName := "www.example.com"
+		proxy := true
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return([]byte(`
+		{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 0,
+					"total_count": 0
+				}
+    }
+		`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+			{
+				"id": "023e105f4ecef8ad9ca31a8372d0c353",
+				"name": "example.com",
+				"development_mode": 7200,
+				"original_name_servers": [
+				"ns1.originaldnshost.com",
+				"ns2.originaldnshost.com"
+				],
+				"original_registrar": "GoDaddy",
+				"original_dnshost": "NameCheap",
+				"created_on": "2014-01-01T05:20:00.12345Z",
+				"modified_on": "2014-01-01T05:20:00.12345Z",
+				"name_servers": [
+				"tony.ns.cloudflare.com",
+				"woz.ns.cloudflare.com"
+				],
+				"owner": {
+				"id": "7c5dae5552338874e5053f2534d2767a",
+				"email": "user@example.com",
+				"owner_type": "user"
+				},
+				"permissions": [
+				"#zone:read",
+				"#zone:edit"
+				],
+				"plan": {
+				"id": "e592fd9519420ba7405e1307bff33214",
+				"name": "Pro Plan",
+				"price": 20,
+				"currency": "USD",
+				"frequency": "monthly",
+				"legacy_id": "pro",
+				"is_subscribed": true,
+				"can_subscribe": true
+				},
+				"plan_pending": {
+				"id": "e592fd9519420ba7405e1307bff33214",
+				"name": "Pro Plan",
+				"price": 20,
+				"currency": "USD",
+				"frequency": "monthly",
+				"legacy_id": "pro",
+				"is_subscribed": true,
+				"can_subscribe": true
+				},
+				"status": "active",
+				"paused": false,
+				"type": "full",
+				"checked_on": "2014-01-01T05:20:00.12345Z"
+			}
+			],
+			"result_info": {
+			"page": 1,
+			"per_page": 20,
+			"count": 1,
+			"total_count": 1
+			}
+		}
+		`), nil)
+
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return([]byte(`
+			{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [],
+			"result_info": {
+				"page": 1,
+				"per_page": 20,
+				"count": 0,
+				"total_count": 0
+			}
+			}
+		`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		_, err := apiClient.UpdateProxySetting("A", dnsRecordName, proxy)
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorIfUpdateFailed", func(t *testing.T) {
+
+		dnsRecordName := "www.example.com"
+		proxy := true
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return([]byte(`
+		{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 0,
+					"total_count": 0
+				}
+    }
+		`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`
+		{
+		"success": true,
+		"errors": [],
+		"messages": [],
+		"result": [
+			{
+			"id": "023e105f4ecef8ad9ca31a8372d0c353",
+			"name": "example.com",
+			"development_mode": 7200,
+			"original_name_servers": [
+				"ns1.originaldnshost.com",
+				"ns2.originaldnshost.com"
+			],
+			"original_registrar": "GoDaddy",
+			"original_dnshost": "NameCheap",
+			"created_on": "2014-01-01T05:20:00.12345Z",
+			"modified_on": "2014-01-01T05:20:00.12345Z",
+			"name_servers": [
+				"tony.ns.cloudflare.com",
+				"woz.ns.cloudflare.com"
+			],
+			"owner": {
+				"id": "7c5dae5552338874e5053f2534d2767a",
+				"email": "user@example.com",
+				"owner_type": "user"
+			},
+			"permissions": [
+				"#zone:read",
+				"#zone:edit"
+			],
+			"plan": {
+				"id": "e592fd9519420ba7405e1307bff33214",
+				"name": "Pro Plan",
+				"price": 20,
+				"currency": "USD",
+				"frequency": "monthly",
+				"legacy_id": "pro",
+				"is_subscribed": true,
+				"can_subscribe": true
+			},
+			"plan_pending": {
+				"id": "e592fd9519420ba7405e1307bff33214",
+				"name": "Pro Plan",
+				"price": 20,
+				"currency": "USD",
+				"frequency": "monthly",
+				"legacy_id": "pro",
+				"is_subscribed": true,
+				"can_subscribe": true
+			},
+			"status": "active",
+			"paused": false,
+			"type": "full",
+			"checked_on": "2014-01-01T05:20:00.12345Z"
+			}
+		],
+		"result_info": {
+			"page": 1,
+			"per_page": 20,
+			"count": 1,
+			"total_count": 1
+		}
+		}
+		`), nil)
+
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return([]byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+			{
+			"id": "372e67954025e0ba6aaa6d586b9e0b59",
+			"type": "A",
+			"name": "www.example.com",
+			"content": "1.2.3.4",
+			"proxiable": true,
+			"proxied": false,
+			"ttl": 120,
+			"locked": false,
+			"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+			"zone_name": "example.com",
+			"created_on": "2014-01-01T05:20:00.12345Z",
+			"modified_on": "2014-01-01T05:20:00.12345Z",
+			"data": {}
+			}
+			],
+			"result_info": {
+			"page": 1,
+			"per_page": 20,
+			"count": 1,
+			"total_count": 1
+			}
+		}
+		`), nil)
+
+		fakeRESTClient.On("Patch", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", dnsRecordProxyPatch{Proxied: true, TTL: 1}, authentication).Return([]byte(`
+		{
+			"success": false,
+			"errors": [{"code": 1004, "message": "DNS Validation Error"}],
+			"messages": [],
+			"result": {}
+		}
+		`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		_, err := apiClient.UpdateProxySetting("A", dnsRecordName, proxy)
+
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "1004: DNS Validation Error")
+	})
+
+	t.Run("ReturnsUpdatedDnsRecordIfUpdateSucceeded", func(t *testing.T) {
+
+		dnsRecordName := "www.example.com"
+		proxy := true
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return([]byte(`
+		{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 0,
+					"total_count": 0
+				}
+    }
+		`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`
+		{
+		"success": true,
+		"errors": [],
+		"messages": [],
+		"result": [
+			{
+			"id": "023e105f4ecef8ad9ca31a8372d0c353",
+			"name": "example.com",
+			"development_mode": 7200,
+			"original_name_servers": [
+				"ns1.originaldnshost.com",
+				"ns2.originaldnshost.com"
+			],
+			"original_registrar": "GoDaddy",
+			"original_dnshost": "NameCheap",
+			"created_on": "2014-01-01T05:20:00.12345Z",
+			"modified_on": "2014-01-01T05:20:00.12345Z",
+			"name_servers": [
+				"tony.ns.cloudflare.com",
+				"woz.ns.cloudflare.com"
+			],
+			"owner": {
+				"id": "7c5dae5552338874e5053f2534d2767a",
+				"email": "user@example.com",
+				"owner_type": "user"
+			},
+			"permissions": [
+				"#zone:read",
+				"#zone:edit"
+			],
+			"plan": {
+				"id": "e592fd9519420ba7405e1307bff33214",
+				"name": "Pro Plan",
+				"price": 20,
+				"currency": "USD",
+				"frequency": "monthly",
+				"legacy_id": "pro",
+				"is_subscribed": true,
+				"can_subscribe": true
+			},
+			"plan_pending": {
+				"id": "e592fd9519420ba7405e1307bff33214",
+				"name": "Pro Plan",
+				"price": 20,
+				"currency": "USD",
+				"frequency": "monthly",
+				"legacy_id": "pro",
+				"is_subscribed": true,
+				"can_subscribe": true
+			},
+			"status": "active",
+			"paused": false,
+			"type": "full",
+			"checked_on": "2014-01-01T05:20:00.12345Z"
+			}
+		],
+		"result_info": {
+			"page": 1,
+			"per_page": 20,
+			"count": 1,
+			"total_count": 1
+		}
+		}
+		`), nil)
+
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return([]byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+			{
+			"id": "372e67954025e0ba6aaa6d586b9e0b59",
+			"type": "A",
+			"name": "www.example.com",
+			"content": "1.2.3.4",
+			"proxiable": true,
+			"proxied": false,
+			"ttl": 120,
+			"locked": false,
+			"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+			"zone_name": "example.com",
+			"created_on": "2014-01-01T05:20:00.12345Z",
+			"modified_on": "2014-01-01T05:20:00.12345Z",
+			"data": {}
+			}
+			],
+			"result_info": {
+			"page": 1,
+			"per_page": 20,
+			"count": 1,
+			"total_count": 1
+			}
+		}
+		`), nil)
+
+		fakeRESTClient.On("Patch", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", dnsRecordProxyPatch{Proxied: true, TTL: 1}, authentication).Return([]byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": {
+			"id": "372e67954025e0ba6aaa6d586b9e0b59",
+			"type": "A",
+			"name": "www.example.com",
+			"content": "1.2.3.4",
+			"proxiable": true,
+			"proxied": true,
+			"ttl": 120,
+			"locked": false,
+			"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
 			"zone_name": "example.com",
 			"created_on": "2014-01-01T05:20:00.12345Z",
 			"modified_on": "2016-01-01T05:20:00.12345Z",
 			"data": {}
 			}
 		}
-		`), nil)
+		`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		returnedDNSRecord, err := apiClient.UpdateProxySetting("A", dnsRecordName, proxy)
+
+		assert.Nil(t, err)
+		assert.Equal(t, true, returnedDNSRecord.Proxied)
+	})
+
+	t.Run("DoesNotUpdateIfProxySettingAlreadyMatches", func(t *testing.T) {
+
+		dnsRecordName := "example.com"
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+					{
+						"id": "023e105f4ecef8ad9ca31a8372d0c353",
+						"name": "example.com"
+					}
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 1,
+					"total_count": 1
+				}
+			}
+		`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=A", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+					{
+						"id": "372e67954025e0ba6aaa6d586b9e0b59",
+						"type": "A",
+						"name": "example.com",
+						"content": "1.2.3.4",
+						"proxiable": true,
+						"proxied": true,
+						"ttl": 1,
+						"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+						"zone_name": "example.com"
+					}
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 1,
+					"total_count": 1
+				}
+			}
+		`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		returnedDNSRecord, err := apiClient.UpdateProxySetting("A", dnsRecordName, true)
+
+		assert.Nil(t, err)
+		assert.True(t, returnedDNSRecord.Proxied)
+		fakeRESTClient.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("PatchesOnlyTheProxiedFieldWhenDisablingProxy", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return([]byte(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "www.example.com", "content": "1.2.3.4", "proxiable": true, "proxied": true, "ttl": 1, "comment": "changed by hand", "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Patch", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		returnedDNSRecord, err := apiClient.UpdateProxySetting("A", "www.example.com", false)
+
+		assert.Nil(t, err)
+		assert.False(t, returnedDNSRecord.Proxied)
+		fakeRESTClient.AssertCalled(t, "Patch", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", mock.MatchedBy(func(params interface{}) bool {
+			payload, _ := json.Marshal(params)
+			return string(payload) == `{"proxied":false}`
+		}), authentication)
+		fakeRESTClient.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestUpdateTTL(t *testing.T) {
+
+	t.Run("UpdatesOnlyTheTTLWhenItDiffers", func(t *testing.T) {
+
+		dnsRecordName := "example.com"
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+					{
+						"id": "023e105f4ecef8ad9ca31a8372d0c353",
+						"name": "example.com"
+					}
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 1,
+					"total_count": 1
+				}
+			}
+		`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=A", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+					{
+						"id": "372e67954025e0ba6aaa6d586b9e0b59",
+						"type": "A",
+						"name": "example.com",
+						"content": "1.2.3.4",
+						"proxiable": true,
+						"proxied": false,
+						"ttl": 1,
+						"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+						"zone_name": "example.com"
+					}
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 1,
+					"total_count": 1
+				}
+			}
+		`), nil)
+		fakeRESTClient.On("Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", mock.MatchedBy(func(params interface{}) bool {
+			dnsRecord, ok := params.(DNSRecord)
+			return ok && dnsRecord.TTL == 300 && dnsRecord.Type == "A" && dnsRecord.Content == "1.2.3.4" && !dnsRecord.Proxied
+		}), authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": {
+					"id": "372e67954025e0ba6aaa6d586b9e0b59",
+					"type": "A",
+					"name": "example.com",
+					"content": "1.2.3.4",
+					"proxiable": true,
+					"proxied": false,
+					"ttl": 300,
+					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"zone_name": "example.com"
+				}
+			}
+		`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		returnedDNSRecord, err := apiClient.UpdateTTL("A", dnsRecordName, 300)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 300, returnedDNSRecord.TTL)
+		assert.Equal(t, "1.2.3.4", returnedDNSRecord.Content)
+		fakeRESTClient.AssertNumberOfCalls(t, "Put", 1)
+	})
+
+	t.Run("DoesNotUpdateWhenTTLIsUnchanged", func(t *testing.T) {
+
+		dnsRecordName := "example.com"
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+					{
+						"id": "023e105f4ecef8ad9ca31a8372d0c353",
+						"name": "example.com"
+					}
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 1,
+					"total_count": 1
+				}
+			}
+		`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=A", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+					{
+						"id": "372e67954025e0ba6aaa6d586b9e0b59",
+						"type": "A",
+						"name": "example.com",
+						"content": "1.2.3.4",
+						"proxiable": true,
+						"proxied": false,
+						"ttl": 300,
+						"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+						"zone_name": "example.com"
+					}
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 1,
+					"total_count": 1
+				}
+			}
+		`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		returnedDNSRecord, err := apiClient.UpdateTTL("A", dnsRecordName, 300)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 300, returnedDNSRecord.TTL)
+		fakeRESTClient.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("DoesNotUpdateProxiedRecordSinceItsTTLIsAlwaysAutomatic", func(t *testing.T) {
+
+		dnsRecordName := "example.com"
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+					{
+						"id": "023e105f4ecef8ad9ca31a8372d0c353",
+						"name": "example.com"
+					}
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 1,
+					"total_count": 1
+				}
+			}
+		`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=A", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+					{
+						"id": "372e67954025e0ba6aaa6d586b9e0b59",
+						"type": "A",
+						"name": "example.com",
+						"content": "1.2.3.4",
+						"proxiable": true,
+						"proxied": true,
+						"ttl": 1,
+						"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+						"zone_name": "example.com"
+					}
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 1,
+					"total_count": 1
+				}
+			}
+		`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		_, err := apiClient.UpdateTTL("A", dnsRecordName, 300)
+
+		assert.Nil(t, err)
+		fakeRESTClient.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestGetZoneSSLSetting(t *testing.T) {
+
+	t.Run("ReturnsSSLModeOfZone", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+		zone := Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353", Name: "example.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/settings/ssl", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": {
+					"id": "ssl",
+					"value": "flexible",
+					"editable": true,
+					"modified_on": "2014-01-01T05:20:00.12345Z"
+				}
+			}
+		`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		sslMode, err := apiClient.GetZoneSSLSetting(zone)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "flexible", sslMode)
+	})
+
+	t.Run("ReturnsErrorWhenRequestIsUnsuccessful", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+		zone := Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353", Name: "example.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/settings/ssl", authentication).Return([]byte(`{"success": false, "errors": [{"code": 1003, "message": "Invalid or missing zone id."}], "messages": [], "result": null}`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		_, err := apiClient.GetZoneSSLSetting(zone)
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestUpdateZoneSSLSetting(t *testing.T) {
+
+	t.Run("PatchesSSLSettingOfZoneWithMode", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+		zone := Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353", Name: "example.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Patch", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/settings/ssl", ZoneSetting{Value: "strict"}, authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": {
+					"id": "ssl",
+					"value": "strict",
+					"editable": true,
+					"modified_on": "2014-01-01T05:20:00.12345Z"
+				}
+			}
+		`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		setting, err := apiClient.UpdateZoneSSLSetting(zone, "strict")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "strict", setting.Value)
+		fakeRESTClient.AssertNumberOfCalls(t, "Patch", 1)
+	})
+
+	t.Run("ReturnsErrorWhenRequestIsUnsuccessful", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+		zone := Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353", Name: "example.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Patch", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/settings/ssl", mock.Anything, authentication).Return([]byte(`{"success": false, "errors": [{"code": 1007, "message": "Invalid value for zone setting ssl"}], "messages": [], "result": null}`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		_, err := apiClient.UpdateZoneSSLSetting(zone, "strict")
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestUpdateZoneSetting(t *testing.T) {
+
+	t.Run("PatchesNamedSettingOfZoneWithValue", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+		zone := Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353", Name: "example.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Patch", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/settings/browser_cache_ttl", ZoneSetting{Value: 14400}, authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": {
+					"id": "browser_cache_ttl",
+					"value": 14400,
+					"editable": true,
+					"modified_on": "2014-01-01T05:20:00.12345Z"
+				}
+			}
+		`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		setting, err := apiClient.UpdateZoneSetting(zone, "browser_cache_ttl", 14400)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "browser_cache_ttl", setting.ID)
+		assert.Equal(t, float64(14400), setting.Value)
+		fakeRESTClient.AssertNumberOfCalls(t, "Patch", 1)
+	})
+
+	t.Run("ReturnsErrorWhenRequestIsUnsuccessful", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+		zone := Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353", Name: "example.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Patch", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/settings/always_use_https", mock.Anything, authentication).Return([]byte(`{"success": false, "errors": [{"code": 1007, "message": "Invalid value for zone setting always_use_https"}], "messages": [], "result": null}`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		_, err := apiClient.UpdateZoneSetting(zone, "always_use_https", "maybe")
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestUpsertSRVRecord(t *testing.T) {
+
+	emptyZonesResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+			],
+			"result_info": {
+				"page": 1,
+				"per_page": 20,
+				"count": 0,
+				"total_count": 0
+			}
+		}
+	`)
+	zonesResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{
+					"id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"name": "example.com",
+					"status": "active",
+					"paused": false,
+					"type": "full"
+				}
+			],
+			"result_info": {
+				"page": 1,
+				"per_page": 20,
+				"count": 1,
+				"total_count": 1
+			}
+		}
+	`)
+
+	t.Run("ReturnsCreatedDnsRecordIfDnsRecordDoesNotExist", func(t *testing.T) {
+
+		dnsRecordName := "_sip._tcp.example.com"
+		srvRecordData := SRVRecordData{Priority: 10, Weight: 5, Port: 5060, Target: "sip.example.com"}
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=_sip._tcp.example.com", authentication).Return(emptyZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=_tcp.example.com", authentication).Return(emptyZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=_sip._tcp.example.com&type=SRV", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 0,
+					"total_count": 0
+				}
+			}
+		`), nil)
+
+		newDNSRecord := DNSRecord{Type: "SRV", Name: dnsRecordName, Data: srvRecordData, TTL: 1}
+
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", newDNSRecord, authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": {
+					"id": "372e67954025e0ba6aaa6d586b9e0b59",
+					"type": "SRV",
+					"name": "_sip._tcp.example.com",
+					"content": "5 5060 sip.example.com",
+					"priority": 10,
+					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"zone_name": "example.com",
+					"data": {
+						"priority": 10,
+						"weight": 5,
+						"port": 5060,
+						"target": "sip.example.com"
+					}
+				}
+			}
+		`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		dnsRecords, err := apiClient.UpsertStructuredDNSRecords("SRV", dnsRecordName, []interface{}{srvRecordData}, "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(dnsRecords))
+		assert.Equal(t, "372e67954025e0ba6aaa6d586b9e0b59", dnsRecords[0].ID)
+		assert.Equal(t, "SRV", dnsRecords[0].Type)
+	})
+
+	t.Run("DoesNotUpdateIfDataIsUnchanged", func(t *testing.T) {
+
+		dnsRecordName := "_sip._tcp.example.com"
+		srvRecordData := SRVRecordData{Priority: 10, Weight: 5, Port: 5060, Target: "sip.example.com"}
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=_sip._tcp.example.com", authentication).Return(emptyZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=_tcp.example.com", authentication).Return(emptyZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=_sip._tcp.example.com&type=SRV", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+					{
+						"id": "372e67954025e0ba6aaa6d586b9e0b59",
+						"type": "SRV",
+						"name": "_sip._tcp.example.com",
+						"content": "5 5060 sip.example.com",
+						"priority": 10,
+						"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+						"zone_name": "example.com",
+						"data": {
+							"priority": 10,
+							"weight": 5,
+							"port": 5060,
+							"target": "sip.example.com"
+						}
+					}
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 1,
+					"total_count": 1
+				}
+			}
+		`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		dnsRecords, err := apiClient.UpsertStructuredDNSRecords("SRV", dnsRecordName, []interface{}{srvRecordData}, "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(dnsRecords))
+		assert.Equal(t, "372e67954025e0ba6aaa6d586b9e0b59", dnsRecords[0].ID)
+		fakeRESTClient.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("ReconcilesMultipleDnsRecordsWithTheSameNameAsASet", func(t *testing.T) {
+
+		dnsRecordName := "_sip._tcp.example.com"
+		srvRecordsData := []interface{}{SRVRecordData{Priority: 10, Weight: 5, Port: 5060, Target: "sip1.example.com"}, SRVRecordData{Priority: 20, Weight: 5, Port: 5060, Target: "sip2.example.com"}}
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=_sip._tcp.example.com", authentication).Return(emptyZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=_tcp.example.com", authentication).Return(emptyZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=_sip._tcp.example.com&type=SRV", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+					{
+						"id": "372e67954025e0ba6aaa6d586b9e0b59",
+						"type": "SRV",
+						"name": "_sip._tcp.example.com",
+						"content": "5 5060 sip1.example.com",
+						"priority": 10,
+						"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+						"zone_name": "example.com",
+						"data": {
+							"priority": 10,
+							"weight": 5,
+							"port": 5060,
+							"target": "sip1.example.com"
+						}
+					},
+					{
+						"id": "9a7806061c88ada191ed06f989cc3dac",
+						"type": "SRV",
+						"name": "_sip._tcp.example.com",
+						"content": "5 5060 sip3.example.com",
+						"priority": 30,
+						"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+						"zone_name": "example.com",
+						"data": {
+							"priority": 30,
+							"weight": 5,
+							"port": 5060,
+							"target": "sip3.example.com"
+						}
+					},
+					{
+						"id": "e4d4c5e3b8a1f0c2d9e7b6a5f4c3d2e1",
+						"type": "SRV",
+						"name": "_sip._tcp.example.com",
+						"content": "5 5060 sip4.example.com",
+						"priority": 40,
+						"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+						"zone_name": "example.com",
+						"data": {
+							"priority": 40,
+							"weight": 5,
+							"port": 5060,
+							"target": "sip4.example.com"
+						}
+					}
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 3,
+					"total_count": 3
+				}
+			}
+		`), nil)
+		fakeRESTClient.On("Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/9a7806061c88ada191ed06f989cc3dac", mock.MatchedBy(func(r DNSRecord) bool { return r.Content == "" && r.Data == srvRecordsData[1] }), authentication).Return([]byte(`{"success": true, "result": {"id": "9a7806061c88ada191ed06f989cc3dac", "type": "SRV", "name": "_sip._tcp.example.com"}}`), nil)
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/e4d4c5e3b8a1f0c2d9e7b6a5f4c3d2e1", authentication).Return([]byte(`{"success": true, "result": {"id": "e4d4c5e3b8a1f0c2d9e7b6a5f4c3d2e1"}}`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		dnsRecords, err := apiClient.UpsertStructuredDNSRecords("SRV", dnsRecordName, srvRecordsData, "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(dnsRecords))
+		fakeRESTClient.AssertNumberOfCalls(t, "Put", 1)
+		fakeRESTClient.AssertNumberOfCalls(t, "Delete", 1)
+		fakeRESTClient.AssertNotCalled(t, "Post", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("DeleteStructuredDNSRecordIfMatchingDeletesTheDnsRecordWithMatchingDataWhenMultipleShareTheName", func(t *testing.T) {
+
+		dnsRecordName := "_sip._tcp.example.com"
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=_sip._tcp.example.com", authentication).Return(emptyZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=_tcp.example.com", authentication).Return(emptyZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=_sip._tcp.example.com&type=SRV", authentication).Return([]byte(`
+			{
+				"success": true,
+				"errors": [],
+				"messages": [],
+				"result": [
+					{
+						"id": "372e67954025e0ba6aaa6d586b9e0b59",
+						"type": "SRV",
+						"name": "_sip._tcp.example.com",
+						"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+						"data": {
+							"priority": 10,
+							"weight": 5,
+							"port": 5060,
+							"target": "sip1.example.com"
+						}
+					},
+					{
+						"id": "9a7806061c88ada191ed06f989cc3dac",
+						"type": "SRV",
+						"name": "_sip._tcp.example.com",
+						"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+						"data": {
+							"priority": 20,
+							"weight": 5,
+							"port": 5060,
+							"target": "sip2.example.com"
+						}
+					}
+				],
+				"result_info": {
+					"page": 1,
+					"per_page": 20,
+					"count": 2,
+					"total_count": 2
+				}
+			}
+		`), nil)
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/9a7806061c88ada191ed06f989cc3dac", authentication).Return([]byte(`{"success": true, "result": {"id": "9a7806061c88ada191ed06f989cc3dac"}}`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		deleted, err := apiClient.DeleteStructuredDNSRecordIfMatching("SRV", dnsRecordName, SRVRecordData{Priority: 20, Weight: 5, Port: 5060, Target: "sip2.example.com"})
+
+		assert.Nil(t, err)
+		assert.True(t, deleted)
+		fakeRESTClient.AssertNumberOfCalls(t, "Delete", 1)
+	})
+}
+
+func TestUpsertLOCRecord(t *testing.T) {
+
+	zonesResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{
+					"id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"name": "example.com",
+					"status": "active",
+					"paused": false,
+					"type": "full"
+				}
+			],
+			"result_info": {
+				"page": 1,
+				"per_page": 20,
+				"count": 1,
+				"total_count": 1
+			}
+		}
+	`)
+	locDNSRecordsResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{
+					"id": "372e67954025e0ba6aaa6d586b9e0b59",
+					"type": "LOC",
+					"name": "example.com",
+					"content": "52 22 23.000 N 4 53 32.000 E -2.00 0.00 10000.00 10.00",
+					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"zone_name": "example.com",
+					"data": {
+						"lat_degrees": 52,
+						"lat_minutes": 22,
+						"lat_seconds": 23,
+						"lat_direction": "N",
+						"long_degrees": 4,
+						"long_minutes": 53,
+						"long_seconds": 32,
+						"long_direction": "E",
+						"altitude": -2,
+						"size": 0,
+						"precision_horz": 10000,
+						"precision_vert": 10
+					}
+				}
+			],
+			"result_info": {
+				"page": 1,
+				"per_page": 20,
+				"count": 1,
+				"total_count": 1
+			}
+		}
+	`)
+	successResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": {
+				"id": "372e67954025e0ba6aaa6d586b9e0b59",
+				"type": "LOC",
+				"name": "example.com",
+				"content": "52 22 23.000 N 4 53 32.000 E 10.00 0.00 10000.00 10.00",
+				"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+				"zone_name": "example.com"
+			}
+		}
+	`)
+	authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+	t.Run("CreatesDnsRecordWithStructuredDataIfDnsRecordDoesNotExist", func(t *testing.T) {
+
+		locRecordData := LOCRecordData{LatDegrees: 52, LatMinutes: 22, LatSeconds: 23, LatDirection: "N", LongDegrees: 4, LongMinutes: 53, LongSeconds: 32, LongDirection: "E", Altitude: -2, PrecisionHorz: 10000, PrecisionVert: 10}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=LOC", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"count": 0}}`), nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return(successResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		dnsRecords, err := apiClient.UpsertStructuredDNSRecords("LOC", "example.com", []interface{}{locRecordData}, "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(dnsRecords))
+		assert.Equal(t, "LOC", dnsRecords[0].Type)
+		fakeRESTClient.AssertCalled(t, "Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", DNSRecord{Type: "LOC", Name: "example.com", Data: locRecordData, TTL: 1}, authentication)
+	})
+
+	t.Run("MarshalsStructuredDataIntoTheLocPayloadFields", func(t *testing.T) {
+
+		locRecordData := LOCRecordData{LatDegrees: 52, LatMinutes: 22, LatSeconds: 23.5, LatDirection: "N", LongDegrees: 4, LongMinutes: 53, LongSeconds: 32, LongDirection: "E", Altitude: -2, Size: 1, PrecisionHorz: 10000, PrecisionVert: 10}
+
+		// act
+		payload, err := json.Marshal(DNSRecord{Type: "LOC", Name: "example.com", Data: locRecordData})
+
+		assert.Nil(t, err)
+		assert.Contains(t, string(payload), `"data":{"lat_degrees":52,"lat_minutes":22,"lat_seconds":23.5,"lat_direction":"N","long_degrees":4,"long_minutes":53,"long_seconds":32,"long_direction":"E","altitude":-2,"size":1,"precision_horz":10000,"precision_vert":10}`)
+	})
+
+	t.Run("DoesNotUpdateIfStructuredDataIsUnchanged", func(t *testing.T) {
+
+		locRecordData := LOCRecordData{LatDegrees: 52, LatMinutes: 22, LatSeconds: 23, LatDirection: "N", LongDegrees: 4, LongMinutes: 53, LongSeconds: 32, LongDirection: "E", Altitude: -2, PrecisionHorz: 10000, PrecisionVert: 10}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=LOC", authentication).Return(locDNSRecordsResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		dnsRecords, err := apiClient.UpsertStructuredDNSRecords("LOC", "example.com", []interface{}{locRecordData}, "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(dnsRecords))
+		assert.Equal(t, "372e67954025e0ba6aaa6d586b9e0b59", dnsRecords[0].ID)
+		fakeRESTClient.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("UpdatesDnsRecordWithStructuredDataIfStructuredDataChanged", func(t *testing.T) {
+
+		locRecordData := LOCRecordData{LatDegrees: 52, LatMinutes: 22, LatSeconds: 23, LatDirection: "N", LongDegrees: 4, LongMinutes: 53, LongSeconds: 32, LongDirection: "E", Altitude: 10, PrecisionHorz: 10000, PrecisionVert: 10}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=LOC", authentication).Return(locDNSRecordsResult, nil)
+		fakeRESTClient.On("Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", mock.MatchedBy(func(r DNSRecord) bool { return r.Content == "" && r.Data == locRecordData }), authentication).Return(successResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		_, err := apiClient.UpsertStructuredDNSRecords("LOC", "example.com", []interface{}{locRecordData}, "")
+
+		assert.Nil(t, err)
+		fakeRESTClient.AssertNumberOfCalls(t, "Put", 1)
+	})
+
+	t.Run("CreatesMissingDnsRecordAndKeepsExistingOneIfMultipleShareTheName", func(t *testing.T) {
+
+		existingLOCRecordData := LOCRecordData{LatDegrees: 52, LatMinutes: 22, LatSeconds: 23, LatDirection: "N", LongDegrees: 4, LongMinutes: 53, LongSeconds: 32, LongDirection: "E", Altitude: -2, PrecisionHorz: 10000, PrecisionVert: 10}
+		missingLOCRecordData := LOCRecordData{LatDegrees: 51, LatMinutes: 30, LatSeconds: 0, LatDirection: "N", LongDegrees: 0, LongMinutes: 7, LongSeconds: 0, LongDirection: "W", PrecisionHorz: 10000, PrecisionVert: 10}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=LOC", authentication).Return(locDNSRecordsResult, nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return(successResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		dnsRecords, err := apiClient.UpsertStructuredDNSRecords("LOC", "example.com", []interface{}{existingLOCRecordData, missingLOCRecordData}, "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(dnsRecords))
+		fakeRESTClient.AssertCalled(t, "Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", DNSRecord{Type: "LOC", Name: "example.com", Data: missingLOCRecordData, TTL: 1}, authentication)
+		fakeRESTClient.AssertNumberOfCalls(t, "Post", 1)
+		fakeRESTClient.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything)
+		fakeRESTClient.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("DeleteStructuredDNSRecordIfMatchingReturnsErrorIfStructuredDataDoesNotMatch", func(t *testing.T) {
+
+		locRecordData := LOCRecordData{LatDegrees: 51, LatMinutes: 30, LatSeconds: 0, LatDirection: "N", LongDegrees: 0, LongMinutes: 7, LongSeconds: 0, LongDirection: "W", PrecisionHorz: 10000, PrecisionVert: 10}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=LOC", authentication).Return(locDNSRecordsResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		deleted, err := apiClient.DeleteStructuredDNSRecordIfMatching("LOC", "example.com", locRecordData)
+
+		assert.NotNil(t, err)
+		assert.False(t, deleted)
+		fakeRESTClient.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+}
+
+func TestOwnershipMarker(t *testing.T) {
+
+	zonesResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{
+					"id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"name": "example.com",
+					"status": "active",
+					"paused": false,
+					"type": "full"
+				}
+			],
+			"result_info": {
+				"page": 1,
+				"per_page": 20,
+				"count": 1,
+				"total_count": 1
+			}
+		}
+	`)
+	ownedDNSRecordsResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{
+					"id": "372e67954025e0ba6aaa6d586b9e0b59",
+					"type": "A",
+					"name": "example.com",
+					"content": "1.2.3.4",
+					"comment": "managed by estafette-cloudflare-dns",
+					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"zone_name": "example.com"
+				}
+			],
+			"result_info": {
+				"page": 1,
+				"per_page": 20,
+				"count": 1,
+				"total_count": 1
+			}
+		}
+	`)
+	unownedDNSRecordsResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{
+					"id": "372e67954025e0ba6aaa6d586b9e0b59",
+					"type": "A",
+					"name": "example.com",
+					"content": "1.2.3.4",
+					"comment": "created by hand",
+					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"zone_name": "example.com"
+				}
+			],
+			"result_info": {
+				"page": 1,
+				"per_page": 20,
+				"count": 1,
+				"total_count": 1
+			}
+		}
+	`)
+	successResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": {
+				"id": "372e67954025e0ba6aaa6d586b9e0b59",
+				"type": "A",
+				"name": "example.com",
+				"content": "5.6.7.8",
+				"comment": "managed by estafette-cloudflare-dns",
+				"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+				"zone_name": "example.com"
+			}
+		}
+	`)
+	authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+	t.Run("UpsertDNSRecordUpdatesOwnedRecord", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=A", authentication).Return(ownedDNSRecordsResult, nil)
+		fakeRESTClient.On("Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", mock.Anything, authentication).Return(successResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+		apiClient.ownershipMarker = "managed by estafette-cloudflare-dns"
+
+		// act
+		dnsRecord, _, err := apiClient.UpsertDNSRecord("A", "example.com", "5.6.7.8", false, "", "", 0)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "5.6.7.8", dnsRecord.Content)
+		fakeRESTClient.AssertCalled(t, "Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", mock.Anything, authentication)
+	})
+
+	t.Run("UpsertDNSRecordSkipsUnownedRecord", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=A", authentication).Return(unownedDNSRecordsResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+		apiClient.ownershipMarker = "managed by estafette-cloudflare-dns"
+
+		// act
+		dnsRecord, _, err := apiClient.UpsertDNSRecord("A", "example.com", "5.6.7.8", false, "", "", 0)
+
+		assert.True(t, errors.Is(err, errDNSRecordNotOwned))
+		assert.Equal(t, "1.2.3.4", dnsRecord.Content)
+		fakeRESTClient.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything)
+		fakeRESTClient.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("DeleteDNSRecordIfMatchingDeletesOwnedRecord", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=A", authentication).Return(ownedDNSRecordsResult, nil)
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return(successResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+		apiClient.ownershipMarker = "managed by estafette-cloudflare-dns"
+
+		// act
+		deleted, err := apiClient.DeleteDNSRecordIfMatching("example.com", "A", "1.2.3.4")
+
+		assert.Nil(t, err)
+		assert.True(t, deleted)
+	})
+
+	t.Run("DeleteDNSRecordIfMatchingReturnsErrorForUnownedRecord", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=A", authentication).Return(unownedDNSRecordsResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+		apiClient.ownershipMarker = "managed by estafette-cloudflare-dns"
+
+		// act
+		deleted, err := apiClient.DeleteDNSRecordIfMatching("example.com", "A", "1.2.3.4")
+
+		assert.Equal(t, errDNSRecordNotOwned, err)
+		assert.False(t, deleted)
+		fakeRESTClient.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+}
+
+func TestDeleteAllMatchingDNSRecords(t *testing.T) {
+
+	zonesResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{
+					"id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"name": "example.com",
+					"status": "active",
+					"paused": false,
+					"type": "full"
+				}
+			],
+			"result_info": {
+				"page": 1,
+				"per_page": 20,
+				"count": 1,
+				"total_count": 1
+			}
+		}
+	`)
+	dnsRecordsResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{
+					"id": "372e67954025e0ba6aaa6d586b9e0b59",
+					"type": "A",
+					"name": "example.com",
+					"content": "1.2.3.4",
+					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"zone_name": "example.com"
+				},
+				{
+					"id": "a8f2c5b1d4e3f6a7b8c9d0e1f2a3b4c5",
+					"type": "A",
+					"name": "example.com",
+					"content": "1.2.3.4",
+					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"zone_name": "example.com"
+				},
+				{
+					"id": "0d1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a",
+					"type": "AAAA",
+					"name": "example.com",
+					"content": "2001:db8::1",
+					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"zone_name": "example.com"
+				}
+			],
+			"result_info": {
+				"page": 1,
+				"per_page": 20,
+				"count": 3,
+				"total_count": 3
+			}
+		}
+	`)
+	deleteResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": {
+				"id": "372e67954025e0ba6aaa6d586b9e0b59"
+			}
+		}
+	`)
+	authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+	t.Run("DeleteDNSRecordIfMatchingDeletesAllRecordsWithMatchingTypeAndContent", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=A", authentication).Return(dnsRecordsResult, nil)
+		fakeRESTClient.On("Delete", mock.Anything, authentication).Return(deleteResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		deleted, err := apiClient.DeleteDNSRecordIfMatching("example.com", "A", "1.2.3.4")
+
+		assert.Nil(t, err)
+		assert.True(t, deleted)
+		fakeRESTClient.AssertNumberOfCalls(t, "Delete", 2)
+		fakeRESTClient.AssertCalled(t, "Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication)
+		fakeRESTClient.AssertCalled(t, "Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/a8f2c5b1d4e3f6a7b8c9d0e1f2a3b4c5", authentication)
+	})
+
+	t.Run("DeleteDNSRecordIfMatchingReturnsErrorIfNoRecordMatches", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=A", authentication).Return(dnsRecordsResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		deleted, err := apiClient.DeleteDNSRecordIfMatching("example.com", "A", "5.6.7.8")
+
+		assert.NotNil(t, err)
+		assert.False(t, deleted)
+		fakeRESTClient.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("DeleteDNSRecordDeletesAllRecords", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com", authentication).Return(dnsRecordsResult, nil)
+		fakeRESTClient.On("Delete", mock.Anything, authentication).Return(deleteResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		deleted, err := apiClient.DeleteDNSRecord("example.com")
+
+		assert.Nil(t, err)
+		assert.True(t, deleted)
+		fakeRESTClient.AssertNumberOfCalls(t, "Delete", 3)
+	})
+}
+
+func TestUpsertDNSRecordSet(t *testing.T) {
+
+	zonesResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{
+					"id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"name": "example.com",
+					"status": "active",
+					"paused": false,
+					"type": "full"
+				}
+			],
+			"result_info": {
+				"page": 1,
+				"per_page": 20,
+				"count": 1,
+				"total_count": 1
+			}
+		}
+	`)
+	dnsRecordsResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{
+					"id": "372e67954025e0ba6aaa6d586b9e0b59",
+					"type": "A",
+					"name": "www.example.com",
+					"content": "1.2.3.4",
+					"proxiable": true,
+					"proxied": false,
+					"ttl": 1,
+					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353"
+				},
+				{
+					"id": "a8f2c5b1d4e3f6a7b8c9d0e1f2a3b4c5",
+					"type": "A",
+					"name": "www.example.com",
+					"content": "9.9.9.9",
+					"proxiable": true,
+					"proxied": false,
+					"ttl": 1,
+					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353"
+				}
+			],
+			"result_info": {
+				"page": 1,
+				"per_page": 20,
+				"count": 2,
+				"total_count": 2
+			}
+		}
+	`)
+	createResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": {
+				"id": "0d1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a",
+				"type": "A",
+				"name": "www.example.com",
+				"content": "5.6.7.8",
+				"proxiable": true,
+				"proxied": false,
+				"ttl": 1,
+				"zone_id": "023e105f4ecef8ad9ca31a8372d0c353"
+			}
+		}
+	`)
+	deleteResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": {
+				"id": "a8f2c5b1d4e3f6a7b8c9d0e1f2a3b4c5"
+			}
+		}
+	`)
+	authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+	t.Run("KeepsMatchingRecordCreatesMissingOneAndDeletesRemovedOne", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(dnsRecordsResult, nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.MatchedBy(func(dnsRecord DNSRecord) bool { return dnsRecord.Content == "5.6.7.8" }), authentication).Return(createResult, nil)
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/a8f2c5b1d4e3f6a7b8c9d0e1f2a3b4c5", authentication).Return(deleteResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		dnsRecords, _, err := apiClient.UpsertDNSRecordSet("A", "www.example.com", []string{"1.2.3.4", "5.6.7.8"}, false, "", "", 0)
+
+		assert.Nil(t, err)
+		if assert.Equal(t, 2, len(dnsRecords)) {
+			assert.Equal(t, "1.2.3.4", dnsRecords[0].Content)
+			assert.Equal(t, "5.6.7.8", dnsRecords[1].Content)
+			assert.Equal(t, "example.com", dnsRecords[1].ZoneName)
+		}
+		fakeRESTClient.AssertNumberOfCalls(t, "Post", 1)
+		fakeRESTClient.AssertNumberOfCalls(t, "Delete", 1)
+		fakeRESTClient.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("DeletesRecordsOfAnotherAddressType", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(dnsRecordsResult, nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return([]byte(`{"success": true, "result": {"id": "0d1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a", "type": "AAAA", "ttl": 1}}`), nil)
+		fakeRESTClient.On("Delete", mock.Anything, authentication).Return(deleteResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		_, _, err := apiClient.UpsertDNSRecordSet("AAAA", "www.example.com", []string{"2001:db8::1", "2001:db8::2"}, false, "", "", 0)
+
+		assert.Nil(t, err)
+		fakeRESTClient.AssertNumberOfCalls(t, "Delete", 2)
+		fakeRESTClient.AssertNumberOfCalls(t, "Post", 2)
+	})
+}
+
+func TestGetZones(t *testing.T) {
+
+	authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+	t.Run("ReturnsZonesOfAllPages", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?page=1&per_page=50", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com"}], "result_info": {"page": 1, "per_page": 50, "count": 1, "total_count": 51}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?page=2&per_page=50", authentication).Return([]byte(`{"success": true, "result": [{"id": "a8f2c5b1d4e3f6a7b8c9d0e1f2a3b4c5", "name": "example.org"}], "result_info": {"page": 2, "per_page": 50, "count": 1, "total_count": 51}}`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		zones, err := apiClient.GetZones()
+
+		assert.Nil(t, err)
+		if assert.Equal(t, 2, len(zones)) {
+			assert.Equal(t, "example.com", zones[0].Name)
+			assert.Equal(t, "example.org", zones[1].Name)
+		}
+	})
+
+	t.Run("LeavesOutZonesThatAreNotAllowed", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?page=1&per_page=50", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com"}, {"id": "a8f2c5b1d4e3f6a7b8c9d0e1f2a3b4c5", "name": "example.org"}], "result_info": {"page": 1, "per_page": 50, "count": 2, "total_count": 2}}`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+		apiClient.allowedZones = []string{"example.org"}
+
+		// act
+		zones, err := apiClient.GetZones()
+
+		assert.Nil(t, err)
+		if assert.Equal(t, 1, len(zones)) {
+			assert.Equal(t, "example.org", zones[0].Name)
+		}
+	})
+
+	t.Run("ReturnsConfiguredZoneWithoutListing", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+		apiClient.zone = &Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353", Name: "example.com"}
+
+		// act
+		zones, err := apiClient.GetZones()
+
+		assert.Nil(t, err)
+		assert.Equal(t, []Zone{{ID: "023e105f4ecef8ad9ca31a8372d0c353", Name: "example.com"}}, zones)
+		fakeRESTClient.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+	})
+}
+
+func TestGetOwnedDNSRecords(t *testing.T) {
+
+	authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+	zone := Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353", Name: "example.com"}
+
+	t.Run("ReturnsOnlyRecordsWithOwnershipMarker", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?comment.contains=managed+by+estafette-cloudflare-dns&page=1&per_page=50", authentication).Return([]byte(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "www.example.com", "content": "1.2.3.4", "comment": "managed by estafette-cloudflare-dns"}, {"id": "a8f2c5b1d4e3f6a7b8c9d0e1f2a3b4c5", "type": "A", "name": "other.example.com", "content": "1.2.3.4", "comment": "created by hand"}], "result_info": {"page": 1, "per_page": 50, "count": 2, "total_count": 2}}`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+		apiClient.ownershipMarker = defaultCloudflareComment
+
+		// act
+		dnsRecords, err := apiClient.GetOwnedDNSRecords(zone)
+
+		assert.Nil(t, err)
+		if assert.Equal(t, 1, len(dnsRecords)) {
+			assert.Equal(t, "www.example.com", dnsRecords[0].Name)
+			assert.Equal(t, "023e105f4ecef8ad9ca31a8372d0c353", dnsRecords[0].ZoneID)
+		}
+	})
+
+	t.Run("ReturnsErrorWithoutOwnershipMarker", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		_, err := apiClient.GetOwnedDNSRecords(zone)
+
+		assert.NotNil(t, err)
+		fakeRESTClient.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+	})
+}
+
+func TestUpsertNSRecords(t *testing.T) {
+
+	zonesResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{
+					"id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"name": "example.com",
+					"status": "active",
+					"paused": false,
+					"type": "full"
+				}
+			],
+			"result_info": {
+				"page": 1,
+				"per_page": 20,
+				"count": 1,
+				"total_count": 1
+			}
+		}
+	`)
+	noZonesResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [],
+			"result_info": {
+				"page": 1,
+				"per_page": 20,
+				"count": 0,
+				"total_count": 0
+			}
+		}
+	`)
+	dnsRecordsResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{
+					"id": "372e67954025e0ba6aaa6d586b9e0b59",
+					"type": "NS",
+					"name": "sub.example.com",
+					"content": "ns1.provider.com",
+					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"zone_name": "example.com"
+				},
+				{
+					"id": "a8f2c5b1d4e3f6a7b8c9d0e1f2a3b4c5",
+					"type": "NS",
+					"name": "sub.example.com",
+					"content": "ns2.provider.com",
+					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"zone_name": "example.com"
+				}
+			],
+			"result_info": {
+				"page": 1,
+				"per_page": 20,
+				"count": 2,
+				"total_count": 2
+			}
+		}
+	`)
+	createResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": {
+				"id": "0d1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a",
+				"type": "NS",
+				"name": "sub.example.com",
+				"content": "ns3.provider.com",
+				"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+				"zone_name": "example.com"
+			}
+		}
+	`)
+	deleteResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": {
+				"id": "a8f2c5b1d4e3f6a7b8c9d0e1f2a3b4c5"
+			}
+		}
+	`)
+	authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+	t.Run("CreatesMissingAndDeletesSuperfluousNSRecords", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=sub.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=sub.example.com", authentication).Return(dnsRecordsResult, nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return(createResult, nil)
+		fakeRESTClient.On("Delete", mock.Anything, authentication).Return(deleteResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		records, err := apiClient.UpsertNSRecords("sub.example.com", []string{"ns1.provider.com", "ns3.provider.com"}, "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(records))
+		fakeRESTClient.AssertNumberOfCalls(t, "Post", 1)
+		fakeRESTClient.AssertNumberOfCalls(t, "Delete", 1)
+		fakeRESTClient.AssertCalled(t, "Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/a8f2c5b1d4e3f6a7b8c9d0e1f2a3b4c5", authentication)
+	})
+
+	t.Run("DoesNothingIfAllNSRecordsExist", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=sub.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=sub.example.com", authentication).Return(dnsRecordsResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		records, err := apiClient.UpsertNSRecords("sub.example.com", []string{"ns1.provider.com", "ns2.provider.com"}, "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(records))
+		fakeRESTClient.AssertNotCalled(t, "Post", mock.Anything, mock.Anything, mock.Anything)
+		fakeRESTClient.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("DeleteNSRecordsDeletesAllNSRecords", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=sub.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=sub.example.com&type=NS", authentication).Return(dnsRecordsResult, nil)
+		fakeRESTClient.On("Delete", mock.Anything, authentication).Return(deleteResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		deleted, err := apiClient.DeleteNSRecords("sub.example.com")
+
+		assert.Nil(t, err)
+		assert.True(t, deleted)
+		fakeRESTClient.AssertNumberOfCalls(t, "Delete", 2)
+	})
+}
+
+func TestUpsertCAARecords(t *testing.T) {
+
+	zonesResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{
+					"id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"name": "example.com"
+				}
+			],
+			"result_info": {
+				"page": 1,
+				"per_page": 20,
+				"count": 1,
+				"total_count": 1
+			}
+		}
+	`)
+	dnsRecordsResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{
+					"id": "372e67954025e0ba6aaa6d586b9e0b59",
+					"type": "A",
+					"name": "example.com",
+					"content": "1.2.3.4",
+					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"zone_name": "example.com"
+				},
+				{
+					"id": "a8f2c5b1d4e3f6a7b8c9d0e1f2a3b4c5",
+					"type": "CAA",
+					"name": "example.com",
+					"content": "0 issue \"digicert.com\"",
+					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"zone_name": "example.com",
+					"data": {
+						"flags": 0,
+						"tag": "issue",
+						"value": "digicert.com"
+					}
+				}
+			],
+			"result_info": {
+				"page": 1,
+				"per_page": 20,
+				"count": 2,
+				"total_count": 2
+			}
+		}
+	`)
+	writeResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": {
+				"id": "0d1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a",
+				"type": "CAA",
+				"name": "example.com",
+				"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+				"zone_name": "example.com"
+			}
+		}
+	`)
+	deleteResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": {
+				"id": "a8f2c5b1d4e3f6a7b8c9d0e1f2a3b4c5"
+			}
+		}
+	`)
+	authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+	t.Run("UpdatesSuperfluousAndCreatesMissingCAARecordsWithStructuredData", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=CAA", authentication).Return(dnsRecordsResult, nil)
+		fakeRESTClient.On("Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/a8f2c5b1d4e3f6a7b8c9d0e1f2a3b4c5", mock.Anything, authentication).Return(writeResult, nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return(writeResult, nil)
 
 		apiClient := New(authentication)
 		apiClient.restClient = fakeRESTClient
 
 		// act
-		returnedDNSRecord, err := apiClient.UpdateProxySetting(dnsRecordName, proxy)
+		records, err := apiClient.UpsertCAARecords("example.com", []CAARecordData{{Flags: 0, Tag: "issue", Value: "letsencrypt.org"}, {Flags: 0, Tag: "iodef", Value: "mailto:security@example.com"}}, "")
 
 		assert.Nil(t, err)
-		assert.Equal(t, true, returnedDNSRecord.Proxied)
+		assert.Equal(t, 2, len(records))
+		fakeRESTClient.AssertCalled(t, "Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/a8f2c5b1d4e3f6a7b8c9d0e1f2a3b4c5", mock.MatchedBy(func(params interface{}) bool {
+			payload, _ := json.Marshal(params)
+			return strings.Contains(string(payload), `"data":{"flags":0,"tag":"issue","value":"letsencrypt.org"}`)
+		}), authentication)
+		fakeRESTClient.AssertCalled(t, "Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", DNSRecord{Type: "CAA", Name: "example.com", Data: CAARecordData{Flags: 0, Tag: "iodef", Value: "mailto:security@example.com"}, TTL: 1}, authentication)
+		fakeRESTClient.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("DeletesSuperfluousCAARecords", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=CAA", authentication).Return(dnsRecordsResult, nil)
+		fakeRESTClient.On("Delete", mock.Anything, authentication).Return(deleteResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		records, err := apiClient.UpsertCAARecords("example.com", []CAARecordData{}, "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(records))
+		fakeRESTClient.AssertNumberOfCalls(t, "Delete", 1)
+		fakeRESTClient.AssertCalled(t, "Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/a8f2c5b1d4e3f6a7b8c9d0e1f2a3b4c5", authentication)
+	})
+
+	t.Run("DoesNothingIfCAARecordDataIsUnchanged", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=CAA", authentication).Return(dnsRecordsResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		records, err := apiClient.UpsertCAARecords("example.com", []CAARecordData{{Flags: 0, Tag: "issue", Value: "digicert.com"}}, "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(records))
+		fakeRESTClient.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything)
+		fakeRESTClient.AssertNotCalled(t, "Post", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("DeleteCAARecordIfMatchingDeletesRecordWithMatchingData", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=CAA", authentication).Return(dnsRecordsResult, nil)
+		fakeRESTClient.On("Delete", mock.Anything, authentication).Return(deleteResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		deleted, err := apiClient.DeleteCAARecordIfMatching("example.com", CAARecordData{Flags: 0, Tag: "issue", Value: "digicert.com"})
+
+		assert.Nil(t, err)
+		assert.True(t, deleted)
+		fakeRESTClient.AssertCalled(t, "Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/a8f2c5b1d4e3f6a7b8c9d0e1f2a3b4c5", authentication)
+	})
+
+	t.Run("DeleteCAARecordIfMatchingReturnsErrorIfDataDoesNotMatch", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=CAA", authentication).Return(dnsRecordsResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		deleted, err := apiClient.DeleteCAARecordIfMatching("example.com", CAARecordData{Flags: 0, Tag: "issue", Value: "letsencrypt.org"})
+
+		assert.NotNil(t, err)
+		assert.False(t, deleted)
+		fakeRESTClient.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+}
+
+func TestDNSRecordsCache(t *testing.T) {
+
+	zone := Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353", Name: "example.com"}
+	dnsRecordsResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": [
+				{
+					"id": "372e67954025e0ba6aaa6d586b9e0b59",
+					"type": "A",
+					"name": "www.example.com",
+					"content": "1.2.3.4",
+					"zone_id": "023e105f4ecef8ad9ca31a8372d0c353",
+					"zone_name": "example.com"
+				}
+			],
+			"result_info": {
+				"page": 1,
+				"per_page": 20,
+				"count": 1,
+				"total_count": 1
+			}
+		}
+	`)
+	deleteResult := []byte(`
+		{
+			"success": true,
+			"errors": [],
+			"messages": [],
+			"result": {
+				"id": "372e67954025e0ba6aaa6d586b9e0b59"
+			}
+		}
+	`)
+	authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+	cachedResult := dNSRecordsResult{Success: true, DNSRecords: []DNSRecord{{ID: "372e67954025e0ba6aaa6d586b9e0b59", Name: "www.example.com", Content: "1.2.3.4"}}}
+
+	t.Run("LooksUpDNSRecordsOnlyOnceWithinTTL", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(dnsRecordsResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+		apiClient.dnsRecordsCache = newDNSRecordsCache(time.Minute)
+
+		// act
+		apiClient.getDNSRecordsByZoneAndName(zone, "www.example.com", "")
+		result, err := apiClient.getDNSRecordsByZoneAndName(zone, "www.example.com", "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "1.2.3.4", result.DNSRecords[0].Content)
+		fakeRESTClient.AssertNumberOfCalls(t, "Get", 1)
+	})
+
+	t.Run("LooksUpDNSRecordsAgainAfterTheyGetModified", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(dnsRecordsResult, nil)
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return(deleteResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+		apiClient.dnsRecordsCache = newDNSRecordsCache(time.Minute)
+
+		result, _ := apiClient.getDNSRecordsByZoneAndName(zone, "www.example.com", "")
+		apiClient.deleteDNSRecordByDNSRecord(result.DNSRecords[0])
+
+		// act
+		_, err := apiClient.getDNSRecordsByZoneAndName(zone, "www.example.com", "")
+
+		assert.Nil(t, err)
+		fakeRESTClient.AssertNumberOfCalls(t, "Get", 2)
+	})
+
+	t.Run("CachesLookupsFilteredOnTypeSeparately", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(dnsRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=TXT", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+		apiClient.dnsRecordsCache = newDNSRecordsCache(time.Minute)
+
+		apiClient.getDNSRecordsByZoneAndName(zone, "www.example.com", "")
+
+		// act
+		result, err := apiClient.getDNSRecordsByZoneAndName(zone, "www.example.com", "TXT")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(result.DNSRecords))
+		fakeRESTClient.AssertNumberOfCalls(t, "Get", 2)
+	})
+
+	t.Run("LooksUpDNSRecordsOfAllTypesAgainAfterTheyGetModified", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(dnsRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(dnsRecordsResult, nil)
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return(deleteResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+		apiClient.dnsRecordsCache = newDNSRecordsCache(time.Minute)
+
+		apiClient.getDNSRecordsByZoneAndName(zone, "www.example.com", "")
+		result, _ := apiClient.getDNSRecordsByZoneAndName(zone, "www.example.com", "A")
+		apiClient.deleteDNSRecordByDNSRecord(result.DNSRecords[0])
+
+		// act
+		apiClient.getDNSRecordsByZoneAndName(zone, "www.example.com", "")
+		_, err := apiClient.getDNSRecordsByZoneAndName(zone, "www.example.com", "A")
+
+		assert.Nil(t, err)
+		fakeRESTClient.AssertNumberOfCalls(t, "Get", 4)
+	})
+
+	t.Run("LooksUpDNSRecordsAgainAfterTTLExpires", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(dnsRecordsResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+		apiClient.dnsRecordsCache = newDNSRecordsCache(time.Millisecond)
+
+		apiClient.getDNSRecordsByZoneAndName(zone, "www.example.com", "")
+		time.Sleep(5 * time.Millisecond)
+
+		// act
+		_, err := apiClient.getDNSRecordsByZoneAndName(zone, "www.example.com", "")
+
+		assert.Nil(t, err)
+		fakeRESTClient.AssertNumberOfCalls(t, "Get", 2)
+	})
+
+	t.Run("LooksUpDNSRecordsEveryTimeWhenDisabled", func(t *testing.T) {
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(dnsRecordsResult, nil)
+
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		apiClient.getDNSRecordsByZoneAndName(zone, "www.example.com", "")
+		_, err := apiClient.getDNSRecordsByZoneAndName(zone, "www.example.com", "")
+
+		assert.Nil(t, err)
+		fakeRESTClient.AssertNumberOfCalls(t, "Get", 2)
+	})
+
+	t.Run("RemovesExpiredLookupWhenGettingIt", func(t *testing.T) {
+
+		cache := newDNSRecordsCache(time.Millisecond)
+		cache.set("023e105f4ecef8ad9ca31a8372d0c353", "www.example.com", "A", cachedResult)
+		time.Sleep(5 * time.Millisecond)
+
+		// act
+		_, ok := cache.get("023e105f4ecef8ad9ca31a8372d0c353", "www.example.com", "A")
+
+		assert.False(t, ok)
+		assert.Equal(t, 0, len(cache.entries))
+	})
+
+	t.Run("KeepsLookupsOfOtherTypesWhenRemovingExpiredLookup", func(t *testing.T) {
+
+		cache := newDNSRecordsCache(time.Minute)
+		cache.set("023e105f4ecef8ad9ca31a8372d0c353", "www.example.com", "A", cachedResult)
+		cache.set("023e105f4ecef8ad9ca31a8372d0c353", "www.example.com", "", cachedResult)
+		key := getDNSRecordsCacheKey("023e105f4ecef8ad9ca31a8372d0c353", "www.example.com")
+		entry := cache.entries[key]["A"]
+		entry.expires = time.Now().Add(-time.Second)
+		cache.entries[key]["A"] = entry
+
+		// act
+		_, ok := cache.get("023e105f4ecef8ad9ca31a8372d0c353", "www.example.com", "A")
+
+		assert.False(t, ok)
+		assert.NotContains(t, cache.entries[key], "A")
+		assert.Contains(t, cache.entries[key], "")
+	})
+
+	t.Run("SweepsExpiredLookupsOfOtherNamesWhenSettingOne", func(t *testing.T) {
+
+		cache := newDNSRecordsCache(time.Millisecond)
+		cache.set("023e105f4ecef8ad9ca31a8372d0c353", "old.example.com", "A", cachedResult)
+		time.Sleep(5 * time.Millisecond)
+
+		// act
+		cache.set("023e105f4ecef8ad9ca31a8372d0c353", "www.example.com", "A", cachedResult)
+
+		assert.Equal(t, 1, len(cache.entries))
+		assert.Contains(t, cache.entries, getDNSRecordsCacheKey("023e105f4ecef8ad9ca31a8372d0c353", "www.example.com"))
+	})
+
+	t.Run("DoesNotSweepMoreThanOncePerTTL", func(t *testing.T) {
+
+		cache := newDNSRecordsCache(time.Minute)
+		cache.set("023e105f4ecef8ad9ca31a8372d0c353", "old.example.com", "A", cachedResult)
+		key := getDNSRecordsCacheKey("023e105f4ecef8ad9ca31a8372d0c353", "old.example.com")
+		entry := cache.entries[key]["A"]
+		entry.expires = time.Now().Add(-time.Second)
+		cache.entries[key]["A"] = entry
+
+		// act
+		cache.set("023e105f4ecef8ad9ca31a8372d0c353", "www.example.com", "A", cachedResult)
+
+		assert.Equal(t, 2, len(cache.entries))
+	})
+}
+
+func TestExportZoneRecords(t *testing.T) {
+
+	t.Run("ReturnsRecordsInBindFormat", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+		zone := Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353", Name: "example.com"}
+		bindData := []byte("example.com.\t1\tIN\tA\t1.2.3.4\nwww.example.com.\t1\tIN\tCNAME\texample.com.\n")
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/export", authentication).Return(bindData, nil)
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		data, err := apiClient.ExportZoneRecords(zone)
+
+		assert.Nil(t, err)
+		assert.Equal(t, bindData, data)
+	})
+
+	t.Run("ReturnsErrorIfExportFails", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+		zone := Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353", Name: "example.com"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/export", authentication).Return([]byte(`{"success": false, "errors": [{"code": 7003, "message": "Could not route to /zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/export, perhaps your object identifier is invalid?"}], "messages": []}`), nil)
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		_, err := apiClient.ExportZoneRecords(zone)
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestImportZoneRecords(t *testing.T) {
+
+	t.Run("UploadsRecordsAndReturnsNumberOfAddedRecords", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+		zone := Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353", Name: "example.com"}
+		bindData := []byte("example.com.\t1\tIN\tA\t1.2.3.4\nwww.example.com.\t1\tIN\tCNAME\texample.com.\n")
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("PostFile", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/import", map[string]string{"proxied": "true"}, "example.com.txt", bindData, authentication).Return([]byte(`{"success": true, "errors": [], "messages": [], "result": {"recs_added": 2, "total_records_parsed": 2}}`), nil)
+		apiClient := New(authentication)
+		apiClient.restClient = fakeRESTClient
+
+		// act
+		r, err := apiClient.ImportZoneRecords(zone, bindData, true)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, r.Result.RecordsAdded)
+		assert.Equal(t, 2, r.Result.TotalRecordsParsed)
 	})
 }
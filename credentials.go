@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+// the keys of the credentials in a secret, the same as in the secret of the helm chart
+const (
+	credentialsSecretEmailKey = "cloudflareApiEmail"
+	credentialsSecretKeyKey   = "cloudflareApiKey"
+)
+
+// errCredentialsSecretNotFound is returned if the secret an object references for its credentials doesn't exist
+var errCredentialsSecretNotFound = errors.New("Credentials secret not found")
+
+// secretLister is used to look up the Cloudflare credentials of objects in secrets; all objects use the global credentials if nil
+var secretLister corelisters.SecretLister
+
+// cloudflareClients holds a client per set of credentials from secrets, so objects with the same credentials share their caches
+var cloudflareClients sync.Map
+
+// initSecretLister starts an informer for the secrets in the watched namespaces, so credentials can be looked up without an api call per reconcile
+func initSecretLister(ctx context.Context, kubeClientset kubernetes.Interface, stopper chan struct{}) error {
+
+	factory := informers.NewSharedInformerFactoryWithOptions(kubeClientset, 0, informers.WithNamespace(*namespace))
+	secretsInformer := factory.Core().V1().Secrets()
+	secretLister = secretsInformer.Lister()
+
+	informer := secretsInformer.Informer()
+	factory.Start(stopper)
+
+	return waitForInformerCacheSync(ctx, "secret", informer, stopper)
+}
+
+// getObjectCloudflareClient returns the client for the credentials in the secret named by the estafette.io/cloudflare-credentials-secret annotation,
+// or else in the default secret of the namespace, or else the client with the global credentials
+func getObjectCloudflareClient(cf *Cloudflare, lister corelisters.SecretLister, annotations map[string]string, kind, name, namespace string) (*Cloudflare, error) {
+
+	if lister == nil {
+		if annotations[annotationCloudflareCredentialsSecret] != "" {
+			log.Warn().Msgf("%v %v.%v - Ignoring annotation %v, because looking up credentials in secrets isn't enabled", kind, name, namespace, annotationCloudflareCredentialsSecret)
+		}
+		return cf, nil
+	}
+
+	// a secret referenced by the object has to exist, to not silently manage its records with other credentials
+	if secretName := annotations[annotationCloudflareCredentialsSecret]; secretName != "" {
+		secret, err := lister.Secrets(namespace).Get(secretName)
+		if k8serrors.IsNotFound(err) {
+			return cf, fmt.Errorf("%w: %v for %v %v.%v", errCredentialsSecretNotFound, secretName, kind, name, namespace)
+		}
+		if err != nil {
+			return cf, fmt.Errorf("Getting credentials secret %v for %v %v.%v failed: %w", secretName, kind, name, namespace, err)
+		}
+		return getCloudflareClientForSecret(cf, secret)
+	}
+
+	if *namespaceCredentialsSecret != "" {
+		secret, err := lister.Secrets(namespace).Get(*namespaceCredentialsSecret)
+		if err == nil {
+			return getCloudflareClientForSecret(cf, secret)
+		}
+		if !k8serrors.IsNotFound(err) {
+			return cf, fmt.Errorf("Getting credentials secret %v for %v %v.%v failed: %w", *namespaceCredentialsSecret, kind, name, namespace, err)
+		}
+	}
+
+	return cf, nil
+}
+
+// getCloudflareClientForSecret returns the cached client for the credentials in the secret, creating it on first use
+func getCloudflareClientForSecret(cf *Cloudflare, secret *v1.Secret) (*Cloudflare, error) {
+
+	authentication, err := getSecretCredentials(secret)
+	if err != nil {
+		return cf, err
+	}
+
+	if client, ok := cloudflareClients.Load(authentication); ok {
+		return client.(*Cloudflare), nil
+	}
+
+	client, _ := cloudflareClients.LoadOrStore(authentication, cf.withAuthentication(authentication))
+
+	return client.(*Cloudflare), nil
+}
+
+func getSecretCredentials(secret *v1.Secret) (r APIAuthentication, err error) {
+
+	r.Email = string(secret.Data[credentialsSecretEmailKey])
+	r.Key = string(secret.Data[credentialsSecretKeyKey])
+	if r.Email == "" || r.Key == "" {
+		return r, fmt.Errorf("Credentials secret %v.%v should have both the %v and %v keys", secret.Name, secret.Namespace, credentialsSecretEmailKey, credentialsSecretKeyKey)
+	}
+
+	return r, nil
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/record"
+)
+
+var dnsRecordsResource = schema.GroupVersionResource{Group: "estafette.io", Version: "v1", Resource: "dnsrecords"}
+
+// dnsRecordResourcesInstalled is set if the DNSRecord custom resource definition is installed in the cluster; DNSRecord objects are left alone if false
+var dnsRecordResourcesInstalled bool
+
+// DNSRecordSpec represents the spec of a DNSRecord custom resource, declaring a single record at Cloudflare.
+type DNSRecordSpec struct {
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	Proxied bool   `json:"proxied,omitempty"`
+	TTL     int    `json:"ttl,omitempty"`
+}
+
+// DNSRecordStatus represents the status of a DNSRecord custom resource, pointing at the record it created at Cloudflare.
+type DNSRecordStatus struct {
+	RecordID           string `json:"recordId,omitempty"`
+	ZoneID             string `json:"zoneId,omitempty"`
+	ZoneName           string `json:"zoneName,omitempty"`
+	Name               string `json:"name,omitempty"`
+	Proxied            bool   `json:"proxied,omitempty"`
+	ObservedGeneration int64  `json:"observedGeneration,omitempty"`
+}
+
+// isDNSRecordResourceInstalled returns true if the api server serves DNSRecord objects, so the controller keeps working without the custom resource definition
+func isDNSRecordResourceInstalled(discoveryClient discovery.DiscoveryInterface) bool {
+
+	resources, err := discoveryClient.ServerResourcesForGroupVersion(dnsRecordsResource.GroupVersion().String())
+	if err != nil {
+		log.Debug().Err(err).Msgf("Looking up resources of %v failed, assuming DNSRecord custom resource definition isn't installed", dnsRecordsResource.GroupVersion())
+		return false
+	}
+
+	for _, resource := range resources.APIResources {
+		if resource.Name == dnsRecordsResource.Resource {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getDNSRecordSpec returns the spec of a DNSRecord object, with its type in uppercase, without trailing dots and unproxied if --disable-proxy is set, or an error if it can't be used as record
+func getDNSRecordSpec(obj *unstructured.Unstructured) (spec DNSRecordSpec, err error) {
+
+	fields, _, err := unstructured.NestedMap(obj.Object, "spec")
+	if err != nil {
+		return spec, fmt.Errorf("Spec is malformed: %w", err)
+	}
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(fields, &spec)
+	if err != nil {
+		return spec, fmt.Errorf("Spec is malformed: %w", err)
+	}
+
+	spec.Type = strings.ToUpper(strings.TrimSpace(spec.Type))
+	spec.Name = strings.TrimSuffix(strings.TrimSpace(spec.Name), ".")
+	spec.Content = strings.TrimSpace(spec.Content)
+	if *disableProxy {
+		spec.Proxied = false
+	}
+
+	if reason := validateHostname(spec.Name); reason != "" {
+		return spec, fmt.Errorf("Name '%v' is not a valid hostname: %v", spec.Name, reason)
+	}
+	if spec.TTL < 0 {
+		return spec, fmt.Errorf("Ttl %v is not a number of seconds or 1 for automatic", spec.TTL)
+	}
+
+	switch spec.Type {
+	case "A", "AAAA":
+		spec.Content, err = normalizeIPAddress(spec.Content)
+		if err != nil {
+			return spec, err
+		}
+		if spec.Type != getTargetDNSRecordType(CloudflareState{IPAddress: spec.Content}) {
+			return spec, fmt.Errorf("Ip address '%v' doesn't match record type %v", spec.Content, spec.Type)
+		}
+	case "CNAME":
+		spec.Content = strings.TrimSuffix(spec.Content, ".")
+		if reason := validateHostname(spec.Content); reason != "" {
+			return spec, fmt.Errorf("Content '%v' is not a valid hostname: %v", spec.Content, reason)
+		}
+	case "TXT":
+		if spec.Content == "" {
+			return spec, errors.New("Content is empty")
+		}
+	default:
+		return spec, fmt.Errorf("Record type '%v' is not supported, expected A, AAAA, CNAME or TXT", spec.Type)
+	}
+
+	return spec, nil
+}
+
+// getDNSRecordStatus returns the status of a DNSRecord object, which is empty until its record has been created
+func getDNSRecordStatus(obj *unstructured.Unstructured) (status DNSRecordStatus) {
+
+	fields, ok, err := unstructured.NestedMap(obj.Object, "status")
+	if err != nil || !ok {
+		return
+	}
+
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(fields, &status)
+	if err != nil {
+		log.Warn().Err(err).Msgf("DNSRecord %v.%v - Status is malformed, ignoring it", obj.GetName(), obj.GetNamespace())
+		return DNSRecordStatus{}
+	}
+
+	return
+}
+
+// updateDNSRecordStatus stores the status in the status subresource of a DNSRecord object
+func updateDNSRecordStatus(ctx context.Context, dynamicClient dynamic.Interface, obj *unstructured.Unstructured, status DNSRecordStatus) error {
+
+	fields, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&status)
+	if err != nil {
+		return err
+	}
+
+	updated := obj.DeepCopy()
+	err = unstructured.SetNestedMap(updated.Object, fields, "status")
+	if err != nil {
+		return err
+	}
+
+	_, err = dynamicClient.Resource(dnsRecordsResource).Namespace(obj.GetNamespace()).UpdateStatus(ctx, updated, metav1.UpdateOptions{})
+
+	return err
+}
+
+func makeDNSRecordChanges(ctx context.Context, cf *Cloudflare, dynamicClient dynamic.Interface, recorder record.EventRecorder, obj *unstructured.Unstructured, initiator string, spec DNSRecordSpec, currentStatus DNSRecordStatus) (status string, changes int, err error) {
+
+	status = "failed"
+
+	// the status is only updated after the record has been upserted, so an unchanged generation and proxy setting means it's still in place
+	if currentStatus.RecordID != "" && currentStatus.Name == spec.Name && currentStatus.Proxied == spec.Proxied && currentStatus.ObservedGeneration == obj.GetGeneration() {
+		status = "skipped"
+		return status, changes, nil
+	}
+
+	// clean up the record by the previous name if the name has changed
+	if currentStatus.Name != "" && currentStatus.Name != spec.Name {
+		deleted, err := deleteDNSRecordResourceRecord(cf, recorder, obj, initiator, currentStatus)
+		changes += deleted
+		if err != nil {
+			return status, changes, err
+		}
+	}
+
+	log.Info().Msgf("[%v] DNSRecord %v.%v - Upserting dns record %v (%v) to value %v...", initiator, obj.GetName(), obj.GetNamespace(), spec.Name, spec.Type, spec.Content)
+
+	dnsRecord, upserted, err := cf.UpsertDNSRecord(spec.Type, spec.Name, spec.Content, spec.Proxied, defaultCloudflareComment, "", spec.TTL)
+	if err != nil {
+		getUpsertFailureLogEvent(err).Msgf("[%v] DNSRecord %v.%v - Upserting dns record %v (%v) to value %v failed", initiator, obj.GetName(), obj.GetNamespace(), spec.Name, spec.Type, spec.Content)
+		recorder.Eventf(obj, v1.EventTypeWarning, "DNSRecordUpsertFailed", "Upserting dns record %v (%v) to value %v failed: %v", spec.Name, spec.Type, spec.Content, err)
+		return status, changes, err
+	}
+	if upserted {
+		recorder.Eventf(obj, v1.EventTypeNormal, "DNSRecordUpserted", "Upserted dns record %v (%v) to value %v", spec.Name, spec.Type, spec.Content)
+		changes++
+	}
+
+	err = updateDNSRecordStatus(ctx, dynamicClient, obj, DNSRecordStatus{
+		RecordID:           dnsRecord.ID,
+		ZoneID:             dnsRecord.ZoneID,
+		ZoneName:           dnsRecord.ZoneName,
+		Name:               spec.Name,
+		Proxied:            spec.Proxied,
+		ObservedGeneration: obj.GetGeneration(),
+	})
+	if err != nil {
+		log.Error().Err(err).Msgf("[%v] DNSRecord %v.%v - Updating dnsrecord status has failed", initiator, obj.GetName(), obj.GetNamespace())
+		return status, changes, err
+	}
+
+	status = "succeeded"
+
+	log.Info().Msgf("[%v] DNSRecord %v.%v - DNSRecord has been updated successfully...", initiator, obj.GetName(), obj.GetNamespace())
+
+	return status, changes, nil
+}
+
+func processDNSRecordResource(ctx context.Context, cf *Cloudflare, dynamicClient dynamic.Interface, recorder record.EventRecorder, obj *unstructured.Unstructured, initiator string) (status string, changes int, err error) {
+
+	status = "failed"
+
+	if obj != nil {
+
+		spec, err := getDNSRecordSpec(obj)
+		if err != nil {
+			log.Error().Err(err).Msgf("[%v] DNSRecord %v.%v - Spec is invalid, skipping it", initiator, obj.GetName(), obj.GetNamespace())
+			recorder.Eventf(obj, v1.EventTypeWarning, "InvalidRecordSpec", "Spec is invalid: %v", err)
+			return status, changes, err
+		}
+
+		status, changes, err = makeDNSRecordChanges(ctx, cf, dynamicClient, recorder, obj, initiator, spec, getDNSRecordStatus(obj))
+		status, err = handleZoneMissing("DNSRecord", obj.GetName(), obj.GetNamespace(), status, err)
+
+		return status, changes, err
+	}
+
+	return status, changes, nil
+}
+
+// deleteDNSRecordResourceRecord deletes the record a DNSRecord object created, by the name and zone in its status
+func deleteDNSRecordResourceRecord(cf *Cloudflare, recorder record.EventRecorder, obj *unstructured.Unstructured, initiator string, currentStatus DNSRecordStatus) (changes int, err error) {
+
+	zone := Zone{ID: currentStatus.ZoneID, Name: currentStatus.ZoneName}
+	if zone.ID == "" {
+		zone, err = cf.GetZoneByDNSName(currentStatus.Name)
+		if err != nil {
+			log.Warn().Err(err).Msgf("[%v] DNSRecord %v.%v - Failed looking up zone of dns record %v...", initiator, obj.GetName(), obj.GetNamespace(), currentStatus.Name)
+			return changes, err
+		}
+	}
+
+	log.Info().Msgf("[%v] DNSRecord %v.%v - Deleting dns record %v...", initiator, obj.GetName(), obj.GetNamespace(), currentStatus.Name)
+
+	_, err = cf.deleteDNSRecordByZone(zone, currentStatus.Name)
+	if errors.Is(err, errDNSRecordNotFound) {
+		// it's gone already, which is what we're after
+		return changes, nil
+	}
+	if err != nil {
+		log.Warn().Err(err).Msgf("[%v] DNSRecord %v.%v - Failed deleting dns record %v...", initiator, obj.GetName(), obj.GetNamespace(), currentStatus.Name)
+		recorder.Eventf(obj, v1.EventTypeWarning, "DNSRecordDeleteFailed", "Deleting dns record %v failed: %v", currentStatus.Name, err)
+		if countDeleteFailure("DNSRecord", obj.GetNamespace(), err) {
+			return changes, err
+		}
+		return changes, nil
+	}
+	recorder.Eventf(obj, v1.EventTypeNormal, "DNSRecordDeleted", "Deleted dns record %v", currentStatus.Name)
+	changes++
+
+	return changes, nil
+}
+
+func deleteDNSRecordResource(ctx context.Context, cf *Cloudflare, recorder record.EventRecorder, obj *unstructured.Unstructured, initiator string) (status string, changes int, err error) {
+
+	status = "failed"
+
+	if obj != nil {
+
+		// without a record name in the status the record never got created
+		currentStatus := getDNSRecordStatus(obj)
+		if currentStatus.Name == "" {
+			status = "skipped"
+			return status, changes, nil
+		}
+
+		changes, err = deleteDNSRecordResourceRecord(cf, recorder, obj, initiator, currentStatus)
+		if err != nil {
+			return status, changes, err
+		}
+
+		status = "deleted"
+	}
+
+	return status, changes, nil
+}
+
+func watchDNSRecords(ctx context.Context, cf *Cloudflare, dynamicClient dynamic.Interface, recorder record.EventRecorder, factory dynamicinformer.DynamicSharedInformerFactory, waitGroup *sync.WaitGroup, stopper chan struct{}) error {
+	dnsRecordsInformer := factory.ForResource(dnsRecordsResource).Informer()
+
+	dnsRecordsQueue := newObjectQueue("dnsrecord", dnsRecordsInformer.GetIndexer(),
+		func(obj interface{}) (string, int, error) {
+			dnsRecord, ok := obj.(*unstructured.Unstructured)
+			if !ok {
+				return "failed", 0, errors.New("Watcher for dnsrecords returns event object of incorrect type")
+			}
+			return processDNSRecordResource(ctx, cf, dynamicClient, recorder, dnsRecord, "watcher")
+		},
+		func(obj interface{}) (string, int, error) {
+			dnsRecord, ok := obj.(*unstructured.Unstructured)
+			if !ok {
+				return "failed", 0, errors.New("Watcher for dnsrecords returns event object of incorrect type")
+			}
+			return deleteDNSRecordResource(ctx, cf, recorder, dnsRecord, "watcher:deleted")
+		},
+	)
+
+	dnsRecordsInformer.AddEventHandler(dnsRecordsQueue.handlers())
+
+	watchInformerHealth("dnsrecords", dnsRecordsInformer, stopper)
+
+	go dnsRecordsInformer.Run(stopper)
+
+	if err := waitForInformerCacheSync(ctx, "dnsrecords", dnsRecordsInformer, stopper); err != nil {
+		return err
+	}
+
+	dnsRecordsQueue.run(*watcherConcurrency, waitGroup, stopper)
+
+	return nil
+}
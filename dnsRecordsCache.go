@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// dnsRecordsCache holds the results of dns record lookups for a short time to reduce the number of calls to the cloudflare api.
+type dnsRecordsCache struct {
+	ttl     time.Duration
+	mutex   sync.Mutex
+	entries map[string]map[string]dnsRecordsCacheEntry
+
+	// the time after which set sweeps expired entries again, so names that never get looked up again don't pile up
+	nextSweep time.Time
+}
+
+type dnsRecordsCacheEntry struct {
+	result  dNSRecordsResult
+	expires time.Time
+}
+
+func newDNSRecordsCache(ttl time.Duration) *dnsRecordsCache {
+	return &dnsRecordsCache{
+		ttl:     ttl,
+		entries: map[string]map[string]dnsRecordsCacheEntry{},
+	}
+}
+
+func getDNSRecordsCacheKey(zoneID, dnsRecordName string) string {
+	return zoneID + "/" + strings.ToLower(dnsRecordName)
+}
+
+// get returns the cached lookup result for the name in the zone filtered on the type, or unfiltered if it's empty, if it hasn't expired yet.
+func (c *dnsRecordsCache) get(zoneID, dnsRecordName, dnsRecordType string) (r dNSRecordsResult, ok bool) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := getDNSRecordsCacheKey(zoneID, dnsRecordName)
+	entry, ok := c.entries[key][dnsRecordType]
+	if !ok {
+		return r, false
+	}
+	if time.Now().After(entry.expires) {
+		c.delete(key, dnsRecordType)
+		return r, false
+	}
+
+	// copy the records so callers can't modify the cached ones
+	r = entry.result
+	r.DNSRecords = append([]DNSRecord{}, entry.result.DNSRecords...)
+
+	return r, true
+}
+
+func (c *dnsRecordsCache) set(zoneID, dnsRecordName, dnsRecordType string, r dNSRecordsResult) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	if now.After(c.nextSweep) {
+		c.sweep(now)
+		c.nextSweep = now.Add(c.ttl)
+	}
+
+	key := getDNSRecordsCacheKey(zoneID, dnsRecordName)
+	if c.entries[key] == nil {
+		c.entries[key] = map[string]dnsRecordsCacheEntry{}
+	}
+	c.entries[key][dnsRecordType] = dnsRecordsCacheEntry{
+		result:  r,
+		expires: now.Add(c.ttl),
+	}
+}
+
+// sweep removes all expired lookup results; the caller holds the mutex.
+func (c *dnsRecordsCache) sweep(now time.Time) {
+
+	for key, entries := range c.entries {
+		for dnsRecordType, entry := range entries {
+			if now.After(entry.expires) {
+				c.delete(key, dnsRecordType)
+			}
+		}
+	}
+}
+
+// delete removes the lookup result for the type from the cache, together with the name once it has no results left; the caller holds the mutex.
+func (c *dnsRecordsCache) delete(key, dnsRecordType string) {
+
+	delete(c.entries[key], dnsRecordType)
+	if len(c.entries[key]) == 0 {
+		delete(c.entries, key)
+	}
+}
+
+// invalidate removes the cached lookup results of all types for the name in the zone, so the next lookup after a mutation hits the api again.
+func (c *dnsRecordsCache) invalidate(zoneID, dnsRecordName string) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.entries, getDNSRecordsCacheKey(zoneID, dnsRecordName))
+}
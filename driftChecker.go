@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// initDriftChecker periodically compares the actual records at Cloudflare with the desired state of all objects, since the stored state doesn't reflect changes made outside of the controller
+func initDriftChecker(ctx context.Context, cf *Cloudflare, kubeClientset kubernetes.Interface, dynamicClient dynamic.Interface, interval time.Duration) {
+	go func() {
+		for {
+			// the poller already reconciles all objects at startup, so only check after the first interval
+			time.Sleep(interval)
+
+			checkDrift(ctx, cf, kubeClientset, dynamicClient)
+		}
+	}()
+}
+
+// checkDrift requests a forced update for all objects whose records at Cloudflare no longer match their desired state
+func checkDrift(ctx context.Context, cf *Cloudflare, kubeClientset kubernetes.Interface, dynamicClient dynamic.Interface) {
+
+	log.Info().Msg("Checking records at Cloudflare for drift...")
+
+	services, err := kubeClientset.CoreV1().Services(*namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Error().Err(err).Msg("ListServices call for drift check failed")
+	} else {
+		for i := range services.Items {
+			service := &services.Items[i]
+			if driftedHostnames := getDriftedHostnames(cf, getDesiredServiceState(service)); len(driftedHostnames) > 0 {
+				requestForceUpdate("Service", service.Name, service.Namespace, driftedHostnames, func(patch []byte) error {
+					_, err := kubeClientset.CoreV1().Services(service.Namespace).Patch(ctx, service.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+					return err
+				})
+			}
+		}
+	}
+
+	ingresses, err := kubeClientset.NetworkingV1().Ingresses(*namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Error().Err(err).Msg("ListIngresses call for drift check failed")
+	} else {
+		for i := range ingresses.Items {
+			ingress := &ingresses.Items[i]
+			if !isIngressClassMatching(ingress, *ingressClass) {
+				continue
+			}
+			if driftedHostnames := getDriftedHostnames(cf, getDesiredIngressState(ingress)); len(driftedHostnames) > 0 {
+				requestForceUpdate("Ingress", ingress.Name, ingress.Namespace, driftedHostnames, func(patch []byte) error {
+					_, err := kubeClientset.NetworkingV1().Ingresses(ingress.Namespace).Patch(ctx, ingress.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+					return err
+				})
+			}
+		}
+	}
+
+	if *enableHTTPRoutes {
+		routes, err := dynamicClient.Resource(httpRoutesResource).Namespace(*namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			log.Error().Err(err).Msg("ListHTTPRoutes call for drift check failed")
+		} else {
+			for i := range routes.Items {
+				route := &routes.Items[i]
+				if driftedHostnames := getDriftedHostnames(cf, getDesiredHTTPRouteState(ctx, dynamicClient, route)); len(driftedHostnames) > 0 {
+					requestForceUpdate("HTTPRoute", route.GetName(), route.GetNamespace(), driftedHostnames, func(patch []byte) error {
+						_, err := dynamicClient.Resource(httpRoutesResource).Namespace(route.GetNamespace()).Patch(ctx, route.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+						return err
+					})
+				}
+			}
+		}
+	}
+}
+
+// requestForceUpdate sets the force update annotation on the object, so the watchers upsert its records again regardless of the stored state
+func requestForceUpdate(kind, name, namespace string, driftedHostnames []string, patchObject func(patch []byte) error) {
+
+	log.Info().Msgf("%v %v.%v - Records for %v drifted from the desired state, requesting a forced update...", kind, name, namespace, strings.Join(driftedHostnames, ","))
+	driftTotals.WithLabelValues(namespace, strings.ToLower(kind)).Inc()
+
+	patch, err := getAnnotationPatch(annotationCloudflareForceUpdate, "true")
+	if err != nil {
+		log.Error().Err(err).Msgf("%v %v.%v - Marshalling force update annotation failed", kind, name, namespace)
+		return
+	}
+
+	err = patchObject(patch)
+	if err != nil {
+		log.Error().Err(err).Msgf("%v %v.%v - Setting force update annotation has failed", kind, name, namespace)
+	}
+}
+
+// getDriftedHostnames returns the hostnames in the state whose records at Cloudflare don't match it
+func getDriftedHostnames(cf *Cloudflare, state CloudflareState) (driftedHostnames []string) {
+
+	if state.Enabled != "true" {
+		return
+	}
+
+	if len(state.Hostnames) > 0 && (state.IPAddress != "" || state.CNAMETarget != "") {
+		if state.CNAMETarget == "" && state.UseOriginRecord == "true" && state.OriginRecordHostname != "" {
+			if isDNSRecordDrifted(cf, state.OriginRecordHostname, getTargetDNSRecordType(state), state.IPAddress, "false") {
+				driftedHostnames = append(driftedHostnames, state.OriginRecordHostname)
+			}
+		}
+
+		dnsRecordType, dnsRecordContent := getHostnameDNSRecord(state)
+		for _, hostname := range splitHostnames(state.Hostnames) {
+			if validateHostname(hostname) != "" {
+				continue
+			}
+			if dnsRecordType != "CNAME" && state.AdditionalIPAddresses != "" {
+				if isDNSRecordSetDrifted(cf, hostname, dnsRecordType, getStateIPAddresses(state), state.Proxy) {
+					driftedHostnames = append(driftedHostnames, hostname)
+				}
+				continue
+			}
+			if isDNSRecordDrifted(cf, hostname, dnsRecordType, dnsRecordContent, state.Proxy) {
+				driftedHostnames = append(driftedHostnames, hostname)
+			}
+		}
+	}
+
+	if len(state.InternalHostnames) > 0 && state.InternalIPAddress != "" && state.InternalDNS != "false" {
+		for _, internalHostname := range splitHostnames(state.InternalHostnames) {
+			if validateHostname(internalHostname) != "" {
+				continue
+			}
+			if isDNSRecordDrifted(cf, internalHostname, "A", state.InternalIPAddress, "false") {
+				driftedHostnames = append(driftedHostnames, internalHostname)
+			}
+		}
+	}
+
+	return
+}
+
+// isDNSRecordDrifted returns true if the record is missing at Cloudflare or differs in type, content or proxy setting; with automatic proxying it should be proxied whenever it can be
+func isDNSRecordDrifted(cf *Cloudflare, dnsRecordName, dnsRecordType, dnsRecordContent, proxy string) bool {
+
+	r, err := cf.GetDNSRecordByDNSName(dnsRecordName)
+	if errors.Is(err, errDNSRecordNotFound) {
+		return true
+	}
+	if err != nil {
+		getUpsertFailureLogEvent(err).Msgf("Retrieving dns record %v for drift check failed, skipping it", dnsRecordName)
+		return false
+	}
+
+	// the controller leaves records created by others alone, so don't report those over and over
+	if !isOwnedDNSRecord(r, cf.ownershipMarker) {
+		return false
+	}
+
+	if r.Type != dnsRecordType || !strings.EqualFold(strings.TrimSuffix(r.Content, "."), strings.TrimSuffix(dnsRecordContent, ".")) {
+		return true
+	}
+
+	if proxy == "auto" {
+		return r.Proxiable && !r.Proxied
+	}
+
+	return r.Proxiable && r.Proxied != (proxy == "true")
+}
+
+// isDNSRecordSetDrifted returns true if the name lacks a record for any of the ip addresses, or if one of its records differs like isDNSRecordDrifted checks
+func isDNSRecordSetDrifted(cf *Cloudflare, dnsRecordName, dnsRecordType string, ipAddresses []string, proxy string) bool {
+
+	dnsRecords, err := cf.GetDNSRecordsByDNSName(dnsRecordName)
+	if errors.Is(err, errDNSRecordNotFound) {
+		return true
+	}
+	if err != nil {
+		getUpsertFailureLogEvent(err).Msgf("Retrieving dns records %v for drift check failed, skipping them", dnsRecordName)
+		return false
+	}
+
+	missingIPAddresses := map[string]bool{}
+	for _, ipAddress := range ipAddresses {
+		missingIPAddresses[ipAddress] = true
+	}
+
+	for _, r := range dnsRecords {
+		if r.Type != dnsRecordType {
+			continue
+		}
+		delete(missingIPAddresses, r.Content)
+
+		// the controller leaves records created by others alone, so don't report those over and over
+		if !isOwnedDNSRecord(r, cf.ownershipMarker) {
+			continue
+		}
+
+		if proxy == "auto" && r.Proxiable && !r.Proxied {
+			return true
+		}
+		if proxy != "auto" && r.Proxiable && r.Proxied != (proxy == "true") {
+			return true
+		}
+	}
+
+	return len(missingIPAddresses) > 0
+}
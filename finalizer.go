@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// finalizerCloudflareDNS keeps a managed object from being deleted until the controller has deleted its dns records, so they don't get orphaned if the controller misses the delete event
+const finalizerCloudflareDNS string = "estafette.io/cloudflare-dns"
+
+// finalizedObjects holds the objects whose records got deleted while handling their finalizer, so the delete event that follows doesn't try to delete them again
+var finalizedObjects sync.Map
+
+func getFinalizedObjectKey(kind, namespace, name string) string {
+	return fmt.Sprintf("%v/%v/%v", kind, namespace, name)
+}
+
+// wasFinalized returns true once for an object whose records got deleted while handling its finalizer
+func wasFinalized(kind, namespace, name string) bool {
+	_, ok := finalizedObjects.LoadAndDelete(getFinalizedObjectKey(kind, namespace, name))
+	return ok
+}
+
+func hasFinalizer(obj metav1.Object) bool {
+	for _, finalizer := range obj.GetFinalizers() {
+		if finalizer == finalizerCloudflareDNS {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getFinalizerPatch returns a json patch that adds the finalizer, or removes it if add is false; the removal tests the finalizer is still at the same index, so it fails instead of removing another finalizer if they changed in the meantime
+func getFinalizerPatch(obj metav1.Object, add bool) ([]byte, error) {
+
+	finalizers := obj.GetFinalizers()
+
+	if add {
+		if len(finalizers) == 0 {
+			return json.Marshal([]map[string]interface{}{
+				{"op": "add", "path": "/metadata/finalizers", "value": []string{finalizerCloudflareDNS}},
+			})
+		}
+		return json.Marshal([]map[string]interface{}{
+			{"op": "add", "path": "/metadata/finalizers/-", "value": finalizerCloudflareDNS},
+		})
+	}
+
+	for i, finalizer := range finalizers {
+		if finalizer == finalizerCloudflareDNS {
+			path := fmt.Sprintf("/metadata/finalizers/%v", i)
+			return json.Marshal([]map[string]interface{}{
+				{"op": "test", "path": path, "value": finalizerCloudflareDNS},
+				{"op": "remove", "path": path},
+			})
+		}
+	}
+
+	return nil, fmt.Errorf("Finalizer %v isn't present", finalizerCloudflareDNS)
+}
+
+// updateServiceFinalizer adds the finalizer to a service with managed records and removes it from one without
+func updateServiceFinalizer(ctx context.Context, kubeClientset kubernetes.Interface, service *v1.Service, managed bool) error {
+
+	if hasFinalizer(service) == managed {
+		return nil
+	}
+
+	patch, err := getFinalizerPatch(service, managed)
+	if err != nil {
+		return err
+	}
+
+	_, err = kubeClientset.CoreV1().Services(service.Namespace).Patch(ctx, service.Name, types.JSONPatchType, patch, metav1.PatchOptions{})
+
+	return err
+}
+
+// updateIngressFinalizer adds the finalizer to an ingress with managed records and removes it from one without
+func updateIngressFinalizer(ctx context.Context, kubeClientset kubernetes.Interface, ingress *networkingv1.Ingress, managed bool) error {
+
+	if hasFinalizer(ingress) == managed {
+		return nil
+	}
+
+	patch, err := getFinalizerPatch(ingress, managed)
+	if err != nil {
+		return err
+	}
+
+	_, err = kubeClientset.NetworkingV1().Ingresses(ingress.Namespace).Patch(ctx, ingress.Name, types.JSONPatchType, patch, metav1.PatchOptions{})
+
+	return err
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// collectOrphanedRecords deletes the records carrying the ownership marker whose name isn't used by any existing service, ingress, httproute or dnsrecord, like the ones of objects deleted while the controller was down
+func collectOrphanedRecords(ctx context.Context, cf *Cloudflare, kubeClientset kubernetes.Interface, dynamicClient dynamic.Interface) (deleted int, err error) {
+
+	log.Info().Msg("Collecting orphaned records at Cloudflare...")
+
+	zones, err := cf.GetZones()
+	if err != nil {
+		log.Error().Err(err).Msg("Listing zones for collecting orphaned records failed, skipping it")
+		return
+	}
+
+	ownedDNSRecords := []DNSRecord{}
+	for _, zone := range zones {
+		dnsRecords, err := cf.GetOwnedDNSRecords(zone)
+		if err != nil {
+			log.Error().Err(err).Msgf("Listing owned records in zone %v failed, skipping it", zone.Name)
+			continue
+		}
+		for _, dnsRecord := range dnsRecords {
+			if dnsRecord.ZoneName == "" {
+				dnsRecord.ZoneName = zone.Name
+			}
+			ownedDNSRecords = append(ownedDNSRecords, dnsRecord)
+		}
+	}
+
+	// list the objects after the records, so the records of an object created in the meantime aren't mistaken for orphans; every record would look orphaned if the objects can't all be listed, so bail out instead
+	claimedNames, err := getClaimedRecordNames(ctx, kubeClientset, dynamicClient)
+	if err != nil {
+		log.Error().Err(err).Msg("Listing objects for collecting orphaned records failed, skipping it")
+		return
+	}
+
+	for _, dnsRecord := range ownedDNSRecords {
+		if claimedNames[getClaimedRecordName(dnsRecord.Name)] {
+			continue
+		}
+
+		log.Info().Msgf("Deleting orphaned dns record %v (%v) with content %v in zone %v...", dnsRecord.Name, dnsRecord.Type, dnsRecord.Content, dnsRecord.ZoneName)
+		err := cf.DeleteOwnedDNSRecord(dnsRecord)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Deleting orphaned dns record %v (%v) with content %v in zone %v failed", dnsRecord.Name, dnsRecord.Type, dnsRecord.Content, dnsRecord.ZoneName)
+			continue
+		}
+
+		orphanedRecordsTotals.With(prometheus.Labels{"zone": dnsRecord.ZoneName}).Inc()
+		deleted++
+	}
+
+	log.Info().Msgf("Deleted %v orphaned record(s)", deleted)
+
+	return deleted, nil
+}
+
+// getClaimedRecordNames returns the names of all records the existing objects have or want, from both their desired and stored state, regardless of whether they're enabled or of the ingress class
+func getClaimedRecordNames(ctx context.Context, kubeClientset kubernetes.Interface, dynamicClient dynamic.Interface) (claimedNames map[string]bool, err error) {
+
+	claimedNames = map[string]bool{}
+
+	services, err := kubeClientset.CoreV1().Services(*namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range services.Items {
+		service := &services.Items[i]
+		addClaimedRecordNames(claimedNames, getDesiredServiceState(service))
+		addClaimedRecordNames(claimedNames, getCurrentServiceState(ctx, service))
+	}
+
+	ingresses, err := kubeClientset.NetworkingV1().Ingresses(*namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range ingresses.Items {
+		ingress := &ingresses.Items[i]
+		addClaimedRecordNames(claimedNames, getDesiredIngressState(ingress))
+		addClaimedRecordNames(claimedNames, getCurrentIngressState(ctx, ingress))
+	}
+
+	if *enableHTTPRoutes {
+		routes, err := dynamicClient.Resource(httpRoutesResource).Namespace(*namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for i := range routes.Items {
+			route := &routes.Items[i]
+			addClaimedRecordNames(claimedNames, getDesiredHTTPRouteState(ctx, dynamicClient, route))
+			addClaimedRecordNames(claimedNames, getCurrentHTTPRouteState(ctx, route))
+		}
+	}
+
+	if dnsRecordResourcesInstalled {
+		dnsRecords, err := dynamicClient.Resource(dnsRecordsResource).Namespace(*namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for i := range dnsRecords.Items {
+			dnsRecord := &dnsRecords.Items[i]
+			spec, _ := getDNSRecordSpec(dnsRecord)
+			for _, name := range []string{spec.Name, getDNSRecordStatus(dnsRecord).Name} {
+				if name != "" {
+					claimedNames[getClaimedRecordName(name)] = true
+				}
+			}
+		}
+	}
+
+	return claimedNames, nil
+}
+
+// addClaimedRecordNames adds the names of all records of a state, including the srv, caa, loc and ns records
+func addClaimedRecordNames(claimedNames map[string]bool, state CloudflareState) {
+
+	names := splitHostnames(state.Hostnames)
+	names = append(names, splitHostnames(state.InternalHostnames)...)
+	names = append(names, state.OriginRecordHostname)
+	for _, r := range state.Records {
+		names = append(names, r.Name)
+	}
+
+	structuredRecords, _ := getStructuredRecords(state)
+	for _, r := range structuredRecords {
+		names = append(names, r.Name)
+	}
+	caaRecords, _ := parseCAARecords(state.CAARecords)
+	for _, r := range caaRecords {
+		names = append(names, r.Name)
+	}
+	nsRecords, _ := parseNSRecords(state.NSRecords)
+	for _, r := range nsRecords {
+		names = append(names, r.Name)
+	}
+
+	for _, name := range names {
+		if name != "" {
+			claimedNames[getClaimedRecordName(name)] = true
+		}
+	}
+}
+
+// getClaimedRecordName returns the name the way cloudflare returns it, in lowercase punycode without a trailing dot
+func getClaimedRecordName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(toASCIIHostname(strings.TrimSpace(name)), "."))
+}
@@ -1,10 +1,54 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/idna"
 )
 
+var errZoneNotFound = errors.New("cloudflare: no matching zone has been found")
+
+var errZoneNotAllowed = errors.New("cloudflare: zone isn't in the allowed zones")
+
+var errZonePaused = errors.New("cloudflare: zone is paused")
+
+var errDNSRecordNotFound = errors.New("No matching dns record has been found")
+
+var errDNSRecordNotOwned = errors.New("cloudflare: dns record lacks the ownership marker in its comment")
+
+var errDNSRecordNotMatching = errors.New("Type or content does not match")
+
+// isRetryableDeleteError returns true if deleting a dns record failed in a way that retrying can fix; records that are gone already or belong to someone else never get deleted by retrying
+func isRetryableDeleteError(err error) bool {
+	return err != nil &&
+		!errors.Is(err, errDNSRecordNotFound) &&
+		!errors.Is(err, errDNSRecordNotOwned) &&
+		!errors.Is(err, errDNSRecordNotMatching) &&
+		!errors.Is(err, errZoneNotFound) &&
+		!errors.Is(err, errZoneNotAllowed) &&
+		!errors.Is(err, errZonePaused)
+}
+
+// isDNSRecordLeftBehind returns true if deleting a dns record failed without retrying helping, but the record managed by the controller is still at cloudflare, like for zones that are paused or not allowed
+func isDNSRecordLeftBehind(err error) bool {
+	return err != nil &&
+		!errors.Is(err, errDNSRecordNotFound) &&
+		!errors.Is(err, errDNSRecordNotOwned) &&
+		!errors.Is(err, errDNSRecordNotMatching) &&
+		!errors.Is(err, errZoneNotFound)
+}
+
 func getLastItemsFromSlice(source []string, numberOfItems int) (r []string, err error) {
 
 	if len(source) == 0 {
@@ -29,13 +73,339 @@ func getMatchingZoneFromZones(zones []Zone, zoneName string) (r Zone, err error)
 		return
 	}
 
+	matchingZones := []Zone{}
 	for _, zone := range zones {
 		if zone.Name == zoneName {
-			r = zone
-			return
+			matchingZones = append(matchingZones, zone)
+		}
+	}
+
+	if len(matchingZones) == 0 {
+		err = errors.New("cloudflare: no zone matches name")
+		return
+	}
+
+	r = matchingZones[0]
+	if len(matchingZones) > 1 {
+		// prefer active full zones over paused, pending or partial (cname setup) zones with the same name
+		for _, zone := range matchingZones[1:] {
+			if zone.preference() > r.preference() {
+				r = zone
+			}
+		}
+		log.Warn().Msgf("Found %v zones with name %v, using zone %v with status %v and type %v", len(matchingZones), zoneName, r.ID, r.Status, r.Type)
+	}
+
+	return
+}
+
+// isDNSNameInZone returns true if the dns name equals the zone name or is a subdomain of it
+func isDNSNameInZone(dnsName, zoneName string) bool {
+	dnsName = strings.ToLower(strings.TrimSuffix(dnsName, "."))
+	zoneName = strings.ToLower(strings.TrimSuffix(zoneName, "."))
+
+	return dnsName == zoneName || strings.HasSuffix(dnsName, "."+zoneName)
+}
+
+// isAddressDNSRecordType returns true for the record types a hostname points at its target with, of which a name can only have one
+func isAddressDNSRecordType(dnsRecordType string) bool {
+	return dnsRecordType == "A" || dnsRecordType == "AAAA" || dnsRecordType == "CNAME"
+}
+
+// isZonePlanAllowed returns true if the zone is on one of the comma-separated plans, by legacy id like free or pro or by name like Free Website; a zone without plan info is allowed, to not unproxy records because of a response lacking it
+func isZonePlanAllowed(zone Zone, plans string) bool {
+
+	if zone.Plan.LegacyID == "" && zone.Plan.Name == "" {
+		return true
+	}
+
+	for _, plan := range strings.Split(plans, ",") {
+		plan = strings.TrimSpace(plan)
+		if plan != "" && (strings.EqualFold(plan, zone.Plan.LegacyID) || strings.EqualFold(plan, zone.Plan.Name)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getZonePlanName returns the legacy id of the plan of the zone, or its name if it has none
+func getZonePlanName(zone Zone) string {
+	if zone.Plan.LegacyID != "" {
+		return zone.Plan.LegacyID
+	}
+	return zone.Plan.Name
+}
+
+// isDNSRecordUpToDate returns true if updating the existing record wouldn't change it; enabling proxying is left to UpdateProxySetting, so only a proxied record that shouldn't be counts as a difference
+func isDNSRecordUpToDate(r DNSRecord, dnsRecordContent string, proxy bool, ttl int, dnsRecordComment, dnsRecordRegion string) bool {
+
+	if !strings.EqualFold(strings.TrimSuffix(r.Content, "."), strings.TrimSuffix(dnsRecordContent, ".")) {
+		return false
+	}
+	if r.Proxied && !proxy {
+		return false
+	}
+	if r.TTL != ttl {
+		return false
+	}
+	if r.Region != dnsRecordRegion {
+		return false
+	}
+
+	return dnsRecordComment == "" || r.Comment == dnsRecordComment
+}
+
+// automaticTTL is the ttl cloudflare uses for records with an automatic ttl
+const automaticTTL = 1
+
+func getTTLForProxySetting(dnsRecordName string, ttl int, proxy bool) int {
+
+	// cloudflare forces the ttl of proxied records to automatic
+	if proxy && ttl != automaticTTL {
+		if ttl > automaticTTL {
+			log.Info().Msgf("Overriding ttl %v for dns record %v with automatic ttl, because it is proxied", ttl, dnsRecordName)
 		}
+		return automaticTTL
+	}
+
+	// records without a ttl get the automatic ttl at cloudflare, so set it explicitly to match what cloudflare returns
+	if ttl == 0 {
+		return automaticTTL
+	}
+
+	return ttl
+}
+
+// isDNSRecordDataMatching returns true if the structured data of a record, which is decoded as a generic map, equals the desired data once round-tripped through json into its type
+func isDNSRecordDataMatching(data, desiredData interface{}) bool {
+
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return false
+	}
+
+	currentData := reflect.New(reflect.TypeOf(desiredData))
+	if err := json.Unmarshal(bytes, currentData.Interface()); err != nil {
+		return false
+	}
+
+	return currentData.Elem().Interface() == desiredData
+}
+
+func getCAARecordData(data interface{}) (r CAARecordData, err error) {
+
+	// data is decoded as a generic map, so round-trip it through json to get the structured caa data
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return
 	}
 
-	err = errors.New("cloudflare: no zone matches name")
+	err = json.Unmarshal(bytes, &r)
 	return
 }
+
+// observeDNSRecordAge observes the time since a record was last modified in the estafette_cloudflare_dns_record_age_seconds metric, skipping records without a modification time
+func observeDNSRecordAge(dnsRecord DNSRecord, zoneName string, now time.Time) {
+
+	if dnsRecord.ModifiedOn.IsZero() {
+		return
+	}
+
+	age := now.Sub(dnsRecord.ModifiedOn)
+	if age < 0 {
+		age = 0
+	}
+
+	dnsRecordAgeSeconds.With(prometheus.Labels{"zone": zoneName}).Observe(age.Seconds())
+}
+
+func isOwnedDNSRecord(dnsRecord DNSRecord, ownershipMarker string) bool {
+
+	// without a marker every record is considered owned
+	if ownershipMarker == "" {
+		return true
+	}
+
+	return strings.Contains(dnsRecord.Comment, ownershipMarker)
+}
+
+func addOwnershipMarker(comment, ownershipMarker string) string {
+
+	if ownershipMarker == "" || strings.Contains(comment, ownershipMarker) {
+		return comment
+	}
+	if comment == "" {
+		return ownershipMarker
+	}
+
+	return comment + "; " + ownershipMarker
+}
+
+func containsCAARecordData(caaRecordsData []CAARecordData, caaRecordData CAARecordData) bool {
+	for _, d := range caaRecordsData {
+		if d == caaRecordData {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getMissingDNSRecordDataIndex returns the index of the first desired structured data that matches the data and isn't matched by another record yet, or -1 if there's none
+func getMissingDNSRecordDataIndex(dnsRecordsData []interface{}, matched []bool, data interface{}) int {
+	for i, d := range dnsRecordsData {
+		if !matched[i] && isDNSRecordDataMatching(data, d) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// authenticationErrorCodes are the codes cloudflare returns along with a 401 or 403 status for missing, unknown or revoked credentials
+var authenticationErrorCodes = map[int]bool{
+	6003:  true, // invalid request headers
+	6103:  true, // invalid format for X-Auth-Key header
+	9103:  true, // unknown X-Auth-Key or X-Auth-Email
+	10000: true, // authentication error
+}
+
+// isAuthenticationFailure returns true if the response body says the credentials have been rejected
+func isAuthenticationFailure(body []byte, err error) bool {
+
+	if err != nil || len(body) == 0 {
+		return false
+	}
+
+	var r apiResult
+	if json.Unmarshal(body, &r) != nil || r.Success {
+		return false
+	}
+
+	for _, e := range r.Errors {
+		if authenticationErrorCodes[e.Code] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// appendZoneName adds a zone name to the list of zones records have been upserted in, unless it's unknown or listed already
+func appendZoneName(zoneNames []string, zoneName string) []string {
+
+	if zoneName == "" {
+		return zoneNames
+	}
+	for _, z := range zoneNames {
+		if z == zoneName {
+			return zoneNames
+		}
+	}
+
+	return append(zoneNames, zoneName)
+}
+
+// idnaProfile converts internationalized hostnames like cloudflare does, while still allowing wildcard and underscore labels
+var idnaProfile = idna.New(idna.MapForLookup(), idna.StrictDomainName(false), idna.Transitional(false))
+
+// toASCIIHostname returns the punycode form of an internationalized hostname; ascii hostnames and ones that can't be converted are returned as is
+func toASCIIHostname(hostname string) string {
+
+	for _, c := range hostname {
+		if c > unicode.MaxASCII {
+			asciiHostname, err := idnaProfile.ToASCII(hostname)
+			if err != nil {
+				log.Warn().Err(err).Msgf("Converting hostname %v to punycode failed", hostname)
+				return hostname
+			}
+			return asciiHostname
+		}
+	}
+
+	return hostname
+}
+
+// getUserAgent returns the User-Agent header to send to cloudflare, made up of the app name and version unless overridden
+func getUserAgent(override, app, version string) string {
+
+	if override != "" {
+		return override
+	}
+	if app == "" {
+		app = "estafette-cloudflare-dns"
+	}
+	if version == "" {
+		return app
+	}
+
+	return app + "/" + version
+}
+
+// isZoneAllowed returns true if no allowed zones are configured or the zone is one of them
+func isZoneAllowed(zoneName string, allowedZones []string) bool {
+
+	if len(allowedZones) == 0 {
+		return true
+	}
+
+	zoneName = strings.ToLower(toASCIIHostname(zoneName))
+	for _, allowedZone := range allowedZones {
+		if strings.ToLower(toASCIIHostname(allowedZone)) == zoneName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getHTTPProxy returns the proxy function for requests to the Cloudflare api, using the proxy url if set and the standard proxy environment variables otherwise
+func getHTTPProxy(proxyURL string) (func(*http.Request) (*url.URL, error), error) {
+
+	if proxyURL == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("Proxy url %v should include a scheme and host, like http://proxy.example.com:3128", proxyURL)
+	}
+
+	return http.ProxyURL(u), nil
+}
+
+var hostnamePlaceholderRegex = regexp.MustCompile(`\{[^{}]*\}`)
+
+// expandHostnames replaces placeholders like {service} in a comma-separated list of hostnames with their values, leaving out the hostnames with placeholders that have no value
+func expandHostnames(hostnames string, values map[string]string) (expandedHostnames string, unresolvedHostnames []string) {
+
+	// leave lists without placeholders untouched to keep the state of existing objects stable
+	if !strings.Contains(hostnames, "{") {
+		return hostnames, nil
+	}
+
+	resolvedHostnames := []string{}
+	for _, hostname := range splitHostnames(hostnames) {
+		unresolved := false
+		expandedHostname := hostnamePlaceholderRegex.ReplaceAllStringFunc(hostname, func(placeholder string) string {
+			value, ok := values[strings.ToLower(strings.Trim(placeholder, "{}"))]
+			if !ok || value == "" {
+				unresolved = true
+				return placeholder
+			}
+			return value
+		})
+
+		if unresolved {
+			unresolvedHostnames = append(unresolvedHostnames, hostname)
+			continue
+		}
+		resolvedHostnames = append(resolvedHostnames, expandedHostname)
+	}
+
+	return strings.Join(resolvedHostnames, ","), unresolvedHostnames
+}
@@ -1,8 +1,18 @@
 package main
 
 import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -26,11 +36,216 @@ func (r *fakeRESTClient) Put(cloudflareAPIURL string, params interface{}, authen
 	return args.Get(0).([]byte), args.Error(1)
 }
 
+func (r *fakeRESTClient) Patch(cloudflareAPIURL string, params interface{}, authentication APIAuthentication) (body []byte, err error) {
+	args := r.Called(cloudflareAPIURL, params, authentication)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
 func (r *fakeRESTClient) Delete(cloudflareAPIURL string, authentication APIAuthentication) (body []byte, err error) {
 	args := r.Called(cloudflareAPIURL, authentication)
 	return args.Get(0).([]byte), args.Error(1)
 }
 
+func (r *fakeRESTClient) PostFile(cloudflareAPIURL string, fields map[string]string, fileName string, file []byte, authentication APIAuthentication) (body []byte, err error) {
+	args := r.Called(cloudflareAPIURL, fields, fileName, file, authentication)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func TestRealRESTClientPatch(t *testing.T) {
+
+	t.Run("SendsPatchRequestWithJsonParamsAndAuthentication", func(t *testing.T) {
+
+		var method, contentType, authKey, authEmail, requestBody string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			method = r.Method
+			contentType = r.Header.Get("Content-Type")
+			authKey = r.Header.Get("X-Auth-Key")
+			authEmail = r.Header.Get("X-Auth-Email")
+			body, _ := ioutil.ReadAll(r.Body)
+			requestBody = string(body)
+			w.Write([]byte(`{"success": true}`))
+		}))
+		defer server.Close()
+
+		client := &realRESTClient{}
+
+		// act
+		body, err := client.Patch(server.URL+"/zones/023e105f4ecef8ad9ca31a8372d0c353/settings/ssl", ZoneSetting{Value: "strict"}, APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, `{"success": true}`, string(body))
+		assert.Equal(t, http.MethodPatch, method)
+		assert.Equal(t, "application/json", contentType)
+		assert.Equal(t, "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", authKey)
+		assert.Equal(t, "name@server.com", authEmail)
+		assert.Equal(t, `{"value":"strict"}`, requestBody)
+	})
+}
+
+func TestRateLimitBudget(t *testing.T) {
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	newHeader := func(limit, remaining, reset string) http.Header {
+		header := http.Header{}
+		header.Set("X-RateLimit-Limit", limit)
+		header.Set("X-RateLimit-Remaining", remaining)
+		header.Set("X-RateLimit-Reset", reset)
+		return header
+	}
+
+	t.Run("DoesNotDelayWithoutRateLimitHeaders", func(t *testing.T) {
+
+		budget := newRateLimitBudget()
+		budget.update(http.Header{}, now)
+
+		// act
+		delay := budget.getDelay(now)
+
+		assert.Equal(t, time.Duration(0), delay)
+	})
+
+	t.Run("DoesNotDelayWhilePlentyOfBudgetIsLeft", func(t *testing.T) {
+
+		budget := newRateLimitBudget()
+		budget.update(newHeader("1200", "900", "300"), now)
+
+		// act
+		delay := budget.getDelay(now)
+
+		assert.Equal(t, time.Duration(0), delay)
+		assert.Equal(t, float64(900), testutil.ToFloat64(apiRateLimitRemaining))
+	})
+
+	t.Run("SpreadsRemainingRequestsOverTimeUntilResetWhenBudgetRunsLow", func(t *testing.T) {
+
+		budget := newRateLimitBudget()
+		budget.update(newHeader("1200", "99", "50"), now)
+
+		// act
+		delay := budget.getDelay(now)
+
+		assert.Equal(t, 500*time.Millisecond, delay)
+	})
+
+	t.Run("WaitsUntilResetWhenBudgetIsUsedUp", func(t *testing.T) {
+
+		budget := newRateLimitBudget()
+		budget.update(newHeader("1200", "0", "30"), now)
+
+		// act
+		delay := budget.getDelay(now)
+
+		assert.Equal(t, 30*time.Second, delay)
+	})
+
+	t.Run("CapsDelay", func(t *testing.T) {
+
+		budget := newRateLimitBudget()
+		budget.update(newHeader("1200", "0", "3600"), now)
+
+		// act
+		delay := budget.getDelay(now)
+
+		assert.Equal(t, maxRateLimitDelay, delay)
+	})
+
+	t.Run("TakesResetAsUnixTimestamp", func(t *testing.T) {
+
+		budget := newRateLimitBudget()
+		budget.update(newHeader("1200", "0", strconv.FormatInt(now.Add(20*time.Second).Unix(), 10)), now)
+
+		// act
+		delay := budget.getDelay(now)
+
+		assert.Equal(t, 20*time.Second, delay)
+	})
+
+	t.Run("DoesNotDelayOnceResetHasPassed", func(t *testing.T) {
+
+		budget := newRateLimitBudget()
+		budget.update(newHeader("1200", "0", "30"), now)
+
+		// act
+		delay := budget.getDelay(now.Add(31 * time.Second))
+
+		assert.Equal(t, time.Duration(0), delay)
+	})
+
+	t.Run("RealRESTClientTakesBudgetFromResponseHeaders", func(t *testing.T) {
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-RateLimit-Limit", "1200")
+			w.Header().Set("X-RateLimit-Remaining", "1150")
+			w.Header().Set("X-RateLimit-Reset", "240")
+			w.Write([]byte(`{"success": true}`))
+		}))
+		defer server.Close()
+
+		client := newRealRESTClient("", nil)
+
+		// act
+		_, err := client.Get(server.URL+"/zones", APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1150, client.rateLimit.remaining)
+		assert.Equal(t, 1200, client.rateLimit.limit)
+		assert.Equal(t, float64(1150), testutil.ToFloat64(apiRateLimitRemaining))
+	})
+
+	t.Run("RealRESTClientReturnsRateLimitedErrorForTooManyRequestsResponse", func(t *testing.T) {
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"success": false, "errors": [{"code": 971, "message": "Please wait and consider throttling your request speed"}]}`))
+		}))
+		defer server.Close()
+
+		client := newRealRESTClient("", nil)
+
+		// act
+		_, err := client.Get(server.URL+"/zones", APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"})
+
+		var rateLimitedErr *rateLimitedError
+		if assert.True(t, errors.As(err, &rateLimitedErr)) {
+			assert.Equal(t, 30*time.Second, rateLimitedErr.retryAfter)
+		}
+	})
+
+	t.Run("GetRetryAfterParsesHttpDate", func(t *testing.T) {
+
+		header := http.Header{}
+		header.Set("Retry-After", now.Add(90*time.Second).UTC().Format(http.TimeFormat))
+
+		// act
+		retryAfter := getRetryAfter(header, now)
+
+		assert.Equal(t, 90*time.Second, retryAfter)
+	})
+
+	t.Run("GetRetryAfterFallsBackToRateLimitReset", func(t *testing.T) {
+
+		header := http.Header{}
+		header.Set("X-RateLimit-Reset", "45")
+
+		// act
+		retryAfter := getRetryAfter(header, now)
+
+		assert.Equal(t, 45*time.Second, retryAfter)
+	})
+
+	t.Run("GetRetryAfterCapsRetryAfterAtRateLimitWindow", func(t *testing.T) {
+
+		header := http.Header{}
+		header.Set("Retry-After", "3600")
+
+		// act
+		retryAfter := getRetryAfter(header, now)
+
+		assert.Equal(t, defaultRateLimitWindow, retryAfter)
+	})
+}
+
 func testEq(a, b []string) bool {
 
 	if a == nil && b == nil {
@@ -135,4 +350,590 @@ func TestGetMatchingZoneFromZones(t *testing.T) {
 		assert.Equal(t, "efgh", zone.ID)
 		assert.Equal(t, "domain.com", zone.Name)
 	})
+
+	t.Run("MultipleMatchingZonesReturnsActiveFullZone", func(t *testing.T) {
+
+		zones := []Zone{
+			Zone{ID: "abcd", Name: "domain.com", Status: "active", Type: "partial"},
+			Zone{ID: "efgh", Name: "domain.com", Status: "pending", Type: "full"},
+			Zone{ID: "ijkl", Name: "domain.com", Status: "active", Type: "full"},
+		}
+		zoneName := "domain.com"
+
+		// act
+		zone, _ := getMatchingZoneFromZones(zones, zoneName)
+
+		assert.Equal(t, "ijkl", zone.ID)
+	})
+
+	t.Run("MultipleMatchingZonesReturnsFirstZoneWhenEquallyPreferred", func(t *testing.T) {
+
+		zones := []Zone{
+			Zone{ID: "abcd", Name: "domain.com", Status: "active", Type: "full"},
+			Zone{ID: "efgh", Name: "domain.com", Status: "active", Type: "full"},
+		}
+		zoneName := "domain.com"
+
+		// act
+		zone, _ := getMatchingZoneFromZones(zones, zoneName)
+
+		assert.Equal(t, "abcd", zone.ID)
+	})
+}
+
+func TestIsDNSNameInZone(t *testing.T) {
+
+	t.Run("ReturnsTrueForZoneApex", func(t *testing.T) {
+
+		// act
+		inZone := isDNSNameInZone("server.com", "server.com")
+
+		assert.True(t, inZone)
+	})
+
+	t.Run("ReturnsTrueForSubdomain", func(t *testing.T) {
+
+		// act
+		inZone := isDNSNameInZone("www.Server.com", "server.com")
+
+		assert.True(t, inZone)
+	})
+
+	t.Run("ReturnsFalseForNameThatOnlySharesSuffix", func(t *testing.T) {
+
+		// act
+		inZone := isDNSNameInZone("www.myserver.com", "server.com")
+
+		assert.False(t, inZone)
+	})
+}
+
+func TestGetTTLForProxySetting(t *testing.T) {
+
+	t.Run("ReturnsAutomaticTTLWhenProxiedAndTTLIsExplicit", func(t *testing.T) {
+
+		// act
+		ttl := getTTLForProxySetting("www.server.com", 120, true)
+
+		assert.Equal(t, 1, ttl)
+	})
+
+	t.Run("ReturnsAutomaticTTLWhenProxiedAndTTLIsNotSet", func(t *testing.T) {
+
+		// act
+		ttl := getTTLForProxySetting("www.server.com", 0, true)
+
+		assert.Equal(t, 1, ttl)
+	})
+
+	t.Run("ReturnsExplicitTTLWhenNotProxied", func(t *testing.T) {
+
+		// act
+		ttl := getTTLForProxySetting("www.server.com", 120, false)
+
+		assert.Equal(t, 120, ttl)
+	})
+
+	t.Run("ReturnsAutomaticTTLWhenNotProxiedAndTTLIsNotSet", func(t *testing.T) {
+
+		// act
+		ttl := getTTLForProxySetting("www.server.com", 0, false)
+
+		assert.Equal(t, 1, ttl)
+	})
+}
+
+func TestAddOwnershipMarker(t *testing.T) {
+
+	t.Run("ReturnsCommentUnchangedWhenMarkerIsEmpty", func(t *testing.T) {
+
+		// act
+		comment := addOwnershipMarker("some comment", "")
+
+		assert.Equal(t, "some comment", comment)
+	})
+
+	t.Run("ReturnsMarkerWhenCommentIsEmpty", func(t *testing.T) {
+
+		// act
+		comment := addOwnershipMarker("", "managed by estafette-cloudflare-dns")
+
+		assert.Equal(t, "managed by estafette-cloudflare-dns", comment)
+	})
+
+	t.Run("AppendsMarkerWhenCommentLacksIt", func(t *testing.T) {
+
+		// act
+		comment := addOwnershipMarker("team a", "managed by estafette-cloudflare-dns")
+
+		assert.Equal(t, "team a; managed by estafette-cloudflare-dns", comment)
+	})
+
+	t.Run("ReturnsCommentUnchangedWhenItContainsMarker", func(t *testing.T) {
+
+		// act
+		comment := addOwnershipMarker("managed by estafette-cloudflare-dns", "managed by estafette-cloudflare-dns")
+
+		assert.Equal(t, "managed by estafette-cloudflare-dns", comment)
+	})
+}
+
+func TestToASCIIHostname(t *testing.T) {
+
+	t.Run("ReturnsPunycodeForUnicodeHostname", func(t *testing.T) {
+
+		// act
+		hostname := toASCIIHostname("bücher.example.com")
+
+		assert.Equal(t, "xn--bcher-kva.example.com", hostname)
+	})
+
+	t.Run("ReturnsPunycodeForUnicodeWildcardHostname", func(t *testing.T) {
+
+		// act
+		hostname := toASCIIHostname("*.bücher.example.com")
+
+		assert.Equal(t, "*.xn--bcher-kva.example.com", hostname)
+	})
+
+	t.Run("ReturnsAsciiHostnameUnchanged", func(t *testing.T) {
+
+		// act
+		hostname := toASCIIHostname("_sip._tcp.Example.com")
+
+		assert.Equal(t, "_sip._tcp.Example.com", hostname)
+	})
+}
+
+func TestGetUserAgent(t *testing.T) {
+
+	t.Run("ReturnsAppNameAndVersion", func(t *testing.T) {
+
+		// act
+		userAgent := getUserAgent("", "estafette-cloudflare-dns", "1.2.3")
+
+		assert.Equal(t, "estafette-cloudflare-dns/1.2.3", userAgent)
+	})
+
+	t.Run("ReturnsOverrideIfSet", func(t *testing.T) {
+
+		// act
+		userAgent := getUserAgent("my-team-dns-controller", "estafette-cloudflare-dns", "1.2.3")
+
+		assert.Equal(t, "my-team-dns-controller", userAgent)
+	})
+
+	t.Run("ReturnsDefaultAppNameWithoutVersionForLocalBuilds", func(t *testing.T) {
+
+		// act
+		userAgent := getUserAgent("", "", "")
+
+		assert.Equal(t, "estafette-cloudflare-dns", userAgent)
+	})
+}
+
+func TestAppendZoneName(t *testing.T) {
+
+	t.Run("AppendsNewZoneName", func(t *testing.T) {
+
+		// act
+		zoneNames := appendZoneName([]string{"example.com"}, "example.org")
+
+		assert.Equal(t, []string{"example.com", "example.org"}, zoneNames)
+	})
+
+	t.Run("SkipsZoneNameThatIsListedAlready", func(t *testing.T) {
+
+		// act
+		zoneNames := appendZoneName([]string{"example.com"}, "example.com")
+
+		assert.Equal(t, []string{"example.com"}, zoneNames)
+	})
+
+	t.Run("SkipsEmptyZoneName", func(t *testing.T) {
+
+		// act
+		zoneNames := appendZoneName([]string{}, "")
+
+		assert.Equal(t, []string{}, zoneNames)
+	})
+}
+
+func TestIsZoneAllowed(t *testing.T) {
+
+	t.Run("ReturnsTrueWhenNoAllowedZonesAreConfigured", func(t *testing.T) {
+
+		// act
+		allowed := isZoneAllowed("example.com", nil)
+
+		assert.True(t, allowed)
+	})
+
+	t.Run("ReturnsTrueWhenZoneIsInAllowedZonesIgnoringCase", func(t *testing.T) {
+
+		// act
+		allowed := isZoneAllowed("example.com", []string{"example.org", "Example.com"})
+
+		assert.True(t, allowed)
+	})
+
+	t.Run("ReturnsTrueWhenUnicodeAllowedZoneEqualsPunycodeZone", func(t *testing.T) {
+
+		// act
+		allowed := isZoneAllowed("xn--bcher-kva.com", []string{"bücher.com"})
+
+		assert.True(t, allowed)
+	})
+
+	t.Run("ReturnsFalseWhenZoneIsNotInAllowedZones", func(t *testing.T) {
+
+		// act
+		allowed := isZoneAllowed("example.com", []string{"example.org"})
+
+		assert.False(t, allowed)
+	})
+}
+
+func TestIsAuthenticationFailure(t *testing.T) {
+
+	t.Run("ReturnsTrueForAuthenticationError", func(t *testing.T) {
+
+		// act
+		failed := isAuthenticationFailure([]byte(`{"success": false, "errors": [{"code": 10000, "message": "Authentication error"}]}`), nil)
+
+		assert.True(t, failed)
+	})
+
+	t.Run("ReturnsFalseForOtherErrors", func(t *testing.T) {
+
+		// act
+		failed := isAuthenticationFailure([]byte(`{"success": false, "errors": [{"code": 81057, "message": "Record already exists."}]}`), nil)
+
+		assert.False(t, failed)
+	})
+
+	t.Run("ReturnsFalseForSuccessfulResponse", func(t *testing.T) {
+
+		// act
+		failed := isAuthenticationFailure([]byte(`{"success": true, "errors": []}`), nil)
+
+		assert.False(t, failed)
+	})
+
+	t.Run("ReturnsFalseIfRequestFailed", func(t *testing.T) {
+
+		// act
+		failed := isAuthenticationFailure(nil, errors.New("connection refused"))
+
+		assert.False(t, failed)
+	})
+}
+
+func TestIsRetryableDeleteError(t *testing.T) {
+
+	t.Run("ReturnsTrueIfRequestFailed", func(t *testing.T) {
+
+		// act
+		retryable := isRetryableDeleteError(errors.New("connection refused"))
+
+		assert.True(t, retryable)
+	})
+
+	t.Run("ReturnsFalseIfRecordIsGoneOrNotOurs", func(t *testing.T) {
+
+		for _, err := range []error{nil, errDNSRecordNotFound, errDNSRecordNotOwned, errDNSRecordNotMatching, errZoneNotFound, errZoneNotAllowed, errZonePaused} {
+
+			// act
+			retryable := isRetryableDeleteError(err)
+
+			assert.False(t, retryable, "%v", err)
+		}
+	})
+}
+
+func TestIsDNSRecordLeftBehind(t *testing.T) {
+
+	t.Run("ReturnsTrueIfZoneCannotBeWrittenTo", func(t *testing.T) {
+
+		for _, err := range []error{errZoneNotAllowed, errZonePaused, errors.New("connection refused")} {
+
+			// act
+			leftBehind := isDNSRecordLeftBehind(err)
+
+			assert.True(t, leftBehind, "%v", err)
+		}
+	})
+
+	t.Run("ReturnsFalseIfRecordIsGoneOrNotOurs", func(t *testing.T) {
+
+		for _, err := range []error{nil, errDNSRecordNotFound, errDNSRecordNotOwned, errDNSRecordNotMatching, errZoneNotFound} {
+
+			// act
+			leftBehind := isDNSRecordLeftBehind(err)
+
+			assert.False(t, leftBehind, "%v", err)
+		}
+	})
+}
+
+func TestGetHTTPProxy(t *testing.T) {
+
+	t.Run("ReturnsConfiguredProxyUrlForEveryRequest", func(t *testing.T) {
+
+		request, _ := http.NewRequest("GET", "https://api.cloudflare.com/client/v4/zones", nil)
+
+		// act
+		proxy, err := getHTTPProxy("http://proxy.example.com:3128")
+
+		assert.Nil(t, err)
+		proxyURL, err := proxy(request)
+		assert.Nil(t, err)
+		assert.Equal(t, "http://proxy.example.com:3128", proxyURL.String())
+	})
+
+	t.Run("ReturnsProxyFromEnvironmentWhenEmpty", func(t *testing.T) {
+
+		// act
+		proxy, err := getHTTPProxy("")
+
+		assert.Nil(t, err)
+		assert.Equal(t, reflect.ValueOf(http.ProxyFromEnvironment).Pointer(), reflect.ValueOf(proxy).Pointer())
+	})
+
+	t.Run("ReturnsErrorWhenProxyUrlLacksSchemeOrHost", func(t *testing.T) {
+
+		// act
+		_, err := getHTTPProxy("proxy.example.com:3128")
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestIsZonePlanAllowed(t *testing.T) {
+
+	t.Run("ReturnsTrueIfPlanLegacyIDIsOneOfThePlans", func(t *testing.T) {
+
+		// act
+		allowed := isZonePlanAllowed(Zone{Plan: ZonePlan{Name: "Pro Plan", LegacyID: "pro"}}, "pro,business")
+
+		assert.True(t, allowed)
+	})
+
+	t.Run("ReturnsTrueIfPlanNameIsOneOfThePlans", func(t *testing.T) {
+
+		// act
+		allowed := isZonePlanAllowed(Zone{Plan: ZonePlan{Name: "Pro Plan", LegacyID: "pro"}}, "pro plan")
+
+		assert.True(t, allowed)
+	})
+
+	t.Run("ReturnsFalseForRestrictedPlan", func(t *testing.T) {
+
+		// act
+		allowed := isZonePlanAllowed(Zone{Plan: ZonePlan{Name: "Free Website", LegacyID: "free"}}, "pro,business")
+
+		assert.False(t, allowed)
+	})
+
+	t.Run("ReturnsTrueIfZoneHasNoPlanInfo", func(t *testing.T) {
+
+		// act
+		allowed := isZonePlanAllowed(Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353", Name: "example.com"}, "pro")
+
+		assert.True(t, allowed)
+	})
+}
+
+func TestIsDNSRecordDataMatching(t *testing.T) {
+
+	t.Run("ReturnsTrueIfGenericDataEqualsStructuredData", func(t *testing.T) {
+
+		data := map[string]interface{}{"priority": float64(10), "weight": float64(5), "port": float64(5060), "target": "sip.example.com"}
+
+		// act
+		matching := isDNSRecordDataMatching(data, SRVRecordData{Priority: 10, Weight: 5, Port: 5060, Target: "sip.example.com"})
+
+		assert.True(t, matching)
+	})
+
+	t.Run("ReturnsFalseIfGenericDataDiffersFromStructuredData", func(t *testing.T) {
+
+		data := map[string]interface{}{"lat_degrees": float64(52), "lat_direction": "N", "long_degrees": float64(4), "long_direction": "E"}
+
+		// act
+		matching := isDNSRecordDataMatching(data, LOCRecordData{LatDegrees: 52, LatDirection: "S", LongDegrees: 4, LongDirection: "E"})
+
+		assert.False(t, matching)
+	})
+
+	t.Run("ReturnsFalseIfDataCannotBeDecodedIntoStructuredType", func(t *testing.T) {
+
+		// act
+		matching := isDNSRecordDataMatching("1.2.3.4", SRVRecordData{Target: "sip.example.com"})
+
+		assert.False(t, matching)
+	})
+}
+
+func TestIsDNSRecordUpToDate(t *testing.T) {
+
+	dnsRecord := DNSRecord{Type: "A", Name: "www.example.com", Content: "1.2.3.4", Comment: "managed by estafette-cloudflare-dns", TTL: 1}
+
+	t.Run("ReturnsTrueIfContentTTLAndCommentMatch", func(t *testing.T) {
+
+		// act
+		upToDate := isDNSRecordUpToDate(dnsRecord, "1.2.3.4", false, 1, "managed by estafette-cloudflare-dns", "")
+
+		assert.True(t, upToDate)
+	})
+
+	t.Run("ReturnsFalseIfContentDiffers", func(t *testing.T) {
+
+		// act
+		upToDate := isDNSRecordUpToDate(dnsRecord, "5.6.7.8", false, 1, "managed by estafette-cloudflare-dns", "")
+
+		assert.False(t, upToDate)
+	})
+
+	t.Run("ReturnsFalseIfTTLDiffers", func(t *testing.T) {
+
+		// act
+		upToDate := isDNSRecordUpToDate(dnsRecord, "1.2.3.4", false, 300, "managed by estafette-cloudflare-dns", "")
+
+		assert.False(t, upToDate)
+	})
+
+	t.Run("ReturnsFalseIfProxiedRecordShouldNotBeProxied", func(t *testing.T) {
+
+		proxiedDNSRecord := dnsRecord
+		proxiedDNSRecord.Proxied = true
+
+		// act
+		upToDate := isDNSRecordUpToDate(proxiedDNSRecord, "1.2.3.4", false, 1, "managed by estafette-cloudflare-dns", "")
+
+		assert.False(t, upToDate)
+	})
+
+	t.Run("ReturnsFalseIfCommentDiffers", func(t *testing.T) {
+
+		// act
+		upToDate := isDNSRecordUpToDate(dnsRecord, "1.2.3.4", false, 1, "owned by team a", "")
+
+		assert.False(t, upToDate)
+	})
+
+	t.Run("ReturnsFalseIfRegionDiffers", func(t *testing.T) {
+
+		// act
+		upToDate := isDNSRecordUpToDate(dnsRecord, "1.2.3.4", false, 1, "managed by estafette-cloudflare-dns", "eu")
+
+		assert.False(t, upToDate)
+	})
+}
+
+// getHistogramSampleCount returns the number of observations of a histogram
+func getHistogramSampleCount(t *testing.T, observer prometheus.Observer) uint64 {
+
+	metric := &dto.Metric{}
+	err := observer.(prometheus.Histogram).Write(metric)
+	assert.Nil(t, err)
+
+	return metric.GetHistogram().GetSampleCount()
+}
+
+func TestObserveDNSRecordAge(t *testing.T) {
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("ObservesTimeSinceLastModification", func(t *testing.T) {
+
+		observer := dnsRecordAgeSeconds.With(prometheus.Labels{"zone": "observed.example.com"})
+		before := getHistogramSampleCount(t, observer)
+
+		// act
+		observeDNSRecordAge(DNSRecord{Name: "www.observed.example.com", ModifiedOn: now.Add(-48 * time.Hour)}, "observed.example.com", now)
+
+		assert.Equal(t, before+1, getHistogramSampleCount(t, observer))
+
+		metric := &dto.Metric{}
+		observer.(prometheus.Histogram).Write(metric)
+		assert.Equal(t, float64(48*3600), metric.GetHistogram().GetSampleSum())
+	})
+
+	t.Run("SkipsRecordsWithoutModificationTime", func(t *testing.T) {
+
+		observer := dnsRecordAgeSeconds.With(prometheus.Labels{"zone": "unmodified.example.com"})
+		before := getHistogramSampleCount(t, observer)
+
+		// act
+		observeDNSRecordAge(DNSRecord{Name: "www.unmodified.example.com"}, "unmodified.example.com", now)
+
+		assert.Equal(t, before, getHistogramSampleCount(t, observer))
+	})
+}
+
+func TestExpandHostnames(t *testing.T) {
+
+	values := map[string]string{"service": "myservice", "namespace": "mynamespace", "domain": "mydomain.com"}
+
+	t.Run("ReplacesPlaceholdersWithValues", func(t *testing.T) {
+
+		// act
+		hostnames, unresolvedHostnames := expandHostnames("{service}.{namespace}.{domain},{Service}.mydomain.com", values)
+
+		assert.Equal(t, "myservice.mynamespace.mydomain.com,myservice.mydomain.com", hostnames)
+		assert.Empty(t, unresolvedHostnames)
+	})
+
+	t.Run("ReturnsHostnamesWithoutPlaceholdersUnchanged", func(t *testing.T) {
+
+		// act
+		hostnames, unresolvedHostnames := expandHostnames("www.mydomain.com, api.mydomain.com", values)
+
+		assert.Equal(t, "www.mydomain.com, api.mydomain.com", hostnames)
+		assert.Empty(t, unresolvedHostnames)
+	})
+
+	t.Run("LeavesOutHostnamesWithUnknownPlaceholders", func(t *testing.T) {
+
+		// act
+		hostnames, unresolvedHostnames := expandHostnames("{service}.{cluster}.mydomain.com,{service}.mydomain.com", values)
+
+		assert.Equal(t, "myservice.mydomain.com", hostnames)
+		assert.Equal(t, []string{"{service}.{cluster}.mydomain.com"}, unresolvedHostnames)
+	})
+
+	t.Run("LeavesOutHostnamesWithPlaceholdersWithoutValue", func(t *testing.T) {
+
+		// act
+		hostnames, unresolvedHostnames := expandHostnames("{service}.{domain}", map[string]string{"service": "myservice", "domain": ""})
+
+		assert.Equal(t, "", hostnames)
+		assert.Equal(t, []string{"{service}.{domain}"}, unresolvedHostnames)
+	})
+}
+
+func TestGetMissingDNSRecordDataIndex(t *testing.T) {
+
+	t.Run("ReturnsIndexOfFirstUnmatchedEqualData", func(t *testing.T) {
+
+		dnsRecordsData := []interface{}{SRVRecordData{Priority: 10, Weight: 5, Port: 5060, Target: "sip.example.com"}, SRVRecordData{Priority: 10, Weight: 5, Port: 5060, Target: "sip.example.com"}}
+		data := map[string]interface{}{"priority": float64(10), "weight": float64(5), "port": float64(5060), "target": "sip.example.com"}
+
+		// act
+		index := getMissingDNSRecordDataIndex(dnsRecordsData, []bool{true, false}, data)
+
+		assert.Equal(t, 1, index)
+	})
+
+	t.Run("ReturnsMinusOneIfNoDataMatches", func(t *testing.T) {
+
+		dnsRecordsData := []interface{}{SRVRecordData{Priority: 10, Weight: 5, Port: 5060, Target: "sip.example.com"}}
+		data := map[string]interface{}{"priority": float64(20), "weight": float64(5), "port": float64(5060), "target": "sip.example.com"}
+
+		// act
+		index := getMissingDNSRecordDataIndex(dnsRecordsData, []bool{false}, data)
+
+		assert.Equal(t, -1, index)
+	})
 }
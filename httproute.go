@@ -0,0 +1,548 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	v1 "k8s.io/api/core/v1"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/record"
+)
+
+var (
+	httpRoutesResource = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "httproutes"}
+	gatewaysResource   = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "gateways"}
+)
+
+func getDesiredHTTPRouteState(ctx context.Context, dynamicClient dynamic.Interface, route *unstructured.Unstructured) (state CloudflareState) {
+
+	var ok bool
+
+	annotations := route.GetAnnotations()
+
+	state.Enabled = getBooleanAnnotation(annotations, annotationCloudflareDNS, false, "HTTPRoute", route.GetName(), route.GetNamespace())
+	state.Hostnames, ok = annotations[annotationCloudflareHostnames]
+	if !ok {
+		// fall back to the hostnames in the route spec
+		hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+		state.Hostnames = strings.Join(hostnames, ",")
+	}
+	state.Hostnames = normalizeHostnames(state.Hostnames)
+	state.Proxy = getBooleanProxyAnnotation(annotations, "HTTPRoute", route.GetName(), route.GetNamespace())
+	state.ProxyPlans = getProxyPlansAnnotation(annotations)
+	state.UseOriginRecord = getBooleanAnnotation(annotations, annotationCloudflareUseOriginRecord, *defaultUseOriginRecord, "HTTPRoute", route.GetName(), route.GetNamespace())
+	state.OriginRecordHostname = getOriginRecordHostname(annotations, state.UseOriginRecord, state.Hostnames, "HTTPRoute", route.GetName(), route.GetNamespace())
+	state.CNAMETarget, ok = annotations[annotationCloudflareCNAMETarget]
+	if !ok {
+		state.CNAMETarget = ""
+	}
+	state.Comment, ok = annotations[annotationCloudflareComment]
+	if !ok {
+		state.Comment = defaultCloudflareComment
+	}
+	state.TTL = getTTLAnnotation(annotations, "HTTPRoute", route.GetName(), route.GetNamespace())
+	state.Region = getRegionAnnotation(annotations)
+
+	ipAddress, err := getHTTPRouteGatewayIPAddress(ctx, dynamicClient, route)
+	if err != nil {
+		log.Debug().Err(err).Msgf("HTTPRoute %v.%v - Failed retrieving gateway ip address", route.GetName(), route.GetNamespace())
+	}
+	state.IPAddress = ipAddress
+
+	return
+}
+
+// getHTTPRouteGatewayIPAddress returns the first ip address in the status of the first gateway referenced by the route
+func getHTTPRouteGatewayIPAddress(ctx context.Context, dynamicClient dynamic.Interface, route *unstructured.Unstructured) (ipAddress string, err error) {
+
+	parentRefs, _, err := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+	if err != nil {
+		return
+	}
+
+	for _, pr := range parentRefs {
+		parentRef, ok := pr.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		// parent refs default to a gateway in the namespace of the route
+		kind, _, _ := unstructured.NestedString(parentRef, "kind")
+		if kind != "" && kind != "Gateway" {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(parentRef, "name")
+		namespace, _, _ := unstructured.NestedString(parentRef, "namespace")
+		if namespace == "" {
+			namespace = route.GetNamespace()
+		}
+
+		gateway, err := dynamicClient.Resource(gatewaysResource).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return ipAddress, err
+		}
+
+		addresses, _, _ := unstructured.NestedSlice(gateway.Object, "status", "addresses")
+		for _, a := range addresses {
+			address, ok := a.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			addressType, _, _ := unstructured.NestedString(address, "type")
+			if addressType != "" && addressType != "IPAddress" {
+				continue
+			}
+			value, _, _ := unstructured.NestedString(address, "value")
+			if value != "" {
+				return value, nil
+			}
+		}
+	}
+
+	return ipAddress, errors.New("No gateway with an ip address has been found")
+}
+
+func getCurrentHTTPRouteState(ctx context.Context, route *unstructured.Unstructured) (state CloudflareState) {
+
+	// get state stored in the configmap if enabled, falling back to the annotation for objects that haven't been reconciled since switching
+	if stateStore != nil {
+		if state, ok := stateStore.get(ctx, "HTTPRoute", route.GetNamespace(), route.GetName()); ok {
+			return state
+		}
+	}
+
+	// get state stored in annotations if present or set to empty struct
+	cloudflareStateString, ok := route.GetAnnotations()[annotationCloudflareState]
+	if !ok {
+		// couldn't find saved state, setting to default struct
+		state = CloudflareState{}
+		return
+	}
+
+	if err := decodeStateAnnotation(cloudflareStateString, &state); err != nil {
+		// couldn't deserialize, setting to default struct
+		countStateDecodeFailure("HTTPRoute", route.GetNamespace(), route.GetName(), cloudflareStateString, err)
+		state = CloudflareState{}
+		return
+	}
+
+	// return deserialized state
+	return
+}
+
+func makeHTTPRouteChanges(ctx context.Context, cf *Cloudflare, dynamicClient dynamic.Interface, recorder record.EventRecorder, route *unstructured.Unstructured, initiator string, desiredState, currentState CloudflareState, finalState *CloudflareState) (status string, changes int, err error) {
+
+	status = "failed"
+
+	// upsert all records regardless of the stored state if requested, to heal changes made outside of the controller
+	forceUpdate := getBooleanAnnotation(route.GetAnnotations(), annotationCloudflareForceUpdate, false, "HTTPRoute", route.GetName(), route.GetNamespace()) == "true"
+
+	// the records aren't part of the desired state, so keep the stored ones until the records get upserted again
+	desiredState.Records = currentState.Records
+
+	// hand the state as it ended up to the caller, for counting the managed records
+	if finalState != nil {
+		defer func() {
+			*finalState = desiredState
+		}()
+	}
+
+	// the records as upserted, for the zone each hostname resolved to and the ttl cloudflare assigned
+	upsertedRecords := map[string]DNSRecord{}
+
+	// the names of the records that exist at cloudflare without the ownership marker, which are left alone and not stored
+	notOwnedRecords := map[string]bool{}
+
+	if *logReconcileDiff {
+		logStateDiff("HTTPRoute", route.GetName(), route.GetNamespace(), initiator, desiredState, currentState)
+	}
+
+	// check if dns has been disabled since the state was stored, in which case the previously managed records need to be cleaned up
+	if desiredState.Enabled != "true" && currentState.Enabled == "true" {
+
+		log.Info().Msgf("[%v] HTTPRoute %v.%v - Dns has been disabled, deleting previously managed dns records...", initiator, route.GetName(), route.GetNamespace())
+
+		deletedChanges, err := deleteRecordsFromState(cf, recorder, route, "HTTPRoute", route.GetName(), route.GetNamespace(), initiator, currentState)
+		changes += deletedChanges
+		if err != nil {
+			// keep the stored state, so the records that failed to get deleted are retried
+			log.Error().Err(err).Msgf("[%v] HTTPRoute %v.%v - Deleting previously managed dns records has failed", initiator, route.GetName(), route.GetNamespace())
+			return status, changes, err
+		}
+
+		if stateStore != nil {
+			// remove the stored state, so the records get recreated if dns is enabled again
+			err = stateStore.remove(ctx, "HTTPRoute", route.GetNamespace(), route.GetName())
+			if err != nil {
+				log.Error().Err(err).Msgf("[%v] HTTPRoute %v.%v - Removing httproute state from configmap has failed", initiator, route.GetName(), route.GetNamespace())
+				return status, changes, err
+			}
+		} else {
+			// remove the state annotation, so the records get recreated if dns is enabled again
+			patch, err := getStateAnnotationPatch(nil)
+			if err != nil {
+				log.Error().Err(err).Msgf("[%v] HTTPRoute %v.%v - Marshalling state patch failed", initiator, route.GetName(), route.GetNamespace())
+				return status, changes, err
+			}
+
+			_, err = dynamicClient.Resource(httpRoutesResource).Namespace(route.GetNamespace()).Patch(ctx, route.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+			if err != nil {
+				log.Error().Err(err).Msgf("[%v] HTTPRoute %v.%v - Removing httproute state has failed", initiator, route.GetName(), route.GetNamespace())
+				return status, changes, err
+			}
+		}
+
+		status = "deleted"
+
+		return status, changes, nil
+	}
+
+	// check if route has estafette.io/cloudflare-dns annotation and it's value is true and
+	// check if route has hostnames and
+	// check if the gateway has an ip address or a cname target is set
+	if desiredState.Enabled == "true" && len(desiredState.Hostnames) > 0 && (desiredState.IPAddress != "" || desiredState.CNAMETarget != "") {
+
+		// update dns record if anything has changed compared to the stored state
+		if forceUpdate ||
+			desiredState.IPAddress != currentState.IPAddress ||
+			desiredState.Hostnames != currentState.Hostnames ||
+			desiredState.Proxy != currentState.Proxy ||
+			desiredState.ProxyPlans != currentState.ProxyPlans ||
+			desiredState.UseOriginRecord != currentState.UseOriginRecord ||
+			desiredState.OriginRecordHostname != currentState.OriginRecordHostname ||
+			desiredState.CNAMETarget != currentState.CNAMETarget ||
+			desiredState.Comment != currentState.Comment ||
+			desiredState.TTL != currentState.TTL ||
+			desiredState.Region != currentState.Region {
+
+			// point to the gateway with an A record, or an AAAA record for ipv6 addresses
+			dnsRecordType := getTargetDNSRecordType(desiredState)
+			hostnameDNSRecordType, _ := getHostnameDNSRecord(desiredState)
+
+			// if use origin is enabled, create a record for the origin
+			if desiredState.CNAMETarget == "" && desiredState.UseOriginRecord == "true" && desiredState.OriginRecordHostname != "" {
+
+				log.Info().Msgf("[%v] HTTPRoute %v.%v - Upserting origin dns record %v (%v) to ip address %v...", initiator, route.GetName(), route.GetNamespace(), desiredState.OriginRecordHostname, dnsRecordType, desiredState.IPAddress)
+
+				originDNSRecord, upserted, err := cf.UpsertDNSRecord(dnsRecordType, desiredState.OriginRecordHostname, desiredState.IPAddress, false, desiredState.Comment, desiredState.Region, 0)
+				if errors.Is(err, errDNSRecordNotOwned) {
+					log.Warn().Msgf("[%v] HTTPRoute %v.%v - Origin dns record %v (%v) lacks the ownership marker, leaving it alone", initiator, route.GetName(), route.GetNamespace(), desiredState.OriginRecordHostname, dnsRecordType)
+					notOwnedRecords[desiredState.OriginRecordHostname] = true
+				} else if err != nil {
+					getUpsertFailureLogEvent(err).Msgf("[%v] HTTPRoute %v.%v - Upserting origin dns record %v (%v) to ip address %v failed", initiator, route.GetName(), route.GetNamespace(), desiredState.OriginRecordHostname, dnsRecordType, desiredState.IPAddress)
+					recorder.Eventf(route, v1.EventTypeWarning, "DNSRecordUpsertFailed", "Upserting origin dns record %v (%v) to ip address %v failed: %v", desiredState.OriginRecordHostname, dnsRecordType, desiredState.IPAddress, err)
+					return status, changes, err
+				} else {
+					upsertedRecords[desiredState.OriginRecordHostname] = originDNSRecord
+					if upserted {
+						recorder.Eventf(route, v1.EventTypeNormal, "DNSRecordUpserted", "Upserted origin dns record %v (%v) to ip address %v", desiredState.OriginRecordHostname, dnsRecordType, desiredState.IPAddress)
+						changes++
+					}
+				}
+			}
+
+			// loop all hostnames
+			hostnames := splitHostnames(desiredState.Hostnames)
+			// the ttl is set while upserting the records, 0 leaves it automatic
+			ttl, _ := strconv.Atoi(desiredState.TTL)
+			for _, hostname := range hostnames {
+
+				// validate hostname, skip if invalid
+				if reason := validateHostname(hostname); reason != "" {
+					log.Error().Msgf("[%v] HTTPRoute %v.%v - Invalid dns record %v (%v), skipping", initiator, route.GetName(), route.GetNamespace(), hostname, reason)
+					invalidHostnamesTotals.With(prometheus.Labels{"namespace": route.GetNamespace(), "reason": reason}).Inc()
+					continue
+				}
+
+				// zones on plans other than the proxy plans can't proxy every record, so they get dns only records instead of failing
+				planAllowsProxy, plan := isProxyAllowedByZonePlan(cf.GetZoneByDNSName, hostname, desiredState.ProxyPlans)
+				if !planAllowsProxy && desiredState.Proxy == "true" {
+					log.Warn().Msgf("[%v] HTTPRoute %v.%v - Zone of dns record %v is on plan %v, which isn't one of the proxy plans %v, leaving it dns only", initiator, route.GetName(), route.GetNamespace(), hostname, plan, desiredState.ProxyPlans)
+					recorder.Eventf(route, v1.EventTypeWarning, "ProxyDowngraded", "Zone of dns record %v is on plan %v, which isn't one of the proxy plans %v, leaving it dns only", hostname, plan, desiredState.ProxyPlans)
+				}
+				proxy := desiredState.Proxy == "true" && planAllowsProxy
+
+				var dnsRecord DNSRecord
+				var upserted bool
+				// if a cname target is set, create a CNAME record pointing to that target, else if use origin is enabled, create a CNAME record pointing to the origin record
+				if desiredState.CNAMETarget != "" {
+
+					log.Info().Msgf("[%v] HTTPRoute %v.%v - Upserting dns record %v (CNAME) to value %v...", initiator, route.GetName(), route.GetNamespace(), hostname, desiredState.CNAMETarget)
+
+					dnsRecord, upserted, err = cf.UpsertDNSRecord("CNAME", hostname, desiredState.CNAMETarget, proxy, desiredState.Comment, desiredState.Region, ttl)
+					if errors.Is(err, errDNSRecordNotOwned) {
+						log.Warn().Msgf("[%v] HTTPRoute %v.%v - Dns record %v (CNAME) lacks the ownership marker, leaving it alone", initiator, route.GetName(), route.GetNamespace(), hostname)
+						notOwnedRecords[hostname] = true
+						continue
+					}
+					if err != nil {
+						getUpsertFailureLogEvent(err).Msgf("[%v] HTTPRoute %v.%v - Upserting dns record %v (CNAME) to value %v failed", initiator, route.GetName(), route.GetNamespace(), hostname, desiredState.CNAMETarget)
+						recorder.Eventf(route, v1.EventTypeWarning, "DNSRecordUpsertFailed", "Upserting dns record %v (CNAME) to value %v failed: %v", hostname, desiredState.CNAMETarget, err)
+						return status, changes, err
+					}
+					if upserted {
+						recorder.Eventf(route, v1.EventTypeNormal, "DNSRecordUpserted", "Upserted dns record %v (CNAME) to value %v", hostname, desiredState.CNAMETarget)
+						changes++
+					}
+				} else if desiredState.UseOriginRecord == "true" && desiredState.OriginRecordHostname != "" {
+
+					log.Info().Msgf("[%v] HTTPRoute %v.%v - Upserting dns record %v (CNAME) to value %v...", initiator, route.GetName(), route.GetNamespace(), hostname, desiredState.OriginRecordHostname)
+
+					dnsRecord, upserted, err = cf.UpsertDNSRecord("CNAME", hostname, desiredState.OriginRecordHostname, proxy, desiredState.Comment, desiredState.Region, ttl)
+					if errors.Is(err, errDNSRecordNotOwned) {
+						log.Warn().Msgf("[%v] HTTPRoute %v.%v - Dns record %v (CNAME) lacks the ownership marker, leaving it alone", initiator, route.GetName(), route.GetNamespace(), hostname)
+						notOwnedRecords[hostname] = true
+						continue
+					}
+					if err != nil {
+						getUpsertFailureLogEvent(err).Msgf("[%v] HTTPRoute %v.%v - Upserting dns record %v (CNAME) to value %v failed", initiator, route.GetName(), route.GetNamespace(), hostname, desiredState.OriginRecordHostname)
+						recorder.Eventf(route, v1.EventTypeWarning, "DNSRecordUpsertFailed", "Upserting dns record %v (CNAME) to value %v failed: %v", hostname, desiredState.OriginRecordHostname, err)
+						return status, changes, err
+					}
+					if upserted {
+						recorder.Eventf(route, v1.EventTypeNormal, "DNSRecordUpserted", "Upserted dns record %v (CNAME) to value %v", hostname, desiredState.OriginRecordHostname)
+						changes++
+					}
+				} else {
+
+					log.Info().Msgf("[%v] HTTPRoute %v.%v - Upserting dns record %v (%v) to ip address %v...", initiator, route.GetName(), route.GetNamespace(), hostname, dnsRecordType, desiredState.IPAddress)
+
+					dnsRecord, upserted, err = cf.UpsertDNSRecord(dnsRecordType, hostname, desiredState.IPAddress, proxy, desiredState.Comment, desiredState.Region, ttl)
+					if errors.Is(err, errDNSRecordNotOwned) {
+						log.Warn().Msgf("[%v] HTTPRoute %v.%v - Dns record %v (%v) lacks the ownership marker, leaving it alone", initiator, route.GetName(), route.GetNamespace(), hostname, dnsRecordType)
+						notOwnedRecords[hostname] = true
+						continue
+					}
+					if err != nil {
+						getUpsertFailureLogEvent(err).Msgf("[%v] HTTPRoute %v.%v - Upserting dns record %v (%v) to ip address %v failed", initiator, route.GetName(), route.GetNamespace(), hostname, dnsRecordType, desiredState.IPAddress)
+						recorder.Eventf(route, v1.EventTypeWarning, "DNSRecordUpsertFailed", "Upserting dns record %v (%v) to ip address %v failed: %v", hostname, dnsRecordType, desiredState.IPAddress, err)
+						return status, changes, err
+					}
+					if upserted {
+						recorder.Eventf(route, v1.EventTypeNormal, "DNSRecordUpserted", "Upserted dns record %v (%v) to ip address %v", hostname, dnsRecordType, desiredState.IPAddress)
+						changes++
+					}
+				}
+
+				upsertedRecords[hostname] = dnsRecord
+
+				// if proxy is enabled, update it at Cloudflare
+				if proxy {
+					log.Info().Msgf("[%v] HTTPRoute %v.%v - Enabling proxying for dns record %v (A)...", initiator, route.GetName(), route.GetNamespace(), hostname)
+				} else {
+					log.Info().Msgf("[%v] HTTPRoute %v.%v - Disabling proxying for dns record %v (A)...", initiator, route.GetName(), route.GetNamespace(), hostname)
+				}
+
+				_, err := cf.UpdateProxySetting(hostnameDNSRecordType, hostname, proxy)
+				if err != nil {
+					if proxy {
+						log.Error().Err(err).Msgf("[%v] HTTPRoute %v.%v - Enabling proxying for dns record %v (A) failed", initiator, route.GetName(), route.GetNamespace(), hostname)
+						recorder.Eventf(route, v1.EventTypeWarning, "ProxySettingUpdateFailed", "Enabling proxying for dns record %v (A) failed: %v", hostname, err)
+					} else {
+						log.Error().Err(err).Msgf("[%v] HTTPRoute %v.%v - Disabling proxying for dns record %v (A) failed", initiator, route.GetName(), route.GetNamespace(), hostname)
+						recorder.Eventf(route, v1.EventTypeWarning, "ProxySettingUpdateFailed", "Disabling proxying for dns record %v (A) failed: %v", hostname, err)
+					}
+
+					return status, changes, err
+				}
+				recorder.Eventf(route, v1.EventTypeNormal, "ProxySettingUpdated", "Set proxying for dns record %v (A) to %v", hostname, proxy)
+			}
+
+			// clean up the records that are no longer desired, like the ones of removed hostnames
+			desiredState.Records = setUpsertedRecordDetails(removeNotOwnedRecords(getStateManagedRecords(desiredState), notOwnedRecords), upsertedRecords)
+			staleChanges, staleFailures := deleteStaleRecords(cf, recorder, route, "HTTPRoute", route.GetName(), route.GetNamespace(), initiator, desiredState.Records, getStoredRecords(currentState))
+			changes += staleChanges
+			if staleFailures > 0 {
+				// leave the stored state as is, so the stale records that failed to get deleted are retried
+				err = getDeleteFailuresError(staleFailures)
+				log.Error().Err(err).Msgf("[%v] HTTPRoute %v.%v - Deleting stale dns records has failed", initiator, route.GetName(), route.GetNamespace())
+				return status, changes, err
+			}
+
+			// if any state property changed make sure to update all
+			currentState = desiredState
+
+			log.Info().Msgf("[%v] HTTPRoute %v.%v - Updating httproute because state has changed...", initiator, route.GetName(), route.GetNamespace())
+
+			if stateStore != nil {
+				// store state in the configmap, leaving the httproute itself untouched
+				err = stateStore.set(ctx, "HTTPRoute", route.GetNamespace(), route.GetName(), currentState)
+				if err != nil {
+					log.Error().Err(err).Msgf("[%v] HTTPRoute %v.%v - Storing httproute state in configmap has failed", initiator, route.GetName(), route.GetNamespace())
+					return status, changes, err
+				}
+			} else {
+				// serialize state and store it in the annotation
+				patch, err := getStateAnnotationPatch(&currentState)
+				if err != nil {
+					log.Error().Err(err).Msgf("[%v] HTTPRoute %v.%v - Marshalling state failed", initiator, route.GetName(), route.GetNamespace())
+					return status, changes, err
+				}
+
+				// only patch the state annotation, so it doesn't conflict with changes to the rest of the route
+				_, err = dynamicClient.Resource(httpRoutesResource).Namespace(route.GetNamespace()).Patch(ctx, route.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+				if isAnnotationsTooLargeError(err) {
+					// the records are in place, so carry on without stored state; they get upserted again every cycle until the annotations shrink
+					log.Warn().Err(err).Msgf("[%v] HTTPRoute %v.%v - Storing httproute state has failed, because its annotations exceed the limit of %v bytes; use --state-storage=configmap to store the state elsewhere", initiator, route.GetName(), route.GetNamespace(), apivalidation.TotalAnnotationSizeLimitB)
+					err = nil
+				}
+				if err != nil {
+					log.Error().Err(err).Msgf("[%v] HTTPRoute %v.%v - Updating httproute state has failed", initiator, route.GetName(), route.GetNamespace())
+					return status, changes, err
+				}
+			}
+
+			// only force the update once
+			if forceUpdate {
+				patch, err := getAnnotationPatch(annotationCloudflareForceUpdate, nil)
+				if err != nil {
+					log.Error().Err(err).Msgf("[%v] HTTPRoute %v.%v - Marshalling force update annotation removal failed", initiator, route.GetName(), route.GetNamespace())
+					return status, changes, err
+				}
+
+				_, err = dynamicClient.Resource(httpRoutesResource).Namespace(route.GetNamespace()).Patch(ctx, route.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+				if err != nil {
+					log.Error().Err(err).Msgf("[%v] HTTPRoute %v.%v - Removing force update annotation has failed", initiator, route.GetName(), route.GetNamespace())
+					return status, changes, err
+				}
+			}
+
+			status = "succeeded"
+
+			log.Info().Msgf("[%v] HTTPRoute %v.%v - HTTPRoute has been updated successfully...", initiator, route.GetName(), route.GetNamespace())
+
+			return status, changes, nil
+		}
+	}
+
+	status = "skipped"
+
+	return status, changes, nil
+}
+
+func processHTTPRoute(ctx context.Context, cf *Cloudflare, dynamicClient dynamic.Interface, recorder record.EventRecorder, route *unstructured.Unstructured, initiator string) (status string, changes int, err error) {
+
+	if route != nil {
+		return processHTTPRouteWithDesiredState(ctx, cf, dynamicClient, recorder, route, initiator, getDesiredHTTPRouteState(ctx, dynamicClient, route), nil)
+	}
+
+	return "skipped", 0, nil
+}
+
+// processHTTPRouteWithDesiredState reconciles a route against a desired state that's already been determined, so callers that need it as well don't have to retrieve the gateway twice; the state it ended up with is handed to the caller if asked for
+func processHTTPRouteWithDesiredState(ctx context.Context, cf *Cloudflare, dynamicClient dynamic.Interface, recorder record.EventRecorder, route *unstructured.Unstructured, initiator string, desiredState CloudflareState, finalState *CloudflareState) (status string, changes int, err error) {
+
+	status = "failed"
+
+	if route != nil {
+
+		currentState := getCurrentHTTPRouteState(ctx, route)
+
+		status, changes, err = makeHTTPRouteChanges(ctx, cf, dynamicClient, recorder, route, initiator, desiredState, currentState, finalState)
+		status, err = handleZoneMissing("HTTPRoute", route.GetName(), route.GetNamespace(), status, err)
+
+		return
+	}
+
+	status = "skipped"
+
+	return status, changes, nil
+}
+
+func deleteHTTPRoute(ctx context.Context, cf *Cloudflare, recorder record.EventRecorder, route *unstructured.Unstructured, initiator string) (status string, changes int, err error) {
+
+	status = "failed"
+
+	if route != nil {
+
+		// the gateway might be gone already, so use the stored state to find the records to delete
+		currentState := getCurrentHTTPRouteState(ctx, route)
+
+		dnsRecordType, dnsRecordContent := getHostnameDNSRecord(currentState)
+
+		// count the records that failed to get deleted, to retry deleting the object if retrying can help
+		failures := 0
+
+		// loop all hostnames
+		hostnames := splitHostnames(currentState.Hostnames)
+		for _, hostname := range hostnames {
+			log.Info().Msgf("[%v] HTTPRoute %v.%v - Deleting dns record %v (%v) with content %v...", initiator, route.GetName(), route.GetNamespace(), hostname, dnsRecordType, dnsRecordContent)
+			_, err := cf.DeleteDNSRecordIfMatching(hostname, dnsRecordType, dnsRecordContent)
+			if err != nil {
+				log.Warn().Err(err).Msgf("[%v] HTTPRoute %v.%v - Failed deleting dns record %v (%v) with content %v...", initiator, route.GetName(), route.GetNamespace(), hostname, dnsRecordType, dnsRecordContent)
+				recorder.Eventf(route, v1.EventTypeWarning, "DNSRecordDeleteFailed", "Deleting dns record %v (%v) with content %v failed: %v", hostname, dnsRecordType, dnsRecordContent, err)
+				if countDeleteFailure("HTTPRoute", route.GetNamespace(), err) {
+					failures++
+				}
+			} else {
+				recorder.Eventf(route, v1.EventTypeNormal, "DNSRecordDeleted", "Deleted dns record %v (%v) with content %v", hostname, dnsRecordType, dnsRecordContent)
+				changes++
+				status = "deleted"
+			}
+		}
+
+		// keep the stored state until all records are gone, so retries know which ones to delete
+		if failures > 0 {
+			return status, changes, getDeleteFailuresError(failures)
+		}
+
+		// the stored state is of no use anymore once the httproute is gone
+		if stateStore != nil {
+			if err := stateStore.remove(ctx, "HTTPRoute", route.GetNamespace(), route.GetName()); err != nil {
+				log.Warn().Err(err).Msgf("[%v] HTTPRoute %v.%v - Removing httproute state from configmap has failed", initiator, route.GetName(), route.GetNamespace())
+			}
+		}
+
+		return status, changes, nil
+	}
+
+	status = "skipped"
+
+	return status, changes, nil
+}
+
+func watchHTTPRoutes(ctx context.Context, cf *Cloudflare, dynamicClient dynamic.Interface, recorder record.EventRecorder, factory dynamicinformer.DynamicSharedInformerFactory, waitGroup *sync.WaitGroup, stopper chan struct{}) error {
+	httpRoutesInformer := factory.ForResource(httpRoutesResource).Informer()
+
+	httpRoutesQueue := newObjectQueue("httproute", httpRoutesInformer.GetIndexer(),
+		func(obj interface{}) (string, int, error) {
+			route, ok := obj.(*unstructured.Unstructured)
+			if !ok {
+				return "failed", 0, errors.New("Watcher for httproutes returns event object of incorrect type")
+			}
+			return processHTTPRoute(ctx, cf, dynamicClient, recorder, route, "watcher")
+		},
+		func(obj interface{}) (string, int, error) {
+			route, ok := obj.(*unstructured.Unstructured)
+			if !ok {
+				return "failed", 0, errors.New("Watcher for httproutes returns event object of incorrect type")
+			}
+			return deleteHTTPRoute(ctx, cf, recorder, route, "watcher:deleted")
+		},
+	)
+
+	httpRoutesInformer.AddEventHandler(httpRoutesQueue.handlers())
+
+	watchInformerHealth("httproutes", httpRoutesInformer, stopper)
+
+	go httpRoutesInformer.Run(stopper)
+
+	if err := waitForInformerCacheSync(ctx, "httproutes", httpRoutesInformer, stopper); err != nil {
+		return err
+	}
+
+	httpRoutesQueue.run(*watcherConcurrency, waitGroup, stopper)
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	"k8s.io/client-go/tools/cache"
+)
+
+// informerHealthCheckInterval is how often the health of the informers is reflected in the estafette_cloudflare_dns_informer_healthy metric
+const informerHealthCheckInterval = 15 * time.Second
+
+// informerHealth tracks whether an informer receives events, since a dropped watch connection otherwise goes unnoticed until the poller runs.
+type informerHealth struct {
+	kind     string
+	informer cache.SharedIndexInformer
+
+	mutex sync.Mutex
+	// the resource version the informer was at when its watch failed; once it moves on, the informer has recovered
+	failedResourceVersion string
+	failing               bool
+}
+
+func newInformerHealth(kind string, informer cache.SharedIndexInformer) *informerHealth {
+	return &informerHealth{
+		kind:     kind,
+		informer: informer,
+	}
+}
+
+// watchInformerHealth records watch errors of the informer and keeps its health metric up to date; it has to be called before the informer runs
+func watchInformerHealth(kind string, informer cache.SharedIndexInformer, stopper chan struct{}) {
+
+	health := newInformerHealth(kind, informer)
+
+	err := informer.SetWatchErrorHandler(health.handleWatchError)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Setting watch error handler for %v informer failed", kind)
+	}
+
+	go func() {
+		ticker := time.NewTicker(informerHealthCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			health.updateMetric()
+
+			select {
+			case <-ticker.C:
+			case <-stopper:
+				return
+			}
+		}
+	}()
+}
+
+func (h *informerHealth) handleWatchError(r *cache.Reflector, err error) {
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	log.Warn().Err(err).Msgf("Watch for %v failed, not receiving changes until it has recovered", h.kind)
+
+	h.failedResourceVersion = h.informer.LastSyncResourceVersion()
+	h.failing = true
+
+	informerHealthy.With(prometheus.Labels{"type": h.kind}).Set(0)
+}
+
+// isHealthy returns true if the informer has synced and either never failed or received changes since its watch failed
+func (h *informerHealth) isHealthy() bool {
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if !h.informer.HasSynced() {
+		return false
+	}
+
+	if h.failing && h.informer.LastSyncResourceVersion() != h.failedResourceVersion {
+		log.Info().Msgf("Watch for %v has recovered", h.kind)
+		h.failing = false
+	}
+
+	return !h.failing
+}
+
+func (h *informerHealth) updateMetric() {
+
+	healthy := 0.0
+	if h.isHealthy() {
+		healthy = 1
+	}
+
+	informerHealthy.With(prometheus.Labels{"type": h.kind}).Set(healthy)
+}
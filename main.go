@@ -1,47 +1,196 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
 	"runtime"
+	"runtime/debug"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/alecthomas/kingpin"
 	foundation "github.com/estafette/estafette-foundation"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	v1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	k8sruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 )
 
+const annotationIngressClass string = "kubernetes.io/ingress.class"
+
 const annotationCloudflareDNS string = "estafette.io/cloudflare-dns"
 const annotationCloudflareHostnames string = "estafette.io/cloudflare-hostnames"
 const annotationCloudflareInternalHostnames string = "estafette.io/cloudflare-internal-hostnames"
+const annotationCloudflareInternalDNS string = "estafette.io/cloudflare-internal-dns"
+const annotationCloudflareInternalIPAddress string = "estafette.io/cloudflare-internal-ip-address"
 const annotationCloudflareProxy string = "estafette.io/cloudflare-proxy"
+const annotationCloudflareProxyAfter string = "estafette.io/cloudflare-proxy-after"
+const annotationCloudflareProxyPlans string = "estafette.io/cloudflare-proxy-plans"
 const annotationCloudflareUseOriginRecord string = "estafette.io/cloudflare-use-origin-record"
 const annotationCloudflareOriginRecordHostname string = "estafette.io/cloudflare-origin-record-hostname"
+const annotationCloudflareSRVRecords string = "estafette.io/cloudflare-srv-records"
+const annotationCloudflareNSRecords string = "estafette.io/cloudflare-ns-records"
+const annotationCloudflareCAARecords string = "estafette.io/cloudflare-caa-records"
+const annotationCloudflareLOCRecords string = "estafette.io/cloudflare-loc-records"
+const annotationCloudflareComment string = "estafette.io/cloudflare-comment"
+const annotationCloudflareCredentialsSecret string = "estafette.io/cloudflare-credentials-secret"
+const annotationCloudflareCNAMETarget string = "estafette.io/cloudflare-cname-target"
+const annotationCloudflareForceUpdate string = "estafette.io/cloudflare-force-update"
+const annotationCloudflareTTL string = "estafette.io/cloudflare-ttl"
+const annotationCloudflareRegion string = "estafette.io/cloudflare-region"
+const annotationCloudflareSSLMode string = "estafette.io/cloudflare-ssl-mode"
+const annotationCloudflareZoneSettings string = "estafette.io/cloudflare-zone-settings"
+const annotationCloudflareUseNodeExternalIP string = "estafette.io/cloudflare-use-node-external-ip"
+const annotationCloudflareRecordName string = "estafette.io/cloudflare-record-name"
+
+const defaultCloudflareComment string = "managed by estafette-cloudflare-dns"
 
 const annotationCloudflareState string = "estafette.io/cloudflare-state"
 
+const readinessPort int = 5001
+const reconcilePort int = 5002
+const admissionWebhookPort int = 5003
+
+// informerCacheSyncTimeout is how long to wait for an informer cache to sync before giving up, so tests can shorten it
+var informerCacheSyncTimeout = 5 * time.Minute
+
+var (
+	errInformerCacheSyncTimeout = errors.New("Informer cache did not sync in time")
+	errInformerCacheSyncStopped = errors.New("Informer got stopped before its cache synced")
+)
+
+// timeNow returns the current time, so tests can fake the clock
+var timeNow = time.Now
+
 // CloudflareState represents the state of the service at Cloudflare
 type CloudflareState struct {
 	Enabled              string `json:"enabled"`
 	Hostnames            string `json:"hostnames"`
 	InternalHostnames    string `json:"internalHostnames,omitempty"`
+	InternalDNS          string `json:"internalDns,omitempty"`
 	Proxy                string `json:"proxy"`
 	UseOriginRecord      string `json:"useOriginRecord"`
 	OriginRecordHostname string `json:"originRecordHostname"`
+	CNAMETarget          string `json:"cnameTarget,omitempty"`
 	IPAddress            string `json:"ipAddress"`
-	InternalIPAddress    string `json:"internalIpAddress,omitempty"`
+	// the load balancer ip addresses after the first one, which get a record next to the one for the first
+	AdditionalIPAddresses string `json:"additionalIpAddresses,omitempty"`
+	InternalIPAddress     string `json:"internalIpAddress,omitempty"`
+	TargetIsHostname      string `json:"targetIsHostname,omitempty"`
+	SRVRecords            string `json:"srvRecords,omitempty"`
+	NSRecords             string `json:"nsRecords,omitempty"`
+	CAARecords            string `json:"caaRecords,omitempty"`
+	LOCRecords            string `json:"locRecords,omitempty"`
+	Comment               string `json:"comment,omitempty"`
+	TTL                   string `json:"ttl,omitempty"`
+	Region                string `json:"region,omitempty"`
+	SSLMode               string `json:"sslMode,omitempty"`
+	ZoneSettings          string `json:"zoneSettings,omitempty"`
+
+	// how long new records stay dns only before they get proxied, and since when they are; empty if they're proxied right away
+	ProxyAfter   string `json:"proxyAfter,omitempty"`
+	DNSOnlySince string `json:"dnsOnlySince,omitempty"`
+
+	// the plans of the zones whose records get proxied, the others stay dns only; empty for any plan
+	ProxyPlans string `json:"proxyPlans,omitempty"`
+
+	// the hostname=name mappings of hostnames whose records get created under another name, which replaces them in the hostnames
+	RecordNames string `json:"recordNames,omitempty"`
+
+	// the zones the records for the hostnames have been upserted in, for operators to see where records go
+	ZoneName string `json:"zoneName,omitempty"`
+
+	// the records created for the object, so the ones that are no longer desired can be cleaned up precisely
+	Records []managedRecord `json:"records,omitempty"`
+}
+
+// managedRecord represents a dns record the controller created at Cloudflare for an object
+type managedRecord struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Content string `json:"content"`
+	Proxied bool   `json:"proxied,omitempty"`
+	TTL     int    `json:"ttl,omitempty"`
+
+	// the zone the record has been upserted in, to see where the records of an object spread over multiple zones went
+	Zone string `json:"zone,omitempty"`
+}
+
+// srvRecord represents an srv record as configured in the estafette.io/cloudflare-srv-records annotation
+type srvRecord struct {
+	Name string
+	Data SRVRecordData
+}
+
+// caaRecord represents a caa record as configured in the estafette.io/cloudflare-caa-records annotation
+type caaRecord struct {
+	Name string
+	Data CAARecordData
+}
+
+// locRecord represents a loc record as configured in the estafette.io/cloudflare-loc-records annotation
+type locRecord struct {
+	Name string
+	Data LOCRecordData
+}
+
+// structuredRecord represents a record whose content cloudflare derives from its structured data, like the srv and loc records configured in annotations
+type structuredRecord struct {
+	Type string
+	Name string
+	Data interface{}
+}
+
+// structuredRecordSet represents all srv or loc records configured for a single name, which are reconciled together
+type structuredRecordSet struct {
+	Type string
+	Name string
+	Data []interface{}
+}
+
+// nsRecord represents the delegation of a name to nameservers as configured in the estafette.io/cloudflare-ns-records annotation
+type nsRecord struct {
+	Name        string
+	Nameservers []string
+}
+
+// zoneSettingValue is a setting to apply to the zones of the hostnames of an object
+type zoneSettingValue struct {
+	Name  string
+	Value interface{}
 }
 
 var (
@@ -54,9 +203,108 @@ var (
 	goVersion = runtime.Version()
 )
 
+// reconcileMutex makes sure the poller and the /reconcile endpoint never run a pass at the same time
+var reconcileMutex sync.Mutex
+
+// reconcileLocks makes sure the watchers and the poller never reconcile the same object at the same time
+var reconcileLocks = newObjectLocks()
+
+// reconcileSlots caps the number of reconciles running at the same time across the watchers, the poller and the /reconcile endpoint; unlimited if nil
+var reconcileSlots chan struct{}
+
+// reconcileSummary holds the number of objects processed by a pass over all objects
+type reconcileSummary struct {
+	Services   int `json:"services"`
+	Ingresses  int `json:"ingresses"`
+	HTTPRoutes int `json:"httpRoutes"`
+	DNSRecords int `json:"dnsRecords"`
+	Failures   int `json:"failures"`
+}
+
+// zoneMissingObjects holds the objects for which a missing zone has already been logged
+var zoneMissingObjects sync.Map
+
+// originRecordZoneLookup is used to look up the zone for the {zone} placeholder of generated origin record hostnames; they don't get generated if nil
+var originRecordZoneLookup *Cloudflare
+
+// originRecordZones caches the zone names of the hostnames generated origin record hostnames are based on, so they stay the same across reconciles without a lookup each time
+var originRecordZones sync.Map
+
 var (
-	cfAPIKey   = kingpin.Flag("cloudflare-api-key", "The Cloudflare API key.").Envar("CF_API_KEY").Required().String()
-	cfAPIEmail = kingpin.Flag("cloudflare-api-email", "The Cloudflare API email address.").Envar("CF_API_EMAIL").Required().String()
+	cfAPIKey                 = kingpin.Flag("cloudflare-api-key", "The Cloudflare API key.").Envar("CF_API_KEY").Required().String()
+	cfAPIEmail               = kingpin.Flag("cloudflare-api-email", "The Cloudflare API email address.").Envar("CF_API_EMAIL").Required().String()
+	cfAPIKeySecondary        = kingpin.Flag("cloudflare-api-key-secondary", "A second Cloudflare API key to retry requests with if the primary one gets rejected, to rotate keys without downtime.").Envar("CF_API_KEY_SECONDARY").Default("").String()
+	cfAPIEmailSecondary      = kingpin.Flag("cloudflare-api-email-secondary", "The email address for the secondary Cloudflare API key; defaults to the primary email address.").Envar("CF_API_EMAIL_SECONDARY").Default("").String()
+	cfAccountID              = kingpin.Flag("cloudflare-account-id", "The Cloudflare account id to limit zone lookups to; considers zones in all accounts if empty.").Envar("CF_ACCOUNT_ID").Default("").String()
+	cfZoneID                 = kingpin.Flag("cloudflare-zone-id", "The id of the Cloudflare zone to manage all records in, skipping zone lookups; for tokens that are only allowed to access a single zone.").Envar("CF_ZONE_ID").Default("").String()
+	cfZoneName               = kingpin.Flag("cloudflare-zone-name", "The name of the zone set with --cloudflare-zone-id; if set, hostnames outside of it are treated as not matching any zone.").Envar("CF_ZONE_NAME").Default("").String()
+	defaultDomainSuffix      = kingpin.Flag("default-domain-suffix", "The domain to fill in for the {domain} placeholder in the hostnames annotations of services, like example.com.").Envar("DEFAULT_DOMAIN_SUFFIX").Default("").String()
+	defaultProxy             = kingpin.Flag("default-proxy", "Whether to proxy records through Cloudflare for objects without the estafette.io/cloudflare-proxy annotation.").Envar("DEFAULT_PROXY").Default("true").Enum("true", "false")
+	defaultTTL               = kingpin.Flag("default-ttl", "The ttl in seconds, or 1 for automatic, of records of objects without the estafette.io/cloudflare-ttl annotation; Cloudflare's default is used if 0.").Envar("DEFAULT_TTL").Default("0").Int()
+	defaultUseOriginRecord   = kingpin.Flag("default-use-origin-record", "Whether to create an origin record to point the hostnames at for objects without the estafette.io/cloudflare-use-origin-record annotation.").Envar("DEFAULT_USE_ORIGIN_RECORD").Default("false").Bool()
+	originRecordTemplate     = kingpin.Flag("origin-record-hostname-template", "The template to generate the origin record hostname from for objects that use an origin record without the estafette.io/cloudflare-origin-record-hostname annotation, like origin-{name}-{namespace}.{zone}; {zone} is the zone of the first hostname. Not generated if empty.").Envar("ORIGIN_RECORD_HOSTNAME_TEMPLATE").Default("").String()
+	cfAllowedZones           = kingpin.Flag("allowed-zones", "Comma-separated list of the Cloudflare zones records may be written to; hostnames in other zones are skipped. All zones are allowed if empty.").Envar("ALLOWED_ZONES").Default("").String()
+	cfHTTPProxy              = kingpin.Flag("cloudflare-http-proxy", "The url of the http proxy to send Cloudflare api requests through, like http://proxy.example.com:3128; the HTTPS_PROXY and NO_PROXY environment variables are honored if empty.").Envar("CF_HTTP_PROXY").Default("").String()
+	cfUserAgent              = kingpin.Flag("cloudflare-user-agent", "The User-Agent header to send with Cloudflare api requests; defaults to the app name and version.").Envar("CF_USER_AGENT").Default("").String()
+	cfRequireOwnershipMarker = kingpin.Flag("require-ownership-marker", "Only update or delete records that carry the controller's ownership marker in their comment.").Envar("CF_REQUIRE_OWNERSHIP_MARKER").Default("false").Bool()
+	cfScopeRecordLookups     = kingpin.Flag("scope-record-lookups", "Have the Cloudflare api only return records that carry the controller's ownership marker in their comment; requires --require-ownership-marker.").Envar("CF_SCOPE_RECORD_LOOKUPS").Default("false").Bool()
+	cfSkipPausedZones        = kingpin.Flag("skip-paused-zones", "Leave hostnames in zones that are paused in Cloudflare alone instead of only warning that their records aren't served.").Envar("CF_SKIP_PAUSED_ZONES").Default("false").Bool()
+
+	dnsRecordsCacheTTL = kingpin.Flag("dns-records-cache-ttl", "How long to cache dns record lookups to reduce the number of Cloudflare api calls; records are looked up again after they get modified, caching is disabled if 0.").Envar("DNS_RECORDS_CACHE_TTL").Default("0s").Duration()
+
+	namespace = kingpin.Flag("namespace", "The namespace to watch; watches all namespaces if empty.").Envar("WATCH_NAMESPACE").Default("").String()
+
+	ingressClass = kingpin.Flag("ingress-class", "The ingress class to reconcile ingresses for; reconciles ingresses of all classes if empty.").Envar("INGRESS_CLASS").Default("").String()
+
+	stateStorage            = kingpin.Flag("state-storage", "Where to store the state of managed objects, either in the estafette.io/cloudflare-state annotation on each object or in a configmap.").Envar("STATE_STORAGE").Default("annotation").Enum("annotation", "configmap")
+	stateConfigMapName      = kingpin.Flag("state-configmap-name", "The name of the configmap to store state in when --state-storage is configmap.").Envar("STATE_CONFIGMAP_NAME").Default("estafette-cloudflare-dns-state").String()
+	stateConfigMapNamespace = kingpin.Flag("state-configmap-namespace", "The namespace of the configmap to store state in; defaults to the namespace the controller runs in.").Envar("STATE_CONFIGMAP_NAMESPACE").Default("").String()
+
+	informerResyncPeriod = kingpin.Flag("informer-resync-period", "How often the informers replay all watched objects as update events; disabled if 0.").Envar("INFORMER_RESYNC_PERIOD").Default("0s").Duration()
+
+	pollerConcurrency       = kingpin.Flag("poller-concurrency", "The number of objects the safety-net poller processes in parallel.").Envar("POLLER_CONCURRENCY").Default("1").Int()
+	watcherConcurrency      = kingpin.Flag("watcher-concurrency", "The number of workers reconciling the objects that changed according to the watchers.").Envar("WATCHER_CONCURRENCY").Default("1").Int()
+	maxConcurrentReconciles = kingpin.Flag("max-concurrent-reconciles", "The maximum number of objects reconciled at the same time by the watchers, the poller and the /reconcile endpoint together; unlimited if 0.").Envar("MAX_CONCURRENT_RECONCILES").Default("0").Int()
+
+	jitterFraction = kingpin.Flag("jitter-fraction", "How far the sleep between poller runs and connectivity checks may deviate from its interval at random, as a fraction of that interval between 0 and 1; disabled if 0.").Envar("JITTER_FRACTION").Default("0.25").Float64()
+
+	logReconcileDiff   = kingpin.Flag("log-reconcile-diff", "Log the state fields that differ between the desired and stored state at debug level before reconciling an object.").Envar("LOG_RECONCILE_DIFF").Default("false").Bool()
+	driftCheckInterval = kingpin.Flag("drift-check-interval", "How often to compare the actual records at Cloudflare with the desired state of all objects and force an update for the ones that drifted; disabled if 0.").Envar("DRIFT_CHECK_INTERVAL").Default("0s").Duration()
+
+	disableProxy = kingpin.Flag("disable-proxy", "Emergency override that turns off proxying for all managed records regardless of their annotations, to bypass Cloudflare; annotations apply again once unset.").Envar("DISABLE_PROXY").Default("false").Bool()
+
+	authoritativePoller = kingpin.Flag("authoritative-poller", "Have the poller delete the records carrying the ownership marker whose name isn't used by any service, ingress or httproute anymore; requires --require-ownership-marker and watching all namespaces.").Envar("AUTHORITATIVE_POLLER").Default("false").Bool()
+
+	reconcileToken = kingpin.Flag("reconcile-token", "The shared secret to pass as bearer token to the POST /reconcile endpoint that triggers an immediate pass over all objects; the endpoint is disabled if empty.").Envar("RECONCILE_TOKEN").Default("").String()
+
+	once = kingpin.Flag("once", "Reconcile all objects a single time and exit, with a non-zero exit code if any of them failed.").Envar("ONCE").Default("false").Bool()
+
+	enableHTTPRoutes = kingpin.Flag("enable-httproutes", "Watch Gateway API HTTPRoute objects; requires the Gateway API CRDs to be installed.").Envar("ENABLE_HTTPROUTES").Default("false").Bool()
+
+	enableCredentialsSecrets   = kingpin.Flag("enable-credentials-secrets", "Look up the Cloudflare credentials of services and ingresses in the secret named by their estafette.io/cloudflare-credentials-secret annotation or by --namespace-credentials-secret; requires permission to read secrets.").Envar("ENABLE_CREDENTIALS_SECRETS").Default("false").Bool()
+	namespaceCredentialsSecret = kingpin.Flag("namespace-credentials-secret", "The name of the secret with the Cloudflare credentials for the services and ingresses in its namespace that lack the estafette.io/cloudflare-credentials-secret annotation; the global credentials are used in namespaces without it.").Envar("NAMESPACE_CREDENTIALS_SECRET").Default("").String()
+
+	enableAdmissionWebhook   = kingpin.Flag("enable-admission-webhook", "Serve a validating admission webhook on port 5003 that rejects services and ingresses with malformed estafette.io/cloudflare-* annotations.").Envar("ENABLE_ADMISSION_WEBHOOK").Default("false").Bool()
+	admissionWebhookCertFile = kingpin.Flag("admission-webhook-cert-file", "The tls certificate the admission webhook serves, issued for the dns name of its service.").Envar("ADMISSION_WEBHOOK_CERT_FILE").Default("/etc/webhook/certs/tls.crt").String()
+	admissionWebhookKeyFile  = kingpin.Flag("admission-webhook-key-file", "The private key of the admission webhook certificate.").Envar("ADMISSION_WEBHOOK_KEY_FILE").Default("/etc/webhook/certs/tls.key").String()
+
+	runCommand = kingpin.Command("run", "Watch services, ingresses and httproutes and manage their records at Cloudflare.").Default()
+
+	exportCommand  = kingpin.Command("export", "Export all records of a zone in BIND format, to back them up.")
+	exportZoneName = exportCommand.Flag("zone-name", "The name of the zone to export the records of.").Required().String()
+	exportFile     = exportCommand.Flag("file", "The file to write the exported records to.").Required().String()
+
+	importCommand  = kingpin.Command("import", "Import records in BIND format into a zone, to restore a backup.")
+	importZoneName = importCommand.Flag("zone-name", "The name of the zone to import the records into.").Required().String()
+	importFile     = importCommand.Flag("file", "The file with the records to import.").Required().ExistingFile()
+	importProxied  = importCommand.Flag("proxied", "Proxy the imported A, AAAA and CNAME records; the BIND format doesn't include the proxy setting.").Default("false").Bool()
+
+	reconcileCommand        = kingpin.Command("reconcile", "Reconcile a single service or ingress once and exit, to troubleshoot its records.")
+	reconcileNamespace      = reconcileCommand.Flag("object-namespace", "The namespace of the object to reconcile.").Short('n').Default("default").String()
+	reconcileServiceCommand = reconcileCommand.Command("service", "Reconcile a single service.")
+	reconcileServiceName    = reconcileServiceCommand.Arg("name", "The name of the service to reconcile.").Required().String()
+	reconcileIngressCommand = reconcileCommand.Command("ingress", "Reconcile a single ingress.")
+	reconcileIngressName    = reconcileIngressCommand.Arg("name", "The name of the ingress to reconcile.").Required().String()
 
 	// seed random number
 	r = rand.New(rand.NewSource(time.Now().UnixNano()))
@@ -65,31 +313,207 @@ var (
 	dnsRecordsTotals = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "estafette_cloudflare_dns_record_totals",
-			Help: "Number of updated Cloudflare dns records.",
+			Help: "Number of created, updated or deleted Cloudflare dns records.",
 		},
 		[]string{"namespace", "status", "initiator", "type"},
 	)
+
+	invalidHostnamesTotals = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "estafette_cloudflare_dns_invalid_hostname_totals",
+			Help: "Number of hostnames skipped because they are not valid as dns record.",
+		},
+		[]string{"namespace", "reason"},
+	)
+
+	reconcilePanicsTotals = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "estafette_cloudflare_dns_reconcile_panic_totals",
+			Help: "Number of reconciles of a single object that panicked and were recovered from.",
+		},
+		[]string{"namespace", "type"},
+	)
+
+	deleteFailuresTotals = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "estafette_cloudflare_dns_delete_failure_totals",
+			Help: "Number of Cloudflare dns records that failed to get deleted and are retried.",
+		},
+		[]string{"namespace", "type"},
+	)
+
+	driftTotals = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "estafette_cloudflare_dns_drift_totals",
+			Help: "Number of objects whose records at Cloudflare were found to differ from their desired state.",
+		},
+		[]string{"namespace", "type"},
+	)
+
+	stateDecodeFailuresTotals = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "estafette_cloudflare_dns_state_decode_failure_totals",
+			Help: "Number of stored states that failed to deserialize and were reset, forcing their records to be upserted again.",
+		},
+		[]string{"namespace", "type"},
+	)
+
+	orphanedRecordsTotals = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "estafette_cloudflare_dns_orphaned_record_totals",
+			Help: "Number of Cloudflare dns records deleted by the authoritative poller because no object uses their name anymore.",
+		},
+		[]string{"zone"},
+	)
+
+	// define prometheus gauge
+	managedDNSRecords = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "estafette_cloudflare_dns_managed_records",
+			Help: "Number of Cloudflare dns records currently managed by this controller.",
+		},
+		[]string{"zone"},
+	)
+
+	apiRateLimitRemaining = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "estafette_cloudflare_dns_api_rate_limit_remaining",
+			Help: "Number of requests left in the Cloudflare api rate limit budget, as reported in the X-RateLimit-Remaining header of the last response that had it.",
+		},
+	)
+
+	informerHealthy = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "estafette_cloudflare_dns_informer_healthy",
+			Help: "Whether the informer for a type of object has synced and its watch receives changes, 1 if so and 0 otherwise.",
+		},
+		[]string{"type"},
+	)
+
+	// define prometheus histogram
+	dnsRecordAgeSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "estafette_cloudflare_dns_record_age_seconds",
+			Help:    "Time since managed Cloudflare dns records were last modified, observed when they get upserted; records that stay old for long may belong to dead configuration.",
+			Buckets: []float64{3600, 86400, 604800, 2592000, 7776000, 15552000, 31536000},
+		},
+		[]string{"zone"},
+	)
 )
 
 func init() {
 	// Metrics have to be registered to be exposed:
 	prometheus.MustRegister(dnsRecordsTotals)
+	prometheus.MustRegister(invalidHostnamesTotals)
+	prometheus.MustRegister(reconcilePanicsTotals)
+	prometheus.MustRegister(deleteFailuresTotals)
+	prometheus.MustRegister(driftTotals)
+	prometheus.MustRegister(stateDecodeFailuresTotals)
+	prometheus.MustRegister(orphanedRecordsTotals)
+	prometheus.MustRegister(managedDNSRecords)
+	prometheus.MustRegister(informerHealthy)
+	prometheus.MustRegister(apiRateLimitRemaining)
+	prometheus.MustRegister(dnsRecordAgeSeconds)
 }
 
 func main() {
 
 	// parse command line parameters
-	kingpin.Parse()
+	command := kingpin.Parse()
 
 	// init log format from envvar ESTAFETTE_LOG_FORMAT
 	foundation.InitLoggingFromEnv(foundation.NewApplicationInfo(appgroup, app, version, branch, revision, buildDate))
 
 	ctx := context.Background()
 
+	if *defaultTTL < 0 {
+		log.Fatal().Msgf("Default ttl %v should be a number of seconds, 1 for automatic or 0 to leave it to Cloudflare", *defaultTTL)
+	}
+	if *jitterFraction < 0 || *jitterFraction > 1 {
+		log.Fatal().Msgf("Jitter fraction %v should be between 0 and 1", *jitterFraction)
+	}
+
 	// init /liveness endpoint
 	foundation.InitLiveness()
 
 	cf := New(APIAuthentication{Key: *cfAPIKey, Email: *cfAPIEmail})
+	httpProxy, err := getHTTPProxy(*cfHTTPProxy)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid Cloudflare http proxy url")
+	}
+	cf.restClient = newRealRESTClient(getUserAgent(*cfUserAgent, app, version), httpProxy)
+	if *cfAPIKeySecondary != "" {
+		secondaryEmail := *cfAPIEmailSecondary
+		if secondaryEmail == "" {
+			secondaryEmail = *cfAPIEmail
+		}
+		cf.secondaryAuthentication = &APIAuthentication{Key: *cfAPIKeySecondary, Email: secondaryEmail}
+	}
+	cf.accountID = *cfAccountID
+	if *cfZoneID != "" {
+		cf.zone = &Zone{ID: *cfZoneID, Name: toASCIIHostname(*cfZoneName)}
+	}
+	if *cfAllowedZones != "" {
+		cf.allowedZones = splitHostnames(*cfAllowedZones)
+	}
+	if *cfRequireOwnershipMarker {
+		cf.ownershipMarker = defaultCloudflareComment
+	}
+	if *cfScopeRecordLookups {
+		if !*cfRequireOwnershipMarker {
+			log.Fatal().Msg("Scoping record lookups requires --require-ownership-marker")
+		}
+		cf.scopeRecordLookups = true
+	}
+	cf.skipPausedZones = *cfSkipPausedZones
+	if *originRecordTemplate != "" {
+		originRecordZoneLookup = cf
+	}
+	if *authoritativePoller {
+		if !*cfRequireOwnershipMarker {
+			log.Fatal().Msg("An authoritative poller requires --require-ownership-marker, to only delete records created by the controller")
+		}
+		if *namespace != "" {
+			log.Fatal().Msg("An authoritative poller requires watching all namespaces, since records of objects in other namespaces would look orphaned")
+		}
+	}
+	if *disableProxy {
+		log.Warn().Msg("Proxying is disabled for all managed records by --disable-proxy, ignoring the proxy annotations")
+	}
+	if *dnsRecordsCacheTTL > 0 {
+		cf.dnsRecordsCache = newDNSRecordsCache(*dnsRecordsCacheTTL)
+	}
+
+	// fail fast on misconfigured credentials instead of on the first reconcile
+	err = cf.VerifyCredentials()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed verifying Cloudflare credentials, check the api key and email address")
+	}
+
+	// back up or restore the records of a zone instead of running the controller if requested
+	switch command {
+	case exportCommand.FullCommand():
+		err = exportZone(cf, *exportZoneName, *exportFile)
+		if err != nil {
+			log.Fatal().Err(err).Msgf("Exporting records of zone %v failed", *exportZoneName)
+		}
+		return
+	case importCommand.FullCommand():
+		err = importZone(cf, *importZoneName, *importFile, *importProxied)
+		if err != nil {
+			log.Fatal().Err(err).Msgf("Importing records into zone %v failed", *importZoneName)
+		}
+		return
+	}
+
+	if *maxConcurrentReconciles > 0 {
+		reconcileSlots = make(chan struct{}, *maxConcurrentReconciles)
+	}
+
+	// init /readiness endpoint reflecting cloudflare connectivity
+	if command == runCommand.FullCommand() && !*once {
+		initReadiness(cf)
+	}
 
 	// creates the in-cluster config
 	kubeClientConfig, err := rest.InClusterConfig()
@@ -102,13 +526,87 @@ func main() {
 		log.Fatal().Err(err).Msg("Failed creating kubernetes clientset")
 	}
 
-	// create the shared informer factory and use the client to connect to Kubernetes API
-	factory := informers.NewSharedInformerFactory(kubeClientset, 0)
-
 	// create a channel to stop the shared informers gracefully
 	stopper := make(chan struct{})
 	defer close(stopper)
 
+	// look up nodes for NodePort services pointing at the external ip address of a node
+	if err := initNodeLister(ctx, kubeClientset, stopper); err != nil {
+		log.Fatal().Err(err).Msg("Failed starting node lister")
+	}
+
+	// look up the credentials of objects that belong to another cloudflare account if enabled, since that requires reading secrets
+	if *enableCredentialsSecrets {
+		if err := initSecretLister(ctx, kubeClientset, stopper); err != nil {
+			log.Fatal().Err(err).Msg("Failed starting secret lister")
+		}
+	}
+
+	// store state in a configmap instead of in an annotation on each object if configured
+	if *stateStorage == "configmap" {
+		stateConfigMapNamespaceOrDefault := *stateConfigMapNamespace
+		if stateConfigMapNamespaceOrDefault == "" {
+			stateConfigMapNamespaceOrDefault = getControllerNamespace()
+		}
+		stateStore = newConfigMapStateStore(kubeClientset, stateConfigMapNamespaceOrDefault, *stateConfigMapName)
+	}
+
+	// creates the dynamic client for resources without a typed clientset
+	dynamicClient, err := dynamic.NewForConfig(kubeClientConfig)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed creating kubernetes dynamic client")
+	}
+
+	// reconcile DNSRecord objects only if their custom resource definition is installed
+	dnsRecordResourcesInstalled = isDNSRecordResourceInstalled(kubeClientset.Discovery())
+	if dnsRecordResourcesInstalled {
+		log.Info().Msgf("Custom resource definition for %v is installed, reconciling DNSRecord objects", dnsRecordsResource.GroupResource())
+	}
+
+	// create an event recorder to make the controller's actions visible on the kubernetes objects
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClientset.CoreV1().Events("")})
+	defer eventBroadcaster.Shutdown()
+	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "estafette-cloudflare-dns"})
+
+	// reconcile a single object instead of running the controller if requested
+	reconcileKind, reconcileName := "", ""
+	switch command {
+	case reconcileServiceCommand.FullCommand():
+		reconcileKind, reconcileName = "service", *reconcileServiceName
+	case reconcileIngressCommand.FullCommand():
+		reconcileKind, reconcileName = "ingress", *reconcileIngressName
+	}
+	if reconcileKind != "" {
+		status, changes, err := reconcileNamedObject(ctx, cf, kubeClientset, recorder, reconcileKind, reconcileName, *reconcileNamespace)
+		fmt.Printf("%v %v.%v: %v with %v change(s)\n", reconcileKind, reconcileName, *reconcileNamespace, status, changes)
+		if err == nil && status == "zone-missing" {
+			err = fmt.Errorf("No Cloudflare zone matches the hostnames of %v %v.%v", reconcileKind, reconcileName, *reconcileNamespace)
+		}
+		if err != nil {
+			eventBroadcaster.Shutdown()
+			log.Fatal().Err(err).Msgf("Reconciling %v %v.%v failed", reconcileKind, reconcileName, *reconcileNamespace)
+		}
+		return
+	}
+
+	// reconcile all objects a single time without watching them, for running as a one-shot job
+	if *once {
+		summary := reconcileAll(ctx, cf, kubeClientset, dynamicClient, recorder, &sync.WaitGroup{}, "once")
+		if exitCode := getOnceExitCode(summary); exitCode != 0 {
+			eventBroadcaster.Shutdown()
+			log.Error().Msgf("Reconciling failed for %v object(s)", summary.Failures)
+			os.Exit(exitCode)
+		}
+
+		log.Info().Msg("Reconciled all objects successfully")
+		return
+	}
+
+	// create the shared informer factory and use the client to connect to Kubernetes API
+	factory := informers.NewSharedInformerFactoryWithOptions(kubeClientset, *informerResyncPeriod, informers.WithNamespace(*namespace))
+	dynamicFactory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, *informerResyncPeriod, *namespace, nil)
+
 	// handle kubernetes API crashes
 	defer k8sruntime.HandleCrash()
 
@@ -116,65 +614,62 @@ func main() {
 
 	gracefulShutdown, waitGroup := foundation.InitGracefulShutdownHandling()
 
-	// watch services for all namespaces
-	watchServices(ctx, cf, kubeClientset, factory, waitGroup, stopper)
+	// watch services for the configured namespace or all namespaces
+	if err := watchServices(ctx, cf, kubeClientset, recorder, factory, waitGroup, stopper); err != nil {
+		log.Fatal().Err(err).Msg("Failed watching services")
+	}
 
-	// watch ingresses for all namespaces
-	watchIngresses(ctx, cf, kubeClientset, factory, waitGroup, stopper)
+	// watch ingresses for the configured namespace or all namespaces
+	if err := watchIngresses(ctx, cf, kubeClientset, recorder, factory, waitGroup, stopper); err != nil {
+		log.Fatal().Err(err).Msg("Failed watching ingresses")
+	}
 
-	// loop services and ingresses at large intervals as safety net in case the informers miss something
-	go func(waitGroup *sync.WaitGroup) {
-		// loop indefinitely
-		for {
-			// get services for all namespaces
-			log.Info().Msg("Listing services for all namespaces...")
-			services, err := kubeClientset.CoreV1().Services("").List(ctx, metav1.ListOptions{})
-			if err != nil {
-				log.Error().Err(err).Msg("ListServices call failed")
-			}
-			log.Info().Msgf("Cluster has %v services", len(services.Items))
+	// watch httproutes for the configured namespace or all namespaces
+	if *enableHTTPRoutes {
+		if err := watchHTTPRoutes(ctx, cf, dynamicClient, recorder, dynamicFactory, waitGroup, stopper); err != nil {
+			log.Fatal().Err(err).Msg("Failed watching httproutes")
+		}
+	}
 
-			// loop all services
-			if services != nil && services.Items != nil {
-				for _, service := range services.Items {
-					waitGroup.Add(1)
-					status, err := processService(ctx, cf, kubeClientset, &service, "poller")
-					dnsRecordsTotals.With(prometheus.Labels{"namespace": service.Namespace, "status": status, "initiator": "poller", "type": "service"}).Inc()
-					waitGroup.Done()
+	// watch dnsrecords for the configured namespace or all namespaces
+	if dnsRecordResourcesInstalled {
+		if err := watchDNSRecords(ctx, cf, dynamicClient, recorder, dynamicFactory, waitGroup, stopper); err != nil {
+			log.Fatal().Err(err).Msg("Failed watching dnsrecords")
+		}
+	}
 
-					if err != nil {
-						log.Error().Err(err).Msgf("Processing service %v.%v failed", service.Name, service.Namespace)
-						continue
-					}
-				}
-			}
+	// init /reconcile endpoint to trigger a pass over all objects on demand
+	if *reconcileToken != "" {
+		initReconcileEndpoint(*reconcileToken, func() reconcileSummary {
+			return reconcileAll(ctx, cf, kubeClientset, dynamicClient, recorder, waitGroup, "endpoint")
+		})
+	}
 
-			// get ingresses for all namespaces
-			log.Info().Msg("Listing ingresses for all namespaces...")
-			ingresses, err := kubeClientset.NetworkingV1().Ingresses("").List(ctx, metav1.ListOptions{})
-			if err != nil {
-				log.Error().Err(err).Msg("ListIngresses call failed")
-			}
-			log.Info().Msgf("Cluster has %v ingresses", len(ingresses.Items))
+	// reject malformed annotations on apply if enabled, since the api server has to be configured to call the webhook
+	if *enableAdmissionWebhook {
+		initAdmissionWebhook(*admissionWebhookCertFile, *admissionWebhookKeyFile)
+	}
 
-			// loop all ingresses
-			if ingresses != nil && ingresses.Items != nil {
-				for _, ingress := range ingresses.Items {
+	// check for records changed outside of the controller if enabled, since that costs extra api calls
+	if *driftCheckInterval > 0 {
+		initDriftChecker(ctx, cf, kubeClientset, dynamicClient, *driftCheckInterval)
+	}
 
-					waitGroup.Add(1)
-					status, err := processIngress(ctx, cf, kubeClientset, &ingress, "poller")
-					dnsRecordsTotals.With(prometheus.Labels{"namespace": ingress.Namespace, "status": status, "initiator": "poller", "type": "ingress"}).Inc()
-					waitGroup.Done()
+	// loop services and ingresses at large intervals as safety net in case the informers miss something
+	go func(waitGroup *sync.WaitGroup) {
+		// loop indefinitely
+		for {
+			reconcileMutex.Lock()
+			reconcileAll(ctx, cf, kubeClientset, dynamicClient, recorder, waitGroup, "poller")
 
-					if err != nil {
-						log.Error().Err(err).Msgf("Processing ingress %v.%v failed", ingress.Name, ingress.Namespace)
-						continue
-					}
-				}
+			// clean up the records of objects that got deleted without the controller noticing if enabled
+			if *authoritativePoller {
+				collectOrphanedRecords(ctx, cf, kubeClientset, dynamicClient)
 			}
+			reconcileMutex.Unlock()
 
 			// sleep random time around 900 seconds
-			sleepTime := applyJitter(900)
+			sleepTime := applyJitter(900, *jitterFraction)
 			log.Info().Msgf("Sleeping for %v seconds...", sleepTime)
 			time.Sleep(time.Duration(sleepTime) * time.Second)
 		}
@@ -183,632 +678,3474 @@ func main() {
 	foundation.HandleGracefulShutdown(gracefulShutdown, waitGroup)
 }
 
-func applyJitter(input int) (output int) {
+// getOnceExitCode returns the exit code for a single pass over all objects, which is non-zero if any of them failed, so a job running it shows up as failed
+func getOnceExitCode(summary reconcileSummary) int {
 
-	deviation := int(0.25 * float64(input))
+	if summary.Failures > 0 {
+		return 1
+	}
 
-	return input - deviation + r.Intn(2*deviation)
+	return 0
 }
 
-func getDesiredServiceState(service *v1.Service) (state CloudflareState) {
-
-	var ok bool
-
-	state.Enabled, ok = service.Annotations[annotationCloudflareDNS]
-	if !ok {
-		state.Enabled = "false"
-	}
-	state.Hostnames, ok = service.Annotations[annotationCloudflareHostnames]
-	if !ok {
-		state.Hostnames = ""
-	}
-	state.InternalHostnames, ok = service.Annotations[annotationCloudflareInternalHostnames]
-	if !ok {
-		state.InternalHostnames = ""
-	}
-	state.Proxy, ok = service.Annotations[annotationCloudflareProxy]
-	if !ok {
-		state.Proxy = "true"
-	}
-	state.UseOriginRecord, ok = service.Annotations[annotationCloudflareUseOriginRecord]
-	if !ok {
-		state.UseOriginRecord = "false"
-	}
-	state.OriginRecordHostname, ok = service.Annotations[annotationCloudflareOriginRecordHostname]
-	if !ok {
-		state.OriginRecordHostname = ""
-	}
+// reconcileNamedObject fetches a single service or ingress and processes it once
+func reconcileNamedObject(ctx context.Context, cf *Cloudflare, kubeClientset kubernetes.Interface, recorder record.EventRecorder, kind, name, namespace string) (status string, changes int, err error) {
 
-	if service.Spec.Type == "LoadBalancer" && len(service.Status.LoadBalancer.Ingress) > 0 {
-		state.IPAddress = service.Status.LoadBalancer.Ingress[0].IP
-	}
-	if service.Spec.ClusterIP != "" {
-		state.InternalIPAddress = service.Spec.ClusterIP
+	switch kind {
+	case "service":
+		service, err := kubeClientset.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "failed", 0, err
+		}
+		return reconcileObject(kind, name, namespace, func() (string, int, error) {
+			return processService(ctx, cf, kubeClientset, recorder, service, "cli")
+		})
+	case "ingress":
+		ingress, err := kubeClientset.NetworkingV1().Ingresses(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "failed", 0, err
+		}
+		return reconcileObject(kind, name, namespace, func() (string, int, error) {
+			return processIngress(ctx, cf, kubeClientset, recorder, ingress, "cli")
+		})
 	}
 
-	return
+	return "failed", 0, fmt.Errorf("Reconciling objects of kind %v is not supported", kind)
 }
 
-func getCurrentServiceState(service *v1.Service) (state CloudflareState) {
+// reconcileAll processes all services, ingresses, httproutes and dnsrecords in the watched namespaces and returns the number of them that failed
+func reconcileAll(ctx context.Context, cf *Cloudflare, kubeClientset kubernetes.Interface, dynamicClient dynamic.Interface, recorder record.EventRecorder, waitGroup *sync.WaitGroup, initiator string) (summary reconcileSummary) {
 
-	// get state stored in annotations if present or set to empty struct
-	cloudflareStateString, ok := service.Annotations[annotationCloudflareState]
-	if !ok {
-		// couldn't find saved state, setting to default struct
-		state = CloudflareState{}
-		return
+	namespaceDescription := "all namespaces"
+	if *namespace != "" {
+		namespaceDescription = "namespace " + *namespace
 	}
 
-	if err := json.Unmarshal([]byte(cloudflareStateString), &state); err != nil {
-		// couldn't deserialize, setting to default struct
-		state = CloudflareState{}
-		return
-	}
+	// the objects get processed by a pool of workers, so keep track of failures and managed records in a concurrency safe way
+	var failures int32
+	managedRecords := newManagedRecordsCounter()
+	jobs := []func(){}
 
-	// return deserialized state
-	return
-}
+	// get services for the configured namespace or all namespaces
+	log.Info().Msgf("Listing services for %v...", namespaceDescription)
+	services, err := kubeClientset.CoreV1().Services(*namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Error().Err(err).Msg("ListServices call failed")
+		failures++
+	}
 
-func makeServiceChanges(ctx context.Context, cf *Cloudflare, kubeClientset *kubernetes.Clientset, service *v1.Service, initiator string, desiredState, currentState CloudflareState) (status string, err error) {
+	// loop all services
+	if services != nil && services.Items != nil {
+		log.Info().Msgf("Cluster has %v services", len(services.Items))
+		summary.Services = len(services.Items)
+
+		for i := range services.Items {
+			service := &services.Items[i]
+			jobs = append(jobs, func() {
+				waitGroup.Add(1)
+				var finalState CloudflareState
+				status, changes, err := reconcileObject("service", service.Name, service.Namespace, func() (string, int, error) {
+					return processServiceWithFinalState(ctx, cf, kubeClientset, recorder, service, initiator, &finalState)
+				})
+				countManagedRecords(finalState, managedRecords)
+				countDNSRecordsTotals(service.Namespace, status, initiator, "service", changes)
+				waitGroup.Done()
+
+				if status == "zone-missing" {
+					atomic.AddInt32(&failures, 1)
+				}
+				if err != nil {
+					log.Error().Err(err).Msgf("Processing service %v.%v failed", service.Name, service.Namespace)
+					atomic.AddInt32(&failures, 1)
+				}
+			})
+		}
+	}
 
-	status = "failed"
-	hasChanges := false
+	// get ingresses for the configured namespace or all namespaces
+	log.Info().Msgf("Listing ingresses for %v...", namespaceDescription)
+	ingresses, err := kubeClientset.NetworkingV1().Ingresses(*namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Error().Err(err).Msg("ListIngresses call failed")
+		failures++
+	}
 
-	// check if service has estafette.io/cloudflare-dns annotation and it's value is true and
-	// check if service has estafette.io/cloudflare-hostnames annotation and it's value is not empty and
-	// check if type equals LoadBalancer and
-	// check if LoadBalancer has an ip address
-	if desiredState.Enabled == "true" && len(desiredState.Hostnames) > 0 && desiredState.IPAddress != "" {
+	// loop all ingresses
+	if ingresses != nil && ingresses.Items != nil {
+		log.Info().Msgf("Cluster has %v ingresses", len(ingresses.Items))
+		summary.Ingresses = len(ingresses.Items)
+
+		for i := range ingresses.Items {
+			ingress := &ingresses.Items[i]
+			jobs = append(jobs, func() {
+				waitGroup.Add(1)
+				var finalState CloudflareState
+				status, changes, err := reconcileObject("ingress", ingress.Name, ingress.Namespace, func() (string, int, error) {
+					return processIngressWithFinalState(ctx, cf, kubeClientset, recorder, ingress, initiator, &finalState)
+				})
+				countManagedRecords(finalState, managedRecords)
+				countDNSRecordsTotals(ingress.Namespace, status, initiator, "ingress", changes)
+				waitGroup.Done()
+
+				if status == "zone-missing" {
+					atomic.AddInt32(&failures, 1)
+				}
+				if err != nil {
+					log.Error().Err(err).Msgf("Processing ingress %v.%v failed", ingress.Name, ingress.Namespace)
+					atomic.AddInt32(&failures, 1)
+				}
+			})
+		}
+	}
 
-		// update dns record if anything has changed compared to the stored state
-		if desiredState.IPAddress != currentState.IPAddress ||
-			desiredState.Hostnames != currentState.Hostnames ||
-			desiredState.Proxy != currentState.Proxy ||
-			desiredState.UseOriginRecord != currentState.UseOriginRecord ||
-			desiredState.OriginRecordHostname != currentState.OriginRecordHostname {
+	// get httproutes for the configured namespace or all namespaces
+	if *enableHTTPRoutes {
+		log.Info().Msgf("Listing httproutes for %v...", namespaceDescription)
+		routes, err := dynamicClient.Resource(httpRoutesResource).Namespace(*namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			log.Error().Err(err).Msg("ListHTTPRoutes call failed")
+			failures++
+		}
 
-			hasChanges = true
+		// loop all httproutes
+		if routes != nil && routes.Items != nil {
+			log.Info().Msgf("Cluster has %v httproutes", len(routes.Items))
+			summary.HTTPRoutes = len(routes.Items)
 
-			// if use origin is enabled, create an A record for the origin
-			if desiredState.UseOriginRecord == "true" && desiredState.OriginRecordHostname != "" {
+			for i := range routes.Items {
+				route := &routes.Items[i]
+				jobs = append(jobs, func() {
+					// the desired state retrieves the gateway of the route, so only determine it once per pass
+					desiredState := getDesiredHTTPRouteState(ctx, dynamicClient, route)
 
-				log.Info().Msgf("[%v] Service %v.%v - Upserting origin dns record %v (A) to ip address %v...", initiator, service.Name, service.Namespace, desiredState.OriginRecordHostname, desiredState.IPAddress)
+					waitGroup.Add(1)
+					var finalState CloudflareState
+					status, changes, err := reconcileObject("httproute", route.GetName(), route.GetNamespace(), func() (string, int, error) {
+						return processHTTPRouteWithDesiredState(ctx, cf, dynamicClient, recorder, route, initiator, desiredState, &finalState)
+					})
+					countManagedRecords(finalState, managedRecords)
+					countDNSRecordsTotals(route.GetNamespace(), status, initiator, "httproute", changes)
+					waitGroup.Done()
 
-				_, err := cf.UpsertDNSRecord("A", desiredState.OriginRecordHostname, desiredState.IPAddress, false)
-				if err != nil {
-					log.Error().Err(err).Msgf("[%v] Service %v.%v - Upserting origin dns record %v (A) to ip address %v failed", initiator, service.Name, service.Namespace, desiredState.OriginRecordHostname, desiredState.IPAddress)
-					return status, err
-				}
+					if status == "zone-missing" {
+						atomic.AddInt32(&failures, 1)
+					}
+					if err != nil {
+						log.Error().Err(err).Msgf("Processing httproute %v.%v failed", route.GetName(), route.GetNamespace())
+						atomic.AddInt32(&failures, 1)
+					}
+				})
+			}
+		}
+	}
+
+	// get dnsrecords for the configured namespace or all namespaces
+	if dnsRecordResourcesInstalled {
+		log.Info().Msgf("Listing dnsrecords for %v...", namespaceDescription)
+		dnsRecords, err := dynamicClient.Resource(dnsRecordsResource).Namespace(*namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			log.Error().Err(err).Msg("ListDNSRecords call failed")
+			failures++
+		}
+
+		// loop all dnsrecords
+		if dnsRecords != nil && dnsRecords.Items != nil {
+			log.Info().Msgf("Cluster has %v dnsrecords", len(dnsRecords.Items))
+			summary.DNSRecords = len(dnsRecords.Items)
+
+			for i := range dnsRecords.Items {
+				dnsRecord := &dnsRecords.Items[i]
+				jobs = append(jobs, func() {
+					waitGroup.Add(1)
+					status, changes, err := reconcileObject("dnsrecord", dnsRecord.GetName(), dnsRecord.GetNamespace(), func() (string, int, error) {
+						return processDNSRecordResource(ctx, cf, dynamicClient, recorder, dnsRecord, initiator)
+					})
+					countDNSRecordsTotals(dnsRecord.GetNamespace(), status, initiator, "dnsrecord", changes)
+					waitGroup.Done()
+
+					if status == "zone-missing" {
+						atomic.AddInt32(&failures, 1)
+					}
+					if err != nil {
+						log.Error().Err(err).Msgf("Processing dnsrecord %v.%v failed", dnsRecord.GetName(), dnsRecord.GetNamespace())
+						atomic.AddInt32(&failures, 1)
+					}
+				})
+			}
+		}
+	}
+
+	runJobs(jobs, *pollerConcurrency)
+
+	setManagedDNSRecordsGauge(managedRecords)
+
+	summary.Failures = int(failures)
+
+	return summary
+}
+
+// countDNSRecordsTotals increments the dns record totals by the number of records changed for an object; a failed object counts as one record so failures stay visible
+func countDNSRecordsTotals(namespace, status, initiator, objectType string, changes int) {
+
+	if status == "failed" && changes == 0 {
+		changes = 1
+	}
+
+	dnsRecordsTotals.With(prometheus.Labels{"namespace": namespace, "status": status, "initiator": initiator, "type": objectType}).Add(float64(changes))
+}
+
+// runJobs runs the jobs with at most concurrency of them at the same time and returns once all of them are done
+func runJobs(jobs []func(), concurrency int) {
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	jobsChannel := make(chan func())
+
+	var workers sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for job := range jobsChannel {
+				job()
+			}
+		}()
+	}
+
+	for _, job := range jobs {
+		jobsChannel <- job
+	}
+	close(jobsChannel)
+
+	workers.Wait()
+}
+
+// reconcileObject runs the reconcile of a single object once no other goroutine is reconciling it, turning a panic into a failure so it doesn't take down the poller or watchers
+func reconcileObject(objectType, name, namespace string, reconcile func() (string, int, error)) (status string, changes int, err error) {
+
+	unlock := reconcileLocks.lock(objectType, namespace, name)
+	defer unlock()
+
+	// only take a slot once the object is ours, so waiting for another reconcile of the same object doesn't hold one
+	release := acquireReconcileSlot()
+	defer release()
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Msgf("Reconciling %v %v.%v panicked: %v\n%s", objectType, name, namespace, r, debug.Stack())
+			reconcilePanicsTotals.With(prometheus.Labels{"namespace": namespace, "type": objectType}).Inc()
+
+			status = "failed"
+			err = fmt.Errorf("Reconciling %v %v.%v panicked: %v", objectType, name, namespace, r)
+		}
+	}()
+
+	return reconcile()
+}
+
+// acquireReconcileSlot blocks until fewer than the maximum number of reconciles are running and returns the function that frees the slot again
+func acquireReconcileSlot() (release func()) {
+
+	if reconcileSlots == nil {
+		return func() {}
+	}
+
+	reconcileSlots <- struct{}{}
+
+	return func() {
+		<-reconcileSlots
+	}
+}
+
+// initReadiness serves a /readiness endpoint that fails as long as the last periodic check of the Cloudflare api failed
+func initReadiness(cf *Cloudflare) {
+
+	var cloudflareReachable int32
+
+	// check cloudflare connectivity periodically
+	go func() {
+		for {
+			err := cf.CheckConnectivity()
+			if err != nil {
+				log.Warn().Err(err).Msg("Checking Cloudflare connectivity failed, reporting not ready")
+				atomic.StoreInt32(&cloudflareReachable, 0)
+			} else {
+				atomic.StoreInt32(&cloudflareReachable, 1)
+			}
+
+			time.Sleep(time.Duration(applyJitter(60, *jitterFraction)) * time.Second)
+		}
+	}()
+
+	go func() {
+		log.Debug().Msgf("Serving /readiness endpoint on port %v...", readinessPort)
+
+		serverMux := http.NewServeMux()
+		serverMux.HandleFunc("/readiness", func(w http.ResponseWriter, _ *http.Request) {
+			if atomic.LoadInt32(&cloudflareReachable) == 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				io.WriteString(w, "Cloudflare api is not reachable\n")
+				return
+			}
+			io.WriteString(w, "I'm ready!\n")
+		})
+
+		if err := http.ListenAndServe(fmt.Sprintf(":%v", readinessPort), serverMux); err != nil {
+			log.Fatal().Err(err).Msg("Starting /readiness listener failed")
+		}
+	}()
+}
+
+// initReconcileEndpoint serves a /reconcile endpoint that runs a pass over all objects when called with the shared secret token
+func initReconcileEndpoint(token string, reconcile func() reconcileSummary) {
+
+	go func() {
+		log.Debug().Msgf("Serving /reconcile endpoint on port %v...", reconcilePort)
+
+		serverMux := http.NewServeMux()
+		serverMux.HandleFunc("/reconcile", newReconcileHandler(token, reconcile))
+
+		if err := http.ListenAndServe(fmt.Sprintf(":%v", reconcilePort), serverMux); err != nil {
+			log.Fatal().Err(err).Msg("Starting /reconcile listener failed")
+		}
+	}()
+}
+
+// newReconcileHandler returns a handler that runs reconcile for authorized POST requests and responds with its summary
+func newReconcileHandler(token string, reconcile func() reconcileSummary) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		// don't queue up passes, one is already running
+		if !reconcileMutex.TryLock() {
+			w.WriteHeader(http.StatusConflict)
+			io.WriteString(w, "A reconcile pass is already running\n")
+			return
+		}
+		defer reconcileMutex.Unlock()
+
+		log.Info().Msg("Reconciling all objects as requested via /reconcile endpoint...")
+		summary := reconcile()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(summary)
+	}
+}
+
+// applyJitter returns a random value that deviates at most fraction times the input from the input
+func applyJitter(input int, fraction float64) (output int) {
+
+	deviation := int(fraction * float64(input))
+	if deviation <= 0 {
+		return input
+	}
+
+	return input - deviation + r.Intn(2*deviation)
+}
+
+// managedRecordsCounter counts the managed records per zone, safe for use by concurrent workers
+type managedRecordsCounter struct {
+	mutex  sync.Mutex
+	counts map[string]int
+}
+
+func newManagedRecordsCounter() *managedRecordsCounter {
+	return &managedRecordsCounter{counts: map[string]int{}}
+}
+
+func (c *managedRecordsCounter) increment(zoneName string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.counts[zoneName]++
+}
+
+// setManagedDNSRecordsGauge sets the managed records gauge to the counts of a full pass, resetting it first so zones that are no longer in use drop off
+func setManagedDNSRecordsGauge(managedRecords *managedRecordsCounter) {
+	managedDNSRecords.Reset()
+	for zoneName, count := range managedRecords.counts {
+		managedDNSRecords.With(prometheus.Labels{"zone": zoneName}).Set(float64(count))
+	}
+}
+
+// countManagedRecords counts the records of the state an object ended up with after reconciling it by their zone; records whose zone isn't known yet, because they haven't been upserted since the controller started storing it, are left out until they are
+func countManagedRecords(state CloudflareState, managedRecords *managedRecordsCounter) {
+
+	if state.Enabled != "true" {
+		return
+	}
+
+	for _, record := range state.Records {
+		if record.Zone == "" {
+			continue
+		}
+		managedRecords.increment(record.Zone)
+	}
+}
+
+func getDesiredServiceState(service *v1.Service) (state CloudflareState) {
+
+	var ok bool
+
+	state.Enabled = getBooleanAnnotation(service.Annotations, annotationCloudflareDNS, false, "Service", service.Name, service.Namespace)
+	state.Hostnames, ok = service.Annotations[annotationCloudflareHostnames]
+	if !ok {
+		state.Hostnames = ""
+	}
+	state.InternalHostnames, ok = service.Annotations[annotationCloudflareInternalHostnames]
+	if !ok {
+		state.InternalHostnames = ""
+	}
+	state.Hostnames = expandServiceHostnames(service, state.Hostnames)
+	state.InternalHostnames = expandServiceHostnames(service, state.InternalHostnames)
+	state.Hostnames = normalizeHostnames(state.Hostnames)
+	state.InternalHostnames = normalizeHostnames(state.InternalHostnames)
+	state.RecordNames = getRecordNameAnnotation(service.Annotations, state.Hostnames, "Service", service.Name, service.Namespace)
+	state.Hostnames = applyRecordNames(state.Hostnames, state.RecordNames)
+	state.InternalHostnames = applyRecordNames(state.InternalHostnames, state.RecordNames)
+	state.InternalDNS = getBooleanAnnotation(service.Annotations, annotationCloudflareInternalDNS, true, "Service", service.Name, service.Namespace)
+	state.Proxy = getProxyAnnotation(service.Annotations, "Service", service.Name, service.Namespace)
+	state.ProxyAfter = getProxyAfterAnnotation(service.Annotations, "Service", service.Name, service.Namespace)
+	state.ProxyPlans = getProxyPlansAnnotation(service.Annotations)
+	state.UseOriginRecord = getBooleanAnnotation(service.Annotations, annotationCloudflareUseOriginRecord, *defaultUseOriginRecord, "Service", service.Name, service.Namespace)
+	state.OriginRecordHostname = getOriginRecordHostname(service.Annotations, state.UseOriginRecord, state.Hostnames, "Service", service.Name, service.Namespace)
+	state.CNAMETarget, ok = service.Annotations[annotationCloudflareCNAMETarget]
+	if !ok {
+		state.CNAMETarget = ""
+	}
+	state.Comment, ok = service.Annotations[annotationCloudflareComment]
+	if !ok {
+		state.Comment = defaultCloudflareComment
+	}
+	state.TTL = getTTLAnnotation(service.Annotations, "Service", service.Name, service.Namespace)
+	state.Region = getRegionAnnotation(service.Annotations)
+	state.SSLMode = getSSLModeAnnotation(service.Annotations, "Service", service.Name, service.Namespace)
+	state.ZoneSettings = strings.TrimSpace(service.Annotations[annotationCloudflareZoneSettings])
+	state.SRVRecords, ok = service.Annotations[annotationCloudflareSRVRecords]
+	if !ok {
+		state.SRVRecords = ""
+	}
+	state.NSRecords, ok = service.Annotations[annotationCloudflareNSRecords]
+	if !ok {
+		state.NSRecords = ""
+	}
+	state.CAARecords, ok = service.Annotations[annotationCloudflareCAARecords]
+	if !ok {
+		state.CAARecords = ""
+	}
+	state.LOCRecords, ok = service.Annotations[annotationCloudflareLOCRecords]
+	if !ok {
+		state.LOCRecords = ""
+	}
+
+	if service.Spec.Type == "LoadBalancer" && len(service.Status.LoadBalancer.Ingress) > 0 {
+		state.IPAddress, state.TargetIsHostname = getLoadBalancerTarget(service.Status.LoadBalancer.Ingress[0])
+
+		// a load balancer with more than one ip address gets a record per ip address for round-robin dns
+		if state.TargetIsHostname != "true" {
+			state.AdditionalIPAddresses = strings.Join(getAdditionalLoadBalancerIPAddresses(state.IPAddress, service.Status.LoadBalancer.Ingress[1:]), ",")
+		}
+	}
+
+	// NodePort services have no load balancer, so point at the external ip address of a node if opted in
+	if service.Spec.Type == "NodePort" && getBooleanAnnotation(service.Annotations, annotationCloudflareUseNodeExternalIP, false, "Service", service.Name, service.Namespace) == "true" {
+		state.IPAddress = getNodeExternalIPAddress()
+	}
+
+	// take the internal ip address from the annotation if set, for example to point at a vip, or else the cluster ip
+	state.InternalIPAddress, ok = service.Annotations[annotationCloudflareInternalIPAddress]
+	if !ok {
+		state.InternalIPAddress = service.Spec.ClusterIP
+	}
+
+	return
+}
+
+func getCurrentServiceState(ctx context.Context, service *v1.Service) (state CloudflareState) {
+
+	// get state stored in the configmap if enabled, falling back to the annotation for objects that haven't been reconciled since switching
+	if stateStore != nil {
+		if state, ok := stateStore.get(ctx, "Service", service.Namespace, service.Name); ok {
+			return state
+		}
+	}
+
+	// get state stored in annotations if present or set to empty struct
+	cloudflareStateString, ok := service.Annotations[annotationCloudflareState]
+	if !ok {
+		// couldn't find saved state, setting to default struct
+		state = CloudflareState{}
+		return
+	}
+
+	if err := decodeStateAnnotation(cloudflareStateString, &state); err != nil {
+		// couldn't deserialize, setting to default struct
+		countStateDecodeFailure("Service", service.Namespace, service.Name, cloudflareStateString, err)
+		state = CloudflareState{}
+		return
+	}
+
+	// return deserialized state
+	return
+}
+
+func makeServiceChanges(ctx context.Context, cf *Cloudflare, kubeClientset kubernetes.Interface, recorder record.EventRecorder, service *v1.Service, initiator string, desiredState, currentState CloudflareState, finalState *CloudflareState) (status string, changes int, err error) {
+
+	status = "failed"
+	hasChanges := false
+
+	// the records as upserted, for the zone each hostname resolved to and the ttl cloudflare assigned
+	upsertedRecords := map[string]DNSRecord{}
+
+	// the names of the records that exist at cloudflare without the ownership marker, which are left alone and not stored
+	notOwnedRecords := map[string]bool{}
+
+	// look up the zones of the records once, instead of for every record operation
+	zones := newObjectZones(cf)
+
+	// upsert all records regardless of the stored state if requested, to heal changes made outside of the controller
+	forceUpdate := getBooleanAnnotation(service.Annotations, annotationCloudflareForceUpdate, false, "Service", service.Name, service.Namespace) == "true"
+
+	// the zone name and records aren't part of the desired state, so keep the stored ones until the records get upserted again
+	desiredState.ZoneName = currentState.ZoneName
+	desiredState.Records = currentState.Records
+
+	// hand the state as it ended up to the caller, for the reconcile summary
+	if finalState != nil {
+		defer func() {
+			*finalState = desiredState
+		}()
+	}
+
+	// the load balancer status can have the ip address formatted unexpectedly, with a port or ipv6 zone id, so normalize it instead of creating invalid records
+	if desiredState.Enabled == "true" {
+		normalizedState, err := normalizeRecordTargets(desiredState)
+		if err != nil {
+			log.Error().Err(err).Msgf("[%v] Service %v.%v - Invalid record content, skipping dns records", initiator, service.Name, service.Namespace)
+			recorder.Eventf(service, v1.EventTypeWarning, "InvalidRecordContent", "Skipping dns records: %v", err)
+			return status, changes, err
+		}
+		desiredState = normalizedState
+	}
+
+	// keep new records dns only until their grace period has passed, so they can be verified before traffic goes through cloudflare
+	desiredState = applyProxyGracePeriod(desiredState, currentState, timeNow())
+
+	if *logReconcileDiff {
+		logStateDiff("Service", service.Name, service.Namespace, initiator, desiredState, currentState)
+	}
+
+	// check if dns has been disabled since the state was stored, in which case the previously managed records need to be cleaned up
+	if desiredState.Enabled != "true" && currentState.Enabled == "true" {
+
+		log.Info().Msgf("[%v] Service %v.%v - Dns has been disabled, deleting previously managed dns records...", initiator, service.Name, service.Namespace)
+
+		deletedChanges, err := deleteRecordsFromState(cf, recorder, service, "Service", service.Name, service.Namespace, initiator, currentState)
+		changes += deletedChanges
+		if err != nil {
+			// keep the stored state, so the records that failed to get deleted are retried
+			log.Error().Err(err).Msgf("[%v] Service %v.%v - Deleting previously managed dns records has failed", initiator, service.Name, service.Namespace)
+			return status, changes, err
+		}
+
+		if stateStore != nil {
+			// remove the stored state, so the records get recreated if dns is enabled again
+			err = stateStore.remove(ctx, "Service", service.Namespace, service.Name)
+			if err != nil {
+				log.Error().Err(err).Msgf("[%v] Service %v.%v - Removing service state from configmap has failed", initiator, service.Name, service.Namespace)
+				return status, changes, err
+			}
+		} else {
+			// remove the state annotation, so the records get recreated if dns is enabled again
+			patch, err := getStateAnnotationPatch(nil)
+			if err != nil {
+				log.Error().Err(err).Msgf("[%v] Service %v.%v - Marshalling state patch failed", initiator, service.Name, service.Namespace)
+				return status, changes, err
+			}
+
+			_, err = kubeClientset.CoreV1().Services(service.Namespace).Patch(ctx, service.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+			if err != nil {
+				log.Error().Err(err).Msgf("[%v] Service %v.%v - Removing service state has failed", initiator, service.Name, service.Namespace)
+				return status, changes, err
+			}
+		}
+
+		status = "deleted"
+
+		return status, changes, nil
+	}
+
+	// check if service has estafette.io/cloudflare-dns annotation and it's value is true and
+	// check if service has estafette.io/cloudflare-hostnames annotation and it's value is not empty and
+	// check if type equals LoadBalancer and
+	// check if LoadBalancer has an ip address or a cname target is set
+	if desiredState.Enabled == "true" && len(desiredState.Hostnames) > 0 && (desiredState.IPAddress != "" || desiredState.CNAMETarget != "") {
+
+		// update dns record if anything has changed compared to the stored state
+		if forceUpdate ||
+			desiredState.IPAddress != currentState.IPAddress ||
+			desiredState.AdditionalIPAddresses != currentState.AdditionalIPAddresses ||
+			desiredState.Hostnames != currentState.Hostnames ||
+			desiredState.Proxy != currentState.Proxy ||
+			desiredState.ProxyPlans != currentState.ProxyPlans ||
+			desiredState.UseOriginRecord != currentState.UseOriginRecord ||
+			desiredState.OriginRecordHostname != currentState.OriginRecordHostname ||
+			desiredState.CNAMETarget != currentState.CNAMETarget ||
+			desiredState.Comment != currentState.Comment ||
+			desiredState.TTL != currentState.TTL ||
+			desiredState.Region != currentState.Region ||
+			desiredState.SSLMode != currentState.SSLMode ||
+			desiredState.ZoneSettings != currentState.ZoneSettings {
+
+			hasChanges = true
+
+			// point to the load balancer with an A or AAAA record, or a CNAME record if it only has a hostname
+			dnsRecordType := getTargetDNSRecordType(desiredState)
+
+			// if use origin is enabled, create a record for the origin
+			if desiredState.CNAMETarget == "" && desiredState.UseOriginRecord == "true" && desiredState.OriginRecordHostname != "" {
+
+				log.Info().Msgf("[%v] Service %v.%v - Upserting origin dns record %v (%v) to %v...", initiator, service.Name, service.Namespace, desiredState.OriginRecordHostname, dnsRecordType, desiredState.IPAddress)
+
+				originDNSRecord, upserted, err := zones.upsertDNSRecord(dnsRecordType, desiredState.OriginRecordHostname, desiredState.IPAddress, false, desiredState.Comment, desiredState.Region, 0)
+				if errors.Is(err, errDNSRecordNotOwned) {
+					log.Warn().Msgf("[%v] Service %v.%v - Origin dns record %v (%v) lacks the ownership marker, leaving it alone", initiator, service.Name, service.Namespace, desiredState.OriginRecordHostname, dnsRecordType)
+					notOwnedRecords[desiredState.OriginRecordHostname] = true
+				} else if err != nil {
+					getUpsertFailureLogEvent(err).Msgf("[%v] Service %v.%v - Upserting origin dns record %v (%v) to %v failed", initiator, service.Name, service.Namespace, desiredState.OriginRecordHostname, dnsRecordType, desiredState.IPAddress)
+					recorder.Eventf(service, v1.EventTypeWarning, "DNSRecordUpsertFailed", "Upserting origin dns record %v (%v) to %v failed: %v", desiredState.OriginRecordHostname, dnsRecordType, desiredState.IPAddress, err)
+					return status, changes, err
+				} else {
+					upsertedRecords[desiredState.OriginRecordHostname] = originDNSRecord
+					if upserted {
+						recorder.Eventf(service, v1.EventTypeNormal, "DNSRecordUpserted", "Upserted origin dns record %v (%v) to %v", desiredState.OriginRecordHostname, dnsRecordType, desiredState.IPAddress)
+						changes++
+					}
+				}
+			}
+
+			// loop all hostnames
+			hostnames := splitHostnames(desiredState.Hostnames)
+			zoneNames := []string{}
+			sslModeZones := map[string]bool{}
+			zoneSettingsZones := map[string]bool{}
+			zoneSettings, err := parseZoneSettings(desiredState.ZoneSettings)
+			if err != nil {
+				log.Error().Err(err).Msgf("[%v] Service %v.%v - Parsing zone settings %v failed", initiator, service.Name, service.Namespace, desiredState.ZoneSettings)
+				return status, changes, err
+			}
+			// the ttl is set while upserting the records, 0 leaves it automatic
+			ttl, _ := strconv.Atoi(desiredState.TTL)
+			for _, hostname := range hostnames {
+
+				// validate hostname, skip if invalid
+				if reason := validateHostname(hostname); reason != "" {
+					log.Error().Msgf("[%v] Service %v.%v - Invalid dns record %v (%v), skipping", initiator, service.Name, service.Namespace, hostname, reason)
+					invalidHostnamesTotals.With(prometheus.Labels{"namespace": service.Namespace, "reason": reason}).Inc()
+					continue
+				}
+
+				hostnameDNSRecordType, _, isApex := getServiceHostnameDNSRecord(zones, desiredState, hostname)
+
+				// cloudflare only flattens a CNAME record at the zone apex if it's proxied
+				if isApex && hostnameDNSRecordType == "CNAME" && desiredState.Proxy == "false" {
+					log.Warn().Msgf("[%v] Service %v.%v - Dns record %v (CNAME) is the zone apex and can only be created with proxying enabled, skipping", initiator, service.Name, service.Namespace, hostname)
+					recorder.Eventf(service, v1.EventTypeWarning, "DNSRecordSkipped", "Dns record %v (CNAME) is the zone apex and can only be created with proxying enabled", hostname)
+					continue
+				}
+
+				// zones on plans other than the proxy plans can't proxy every record, so they get dns only records instead of failing
+				planAllowsProxy, plan := isProxyAllowedByZonePlan(zones.getZone, hostname, desiredState.ProxyPlans)
+				if !planAllowsProxy && desiredState.Proxy == "true" {
+					log.Warn().Msgf("[%v] Service %v.%v - Zone of dns record %v is on plan %v, which isn't one of the proxy plans %v, leaving it dns only", initiator, service.Name, service.Namespace, hostname, plan, desiredState.ProxyPlans)
+					recorder.Eventf(service, v1.EventTypeWarning, "ProxyDowngraded", "Zone of dns record %v is on plan %v, which isn't one of the proxy plans %v, leaving it dns only", hostname, plan, desiredState.ProxyPlans)
+				}
+
+				var dnsRecord DNSRecord
+				var upserted bool
+				// with automatic proxying an existing record stays proxied if cloudflare allows it, a new one only gets proxied once cloudflare tells whether it can be
+				proxy := desiredState.Proxy == "true" && planAllowsProxy
+				if desiredState.Proxy == "auto" {
+					proxy = planAllowsProxy && zones.isDNSRecordProxiable(hostname)
+				}
+
+				// a hostname pointing at the load balancer ip addresses gets a record per ip address, also to clean up the extra records once it has a single one again; the set upsert sets proxying and ttl itself
+				recordSet := hostnameDNSRecordType != "CNAME" && (desiredState.AdditionalIPAddresses != "" || currentState.AdditionalIPAddresses != "")
+
+				// if a cname target is set, create a CNAME record pointing to that target, else if use origin is enabled, create a CNAME record pointing to the origin record, except at the zone apex
+				if desiredState.CNAMETarget != "" {
+
+					log.Info().Msgf("[%v] Service %v.%v - Upserting dns record %v (CNAME) to value %v...", initiator, service.Name, service.Namespace, hostname, desiredState.CNAMETarget)
+
+					dnsRecord, upserted, err = zones.upsertDNSRecord("CNAME", hostname, desiredState.CNAMETarget, proxy, desiredState.Comment, desiredState.Region, ttl)
+					if errors.Is(err, errDNSRecordNotOwned) {
+						log.Warn().Msgf("[%v] Service %v.%v - Dns record %v (CNAME) lacks the ownership marker, leaving it alone", initiator, service.Name, service.Namespace, hostname)
+						notOwnedRecords[hostname] = true
+						continue
+					}
+					if err != nil {
+						getUpsertFailureLogEvent(err).Msgf("[%v] Service %v.%v - Upserting dns record %v (CNAME) to value %v failed", initiator, service.Name, service.Namespace, hostname, desiredState.CNAMETarget)
+						recorder.Eventf(service, v1.EventTypeWarning, "DNSRecordUpsertFailed", "Upserting dns record %v (CNAME) to value %v failed: %v", hostname, desiredState.CNAMETarget, err)
+						return status, changes, err
+					}
+					if upserted {
+						recorder.Eventf(service, v1.EventTypeNormal, "DNSRecordUpserted", "Upserted dns record %v (CNAME) to value %v in zone %v", hostname, desiredState.CNAMETarget, dnsRecord.ZoneName)
+						changes++
+					}
+				} else if desiredState.UseOriginRecord == "true" && desiredState.OriginRecordHostname != "" && !isApex {
+
+					log.Info().Msgf("[%v] Service %v.%v - Upserting dns record %v (CNAME) to value %v...", initiator, service.Name, service.Namespace, hostname, desiredState.OriginRecordHostname)
+
+					dnsRecord, upserted, err = zones.upsertDNSRecord("CNAME", hostname, desiredState.OriginRecordHostname, proxy, desiredState.Comment, desiredState.Region, ttl)
+					if errors.Is(err, errDNSRecordNotOwned) {
+						log.Warn().Msgf("[%v] Service %v.%v - Dns record %v (CNAME) lacks the ownership marker, leaving it alone", initiator, service.Name, service.Namespace, hostname)
+						notOwnedRecords[hostname] = true
+						continue
+					}
+					if err != nil {
+						getUpsertFailureLogEvent(err).Msgf("[%v] Service %v.%v - Upserting dns record %v (CNAME) to value %v failed", initiator, service.Name, service.Namespace, hostname, desiredState.OriginRecordHostname)
+						recorder.Eventf(service, v1.EventTypeWarning, "DNSRecordUpsertFailed", "Upserting dns record %v (CNAME) to value %v failed: %v", hostname, desiredState.OriginRecordHostname, err)
+						return status, changes, err
+					}
+					if upserted {
+						recorder.Eventf(service, v1.EventTypeNormal, "DNSRecordUpserted", "Upserted dns record %v (CNAME) to value %v in zone %v", hostname, desiredState.OriginRecordHostname, dnsRecord.ZoneName)
+						changes++
+					}
+				} else if recordSet {
+
+					ipAddresses := getStateIPAddresses(desiredState)
+
+					log.Info().Msgf("[%v] Service %v.%v - Upserting dns records %v (%v) to %v...", initiator, service.Name, service.Namespace, hostname, dnsRecordType, strings.Join(ipAddresses, ","))
+
+					dnsRecords, upserted, err := zones.upsertDNSRecordSet(dnsRecordType, hostname, ipAddresses, proxy, desiredState.Comment, desiredState.Region, ttl)
+					if err != nil {
+						getUpsertFailureLogEvent(err).Msgf("[%v] Service %v.%v - Upserting dns records %v (%v) to %v failed", initiator, service.Name, service.Namespace, hostname, dnsRecordType, strings.Join(ipAddresses, ","))
+						recorder.Eventf(service, v1.EventTypeWarning, "DNSRecordUpsertFailed", "Upserting dns records %v (%v) to %v failed: %v", hostname, dnsRecordType, strings.Join(ipAddresses, ","), err)
+						return status, changes, err
+					}
+					if len(dnsRecords) > 0 {
+						dnsRecord = dnsRecords[0]
+					}
+					if upserted {
+						recorder.Eventf(service, v1.EventTypeNormal, "DNSRecordUpserted", "Upserted dns records %v (%v) to %v in zone %v", hostname, dnsRecordType, strings.Join(ipAddresses, ","), dnsRecord.ZoneName)
+						changes++
+					}
+				} else {
+
+					log.Info().Msgf("[%v] Service %v.%v - Upserting dns record %v (%v) to %v...", initiator, service.Name, service.Namespace, hostname, dnsRecordType, desiredState.IPAddress)
+
+					dnsRecord, upserted, err = zones.upsertDNSRecord(dnsRecordType, hostname, desiredState.IPAddress, proxy, desiredState.Comment, desiredState.Region, ttl)
+					if errors.Is(err, errDNSRecordNotOwned) {
+						log.Warn().Msgf("[%v] Service %v.%v - Dns record %v (%v) lacks the ownership marker, leaving it alone", initiator, service.Name, service.Namespace, hostname, dnsRecordType)
+						notOwnedRecords[hostname] = true
+						continue
+					}
+					if err != nil {
+						getUpsertFailureLogEvent(err).Msgf("[%v] Service %v.%v - Upserting dns record %v (%v) to %v failed", initiator, service.Name, service.Namespace, hostname, dnsRecordType, desiredState.IPAddress)
+						recorder.Eventf(service, v1.EventTypeWarning, "DNSRecordUpsertFailed", "Upserting dns record %v (%v) to %v failed: %v", hostname, dnsRecordType, desiredState.IPAddress, err)
+						return status, changes, err
+					}
+					if upserted {
+						recorder.Eventf(service, v1.EventTypeNormal, "DNSRecordUpserted", "Upserted dns record %v (%v) to %v in zone %v", hostname, dnsRecordType, desiredState.IPAddress, dnsRecord.ZoneName)
+						changes++
+					}
+				}
+
+				log.Info().Msgf("[%v] Service %v.%v - Dns record %v is in zone %v", initiator, service.Name, service.Namespace, hostname, dnsRecord.ZoneName)
+				zoneNames = appendZoneName(zoneNames, dnsRecord.ZoneName)
+				upsertedRecords[hostname] = dnsRecord
+
+				if desiredState.Proxy == "auto" {
+					proxy = planAllowsProxy && dnsRecord.Proxiable
+				}
+
+				// the records of a set already got their proxy setting and ttl while upserting them
+				if !recordSet {
+					// if proxy is enabled, update it at Cloudflare
+					if proxy {
+						log.Info().Msgf("[%v] Service %v.%v - Enabling proxying for dns record %v (%v)...", initiator, service.Name, service.Namespace, hostname, hostnameDNSRecordType)
+					} else {
+						log.Info().Msgf("[%v] Service %v.%v - Disabling proxying for dns record %v (%v)...", initiator, service.Name, service.Namespace, hostname, hostnameDNSRecordType)
+					}
+
+					_, err := zones.updateProxySetting(hostnameDNSRecordType, hostname, proxy)
+					if err != nil {
+						if proxy {
+							log.Error().Err(err).Msgf("[%v] Service %v.%v - Enabling proxying for dns record %v (%v) failed", initiator, service.Name, service.Namespace, hostname, hostnameDNSRecordType)
+							recorder.Eventf(service, v1.EventTypeWarning, "ProxySettingUpdateFailed", "Enabling proxying for dns record %v (%v) failed: %v", hostname, hostnameDNSRecordType, err)
+						} else {
+							log.Error().Err(err).Msgf("[%v] Service %v.%v - Disabling proxying for dns record %v (%v) failed", initiator, service.Name, service.Namespace, hostname, hostnameDNSRecordType)
+							recorder.Eventf(service, v1.EventTypeWarning, "ProxySettingUpdateFailed", "Disabling proxying for dns record %v (%v) failed: %v", hostname, hostnameDNSRecordType, err)
+						}
+
+						return status, changes, err
+					}
+					recorder.Eventf(service, v1.EventTypeNormal, "ProxySettingUpdated", "Set proxying for dns record %v (%v) to %v", hostname, hostnameDNSRecordType, proxy)
+				}
+
+				// the ssl mode applies to the whole zone, so only set it if asked for and once per zone
+				if desiredState.SSLMode != "" && proxy && !sslModeZones[dnsRecord.ZoneName] {
+					sslModeZones[dnsRecord.ZoneName] = true
+
+					sslModeChanges, err := updateZoneSSLMode(zones, recorder, service, "Service", service.Name, service.Namespace, initiator, hostname, desiredState.SSLMode)
+					if err != nil {
+						return status, changes, err
+					}
+					changes += sslModeChanges
+				}
+
+				// zone settings apply to the whole zone as well, so only apply them if asked for and once per zone
+				if len(zoneSettings) > 0 && !zoneSettingsZones[dnsRecord.ZoneName] {
+					zoneSettingsZones[dnsRecord.ZoneName] = true
+
+					zoneSettingsChanges, err := updateZoneSettings(zones, recorder, service, "Service", service.Name, service.Namespace, initiator, hostname, zoneSettings)
+					if err != nil {
+						return status, changes, err
+					}
+					changes += zoneSettingsChanges
+				}
+			}
+
+			desiredState.ZoneName = strings.Join(zoneNames, ",")
+
+			// if use origin is disabled, remove the A or AAAA record for the origin, if state still has a value for OriginRecordHostname
+			if desiredState.OriginRecordHostname != "" && (desiredState.UseOriginRecord != "true" || desiredState.OriginRecordHostname == "") {
+
+				// the origin record got created for the previous address, so only delete it if it still has that type and content
+				originDNSRecordType := getTargetDNSRecordType(currentState)
+				log.Info().Msgf("[%v] Service %v.%v - Deleting origin dns record %v (%v) with content %v...", initiator, service.Name, service.Namespace, desiredState.OriginRecordHostname, originDNSRecordType, currentState.IPAddress)
+
+				_, err := cf.DeleteDNSRecordIfMatching(desiredState.OriginRecordHostname, originDNSRecordType, currentState.IPAddress)
+				if err != nil {
+					log.Error().Err(err).Msgf("[%v] Service %v.%v - Deleting origin dns record %v (%v) with content %v failed", initiator, service.Name, service.Namespace, desiredState.OriginRecordHostname, originDNSRecordType, currentState.IPAddress)
+					recorder.Eventf(service, v1.EventTypeWarning, "DNSRecordDeleteFailed", "Deleting origin dns record %v (%v) with content %v failed: %v", desiredState.OriginRecordHostname, originDNSRecordType, currentState.IPAddress, err)
+					return status, changes, err
+				}
+				recorder.Eventf(service, v1.EventTypeNormal, "DNSRecordDeleted", "Deleted origin dns record %v (%v) with content %v", desiredState.OriginRecordHostname, originDNSRecordType, currentState.IPAddress)
+				changes++
+			}
+		}
+	}
+
+	// internal records can be disabled separately from the other records, in which case the stale record cleanup removes the ones created before
+	if desiredState.Enabled == "true" && desiredState.InternalDNS != currentState.InternalDNS {
+		hasChanges = true
+	}
+
+	// check if service has estafette.io/cloudflare-dns annotation and it's value is true and
+	// check if service has estafette.io/cloudflare-internal-dns annotation and it's value isn't false and
+	// check if service has estafette.io/cloudflare-internal-hostnames annotation and it's value is not empty and
+	// check if service has an internal ip address
+	if desiredState.Enabled == "true" && desiredState.InternalDNS != "false" && len(desiredState.InternalHostnames) > 0 && desiredState.InternalIPAddress != "" {
+
+		// update internal dns record if anything has changed compared to the stored state
+		if forceUpdate ||
+			desiredState.InternalDNS != currentState.InternalDNS ||
+			desiredState.InternalIPAddress != currentState.InternalIPAddress ||
+			desiredState.InternalHostnames != currentState.InternalHostnames ||
+			desiredState.Comment != currentState.Comment {
+
+			hasChanges = true
+
+			// loop all internal hostnames
+			internalHostnames := splitHostnames(desiredState.InternalHostnames)
+			for _, internalHostname := range internalHostnames {
+
+				log.Info().Msgf("[%v] Service %v.%v - Upserting dns record %v (A) to internal ip address %v...", initiator, service.Name, service.Namespace, internalHostname, desiredState.InternalIPAddress)
+
+				internalDNSRecord, upserted, err := zones.upsertDNSRecord("A", internalHostname, desiredState.InternalIPAddress, false, desiredState.Comment, "", 0)
+				if errors.Is(err, errDNSRecordNotOwned) {
+					log.Warn().Msgf("[%v] Service %v.%v - Dns record %v (A) lacks the ownership marker, leaving it alone", initiator, service.Name, service.Namespace, internalHostname)
+					notOwnedRecords[internalHostname] = true
+					continue
+				}
+				if err != nil {
+					getUpsertFailureLogEvent(err).Msgf("[%v] Service %v.%v - Upserting dns record %v (A) to internal ip address %v failed", initiator, service.Name, service.Namespace, internalHostname, desiredState.InternalIPAddress)
+					recorder.Eventf(service, v1.EventTypeWarning, "DNSRecordUpsertFailed", "Upserting dns record %v (A) to internal ip address %v failed: %v", internalHostname, desiredState.InternalIPAddress, err)
+					return status, changes, err
+				}
+				upsertedRecords[internalHostname] = internalDNSRecord
+				if upserted {
+					recorder.Eventf(service, v1.EventTypeNormal, "DNSRecordUpserted", "Upserted dns record %v (A) to internal ip address %v", internalHostname, desiredState.InternalIPAddress)
+					changes++
+				}
+			}
+		}
+	}
+
+	// check if service has estafette.io/cloudflare-dns annotation and it's value is true and
+	// check if service has estafette.io/cloudflare-srv-records or estafette.io/cloudflare-loc-records annotation or comment that changed compared to the stored state
+	if desiredState.Enabled == "true" && (forceUpdate || desiredState.SRVRecords != currentState.SRVRecords || desiredState.LOCRecords != currentState.LOCRecords || desiredState.Comment != currentState.Comment) {
+
+		hasChanges = true
+
+		structuredRecords, err := getStructuredRecords(desiredState)
+		if err != nil {
+			log.Error().Err(err).Msgf("[%v] Service %v.%v - Parsing srv and loc records failed", initiator, service.Name, service.Namespace)
+			return status, changes, err
+		}
+
+		// loop all names, reconciling the srv or loc records for each of them as a set
+		desiredStructuredRecords := map[string]bool{}
+		for _, structuredRecordSet := range groupStructuredRecordsByTypeAndName(structuredRecords) {
+			desiredStructuredRecords[structuredRecordSet.Type+" "+structuredRecordSet.Name] = true
+
+			log.Info().Msgf("[%v] Service %v.%v - Upserting dns records %v (%v) with data %v...", initiator, service.Name, service.Namespace, structuredRecordSet.Name, structuredRecordSet.Type, structuredRecordSet.Data)
+
+			_, err := zones.upsertStructuredDNSRecords(structuredRecordSet.Type, structuredRecordSet.Name, structuredRecordSet.Data, desiredState.Comment)
+			if err != nil {
+				getUpsertFailureLogEvent(err).Msgf("[%v] Service %v.%v - Upserting dns records %v (%v) with data %v failed", initiator, service.Name, service.Namespace, structuredRecordSet.Name, structuredRecordSet.Type, structuredRecordSet.Data)
+				recorder.Eventf(service, v1.EventTypeWarning, "DNSRecordUpsertFailed", "Upserting dns records %v (%v) with data %v failed: %v", structuredRecordSet.Name, structuredRecordSet.Type, structuredRecordSet.Data, err)
+				return status, changes, err
+			}
+			recorder.Eventf(service, v1.EventTypeNormal, "DNSRecordUpserted", "Upserted dns records %v (%v) with data %v", structuredRecordSet.Name, structuredRecordSet.Type, structuredRecordSet.Data)
+			changes++
+		}
+
+		// remove srv and loc records that are no longer in the annotations
+		currentStructuredRecords, _ := getStructuredRecords(currentState)
+		for _, structuredRecord := range currentStructuredRecords {
+			if desiredStructuredRecords[structuredRecord.Type+" "+structuredRecord.Name] {
+				continue
+			}
+
+			log.Info().Msgf("[%v] Service %v.%v - Deleting dns record %v (%v)...", initiator, service.Name, service.Namespace, structuredRecord.Name, structuredRecord.Type)
+
+			_, err := cf.DeleteStructuredDNSRecordIfMatching(structuredRecord.Type, structuredRecord.Name, structuredRecord.Data)
+			if err != nil {
+				log.Warn().Err(err).Msgf("[%v] Service %v.%v - Deleting dns record %v (%v) failed", initiator, service.Name, service.Namespace, structuredRecord.Name, structuredRecord.Type)
+				recorder.Eventf(service, v1.EventTypeWarning, "DNSRecordDeleteFailed", "Deleting dns record %v (%v) failed: %v", structuredRecord.Name, structuredRecord.Type, err)
+			} else {
+				recorder.Eventf(service, v1.EventTypeNormal, "DNSRecordDeleted", "Deleted dns record %v (%v)", structuredRecord.Name, structuredRecord.Type)
+				changes++
+			}
+		}
+	}
+
+	// check if service has estafette.io/cloudflare-dns annotation and it's value is true and
+	// check if service has estafette.io/cloudflare-caa-records annotation or comment that changed compared to the stored state
+	if desiredState.Enabled == "true" && (forceUpdate || desiredState.CAARecords != currentState.CAARecords || desiredState.Comment != currentState.Comment) {
+
+		hasChanges = true
+
+		caaRecords, err := parseCAARecords(desiredState.CAARecords)
+		if err != nil {
+			log.Error().Err(err).Msgf("[%v] Service %v.%v - Parsing caa records %v failed", initiator, service.Name, service.Namespace, desiredState.CAARecords)
+			return status, changes, err
+		}
+
+		// loop all names, reconciling the caa records for each of them as a set
+		caaRecordNames, caaRecordsData := groupCAARecordsByName(caaRecords)
+		for _, caaRecordName := range caaRecordNames {
+			log.Info().Msgf("[%v] Service %v.%v - Upserting dns records %v (CAA) with data %v...", initiator, service.Name, service.Namespace, caaRecordName, caaRecordsData[caaRecordName])
+
+			_, err := zones.upsertCAARecords(caaRecordName, caaRecordsData[caaRecordName], desiredState.Comment)
+			if err != nil {
+				getUpsertFailureLogEvent(err).Msgf("[%v] Service %v.%v - Upserting dns records %v (CAA) with data %v failed", initiator, service.Name, service.Namespace, caaRecordName, caaRecordsData[caaRecordName])
+				recorder.Eventf(service, v1.EventTypeWarning, "DNSRecordUpsertFailed", "Upserting dns records %v (CAA) with data %v failed: %v", caaRecordName, caaRecordsData[caaRecordName], err)
+				return status, changes, err
+			}
+			recorder.Eventf(service, v1.EventTypeNormal, "DNSRecordUpserted", "Upserted dns records %v (CAA) with data %v", caaRecordName, caaRecordsData[caaRecordName])
+			changes++
+		}
+
+		// remove caa records for names that are no longer in the annotation
+		currentCAARecords, _ := parseCAARecords(currentState.CAARecords)
+		for _, caaRecord := range currentCAARecords {
+			if _, ok := caaRecordsData[caaRecord.Name]; ok {
+				continue
+			}
+
+			log.Info().Msgf("[%v] Service %v.%v - Deleting dns record %v (CAA) with data %v...", initiator, service.Name, service.Namespace, caaRecord.Name, caaRecord.Data)
+
+			_, err := cf.DeleteCAARecordIfMatching(caaRecord.Name, caaRecord.Data)
+			if err != nil {
+				log.Warn().Err(err).Msgf("[%v] Service %v.%v - Deleting dns record %v (CAA) with data %v failed", initiator, service.Name, service.Namespace, caaRecord.Name, caaRecord.Data)
+				recorder.Eventf(service, v1.EventTypeWarning, "DNSRecordDeleteFailed", "Deleting dns record %v (CAA) with data %v failed: %v", caaRecord.Name, caaRecord.Data, err)
+			} else {
+				recorder.Eventf(service, v1.EventTypeNormal, "DNSRecordDeleted", "Deleted dns record %v (CAA) with data %v", caaRecord.Name, caaRecord.Data)
+				changes++
+			}
+		}
+	}
+
+	// check if service has estafette.io/cloudflare-dns annotation and it's value is true and
+	// check if service has estafette.io/cloudflare-ns-records annotation that changed compared to the stored state
+	if desiredState.Enabled == "true" && (forceUpdate || desiredState.NSRecords != currentState.NSRecords) {
+
+		hasChanges = true
+
+		nsRecords, err := parseNSRecords(desiredState.NSRecords)
+		if err != nil {
+			log.Error().Err(err).Msgf("[%v] Service %v.%v - Parsing ns records %v failed", initiator, service.Name, service.Namespace, desiredState.NSRecords)
+			return status, changes, err
+		}
+
+		// loop all delegated names
+		desiredNSRecordNames := map[string]bool{}
+		for _, nsRecord := range nsRecords {
+			desiredNSRecordNames[nsRecord.Name] = true
+
+			log.Info().Msgf("[%v] Service %v.%v - Upserting dns records %v (NS) to nameservers %v...", initiator, service.Name, service.Namespace, nsRecord.Name, nsRecord.Nameservers)
+
+			_, err := zones.upsertNSRecords(nsRecord.Name, nsRecord.Nameservers, desiredState.Comment)
+			if err != nil {
+				getUpsertFailureLogEvent(err).Msgf("[%v] Service %v.%v - Upserting dns records %v (NS) to nameservers %v failed", initiator, service.Name, service.Namespace, nsRecord.Name, nsRecord.Nameservers)
+				recorder.Eventf(service, v1.EventTypeWarning, "DNSRecordUpsertFailed", "Upserting dns records %v (NS) to nameservers %v failed: %v", nsRecord.Name, nsRecord.Nameservers, err)
+				return status, changes, err
+			}
+			recorder.Eventf(service, v1.EventTypeNormal, "DNSRecordUpserted", "Upserted dns records %v (NS) to nameservers %v", nsRecord.Name, nsRecord.Nameservers)
+			changes++
+		}
+
+		// remove delegations that are no longer in the annotation
+		currentNSRecords, _ := parseNSRecords(currentState.NSRecords)
+		for _, nsRecord := range currentNSRecords {
+			if desiredNSRecordNames[nsRecord.Name] {
+				continue
+			}
+
+			log.Info().Msgf("[%v] Service %v.%v - Deleting dns records %v (NS)...", initiator, service.Name, service.Namespace, nsRecord.Name)
+
+			_, err := cf.DeleteNSRecords(nsRecord.Name)
+			if err != nil {
+				log.Warn().Err(err).Msgf("[%v] Service %v.%v - Deleting dns records %v (NS) failed", initiator, service.Name, service.Namespace, nsRecord.Name)
+				recorder.Eventf(service, v1.EventTypeWarning, "DNSRecordDeleteFailed", "Deleting dns records %v (NS) failed: %v", nsRecord.Name, err)
+			} else {
+				recorder.Eventf(service, v1.EventTypeNormal, "DNSRecordDeleted", "Deleted dns records %v (NS)", nsRecord.Name)
+				changes++
+			}
+		}
+	}
+
+	if hasChanges {
+
+		// clean up the records that are no longer desired, like the ones of removed hostnames, unless there's no address yet to know the desired records by
+		if desiredState.IPAddress != "" || desiredState.CNAMETarget != "" {
+			desiredState.Records = setUpsertedRecordDetails(removeNotOwnedRecords(getServiceManagedRecords(zones, desiredState), notOwnedRecords), upsertedRecords)
+			staleChanges, staleFailures := deleteStaleRecords(cf, recorder, service, "Service", service.Name, service.Namespace, initiator, desiredState.Records, getStoredRecords(currentState))
+			changes += staleChanges
+			if staleFailures > 0 {
+				// leave the stored state as is, so the stale records that failed to get deleted are retried
+				err = getDeleteFailuresError(staleFailures)
+				log.Error().Err(err).Msgf("[%v] Service %v.%v - Deleting stale dns records has failed", initiator, service.Name, service.Namespace)
+				return status, changes, err
+			}
+		}
+
+		// if any state property changed make sure to update all
+		currentState = desiredState
+
+		log.Info().Msgf("[%v] Service %v.%v - Updating service because state has changed...", initiator, service.Name, service.Namespace)
+
+		if stateStore != nil {
+			// store state in the configmap, leaving the service itself untouched
+			err = stateStore.set(ctx, "Service", service.Namespace, service.Name, currentState)
+			if err != nil {
+				log.Error().Err(err).Msgf("[%v] Service %v.%v - Storing service state in configmap has failed", initiator, service.Name, service.Namespace)
+				return status, changes, err
+			}
+		} else {
+			// serialize state and store it in the annotation
+			patch, err := getStateAnnotationPatch(&currentState)
+			if err != nil {
+				log.Error().Err(err).Msgf("[%v] Service %v.%v - Marshalling state failed", initiator, service.Name, service.Namespace)
+				return status, changes, err
+			}
+
+			// only patch the state annotation, so it doesn't conflict with changes to the rest of the service
+			_, err = kubeClientset.CoreV1().Services(service.Namespace).Patch(ctx, service.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+			if isAnnotationsTooLargeError(err) {
+				// the records are in place, so carry on without stored state; they get upserted again every cycle until the annotations shrink
+				log.Warn().Err(err).Msgf("[%v] Service %v.%v - Storing service state has failed, because its annotations exceed the limit of %v bytes; use --state-storage=configmap to store the state elsewhere", initiator, service.Name, service.Namespace, apivalidation.TotalAnnotationSizeLimitB)
+				err = nil
+			}
+			if err != nil {
+				log.Error().Err(err).Msgf("[%v] Service %v.%v - Updating service state has failed", initiator, service.Name, service.Namespace)
+				return status, changes, err
+			}
+		}
+
+		// only force the update once
+		if forceUpdate {
+			patch, err := getAnnotationPatch(annotationCloudflareForceUpdate, nil)
+			if err != nil {
+				log.Error().Err(err).Msgf("[%v] Service %v.%v - Marshalling force update annotation removal failed", initiator, service.Name, service.Namespace)
+				return status, changes, err
+			}
+
+			_, err = kubeClientset.CoreV1().Services(service.Namespace).Patch(ctx, service.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+			if err != nil {
+				log.Error().Err(err).Msgf("[%v] Service %v.%v - Removing force update annotation has failed", initiator, service.Name, service.Namespace)
+				return status, changes, err
+			}
+		}
+
+		status = "succeeded"
+
+		log.Info().Msgf("[%v] Service %v.%v - Service has been updated successfully...", initiator, service.Name, service.Namespace)
+
+		return status, changes, nil
+	}
+
+	status = "skipped"
+
+	return status, changes, nil
+}
+
+// getStateAnnotationPatch returns a merge patch that only sets the state annotation, or removes it if state is nil
+func getStateAnnotationPatch(state *CloudflareState) ([]byte, error) {
+
+	var cloudflareState interface{}
+	if state != nil {
+		encodedState, err := encodeStateAnnotation(*state)
+		if err != nil {
+			return nil, err
+		}
+		cloudflareState = encodedState
+	}
+
+	// a null value removes the annotation
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				annotationCloudflareState: cloudflareState,
+			},
+		},
+	})
+}
+
+// compressedStatePrefix marks a state annotation that has been compressed, because it's too large to store as plain json comfortably
+const compressedStatePrefix = "gzip:"
+
+// maxUncompressedStateSize is the size above which the state annotation gets compressed, well below the 256KB limit for all annotations of an object
+const maxUncompressedStateSize = 16 * 1024
+
+// encodeStateAnnotation serializes the state to json, compressed with gzip and base64 encoded if it's large, for objects with very many hostnames
+func encodeStateAnnotation(state CloudflareState) (string, error) {
+
+	cloudflareStateByteArray, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+
+	if len(cloudflareStateByteArray) <= maxUncompressedStateSize {
+		return string(cloudflareStateByteArray), nil
+	}
+
+	var compressedState bytes.Buffer
+	writer := gzip.NewWriter(&compressedState)
+	_, err = writer.Write(cloudflareStateByteArray)
+	if err != nil {
+		return "", err
+	}
+	err = writer.Close()
+	if err != nil {
+		return "", err
+	}
+
+	return compressedStatePrefix + base64.StdEncoding.EncodeToString(compressedState.Bytes()), nil
+}
+
+// countStateDecodeFailure counts a stored state that failed to deserialize, since resetting it to an empty state otherwise silently forces a re-upsert and hides serialization bugs
+func countStateDecodeFailure(kind, namespace, name, cloudflareStateString string, err error) {
+
+	log.Warn().Err(err).Msgf("%v %v.%v - Deserializing stored state failed, resetting it", kind, name, namespace)
+	log.Debug().Msgf("%v %v.%v - Stored state that failed to deserialize: %v", kind, name, namespace, cloudflareStateString)
+
+	stateDecodeFailuresTotals.With(prometheus.Labels{"namespace": namespace, "type": strings.ToLower(kind)}).Inc()
+}
+
+// decodeStateAnnotation deserializes the state from the annotation, decompressing it first if needed
+func decodeStateAnnotation(cloudflareStateString string, state *CloudflareState) error {
+
+	if !strings.HasPrefix(cloudflareStateString, compressedStatePrefix) {
+		return json.Unmarshal([]byte(cloudflareStateString), state)
+	}
+
+	compressedState, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(cloudflareStateString, compressedStatePrefix))
+	if err != nil {
+		return err
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(compressedState))
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	return json.NewDecoder(reader).Decode(state)
+}
+
+// isAnnotationsTooLargeError returns true if the api server rejected an update because the annotations of the object together exceed their size limit
+func isAnnotationsTooLargeError(err error) bool {
+
+	if !apierrors.IsInvalid(err) {
+		return false
+	}
+
+	var statusErr apierrors.APIStatus
+	if !errors.As(err, &statusErr) || statusErr.Status().Details == nil {
+		return false
+	}
+
+	for _, cause := range statusErr.Status().Details.Causes {
+		if cause.Type == metav1.CauseType(field.ErrorTypeTooLong) && cause.Field == "metadata.annotations" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getAnnotationPatch returns a merge patch that only sets the annotation, or removes it if value is nil
+func getAnnotationPatch(annotation string, value interface{}) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				annotation: value,
+			},
+		},
+	})
+}
+
+// getUpsertFailureLogEvent logs missing, disallowed and paused zones at debug level only, because handleZoneMissing warns about those once per object
+func getUpsertFailureLogEvent(err error) *zerolog.Event {
+	if errors.Is(err, errZoneNotFound) || errors.Is(err, errZoneNotAllowed) || errors.Is(err, errZonePaused) {
+		return log.Debug().Err(err)
+	}
+
+	return log.Error().Err(err)
+}
+
+// handleZoneMissing turns failures caused by a zone that doesn't exist (anymore) in the Cloudflare account into the zone-missing status and those caused by a zone outside of --allowed-zones into the zone-not-allowed status and those caused by a paused zone with --skip-paused-zones into the zone-paused status, warning only the first time it happens for an object to avoid logging the same error every cycle
+func handleZoneMissing(kind, name, namespace, status string, err error) (string, error) {
+
+	key := fmt.Sprintf("%v/%v/%v", kind, namespace, name)
+
+	if errors.Is(err, errZoneNotAllowed) {
+		if _, alreadyLogged := zoneMissingObjects.LoadOrStore(key, true); !alreadyLogged {
+			log.Warn().Err(err).Msgf("%v %v.%v - Cloudflare zone for its hostnames isn't allowed, skipping it", kind, name, namespace)
+		}
+		return "zone-not-allowed", nil
+	}
+
+	if errors.Is(err, errZonePaused) {
+		if _, alreadyLogged := zoneMissingObjects.LoadOrStore(key, true); !alreadyLogged {
+			log.Warn().Err(err).Msgf("%v %v.%v - Cloudflare zone for its hostnames is paused, skipping it until the zone gets resumed", kind, name, namespace)
+		}
+		return "zone-paused", nil
+	}
+
+	if !errors.Is(err, errZoneNotFound) {
+		if err == nil {
+			zoneMissingObjects.Delete(key)
+		}
+		return status, err
+	}
+
+	if _, alreadyLogged := zoneMissingObjects.LoadOrStore(key, true); !alreadyLogged {
+		log.Warn().Err(err).Msgf("%v %v.%v - No Cloudflare zone found for its hostnames, skipping it until the zone exists", kind, name, namespace)
+	}
+
+	return "zone-missing", nil
+}
+
+func processService(ctx context.Context, cf *Cloudflare, kubeClientset kubernetes.Interface, recorder record.EventRecorder, service *v1.Service, initiator string) (status string, changes int, err error) {
+	return processServiceWithFinalState(ctx, cf, kubeClientset, recorder, service, initiator, nil)
+}
+
+// processServiceWithFinalState reconciles a service and hands the state it ended up with to the caller, so a full pass can count the managed records without looking up their zones again
+func processServiceWithFinalState(ctx context.Context, cf *Cloudflare, kubeClientset kubernetes.Interface, recorder record.EventRecorder, service *v1.Service, initiator string, finalState *CloudflareState) (status string, changes int, err error) {
+
+	status = "failed"
+
+	if service != nil {
+
+		// a service being deleted is only kept around by finalizers, so delete its records before removing the finalizer to let it go
+		if service.DeletionTimestamp != nil {
+			if !hasFinalizer(service) {
+				return "skipped", changes, nil
+			}
+			return finalizeService(ctx, cf, kubeClientset, recorder, service, initiator)
+		}
+
+		// manage the records with the credentials of the cloudflare account the service belongs to
+		cf, err = getObjectCloudflareClient(cf, secretLister, service.Annotations, "Service", service.Name, service.Namespace)
+		if err != nil {
+			log.Error().Err(err).Msgf("[%v] Service %v.%v - Getting Cloudflare credentials failed", initiator, service.Name, service.Namespace)
+			recorder.Eventf(service, v1.EventTypeWarning, "CredentialsLookupFailed", "Getting Cloudflare credentials failed: %v", err)
+			return status, changes, err
+		}
+
+		start := time.Now()
+
+		desiredState := getDesiredServiceState(service)
+		currentState := getCurrentServiceState(ctx, service)
+
+		if finalState == nil {
+			finalState = &CloudflareState{}
+		}
+		status, changes, err = makeServiceChanges(ctx, cf, kubeClientset, recorder, service, initiator, desiredState, currentState, finalState)
+		status, err = handleZoneMissing("Service", service.Name, service.Namespace, status, err)
+
+		// keep the service from being deleted before its records are, for as long as it has managed records
+		if err == nil {
+			err = updateServiceFinalizer(ctx, kubeClientset, service, desiredState.Enabled == "true")
+			if err != nil {
+				log.Error().Err(err).Msgf("[%v] Service %v.%v - Updating finalizer has failed", initiator, service.Name, service.Namespace)
+				status = "failed"
+			}
+		}
+
+		logReconcileSummary("Service", service.Name, service.Namespace, initiator, status, getRecordCounts(status, getStoredRecords(currentState), finalState.Records), finalState.ZoneName, time.Since(start))
+
+		return
+	}
+
+	status = "skipped"
+
+	return status, changes, nil
+}
+
+// finalizeService deletes the records of a service that is being deleted and then removes its finalizer; the finalizer stays until all stored records are gone, so deleting the ones that failed or are in a paused zone gets retried
+func finalizeService(ctx context.Context, cf *Cloudflare, kubeClientset kubernetes.Interface, recorder record.EventRecorder, service *v1.Service, initiator string) (status string, changes int, err error) {
+
+	status, changes, err = deleteService(ctx, cf, kubeClientset, recorder, service, initiator+":deleted")
+	if err != nil {
+		return status, changes, err
+	}
+
+	// mark the service before removing the finalizer, since its delete event can come in right after
+	key := getFinalizedObjectKey("Service", service.Namespace, service.Name)
+	finalizedObjects.Store(key, true)
+
+	err = updateServiceFinalizer(ctx, kubeClientset, service, false)
+	if err != nil {
+		finalizedObjects.Delete(key)
+		log.Error().Err(err).Msgf("[%v] Service %v.%v - Removing finalizer has failed", initiator, service.Name, service.Namespace)
+		return "failed", changes, err
+	}
+
+	log.Info().Msgf("[%v] Service %v.%v - Removed finalizer after deleting dns records", initiator, service.Name, service.Namespace)
+
+	return status, changes, nil
+}
+
+func deleteService(ctx context.Context, cf *Cloudflare, kubeClientset kubernetes.Interface, recorder record.EventRecorder, service *v1.Service, initiator string) (status string, changes int, err error) {
+
+	status = "failed"
+
+	if service != nil {
+
+		// manage the records with the credentials of the cloudflare account the service belongs to
+		cf, err = getObjectCloudflareClient(cf, secretLister, service.Annotations, "Service", service.Name, service.Namespace)
+		if errors.Is(err, errCredentialsSecretNotFound) {
+			// without its credentials the records can't be deleted, so leave them behind instead of blocking the deletion of the service and its namespace for good
+			log.Warn().Err(err).Msgf("[%v] Service %v.%v - Leaving dns records at Cloudflare, because the credentials secret is gone", initiator, service.Name, service.Namespace)
+			recorder.Eventf(service, v1.EventTypeWarning, "CredentialsSecretMissing", "Leaving dns records at Cloudflare, because the credentials secret is gone: %v", err)
+			if stateStore != nil {
+				if err := stateStore.remove(ctx, "Service", service.Namespace, service.Name); err != nil {
+					log.Warn().Err(err).Msgf("[%v] Service %v.%v - Removing service state from configmap has failed", initiator, service.Name, service.Namespace)
+				}
+			}
+			return "skipped", changes, nil
+		}
+		if err != nil {
+			log.Error().Err(err).Msgf("[%v] Service %v.%v - Getting Cloudflare credentials failed", initiator, service.Name, service.Namespace)
+			recorder.Eventf(service, v1.EventTypeWarning, "CredentialsLookupFailed", "Getting Cloudflare credentials failed: %v", err)
+			return status, changes, err
+		}
+
+		desiredState := getDesiredServiceState(service)
+		currentState := getCurrentServiceState(ctx, service)
+
+		// count the records that failed to get deleted, to retry deleting the object if retrying can help
+		failures := 0
+
+		// delete the records for the annotations as well as the stored ones, since the hostnames and load balancer ip addresses might have changed since the records were created; this includes the records for all ip addresses of the load balancer and the origin and internal records
+		recordsChanges, recordsFailures, recordsLeftBehind := deleteManagedRecords(cf, recorder, service, "Service", service.Name, service.Namespace, initiator, getRecordsToDelete(getServiceManagedRecords(cf, desiredState), getStoredRecords(currentState)))
+		failures += recordsFailures
+		if recordsChanges > 0 {
+			changes += recordsChanges
+			status = "deleted"
+		}
+
+		// loop all srv and loc records
+		structuredRecords, _ := getStructuredRecords(desiredState)
+		for _, structuredRecord := range structuredRecords {
+			log.Info().Msgf("[%v] Service %v.%v - Deleting dns record %v (%v)...", initiator, service.Name, service.Namespace, structuredRecord.Name, structuredRecord.Type)
+			_, err := cf.DeleteStructuredDNSRecordIfMatching(structuredRecord.Type, structuredRecord.Name, structuredRecord.Data)
+			if err != nil {
+				log.Warn().Err(err).Msgf("[%v] Service %v.%v - Failed deleting dns record %v (%v)...", initiator, service.Name, service.Namespace, structuredRecord.Name, structuredRecord.Type)
+				recorder.Eventf(service, v1.EventTypeWarning, "DNSRecordDeleteFailed", "Deleting dns record %v (%v) failed: %v", structuredRecord.Name, structuredRecord.Type, err)
+				if countDeleteFailure("Service", service.Namespace, err) {
+					failures++
+				}
+			} else {
+				recorder.Eventf(service, v1.EventTypeNormal, "DNSRecordDeleted", "Deleted dns record %v (%v)", structuredRecord.Name, structuredRecord.Type)
+				changes++
+				status = "deleted"
+			}
+		}
+
+		// loop all caa records
+		caaRecords, _ := parseCAARecords(desiredState.CAARecords)
+		for _, caaRecord := range caaRecords {
+			log.Info().Msgf("[%v] Service %v.%v - Deleting dns record %v (CAA) with data %v...", initiator, service.Name, service.Namespace, caaRecord.Name, caaRecord.Data)
+			_, err := cf.DeleteCAARecordIfMatching(caaRecord.Name, caaRecord.Data)
+			if err != nil {
+				log.Warn().Err(err).Msgf("[%v] Service %v.%v - Failed deleting dns record %v (CAA) with data %v...", initiator, service.Name, service.Namespace, caaRecord.Name, caaRecord.Data)
+				recorder.Eventf(service, v1.EventTypeWarning, "DNSRecordDeleteFailed", "Deleting dns record %v (CAA) with data %v failed: %v", caaRecord.Name, caaRecord.Data, err)
+				if countDeleteFailure("Service", service.Namespace, err) {
+					failures++
+				}
+			} else {
+				recorder.Eventf(service, v1.EventTypeNormal, "DNSRecordDeleted", "Deleted dns record %v (CAA) with data %v", caaRecord.Name, caaRecord.Data)
+				changes++
+				status = "deleted"
+			}
+		}
+
+		// loop all delegated names
+		nsRecords, _ := parseNSRecords(desiredState.NSRecords)
+		for _, nsRecord := range nsRecords {
+			log.Info().Msgf("[%v] Service %v.%v - Deleting dns records %v (NS)...", initiator, service.Name, service.Namespace, nsRecord.Name)
+			_, err := cf.DeleteNSRecords(nsRecord.Name)
+			if err != nil {
+				log.Warn().Err(err).Msgf("[%v] Service %v.%v - Failed deleting dns records %v (NS)...", initiator, service.Name, service.Namespace, nsRecord.Name)
+				recorder.Eventf(service, v1.EventTypeWarning, "DNSRecordDeleteFailed", "Deleting dns records %v (NS) failed: %v", nsRecord.Name, err)
+				if countDeleteFailure("Service", service.Namespace, err) {
+					failures++
+				}
+			} else {
+				recorder.Eventf(service, v1.EventTypeNormal, "DNSRecordDeleted", "Deleted dns records %v (NS)", nsRecord.Name)
+				changes++
+				status = "deleted"
+			}
+		}
+
+		// keep the stored state until all records are gone, so retries know which ones to delete
+		if failures > 0 {
+			return status, changes, getDeleteFailuresError(failures)
+		}
+
+		// records in zones that can't be written to right now, like paused ones, are still there as well, so keep the stored state and with it the finalizer until they're gone
+		if recordsLeftBehind > 0 {
+			return status, changes, fmt.Errorf("%v dns record(s) of the service are still at Cloudflare", recordsLeftBehind)
+		}
+
+		// the stored state is of no use anymore once the service is gone
+		if stateStore != nil {
+			if err := stateStore.remove(ctx, "Service", service.Namespace, service.Name); err != nil {
+				log.Warn().Err(err).Msgf("[%v] Service %v.%v - Removing service state from configmap has failed", initiator, service.Name, service.Namespace)
+			}
+		}
+
+		return status, changes, nil
+	}
+
+	status = "skipped"
+
+	return status, changes, nil
+}
+
+// deleteRecordsFromState deletes the dns records recorded in a previously stored state, as long as they still point to the stored values; records that are already gone or changed are left alone
+func deleteRecordsFromState(cf *Cloudflare, recorder record.EventRecorder, obj apiruntime.Object, kind, name, namespace, initiator string, state CloudflareState) (changes int, err error) {
+
+	failures := 0
+
+	// delete exactly the records that have been created if they're tracked, otherwise derive them from the other state fields
+	if len(state.Records) > 0 {
+		changes, failures, _ = deleteManagedRecords(cf, recorder, obj, kind, name, namespace, initiator, state.Records)
+	} else {
+		dnsRecordType, dnsRecordContent := getHostnameDNSRecord(state)
+
+		if state.Hostnames != "" && dnsRecordContent != "" {
+			hostnames := splitHostnames(state.Hostnames)
+			for _, hostname := range hostnames {
+				log.Info().Msgf("[%v] %v %v.%v - Deleting dns record %v (%v) with content %v...", initiator, kind, name, namespace, hostname, dnsRecordType, dnsRecordContent)
+				_, err := cf.DeleteDNSRecordIfMatching(hostname, dnsRecordType, dnsRecordContent)
+				if err != nil {
+					log.Warn().Err(err).Msgf("[%v] %v %v.%v - Deleting dns record %v (%v) with content %v failed", initiator, kind, name, namespace, hostname, dnsRecordType, dnsRecordContent)
+					recorder.Eventf(obj, v1.EventTypeWarning, "DNSRecordDeleteFailed", "Deleting dns record %v (%v) with content %v failed: %v", hostname, dnsRecordType, dnsRecordContent, err)
+					if countDeleteFailure(kind, namespace, err) {
+						failures++
+					}
+				} else {
+					recorder.Eventf(obj, v1.EventTypeNormal, "DNSRecordDeleted", "Deleted dns record %v (%v) with content %v", hostname, dnsRecordType, dnsRecordContent)
+					changes++
+				}
+			}
+		}
+
+		if state.CNAMETarget == "" && state.UseOriginRecord == "true" && state.OriginRecordHostname != "" && state.IPAddress != "" {
+			originDNSRecordType := getTargetDNSRecordType(state)
+			log.Info().Msgf("[%v] %v %v.%v - Deleting origin dns record %v (%v) with content %v...", initiator, kind, name, namespace, state.OriginRecordHostname, originDNSRecordType, state.IPAddress)
+			_, err := cf.DeleteDNSRecordIfMatching(state.OriginRecordHostname, originDNSRecordType, state.IPAddress)
+			if err != nil {
+				log.Warn().Err(err).Msgf("[%v] %v %v.%v - Deleting origin dns record %v (%v) with content %v failed", initiator, kind, name, namespace, state.OriginRecordHostname, originDNSRecordType, state.IPAddress)
+				recorder.Eventf(obj, v1.EventTypeWarning, "DNSRecordDeleteFailed", "Deleting origin dns record %v (%v) with content %v failed: %v", state.OriginRecordHostname, originDNSRecordType, state.IPAddress, err)
+				if countDeleteFailure(kind, namespace, err) {
+					failures++
+				}
+			} else {
+				recorder.Eventf(obj, v1.EventTypeNormal, "DNSRecordDeleted", "Deleted origin dns record %v (%v) with content %v", state.OriginRecordHostname, originDNSRecordType, state.IPAddress)
+				changes++
+			}
+		}
+
+		if state.InternalHostnames != "" && state.InternalIPAddress != "" && state.InternalDNS != "false" {
+			internalHostnames := splitHostnames(state.InternalHostnames)
+			for _, internalHostname := range internalHostnames {
+				log.Info().Msgf("[%v] %v %v.%v - Deleting dns record %v (A) with internal ip address %v...", initiator, kind, name, namespace, internalHostname, state.InternalIPAddress)
+				_, err := cf.DeleteDNSRecordIfMatching(internalHostname, "A", state.InternalIPAddress)
+				if err != nil {
+					log.Warn().Err(err).Msgf("[%v] %v %v.%v - Deleting dns record %v (A) with internal ip address %v failed", initiator, kind, name, namespace, internalHostname, state.InternalIPAddress)
+					recorder.Eventf(obj, v1.EventTypeWarning, "DNSRecordDeleteFailed", "Deleting dns record %v (A) with internal ip address %v failed: %v", internalHostname, state.InternalIPAddress, err)
+					if countDeleteFailure(kind, namespace, err) {
+						failures++
+					}
+				} else {
+					recorder.Eventf(obj, v1.EventTypeNormal, "DNSRecordDeleted", "Deleted dns record %v (A) with internal ip address %v", internalHostname, state.InternalIPAddress)
+					changes++
+				}
+			}
+		}
+	}
+
+	structuredRecords, _ := getStructuredRecords(state)
+	for _, structuredRecord := range structuredRecords {
+		log.Info().Msgf("[%v] %v %v.%v - Deleting dns record %v (%v)...", initiator, kind, name, namespace, structuredRecord.Name, structuredRecord.Type)
+		_, err := cf.DeleteStructuredDNSRecordIfMatching(structuredRecord.Type, structuredRecord.Name, structuredRecord.Data)
+		if err != nil {
+			log.Warn().Err(err).Msgf("[%v] %v %v.%v - Deleting dns record %v (%v) failed", initiator, kind, name, namespace, structuredRecord.Name, structuredRecord.Type)
+			recorder.Eventf(obj, v1.EventTypeWarning, "DNSRecordDeleteFailed", "Deleting dns record %v (%v) failed: %v", structuredRecord.Name, structuredRecord.Type, err)
+			if countDeleteFailure(kind, namespace, err) {
+				failures++
+			}
+		} else {
+			recorder.Eventf(obj, v1.EventTypeNormal, "DNSRecordDeleted", "Deleted dns record %v (%v)", structuredRecord.Name, structuredRecord.Type)
+			changes++
+		}
+	}
+
+	caaRecords, _ := parseCAARecords(state.CAARecords)
+	for _, caaRecord := range caaRecords {
+		log.Info().Msgf("[%v] %v %v.%v - Deleting dns record %v (CAA) with data %v...", initiator, kind, name, namespace, caaRecord.Name, caaRecord.Data)
+		_, err := cf.DeleteCAARecordIfMatching(caaRecord.Name, caaRecord.Data)
+		if err != nil {
+			log.Warn().Err(err).Msgf("[%v] %v %v.%v - Deleting dns record %v (CAA) with data %v failed", initiator, kind, name, namespace, caaRecord.Name, caaRecord.Data)
+			recorder.Eventf(obj, v1.EventTypeWarning, "DNSRecordDeleteFailed", "Deleting dns record %v (CAA) with data %v failed: %v", caaRecord.Name, caaRecord.Data, err)
+			if countDeleteFailure(kind, namespace, err) {
+				failures++
+			}
+		} else {
+			recorder.Eventf(obj, v1.EventTypeNormal, "DNSRecordDeleted", "Deleted dns record %v (CAA) with data %v", caaRecord.Name, caaRecord.Data)
+			changes++
+		}
+	}
+
+	nsRecords, _ := parseNSRecords(state.NSRecords)
+	for _, nsRecord := range nsRecords {
+		log.Info().Msgf("[%v] %v %v.%v - Deleting dns records %v (NS)...", initiator, kind, name, namespace, nsRecord.Name)
+		_, err := cf.DeleteNSRecords(nsRecord.Name)
+		if err != nil {
+			log.Warn().Err(err).Msgf("[%v] %v %v.%v - Deleting dns records %v (NS) failed", initiator, kind, name, namespace, nsRecord.Name)
+			recorder.Eventf(obj, v1.EventTypeWarning, "DNSRecordDeleteFailed", "Deleting dns records %v (NS) failed: %v", nsRecord.Name, err)
+			if countDeleteFailure(kind, namespace, err) {
+				failures++
+			}
+		} else {
+			recorder.Eventf(obj, v1.EventTypeNormal, "DNSRecordDeleted", "Deleted dns records %v (NS)", nsRecord.Name)
+			changes++
+		}
+	}
+
+	return changes, getDeleteFailuresError(failures)
+}
+
+// getManagedRecords returns the hostname, origin and internal records the controller creates for a state; hostnameDNSRecord returns the type and content of the record for a hostname, or an empty type if it doesn't get one
+func getManagedRecords(state CloudflareState, hostnameDNSRecord func(hostname string) (dnsRecordType, dnsRecordContent string)) (records []managedRecord) {
+
+	records = []managedRecord{}
+
+	if state.Enabled != "true" {
+		return
+	}
+
+	if len(state.Hostnames) > 0 && (state.IPAddress != "" || state.CNAMETarget != "") {
+		if state.CNAMETarget == "" && state.UseOriginRecord == "true" && state.OriginRecordHostname != "" {
+			records = append(records, managedRecord{Name: state.OriginRecordHostname, Type: getTargetDNSRecordType(state), Content: state.IPAddress, TTL: automaticTTL})
+		}
+
+		for _, hostname := range splitHostnames(state.Hostnames) {
+			if validateHostname(hostname) != "" {
+				continue
+			}
+			dnsRecordType, dnsRecordContent := hostnameDNSRecord(hostname)
+			if dnsRecordType == "" {
+				continue
+			}
+			records = append(records, managedRecord{Name: hostname, Type: dnsRecordType, Content: dnsRecordContent, Proxied: state.Proxy == "true", TTL: getStateTTL(state)})
+
+			// a hostname pointing at the load balancer has a record for each of its other ip addresses as well
+			if dnsRecordType != "CNAME" && dnsRecordContent == state.IPAddress && state.AdditionalIPAddresses != "" {
+				for _, ipAddress := range strings.Split(state.AdditionalIPAddresses, ",") {
+					records = append(records, managedRecord{Name: hostname, Type: dnsRecordType, Content: ipAddress, Proxied: state.Proxy == "true", TTL: getStateTTL(state)})
+				}
+			}
+		}
+	}
+
+	if len(state.InternalHostnames) > 0 && state.InternalIPAddress != "" && state.InternalDNS != "false" {
+		for _, internalHostname := range splitHostnames(state.InternalHostnames) {
+			if validateHostname(internalHostname) != "" {
+				continue
+			}
+			records = append(records, managedRecord{Name: internalHostname, Type: "A", Content: state.InternalIPAddress, TTL: automaticTTL})
+		}
+	}
+
+	return
+}
+
+// getServiceManagedRecords returns the records the controller creates for the state of a service, taking into account that the zone apex doesn't get a CNAME record to the origin
+func getServiceManagedRecords(zones zoneApexChecker, state CloudflareState) []managedRecord {
+	return getManagedRecords(state, func(hostname string) (string, string) {
+		dnsRecordType, dnsRecordContent, isApex := getServiceHostnameDNSRecord(zones, state, hostname)
+		if isApex && dnsRecordType == "CNAME" && state.Proxy == "false" {
+			return "", ""
+		}
+		return dnsRecordType, dnsRecordContent
+	})
+}
+
+// getStateManagedRecords returns the records the controller creates for the state of an ingress or httproute
+func getStateManagedRecords(state CloudflareState) []managedRecord {
+	return getManagedRecords(state, func(hostname string) (string, string) {
+		return getHostnameDNSRecord(state)
+	})
+}
+
+// getStateTTL returns the ttl the records for the hostnames get, which is automatic unless set with the ttl annotation for records that aren't proxied
+func getStateTTL(state CloudflareState) int {
+
+	ttl, err := strconv.Atoi(state.TTL)
+	if err != nil || state.Proxy == "true" {
+		return automaticTTL
+	}
+
+	return ttl
+}
+
+// removeNotOwnedRecords leaves out the records that exist at cloudflare without the ownership marker, so they don't end up in the stored state and get deleted later on
+func removeNotOwnedRecords(records []managedRecord, notOwnedRecords map[string]bool) []managedRecord {
+
+	ownedRecords := []managedRecord{}
+	for _, record := range records {
+		if notOwnedRecords[record.Name] {
+			continue
+		}
+		ownedRecords = append(ownedRecords, record)
+	}
+
+	return ownedRecords
+}
+
+// setUpsertedRecordDetails sets the zone each record has been upserted in and the ttl cloudflare returned for it, so the stored records match the ones at cloudflare; the zone is left empty for records that haven't been upserted by this reconcile
+func setUpsertedRecordDetails(records []managedRecord, upsertedRecords map[string]DNSRecord) []managedRecord {
+
+	for i := range records {
+		upsertedRecord := upsertedRecords[records[i].Name]
+		records[i].Zone = upsertedRecord.ZoneName
+		if upsertedRecord.TTL > 0 {
+			records[i].TTL = upsertedRecord.TTL
+		}
+	}
+
+	return records
+}
+
+// recordCounts holds the number of records a reconcile created, updated and deleted
+type recordCounts struct {
+	Created int
+	Updated int
+	Deleted int
+}
+
+// getRecordCounts compares the records stored before a reconcile with the ones it ended up with; only reconciles that succeeded or deleted the records changed anything
+func getRecordCounts(status string, storedRecords, finalRecords []managedRecord) (counts recordCounts) {
+
+	if status == "deleted" {
+		counts.Deleted = len(storedRecords)
+		return
+	}
+	if status != "succeeded" {
+		return
+	}
+
+	storedRecordsByName := map[string]managedRecord{}
+	for _, r := range storedRecords {
+		storedRecordsByName[r.Name] = r
+	}
+
+	finalNames := map[string]bool{}
+	for _, r := range finalRecords {
+		finalNames[r.Name] = true
+
+		storedRecord, ok := storedRecordsByName[r.Name]
+
+		// state stored before zones were tracked lacks them, which isn't a change at Cloudflare
+		if ok && storedRecord.Zone == "" {
+			storedRecord.Zone = r.Zone
+		}
+
+		if !ok {
+			counts.Created++
+		} else if storedRecord != r {
+			counts.Updated++
+		}
+	}
+
+	for _, r := range storedRecords {
+		if !finalNames[r.Name] {
+			counts.Deleted++
+		}
+	}
+
+	return
+}
+
+// logReconcileSummary logs a single structured event at the end of the reconcile of an object, for indexing and building dashboards
+func logReconcileSummary(kind, name, namespace, initiator, status string, counts recordCounts, zoneName string, duration time.Duration) {
+	log.Info().
+		Str("kind", kind).
+		Str("namespace", namespace).
+		Str("name", name).
+		Str("initiator", initiator).
+		Str("status", status).
+		Int("records_created", counts.Created).
+		Int("records_updated", counts.Updated).
+		Int("records_deleted", counts.Deleted).
+		Str("zone", zoneName).
+		Int64("duration_ms", duration.Milliseconds()).
+		Msgf("[%v] %v %v.%v - Reconcile summary", initiator, kind, name, namespace)
+}
+
+// getStoredRecords returns the records in the stored state, deriving them from the other state fields for state that has been stored before the records were tracked
+func getStoredRecords(state CloudflareState) []managedRecord {
+
+	if len(state.Records) > 0 {
+		return state.Records
+	}
+
+	return getStateManagedRecords(state)
+}
+
+// getRecordsToDelete returns the desired and stored records, leaving out stored records with the same name, type and content as a desired one
+func getRecordsToDelete(desiredRecords, storedRecords []managedRecord) (records []managedRecord) {
+
+	records = []managedRecord{}
+	seen := map[string]bool{}
+	for _, r := range append(append([]managedRecord{}, desiredRecords...), storedRecords...) {
+		key := r.Name + " " + r.Type + " " + r.Content
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		records = append(records, r)
+	}
+
+	return
+}
+
+// deleteStaleRecords deletes the stored records for names that are no longer desired, like the ones of hostnames that got removed or changed right before deleting an object; records for names that are still desired have been replaced by the upserts already
+func deleteStaleRecords(cf *Cloudflare, recorder record.EventRecorder, obj apiruntime.Object, kind, name, namespace, initiator string, desiredRecords, storedRecords []managedRecord) (changes, failures int) {
+
+	desiredNames := map[string]bool{}
+	for _, r := range desiredRecords {
+		desiredNames[r.Name] = true
+	}
+
+	staleRecords := []managedRecord{}
+	for _, r := range storedRecords {
+		if !desiredNames[r.Name] {
+			staleRecords = append(staleRecords, r)
+		}
+	}
+
+	changes, failures, _ = deleteManagedRecords(cf, recorder, obj, kind, name, namespace, initiator, staleRecords)
+
+	return changes, failures
+}
+
+// deleteManagedRecords deletes the records if they still have the type and content they've been created with; failures counts the records worth retrying and leftBehind the other ones that are still at cloudflare
+func deleteManagedRecords(cf *Cloudflare, recorder record.EventRecorder, obj apiruntime.Object, kind, name, namespace, initiator string, records []managedRecord) (changes, failures, leftBehind int) {
+
+	for _, r := range records {
+		log.Info().Msgf("[%v] %v %v.%v - Deleting dns record %v (%v) with content %v...", initiator, kind, name, namespace, r.Name, r.Type, r.Content)
+		_, err := cf.DeleteDNSRecordIfMatching(r.Name, r.Type, r.Content)
+		if errors.Is(err, errDNSRecordNotFound) {
+			log.Debug().Msgf("[%v] %v %v.%v - Dns record %v (%v) is gone already", initiator, kind, name, namespace, r.Name, r.Type)
+			continue
+		}
+		if err != nil {
+			log.Warn().Err(err).Msgf("[%v] %v %v.%v - Deleting dns record %v (%v) with content %v failed", initiator, kind, name, namespace, r.Name, r.Type, r.Content)
+			recorder.Eventf(obj, v1.EventTypeWarning, "DNSRecordDeleteFailed", "Deleting dns record %v (%v) with content %v failed: %v", r.Name, r.Type, r.Content, err)
+			if countDeleteFailure(kind, namespace, err) {
+				failures++
+			} else if isDNSRecordLeftBehind(err) {
+				leftBehind++
+			}
+			continue
+		}
+		recorder.Eventf(obj, v1.EventTypeNormal, "DNSRecordDeleted", "Deleted dns record %v (%v) with content %v", r.Name, r.Type, r.Content)
+		changes++
+	}
+
+	return changes, failures, leftBehind
+}
+
+// countDeleteFailure counts a failed delete of a dns record and returns true if it's worth retrying
+func countDeleteFailure(kind, namespace string, err error) bool {
+
+	if !isRetryableDeleteError(err) {
+		return false
+	}
+
+	deleteFailuresTotals.With(prometheus.Labels{"namespace": namespace, "type": strings.ToLower(kind)}).Inc()
+
+	return true
+}
+
+// getDeleteFailuresError returns an error if any dns records failed to get deleted, so the object gets requeued to retry them
+func getDeleteFailuresError(failures int) error {
+
+	if failures == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("Deleting %v dns record(s) failed", failures)
+}
+
+// getIngressClass returns the class of an ingress from its spec, or from the deprecated annotation for older ingresses
+func getIngressClass(ingress *networkingv1.Ingress) string {
+
+	if ingress.Spec.IngressClassName != nil {
+		return *ingress.Spec.IngressClassName
+	}
+
+	return ingress.Annotations[annotationIngressClass]
+}
+
+// isIngressClassMatching returns true if the ingress has the ingress class, or if no ingress class is set to match all of them
+func isIngressClassMatching(ingress *networkingv1.Ingress, ingressClass string) bool {
+
+	if ingressClass == "" {
+		return true
+	}
+
+	return getIngressClass(ingress) == ingressClass
+}
+
+func getDesiredIngressState(ingress *networkingv1.Ingress) (state CloudflareState) {
+
+	var ok bool
+
+	state.Enabled = getBooleanAnnotation(ingress.Annotations, annotationCloudflareDNS, false, "Ingress", ingress.Name, ingress.Namespace)
+	state.Hostnames, ok = ingress.Annotations[annotationCloudflareHostnames]
+	if !ok {
+		state.Hostnames = ""
+	}
+	state.InternalHostnames, ok = ingress.Annotations[annotationCloudflareInternalHostnames]
+	if !ok {
+		state.InternalHostnames = ""
+	}
+	state.Hostnames = normalizeHostnames(state.Hostnames)
+	state.InternalHostnames = normalizeHostnames(state.InternalHostnames)
+	state.InternalDNS = getBooleanAnnotation(ingress.Annotations, annotationCloudflareInternalDNS, true, "Ingress", ingress.Name, ingress.Namespace)
+	state.Proxy = getBooleanProxyAnnotation(ingress.Annotations, "Ingress", ingress.Name, ingress.Namespace)
+	state.ProxyPlans = getProxyPlansAnnotation(ingress.Annotations)
+	state.UseOriginRecord = getBooleanAnnotation(ingress.Annotations, annotationCloudflareUseOriginRecord, *defaultUseOriginRecord, "Ingress", ingress.Name, ingress.Namespace)
+	state.OriginRecordHostname = getOriginRecordHostname(ingress.Annotations, state.UseOriginRecord, state.Hostnames, "Ingress", ingress.Name, ingress.Namespace)
+	state.CNAMETarget, ok = ingress.Annotations[annotationCloudflareCNAMETarget]
+	if !ok {
+		state.CNAMETarget = ""
+	}
+	state.Comment, ok = ingress.Annotations[annotationCloudflareComment]
+	if !ok {
+		state.Comment = defaultCloudflareComment
+	}
+	state.TTL = getTTLAnnotation(ingress.Annotations, "Ingress", ingress.Name, ingress.Namespace)
+	state.Region = getRegionAnnotation(ingress.Annotations)
+	state.SSLMode = getSSLModeAnnotation(ingress.Annotations, "Ingress", ingress.Name, ingress.Namespace)
+	state.ZoneSettings = strings.TrimSpace(ingress.Annotations[annotationCloudflareZoneSettings])
+
+	if len(ingress.Status.LoadBalancer.Ingress) > 0 {
+		state.IPAddress, state.TargetIsHostname = getLoadBalancerTarget(ingress.Status.LoadBalancer.Ingress[0])
+	}
+
+	// ingresses have no cluster ip, so take the internal ip address from the annotation or else a private load balancer ip address
+	state.InternalIPAddress, ok = ingress.Annotations[annotationCloudflareInternalIPAddress]
+	if !ok {
+		state.InternalIPAddress = getPrivateLoadBalancerIPAddress(ingress.Status.LoadBalancer.Ingress)
+	}
+
+	return
+}
+
+func getCurrentIngressState(ctx context.Context, ingress *networkingv1.Ingress) (state CloudflareState) {
+
+	// get state stored in the configmap if enabled, falling back to the annotation for objects that haven't been reconciled since switching
+	if stateStore != nil {
+		if state, ok := stateStore.get(ctx, "Ingress", ingress.Namespace, ingress.Name); ok {
+			return state
+		}
+	}
+
+	// get state stored in annotations if present or set to empty struct
+	cloudflareStateString, ok := ingress.Annotations[annotationCloudflareState]
+	if !ok {
+		// couldn't find saved state, setting to default struct
+		state = CloudflareState{}
+		return
+	}
+
+	if err := decodeStateAnnotation(cloudflareStateString, &state); err != nil {
+		// couldn't deserialize, setting to default struct
+		countStateDecodeFailure("Ingress", ingress.Namespace, ingress.Name, cloudflareStateString, err)
+		state = CloudflareState{}
+		return
+	}
+
+	// return deserialized state
+	return
+}
+
+func makeIngressChanges(ctx context.Context, cf *Cloudflare, kubeClientset kubernetes.Interface, recorder record.EventRecorder, ingress *networkingv1.Ingress, initiator string, desiredState, currentState CloudflareState, finalState *CloudflareState) (status string, changes int, err error) {
+
+	status = "failed"
+	hasChanges := false
+
+	// the records as upserted, for the zone each hostname resolved to and the ttl cloudflare assigned
+	upsertedRecords := map[string]DNSRecord{}
+
+	// the names of the records that exist at cloudflare without the ownership marker, which are left alone and not stored
+	notOwnedRecords := map[string]bool{}
+
+	// look up the zones of the records once, instead of for every record operation
+	zones := newObjectZones(cf)
+
+	// upsert all records regardless of the stored state if requested, to heal changes made outside of the controller
+	forceUpdate := getBooleanAnnotation(ingress.Annotations, annotationCloudflareForceUpdate, false, "Ingress", ingress.Name, ingress.Namespace) == "true"
+
+	// the zone name and records aren't part of the desired state, so keep the stored ones until the records get upserted again
+	desiredState.ZoneName = currentState.ZoneName
+	desiredState.Records = currentState.Records
+
+	// hand the state as it ended up to the caller, for the reconcile summary
+	if finalState != nil {
+		defer func() {
+			*finalState = desiredState
+		}()
+	}
+
+	if *logReconcileDiff {
+		logStateDiff("Ingress", ingress.Name, ingress.Namespace, initiator, desiredState, currentState)
+	}
+
+	// check if dns has been disabled since the state was stored, in which case the previously managed records need to be cleaned up
+	if desiredState.Enabled != "true" && currentState.Enabled == "true" {
+
+		log.Info().Msgf("[%v] Ingress %v.%v - Dns has been disabled, deleting previously managed dns records...", initiator, ingress.Name, ingress.Namespace)
+
+		deletedChanges, err := deleteRecordsFromState(cf, recorder, ingress, "Ingress", ingress.Name, ingress.Namespace, initiator, currentState)
+		changes += deletedChanges
+		if err != nil {
+			// keep the stored state, so the records that failed to get deleted are retried
+			log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Deleting previously managed dns records has failed", initiator, ingress.Name, ingress.Namespace)
+			return status, changes, err
+		}
+
+		if stateStore != nil {
+			// remove the stored state, so the records get recreated if dns is enabled again
+			err = stateStore.remove(ctx, "Ingress", ingress.Namespace, ingress.Name)
+			if err != nil {
+				log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Removing ingress state from configmap has failed", initiator, ingress.Name, ingress.Namespace)
+				return status, changes, err
+			}
+		} else {
+			// remove the state annotation, so the records get recreated if dns is enabled again
+			patch, err := getStateAnnotationPatch(nil)
+			if err != nil {
+				log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Marshalling state patch failed", initiator, ingress.Name, ingress.Namespace)
+				return status, changes, err
+			}
+
+			_, err = kubeClientset.NetworkingV1().Ingresses(ingress.Namespace).Patch(ctx, ingress.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+			if err != nil {
+				log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Removing ingress state has failed", initiator, ingress.Name, ingress.Namespace)
+				return status, changes, err
+			}
+		}
+
+		status = "deleted"
+
+		return status, changes, nil
+	}
+
+	// check if ingress has estafette.io/cloudflare-dns annotation and it's value is true and
+	// check if ingress has estafette.io/cloudflare-hostnames annotation and it's value is not empty and
+	// check if type equals LoadBalancer and
+	// check if LoadBalancer has an ip address or a cname target is set
+	if desiredState.Enabled == "true" && len(desiredState.Hostnames) > 0 && (desiredState.IPAddress != "" || desiredState.CNAMETarget != "") {
+
+		// update dns record if anything has changed compared to the stored state
+		if forceUpdate ||
+			desiredState.IPAddress != currentState.IPAddress ||
+			desiredState.Hostnames != currentState.Hostnames ||
+			desiredState.Proxy != currentState.Proxy ||
+			desiredState.ProxyPlans != currentState.ProxyPlans ||
+			desiredState.UseOriginRecord != currentState.UseOriginRecord ||
+			desiredState.OriginRecordHostname != currentState.OriginRecordHostname ||
+			desiredState.CNAMETarget != currentState.CNAMETarget ||
+			desiredState.Comment != currentState.Comment ||
+			desiredState.TTL != currentState.TTL ||
+			desiredState.Region != currentState.Region ||
+			desiredState.SSLMode != currentState.SSLMode ||
+			desiredState.ZoneSettings != currentState.ZoneSettings {
+
+			hasChanges = true
+
+			// point to the load balancer with an A or AAAA record, or a CNAME record if it only has a hostname
+			dnsRecordType := getTargetDNSRecordType(desiredState)
+			hostnameDNSRecordType, _ := getHostnameDNSRecord(desiredState)
+
+			// if use origin is enabled, create a record for the origin
+			if desiredState.CNAMETarget == "" && desiredState.UseOriginRecord == "true" && desiredState.OriginRecordHostname != "" {
+
+				log.Info().Msgf("[%v] Ingress %v.%v - Upserting origin dns record %v (%v) to %v...", initiator, ingress.Name, ingress.Namespace, desiredState.OriginRecordHostname, dnsRecordType, desiredState.IPAddress)
+
+				originDNSRecord, upserted, err := zones.upsertDNSRecord(dnsRecordType, desiredState.OriginRecordHostname, desiredState.IPAddress, false, desiredState.Comment, desiredState.Region, 0)
+				if errors.Is(err, errDNSRecordNotOwned) {
+					log.Warn().Msgf("[%v] Ingress %v.%v - Origin dns record %v (%v) lacks the ownership marker, leaving it alone", initiator, ingress.Name, ingress.Namespace, desiredState.OriginRecordHostname, dnsRecordType)
+					notOwnedRecords[desiredState.OriginRecordHostname] = true
+				} else if err != nil {
+					getUpsertFailureLogEvent(err).Msgf("[%v] Ingress %v.%v - Upserting origin dns record %v (%v) to %v failed", initiator, ingress.Name, ingress.Namespace, desiredState.OriginRecordHostname, dnsRecordType, desiredState.IPAddress)
+					recorder.Eventf(ingress, v1.EventTypeWarning, "DNSRecordUpsertFailed", "Upserting origin dns record %v (%v) to %v failed: %v", desiredState.OriginRecordHostname, dnsRecordType, desiredState.IPAddress, err)
+					return status, changes, err
+				} else {
+					upsertedRecords[desiredState.OriginRecordHostname] = originDNSRecord
+					if upserted {
+						recorder.Eventf(ingress, v1.EventTypeNormal, "DNSRecordUpserted", "Upserted origin dns record %v (%v) to %v", desiredState.OriginRecordHostname, dnsRecordType, desiredState.IPAddress)
+						changes++
+					}
+				}
+			}
+
+			// loop all hostnames
+			hostnames := splitHostnames(desiredState.Hostnames)
+			zoneNames := []string{}
+			sslModeZones := map[string]bool{}
+			zoneSettingsZones := map[string]bool{}
+			zoneSettings, err := parseZoneSettings(desiredState.ZoneSettings)
+			if err != nil {
+				log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Parsing zone settings %v failed", initiator, ingress.Name, ingress.Namespace, desiredState.ZoneSettings)
+				return status, changes, err
+			}
+			// the ttl is set while upserting the records, 0 leaves it automatic
+			ttl, _ := strconv.Atoi(desiredState.TTL)
+			for _, hostname := range hostnames {
+
+				// validate hostname, skip if invalid
+				if reason := validateHostname(hostname); reason != "" {
+					log.Error().Msgf("[%v] Ingress %v.%v - Invalid dns record %v (%v), skipping", initiator, ingress.Name, ingress.Namespace, hostname, reason)
+					invalidHostnamesTotals.With(prometheus.Labels{"namespace": ingress.Namespace, "reason": reason}).Inc()
+					continue
+				}
+
+				// zones on plans other than the proxy plans can't proxy every record, so they get dns only records instead of failing
+				planAllowsProxy, plan := isProxyAllowedByZonePlan(zones.getZone, hostname, desiredState.ProxyPlans)
+				if !planAllowsProxy && desiredState.Proxy == "true" {
+					log.Warn().Msgf("[%v] Ingress %v.%v - Zone of dns record %v is on plan %v, which isn't one of the proxy plans %v, leaving it dns only", initiator, ingress.Name, ingress.Namespace, hostname, plan, desiredState.ProxyPlans)
+					recorder.Eventf(ingress, v1.EventTypeWarning, "ProxyDowngraded", "Zone of dns record %v is on plan %v, which isn't one of the proxy plans %v, leaving it dns only", hostname, plan, desiredState.ProxyPlans)
+				}
+				proxy := desiredState.Proxy == "true" && planAllowsProxy
+
+				var dnsRecord DNSRecord
+				var upserted bool
+				// if a cname target is set, create a CNAME record pointing to that target, else if use origin is enabled, create a CNAME record pointing to the origin record
+				if desiredState.CNAMETarget != "" {
+
+					log.Info().Msgf("[%v] Ingress %v.%v - Upserting dns record %v (CNAME) to value %v...", initiator, ingress.Name, ingress.Namespace, hostname, desiredState.CNAMETarget)
+
+					dnsRecord, upserted, err = zones.upsertDNSRecord("CNAME", hostname, desiredState.CNAMETarget, proxy, desiredState.Comment, desiredState.Region, ttl)
+					if errors.Is(err, errDNSRecordNotOwned) {
+						log.Warn().Msgf("[%v] Ingress %v.%v - Dns record %v (CNAME) lacks the ownership marker, leaving it alone", initiator, ingress.Name, ingress.Namespace, hostname)
+						notOwnedRecords[hostname] = true
+						continue
+					}
+					if err != nil {
+						getUpsertFailureLogEvent(err).Msgf("[%v] Ingress %v.%v - Upserting dns record %v (CNAME) to value %v failed", initiator, ingress.Name, ingress.Namespace, hostname, desiredState.CNAMETarget)
+						recorder.Eventf(ingress, v1.EventTypeWarning, "DNSRecordUpsertFailed", "Upserting dns record %v (CNAME) to value %v failed: %v", hostname, desiredState.CNAMETarget, err)
+						return status, changes, err
+					}
+					if upserted {
+						recorder.Eventf(ingress, v1.EventTypeNormal, "DNSRecordUpserted", "Upserted dns record %v (CNAME) to value %v in zone %v", hostname, desiredState.CNAMETarget, dnsRecord.ZoneName)
+						changes++
+					}
+				} else if desiredState.UseOriginRecord == "true" && desiredState.OriginRecordHostname != "" {
+
+					log.Info().Msgf("[%v] Ingress %v.%v - Upserting dns record %v (CNAME) to value %v...", initiator, ingress.Name, ingress.Namespace, hostname, desiredState.OriginRecordHostname)
+
+					dnsRecord, upserted, err = zones.upsertDNSRecord("CNAME", hostname, desiredState.OriginRecordHostname, proxy, desiredState.Comment, desiredState.Region, ttl)
+					if errors.Is(err, errDNSRecordNotOwned) {
+						log.Warn().Msgf("[%v] Ingress %v.%v - Dns record %v (CNAME) lacks the ownership marker, leaving it alone", initiator, ingress.Name, ingress.Namespace, hostname)
+						notOwnedRecords[hostname] = true
+						continue
+					}
+					if err != nil {
+						getUpsertFailureLogEvent(err).Msgf("[%v] Ingress %v.%v - Upserting dns record %v (CNAME) to value %v failed", initiator, ingress.Name, ingress.Namespace, hostname, desiredState.OriginRecordHostname)
+						recorder.Eventf(ingress, v1.EventTypeWarning, "DNSRecordUpsertFailed", "Upserting dns record %v (CNAME) to value %v failed: %v", hostname, desiredState.OriginRecordHostname, err)
+						return status, changes, err
+					}
+					if upserted {
+						recorder.Eventf(ingress, v1.EventTypeNormal, "DNSRecordUpserted", "Upserted dns record %v (CNAME) to value %v in zone %v", hostname, desiredState.OriginRecordHostname, dnsRecord.ZoneName)
+						changes++
+					}
+				} else {
+
+					log.Info().Msgf("[%v] Ingress %v.%v - Upserting dns record %v (%v) to %v...", initiator, ingress.Name, ingress.Namespace, hostname, dnsRecordType, desiredState.IPAddress)
+
+					dnsRecord, upserted, err = zones.upsertDNSRecord(dnsRecordType, hostname, desiredState.IPAddress, proxy, desiredState.Comment, desiredState.Region, ttl)
+					if errors.Is(err, errDNSRecordNotOwned) {
+						log.Warn().Msgf("[%v] Ingress %v.%v - Dns record %v (%v) lacks the ownership marker, leaving it alone", initiator, ingress.Name, ingress.Namespace, hostname, dnsRecordType)
+						notOwnedRecords[hostname] = true
+						continue
+					}
+					if err != nil {
+						getUpsertFailureLogEvent(err).Msgf("[%v] Ingress %v.%v - Upserting dns record %v (%v) to %v failed", initiator, ingress.Name, ingress.Namespace, hostname, dnsRecordType, desiredState.IPAddress)
+						recorder.Eventf(ingress, v1.EventTypeWarning, "DNSRecordUpsertFailed", "Upserting dns record %v (%v) to %v failed: %v", hostname, dnsRecordType, desiredState.IPAddress, err)
+						return status, changes, err
+					}
+					if upserted {
+						recorder.Eventf(ingress, v1.EventTypeNormal, "DNSRecordUpserted", "Upserted dns record %v (%v) to %v in zone %v", hostname, dnsRecordType, desiredState.IPAddress, dnsRecord.ZoneName)
+						changes++
+					}
+				}
+
+				log.Info().Msgf("[%v] Ingress %v.%v - Dns record %v is in zone %v", initiator, ingress.Name, ingress.Namespace, hostname, dnsRecord.ZoneName)
+				zoneNames = appendZoneName(zoneNames, dnsRecord.ZoneName)
+				upsertedRecords[hostname] = dnsRecord
+
+				// if proxy is enabled, update it at Cloudflare
+				if proxy {
+					log.Info().Msgf("[%v] Ingress %v.%v - Enabling proxying for dns record %v (%v)...", initiator, ingress.Name, ingress.Namespace, hostname, hostnameDNSRecordType)
+				} else {
+					log.Info().Msgf("[%v] Ingress %v.%v - Disabling proxying for dns record %v (%v)...", initiator, ingress.Name, ingress.Namespace, hostname, hostnameDNSRecordType)
+				}
+
+				_, err := zones.updateProxySetting(hostnameDNSRecordType, hostname, proxy)
+				if err != nil {
+					if proxy {
+						log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Enabling proxying for dns record %v (%v) failed", initiator, ingress.Name, ingress.Namespace, hostname, hostnameDNSRecordType)
+						recorder.Eventf(ingress, v1.EventTypeWarning, "ProxySettingUpdateFailed", "Enabling proxying for dns record %v (%v) failed: %v", hostname, hostnameDNSRecordType, err)
+					} else {
+						log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Disabling proxying for dns record %v (%v) failed", initiator, ingress.Name, ingress.Namespace, hostname, hostnameDNSRecordType)
+						recorder.Eventf(ingress, v1.EventTypeWarning, "ProxySettingUpdateFailed", "Disabling proxying for dns record %v (%v) failed: %v", hostname, hostnameDNSRecordType, err)
+					}
+
+					return status, changes, err
+				}
+				recorder.Eventf(ingress, v1.EventTypeNormal, "ProxySettingUpdated", "Set proxying for dns record %v (%v) to %v", hostname, hostnameDNSRecordType, proxy)
+
+				// the ssl mode applies to the whole zone, so only set it if asked for and once per zone
+				if desiredState.SSLMode != "" && desiredState.Proxy == "true" && !sslModeZones[dnsRecord.ZoneName] {
+					sslModeZones[dnsRecord.ZoneName] = true
+
+					sslModeChanges, err := updateZoneSSLMode(zones, recorder, ingress, "Ingress", ingress.Name, ingress.Namespace, initiator, hostname, desiredState.SSLMode)
+					if err != nil {
+						return status, changes, err
+					}
+					changes += sslModeChanges
+				}
+
+				// zone settings apply to the whole zone as well, so only apply them if asked for and once per zone
+				if len(zoneSettings) > 0 && !zoneSettingsZones[dnsRecord.ZoneName] {
+					zoneSettingsZones[dnsRecord.ZoneName] = true
+
+					zoneSettingsChanges, err := updateZoneSettings(zones, recorder, ingress, "Ingress", ingress.Name, ingress.Namespace, initiator, hostname, zoneSettings)
+					if err != nil {
+						return status, changes, err
+					}
+					changes += zoneSettingsChanges
+				}
+			}
+
+			desiredState.ZoneName = strings.Join(zoneNames, ",")
+
+			// if use origin is disabled, remove the A or AAAA record for the origin, if state still has a value for OriginRecordHostname
+			if desiredState.OriginRecordHostname != "" && (desiredState.UseOriginRecord != "true" || desiredState.OriginRecordHostname == "") {
+
+				// the origin record got created for the previous address, so only delete it if it still has that type and content
+				originDNSRecordType := getTargetDNSRecordType(currentState)
+				log.Info().Msgf("[%v] Ingress %v.%v - Deleting origin dns record %v (%v) with content %v...", initiator, ingress.Name, ingress.Namespace, desiredState.OriginRecordHostname, originDNSRecordType, currentState.IPAddress)
+
+				_, err := cf.DeleteDNSRecordIfMatching(desiredState.OriginRecordHostname, originDNSRecordType, currentState.IPAddress)
+				if err != nil {
+					log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Deleting origin dns record %v (%v) with content %v failed", initiator, ingress.Name, ingress.Namespace, desiredState.OriginRecordHostname, originDNSRecordType, currentState.IPAddress)
+					recorder.Eventf(ingress, v1.EventTypeWarning, "DNSRecordDeleteFailed", "Deleting origin dns record %v (%v) with content %v failed: %v", desiredState.OriginRecordHostname, originDNSRecordType, currentState.IPAddress, err)
+					return status, changes, err
+				}
+				recorder.Eventf(ingress, v1.EventTypeNormal, "DNSRecordDeleted", "Deleted origin dns record %v (%v) with content %v", desiredState.OriginRecordHostname, originDNSRecordType, currentState.IPAddress)
+				changes++
+			}
+		}
+	}
+
+	// internal records can be disabled separately from the other records, in which case the stale record cleanup removes the ones created before
+	if desiredState.Enabled == "true" && desiredState.InternalDNS != currentState.InternalDNS {
+		hasChanges = true
+	}
+
+	// check if ingress has estafette.io/cloudflare-dns annotation and it's value is true and
+	// check if ingress has estafette.io/cloudflare-internal-dns annotation and it's value isn't false and
+	// check if ingress has estafette.io/cloudflare-internal-hostnames annotation and it's value is not empty and
+	// check if ingress has an internal ip address
+	if desiredState.Enabled == "true" && desiredState.InternalDNS != "false" && len(desiredState.InternalHostnames) > 0 && desiredState.InternalIPAddress != "" {
+
+		// update internal dns record if anything has changed compared to the stored state
+		if forceUpdate ||
+			desiredState.InternalDNS != currentState.InternalDNS ||
+			desiredState.InternalIPAddress != currentState.InternalIPAddress ||
+			desiredState.InternalHostnames != currentState.InternalHostnames ||
+			desiredState.Comment != currentState.Comment {
+
+			hasChanges = true
+
+			// loop all internal hostnames
+			internalHostnames := splitHostnames(desiredState.InternalHostnames)
+			for _, internalHostname := range internalHostnames {
+
+				log.Info().Msgf("[%v] Ingress %v.%v - Upserting dns record %v (A) to internal ip address %v...", initiator, ingress.Name, ingress.Namespace, internalHostname, desiredState.InternalIPAddress)
+
+				internalDNSRecord, upserted, err := zones.upsertDNSRecord("A", internalHostname, desiredState.InternalIPAddress, false, desiredState.Comment, "", 0)
+				if errors.Is(err, errDNSRecordNotOwned) {
+					log.Warn().Msgf("[%v] Ingress %v.%v - Dns record %v (A) lacks the ownership marker, leaving it alone", initiator, ingress.Name, ingress.Namespace, internalHostname)
+					notOwnedRecords[internalHostname] = true
+					continue
+				}
+				if err != nil {
+					getUpsertFailureLogEvent(err).Msgf("[%v] Ingress %v.%v - Upserting dns record %v (A) to internal ip address %v failed", initiator, ingress.Name, ingress.Namespace, internalHostname, desiredState.InternalIPAddress)
+					recorder.Eventf(ingress, v1.EventTypeWarning, "DNSRecordUpsertFailed", "Upserting dns record %v (A) to internal ip address %v failed: %v", internalHostname, desiredState.InternalIPAddress, err)
+					return status, changes, err
+				}
+				upsertedRecords[internalHostname] = internalDNSRecord
+				if upserted {
+					recorder.Eventf(ingress, v1.EventTypeNormal, "DNSRecordUpserted", "Upserted dns record %v (A) to internal ip address %v", internalHostname, desiredState.InternalIPAddress)
+					changes++
+				}
+			}
+		}
+	}
+
+	if hasChanges {
+
+		// clean up the records that are no longer desired, like the ones of removed hostnames, unless there's no address yet to know the desired records by
+		if desiredState.IPAddress != "" || desiredState.CNAMETarget != "" {
+			desiredState.Records = setUpsertedRecordDetails(removeNotOwnedRecords(getStateManagedRecords(desiredState), notOwnedRecords), upsertedRecords)
+			staleChanges, staleFailures := deleteStaleRecords(cf, recorder, ingress, "Ingress", ingress.Name, ingress.Namespace, initiator, desiredState.Records, getStoredRecords(currentState))
+			changes += staleChanges
+			if staleFailures > 0 {
+				// leave the stored state as is, so the stale records that failed to get deleted are retried
+				err = getDeleteFailuresError(staleFailures)
+				log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Deleting stale dns records has failed", initiator, ingress.Name, ingress.Namespace)
+				return status, changes, err
+			}
+		}
+
+		// if any state property changed make sure to update all
+		currentState = desiredState
+
+		log.Info().Msgf("[%v] Ingress %v.%v - Updating ingress because state has changed...", initiator, ingress.Name, ingress.Namespace)
+
+		if stateStore != nil {
+			// store state in the configmap, leaving the ingress itself untouched
+			err = stateStore.set(ctx, "Ingress", ingress.Namespace, ingress.Name, currentState)
+			if err != nil {
+				log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Storing ingress state in configmap has failed", initiator, ingress.Name, ingress.Namespace)
+				return status, changes, err
+			}
+		} else {
+			// serialize state and store it in the annotation
+			patch, err := getStateAnnotationPatch(&currentState)
+			if err != nil {
+				log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Marshalling state failed", initiator, ingress.Name, ingress.Namespace)
+				return status, changes, err
+			}
+
+			// only patch the state annotation, so it doesn't conflict with changes to the rest of the ingress
+			_, err = kubeClientset.NetworkingV1().Ingresses(ingress.Namespace).Patch(ctx, ingress.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+			if isAnnotationsTooLargeError(err) {
+				// the records are in place, so carry on without stored state; they get upserted again every cycle until the annotations shrink
+				log.Warn().Err(err).Msgf("[%v] Ingress %v.%v - Storing ingress state has failed, because its annotations exceed the limit of %v bytes; use --state-storage=configmap to store the state elsewhere", initiator, ingress.Name, ingress.Namespace, apivalidation.TotalAnnotationSizeLimitB)
+				err = nil
+			}
+			if err != nil {
+				log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Updating ingress state has failed", initiator, ingress.Name, ingress.Namespace)
+				return status, changes, err
+			}
+		}
+
+		// only force the update once
+		if forceUpdate {
+			patch, err := getAnnotationPatch(annotationCloudflareForceUpdate, nil)
+			if err != nil {
+				log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Marshalling force update annotation removal failed", initiator, ingress.Name, ingress.Namespace)
+				return status, changes, err
+			}
+
+			_, err = kubeClientset.NetworkingV1().Ingresses(ingress.Namespace).Patch(ctx, ingress.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+			if err != nil {
+				log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Removing force update annotation has failed", initiator, ingress.Name, ingress.Namespace)
+				return status, changes, err
+			}
+		}
+
+		status = "succeeded"
+
+		log.Info().Msgf("[%v] Ingress %v.%v - Ingress has been updated successfully...", initiator, ingress.Name, ingress.Namespace)
+
+		return status, changes, nil
+	}
+
+	status = "skipped"
+
+	return status, changes, nil
+}
+
+func processIngress(ctx context.Context, cf *Cloudflare, kubeClientset kubernetes.Interface, recorder record.EventRecorder, ingress *networkingv1.Ingress, initiator string) (status string, changes int, err error) {
+	return processIngressWithFinalState(ctx, cf, kubeClientset, recorder, ingress, initiator, nil)
+}
+
+// processIngressWithFinalState reconciles an ingress and hands the state it ended up with to the caller, so a full pass can count the managed records without looking up their zones again
+func processIngressWithFinalState(ctx context.Context, cf *Cloudflare, kubeClientset kubernetes.Interface, recorder record.EventRecorder, ingress *networkingv1.Ingress, initiator string, finalState *CloudflareState) (status string, changes int, err error) {
+
+	status = "failed"
+
+	if ingress != nil {
+
+		// leave ingresses handled by another ingress controller alone
+		if !isIngressClassMatching(ingress, *ingressClass) {
+			log.Debug().Msgf("[%v] Ingress %v.%v - Skipping, because its ingress class '%v' doesn't match '%v'", initiator, ingress.Name, ingress.Namespace, getIngressClass(ingress), *ingressClass)
+			return "skipped", changes, nil
+		}
+
+		// a ingress being deleted is only kept around by finalizers, so delete its records before removing the finalizer to let it go
+		if ingress.DeletionTimestamp != nil {
+			if !hasFinalizer(ingress) {
+				return "skipped", changes, nil
 			}
+			return finalizeIngress(ctx, cf, kubeClientset, recorder, ingress, initiator)
+		}
 
-			// loop all hostnames
-			hostnames := strings.Split(desiredState.Hostnames, ",")
-			for _, hostname := range hostnames {
+		// manage the records with the credentials of the cloudflare account the ingress belongs to
+		cf, err = getObjectCloudflareClient(cf, secretLister, ingress.Annotations, "Ingress", ingress.Name, ingress.Namespace)
+		if err != nil {
+			log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Getting Cloudflare credentials failed", initiator, ingress.Name, ingress.Namespace)
+			recorder.Eventf(ingress, v1.EventTypeWarning, "CredentialsLookupFailed", "Getting Cloudflare credentials failed: %v", err)
+			return status, changes, err
+		}
 
-				// validate hostname, skip if invalid
-				if !validateHostname(hostname) {
-					log.Error().Err(err).Msgf("[%v] Service %v.%v - Invalid dns record %v, skipping", initiator, service.Name, service.Namespace, hostname)
-					continue
-				}
+		start := time.Now()
 
-				// if use origin is enabled, create a CNAME record pointing to the origin record
-				if desiredState.UseOriginRecord == "true" && desiredState.OriginRecordHostname != "" {
+		desiredState := getDesiredIngressState(ingress)
+		currentState := getCurrentIngressState(ctx, ingress)
 
-					log.Info().Msgf("[%v] Service %v.%v - Upserting dns record %v (CNAME) to value %v...", initiator, service.Name, service.Namespace, hostname, desiredState.OriginRecordHostname)
+		if finalState == nil {
+			finalState = &CloudflareState{}
+		}
+		status, changes, err = makeIngressChanges(ctx, cf, kubeClientset, recorder, ingress, initiator, desiredState, currentState, finalState)
+		status, err = handleZoneMissing("Ingress", ingress.Name, ingress.Namespace, status, err)
 
-					_, err := cf.UpsertDNSRecord("CNAME", hostname, desiredState.OriginRecordHostname, desiredState.Proxy == "true")
-					if err != nil {
-						log.Error().Err(err).Msgf("[%v] Service %v.%v - Upserting dns record %v (CNAME) to value %v failed", initiator, service.Name, service.Namespace, hostname, desiredState.OriginRecordHostname)
-						return status, err
-					}
-				} else {
+		// keep the ingress from being deleted before its records are, for as long as it has managed records
+		if err == nil {
+			err = updateIngressFinalizer(ctx, kubeClientset, ingress, desiredState.Enabled == "true")
+			if err != nil {
+				log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Updating finalizer has failed", initiator, ingress.Name, ingress.Namespace)
+				status = "failed"
+			}
+		}
 
-					log.Info().Msgf("[%v] Service %v.%v - Upserting dns record %v (A) to ip address %v...", initiator, service.Name, service.Namespace, hostname, desiredState.IPAddress)
+		logReconcileSummary("Ingress", ingress.Name, ingress.Namespace, initiator, status, getRecordCounts(status, getStoredRecords(currentState), finalState.Records), finalState.ZoneName, time.Since(start))
 
-					_, err := cf.UpsertDNSRecord("A", hostname, desiredState.IPAddress, desiredState.Proxy == "true")
-					if err != nil {
-						log.Error().Err(err).Msgf("[%v] Service %v.%v - Upserting dns record %v (A) to ip address %v failed", initiator, service.Name, service.Namespace, hostname, desiredState.IPAddress)
-						return status, err
-					}
-				}
+		return
+	}
 
-				// if proxy is enabled, update it at Cloudflare
-				if desiredState.Proxy == "true" {
-					log.Info().Msgf("[%v] Service %v.%v - Enabling proxying for dns record %v (A)...", initiator, service.Name, service.Namespace, hostname)
-				} else {
-					log.Info().Msgf("[%v] Service %v.%v - Disabling proxying for dns record %v (A)...", initiator, service.Name, service.Namespace, hostname)
-				}
+	status = "skipped"
 
-				_, err := cf.UpdateProxySetting(hostname, desiredState.Proxy == "true")
-				if err != nil {
-					if desiredState.Proxy == "true" {
-						log.Error().Err(err).Msgf("[%v] Service %v.%v - Enabling proxying for dns record %v (A) failed", initiator, service.Name, service.Namespace, hostname)
-					} else {
-						log.Error().Err(err).Msgf("[%v] Service %v.%v - Disabling proxying for dns record %v (A) failed", initiator, service.Name, service.Namespace, hostname)
-					}
+	return status, changes, nil
+}
+
+// finalizeIngress deletes the records of a ingress that is being deleted and then removes its finalizer; the finalizer stays until all stored records are gone, so deleting the ones that failed or are in a paused zone gets retried
+func finalizeIngress(ctx context.Context, cf *Cloudflare, kubeClientset kubernetes.Interface, recorder record.EventRecorder, ingress *networkingv1.Ingress, initiator string) (status string, changes int, err error) {
+
+	status, changes, err = deleteIngress(ctx, cf, kubeClientset, recorder, ingress, initiator+":deleted")
+	if err != nil {
+		return status, changes, err
+	}
+
+	// mark the ingress before removing the finalizer, since its delete event can come in right after
+	key := getFinalizedObjectKey("Ingress", ingress.Namespace, ingress.Name)
+	finalizedObjects.Store(key, true)
+
+	err = updateIngressFinalizer(ctx, kubeClientset, ingress, false)
+	if err != nil {
+		finalizedObjects.Delete(key)
+		log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Removing finalizer has failed", initiator, ingress.Name, ingress.Namespace)
+		return "failed", changes, err
+	}
+
+	log.Info().Msgf("[%v] Ingress %v.%v - Removed finalizer after deleting dns records", initiator, ingress.Name, ingress.Namespace)
+
+	return status, changes, nil
+}
+
+func deleteIngress(ctx context.Context, cf *Cloudflare, kubeClientset kubernetes.Interface, recorder record.EventRecorder, ingress *networkingv1.Ingress, initiator string) (status string, changes int, err error) {
 
-					return status, err
+	status = "failed"
+
+	if ingress != nil {
+
+		// leave ingresses handled by another ingress controller alone
+		if !isIngressClassMatching(ingress, *ingressClass) {
+			log.Debug().Msgf("[%v] Ingress %v.%v - Skipping, because its ingress class '%v' doesn't match '%v'", initiator, ingress.Name, ingress.Namespace, getIngressClass(ingress), *ingressClass)
+			return "skipped", changes, nil
+		}
+
+		// manage the records with the credentials of the cloudflare account the ingress belongs to
+		cf, err = getObjectCloudflareClient(cf, secretLister, ingress.Annotations, "Ingress", ingress.Name, ingress.Namespace)
+		if errors.Is(err, errCredentialsSecretNotFound) {
+			// without its credentials the records can't be deleted, so leave them behind instead of blocking the deletion of the ingress and its namespace for good
+			log.Warn().Err(err).Msgf("[%v] Ingress %v.%v - Leaving dns records at Cloudflare, because the credentials secret is gone", initiator, ingress.Name, ingress.Namespace)
+			recorder.Eventf(ingress, v1.EventTypeWarning, "CredentialsSecretMissing", "Leaving dns records at Cloudflare, because the credentials secret is gone: %v", err)
+			if stateStore != nil {
+				if err := stateStore.remove(ctx, "Ingress", ingress.Namespace, ingress.Name); err != nil {
+					log.Warn().Err(err).Msgf("[%v] Ingress %v.%v - Removing ingress state from configmap has failed", initiator, ingress.Name, ingress.Namespace)
 				}
 			}
+			return "skipped", changes, nil
+		}
+		if err != nil {
+			log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Getting Cloudflare credentials failed", initiator, ingress.Name, ingress.Namespace)
+			recorder.Eventf(ingress, v1.EventTypeWarning, "CredentialsLookupFailed", "Getting Cloudflare credentials failed: %v", err)
+			return status, changes, err
+		}
 
-			// if use origin is disabled, remove the A record for the origin, if state still has a value for OriginRecordHostname
-			if desiredState.OriginRecordHostname != "" && (desiredState.UseOriginRecord != "true" || desiredState.OriginRecordHostname == "") {
+		desiredState := getDesiredIngressState(ingress)
+		currentState := getCurrentIngressState(ctx, ingress)
 
-				log.Info().Msgf("[%v] Service %v.%v - Deleting origin dns record %v (A)...", initiator, service.Name, service.Namespace, desiredState.OriginRecordHostname)
+		// count the records that failed to get deleted, to retry deleting the object if retrying can help
+		failures := 0
 
-				_, err := cf.DeleteDNSRecord(desiredState.OriginRecordHostname)
-				if err != nil {
-					log.Error().Err(err).Msgf("[%v] Service %v.%v - Deleting origin dns record %v (A) failed", initiator, service.Name, service.Namespace, desiredState.OriginRecordHostname)
-					return status, err
-				}
+		// delete the records for the annotations as well as the stored ones, since the hostnames might have changed since the records were created; this includes the origin and internal records
+		recordsChanges, recordsFailures, recordsLeftBehind := deleteManagedRecords(cf, recorder, ingress, "Ingress", ingress.Name, ingress.Namespace, initiator, getRecordsToDelete(getStateManagedRecords(desiredState), getStoredRecords(currentState)))
+		failures += recordsFailures
+		if recordsChanges > 0 {
+			changes += recordsChanges
+			status = "deleted"
+		}
+
+		// keep the stored state until all records are gone, so retries know which ones to delete
+		if failures > 0 {
+			return status, changes, getDeleteFailuresError(failures)
+		}
+
+		// records in zones that can't be written to right now, like paused ones, are still there as well, so keep the stored state and with it the finalizer until they're gone
+		if recordsLeftBehind > 0 {
+			return status, changes, fmt.Errorf("%v dns record(s) of the ingress are still at Cloudflare", recordsLeftBehind)
+		}
+
+		// the stored state is of no use anymore once the ingress is gone
+		if stateStore != nil {
+			if err := stateStore.remove(ctx, "Ingress", ingress.Namespace, ingress.Name); err != nil {
+				log.Warn().Err(err).Msgf("[%v] Ingress %v.%v - Removing ingress state from configmap has failed", initiator, ingress.Name, ingress.Namespace)
 			}
 		}
+
+		return status, changes, nil
 	}
 
-	// check if service has estafette.io/cloudflare-dns annotation and it's value is true and
-	// check if service has estafette.io/cloudflare-internal-hostnames annotation and it's value is not empty and
-	// check if service has an internal ip address
-	if desiredState.Enabled == "true" && len(desiredState.InternalHostnames) > 0 && desiredState.InternalIPAddress != "" {
+	status = "skipped"
 
-		// update internal dns record if anything has changed compared to the stored state
-		if desiredState.InternalIPAddress != currentState.InternalIPAddress ||
-			desiredState.InternalHostnames != currentState.InternalHostnames {
+	return status, changes, nil
+}
 
-			hasChanges = true
+// getBooleanAnnotation parses a string-boolean annotation case-insensitively and returns it as "true" or "false", falling back to the default for missing or unrecognized values
+func getBooleanAnnotation(annotations map[string]string, annotation string, defaultValue bool, kind, name, namespace string) string {
 
-			// loop all internal hostnames
-			internalHostnames := strings.Split(desiredState.InternalHostnames, ",")
-			for _, internalHostname := range internalHostnames {
+	value, ok := annotations[annotation]
+	if !ok {
+		return strconv.FormatBool(defaultValue)
+	}
 
-				log.Info().Msgf("[%v] Service %v.%v - Upserting dns record %v (A) to internal ip address %v...", initiator, service.Name, service.Namespace, internalHostname, desiredState.InternalIPAddress)
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "1":
+		return "true"
+	case "false", "0":
+		return "false"
+	}
 
-				_, err := cf.UpsertDNSRecord("A", internalHostname, desiredState.InternalIPAddress, false)
-				if err != nil {
-					log.Error().Err(err).Msgf("[%v] Service %v.%v - Upserting dns record %v (A) to internal ip address %v failed", initiator, service.Name, service.Namespace, internalHostname, desiredState.InternalIPAddress)
-					return status, err
-				}
-			}
+	log.Warn().Msgf("%v %v.%v - Annotation %v has unrecognized value '%v', expected true, false, 1 or 0; falling back to default %v", kind, name, namespace, annotation, value, defaultValue)
+
+	return strconv.FormatBool(defaultValue)
+}
+
+// getProxyAnnotation returns the value of the proxy annotation, which is either a boolean or auto to proxy records whenever cloudflare allows it
+func getProxyAnnotation(annotations map[string]string, kind, name, namespace string) string {
+
+	if *disableProxy {
+		return "false"
+	}
+
+	if value, ok := annotations[annotationCloudflareProxy]; ok && strings.EqualFold(strings.TrimSpace(value), "auto") {
+		return "auto"
+	}
+
+	return getBooleanAnnotation(annotations, annotationCloudflareProxy, isDefaultProxy(), kind, name, namespace)
+}
+
+// getBooleanProxyAnnotation returns the value of the proxy annotation for objects that don't support auto
+func getBooleanProxyAnnotation(annotations map[string]string, kind, name, namespace string) string {
+
+	if *disableProxy {
+		return "false"
+	}
+
+	return getBooleanAnnotation(annotations, annotationCloudflareProxy, isDefaultProxy(), kind, name, namespace)
+}
+
+// getProxyAfterAnnotation returns the duration from the proxy-after annotation in its canonical form, or an empty string if it's not set or not a valid duration
+func getProxyAfterAnnotation(annotations map[string]string, kind, name, namespace string) string {
+
+	value, ok := annotations[annotationCloudflareProxyAfter]
+	if !ok {
+		return ""
+	}
+
+	proxyAfter, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil || proxyAfter <= 0 {
+		log.Warn().Msgf("%v %v.%v - Annotation %v has unrecognized value '%v', expected a positive duration like 30m; ignoring it", kind, name, namespace, annotationCloudflareProxyAfter, value)
+		return ""
+	}
+
+	return proxyAfter.String()
+}
+
+// applyProxyGracePeriod returns the desired state with proxying disabled until the proxy-after duration has passed since its records got created, which it tracks in the state; records that already existed before the annotation got added keep being proxied
+func applyProxyGracePeriod(desiredState, currentState CloudflareState, now time.Time) CloudflareState {
+
+	if desiredState.ProxyAfter == "" || desiredState.Proxy == "false" {
+		return desiredState
+	}
+
+	dnsOnlySince := currentState.DNSOnlySince
+	if dnsOnlySince == "" {
+		if currentState.Enabled == "true" {
+			return desiredState
 		}
+		dnsOnlySince = now.UTC().Format(time.RFC3339)
 	}
+	desiredState.DNSOnlySince = dnsOnlySince
 
-	if hasChanges {
+	proxyAfter, _ := time.ParseDuration(desiredState.ProxyAfter)
+	since, err := time.Parse(time.RFC3339, dnsOnlySince)
+	if err == nil && now.Before(since.Add(proxyAfter)) {
+		desiredState.Proxy = "false"
+	}
 
-		// if any state property changed make sure to update all
-		currentState = desiredState
+	return desiredState
+}
 
-		log.Info().Msgf("[%v] Service %v.%v - Updating service because state has changed...", initiator, service.Name, service.Namespace)
+// getProxyPlansAnnotation returns the lowercased plans from the proxy-plans annotation, or an empty string to proxy records in zones on any plan
+func getProxyPlansAnnotation(annotations map[string]string) string {
+
+	plans := []string{}
+	for _, plan := range strings.Split(annotations[annotationCloudflareProxyPlans], ",") {
+		if plan = strings.ToLower(strings.TrimSpace(plan)); plan != "" {
+			plans = append(plans, plan)
+		}
+	}
+
+	return strings.Join(plans, ",")
+}
+
+// isProxyAllowedByZonePlan returns false with the plan of the zone of the hostname if it isn't one of the proxy plans; a zone that can't be looked up is left for the upsert to fail on
+func isProxyAllowedByZonePlan(getZone func(dnsName string) (Zone, error), hostname, proxyPlans string) (bool, string) {
+
+	if proxyPlans == "" {
+		return true, ""
+	}
+
+	zone, err := getZone(hostname)
+	if err != nil {
+		return true, ""
+	}
+
+	return isZonePlanAllowed(zone, proxyPlans), getZonePlanName(zone)
+}
+
+// isDefaultProxy returns whether records of objects without the proxy annotation get proxied, which they do unless --default-proxy is false
+func isDefaultProxy() bool {
+	return *defaultProxy != "false"
+}
+
+// getTTLAnnotation returns the ttl from the ttl annotation, or the --default-ttl if it's not set or not a valid ttl in seconds (1 for automatic); it's an empty string if there's no default either
+func getTTLAnnotation(annotations map[string]string, kind, name, namespace string) string {
+
+	defaultValue := ""
+	if *defaultTTL > 0 {
+		defaultValue = strconv.Itoa(*defaultTTL)
+	}
+
+	value, ok := annotations[annotationCloudflareTTL]
+	if !ok {
+		return defaultValue
+	}
+
+	ttl, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || ttl < 1 {
+		log.Warn().Msgf("%v %v.%v - Annotation %v has unrecognized value '%v', expected a number of seconds or 1 for automatic; ignoring it", kind, name, namespace, annotationCloudflareTTL, value)
+		return defaultValue
+	}
+
+	return strconv.Itoa(ttl)
+}
+
+// getRegionAnnotation returns the regional services region key from the region annotation, or an empty string to not restrict records to a region
+func getRegionAnnotation(annotations map[string]string) string {
+	return strings.ToLower(strings.TrimSpace(annotations[annotationCloudflareRegion]))
+}
+
+// getSSLModeAnnotation returns the ssl mode from the ssl mode annotation, or an empty string if it's not set or not one of off, flexible, full or strict
+func getSSLModeAnnotation(annotations map[string]string, kind, name, namespace string) string {
+
+	value, ok := annotations[annotationCloudflareSSLMode]
+	if !ok {
+		return ""
+	}
+
+	switch sslMode := strings.ToLower(strings.TrimSpace(value)); sslMode {
+	case "off", "flexible", "full", "strict":
+		return sslMode
+	}
+
+	log.Warn().Msgf("%v %v.%v - Annotation %v has unrecognized value '%v', expected off, flexible, full or strict; ignoring it", kind, name, namespace, annotationCloudflareSSLMode, value)
+
+	return ""
+}
+
+// updateZoneSSLMode sets the ssl mode of the zone a proxied hostname is in, unless the zone has that mode already
+func updateZoneSSLMode(zones *objectZones, recorder record.EventRecorder, obj apiruntime.Object, kind, name, namespace, initiator, hostname, sslMode string) (changes int, err error) {
+
+	zone, err := zones.getZone(hostname)
+	if err != nil {
+		getUpsertFailureLogEvent(err).Msgf("[%v] %v %v.%v - Retrieving zone of dns record %v to set its ssl mode failed", initiator, kind, name, namespace, hostname)
+		return
+	}
+
+	currentSSLMode, err := zones.cf.GetZoneSSLSetting(zone)
+	if err != nil {
+		log.Error().Err(err).Msgf("[%v] %v %v.%v - Retrieving ssl mode of zone %v failed", initiator, kind, name, namespace, zone.Name)
+		recorder.Eventf(obj, v1.EventTypeWarning, "ZoneSSLModeUpdateFailed", "Retrieving ssl mode of zone %v failed: %v", zone.Name, err)
+		return
+	}
+
+	if currentSSLMode == sslMode {
+		return
+	}
+
+	log.Info().Msgf("[%v] %v %v.%v - Setting ssl mode of zone %v from %v to %v...", initiator, kind, name, namespace, zone.Name, currentSSLMode, sslMode)
+
+	_, err = zones.cf.UpdateZoneSSLSetting(zone, sslMode)
+	if err != nil {
+		log.Error().Err(err).Msgf("[%v] %v %v.%v - Setting ssl mode of zone %v to %v failed", initiator, kind, name, namespace, zone.Name, sslMode)
+		recorder.Eventf(obj, v1.EventTypeWarning, "ZoneSSLModeUpdateFailed", "Setting ssl mode of zone %v to %v failed: %v", zone.Name, sslMode, err)
+		return
+	}
+	recorder.Eventf(obj, v1.EventTypeNormal, "ZoneSSLModeUpdated", "Set ssl mode of zone %v to %v", zone.Name, sslMode)
+
+	return 1, nil
+}
+
+// updateZoneSettings applies the settings to the zone a hostname is in, skipping the ones that have the desired value already
+func updateZoneSettings(zones *objectZones, recorder record.EventRecorder, obj apiruntime.Object, kind, name, namespace, initiator, hostname string, settings []zoneSettingValue) (changes int, err error) {
+
+	zone, err := zones.getZone(hostname)
+	if err != nil {
+		getUpsertFailureLogEvent(err).Msgf("[%v] %v %v.%v - Retrieving zone of dns record %v to apply zone settings failed", initiator, kind, name, namespace, hostname)
+		return
+	}
 
-		// serialize state and store it in the annotation
-		cloudflareStateByteArray, err := json.Marshal(currentState)
+	for _, setting := range settings {
+		currentSetting, err := zones.cf.GetZoneSetting(zone, setting.Name)
 		if err != nil {
-			log.Error().Err(err).Msgf("[%v] Service %v.%v - Marshalling state failed", initiator, service.Name, service.Namespace)
-			return status, err
+			log.Error().Err(err).Msgf("[%v] %v %v.%v - Retrieving setting %v of zone %v failed", initiator, kind, name, namespace, setting.Name, zone.Name)
+			recorder.Eventf(obj, v1.EventTypeWarning, "ZoneSettingUpdateFailed", "Retrieving setting %v of zone %v failed: %v", setting.Name, zone.Name, err)
+			return changes, err
 		}
-		service.Annotations[annotationCloudflareState] = string(cloudflareStateByteArray)
 
-		// update service, because the state annotations have changed
-		service, err = kubeClientset.CoreV1().Services(service.Namespace).Update(ctx, service, metav1.UpdateOptions{})
+		// numbers are decoded as float64, so compare the formatted values
+		if fmt.Sprint(currentSetting.Value) == fmt.Sprint(setting.Value) {
+			continue
+		}
+
+		log.Info().Msgf("[%v] %v %v.%v - Setting %v of zone %v from %v to %v...", initiator, kind, name, namespace, setting.Name, zone.Name, currentSetting.Value, setting.Value)
+
+		_, err = zones.cf.UpdateZoneSetting(zone, setting.Name, setting.Value)
 		if err != nil {
-			log.Error().Err(err).Msgf("[%v] Service %v.%v - Updating service state has failed", initiator, service.Name, service.Namespace)
-			return status, err
+			log.Error().Err(err).Msgf("[%v] %v %v.%v - Setting %v of zone %v to %v failed", initiator, kind, name, namespace, setting.Name, zone.Name, setting.Value)
+			recorder.Eventf(obj, v1.EventTypeWarning, "ZoneSettingUpdateFailed", "Setting %v of zone %v to %v failed: %v", setting.Name, zone.Name, setting.Value, err)
+			return changes, err
 		}
+		recorder.Eventf(obj, v1.EventTypeNormal, "ZoneSettingUpdated", "Set %v of zone %v to %v", setting.Name, zone.Name, setting.Value)
+		changes++
+	}
 
-		status = "succeeded"
+	return changes, nil
+}
 
-		log.Info().Msgf("[%v] Service %v.%v - Service has been updated successfully...", initiator, service.Name, service.Namespace)
+// getLoadBalancerTarget returns the ip address of a load balancer, or its hostname for providers that only set that
+func getLoadBalancerTarget(loadBalancerIngress v1.LoadBalancerIngress) (target, targetIsHostname string) {
+	if loadBalancerIngress.IP == "" && loadBalancerIngress.Hostname != "" {
+		return loadBalancerIngress.Hostname, "true"
+	}
+
+	return loadBalancerIngress.IP, "false"
+}
+
+// getPrivateLoadBalancerIPAddress returns the first load balancer ip address in a private range, as used by internal load balancers
+func getPrivateLoadBalancerIPAddress(loadBalancerIngresses []v1.LoadBalancerIngress) string {
+	for _, loadBalancerIngress := range loadBalancerIngresses {
+		ip := net.ParseIP(loadBalancerIngress.IP)
+		if ip != nil && ip.IsPrivate() {
+			return loadBalancerIngress.IP
+		}
+	}
+
+	return ""
+}
+
+// getAdditionalLoadBalancerIPAddresses returns the load balancer ip addresses of the same family as the first one, except for the first one itself, so they can go in records of the same type
+func getAdditionalLoadBalancerIPAddresses(firstIPAddress string, loadBalancerIngresses []v1.LoadBalancerIngress) (ipAddresses []string) {
+
+	firstIP := net.ParseIP(firstIPAddress)
+	if firstIP == nil {
+		return
+	}
+
+	seen := map[string]bool{firstIP.String(): true}
+	for _, loadBalancerIngress := range loadBalancerIngresses {
+		ip := net.ParseIP(loadBalancerIngress.IP)
+		if ip == nil || (ip.To4() != nil) != (firstIP.To4() != nil) || seen[ip.String()] {
+			continue
+		}
+		seen[ip.String()] = true
+		ipAddresses = append(ipAddresses, loadBalancerIngress.IP)
+	}
+
+	return
+}
+
+// getStateIPAddresses returns all the load balancer ip addresses in the state, starting with the first one
+func getStateIPAddresses(state CloudflareState) []string {
+
+	ipAddresses := []string{state.IPAddress}
+	if state.AdditionalIPAddresses != "" {
+		ipAddresses = append(ipAddresses, strings.Split(state.AdditionalIPAddresses, ",")...)
+	}
+
+	return ipAddresses
+}
+
+// getTargetDNSRecordType returns the type of record pointing at the target in the state
+func getTargetDNSRecordType(state CloudflareState) string {
+	if state.TargetIsHostname == "true" {
+		return "CNAME"
+	}
+
+	// ipv6 addresses need an AAAA record
+	if ip := net.ParseIP(state.IPAddress); ip != nil && ip.To4() == nil {
+		return "AAAA"
+	}
+
+	return "A"
+}
+
+// getHostnameDNSRecord returns the type and content of the records for the hostnames in the state
+func getHostnameDNSRecord(state CloudflareState) (dnsRecordType, dnsRecordContent string) {
+	if state.CNAMETarget != "" {
+		return "CNAME", state.CNAMETarget
+	}
+	if state.UseOriginRecord == "true" && state.OriginRecordHostname != "" {
+		return "CNAME", state.OriginRecordHostname
+	}
+
+	return getTargetDNSRecordType(state), state.IPAddress
+}
+
+// getServiceHostnameDNSRecord returns the record for a service hostname like getHostnameDNSRecord, except that the zone apex points at the load balancer instead of at the origin record, since a CNAME record isn't allowed there
+func getServiceHostnameDNSRecord(zones zoneApexChecker, state CloudflareState, hostname string) (dnsRecordType, dnsRecordContent string, isApex bool) {
+
+	dnsRecordType, dnsRecordContent = getHostnameDNSRecord(state)
+
+	// only CNAME records are affected, so save the zone lookup for other records
+	if dnsRecordType != "CNAME" || !zones.IsZoneApex(hostname) {
+		return dnsRecordType, dnsRecordContent, false
+	}
+
+	if state.CNAMETarget == "" && state.UseOriginRecord == "true" && state.OriginRecordHostname != "" && getTargetDNSRecordType(state) != "CNAME" {
+		return getTargetDNSRecordType(state), state.IPAddress, true
+	}
+
+	return dnsRecordType, dnsRecordContent, true
+}
+
+// getStateDiff lists the fields that differ between the current and desired state, with their values
+func getStateDiff(desiredState, currentState CloudflareState) (diff []string) {
+
+	desiredValue := reflect.ValueOf(desiredState)
+	currentValue := reflect.ValueOf(currentState)
+
+	for i := 0; i < desiredValue.NumField(); i++ {
+		desiredField := desiredValue.Field(i).Interface()
+		currentField := currentValue.Field(i).Interface()
+
+		if !reflect.DeepEqual(desiredField, currentField) {
+			diff = append(diff, fmt.Sprintf("%v: '%v' -> '%v'", desiredValue.Type().Field(i).Name, currentField, desiredField))
+		}
+	}
+
+	return
+}
+
+// logStateDiff logs at debug level which state fields differ, to explain why an object does or doesn't get reconciled
+func logStateDiff(kind, name, namespace, initiator string, desiredState, currentState CloudflareState) {
+
+	diff := getStateDiff(desiredState, currentState)
+	if len(diff) == 0 {
+		log.Debug().Msgf("[%v] %v %v.%v - Desired state equals stored state", initiator, kind, name, namespace)
+		return
+	}
+
+	log.Debug().Msgf("[%v] %v %v.%v - Desired state differs from stored state in %v", initiator, kind, name, namespace, strings.Join(diff, ", "))
+}
+
+// splitHostnames splits a comma-separated list of hostnames, trimming whitespace and skipping empty entries
+func splitHostnames(hostnames string) (r []string) {
+
+	r = []string{}
+	for _, hostname := range strings.Split(hostnames, ",") {
+		hostname = strings.TrimSpace(hostname)
+		if hostname == "" {
+			continue
+		}
+		r = append(r, hostname)
+	}
+
+	return
+}
+
+// expandServiceHostnames fills in the {service}, {namespace} and {domain} placeholders in the hostnames of a service, so many services can share the same hostnames template
+func expandServiceHostnames(service *v1.Service, hostnames string) string {
+
+	expandedHostnames, unresolvedHostnames := expandHostnames(hostnames, getServiceHostnamePlaceholders(service))
+	if len(unresolvedHostnames) > 0 {
+		log.Warn().Msgf("Service %v.%v - Hostnames %v have placeholders without a value, skipping them; only {service}, {namespace} and {domain} are supported, the latter if --default-domain-suffix is set", service.Name, service.Namespace, strings.Join(unresolvedHostnames, ","))
+	}
+
+	return expandedHostnames
+}
+
+// getServiceHostnamePlaceholders returns the values of the placeholders in the hostnames of a service
+func getServiceHostnamePlaceholders(service *v1.Service) map[string]string {
+	return map[string]string{
+		"service":   service.Name,
+		"namespace": service.Namespace,
+		"domain":    strings.Trim(*defaultDomainSuffix, "."),
+	}
+}
+
+// getRecordNameAnnotation returns the record-name annotation as a comma-separated list of hostname=name mappings; a name without hostname maps the only hostname
+func getRecordNameAnnotation(annotations map[string]string, hostnames, kind, name, namespace string) string {
 
-		return status, nil
+	value := strings.TrimSpace(annotations[annotationCloudflareRecordName])
+	if value == "" {
+		return ""
 	}
 
-	status = "skipped"
+	mappings := []string{}
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
 
-	return status, nil
-}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) == 1 {
+			if len(splitHostnames(hostnames)) != 1 {
+				log.Warn().Msgf("%v %v.%v - Annotation %v has record name '%v' without hostname, which is only allowed for a single hostname; ignoring it", kind, name, namespace, annotationCloudflareRecordName, strings.TrimSpace(entry))
+				continue
+			}
+			parts = []string{splitHostnames(hostnames)[0], parts[0]}
+		}
 
-func processService(ctx context.Context, cf *Cloudflare, kubeClientset *kubernetes.Clientset, service *v1.Service, initiator string) (status string, err error) {
+		hostname := toASCIIHostname(strings.TrimSpace(parts[0]))
+		recordName := toASCIIHostname(strings.TrimSpace(parts[1]))
+		if hostname == "" || recordName == "" {
+			log.Warn().Msgf("%v %v.%v - Annotation %v has unrecognized entry '%v', expected the form 'hostname=name'; ignoring it", kind, name, namespace, annotationCloudflareRecordName, strings.TrimSpace(entry))
+			continue
+		}
 
-	status = "failed"
+		mappings = append(mappings, hostname+"="+recordName)
+	}
 
-	if service != nil {
+	return strings.Join(mappings, ",")
+}
 
-		desiredState := getDesiredServiceState(service)
-		currentState := getCurrentServiceState(service)
+// applyRecordNames returns the hostnames with the ones mapped to another record name replaced by that name, skipping duplicates
+func applyRecordNames(hostnames, recordNames string) string {
 
-		status, err = makeServiceChanges(ctx, cf, kubeClientset, service, initiator, desiredState, currentState)
+	if recordNames == "" {
+		return hostnames
+	}
 
-		return
+	names := map[string]string{}
+	for _, mapping := range strings.Split(recordNames, ",") {
+		parts := strings.SplitN(mapping, "=", 2)
+		names[strings.ToLower(parts[0])] = parts[1]
 	}
 
-	status = "skipped"
+	seen := map[string]bool{}
+	mappedHostnames := []string{}
+	for _, hostname := range splitHostnames(hostnames) {
+		if recordName, ok := names[strings.ToLower(hostname)]; ok {
+			hostname = recordName
+		}
+		if seen[strings.ToLower(hostname)] {
+			continue
+		}
+		seen[strings.ToLower(hostname)] = true
+		mappedHostnames = append(mappedHostnames, hostname)
+	}
 
-	return status, nil
+	return strings.Join(mappedHostnames, ",")
 }
 
-func deleteService(cf *Cloudflare, kubeClientset *kubernetes.Clientset, service *v1.Service, initiator string) (status string, err error) {
-
-	status = "failed"
-
-	if service != nil {
+// getOriginRecordHostname returns the origin record hostname from the annotation, or generates it with --origin-record-hostname-template if the origin record is used without one
+func getOriginRecordHostname(annotations map[string]string, useOriginRecord, hostnames, kind, name, namespace string) string {
 
-		desiredState := getDesiredServiceState(service)
+	if originRecordHostname := annotations[annotationCloudflareOriginRecordHostname]; originRecordHostname != "" || useOriginRecord != "true" || *originRecordTemplate == "" {
+		return originRecordHostname
+	}
 
-		dnsRecordType := "A"
-		if desiredState.UseOriginRecord == "true" && desiredState.OriginRecordHostname != "" {
-			dnsRecordType = "CNAME"
-		}
+	splitHostnames := splitHostnames(hostnames)
+	if len(splitHostnames) == 0 {
+		return ""
+	}
 
-		// loop all hostnames
-		hostnames := strings.Split(desiredState.Hostnames, ",")
-		for _, hostname := range hostnames {
-			log.Info().Msgf("[%v] Service %v.%v - Deleting dns record %v (%v) with ip address %v...", initiator, service.Name, service.Namespace, hostname, dnsRecordType, desiredState.IPAddress)
-			_, err = cf.DeleteDNSRecordIfMatching(hostname, dnsRecordType, desiredState.IPAddress)
-			if err != nil {
-				log.Warn().Err(err).Msgf("[%v] Service %v.%v - Failed deleting dns record %v (%v) with ip address %v...", initiator, service.Name, service.Namespace, hostname, dnsRecordType, desiredState.IPAddress)
-			} else {
-				status = "deleted"
-			}
+	values := map[string]string{
+		"name":      strings.ToLower(name),
+		"namespace": strings.ToLower(namespace),
+	}
+	if strings.Contains(strings.ToLower(*originRecordTemplate), "{zone}") {
+		zoneName, err := getOriginRecordZoneName(annotations, splitHostnames[0], kind, name, namespace)
+		if err != nil {
+			log.Warn().Err(err).Msgf("%v %v.%v - Looking up zone of %v for the origin record hostname failed, not generating it", kind, name, namespace, splitHostnames[0])
+			return ""
 		}
-
-		return
+		values["zone"] = zoneName
 	}
 
-	status = "skipped"
+	originRecordHostname, unresolvedHostnames := expandHostnames(*originRecordTemplate, values)
+	if len(unresolvedHostnames) > 0 {
+		log.Warn().Msgf("%v %v.%v - Origin record hostname template %v has placeholders without a value, not generating it; only {name}, {namespace} and {zone} are supported", kind, name, namespace, *originRecordTemplate)
+		return ""
+	}
 
-	return status, nil
+	return normalizeHostnames(originRecordHostname)
 }
 
-func getDesiredIngressState(ingress *networkingv1.Ingress) (state CloudflareState) {
-
-	var ok bool
+// getOriginRecordZoneName returns the name of the zone of a hostname, from the cache if it has been looked up before; it's looked up with the credentials of the object, since the zone might be in another account
+func getOriginRecordZoneName(annotations map[string]string, hostname, kind, name, namespace string) (string, error) {
 
-	state.Enabled, ok = ingress.Annotations[annotationCloudflareDNS]
-	if !ok {
-		state.Enabled = "false"
+	if zoneName, ok := originRecordZones.Load(hostname); ok {
+		return zoneName.(string), nil
 	}
-	state.Hostnames, ok = ingress.Annotations[annotationCloudflareHostnames]
-	if !ok {
-		state.Hostnames = ""
+
+	if originRecordZoneLookup == nil {
+		return "", errors.New("Zone lookup for origin record hostnames isn't configured")
 	}
-	state.Proxy, ok = ingress.Annotations[annotationCloudflareProxy]
-	if !ok {
-		state.Proxy = "true"
+
+	cf, err := getObjectCloudflareClient(originRecordZoneLookup, secretLister, annotations, kind, name, namespace)
+	if err != nil {
+		return "", err
 	}
-	state.UseOriginRecord, ok = ingress.Annotations[annotationCloudflareUseOriginRecord]
-	if !ok {
-		state.UseOriginRecord = "false"
+
+	zone, err := cf.GetZoneByDNSName(hostname)
+	if err != nil {
+		return "", err
 	}
-	state.OriginRecordHostname, ok = ingress.Annotations[annotationCloudflareOriginRecordHostname]
-	if !ok {
-		state.OriginRecordHostname = ""
+	if zone.Name == "" {
+		return "", fmt.Errorf("Zone of %v has no name", hostname)
 	}
 
-	if len(ingress.Status.LoadBalancer.Ingress) > 0 {
-		state.IPAddress = ingress.Status.LoadBalancer.Ingress[0].IP
-	}
+	originRecordZones.Store(hostname, zone.Name)
 
-	return
+	return zone.Name, nil
 }
 
-func getCurrentIngressState(ingress *networkingv1.Ingress) (state CloudflareState) {
+// normalizeHostnames converts internationalized hostnames in a comma-separated list to punycode, so the stored state matches the record names at cloudflare
+func normalizeHostnames(hostnames string) string {
 
-	// get state stored in annotations if present or set to empty struct
-	cloudflareStateString, ok := ingress.Annotations[annotationCloudflareState]
-	if !ok {
-		// couldn't find saved state, setting to default struct
-		state = CloudflareState{}
-		return
+	changed := false
+	normalizedHostnames := []string{}
+	for _, hostname := range splitHostnames(hostnames) {
+		asciiHostname := toASCIIHostname(hostname)
+		if asciiHostname != hostname {
+			changed = true
+		}
+		normalizedHostnames = append(normalizedHostnames, asciiHostname)
 	}
 
-	if err := json.Unmarshal([]byte(cloudflareStateString), &state); err != nil {
-		// couldn't deserialize, setting to default struct
-		state = CloudflareState{}
-		return
+	// leave ascii-only lists untouched to keep the state of existing objects stable
+	if !changed {
+		return hostnames
 	}
 
-	// return deserialized state
-	return
+	return strings.Join(normalizedHostnames, ",")
 }
 
-func makeIngressChanges(ctx context.Context, cf *Cloudflare, kubeClientset *kubernetes.Clientset, ingress *networkingv1.Ingress, initiator string, desiredState, currentState CloudflareState) (status string, err error) {
+// parseSRVRecords parses a comma-separated list of srv records in the form '_service._proto.name priority weight port target'
+func parseSRVRecords(srvRecords string) (r []srvRecord, err error) {
 
-	status = "failed"
+	r = []srvRecord{}
+	for _, entry := range strings.Split(srvRecords, ",") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 5 {
+			return r, fmt.Errorf("Srv record '%v' should have the form '_service._proto.name priority weight port target'", strings.TrimSpace(entry))
+		}
 
-	// check if ingress has estafette.io/cloudflare-dns annotation and it's value is true and
-	// check if ingress has estafette.io/cloudflare-hostnames annotation and it's value is not empty and
-	// check if type equals LoadBalancer and
-	// check if LoadBalancer has an ip address
-	if desiredState.Enabled == "true" && len(desiredState.Hostnames) > 0 && desiredState.IPAddress != "" {
+		record := srvRecord{Name: fields[0], Data: SRVRecordData{Target: fields[4]}}
+		if record.Data.Priority, err = strconv.Atoi(fields[1]); err != nil {
+			return r, fmt.Errorf("Srv record '%v' has invalid priority: %w", fields[0], err)
+		}
+		if record.Data.Weight, err = strconv.Atoi(fields[2]); err != nil {
+			return r, fmt.Errorf("Srv record '%v' has invalid weight: %w", fields[0], err)
+		}
+		if record.Data.Port, err = strconv.Atoi(fields[3]); err != nil {
+			return r, fmt.Errorf("Srv record '%v' has invalid port: %w", fields[0], err)
+		}
 
-		// update dns record if anything has changed compared to the stored state
-		if desiredState.IPAddress != currentState.IPAddress ||
-			desiredState.Hostnames != currentState.Hostnames ||
-			desiredState.Proxy != currentState.Proxy ||
-			desiredState.UseOriginRecord != currentState.UseOriginRecord ||
-			desiredState.OriginRecordHostname != currentState.OriginRecordHostname {
+		r = append(r, record)
+	}
 
-			// if use origin is enabled, create an A record for the origin
-			if desiredState.UseOriginRecord == "true" && desiredState.OriginRecordHostname != "" {
+	return r, nil
+}
 
-				log.Info().Msgf("[%v] Ingress %v.%v - Upserting origin dns record %v (A) to ip address %v...", initiator, ingress.Name, ingress.Namespace, desiredState.OriginRecordHostname, desiredState.IPAddress)
+// parseCAARecords parses a comma-separated list of caa records in the form 'name flags tag value'
+func parseCAARecords(caaRecords string) (r []caaRecord, err error) {
 
-				_, err := cf.UpsertDNSRecord("A", desiredState.OriginRecordHostname, desiredState.IPAddress, false)
-				if err != nil {
-					log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Upserting origin dns record %v (A) to ip address %v failed", initiator, ingress.Name, ingress.Namespace, desiredState.OriginRecordHostname, desiredState.IPAddress)
-					return status, err
-				}
-			}
+	r = []caaRecord{}
+	for _, entry := range strings.Split(caaRecords, ",") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 4 {
+			return r, fmt.Errorf("Caa record '%v' should have the form 'name flags tag value'", strings.TrimSpace(entry))
+		}
 
-			// loop all hostnames
-			hostnames := strings.Split(desiredState.Hostnames, ",")
-			for _, hostname := range hostnames {
+		// the value can contain spaces, for example in 'letsencrypt.org; validationmethods=dns-01'
+		record := caaRecord{Name: fields[0], Data: CAARecordData{Tag: fields[2], Value: strings.Join(fields[3:], " ")}}
+		if record.Data.Flags, err = strconv.Atoi(fields[1]); err != nil || record.Data.Flags < 0 || record.Data.Flags > 255 {
+			return r, fmt.Errorf("Caa record '%v' has invalid flags '%v', should be between 0 and 255", fields[0], fields[1])
+		}
+		if record.Data.Tag != "issue" && record.Data.Tag != "issuewild" && record.Data.Tag != "iodef" {
+			return r, fmt.Errorf("Caa record '%v' has invalid tag '%v', should be issue, issuewild or iodef", fields[0], fields[2])
+		}
 
-				// if use origin is enabled, create a CNAME record pointing to the origin record
-				if desiredState.UseOriginRecord == "true" && desiredState.OriginRecordHostname != "" {
+		r = append(r, record)
+	}
 
-					log.Info().Msgf("[%v] Ingress %v.%v - Upserting dns record %v (CNAME) to value %v...", initiator, ingress.Name, ingress.Namespace, hostname, desiredState.OriginRecordHostname)
+	return r, nil
+}
 
-					_, err := cf.UpsertDNSRecord("CNAME", hostname, desiredState.OriginRecordHostname, desiredState.Proxy == "true")
-					if err != nil {
-						log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Upserting dns record %v (CNAME) to value %v failed", initiator, ingress.Name, ingress.Namespace, hostname, desiredState.OriginRecordHostname)
-						return status, err
-					}
-				} else {
+// parseLOCRecords parses a comma-separated list of loc records in the form 'name d1 m1 s1 N|S d2 m2 s2 E|W altitude size horizontal-precision vertical-precision', with the last four in meters
+func parseLOCRecords(locRecords string) (r []locRecord, err error) {
 
-					log.Info().Msgf("[%v] Ingress %v.%v - Upserting dns record %v (A) to ip address %v...", initiator, ingress.Name, ingress.Namespace, hostname, desiredState.IPAddress)
+	r = []locRecord{}
+	for _, entry := range strings.Split(locRecords, ",") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 13 {
+			return r, fmt.Errorf("Loc record '%v' should have the form 'name d1 m1 s1 N|S d2 m2 s2 E|W altitude size horizontal-precision vertical-precision'", strings.TrimSpace(entry))
+		}
 
-					_, err := cf.UpsertDNSRecord("A", hostname, desiredState.IPAddress, desiredState.Proxy == "true")
-					if err != nil {
-						log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Upserting dns record %v (A) to ip address %v failed", initiator, ingress.Name, ingress.Namespace, hostname, desiredState.IPAddress)
-						return status, err
-					}
-				}
+		record := locRecord{Name: fields[0], Data: LOCRecordData{LatDirection: fields[4], LongDirection: fields[8]}}
+		if record.Data.LatDegrees, err = strconv.Atoi(fields[1]); err != nil || record.Data.LatDegrees < 0 || record.Data.LatDegrees > 90 {
+			return r, fmt.Errorf("Loc record '%v' has invalid latitude degrees '%v', should be between 0 and 90", fields[0], fields[1])
+		}
+		if record.Data.LongDegrees, err = strconv.Atoi(fields[5]); err != nil || record.Data.LongDegrees < 0 || record.Data.LongDegrees > 180 {
+			return r, fmt.Errorf("Loc record '%v' has invalid longitude degrees '%v', should be between 0 and 180", fields[0], fields[5])
+		}
+		if record.Data.LatMinutes, err = strconv.Atoi(fields[2]); err != nil || record.Data.LatMinutes < 0 || record.Data.LatMinutes > 59 {
+			return r, fmt.Errorf("Loc record '%v' has invalid latitude minutes '%v', should be between 0 and 59", fields[0], fields[2])
+		}
+		if record.Data.LongMinutes, err = strconv.Atoi(fields[6]); err != nil || record.Data.LongMinutes < 0 || record.Data.LongMinutes > 59 {
+			return r, fmt.Errorf("Loc record '%v' has invalid longitude minutes '%v', should be between 0 and 59", fields[0], fields[6])
+		}
+		if record.Data.LatSeconds, err = strconv.ParseFloat(fields[3], 64); err != nil || record.Data.LatSeconds < 0 || record.Data.LatSeconds >= 60 {
+			return r, fmt.Errorf("Loc record '%v' has invalid latitude seconds '%v', should be between 0 and 59.999", fields[0], fields[3])
+		}
+		if record.Data.LongSeconds, err = strconv.ParseFloat(fields[7], 64); err != nil || record.Data.LongSeconds < 0 || record.Data.LongSeconds >= 60 {
+			return r, fmt.Errorf("Loc record '%v' has invalid longitude seconds '%v', should be between 0 and 59.999", fields[0], fields[7])
+		}
+		if record.Data.LatDirection != "N" && record.Data.LatDirection != "S" {
+			return r, fmt.Errorf("Loc record '%v' has invalid latitude direction '%v', should be N or S", fields[0], fields[4])
+		}
+		if record.Data.LongDirection != "E" && record.Data.LongDirection != "W" {
+			return r, fmt.Errorf("Loc record '%v' has invalid longitude direction '%v', should be E or W", fields[0], fields[8])
+		}
 
-				// if proxy is enabled, update it at Cloudflare
-				if desiredState.Proxy == "true" {
-					log.Info().Msgf("[%v] Ingress %v.%v - Enabling proxying for dns record %v (A)...", initiator, ingress.Name, ingress.Namespace, hostname)
-				} else {
-					log.Info().Msgf("[%v] Ingress %v.%v - Disabling proxying for dns record %v (A)...", initiator, ingress.Name, ingress.Namespace, hostname)
-				}
+		// the altitude, size and precisions are in meters, optionally suffixed by m like in the zone file format
+		meters := []*float64{&record.Data.Altitude, &record.Data.Size, &record.Data.PrecisionHorz, &record.Data.PrecisionVert}
+		for i, m := range meters {
+			field := fields[9+i]
+			if *m, err = strconv.ParseFloat(strings.TrimSuffix(field, "m"), 64); err != nil || (i > 0 && *m < 0) {
+				return r, fmt.Errorf("Loc record '%v' has invalid distance '%v', should be a number of meters", fields[0], field)
+			}
+		}
 
-				_, err := cf.UpdateProxySetting(hostname, desiredState.Proxy == "true")
-				if err != nil {
-					if desiredState.Proxy == "true" {
-						log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Enabling proxying for dns record %v (A) failed", initiator, ingress.Name, ingress.Namespace, hostname)
-					} else {
-						log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Disabling proxying for dns record %v (A) failed", initiator, ingress.Name, ingress.Namespace, hostname)
-					}
+		r = append(r, record)
+	}
 
-					return status, err
-				}
-			}
+	return r, nil
+}
 
-			// if use origin is disabled, remove the A record for the origin, if state still has a value for OriginRecordHostname
-			if desiredState.OriginRecordHostname != "" && (desiredState.UseOriginRecord != "true" || desiredState.OriginRecordHostname == "") {
+// getStructuredRecords returns the srv and loc records of a state; if either annotation fails to parse, the records that did parse are returned with the error
+func getStructuredRecords(state CloudflareState) (r []structuredRecord, err error) {
 
-				log.Info().Msgf("[%v] Ingress %v.%v - Deleting origin dns record %v (A)...", initiator, ingress.Name, ingress.Namespace, desiredState.OriginRecordHostname)
+	srvRecords, srvErr := parseSRVRecords(state.SRVRecords)
+	for _, record := range srvRecords {
+		r = append(r, structuredRecord{Type: "SRV", Name: record.Name, Data: record.Data})
+	}
 
-				_, err := cf.DeleteDNSRecord(desiredState.OriginRecordHostname)
-				if err != nil {
-					log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Deleting origin dns record %v (A) failed", initiator, ingress.Name, ingress.Namespace, desiredState.OriginRecordHostname)
-					return status, err
-				}
-			}
+	locRecords, locErr := parseLOCRecords(state.LOCRecords)
+	for _, record := range locRecords {
+		r = append(r, structuredRecord{Type: "LOC", Name: record.Name, Data: record.Data})
+	}
 
-			// if any state property changed make sure to update all
-			currentState = desiredState
+	if srvErr != nil {
+		return r, srvErr
+	}
 
-			log.Info().Msgf("[%v] Ingress %v.%v - Updating ingress because state has changed...", initiator, ingress.Name, ingress.Namespace)
+	return r, locErr
+}
 
-			// serialize state and store it in the annotation
-			cloudflareStateByteArray, err := json.Marshal(currentState)
-			if err != nil {
-				log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Marshalling state failed", initiator, ingress.Name, ingress.Namespace)
-				return status, err
-			}
-			ingress.Annotations[annotationCloudflareState] = string(cloudflareStateByteArray)
+// groupStructuredRecordsByTypeAndName returns the srv and loc records grouped into a set per type and name, in order of appearance
+func groupStructuredRecordsByTypeAndName(structuredRecords []structuredRecord) (r []structuredRecordSet) {
 
-			// update ingress, because the state annotations have changed
-			_, err = kubeClientset.NetworkingV1().Ingresses(ingress.Namespace).Update(ctx, ingress, metav1.UpdateOptions{})
-			if err != nil {
-				log.Error().Err(err).Msgf("[%v] Ingress %v.%v - Updating ingress state has failed", initiator, ingress.Name, ingress.Namespace)
-				return status, err
-			}
+	r = []structuredRecordSet{}
+	indexes := map[string]int{}
+	for _, structuredRecord := range structuredRecords {
+		key := structuredRecord.Type + " " + structuredRecord.Name
+		if _, ok := indexes[key]; !ok {
+			indexes[key] = len(r)
+			r = append(r, structuredRecordSet{Type: structuredRecord.Type, Name: structuredRecord.Name})
+		}
+		r[indexes[key]].Data = append(r[indexes[key]].Data, structuredRecord.Data)
+	}
 
-			status = "succeeded"
+	return
+}
 
-			log.Info().Msgf("[%v] Ingress %v.%v - Ingress has been updated successfully...", initiator, ingress.Name, ingress.Namespace)
+// groupCAARecordsByName returns the names of caa records in order of appearance and the data of the records for each name
+func groupCAARecordsByName(caaRecords []caaRecord) (names []string, data map[string][]CAARecordData) {
 
-			return status, nil
+	names = []string{}
+	data = map[string][]CAARecordData{}
+	for _, caaRecord := range caaRecords {
+		if _, ok := data[caaRecord.Name]; !ok {
+			names = append(names, caaRecord.Name)
 		}
+		data[caaRecord.Name] = append(data[caaRecord.Name], caaRecord.Data)
 	}
 
-	status = "skipped"
-
-	return status, nil
+	return
 }
 
-func processIngress(ctx context.Context, cf *Cloudflare, kubeClientset *kubernetes.Clientset, ingress *networkingv1.Ingress, initiator string) (status string, err error) {
-
-	status = "failed"
+// parseZoneSettings parses a comma-separated list of zone settings in the form 'name=value', like 'browser_cache_ttl=14400,always_use_https=on'; numeric values are sent as numbers
+func parseZoneSettings(zoneSettings string) (r []zoneSettingValue, err error) {
 
-	if ingress != nil {
+	r = []zoneSettingValue{}
+	for _, entry := range strings.Split(zoneSettings, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
 
-		desiredState := getDesiredIngressState(ingress)
-		currentState := getCurrentIngressState(ingress)
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return r, fmt.Errorf("Zone setting '%v' should have the form 'name=value'", strings.TrimSpace(entry))
+		}
 
-		status, err = makeIngressChanges(ctx, cf, kubeClientset, ingress, initiator, desiredState, currentState)
+		setting := zoneSettingValue{Name: strings.ToLower(strings.TrimSpace(parts[0])), Value: strings.TrimSpace(parts[1])}
+		if number, err := strconv.Atoi(strings.TrimSpace(parts[1])); err == nil {
+			setting.Value = number
+		}
 
-		return
+		r = append(r, setting)
 	}
 
-	status = "skipped"
-
-	return status, nil
+	return r, nil
 }
 
-func deleteIngress(cf *Cloudflare, kubeClientset *kubernetes.Clientset, ingress *networkingv1.Ingress, initiator string) (status string, err error) {
+// parseNSRecords parses a semicolon-separated list of delegations in the form 'name=ns1.provider.com,ns2.provider.com'
+func parseNSRecords(nsRecords string) (r []nsRecord, err error) {
 
-	status = "failed"
-
-	if ingress != nil {
-
-		desiredState := getDesiredIngressState(ingress)
+	r = []nsRecord{}
+	for _, entry := range strings.Split(nsRecords, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
 
-		dnsRecordType := "A"
-		if desiredState.UseOriginRecord == "true" && desiredState.OriginRecordHostname != "" {
-			dnsRecordType = "CNAME"
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return r, fmt.Errorf("Ns record '%v' should have the form 'name=ns1.provider.com,ns2.provider.com'", strings.TrimSpace(entry))
 		}
 
-		// loop all hostnames
-		hostnames := strings.Split(desiredState.Hostnames, ",")
-		for _, hostname := range hostnames {
-			log.Info().Msgf("[%v] Ingress %v.%v - Deleting dns record %v (%v) with ip address %v...", initiator, ingress.Name, ingress.Namespace, hostname, dnsRecordType, desiredState.IPAddress)
-			_, err = cf.DeleteDNSRecordIfMatching(hostname, dnsRecordType, desiredState.IPAddress)
-			if err != nil {
-				log.Warn().Err(err).Msgf("[%v] Ingress %v.%v - Failed deleting dns record %v (%v) with ip address %v...", initiator, ingress.Name, ingress.Namespace, hostname, dnsRecordType, desiredState.IPAddress)
-			} else {
-				status = "deleted"
-			}
+		record := nsRecord{Name: strings.TrimSpace(parts[0]), Nameservers: splitHostnames(parts[1])}
+		if len(record.Nameservers) == 0 {
+			return r, fmt.Errorf("Ns record '%v' should have at least one nameserver", record.Name)
 		}
 
-		return
+		r = append(r, record)
 	}
 
-	status = "skipped"
-
-	return status, nil
+	return r, nil
 }
 
-func validateHostname(hostname string) bool {
+var dnsLabelRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?$`)
+
+// validateHostname returns the reason why a hostname can't be used as dns record, or an empty string if it's valid
+func validateHostname(hostname string) (reason string) {
 	dnsNameParts := strings.Split(hostname, ".")
 	// we need at least a subdomain within a zone
 	if len(dnsNameParts) < 2 {
-		return false
+		return "too-few-parts"
 	}
-	// each label needs to be max 63 characters
-	for _, label := range dnsNameParts {
+	for i, label := range dnsNameParts {
+		// each label needs to be max 63 characters
 		if len(label) > 63 {
-			return false
+			return "label-too-long"
+		}
+		// a wildcard is only allowed as the leftmost label
+		if i == 0 && label == "*" {
+			continue
+		}
+		// labels consist of alphanumerics and hyphens, optionally prefixed with an underscore for records like _dmarc
+		if !dnsLabelRegex.MatchString(strings.TrimPrefix(label, "_")) {
+			return "invalid-characters"
 		}
 	}
-	return true
+	return ""
 }
 
-func watchServices(ctx context.Context, cf *Cloudflare, kubeClientset *kubernetes.Clientset, factory informers.SharedInformerFactory, waitGroup *sync.WaitGroup, stopper chan struct{}) {
-	servicesInformer := factory.Core().V1().Services().Informer()
+// normalizeIPAddress returns the ip address in its canonical form, after stripping a port, brackets or an ipv6 zone id
+func normalizeIPAddress(address string) (string, error) {
 
-	servicesInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
-			service, ok := obj.(*v1.Service)
-			if !ok {
-				log.Warn().Msg("Watcher for services returns event object of incorrect type")
-				return
-			}
+	host := strings.TrimSpace(address)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if i := strings.Index(host, "%"); i >= 0 {
+		host = host[:i]
+	}
 
-			waitGroup.Add(1)
-			status, err := processService(ctx, cf, kubeClientset, service, "watcher:added")
-			dnsRecordsTotals.With(prometheus.Labels{"namespace": service.Namespace, "status": status, "initiator": "watcher", "type": "service"}).Inc()
-			waitGroup.Done()
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return "", fmt.Errorf("Ip address '%v' is not a valid ipv4 or ipv6 address", address)
+	}
 
-			if err != nil {
-				log.Error().Err(err).Msgf("Processing service %v.%v failed", service.Name, service.Namespace)
-			}
-		},
-		UpdateFunc: func(oldObj interface{}, newObj interface{}) {
+	return ip.String(), nil
+}
 
-			service, ok := newObj.(*v1.Service)
-			if !ok {
-				log.Warn().Msg("Watcher for services returns event object of incorrect type")
-				return
-			}
+// normalizeRecordTargets returns the state with its load balancer ip addresses normalized, or an error if an ip address or hostname target isn't valid as record content
+func normalizeRecordTargets(state CloudflareState) (CloudflareState, error) {
 
-			waitGroup.Add(1)
-			status, err := processService(ctx, cf, kubeClientset, service, "watcher:modified")
-			dnsRecordsTotals.With(prometheus.Labels{"namespace": service.Namespace, "status": status, "initiator": "watcher", "type": "service"}).Inc()
-			waitGroup.Done()
+	if state.CNAMETarget != "" {
+		if reason := validateHostname(strings.TrimSuffix(state.CNAMETarget, ".")); reason != "" {
+			return state, fmt.Errorf("Cname target '%v' is not a valid hostname: %v", state.CNAMETarget, reason)
+		}
+		return state, nil
+	}
 
-			if err != nil {
-				log.Error().Err(err).Msgf("Processing service %v.%v failed", service.Name, service.Namespace)
+	if state.IPAddress == "" {
+		return state, nil
+	}
+
+	if state.TargetIsHostname == "true" {
+		if reason := validateHostname(strings.TrimSuffix(state.IPAddress, ".")); reason != "" {
+			return state, fmt.Errorf("Load balancer hostname '%v' is not a valid hostname: %v", state.IPAddress, reason)
+		}
+		return state, nil
+	}
+
+	ipAddresses := getStateIPAddresses(state)
+	for i := range ipAddresses {
+		ipAddress, err := normalizeIPAddress(ipAddresses[i])
+		if err != nil {
+			return state, err
+		}
+		ipAddresses[i] = ipAddress
+	}
+
+	state.IPAddress = ipAddresses[0]
+	state.AdditionalIPAddresses = strings.Join(ipAddresses[1:], ",")
+
+	return state, nil
+}
+
+// unwrapDeletedObject returns the last known object wrapped in a tombstone, which the informer hands to delete handlers when it missed the actual delete event
+func unwrapDeletedObject(obj interface{}) interface{} {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		return tombstone.Obj
+	}
+	return obj
+}
+
+// waitForInformerCacheSync blocks until the informer has synced its cache, so events and the poller act on a complete view of the cluster; returns an error if that takes too long or the informer gets stopped, so the caller can exit and let kubernetes restart the controller with backoff
+func waitForInformerCacheSync(ctx context.Context, kind string, informer cache.SharedIndexInformer, stopper chan struct{}) error {
+
+	log.Info().Msgf("Waiting for %v informer cache to sync...", kind)
+
+	syncCtx, cancel := context.WithTimeout(ctx, informerCacheSyncTimeout)
+	defer cancel()
+
+	// stop waiting when the informers get stopped as well
+	go func() {
+		select {
+		case <-stopper:
+			cancel()
+		case <-syncCtx.Done():
+		}
+	}()
+
+	if !cache.WaitForCacheSync(syncCtx.Done(), informer.HasSynced) {
+		select {
+		case <-stopper:
+			return fmt.Errorf("%w: %v", errInformerCacheSyncStopped, kind)
+		default:
+			return fmt.Errorf("%w: syncing %v informer cache did not complete within %v", errInformerCacheSyncTimeout, kind, informerCacheSyncTimeout)
+		}
+	}
+
+	log.Info().Msgf("Informer cache for %v has synced, watching for changes", kind)
+
+	return nil
+}
+
+func watchServices(ctx context.Context, cf *Cloudflare, kubeClientset kubernetes.Interface, recorder record.EventRecorder, factory informers.SharedInformerFactory, waitGroup *sync.WaitGroup, stopper chan struct{}) error {
+	servicesInformer := factory.Core().V1().Services().Informer()
+
+	servicesQueue := newObjectQueue("service", servicesInformer.GetIndexer(),
+		func(obj interface{}) (string, int, error) {
+			service, ok := obj.(*v1.Service)
+			if !ok {
+				return "failed", 0, errors.New("Watcher for services returns event object of incorrect type")
 			}
+			return processService(ctx, cf, kubeClientset, recorder, service, "watcher")
 		},
-		DeleteFunc: func(obj interface{}) {
-
+		func(obj interface{}) (string, int, error) {
 			service, ok := obj.(*v1.Service)
 			if !ok {
-				log.Warn().Msg("Watcher for services returns event object of incorrect type")
-				return
+				return "failed", 0, errors.New("Watcher for services returns event object of incorrect type")
 			}
-
-			waitGroup.Add(1)
-			status, err := deleteService(cf, kubeClientset, service, "watcher:deleted")
-			dnsRecordsTotals.With(prometheus.Labels{"namespace": service.Namespace, "status": status, "initiator": "watcher", "type": "service"}).Inc()
-			waitGroup.Done()
-
-			if err != nil {
-				log.Error().Err(err).Msgf("Deleting service %v.%v failed", service.Name, service.Namespace)
+			// the records are gone already if the service got deleted after handling its finalizer
+			if wasFinalized("Service", service.Namespace, service.Name) {
+				return "skipped", 0, nil
 			}
+			return deleteService(ctx, cf, kubeClientset, recorder, service, "watcher:deleted")
 		},
-	})
+	)
+
+	servicesInformer.AddEventHandler(servicesQueue.handlers())
+
+	watchInformerHealth("services", servicesInformer, stopper)
 
 	go servicesInformer.Run(stopper)
+
+	if err := waitForInformerCacheSync(ctx, "services", servicesInformer, stopper); err != nil {
+		return err
+	}
+
+	servicesQueue.run(*watcherConcurrency, waitGroup, stopper)
+
+	return nil
 }
 
-func watchIngresses(ctx context.Context, cf *Cloudflare, kubeClientset *kubernetes.Clientset, factory informers.SharedInformerFactory, waitGroup *sync.WaitGroup, stopper chan struct{}) {
+func watchIngresses(ctx context.Context, cf *Cloudflare, kubeClientset kubernetes.Interface, recorder record.EventRecorder, factory informers.SharedInformerFactory, waitGroup *sync.WaitGroup, stopper chan struct{}) error {
 	ingressesInformer := factory.Networking().V1().Ingresses().Informer()
 
-	ingressesInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
+	ingressesQueue := newObjectQueue("ingress", ingressesInformer.GetIndexer(),
+		func(obj interface{}) (string, int, error) {
 			ingress, ok := obj.(*networkingv1.Ingress)
 			if !ok {
-				log.Warn().Msg("Watcher for ingresses returns event object of incorrect type")
-				return
-			}
-
-			waitGroup.Add(1)
-			status, err := processIngress(ctx, cf, kubeClientset, ingress, "watcher:added")
-			dnsRecordsTotals.With(prometheus.Labels{"namespace": ingress.Namespace, "status": status, "initiator": "watcher", "type": "ingress"}).Inc()
-			waitGroup.Done()
-
-			if err != nil {
-				log.Error().Err(err).Msgf("Processing ingress %v.%v failed", ingress.Name, ingress.Namespace)
+				return "failed", 0, errors.New("Watcher for ingresses returns event object of incorrect type")
 			}
+			return processIngress(ctx, cf, kubeClientset, recorder, ingress, "watcher")
 		},
-		UpdateFunc: func(oldObj interface{}, newObj interface{}) {
-
-			ingress, ok := newObj.(*networkingv1.Ingress)
+		func(obj interface{}) (string, int, error) {
+			ingress, ok := obj.(*networkingv1.Ingress)
 			if !ok {
-				log.Warn().Msg("Watcher for ingresses returns event object of incorrect type")
-				return
+				return "failed", 0, errors.New("Watcher for ingresses returns event object of incorrect type")
 			}
-
-			waitGroup.Add(1)
-			status, err := processIngress(ctx, cf, kubeClientset, ingress, "watcher:modified")
-			dnsRecordsTotals.With(prometheus.Labels{"namespace": ingress.Namespace, "status": status, "initiator": "watcher", "type": "ingress"}).Inc()
-			waitGroup.Done()
-
-			if err != nil {
-				log.Error().Err(err).Msgf("Processing ingress %v.%v failed", ingress.Name, ingress.Namespace)
+			// the records are gone already if the ingress got deleted after handling its finalizer
+			if wasFinalized("Ingress", ingress.Namespace, ingress.Name) {
+				return "skipped", 0, nil
 			}
-
+			return deleteIngress(ctx, cf, kubeClientset, recorder, ingress, "watcher:deleted")
 		},
-		DeleteFunc: func(obj interface{}) {
-
-			ingress, ok := obj.(*networkingv1.Ingress)
-			if !ok {
-				log.Warn().Msg("Watcher for ingresses returns event object of incorrect type")
-				return
-			}
+	)
 
-			waitGroup.Add(1)
-			status, err := deleteIngress(cf, kubeClientset, ingress, "watcher:delete")
-			dnsRecordsTotals.With(prometheus.Labels{"namespace": ingress.Namespace, "status": status, "initiator": "watcher", "type": "ingress"}).Inc()
-			waitGroup.Done()
+	ingressesInformer.AddEventHandler(ingressesQueue.handlers())
 
-			if err != nil {
-				log.Error().Err(err).Msgf("Deleting ingress %v.%v failed", ingress.Name, ingress.Namespace)
-			}
-		},
-	})
+	watchInformerHealth("ingresses", ingressesInformer, stopper)
 
 	go ingressesInformer.Run(stopper)
+
+	if err := waitForInformerCacheSync(ctx, "ingresses", ingressesInformer, stopper); err != nil {
+		return err
+	}
+
+	ingressesQueue.run(*watcherConcurrency, waitGroup, stopper)
+
+	return nil
 }
@@ -0,0 +1,5372 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestUnwrapDeletedObject(t *testing.T) {
+
+	t.Run("ReturnsObjectWhenObjectIsNotATombstone", func(t *testing.T) {
+
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+
+		// act
+		obj := unwrapDeletedObject(service)
+
+		unwrappedService, ok := obj.(*v1.Service)
+		assert.True(t, ok)
+		assert.Equal(t, service, unwrappedService)
+	})
+
+	t.Run("ReturnsWrappedObjectWhenObjectIsATombstone", func(t *testing.T) {
+
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		tombstone := cache.DeletedFinalStateUnknown{Key: "mynamespace/myservice", Obj: service}
+
+		// act
+		obj := unwrapDeletedObject(tombstone)
+
+		unwrappedService, ok := obj.(*v1.Service)
+		assert.True(t, ok)
+		assert.Equal(t, service, unwrappedService)
+	})
+}
+
+func TestSplitHostnames(t *testing.T) {
+
+	t.Run("ReturnsSingleHostname", func(t *testing.T) {
+
+		// act
+		hostnames := splitHostnames("a.example.com")
+
+		assert.Equal(t, []string{"a.example.com"}, hostnames)
+	})
+
+	t.Run("TrimsWhitespaceAroundHostnames", func(t *testing.T) {
+
+		// act
+		hostnames := splitHostnames(" a.example.com, b.example.com ,c.example.com")
+
+		assert.Equal(t, []string{"a.example.com", "b.example.com", "c.example.com"}, hostnames)
+	})
+
+	t.Run("SkipsEmptyEntriesFromTrailingOrDoubleCommas", func(t *testing.T) {
+
+		// act
+		hostnames := splitHostnames("a.example.com,,b.example.com, ,")
+
+		assert.Equal(t, []string{"a.example.com", "b.example.com"}, hostnames)
+	})
+
+	t.Run("ReturnsEmptySliceForEmptyString", func(t *testing.T) {
+
+		// act
+		hostnames := splitHostnames("")
+
+		assert.Equal(t, 0, len(hostnames))
+	})
+}
+
+func TestNormalizeHostnames(t *testing.T) {
+
+	t.Run("ConvertsUnicodeHostnamesToPunycode", func(t *testing.T) {
+
+		// act
+		hostnames := normalizeHostnames("bücher.example.com, www.example.com")
+
+		assert.Equal(t, "xn--bcher-kva.example.com,www.example.com", hostnames)
+	})
+
+	t.Run("ReturnsAsciiHostnamesUnchanged", func(t *testing.T) {
+
+		// act
+		hostnames := normalizeHostnames("www.example.com, api.example.com")
+
+		assert.Equal(t, "www.example.com, api.example.com", hostnames)
+	})
+
+	t.Run("StoresPunycodeHostnamesInDesiredServiceState", func(t *testing.T) {
+
+		service := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myservice",
+				Namespace: "mynamespace",
+				Annotations: map[string]string{
+					annotationCloudflareDNS:       "true",
+					annotationCloudflareHostnames: "bücher.example.com",
+				},
+			},
+		}
+
+		// act
+		state := getDesiredServiceState(service)
+
+		assert.Equal(t, "xn--bcher-kva.example.com", state.Hostnames)
+		assert.Equal(t, "", validateHostname(state.Hostnames))
+	})
+}
+
+func TestParseSRVRecords(t *testing.T) {
+
+	t.Run("ReturnsSRVRecordsForValidEntries", func(t *testing.T) {
+
+		// act
+		srvRecords, err := parseSRVRecords("_sip._tcp.example.com 10 5 5060 sip.example.com, _xmpp._tcp.example.com 20 0 5222 xmpp.example.com")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(srvRecords))
+		assert.Equal(t, "_sip._tcp.example.com", srvRecords[0].Name)
+		assert.Equal(t, SRVRecordData{Priority: 10, Weight: 5, Port: 5060, Target: "sip.example.com"}, srvRecords[0].Data)
+		assert.Equal(t, "_xmpp._tcp.example.com", srvRecords[1].Name)
+		assert.Equal(t, SRVRecordData{Priority: 20, Weight: 0, Port: 5222, Target: "xmpp.example.com"}, srvRecords[1].Data)
+	})
+
+	t.Run("ReturnsEmptySliceForEmptyString", func(t *testing.T) {
+
+		// act
+		srvRecords, err := parseSRVRecords("")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(srvRecords))
+	})
+
+	t.Run("ReturnsErrorForMissingFields", func(t *testing.T) {
+
+		// act
+		_, err := parseSRVRecords("_sip._tcp.example.com 10 5 sip.example.com")
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorForNonNumericPort", func(t *testing.T) {
+
+		// act
+		_, err := parseSRVRecords("_sip._tcp.example.com 10 5 sip sip.example.com")
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestParseLOCRecords(t *testing.T) {
+
+	t.Run("ReturnsLOCRecordsForValidEntries", func(t *testing.T) {
+
+		// act
+		locRecords, err := parseLOCRecords("ams.example.com 52 22 23.5 N 4 53 32 E -2m 0m 10000m 10m, sfo.example.com 37 37 0 N 122 22 30 W 4 1 100 10")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(locRecords))
+		assert.Equal(t, "ams.example.com", locRecords[0].Name)
+		assert.Equal(t, LOCRecordData{LatDegrees: 52, LatMinutes: 22, LatSeconds: 23.5, LatDirection: "N", LongDegrees: 4, LongMinutes: 53, LongSeconds: 32, LongDirection: "E", Altitude: -2, Size: 0, PrecisionHorz: 10000, PrecisionVert: 10}, locRecords[0].Data)
+		assert.Equal(t, "sfo.example.com", locRecords[1].Name)
+		assert.Equal(t, LOCRecordData{LatDegrees: 37, LatMinutes: 37, LatSeconds: 0, LatDirection: "N", LongDegrees: 122, LongMinutes: 22, LongSeconds: 30, LongDirection: "W", Altitude: 4, Size: 1, PrecisionHorz: 100, PrecisionVert: 10}, locRecords[1].Data)
+	})
+
+	t.Run("ReturnsEmptySliceForEmptyString", func(t *testing.T) {
+
+		// act
+		locRecords, err := parseLOCRecords("")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(locRecords))
+	})
+
+	t.Run("ReturnsErrorForMissingFields", func(t *testing.T) {
+
+		// act
+		_, err := parseLOCRecords("ams.example.com 52 22 23.5 N 4 53 32 E")
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorForInvalidDirection", func(t *testing.T) {
+
+		// act
+		_, err := parseLOCRecords("ams.example.com 52 22 23.5 E 4 53 32 N -2m 0m 10000m 10m")
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorForOutOfRangeDegrees", func(t *testing.T) {
+
+		// act
+		_, err := parseLOCRecords("ams.example.com 91 22 23.5 N 4 53 32 E -2m 0m 10000m 10m")
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorForNegativePrecision", func(t *testing.T) {
+
+		// act
+		_, err := parseLOCRecords("ams.example.com 52 22 23.5 N 4 53 32 E -2m 0m -1m 10m")
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestGetStructuredRecords(t *testing.T) {
+
+	t.Run("ReturnsSRVAndLOCRecordsWithTheirType", func(t *testing.T) {
+
+		state := CloudflareState{SRVRecords: "_sip._tcp.example.com 10 5 5060 sip.example.com", LOCRecords: "example.com 52 22 23 N 4 53 32 E 0 1 10000 10"}
+
+		// act
+		records, err := getStructuredRecords(state)
+
+		assert.Nil(t, err)
+		if assert.Equal(t, 2, len(records)) {
+			assert.Equal(t, "SRV", records[0].Type)
+			assert.Equal(t, SRVRecordData{Priority: 10, Weight: 5, Port: 5060, Target: "sip.example.com"}, records[0].Data)
+			assert.Equal(t, "LOC", records[1].Type)
+			assert.Equal(t, "example.com", records[1].Name)
+		}
+	})
+
+	t.Run("ReturnsRecordsThatParsedWithErrorOfTheOthers", func(t *testing.T) {
+
+		state := CloudflareState{SRVRecords: "_sip._tcp.example.com 10 5", LOCRecords: "example.com 52 22 23 N 4 53 32 E 0 1 10000 10"}
+
+		// act
+		records, err := getStructuredRecords(state)
+
+		assert.NotNil(t, err)
+		if assert.Equal(t, 1, len(records)) {
+			assert.Equal(t, "LOC", records[0].Type)
+		}
+	})
+}
+
+func TestParseCAARecords(t *testing.T) {
+
+	t.Run("ReturnsCAARecordsForValidEntries", func(t *testing.T) {
+
+		// act
+		caaRecords, err := parseCAARecords("example.com 0 issue letsencrypt.org, example.com 128 iodef mailto:security@example.com")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(caaRecords))
+		assert.Equal(t, "example.com", caaRecords[0].Name)
+		assert.Equal(t, CAARecordData{Flags: 0, Tag: "issue", Value: "letsencrypt.org"}, caaRecords[0].Data)
+		assert.Equal(t, CAARecordData{Flags: 128, Tag: "iodef", Value: "mailto:security@example.com"}, caaRecords[1].Data)
+	})
+
+	t.Run("ReturnsValueWithSpaces", func(t *testing.T) {
+
+		// act
+		caaRecords, err := parseCAARecords("example.com 0 issue letsencrypt.org; validationmethods=dns-01")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(caaRecords))
+		assert.Equal(t, "letsencrypt.org; validationmethods=dns-01", caaRecords[0].Data.Value)
+	})
+
+	t.Run("ReturnsEmptySliceForEmptyString", func(t *testing.T) {
+
+		// act
+		caaRecords, err := parseCAARecords("")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(caaRecords))
+	})
+
+	t.Run("ReturnsErrorForMissingFields", func(t *testing.T) {
+
+		// act
+		_, err := parseCAARecords("example.com 0 issue")
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorForInvalidFlags", func(t *testing.T) {
+
+		// act
+		_, err := parseCAARecords("example.com 256 issue letsencrypt.org")
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorForInvalidTag", func(t *testing.T) {
+
+		// act
+		_, err := parseCAARecords("example.com 0 issuer letsencrypt.org")
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestGroupCAARecordsByName(t *testing.T) {
+
+	t.Run("GroupsDataByNameInOrderOfAppearance", func(t *testing.T) {
+
+		caaRecords := []caaRecord{
+			{Name: "b.example.com", Data: CAARecordData{Tag: "issue", Value: "letsencrypt.org"}},
+			{Name: "a.example.com", Data: CAARecordData{Tag: "issue", Value: "digicert.com"}},
+			{Name: "b.example.com", Data: CAARecordData{Tag: "issuewild", Value: ";"}},
+		}
+
+		// act
+		names, data := groupCAARecordsByName(caaRecords)
+
+		assert.Equal(t, []string{"b.example.com", "a.example.com"}, names)
+		assert.Equal(t, 2, len(data["b.example.com"]))
+		assert.Equal(t, 1, len(data["a.example.com"]))
+	})
+}
+
+func TestGroupStructuredRecordsByTypeAndName(t *testing.T) {
+
+	t.Run("GroupsDataByTypeAndNameInOrderOfAppearance", func(t *testing.T) {
+
+		structuredRecords := []structuredRecord{
+			{Type: "SRV", Name: "_sip._tcp.example.com", Data: SRVRecordData{Priority: 10, Weight: 5, Port: 5060, Target: "sip1.example.com"}},
+			{Type: "SRV", Name: "_sip._tcp.example.com", Data: SRVRecordData{Priority: 20, Weight: 5, Port: 5060, Target: "sip2.example.com"}},
+			{Type: "LOC", Name: "example.com", Data: LOCRecordData{LatDegrees: 52, LatDirection: "N", LongDegrees: 4, LongDirection: "E"}},
+		}
+
+		// act
+		structuredRecordSets := groupStructuredRecordsByTypeAndName(structuredRecords)
+
+		assert.Equal(t, 2, len(structuredRecordSets))
+		assert.Equal(t, "SRV", structuredRecordSets[0].Type)
+		assert.Equal(t, "_sip._tcp.example.com", structuredRecordSets[0].Name)
+		assert.Equal(t, 2, len(structuredRecordSets[0].Data))
+		assert.Equal(t, "LOC", structuredRecordSets[1].Type)
+		assert.Equal(t, 1, len(structuredRecordSets[1].Data))
+	})
+}
+
+func TestParseNSRecords(t *testing.T) {
+
+	t.Run("ReturnsNSRecordsForValidEntries", func(t *testing.T) {
+
+		// act
+		nsRecords, err := parseNSRecords("sub.example.com=ns1.provider.com,ns2.provider.com; other.example.com=ns3.provider.com")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(nsRecords))
+		assert.Equal(t, "sub.example.com", nsRecords[0].Name)
+		assert.Equal(t, []string{"ns1.provider.com", "ns2.provider.com"}, nsRecords[0].Nameservers)
+		assert.Equal(t, "other.example.com", nsRecords[1].Name)
+		assert.Equal(t, []string{"ns3.provider.com"}, nsRecords[1].Nameservers)
+	})
+
+	t.Run("ReturnsEmptySliceForEmptyString", func(t *testing.T) {
+
+		// act
+		nsRecords, err := parseNSRecords("")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(nsRecords))
+	})
+
+	t.Run("ReturnsErrorForMissingNameservers", func(t *testing.T) {
+
+		// act
+		_, err := parseNSRecords("sub.example.com=")
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorForMissingName", func(t *testing.T) {
+
+		// act
+		_, err := parseNSRecords("ns1.provider.com,ns2.provider.com")
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestParseZoneSettings(t *testing.T) {
+
+	t.Run("ReturnsZoneSettingsForValidEntries", func(t *testing.T) {
+
+		// act
+		zoneSettings, err := parseZoneSettings("browser_cache_ttl=14400, Always_Use_HTTPS=on")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(zoneSettings))
+		assert.Equal(t, "browser_cache_ttl", zoneSettings[0].Name)
+		assert.Equal(t, 14400, zoneSettings[0].Value)
+		assert.Equal(t, "always_use_https", zoneSettings[1].Name)
+		assert.Equal(t, "on", zoneSettings[1].Value)
+	})
+
+	t.Run("ReturnsEmptySliceForEmptyString", func(t *testing.T) {
+
+		// act
+		zoneSettings, err := parseZoneSettings("")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(zoneSettings))
+	})
+
+	t.Run("ReturnsErrorForMissingValue", func(t *testing.T) {
+
+		// act
+		_, err := parseZoneSettings("browser_cache_ttl=")
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorForMissingName", func(t *testing.T) {
+
+		// act
+		_, err := parseZoneSettings("on")
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestGetBooleanAnnotation(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		defaultValue bool
+		value        string
+	}{
+		{"LowercaseTrue", map[string]string{annotationCloudflareDNS: "true"}, false, "true"},
+		{"MixedCaseTrueWithWhitespace", map[string]string{annotationCloudflareDNS: " True\n"}, false, "true"},
+		{"One", map[string]string{annotationCloudflareDNS: "1"}, false, "true"},
+		{"OneWithWhitespace", map[string]string{annotationCloudflareDNS: "\t1 "}, false, "true"},
+		{"LowercaseFalse", map[string]string{annotationCloudflareDNS: "false"}, true, "false"},
+		{"UppercaseFalseWithWhitespace", map[string]string{annotationCloudflareDNS: "  FALSE "}, true, "false"},
+		{"Zero", map[string]string{annotationCloudflareDNS: "0"}, true, "false"},
+		{"ZeroWithWhitespace", map[string]string{annotationCloudflareDNS: " 0\n"}, true, "false"},
+		{"MissingFallsBackToDefaultTrue", map[string]string{}, true, "true"},
+		{"MissingFallsBackToDefaultFalse", map[string]string{}, false, "false"},
+		{"YesFallsBackToDefault", map[string]string{annotationCloudflareDNS: "yes"}, false, "false"},
+		{"OnFallsBackToDefault", map[string]string{annotationCloudflareDNS: "on"}, true, "true"},
+		{"TwoFallsBackToDefault", map[string]string{annotationCloudflareDNS: "2"}, false, "false"},
+		{"EmptyFallsBackToDefault", map[string]string{annotationCloudflareDNS: ""}, true, "true"},
+		{"TrueWithTrailingTextFallsBackToDefault", map[string]string{annotationCloudflareDNS: "true!"}, false, "false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// act
+			value := getBooleanAnnotation(tt.annotations, annotationCloudflareDNS, tt.defaultValue, "Service", "myservice", "mynamespace")
+
+			assert.Equal(t, tt.value, value)
+		})
+	}
+}
+
+func TestGetProxyAnnotation(t *testing.T) {
+
+	t.Run("ReturnsAutoIfSetToAuto", func(t *testing.T) {
+
+		// act
+		proxy := getProxyAnnotation(map[string]string{annotationCloudflareProxy: " Auto"}, "Service", "myservice", "mynamespace")
+
+		assert.Equal(t, "auto", proxy)
+	})
+
+	t.Run("ReturnsBooleanValueIfSetToBoolean", func(t *testing.T) {
+
+		// act
+		proxy := getProxyAnnotation(map[string]string{annotationCloudflareProxy: "false"}, "Service", "myservice", "mynamespace")
+
+		assert.Equal(t, "false", proxy)
+	})
+
+	t.Run("DefaultsToTrue", func(t *testing.T) {
+
+		// act
+		proxy := getProxyAnnotation(map[string]string{}, "Service", "myservice", "mynamespace")
+
+		assert.Equal(t, "true", proxy)
+	})
+
+	t.Run("ReturnsFalseIfProxyIsDisabledRegardlessOfAnnotation", func(t *testing.T) {
+
+		*disableProxy = true
+		defer func() { *disableProxy = false }()
+
+		// act
+		proxy := getProxyAnnotation(map[string]string{annotationCloudflareProxy: "true"}, "Service", "myservice", "mynamespace")
+		autoProxy := getProxyAnnotation(map[string]string{annotationCloudflareProxy: "auto"}, "Service", "myservice", "mynamespace")
+		booleanProxy := getBooleanProxyAnnotation(map[string]string{annotationCloudflareProxy: "true"}, "Ingress", "myingress", "mynamespace")
+
+		assert.Equal(t, "false", proxy)
+		assert.Equal(t, "false", autoProxy)
+		assert.Equal(t, "false", booleanProxy)
+	})
+}
+
+func TestGetTTLAnnotation(t *testing.T) {
+
+	t.Run("ReturnsTTLIfSetToNumberOfSeconds", func(t *testing.T) {
+
+		// act
+		ttl := getTTLAnnotation(map[string]string{annotationCloudflareTTL: " 300"}, "Service", "myservice", "mynamespace")
+
+		assert.Equal(t, "300", ttl)
+	})
+
+	t.Run("ReturnsEmptyStringIfNotSet", func(t *testing.T) {
+
+		// act
+		ttl := getTTLAnnotation(map[string]string{}, "Service", "myservice", "mynamespace")
+
+		assert.Equal(t, "", ttl)
+	})
+
+	t.Run("ReturnsEmptyStringIfInvalid", func(t *testing.T) {
+
+		// act
+		ttl := getTTLAnnotation(map[string]string{annotationCloudflareTTL: "5m"}, "Service", "myservice", "mynamespace")
+
+		assert.Equal(t, "", ttl)
+	})
+}
+
+func TestGetProxyAfterAnnotation(t *testing.T) {
+
+	t.Run("ReturnsDurationInCanonicalForm", func(t *testing.T) {
+
+		// act
+		proxyAfter := getProxyAfterAnnotation(map[string]string{annotationCloudflareProxyAfter: " 90m"}, "Service", "myservice", "mynamespace")
+
+		assert.Equal(t, "1h30m0s", proxyAfter)
+	})
+
+	t.Run("ReturnsEmptyStringIfInvalid", func(t *testing.T) {
+
+		// act
+		proxyAfter := getProxyAfterAnnotation(map[string]string{annotationCloudflareProxyAfter: "30"}, "Service", "myservice", "mynamespace")
+
+		assert.Equal(t, "", proxyAfter)
+	})
+}
+
+func TestGetProxyPlansAnnotation(t *testing.T) {
+
+	t.Run("ReturnsLowercasedPlansWithoutEmptyEntries", func(t *testing.T) {
+
+		// act
+		proxyPlans := getProxyPlansAnnotation(map[string]string{annotationCloudflareProxyPlans: " Pro, ,business "})
+
+		assert.Equal(t, "pro,business", proxyPlans)
+	})
+
+	t.Run("ReturnsEmptyStringIfNotSet", func(t *testing.T) {
+
+		// act
+		proxyPlans := getProxyPlansAnnotation(map[string]string{})
+
+		assert.Equal(t, "", proxyPlans)
+	})
+}
+
+func TestIsProxyAllowedByZonePlan(t *testing.T) {
+
+	freeZone := func(dnsName string) (Zone, error) {
+		return Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353", Name: "example.com", Plan: ZonePlan{Name: "Free Website", LegacyID: "free"}}, nil
+	}
+
+	t.Run("ReturnsFalseWithPlanForZoneOnRestrictedPlan", func(t *testing.T) {
+
+		// act
+		allowed, plan := isProxyAllowedByZonePlan(freeZone, "www.example.com", "pro,business")
+
+		assert.False(t, allowed)
+		assert.Equal(t, "free", plan)
+	})
+
+	t.Run("ReturnsTrueWithoutLookingUpZoneIfNoProxyPlans", func(t *testing.T) {
+
+		// act
+		allowed, _ := isProxyAllowedByZonePlan(func(dnsName string) (Zone, error) {
+			t.Fatal("zone shouldn't be looked up")
+			return Zone{}, nil
+		}, "www.example.com", "")
+
+		assert.True(t, allowed)
+	})
+
+	t.Run("ReturnsTrueIfZoneLookupFails", func(t *testing.T) {
+
+		// act
+		allowed, _ := isProxyAllowedByZonePlan(func(dnsName string) (Zone, error) { return Zone{}, errZoneNotFound }, "www.example.com", "pro")
+
+		assert.True(t, allowed)
+	})
+}
+
+func TestApplyProxyGracePeriod(t *testing.T) {
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("DisablesProxyAndStartsGracePeriodForNewRecords", func(t *testing.T) {
+
+		// act
+		state := applyProxyGracePeriod(CloudflareState{Enabled: "true", Proxy: "true", ProxyAfter: "30m0s"}, CloudflareState{}, now)
+
+		assert.Equal(t, "false", state.Proxy)
+		assert.Equal(t, "2024-03-01T12:00:00Z", state.DNSOnlySince)
+	})
+
+	t.Run("KeepsProxyDisabledUntilGracePeriodHasPassed", func(t *testing.T) {
+
+		// act
+		state := applyProxyGracePeriod(CloudflareState{Enabled: "true", Proxy: "auto", ProxyAfter: "30m0s"}, CloudflareState{Enabled: "true", DNSOnlySince: "2024-03-01T11:45:00Z"}, now)
+
+		assert.Equal(t, "false", state.Proxy)
+		assert.Equal(t, "2024-03-01T11:45:00Z", state.DNSOnlySince)
+	})
+
+	t.Run("EnablesProxyOnceGracePeriodHasPassed", func(t *testing.T) {
+
+		// act
+		state := applyProxyGracePeriod(CloudflareState{Enabled: "true", Proxy: "true", ProxyAfter: "30m0s"}, CloudflareState{Enabled: "true", DNSOnlySince: "2024-03-01T11:30:00Z"}, now)
+
+		assert.Equal(t, "true", state.Proxy)
+	})
+
+	t.Run("LeavesProxyAloneForRecordsThatExistedBeforeTheAnnotation", func(t *testing.T) {
+
+		// act
+		state := applyProxyGracePeriod(CloudflareState{Enabled: "true", Proxy: "true", ProxyAfter: "30m0s"}, CloudflareState{Enabled: "true", Proxy: "true"}, now)
+
+		assert.Equal(t, "true", state.Proxy)
+		assert.Equal(t, "", state.DNSOnlySince)
+	})
+}
+
+func TestGetSSLModeAnnotation(t *testing.T) {
+
+	t.Run("ReturnsSSLModeIfSetToKnownMode", func(t *testing.T) {
+
+		// act
+		sslMode := getSSLModeAnnotation(map[string]string{annotationCloudflareSSLMode: " Strict"}, "Service", "myservice", "mynamespace")
+
+		assert.Equal(t, "strict", sslMode)
+	})
+
+	t.Run("ReturnsEmptyStringIfNotSet", func(t *testing.T) {
+
+		// act
+		sslMode := getSSLModeAnnotation(map[string]string{}, "Service", "myservice", "mynamespace")
+
+		assert.Equal(t, "", sslMode)
+	})
+
+	t.Run("ReturnsEmptyStringIfUnknownMode", func(t *testing.T) {
+
+		// act
+		sslMode := getSSLModeAnnotation(map[string]string{annotationCloudflareSSLMode: "full-strict"}, "Service", "myservice", "mynamespace")
+
+		assert.Equal(t, "", sslMode)
+	})
+}
+
+func TestGetLoadBalancerTarget(t *testing.T) {
+
+	t.Run("ReturnsIPAddressWhenSet", func(t *testing.T) {
+
+		// act
+		target, targetIsHostname := getLoadBalancerTarget(v1.LoadBalancerIngress{IP: "1.2.3.4"})
+
+		assert.Equal(t, "1.2.3.4", target)
+		assert.Equal(t, "false", targetIsHostname)
+	})
+
+	t.Run("ReturnsHostnameWhenIPAddressIsEmpty", func(t *testing.T) {
+
+		// act
+		target, targetIsHostname := getLoadBalancerTarget(v1.LoadBalancerIngress{Hostname: "abc.elb.us-east-1.amazonaws.com"})
+
+		assert.Equal(t, "abc.elb.us-east-1.amazonaws.com", target)
+		assert.Equal(t, "true", targetIsHostname)
+	})
+
+	t.Run("PrefersIPAddressWhenBothAreSet", func(t *testing.T) {
+
+		// act
+		target, targetIsHostname := getLoadBalancerTarget(v1.LoadBalancerIngress{IP: "1.2.3.4", Hostname: "abc.elb.us-east-1.amazonaws.com"})
+
+		assert.Equal(t, "1.2.3.4", target)
+		assert.Equal(t, "false", targetIsHostname)
+	})
+}
+
+func TestGetTargetDNSRecordType(t *testing.T) {
+
+	t.Run("ReturnsARecordForIPAddress", func(t *testing.T) {
+
+		// act
+		dnsRecordType := getTargetDNSRecordType(CloudflareState{IPAddress: "1.2.3.4"})
+
+		assert.Equal(t, "A", dnsRecordType)
+	})
+
+	t.Run("ReturnsCNAMERecordForHostname", func(t *testing.T) {
+
+		// act
+		dnsRecordType := getTargetDNSRecordType(CloudflareState{IPAddress: "abc.elb.us-east-1.amazonaws.com", TargetIsHostname: "true"})
+
+		assert.Equal(t, "CNAME", dnsRecordType)
+	})
+
+	t.Run("ReturnsAAAARecordForIPv6Address", func(t *testing.T) {
+
+		// act
+		dnsRecordType := getTargetDNSRecordType(CloudflareState{IPAddress: "2001:db8::1"})
+
+		assert.Equal(t, "AAAA", dnsRecordType)
+	})
+
+	t.Run("ReturnsARecordForIPv4MappedIPv6Address", func(t *testing.T) {
+
+		// act
+		dnsRecordType := getTargetDNSRecordType(CloudflareState{IPAddress: "::ffff:1.2.3.4"})
+
+		assert.Equal(t, "A", dnsRecordType)
+	})
+}
+
+func TestGetHostnameDNSRecord(t *testing.T) {
+
+	t.Run("ReturnsARecordToIPAddress", func(t *testing.T) {
+
+		// act
+		dnsRecordType, dnsRecordContent := getHostnameDNSRecord(CloudflareState{IPAddress: "1.2.3.4"})
+
+		assert.Equal(t, "A", dnsRecordType)
+		assert.Equal(t, "1.2.3.4", dnsRecordContent)
+	})
+
+	t.Run("ReturnsCNAMERecordToOriginRecordHostnameWhenUseOriginRecordIsEnabled", func(t *testing.T) {
+
+		// act
+		dnsRecordType, dnsRecordContent := getHostnameDNSRecord(CloudflareState{IPAddress: "1.2.3.4", UseOriginRecord: "true", OriginRecordHostname: "origin.mydomain.com"})
+
+		assert.Equal(t, "CNAME", dnsRecordType)
+		assert.Equal(t, "origin.mydomain.com", dnsRecordContent)
+	})
+
+	t.Run("ReturnsCNAMERecordToCNAMETargetWhenSet", func(t *testing.T) {
+
+		// act
+		dnsRecordType, dnsRecordContent := getHostnameDNSRecord(CloudflareState{IPAddress: "1.2.3.4", UseOriginRecord: "true", OriginRecordHostname: "origin.mydomain.com", CNAMETarget: "mydomain.cdn.example.net"})
+
+		assert.Equal(t, "CNAME", dnsRecordType)
+		assert.Equal(t, "mydomain.cdn.example.net", dnsRecordContent)
+	})
+}
+
+func TestGetManagedRecords(t *testing.T) {
+
+	t.Run("ReturnsOriginHostnameAndInternalRecords", func(t *testing.T) {
+
+		state := CloudflareState{Enabled: "true", Hostnames: "www.mydomain.com,api.mydomain.com", Proxy: "true", UseOriginRecord: "true", OriginRecordHostname: "origin.mydomain.com", IPAddress: "1.2.3.4", InternalHostnames: "www.internal.mydomain.com", InternalIPAddress: "10.0.0.1"}
+
+		// act
+		records := getStateManagedRecords(state)
+
+		assert.Equal(t, []managedRecord{
+			{Name: "origin.mydomain.com", Type: "A", Content: "1.2.3.4", TTL: 1},
+			{Name: "www.mydomain.com", Type: "CNAME", Content: "origin.mydomain.com", Proxied: true, TTL: 1},
+			{Name: "api.mydomain.com", Type: "CNAME", Content: "origin.mydomain.com", Proxied: true, TTL: 1},
+			{Name: "www.internal.mydomain.com", Type: "A", Content: "10.0.0.1", TTL: 1},
+		}, records)
+	})
+
+	t.Run("SkipsHostnamesWithoutRecordType", func(t *testing.T) {
+
+		state := CloudflareState{Enabled: "true", Hostnames: "mydomain.com,www.mydomain.com", IPAddress: "1.2.3.4"}
+
+		// act
+		records := getManagedRecords(state, func(hostname string) (string, string) {
+			if hostname == "mydomain.com" {
+				return "", ""
+			}
+			return "A", "1.2.3.4"
+		})
+
+		assert.Equal(t, []managedRecord{{Name: "www.mydomain.com", Type: "A", Content: "1.2.3.4", TTL: 1}}, records)
+	})
+
+	t.Run("SkipsInternalRecordsIfInternalDnsIsDisabled", func(t *testing.T) {
+
+		state := CloudflareState{Enabled: "true", Hostnames: "www.mydomain.com", IPAddress: "1.2.3.4", InternalHostnames: "www.internal.mydomain.com", InternalDNS: "false", InternalIPAddress: "10.0.0.1"}
+
+		// act
+		records := getStateManagedRecords(state)
+
+		assert.Equal(t, []managedRecord{{Name: "www.mydomain.com", Type: "A", Content: "1.2.3.4", TTL: 1}}, records)
+	})
+
+	t.Run("ReturnsNoRecordsIfDnsIsDisabled", func(t *testing.T) {
+
+		state := CloudflareState{Enabled: "false", Hostnames: "www.mydomain.com", IPAddress: "1.2.3.4"}
+
+		// act
+		records := getStateManagedRecords(state)
+
+		assert.Empty(t, records)
+	})
+}
+
+func TestGetStoredRecords(t *testing.T) {
+
+	t.Run("ReturnsTrackedRecords", func(t *testing.T) {
+
+		state := CloudflareState{Enabled: "true", Hostnames: "www.mydomain.com", IPAddress: "1.2.3.4", Records: []managedRecord{{Name: "www.mydomain.com", Type: "AAAA", Content: "2001:db8::1", TTL: 1}}}
+
+		// act
+		records := getStoredRecords(state)
+
+		assert.Equal(t, []managedRecord{{Name: "www.mydomain.com", Type: "AAAA", Content: "2001:db8::1", TTL: 1}}, records)
+	})
+
+	t.Run("DerivesRecordsForStateStoredBeforeRecordsWereTracked", func(t *testing.T) {
+
+		state := CloudflareState{Enabled: "true", Hostnames: "www.mydomain.com", IPAddress: "1.2.3.4"}
+
+		// act
+		records := getStoredRecords(state)
+
+		assert.Equal(t, []managedRecord{{Name: "www.mydomain.com", Type: "A", Content: "1.2.3.4", TTL: 1}}, records)
+	})
+}
+
+func TestGetRecordCounts(t *testing.T) {
+
+	storedRecords := []managedRecord{
+		{Name: "www.mydomain.com", Type: "A", Content: "1.2.3.4", TTL: 1},
+		{Name: "api.mydomain.com", Type: "A", Content: "1.2.3.4", TTL: 1},
+		{Name: "old.mydomain.com", Type: "A", Content: "1.2.3.4", TTL: 1},
+	}
+
+	t.Run("CountsCreatedUpdatedAndDeletedRecordsWhenSucceeded", func(t *testing.T) {
+
+		finalRecords := []managedRecord{
+			{Name: "www.mydomain.com", Type: "A", Content: "1.2.3.4", TTL: 1},
+			{Name: "api.mydomain.com", Type: "A", Content: "5.6.7.8", TTL: 1},
+			{Name: "new.mydomain.com", Type: "A", Content: "1.2.3.4", TTL: 1},
+		}
+
+		// act
+		counts := getRecordCounts("succeeded", storedRecords, finalRecords)
+
+		assert.Equal(t, recordCounts{Created: 1, Updated: 1, Deleted: 1}, counts)
+	})
+
+	t.Run("CountsAllStoredRecordsAsDeletedWhenDeleted", func(t *testing.T) {
+
+		// act
+		counts := getRecordCounts("deleted", storedRecords, storedRecords)
+
+		assert.Equal(t, recordCounts{Deleted: 3}, counts)
+	})
+
+	t.Run("CountsNothingWhenSkipped", func(t *testing.T) {
+
+		// act
+		counts := getRecordCounts("skipped", storedRecords, []managedRecord{})
+
+		assert.Equal(t, recordCounts{}, counts)
+	})
+}
+
+func TestHandleZoneMissing(t *testing.T) {
+
+	t.Run("ReturnsZoneMissingStatusWithoutErrorWhenZoneIsNotFound", func(t *testing.T) {
+
+		// act
+		status, err := handleZoneMissing("Service", "myservice", "mynamespace", "failed", fmt.Errorf("upserting failed: %w", errZoneNotFound))
+
+		assert.Nil(t, err)
+		assert.Equal(t, "zone-missing", status)
+		_, tracked := zoneMissingObjects.Load("Service/mynamespace/myservice")
+		assert.True(t, tracked)
+	})
+
+	t.Run("ReturnsZoneNotAllowedStatusWithoutErrorWhenZoneIsNotAllowed", func(t *testing.T) {
+
+		// act
+		status, err := handleZoneMissing("Service", "disallowedservice", "mynamespace", "failed", fmt.Errorf("upserting failed: %w", errZoneNotAllowed))
+
+		assert.Nil(t, err)
+		assert.Equal(t, "zone-not-allowed", status)
+	})
+
+	t.Run("ReturnsZonePausedStatusWithoutErrorWhenZoneIsPaused", func(t *testing.T) {
+
+		// act
+		status, err := handleZoneMissing("Service", "pausedservice", "mynamespace", "failed", fmt.Errorf("upserting failed: %w", errZonePaused))
+
+		assert.Nil(t, err)
+		assert.Equal(t, "zone-paused", status)
+	})
+
+	t.Run("ForgetsObjectOnceProcessingSucceeds", func(t *testing.T) {
+
+		zoneMissingObjects.Store("Service/mynamespace/otherservice", true)
+
+		// act
+		status, err := handleZoneMissing("Service", "otherservice", "mynamespace", "succeeded", nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "succeeded", status)
+		_, tracked := zoneMissingObjects.Load("Service/mynamespace/otherservice")
+		assert.False(t, tracked)
+	})
+
+	t.Run("ReturnsOtherErrorsUnchanged", func(t *testing.T) {
+
+		otherErr := errors.New("some other error")
+
+		// act
+		status, err := handleZoneMissing("Ingress", "myingress", "mynamespace", "failed", otherErr)
+
+		assert.Equal(t, otherErr, err)
+		assert.Equal(t, "failed", status)
+	})
+}
+
+func TestIsIngressClassMatching(t *testing.T) {
+
+	nginx := "nginx"
+
+	t.Run("ReturnsTrueWhenIngressClassIsNotSet", func(t *testing.T) {
+
+		ingress := &networkingv1.Ingress{Spec: networkingv1.IngressSpec{IngressClassName: &nginx}}
+
+		// act
+		matching := isIngressClassMatching(ingress, "")
+
+		assert.True(t, matching)
+	})
+
+	t.Run("ReturnsTrueWhenIngressClassNameMatches", func(t *testing.T) {
+
+		ingress := &networkingv1.Ingress{Spec: networkingv1.IngressSpec{IngressClassName: &nginx}}
+
+		// act
+		matching := isIngressClassMatching(ingress, "nginx")
+
+		assert.True(t, matching)
+	})
+
+	t.Run("ReturnsFalseWhenIngressClassNameDoesNotMatch", func(t *testing.T) {
+
+		ingress := &networkingv1.Ingress{Spec: networkingv1.IngressSpec{IngressClassName: &nginx}}
+
+		// act
+		matching := isIngressClassMatching(ingress, "traefik")
+
+		assert.False(t, matching)
+	})
+
+	t.Run("ReturnsTrueWhenIngressClassAnnotationMatches", func(t *testing.T) {
+
+		ingress := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"kubernetes.io/ingress.class": "traefik"}}}
+
+		// act
+		matching := isIngressClassMatching(ingress, "traefik")
+
+		assert.True(t, matching)
+	})
+
+	t.Run("ReturnsFalseWhenIngressHasNoClass", func(t *testing.T) {
+
+		ingress := &networkingv1.Ingress{}
+
+		// act
+		matching := isIngressClassMatching(ingress, "nginx")
+
+		assert.False(t, matching)
+	})
+}
+
+func TestGetDesiredServiceStateInternalHostnames(t *testing.T) {
+
+	t.Run("ReturnsInternalIPAddressFromAnnotation", func(t *testing.T) {
+
+		service := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myservice",
+				Namespace: "mynamespace",
+				Annotations: map[string]string{
+					"estafette.io/cloudflare-dns":                 "true",
+					"estafette.io/cloudflare-internal-hostnames":  "myservice.internal.mydomain.com",
+					"estafette.io/cloudflare-internal-ip-address": "10.0.0.5",
+				},
+			},
+			Spec: v1.ServiceSpec{
+				ClusterIP: "10.96.0.12",
+			},
+		}
+
+		// act
+		state := getDesiredServiceState(service)
+
+		assert.Equal(t, "myservice.internal.mydomain.com", state.InternalHostnames)
+		assert.Equal(t, "10.0.0.5", state.InternalIPAddress)
+	})
+
+	t.Run("FallsBackToClusterIP", func(t *testing.T) {
+
+		service := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myservice",
+				Namespace: "mynamespace",
+				Annotations: map[string]string{
+					"estafette.io/cloudflare-dns":                "true",
+					"estafette.io/cloudflare-internal-hostnames": "myservice.internal.mydomain.com",
+				},
+			},
+			Spec: v1.ServiceSpec{
+				ClusterIP: "10.96.0.12",
+			},
+		}
+
+		// act
+		state := getDesiredServiceState(service)
+
+		assert.Equal(t, "10.96.0.12", state.InternalIPAddress)
+		assert.Equal(t, "true", state.InternalDNS)
+	})
+
+	t.Run("ReturnsInternalDnsDisabledFromAnnotation", func(t *testing.T) {
+
+		service := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myservice",
+				Namespace: "mynamespace",
+				Annotations: map[string]string{
+					"estafette.io/cloudflare-dns":                "true",
+					"estafette.io/cloudflare-internal-hostnames": "myservice.internal.mydomain.com",
+					"estafette.io/cloudflare-internal-dns":       "false",
+				},
+			},
+			Spec: v1.ServiceSpec{
+				ClusterIP: "10.96.0.12",
+			},
+		}
+
+		// act
+		state := getDesiredServiceState(service)
+
+		assert.Equal(t, "false", state.InternalDNS)
+		assert.Equal(t, "myservice.internal.mydomain.com", state.InternalHostnames)
+	})
+}
+
+func TestGetDesiredServiceStateHostnamesTemplate(t *testing.T) {
+
+	t.Run("ExpandsPlaceholdersInHostnames", func(t *testing.T) {
+
+		*defaultDomainSuffix = "mydomain.com"
+		defer func() { *defaultDomainSuffix = "" }()
+
+		service := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myservice",
+				Namespace: "mynamespace",
+				Annotations: map[string]string{
+					"estafette.io/cloudflare-dns":                "true",
+					"estafette.io/cloudflare-hostnames":          "{service}.{namespace}.{domain},www.mydomain.com",
+					"estafette.io/cloudflare-internal-hostnames": "{service}.internal.{domain}",
+				},
+			},
+		}
+
+		// act
+		state := getDesiredServiceState(service)
+
+		assert.Equal(t, "myservice.mynamespace.mydomain.com,www.mydomain.com", state.Hostnames)
+		assert.Equal(t, "myservice.internal.mydomain.com", state.InternalHostnames)
+	})
+
+	t.Run("SkipsHostnamesWithDomainPlaceholderIfNoDefaultDomainSuffixIsSet", func(t *testing.T) {
+
+		service := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myservice",
+				Namespace: "mynamespace",
+				Annotations: map[string]string{
+					"estafette.io/cloudflare-dns":       "true",
+					"estafette.io/cloudflare-hostnames": "{service}.{domain},www.mydomain.com",
+				},
+			},
+		}
+
+		// act
+		state := getDesiredServiceState(service)
+
+		assert.Equal(t, "www.mydomain.com", state.Hostnames)
+	})
+}
+
+func TestGetDesiredServiceStateRecordName(t *testing.T) {
+
+	t.Run("ReplacesHostnameWithRecordName", func(t *testing.T) {
+
+		service := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "web",
+				Namespace: "mynamespace",
+				Annotations: map[string]string{
+					"estafette.io/cloudflare-dns":         "true",
+					"estafette.io/cloudflare-hostnames":   "web.mydomain.com",
+					"estafette.io/cloudflare-record-name": "cdn.mydomain.com",
+				},
+			},
+		}
+
+		// act
+		state := getDesiredServiceState(service)
+
+		assert.Equal(t, "cdn.mydomain.com", state.Hostnames)
+		assert.Equal(t, "web.mydomain.com=cdn.mydomain.com", state.RecordNames)
+	})
+
+	t.Run("ReplacesMappedHostnamesAndInternalHostnames", func(t *testing.T) {
+
+		service := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "web",
+				Namespace: "mynamespace",
+				Annotations: map[string]string{
+					"estafette.io/cloudflare-dns":                "true",
+					"estafette.io/cloudflare-hostnames":          "web.mydomain.com,www.mydomain.com",
+					"estafette.io/cloudflare-internal-hostnames": "web.internal.mydomain.com",
+					"estafette.io/cloudflare-record-name":        "web.mydomain.com=cdn.mydomain.com, web.internal.mydomain.com=cdn.internal.mydomain.com",
+				},
+			},
+		}
+
+		// act
+		state := getDesiredServiceState(service)
+
+		assert.Equal(t, "cdn.mydomain.com,www.mydomain.com", state.Hostnames)
+		assert.Equal(t, "cdn.internal.mydomain.com", state.InternalHostnames)
+	})
+
+	t.Run("IgnoresRecordNameWithoutHostnameForMultipleHostnames", func(t *testing.T) {
+
+		service := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "web",
+				Namespace: "mynamespace",
+				Annotations: map[string]string{
+					"estafette.io/cloudflare-dns":         "true",
+					"estafette.io/cloudflare-hostnames":   "web.mydomain.com,www.mydomain.com",
+					"estafette.io/cloudflare-record-name": "cdn.mydomain.com",
+				},
+			},
+		}
+
+		// act
+		state := getDesiredServiceState(service)
+
+		assert.Equal(t, "web.mydomain.com,www.mydomain.com", state.Hostnames)
+		assert.Equal(t, "", state.RecordNames)
+	})
+}
+
+func TestApplyRecordNames(t *testing.T) {
+
+	t.Run("LeavesHostnamesUntouchedWithoutRecordNames", func(t *testing.T) {
+
+		// act
+		hostnames := applyRecordNames("web.mydomain.com, www.mydomain.com", "")
+
+		assert.Equal(t, "web.mydomain.com, www.mydomain.com", hostnames)
+	})
+
+	t.Run("SkipsDuplicateNames", func(t *testing.T) {
+
+		// act
+		hostnames := applyRecordNames("web.mydomain.com,www.mydomain.com", "web.mydomain.com=www.mydomain.com")
+
+		assert.Equal(t, "www.mydomain.com", hostnames)
+	})
+}
+
+func TestGetDesiredStateDefaults(t *testing.T) {
+
+	setDefaults := func() func() {
+		*defaultProxy = "false"
+		*defaultTTL = 300
+		*defaultUseOriginRecord = true
+		return func() {
+			*defaultProxy = ""
+			*defaultTTL = 0
+			*defaultUseOriginRecord = false
+		}
+	}
+
+	t.Run("AppliesFlagDefaultsToServiceWithoutAnnotations", func(t *testing.T) {
+
+		defer setDefaults()()
+
+		service := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myservice",
+				Namespace: "mynamespace",
+				Annotations: map[string]string{
+					"estafette.io/cloudflare-dns":       "true",
+					"estafette.io/cloudflare-hostnames": "www.mydomain.com",
+				},
+			},
+		}
+
+		// act
+		state := getDesiredServiceState(service)
+
+		assert.Equal(t, "false", state.Proxy)
+		assert.Equal(t, "300", state.TTL)
+		assert.Equal(t, "true", state.UseOriginRecord)
+	})
+
+	t.Run("AppliesFlagDefaultsToIngressWithoutAnnotations", func(t *testing.T) {
+
+		defer setDefaults()()
+
+		ingress := &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myingress",
+				Namespace: "mynamespace",
+				Annotations: map[string]string{
+					"estafette.io/cloudflare-dns":       "true",
+					"estafette.io/cloudflare-hostnames": "www.mydomain.com",
+				},
+			},
+		}
+
+		// act
+		state := getDesiredIngressState(ingress)
+
+		assert.Equal(t, "false", state.Proxy)
+		assert.Equal(t, "300", state.TTL)
+		assert.Equal(t, "true", state.UseOriginRecord)
+	})
+
+	t.Run("LetsAnnotationsOverrideFlagDefaults", func(t *testing.T) {
+
+		defer setDefaults()()
+
+		service := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myservice",
+				Namespace: "mynamespace",
+				Annotations: map[string]string{
+					"estafette.io/cloudflare-dns":               "true",
+					"estafette.io/cloudflare-hostnames":         "www.mydomain.com",
+					"estafette.io/cloudflare-proxy":             "true",
+					"estafette.io/cloudflare-ttl":               "1",
+					"estafette.io/cloudflare-use-origin-record": "false",
+				},
+			},
+		}
+
+		// act
+		state := getDesiredServiceState(service)
+
+		assert.Equal(t, "true", state.Proxy)
+		assert.Equal(t, "1", state.TTL)
+		assert.Equal(t, "false", state.UseOriginRecord)
+	})
+}
+
+func TestGetOriginRecordHostname(t *testing.T) {
+
+	authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+	setTemplate := func(template string) func() {
+		*originRecordTemplate = template
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.mydomain.com", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=mydomain.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "mydomain.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		originRecordZoneLookup = New(authentication)
+		originRecordZoneLookup.restClient = fakeRESTClient
+		return func() {
+			*originRecordTemplate = ""
+			originRecordZoneLookup = nil
+			originRecordZones = sync.Map{}
+		}
+	}
+
+	newService := func(annotations map[string]string) *v1.Service {
+		annotations["estafette.io/cloudflare-dns"] = "true"
+		annotations["estafette.io/cloudflare-hostnames"] = "www.mydomain.com,api.mydomain.com"
+		return &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace", Annotations: annotations}}
+	}
+
+	t.Run("GeneratesOriginRecordHostnameFromTemplateAndZoneOfFirstHostname", func(t *testing.T) {
+
+		defer setTemplate("origin-{name}-{namespace}.{zone}")()
+		service := newService(map[string]string{"estafette.io/cloudflare-use-origin-record": "true"})
+
+		// act
+		state := getDesiredServiceState(service)
+
+		assert.Equal(t, "origin-myservice-mynamespace.mydomain.com", state.OriginRecordHostname)
+	})
+
+	t.Run("GeneratesTheSameOriginRecordHostnameAcrossReconciles", func(t *testing.T) {
+
+		defer setTemplate("origin-{name}-{namespace}.{zone}")()
+		service := newService(map[string]string{"estafette.io/cloudflare-use-origin-record": "true"})
+		firstState := getDesiredServiceState(service)
+
+		// act
+		state := getDesiredServiceState(service)
+
+		assert.Equal(t, firstState.OriginRecordHostname, state.OriginRecordHostname)
+		originRecordZoneLookup.restClient.(*fakeRESTClient).AssertNumberOfCalls(t, "Get", 2)
+	})
+
+	t.Run("LooksUpZoneWithCredentialsOfService", func(t *testing.T) {
+
+		defer setTemplate("origin-{name}-{namespace}.{zone}")()
+		tenantAuthentication := APIAuthentication{Key: "origintenantkey", Email: "origin-tenant@server.com"}
+		originRecordZoneLookup.restClient.(*fakeRESTClient).On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.mydomain.com", tenantAuthentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+		originRecordZoneLookup.restClient.(*fakeRESTClient).On("Get", "https://api.cloudflare.com/client/v4/zones/?name=mydomain.com", tenantAuthentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "mydomain.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+		indexer.Add(&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "team-credentials", Namespace: "mynamespace"}, Data: map[string][]byte{"cloudflareApiEmail": []byte(tenantAuthentication.Email), "cloudflareApiKey": []byte(tenantAuthentication.Key)}})
+		secretLister = corelisters.NewSecretLister(indexer)
+		defer func() { secretLister = nil }()
+		service := newService(map[string]string{"estafette.io/cloudflare-use-origin-record": "true", annotationCloudflareCredentialsSecret: "team-credentials"})
+
+		// act
+		state := getDesiredServiceState(service)
+
+		assert.Equal(t, "origin-myservice-mynamespace.mydomain.com", state.OriginRecordHostname)
+		originRecordZoneLookup.restClient.(*fakeRESTClient).AssertNotCalled(t, "Get", "https://api.cloudflare.com/client/v4/zones/?name=mydomain.com", authentication)
+	})
+
+	t.Run("GeneratesOriginRecordHostnameForIngress", func(t *testing.T) {
+
+		defer setTemplate("origin-{name}-{namespace}.{zone}")()
+		ingress := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "myingress", Namespace: "mynamespace", Annotations: map[string]string{
+			"estafette.io/cloudflare-dns":               "true",
+			"estafette.io/cloudflare-hostnames":         "www.mydomain.com",
+			"estafette.io/cloudflare-use-origin-record": "true",
+		}}}
+
+		// act
+		state := getDesiredIngressState(ingress)
+
+		assert.Equal(t, "origin-myingress-mynamespace.mydomain.com", state.OriginRecordHostname)
+	})
+
+	t.Run("ReturnsExplicitOriginRecordHostnameOverTemplate", func(t *testing.T) {
+
+		defer setTemplate("origin-{name}-{namespace}.{zone}")()
+		service := newService(map[string]string{"estafette.io/cloudflare-use-origin-record": "true", "estafette.io/cloudflare-origin-record-hostname": "origin.mydomain.com"})
+
+		// act
+		state := getDesiredServiceState(service)
+
+		assert.Equal(t, "origin.mydomain.com", state.OriginRecordHostname)
+		originRecordZoneLookup.restClient.(*fakeRESTClient).AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+	})
+
+	t.Run("DoesNotGenerateOriginRecordHostnameIfOriginRecordIsNotUsed", func(t *testing.T) {
+
+		defer setTemplate("origin-{name}-{namespace}.{zone}")()
+		service := newService(map[string]string{})
+
+		// act
+		state := getDesiredServiceState(service)
+
+		assert.Equal(t, "", state.OriginRecordHostname)
+	})
+
+	t.Run("DoesNotGenerateOriginRecordHostnameWithoutTemplate", func(t *testing.T) {
+
+		service := newService(map[string]string{"estafette.io/cloudflare-use-origin-record": "true"})
+
+		// act
+		state := getDesiredServiceState(service)
+
+		assert.Equal(t, "", state.OriginRecordHostname)
+	})
+}
+
+func TestGetDesiredServiceStateMultipleLoadBalancerIPAddresses(t *testing.T) {
+
+	newLoadBalancerService := func(ingresses ...v1.LoadBalancerIngress) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myservice",
+				Namespace: "mynamespace",
+				Annotations: map[string]string{
+					"estafette.io/cloudflare-dns":       "true",
+					"estafette.io/cloudflare-hostnames": "myservice.mydomain.com",
+				},
+			},
+			Spec: v1.ServiceSpec{
+				Type: v1.ServiceTypeLoadBalancer,
+			},
+			Status: v1.ServiceStatus{
+				LoadBalancer: v1.LoadBalancerStatus{
+					Ingress: ingresses,
+				},
+			},
+		}
+	}
+
+	t.Run("ReturnsOtherIPAddressesAsAdditionalIPAddresses", func(t *testing.T) {
+
+		service := newLoadBalancerService(v1.LoadBalancerIngress{IP: "1.2.3.4"}, v1.LoadBalancerIngress{IP: "5.6.7.8"})
+
+		// act
+		state := getDesiredServiceState(service)
+
+		assert.Equal(t, "1.2.3.4", state.IPAddress)
+		assert.Equal(t, "5.6.7.8", state.AdditionalIPAddresses)
+		assert.Equal(t, []string{"1.2.3.4", "5.6.7.8"}, getStateIPAddresses(state))
+	})
+
+	t.Run("ReturnsNoAdditionalIPAddressesForASingleIPAddress", func(t *testing.T) {
+
+		service := newLoadBalancerService(v1.LoadBalancerIngress{IP: "1.2.3.4"})
+
+		// act
+		state := getDesiredServiceState(service)
+
+		assert.Equal(t, "1.2.3.4", state.IPAddress)
+		assert.Equal(t, "", state.AdditionalIPAddresses)
+		assert.Equal(t, []string{"1.2.3.4"}, getStateIPAddresses(state))
+	})
+
+	t.Run("SkipsDuplicateIPAddressesAndOnesOfAnotherFamily", func(t *testing.T) {
+
+		service := newLoadBalancerService(v1.LoadBalancerIngress{IP: "1.2.3.4"}, v1.LoadBalancerIngress{IP: "2001:db8::1"}, v1.LoadBalancerIngress{IP: "1.2.3.4"}, v1.LoadBalancerIngress{IP: "5.6.7.8"})
+
+		// act
+		state := getDesiredServiceState(service)
+
+		assert.Equal(t, "5.6.7.8", state.AdditionalIPAddresses)
+	})
+
+	t.Run("ReturnsAManagedRecordPerIPAddress", func(t *testing.T) {
+
+		service := newLoadBalancerService(v1.LoadBalancerIngress{IP: "1.2.3.4"}, v1.LoadBalancerIngress{IP: "5.6.7.8"})
+		state := getDesiredServiceState(service)
+
+		// act
+		records := getStateManagedRecords(state)
+
+		if assert.Equal(t, 2, len(records)) {
+			assert.Equal(t, "1.2.3.4", records[0].Content)
+			assert.Equal(t, "5.6.7.8", records[1].Content)
+			assert.Equal(t, "myservice.mydomain.com", records[1].Name)
+		}
+	})
+}
+
+func TestGetDesiredServiceStateNodePort(t *testing.T) {
+
+	newNode := func(name string, ready bool, externalIP string) *v1.Node {
+		readyStatus := v1.ConditionFalse
+		if ready {
+			readyStatus = v1.ConditionTrue
+		}
+		node := &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: v1.NodeStatus{
+				Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: readyStatus}},
+				Addresses:  []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.1"}},
+			},
+		}
+		if externalIP != "" {
+			node.Status.Addresses = append(node.Status.Addresses, v1.NodeAddress{Type: v1.NodeExternalIP, Address: externalIP})
+		}
+		return node
+	}
+
+	newNodePortService := func(annotations map[string]string) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "myservice",
+				Namespace:   "mynamespace",
+				Annotations: annotations,
+			},
+			Spec: v1.ServiceSpec{
+				Type:      v1.ServiceTypeNodePort,
+				ClusterIP: "10.96.0.12",
+			},
+		}
+	}
+
+	t.Run("ReturnsExternalIPAddressOfFirstReadyNodeIfOptedIn", func(t *testing.T) {
+
+		stopper := make(chan struct{})
+		defer close(stopper)
+		initNodeLister(context.Background(), fake.NewSimpleClientset(newNode("node-c", true, "3.3.3.3"), newNode("node-a", false, "1.1.1.1"), newNode("node-b", true, "2.2.2.2")), stopper)
+		defer func() { nodeLister = nil }()
+
+		service := newNodePortService(map[string]string{
+			"estafette.io/cloudflare-dns":                  "true",
+			"estafette.io/cloudflare-hostnames":            "myservice.mydomain.com",
+			"estafette.io/cloudflare-use-node-external-ip": "true",
+		})
+
+		// act
+		state := getDesiredServiceState(service)
+
+		assert.Equal(t, "2.2.2.2", state.IPAddress)
+		assert.Equal(t, "", state.TargetIsHostname)
+	})
+
+	t.Run("ReturnsEmptyIPAddressIfNotOptedIn", func(t *testing.T) {
+
+		stopper := make(chan struct{})
+		defer close(stopper)
+		initNodeLister(context.Background(), fake.NewSimpleClientset(newNode("node-a", true, "1.1.1.1")), stopper)
+		defer func() { nodeLister = nil }()
+
+		service := newNodePortService(map[string]string{
+			"estafette.io/cloudflare-dns":       "true",
+			"estafette.io/cloudflare-hostnames": "myservice.mydomain.com",
+		})
+
+		// act
+		state := getDesiredServiceState(service)
+
+		assert.Equal(t, "", state.IPAddress)
+	})
+
+	t.Run("ReturnsEmptyIPAddressIfNoReadyNodeHasExternalIPAddress", func(t *testing.T) {
+
+		stopper := make(chan struct{})
+		defer close(stopper)
+		initNodeLister(context.Background(), fake.NewSimpleClientset(newNode("node-a", false, "1.1.1.1"), newNode("node-b", true, "")), stopper)
+		defer func() { nodeLister = nil }()
+
+		service := newNodePortService(map[string]string{
+			"estafette.io/cloudflare-dns":                  "true",
+			"estafette.io/cloudflare-hostnames":            "myservice.mydomain.com",
+			"estafette.io/cloudflare-use-node-external-ip": "true",
+		})
+
+		// act
+		state := getDesiredServiceState(service)
+
+		assert.Equal(t, "", state.IPAddress)
+	})
+
+	t.Run("ReturnsEmptyIPAddressIfNodesAreNotLookedUp", func(t *testing.T) {
+
+		service := newNodePortService(map[string]string{
+			"estafette.io/cloudflare-dns":                  "true",
+			"estafette.io/cloudflare-hostnames":            "myservice.mydomain.com",
+			"estafette.io/cloudflare-use-node-external-ip": "true",
+		})
+
+		// act
+		state := getDesiredServiceState(service)
+
+		assert.Equal(t, "", state.IPAddress)
+	})
+}
+
+func TestGetDesiredIngressStateInternalHostnames(t *testing.T) {
+
+	t.Run("ReturnsInternalHostnamesAndIPAddressFromAnnotations", func(t *testing.T) {
+
+		ingress := &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myingress",
+				Namespace: "mynamespace",
+				Annotations: map[string]string{
+					"estafette.io/cloudflare-dns":                 "true",
+					"estafette.io/cloudflare-internal-hostnames":  "myingress.internal.mydomain.com",
+					"estafette.io/cloudflare-internal-ip-address": "10.0.0.5",
+				},
+			},
+		}
+
+		// act
+		state := getDesiredIngressState(ingress)
+
+		assert.Equal(t, "myingress.internal.mydomain.com", state.InternalHostnames)
+		assert.Equal(t, "10.0.0.5", state.InternalIPAddress)
+	})
+
+	t.Run("FallsBackToPrivateLoadBalancerIPAddress", func(t *testing.T) {
+
+		ingress := &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myingress",
+				Namespace: "mynamespace",
+				Annotations: map[string]string{
+					"estafette.io/cloudflare-dns":                "true",
+					"estafette.io/cloudflare-internal-hostnames": "myingress.internal.mydomain.com",
+				},
+			},
+			Status: networkingv1.IngressStatus{
+				LoadBalancer: v1.LoadBalancerStatus{
+					Ingress: []v1.LoadBalancerIngress{{IP: "35.1.2.3"}, {IP: "10.0.0.7"}},
+				},
+			},
+		}
+
+		// act
+		state := getDesiredIngressState(ingress)
+
+		assert.Equal(t, "35.1.2.3", state.IPAddress)
+		assert.Equal(t, "10.0.0.7", state.InternalIPAddress)
+	})
+
+	t.Run("ReturnsEmptyInternalIPAddressIfLoadBalancerHasNoPrivateIPAddress", func(t *testing.T) {
+
+		ingress := &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myingress",
+				Namespace: "mynamespace",
+				Annotations: map[string]string{
+					"estafette.io/cloudflare-dns":                "true",
+					"estafette.io/cloudflare-internal-hostnames": "myingress.internal.mydomain.com",
+				},
+			},
+			Status: networkingv1.IngressStatus{
+				LoadBalancer: v1.LoadBalancerStatus{
+					Ingress: []v1.LoadBalancerIngress{{IP: "35.1.2.3"}},
+				},
+			},
+		}
+
+		// act
+		state := getDesiredIngressState(ingress)
+
+		assert.Equal(t, "", state.InternalIPAddress)
+	})
+}
+
+func TestApplyJitter(t *testing.T) {
+
+	t.Run("ReturnsInputIfFractionIsZero", func(t *testing.T) {
+
+		// act
+		output := applyJitter(900, 0)
+
+		assert.Equal(t, 900, output)
+	})
+
+	t.Run("ReturnsValueWithinHalfOfInputIfFractionIsHalf", func(t *testing.T) {
+
+		for i := 0; i < 100; i++ {
+
+			// act
+			output := applyJitter(900, 0.5)
+
+			assert.GreaterOrEqual(t, output, 450)
+			assert.Less(t, output, 1350)
+		}
+	})
+}
+
+func TestRunJobs(t *testing.T) {
+
+	t.Run("RunsAllJobs", func(t *testing.T) {
+
+		var ran int32
+		jobs := []func(){}
+		for i := 0; i < 20; i++ {
+			jobs = append(jobs, func() { atomic.AddInt32(&ran, 1) })
+		}
+
+		// act
+		runJobs(jobs, 4)
+
+		assert.Equal(t, int32(20), ran)
+	})
+
+	t.Run("RunsAtMostConcurrencyJobsAtTheSameTime", func(t *testing.T) {
+
+		var running, maxRunning int32
+		jobs := []func(){}
+		for i := 0; i < 20; i++ {
+			jobs = append(jobs, func() {
+				current := atomic.AddInt32(&running, 1)
+				for {
+					max := atomic.LoadInt32(&maxRunning)
+					if current <= max || atomic.CompareAndSwapInt32(&maxRunning, max, current) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+			})
+		}
+
+		// act
+		runJobs(jobs, 3)
+
+		assert.LessOrEqual(t, maxRunning, int32(3))
+	})
+
+	t.Run("RunsJobsSequentiallyIfConcurrencyIsNotPositive", func(t *testing.T) {
+
+		order := []int{}
+		jobs := []func(){}
+		for i := 0; i < 5; i++ {
+			i := i
+			jobs = append(jobs, func() { order = append(order, i) })
+		}
+
+		// act
+		runJobs(jobs, 0)
+
+		assert.Equal(t, []int{0, 1, 2, 3, 4}, order)
+	})
+}
+
+func TestReconcileHandler(t *testing.T) {
+
+	reconcile := func() reconcileSummary {
+		return reconcileSummary{Services: 3, Ingresses: 2, Failures: 1}
+	}
+
+	t.Run("ReturnsSummaryForAuthorizedPost", func(t *testing.T) {
+
+		request := httptest.NewRequest(http.MethodPost, "/reconcile", nil)
+		request.Header.Set("Authorization", "Bearer secret")
+		recorder := httptest.NewRecorder()
+
+		// act
+		newReconcileHandler("secret", reconcile)(recorder, request)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.JSONEq(t, `{"services":3,"ingresses":2,"httpRoutes":0,"dnsRecords":0,"failures":1}`, recorder.Body.String())
+	})
+
+	t.Run("ReturnsUnauthorizedForWrongToken", func(t *testing.T) {
+
+		request := httptest.NewRequest(http.MethodPost, "/reconcile", nil)
+		request.Header.Set("Authorization", "Bearer wrong")
+		recorder := httptest.NewRecorder()
+
+		// act
+		newReconcileHandler("secret", reconcile)(recorder, request)
+
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	})
+
+	t.Run("ReturnsMethodNotAllowedForGet", func(t *testing.T) {
+
+		request := httptest.NewRequest(http.MethodGet, "/reconcile", nil)
+		request.Header.Set("Authorization", "Bearer secret")
+		recorder := httptest.NewRecorder()
+
+		// act
+		newReconcileHandler("secret", reconcile)(recorder, request)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+	})
+
+	t.Run("ReturnsConflictWhileAPassIsRunning", func(t *testing.T) {
+
+		request := httptest.NewRequest(http.MethodPost, "/reconcile", nil)
+		request.Header.Set("Authorization", "Bearer secret")
+		recorder := httptest.NewRecorder()
+
+		reconcileMutex.Lock()
+		defer reconcileMutex.Unlock()
+
+		// act
+		newReconcileHandler("secret", reconcile)(recorder, request)
+
+		assert.Equal(t, http.StatusConflict, recorder.Code)
+	})
+}
+
+func TestCountDNSRecordsTotals(t *testing.T) {
+
+	t.Run("IncrementsByNumberOfChangedRecords", func(t *testing.T) {
+
+		// act
+		countDNSRecordsTotals("changes-namespace", "succeeded", "poller", "service", 3)
+
+		assert.Equal(t, float64(3), testutil.ToFloat64(dnsRecordsTotals.With(prometheus.Labels{"namespace": "changes-namespace", "status": "succeeded", "initiator": "poller", "type": "service"})))
+	})
+
+	t.Run("DoesNotIncrementForUnchangedObjects", func(t *testing.T) {
+
+		// act
+		countDNSRecordsTotals("unchanged-namespace", "skipped", "poller", "service", 0)
+
+		assert.Equal(t, float64(0), testutil.ToFloat64(dnsRecordsTotals.With(prometheus.Labels{"namespace": "unchanged-namespace", "status": "skipped", "initiator": "poller", "type": "service"})))
+	})
+
+	t.Run("CountsFailedObjectWithoutChangesAsOneRecord", func(t *testing.T) {
+
+		// act
+		countDNSRecordsTotals("failed-namespace", "failed", "poller", "ingress", 0)
+
+		assert.Equal(t, float64(1), testutil.ToFloat64(dnsRecordsTotals.With(prometheus.Labels{"namespace": "failed-namespace", "status": "failed", "initiator": "poller", "type": "ingress"})))
+	})
+}
+
+func TestCountManagedRecords(t *testing.T) {
+
+	t.Run("CountsRecordsPerZone", func(t *testing.T) {
+
+		managedRecords := newManagedRecordsCounter()
+		state := CloudflareState{Enabled: "true", Records: []managedRecord{
+			{Name: "www.example.com", Type: "A", Content: "1.2.3.4", Zone: "example.com"},
+			{Name: "api.example.com", Type: "A", Content: "1.2.3.4", Zone: "example.com"},
+			{Name: "web.example.org", Type: "A", Content: "10.0.0.1", Zone: "example.org"},
+		}}
+
+		// act
+		countManagedRecords(state, managedRecords)
+
+		assert.Equal(t, map[string]int{"example.com": 2, "example.org": 1}, managedRecords.counts)
+	})
+
+	t.Run("SkipsDisabledObjects", func(t *testing.T) {
+
+		managedRecords := newManagedRecordsCounter()
+		state := CloudflareState{Enabled: "false", Records: []managedRecord{{Name: "www.example.com", Type: "A", Content: "1.2.3.4", Zone: "example.com"}}}
+
+		// act
+		countManagedRecords(state, managedRecords)
+
+		assert.Equal(t, 0, len(managedRecords.counts))
+	})
+
+	t.Run("SkipsRecordsWithoutZone", func(t *testing.T) {
+
+		managedRecords := newManagedRecordsCounter()
+		state := CloudflareState{Enabled: "true", Records: []managedRecord{
+			{Name: "www.example.com", Type: "A", Content: "1.2.3.4", Zone: "example.com"},
+			{Name: "www.example.org", Type: "A", Content: "1.2.3.4"},
+		}}
+
+		// act
+		countManagedRecords(state, managedRecords)
+
+		assert.Equal(t, map[string]int{"example.com": 1}, managedRecords.counts)
+	})
+}
+
+func TestSetManagedDNSRecordsGauge(t *testing.T) {
+
+	t.Run("SetsGaugePerZoneAndDropsZonesNoLongerInUse", func(t *testing.T) {
+
+		setManagedDNSRecordsGauge(&managedRecordsCounter{counts: map[string]int{"example.com": 2, "example.org": 1}})
+
+		// act
+		setManagedDNSRecordsGauge(&managedRecordsCounter{counts: map[string]int{"example.com": 3}})
+
+		assert.Equal(t, float64(3), testutil.ToFloat64(managedDNSRecords.With(prometheus.Labels{"zone": "example.com"})))
+		assert.Equal(t, 1, testutil.CollectAndCount(managedDNSRecords))
+	})
+}
+
+func TestReconcileObject(t *testing.T) {
+
+	t.Run("ReturnsResultOfReconcile", func(t *testing.T) {
+
+		// act
+		status, changes, err := reconcileObject("service", "myservice", "recover-namespace", func() (string, int, error) {
+			return "succeeded", 2, nil
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "succeeded", status)
+		assert.Equal(t, 2, changes)
+	})
+
+	t.Run("ContainsPanickingReconcile", func(t *testing.T) {
+
+		// act
+		status, _, err := reconcileObject("ingress", "myingress", "panic-namespace", func() (string, int, error) {
+			var ingress *networkingv1.Ingress
+			return ingress.Name, 0, nil
+		})
+
+		assert.NotNil(t, err)
+		assert.Equal(t, "failed", status)
+		assert.Equal(t, float64(1), testutil.ToFloat64(reconcilePanicsTotals.With(prometheus.Labels{"namespace": "panic-namespace", "type": "ingress"})))
+	})
+
+	t.Run("ContainsPanickingJobWithoutStoppingOtherJobs", func(t *testing.T) {
+
+		var succeeded int32
+		jobs := []func(){}
+		for i := 0; i < 3; i++ {
+			i := i
+			jobs = append(jobs, func() {
+				status, _, _ := reconcileObject("service", fmt.Sprintf("myservice-%v", i), "jobs-namespace", func() (string, int, error) {
+					if i == 0 {
+						panic("unexpected object shape")
+					}
+					return "succeeded", 0, nil
+				})
+				if status == "succeeded" {
+					atomic.AddInt32(&succeeded, 1)
+				}
+			})
+		}
+
+		// act
+		runJobs(jobs, 1)
+
+		assert.Equal(t, int32(2), succeeded)
+	})
+
+	t.Run("LimitsNumberOfConcurrentReconciles", func(t *testing.T) {
+
+		reconcileSlots = make(chan struct{}, 2)
+		defer func() { reconcileSlots = nil }()
+
+		var running, maxRunning int32
+		var wg sync.WaitGroup
+
+		// act
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				reconcileObject("service", fmt.Sprintf("myservice-%v", i), "slots-namespace", func() (string, int, error) {
+					current := atomic.AddInt32(&running, 1)
+					for {
+						max := atomic.LoadInt32(&maxRunning)
+						if current <= max || atomic.CompareAndSwapInt32(&maxRunning, max, current) {
+							break
+						}
+					}
+					time.Sleep(5 * time.Millisecond)
+					atomic.AddInt32(&running, -1)
+					return "succeeded", 0, nil
+				})
+			}(i)
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(2), maxRunning)
+	})
+
+	t.Run("ReleasesSlotWhenReconcilePanics", func(t *testing.T) {
+
+		reconcileSlots = make(chan struct{}, 1)
+		defer func() { reconcileSlots = nil }()
+
+		reconcileObject("service", "myservice", "slots-namespace", func() (string, int, error) {
+			panic("unexpected object shape")
+		})
+
+		// act
+		status, _, err := reconcileObject("service", "myservice", "slots-namespace", func() (string, int, error) {
+			return "succeeded", 0, nil
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, "succeeded", status)
+		assert.Equal(t, 0, len(reconcileSlots))
+	})
+}
+
+func TestGetOnceExitCode(t *testing.T) {
+
+	t.Run("ReturnsZeroIfNoObjectFailed", func(t *testing.T) {
+
+		// act
+		exitCode := getOnceExitCode(reconcileSummary{Services: 3, Ingresses: 2})
+
+		assert.Equal(t, 0, exitCode)
+	})
+
+	t.Run("ReturnsOneIfAnyObjectFailed", func(t *testing.T) {
+
+		// act
+		exitCode := getOnceExitCode(reconcileSummary{Services: 3, Ingresses: 2, Failures: 1})
+
+		assert.Equal(t, 1, exitCode)
+	})
+
+	t.Run("ReturnsZeroAfterPassOverEmptyCluster", func(t *testing.T) {
+
+		summary := reconcileAll(context.Background(), nil, fake.NewSimpleClientset(), nil, record.NewFakeRecorder(10), &sync.WaitGroup{}, "once")
+
+		// act
+		exitCode := getOnceExitCode(summary)
+
+		assert.Equal(t, 0, exitCode)
+	})
+
+	t.Run("ReturnsOneAfterPassThatFailedListingObjects", func(t *testing.T) {
+
+		kubeClientset := fake.NewSimpleClientset()
+		kubeClientset.PrependReactor("list", "services", func(action k8stesting.Action) (bool, apiruntime.Object, error) {
+			return true, nil, errors.New("Service unavailable")
+		})
+		summary := reconcileAll(context.Background(), nil, kubeClientset, nil, record.NewFakeRecorder(10), &sync.WaitGroup{}, "once")
+
+		// act
+		exitCode := getOnceExitCode(summary)
+
+		assert.Equal(t, 1, exitCode)
+	})
+}
+
+func TestReconcileAll(t *testing.T) {
+
+	t.Run("OnlyListsObjectsInConfiguredNamespace", func(t *testing.T) {
+
+		*namespace = "mynamespace"
+		*enableHTTPRoutes = true
+		defer func() {
+			*namespace = ""
+			*enableHTTPRoutes = false
+		}()
+
+		kubeClientset := fake.NewSimpleClientset(
+			&v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}},
+			&v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "othernamespace"}},
+			&networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "myingress", Namespace: "mynamespace"}},
+			&networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "myingress", Namespace: "othernamespace"}},
+		)
+
+		dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(apiruntime.NewScheme(), map[schema.GroupVersionResource]string{httpRoutesResource: "HTTPRouteList"})
+		for _, routeNamespace := range []string{"mynamespace", "othernamespace"} {
+			route := &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "gateway.networking.k8s.io/v1", "kind": "HTTPRoute", "metadata": map[string]interface{}{"name": "myroute", "namespace": routeNamespace}}}
+			if err := dynamicClient.Tracker().Create(httpRoutesResource, route, routeNamespace); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// act
+		summary := reconcileAll(context.Background(), nil, kubeClientset, dynamicClient, record.NewFakeRecorder(10), &sync.WaitGroup{}, "poller")
+
+		assert.Equal(t, 1, summary.Services)
+		assert.Equal(t, 1, summary.Ingresses)
+		assert.Equal(t, 1, summary.HTTPRoutes)
+		assert.Equal(t, 0, summary.Failures)
+
+		listActions := 0
+		for _, action := range kubeClientset.Actions() {
+			assert.Equal(t, "mynamespace", action.GetNamespace())
+			if action.GetVerb() == "list" {
+				listActions++
+			}
+		}
+		assert.Equal(t, 2, listActions)
+
+		for _, action := range dynamicClient.Actions() {
+			if action.GetVerb() == "list" {
+				assert.Equal(t, "mynamespace", action.GetNamespace())
+			}
+		}
+	})
+}
+
+func TestReconcileNamedObject(t *testing.T) {
+
+	t.Run("ProcessesServiceWithNameInNamespace", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+
+		// act
+		status, changes, err := reconcileNamedObject(ctx, nil, kubeClientset, record.NewFakeRecorder(10), "service", "myservice", "mynamespace")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "skipped", status)
+		assert.Equal(t, 0, changes)
+	})
+
+	t.Run("ProcessesIngressWithNameInNamespace", func(t *testing.T) {
+
+		ctx := context.Background()
+		ingress := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "myingress", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(ingress)
+
+		// act
+		status, changes, err := reconcileNamedObject(ctx, nil, kubeClientset, record.NewFakeRecorder(10), "ingress", "myingress", "mynamespace")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "skipped", status)
+		assert.Equal(t, 0, changes)
+	})
+
+	t.Run("ReturnsErrorIfObjectDoesNotExist", func(t *testing.T) {
+
+		ctx := context.Background()
+		kubeClientset := fake.NewSimpleClientset()
+
+		// act
+		status, _, err := reconcileNamedObject(ctx, nil, kubeClientset, record.NewFakeRecorder(10), "service", "myservice", "othernamespace")
+
+		assert.True(t, apierrors.IsNotFound(err))
+		assert.Equal(t, "failed", status)
+	})
+
+	t.Run("ReturnsErrorForUnsupportedKind", func(t *testing.T) {
+
+		ctx := context.Background()
+		kubeClientset := fake.NewSimpleClientset()
+
+		// act
+		status, _, err := reconcileNamedObject(ctx, nil, kubeClientset, record.NewFakeRecorder(10), "httproute", "myroute", "mynamespace")
+
+		assert.NotNil(t, err)
+		assert.Equal(t, "failed", status)
+	})
+}
+
+// fakeHealthInformer overrides the methods the informer health check uses
+type fakeHealthInformer struct {
+	cache.SharedIndexInformer
+	synced          bool
+	resourceVersion string
+}
+
+func (i *fakeHealthInformer) HasSynced() bool {
+	return i.synced
+}
+
+func (i *fakeHealthInformer) LastSyncResourceVersion() string {
+	return i.resourceVersion
+}
+
+func TestInformerHealth(t *testing.T) {
+
+	t.Run("IsUnhealthyUntilInformerHasSynced", func(t *testing.T) {
+
+		informer := &fakeHealthInformer{synced: false}
+		health := newInformerHealth("services", informer)
+
+		// act
+		health.updateMetric()
+
+		assert.Equal(t, float64(0), testutil.ToFloat64(informerHealthy.With(prometheus.Labels{"type": "services"})))
+
+		informer.synced = true
+
+		// act
+		health.updateMetric()
+
+		assert.Equal(t, float64(1), testutil.ToFloat64(informerHealthy.With(prometheus.Labels{"type": "services"})))
+	})
+
+	t.Run("IsUnhealthyAfterWatchErrorUntilInformerReceivesChanges", func(t *testing.T) {
+
+		informer := &fakeHealthInformer{synced: true, resourceVersion: "100"}
+		health := newInformerHealth("ingresses", informer)
+
+		// act
+		health.handleWatchError(nil, errors.New("connection refused"))
+		health.updateMetric()
+
+		assert.Equal(t, float64(0), testutil.ToFloat64(informerHealthy.With(prometheus.Labels{"type": "ingresses"})))
+
+		informer.resourceVersion = "105"
+
+		// act
+		health.updateMetric()
+
+		assert.Equal(t, float64(1), testutil.ToFloat64(informerHealthy.With(prometheus.Labels{"type": "ingresses"})))
+	})
+}
+
+func TestWaitForInformerCacheSync(t *testing.T) {
+
+	t.Run("ReturnsNilOnceInformerHasSynced", func(t *testing.T) {
+
+		// act
+		err := waitForInformerCacheSync(context.Background(), "services", &fakeHealthInformer{synced: true}, make(chan struct{}))
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("ReturnsTimeoutErrorIfInformerDoesNotSyncInTime", func(t *testing.T) {
+
+		informerCacheSyncTimeout = 10 * time.Millisecond
+		defer func() { informerCacheSyncTimeout = 5 * time.Minute }()
+
+		// act
+		err := waitForInformerCacheSync(context.Background(), "services", &fakeHealthInformer{synced: false}, make(chan struct{}))
+
+		assert.ErrorIs(t, err, errInformerCacheSyncTimeout)
+	})
+
+	t.Run("ReturnsStoppedErrorIfInformerGetsStoppedBeforeSyncing", func(t *testing.T) {
+
+		stopper := make(chan struct{})
+		close(stopper)
+
+		// act
+		err := waitForInformerCacheSync(context.Background(), "ingresses", &fakeHealthInformer{synced: false}, stopper)
+
+		assert.ErrorIs(t, err, errInformerCacheSyncStopped)
+	})
+}
+
+func TestObjectLocks(t *testing.T) {
+
+	t.Run("ReconcilesSameObjectOneAtATime", func(t *testing.T) {
+
+		locks := newObjectLocks()
+		var running, maxRunning int32
+		var wg sync.WaitGroup
+
+		// act
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				unlock := locks.lock("service", "mynamespace", "myservice")
+				defer unlock()
+
+				current := atomic.AddInt32(&running, 1)
+				if current > atomic.LoadInt32(&maxRunning) {
+					atomic.StoreInt32(&maxRunning, current)
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&running, -1)
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(1), maxRunning)
+		assert.Empty(t, locks.locks)
+	})
+
+	t.Run("DoesNotBlockOtherObjects", func(t *testing.T) {
+
+		locks := newObjectLocks()
+		unlock := locks.lock("service", "mynamespace", "myservice")
+		defer unlock()
+
+		done := make(chan struct{})
+
+		// act
+		go func() {
+			locks.lock("ingress", "mynamespace", "myservice")()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Locking another object blocked")
+		}
+	})
+}
+
+func TestGetObjectCloudflareClient(t *testing.T) {
+
+	globalClient := New(APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"})
+	globalClient.accountID = "01a7362d577a6c3019a474fd6f485823"
+	globalClient.ownershipMarker = defaultCloudflareComment
+
+	newSecretLister := func(secrets ...*v1.Secret) corelisters.SecretLister {
+		indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+		for _, secret := range secrets {
+			indexer.Add(secret)
+		}
+		return corelisters.NewSecretLister(indexer)
+	}
+	newSecret := func(name, namespace, email, key string) *v1.Secret {
+		return &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Data:       map[string][]byte{"cloudflareApiEmail": []byte(email), "cloudflareApiKey": []byte(key)},
+		}
+	}
+
+	t.Run("ReturnsGlobalClientIfLookingUpSecretsIsDisabled", func(t *testing.T) {
+
+		// act
+		client, err := getObjectCloudflareClient(globalClient, nil, map[string]string{annotationCloudflareCredentialsSecret: "tenant-credentials"}, "Service", "myservice", "tenant-a")
+
+		assert.Nil(t, err)
+		assert.Same(t, globalClient, client)
+	})
+
+	t.Run("ReturnsGlobalClientIfObjectHasNoAnnotationAndNamespaceSecretIsNotSet", func(t *testing.T) {
+
+		lister := newSecretLister(newSecret("tenant-credentials", "tenant-a", "tenant-a@server.com", "tenantakey"))
+
+		// act
+		client, err := getObjectCloudflareClient(globalClient, lister, map[string]string{}, "Service", "myservice", "tenant-a")
+
+		assert.Nil(t, err)
+		assert.Same(t, globalClient, client)
+	})
+
+	t.Run("ReturnsClientWithCredentialsOfNamespaceSecretIfObjectHasNoAnnotation", func(t *testing.T) {
+
+		*namespaceCredentialsSecret = "cloudflare-credentials"
+		defer func() { *namespaceCredentialsSecret = "" }()
+		lister := newSecretLister(newSecret("cloudflare-credentials", "tenant-b", "tenant-b@server.com", "tenantbkey"))
+
+		// act
+		client, err := getObjectCloudflareClient(globalClient, lister, map[string]string{}, "Ingress", "myingress", "tenant-b")
+
+		assert.Nil(t, err)
+		assert.Equal(t, APIAuthentication{Key: "tenantbkey", Email: "tenant-b@server.com"}, client.authentication)
+		assert.Equal(t, "", client.accountID)
+		assert.Equal(t, defaultCloudflareComment, client.ownershipMarker)
+	})
+
+	t.Run("ReturnsClientWithCredentialsOfAnnotatedSecretOverNamespaceSecret", func(t *testing.T) {
+
+		*namespaceCredentialsSecret = "cloudflare-credentials"
+		defer func() { *namespaceCredentialsSecret = "" }()
+		lister := newSecretLister(
+			newSecret("cloudflare-credentials", "tenant-c", "tenant-c@server.com", "tenantckey"),
+			newSecret("team-credentials", "tenant-c", "team@server.com", "teamkey"),
+		)
+
+		// act
+		client, err := getObjectCloudflareClient(globalClient, lister, map[string]string{annotationCloudflareCredentialsSecret: "team-credentials"}, "Service", "myservice", "tenant-c")
+
+		assert.Nil(t, err)
+		assert.Equal(t, APIAuthentication{Key: "teamkey", Email: "team@server.com"}, client.authentication)
+	})
+
+	t.Run("ReturnsGlobalClientIfNamespaceSecretDoesNotExist", func(t *testing.T) {
+
+		*namespaceCredentialsSecret = "cloudflare-credentials"
+		defer func() { *namespaceCredentialsSecret = "" }()
+		lister := newSecretLister(newSecret("cloudflare-credentials", "tenant-d", "tenant-d@server.com", "tenantdkey"))
+
+		// act
+		client, err := getObjectCloudflareClient(globalClient, lister, map[string]string{}, "Service", "myservice", "other-namespace")
+
+		assert.Nil(t, err)
+		assert.Same(t, globalClient, client)
+	})
+
+	t.Run("ReturnsErrorIfAnnotatedSecretDoesNotExist", func(t *testing.T) {
+
+		lister := newSecretLister(newSecret("team-credentials", "other-namespace", "team@server.com", "teamkey"))
+
+		// act
+		_, err := getObjectCloudflareClient(globalClient, lister, map[string]string{annotationCloudflareCredentialsSecret: "team-credentials"}, "Service", "myservice", "tenant-e")
+
+		assert.ErrorIs(t, err, errCredentialsSecretNotFound)
+	})
+
+	t.Run("ReturnsErrorIfSecretLacksTheApiKey", func(t *testing.T) {
+
+		lister := newSecretLister(newSecret("team-credentials", "tenant-f", "team@server.com", ""))
+
+		// act
+		_, err := getObjectCloudflareClient(globalClient, lister, map[string]string{annotationCloudflareCredentialsSecret: "team-credentials"}, "Service", "myservice", "tenant-f")
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsSameClientForSameCredentials", func(t *testing.T) {
+
+		lister := newSecretLister(
+			newSecret("team-credentials", "tenant-g", "tenant-g@server.com", "tenantgkey"),
+			newSecret("team-credentials", "tenant-h", "tenant-g@server.com", "tenantgkey"),
+		)
+
+		// act
+		client, err := getObjectCloudflareClient(globalClient, lister, map[string]string{annotationCloudflareCredentialsSecret: "team-credentials"}, "Service", "myservice", "tenant-g")
+		otherClient, otherErr := getObjectCloudflareClient(globalClient, lister, map[string]string{annotationCloudflareCredentialsSecret: "team-credentials"}, "Ingress", "myingress", "tenant-h")
+
+		assert.Nil(t, err)
+		assert.Nil(t, otherErr)
+		assert.Same(t, client, otherClient)
+	})
+}
+
+func TestObjectQueue(t *testing.T) {
+
+	service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "queue-namespace"}}
+
+	t.Run("MergesEventsForQueuedObject", func(t *testing.T) {
+
+		indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+		indexer.Add(service)
+		reconciled := 0
+		q := newObjectQueue("service", indexer,
+			func(obj interface{}) (string, int, error) {
+				reconciled++
+				return "succeeded", 0, nil
+			},
+			func(obj interface{}) (string, int, error) {
+				return "deleted", 0, nil
+			},
+		)
+		q.handlers().OnAdd(service)
+		q.handlers().OnUpdate(service, service)
+
+		// act
+		q.processNextItem(&sync.WaitGroup{})
+
+		assert.Equal(t, 1, reconciled)
+		assert.Equal(t, 0, q.queue.Len())
+	})
+
+	t.Run("DeletesObjectThatIsNoLongerInCache", func(t *testing.T) {
+
+		indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+		var deletedObj interface{}
+		reconciled := 0
+		q := newObjectQueue("service", indexer,
+			func(obj interface{}) (string, int, error) {
+				reconciled++
+				return "succeeded", 0, nil
+			},
+			func(obj interface{}) (string, int, error) {
+				deletedObj = obj
+				return "deleted", 0, nil
+			},
+		)
+		q.handlers().OnDelete(cache.DeletedFinalStateUnknown{Key: "queue-namespace/myservice", Obj: service})
+
+		// act
+		q.processNextItem(&sync.WaitGroup{})
+
+		assert.Equal(t, service, deletedObj)
+		assert.Equal(t, 0, reconciled)
+		_, pending := q.deletedObjects.Load("queue-namespace/myservice")
+		assert.False(t, pending)
+	})
+
+	t.Run("RequeuesFailedReconcileWithBackoff", func(t *testing.T) {
+
+		indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+		indexer.Add(service)
+		q := newObjectQueue("service", indexer,
+			func(obj interface{}) (string, int, error) {
+				return "failed", 0, errors.New("upserting failed")
+			},
+			func(obj interface{}) (string, int, error) {
+				return "deleted", 0, nil
+			},
+		)
+		q.handlers().OnAdd(service)
+
+		// act
+		q.processNextItem(&sync.WaitGroup{})
+
+		assert.Equal(t, 1, q.queue.NumRequeues("queue-namespace/myservice"))
+	})
+
+	t.Run("RequeuesRateLimitedReconcileAfterRetryAfterWithoutCountingRetry", func(t *testing.T) {
+
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if requests == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				w.Write([]byte(`{"success": false}`))
+				return
+			}
+			w.Write([]byte(`{"success": true}`))
+		}))
+		defer server.Close()
+
+		client := newRealRESTClient("", nil)
+		indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+		indexer.Add(service)
+		reconciled := 0
+		q := newObjectQueue("service", indexer,
+			func(obj interface{}) (string, int, error) {
+				_, err := client.Get(server.URL+"/zones", APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"})
+				if err != nil {
+					return "failed", 0, err
+				}
+				reconciled++
+				return "succeeded", 1, nil
+			},
+			func(obj interface{}) (string, int, error) {
+				return "deleted", 0, nil
+			},
+		)
+		q.handlers().OnAdd(service)
+
+		// act
+		q.processNextItem(&sync.WaitGroup{})
+		q.processNextItem(&sync.WaitGroup{})
+
+		assert.Equal(t, 2, requests)
+		assert.Equal(t, 1, reconciled)
+		assert.Equal(t, 0, q.queue.NumRequeues("queue-namespace/myservice"))
+		assert.Equal(t, 0, q.queue.Len())
+	})
+
+	t.Run("KeepsRetryingFailedDeleteBeyondMaxRetries", func(t *testing.T) {
+
+		indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+		q := newObjectQueue("service", indexer,
+			func(obj interface{}) (string, int, error) {
+				return "succeeded", 0, nil
+			},
+			func(obj interface{}) (string, int, error) {
+				return "failed", 0, errors.New("deleting failed")
+			},
+		)
+		for i := 0; i < maxReconcileRetries; i++ {
+			q.queue.AddRateLimited("queue-namespace/myservice")
+		}
+		q.handlers().OnDelete(service)
+
+		// act
+		q.processNextItem(&sync.WaitGroup{})
+
+		assert.Equal(t, maxReconcileRetries+1, q.queue.NumRequeues("queue-namespace/myservice"))
+		_, pending := q.deletedObjects.Load("queue-namespace/myservice")
+		assert.True(t, pending)
+	})
+
+	t.Run("ReturnsFalseOnceQueueIsShutDown", func(t *testing.T) {
+
+		indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+		q := newObjectQueue("service", indexer, nil, nil)
+		q.queue.ShutDown()
+
+		// act
+		more := q.processNextItem(&sync.WaitGroup{})
+
+		assert.False(t, more)
+	})
+}
+
+func TestAdmissionHandler(t *testing.T) {
+
+	newReviewRequest := func(kind string, obj interface{}) *http.Request {
+		raw, _ := json.Marshal(obj)
+		body, _ := json.Marshal(admissionv1.AdmissionReview{
+			TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+			Request: &admissionv1.AdmissionRequest{
+				UID:       "705ab4f5-6393-11e8-b7cc-42010a800002",
+				Kind:      metav1.GroupVersionKind{Version: "v1", Kind: kind},
+				Name:      "myobject",
+				Namespace: "mynamespace",
+				Operation: admissionv1.Create,
+				Object:    apiruntime.RawExtension{Raw: raw},
+			},
+		})
+		return httptest.NewRequest(http.MethodPost, "/validate", strings.NewReader(string(body)))
+	}
+
+	t.Run("AllowsServiceWithValidAnnotations", func(t *testing.T) {
+
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace", Annotations: map[string]string{
+			"estafette.io/cloudflare-dns":         "true",
+			"estafette.io/cloudflare-hostnames":   "{service}.example.com,www.example.com",
+			"estafette.io/cloudflare-proxy":       "auto",
+			"estafette.io/cloudflare-ttl":         "1",
+			"estafette.io/cloudflare-srv-records": "_sip._tcp.example.com 10 5 5060 sip.example.com",
+			"kubernetes.io/description":           "not validated",
+		}}}
+		recorder := httptest.NewRecorder()
+
+		// act
+		newAdmissionHandler()(recorder, newReviewRequest("Service", service))
+
+		var review admissionv1.AdmissionReview
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &review))
+		if assert.NotNil(t, review.Response) {
+			assert.True(t, review.Response.Allowed)
+			assert.Equal(t, "705ab4f5-6393-11e8-b7cc-42010a800002", string(review.Response.UID))
+		}
+	})
+
+	t.Run("RejectsIngressWithInvalidHostname", func(t *testing.T) {
+
+		ingress := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "myingress", Namespace: "mynamespace", Annotations: map[string]string{
+			"estafette.io/cloudflare-dns":       "true",
+			"estafette.io/cloudflare-hostnames": "my_app!.example.com",
+		}}}
+		recorder := httptest.NewRecorder()
+
+		// act
+		newAdmissionHandler()(recorder, newReviewRequest("Ingress", ingress))
+
+		var review admissionv1.AdmissionReview
+		assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &review))
+		if assert.NotNil(t, review.Response) {
+			assert.False(t, review.Response.Allowed)
+			assert.Equal(t, int32(http.StatusUnprocessableEntity), review.Response.Result.Code)
+			assert.Contains(t, review.Response.Result.Message, "invalid hostname 'my_app!.example.com' (invalid-characters)")
+		}
+	})
+
+	t.Run("AllowsObjectsOfOtherKinds", func(t *testing.T) {
+
+		configMap := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "myconfigmap", Namespace: "mynamespace", Annotations: map[string]string{
+			"estafette.io/cloudflare-ttl": "soon",
+		}}}
+		recorder := httptest.NewRecorder()
+
+		// act
+		newAdmissionHandler()(recorder, newReviewRequest("ConfigMap", configMap))
+
+		var review admissionv1.AdmissionReview
+		assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &review))
+		if assert.NotNil(t, review.Response) {
+			assert.True(t, review.Response.Allowed)
+		}
+	})
+
+	t.Run("ReturnsBadRequestForReviewWithoutRequest", func(t *testing.T) {
+
+		request := httptest.NewRequest(http.MethodPost, "/validate", strings.NewReader(`{"apiVersion": "admission.k8s.io/v1", "kind": "AdmissionReview"}`))
+		recorder := httptest.NewRecorder()
+
+		// act
+		newAdmissionHandler()(recorder, request)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("ReturnsMethodNotAllowedForGet", func(t *testing.T) {
+
+		request := httptest.NewRequest(http.MethodGet, "/validate", nil)
+		recorder := httptest.NewRecorder()
+
+		// act
+		newAdmissionHandler()(recorder, request)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+	})
+}
+
+func TestGetAnnotationProblems(t *testing.T) {
+
+	placeholders := map[string]string{"service": "myservice", "namespace": "mynamespace", "domain": ""}
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		problem     string
+	}{
+		{"MisspelledAnnotation", map[string]string{"estafette.io/cloudflare-hostname": "www.example.com"}, "estafette.io/cloudflare-hostname is not a known annotation"},
+		{"UnrecognizedBoolean", map[string]string{"estafette.io/cloudflare-dns": "yes"}, "estafette.io/cloudflare-dns has value 'yes'"},
+		{"UnrecognizedProxy", map[string]string{"estafette.io/cloudflare-proxy": "sometimes"}, "expected true, false, 1, 0 or auto"},
+		{"NegativeProxyAfter", map[string]string{"estafette.io/cloudflare-proxy-after": "-5m"}, "expected a positive duration"},
+		{"NonNumericTTL", map[string]string{"estafette.io/cloudflare-ttl": "5 minutes"}, "expected a number of seconds"},
+		{"TTLConflictingWithProxy", map[string]string{"estafette.io/cloudflare-proxy": "true", "estafette.io/cloudflare-ttl": "300"}, "conflicts with estafette.io/cloudflare-proxy"},
+		{"UnknownSSLMode", map[string]string{"estafette.io/cloudflare-ssl-mode": "paranoid"}, "expected off, flexible, full or strict"},
+		{"InvalidInternalIPAddress", map[string]string{"estafette.io/cloudflare-internal-ip-address": "10.0.0"}, "estafette.io/cloudflare-internal-ip-address is invalid"},
+		{"HostnameWithUnresolvedPlaceholder", map[string]string{"estafette.io/cloudflare-hostnames": "{service}.{domain}"}, "with placeholders without a value"},
+		{"InvalidRecordName", map[string]string{"estafette.io/cloudflare-record-name": "www.example.com=cdn"}, "invalid record name 'cdn' (too-few-parts)"},
+		{"MalformedSRVRecord", map[string]string{"estafette.io/cloudflare-srv-records": "_sip._tcp.example.com 10 5 sip.example.com"}, "Srv record"},
+		{"MalformedCAARecord", map[string]string{"estafette.io/cloudflare-caa-records": "example.com 0 issuer letsencrypt.org"}, "Caa record"},
+		{"MalformedLOCRecord", map[string]string{"estafette.io/cloudflare-loc-records": "example.com 52 22 23 N"}, "Loc record"},
+		{"MalformedNSRecord", map[string]string{"estafette.io/cloudflare-ns-records": "sub.example.com="}, "Ns record"},
+		{"MalformedZoneSetting", map[string]string{"estafette.io/cloudflare-zone-settings": "always_use_https"}, "Zone setting"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// act
+			problems := getAnnotationProblems(tt.annotations, placeholders)
+
+			if assert.Equal(t, 1, len(problems)) {
+				assert.Contains(t, problems[0], tt.problem)
+			}
+		})
+	}
+
+	t.Run("ReturnsNoProblemsForValidAnnotations", func(t *testing.T) {
+
+		annotations := map[string]string{
+			"estafette.io/cloudflare-dns":                    "true",
+			"estafette.io/cloudflare-hostnames":              "{service}.example.com,bücher.example.com",
+			"estafette.io/cloudflare-internal-hostnames":     "{service}.{namespace}.internal.example.com",
+			"estafette.io/cloudflare-proxy":                  "false",
+			"estafette.io/cloudflare-ttl":                    "300",
+			"estafette.io/cloudflare-use-origin-record":      "1",
+			"estafette.io/cloudflare-origin-record-hostname": "origin.example.com",
+			"estafette.io/cloudflare-record-name":            "cdn.example.com",
+			"estafette.io/cloudflare-caa-records":            "example.com 0 issue letsencrypt.org",
+			"estafette.io/cloudflare-state":                  `{"enabled":"true"}`,
+		}
+
+		// act
+		problems := getAnnotationProblems(annotations, placeholders)
+
+		assert.Equal(t, 0, len(problems))
+	})
+}
+
+func TestValidateHostname(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		hostname string
+		reason   string
+	}{
+		{"ValidHostname", "www.mydomain.com", ""},
+		{"ValidHostnameWithHyphensAndDigits", "my-app-1.my-domain.com", ""},
+		{"ValidWildcard", "*.mydomain.com", ""},
+		{"ValidUnderscorePrefix", "_dmarc.mydomain.com", ""},
+		{"ValidSRVName", "_sip._tcp.mydomain.com", ""},
+		{"TooFewParts", "localhost", "too-few-parts"},
+		{"LabelTooLong", strings.Repeat("a", 64) + ".mydomain.com", "label-too-long"},
+		{"WildcardNotLeftmost", "www.*.mydomain.com", "invalid-characters"},
+		{"PartialWildcard", "www*.mydomain.com", "invalid-characters"},
+		{"UnderscoreInsideLabel", "my_app.mydomain.com", "invalid-characters"},
+		{"Space", "my app.mydomain.com", "invalid-characters"},
+		{"LeadingHyphen", "-www.mydomain.com", "invalid-characters"},
+		{"TrailingHyphen", "www-.mydomain.com", "invalid-characters"},
+		{"EmptyLabel", "www..mydomain.com", "invalid-characters"},
+		{"OnlyUnderscore", "_.mydomain.com", "invalid-characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// act
+			reason := validateHostname(tt.hostname)
+
+			assert.Equal(t, tt.reason, reason)
+		})
+	}
+}
+
+func TestNormalizeIPAddress(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		address    string
+		normalized string
+		valid      bool
+	}{
+		{"IPv4", "1.2.3.4", "1.2.3.4", true},
+		{"IPv4WithPort", "1.2.3.4:443", "1.2.3.4", true},
+		{"IPv4WithWhitespace", " 1.2.3.4 ", "1.2.3.4", true},
+		{"IPv6", "2001:db8::1", "2001:db8::1", true},
+		{"IPv6NotCanonical", "2001:DB8:0:0::1", "2001:db8::1", true},
+		{"IPv6WithZoneID", "fe80::1%eth0", "fe80::1", true},
+		{"IPv6WithBrackets", "[2001:db8::1]", "2001:db8::1", true},
+		{"IPv6WithBracketsAndPort", "[2001:db8::1]:443", "2001:db8::1", true},
+		{"Hostname", "abc.elb.us-east-1.amazonaws.com", "", false},
+		{"IncompleteIPv4", "1.2.3", "", false},
+		{"OutOfRangeIPv4", "1.2.3.256", "", false},
+		{"Empty", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// act
+			normalized, err := normalizeIPAddress(tt.address)
+
+			assert.Equal(t, tt.valid, err == nil)
+			assert.Equal(t, tt.normalized, normalized)
+		})
+	}
+}
+
+func TestNormalizeRecordTargets(t *testing.T) {
+
+	t.Run("NormalizesAllLoadBalancerIPAddresses", func(t *testing.T) {
+
+		state := CloudflareState{IPAddress: "1.2.3.4:80", AdditionalIPAddresses: "[2001:db8::1],5.6.7.8"}
+
+		// act
+		normalizedState, err := normalizeRecordTargets(state)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "1.2.3.4", normalizedState.IPAddress)
+		assert.Equal(t, "2001:db8::1,5.6.7.8", normalizedState.AdditionalIPAddresses)
+	})
+
+	t.Run("ReturnsErrorForMalformedIPAddress", func(t *testing.T) {
+
+		state := CloudflareState{IPAddress: "1.2.3.4.5"}
+
+		// act
+		_, err := normalizeRecordTargets(state)
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorForMalformedAdditionalIPAddress", func(t *testing.T) {
+
+		state := CloudflareState{IPAddress: "1.2.3.4", AdditionalIPAddresses: "not-an-ip"}
+
+		// act
+		_, err := normalizeRecordTargets(state)
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ValidatesLoadBalancerHostnameAsHostname", func(t *testing.T) {
+
+		state := CloudflareState{IPAddress: "abc.elb.us-east-1.amazonaws.com", TargetIsHostname: "true"}
+
+		// act
+		normalizedState, err := normalizeRecordTargets(state)
+
+		assert.Nil(t, err)
+		assert.Equal(t, state, normalizedState)
+	})
+
+	t.Run("AcceptsCnameTargetWithTrailingDot", func(t *testing.T) {
+
+		state := CloudflareState{CNAMETarget: "cdn.provider.com.", IPAddress: "1.2.3.4"}
+
+		// act
+		_, err := normalizeRecordTargets(state)
+
+		assert.Nil(t, err)
+	})
+
+	t.Run("ReturnsErrorForMalformedCnameTarget", func(t *testing.T) {
+
+		state := CloudflareState{CNAMETarget: "cdn provider.com:443", IPAddress: "1.2.3.4"}
+
+		// act
+		_, err := normalizeRecordTargets(state)
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestGetStateDiff(t *testing.T) {
+
+	t.Run("ReturnsNoDifferencesForEqualStates", func(t *testing.T) {
+
+		state := CloudflareState{Enabled: "true", Hostnames: "www.mydomain.com", IPAddress: "1.2.3.4"}
+
+		// act
+		diff := getStateDiff(state, state)
+
+		assert.Empty(t, diff)
+	})
+
+	t.Run("ReturnsChangedFieldsWithCurrentAndDesiredValues", func(t *testing.T) {
+
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.mydomain.com", Proxy: "true", IPAddress: "5.6.7.8"}
+		currentState := CloudflareState{Enabled: "true", Hostnames: "www.mydomain.com", Proxy: "false", IPAddress: "1.2.3.4"}
+
+		// act
+		diff := getStateDiff(desiredState, currentState)
+
+		assert.Equal(t, []string{"Proxy: 'false' -> 'true'", "IPAddress: '1.2.3.4' -> '5.6.7.8'"}, diff)
+	})
+	t.Run("ComparesRecordsByValue", func(t *testing.T) {
+
+		desiredState := CloudflareState{Enabled: "true", Records: []managedRecord{{Name: "www.mydomain.com", Type: "A", Content: "1.2.3.4", TTL: 1}}}
+		currentState := CloudflareState{Enabled: "true", Records: []managedRecord{{Name: "www.mydomain.com", Type: "A", Content: "1.2.3.4", TTL: 1}}}
+
+		// act
+		diff := getStateDiff(desiredState, currentState)
+
+		assert.Empty(t, diff)
+	})
+}
+
+func TestGetStateAnnotationPatch(t *testing.T) {
+
+	t.Run("ReturnsMergePatchTouchingOnlyStateAnnotation", func(t *testing.T) {
+
+		state := CloudflareState{Enabled: "true", Hostnames: "www.mydomain.com", IPAddress: "1.2.3.4"}
+
+		// act
+		patch, err := getStateAnnotationPatch(&state)
+
+		assert.Nil(t, err)
+
+		var payload map[string]map[string]map[string]*string
+		err = json.Unmarshal(patch, &payload)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(payload))
+		assert.Equal(t, 1, len(payload["metadata"]))
+		assert.Equal(t, 1, len(payload["metadata"]["annotations"]))
+		if assert.NotNil(t, payload["metadata"]["annotations"][annotationCloudflareState]) {
+			var patchedState CloudflareState
+			err = json.Unmarshal([]byte(*payload["metadata"]["annotations"][annotationCloudflareState]), &patchedState)
+			assert.Nil(t, err)
+			assert.Equal(t, state, patchedState)
+		}
+	})
+
+	t.Run("ReturnsNullAnnotationValueWhenStateIsNil", func(t *testing.T) {
+
+		// act
+		patch, err := getStateAnnotationPatch(nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, `{"metadata":{"annotations":{"estafette.io/cloudflare-state":null}}}`, string(patch))
+	})
+}
+
+func TestEncodeStateAnnotation(t *testing.T) {
+
+	t.Run("ReturnsPlainJSONForSmallState", func(t *testing.T) {
+
+		state := CloudflareState{Enabled: "true", Hostnames: "www.mydomain.com", IPAddress: "1.2.3.4"}
+
+		// act
+		encodedState, err := encodeStateAnnotation(state)
+
+		assert.Nil(t, err)
+		assert.Equal(t, `{"enabled":"true","hostnames":"www.mydomain.com","proxy":"","useOriginRecord":"","originRecordHostname":"","ipAddress":"1.2.3.4"}`, encodedState)
+	})
+
+	t.Run("ReturnsCompressedStateForOversizedStateThatDecodesToTheSameState", func(t *testing.T) {
+
+		hostnames := []string{}
+		records := []managedRecord{}
+		for i := 0; i < 1000; i++ {
+			hostname := fmt.Sprintf("host-%v.mydomain.com", i)
+			hostnames = append(hostnames, hostname)
+			records = append(records, managedRecord{Name: hostname, Type: "A", Content: "1.2.3.4", Proxied: true, TTL: 1, Zone: "mydomain.com"})
+		}
+		state := CloudflareState{Enabled: "true", Hostnames: strings.Join(hostnames, ","), IPAddress: "1.2.3.4", Records: records}
+
+		// act
+		encodedState, err := encodeStateAnnotation(state)
+
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(encodedState, compressedStatePrefix))
+		assert.Less(t, len(encodedState), maxUncompressedStateSize)
+
+		var decodedState CloudflareState
+		err = decodeStateAnnotation(encodedState, &decodedState)
+		assert.Nil(t, err)
+		assert.Equal(t, state, decodedState)
+	})
+}
+
+func TestDecodeStateAnnotation(t *testing.T) {
+
+	t.Run("DecodesPlainJSONState", func(t *testing.T) {
+
+		var state CloudflareState
+
+		// act
+		err := decodeStateAnnotation(`{"enabled":"true","hostnames":"www.mydomain.com"}`, &state)
+
+		assert.Nil(t, err)
+		assert.Equal(t, CloudflareState{Enabled: "true", Hostnames: "www.mydomain.com"}, state)
+	})
+
+	t.Run("ReturnsErrorForCorruptCompressedState", func(t *testing.T) {
+
+		var state CloudflareState
+
+		// act
+		err := decodeStateAnnotation(compressedStatePrefix+"bm90IGd6aXA=", &state)
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestGetCurrentStateDecodeFailures(t *testing.T) {
+
+	t.Run("CountsServiceStateThatFailsToDeserialize", func(t *testing.T) {
+
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "decode-failure-namespace", Annotations: map[string]string{annotationCloudflareState: `{"enabled":`}}}
+
+		// act
+		state := getCurrentServiceState(context.Background(), service)
+
+		assert.Equal(t, CloudflareState{}, state)
+		assert.Equal(t, float64(1), testutil.ToFloat64(stateDecodeFailuresTotals.With(prometheus.Labels{"namespace": "decode-failure-namespace", "type": "service"})))
+	})
+
+	t.Run("CountsIngressStateThatFailsToDeserialize", func(t *testing.T) {
+
+		ingress := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "myingress", Namespace: "decode-failure-namespace", Annotations: map[string]string{annotationCloudflareState: compressedStatePrefix + "bm90IGd6aXA="}}}
+
+		// act
+		state := getCurrentIngressState(context.Background(), ingress)
+
+		assert.Equal(t, CloudflareState{}, state)
+		assert.Equal(t, float64(1), testutil.ToFloat64(stateDecodeFailuresTotals.With(prometheus.Labels{"namespace": "decode-failure-namespace", "type": "ingress"})))
+	})
+
+	t.Run("DoesNotCountValidState", func(t *testing.T) {
+
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "decode-success-namespace", Annotations: map[string]string{annotationCloudflareState: `{"enabled":"true"}`}}}
+
+		// act
+		state := getCurrentServiceState(context.Background(), service)
+
+		assert.Equal(t, "true", state.Enabled)
+		assert.Equal(t, float64(0), testutil.ToFloat64(stateDecodeFailuresTotals.With(prometheus.Labels{"namespace": "decode-success-namespace", "type": "service"})))
+	})
+}
+
+func TestIsAnnotationsTooLargeError(t *testing.T) {
+
+	t.Run("ReturnsTrueIfAnnotationsExceedTheirSizeLimit", func(t *testing.T) {
+
+		err := apierrors.NewInvalid(schema.GroupKind{Kind: "Service"}, "myservice", field.ErrorList{field.TooLong(field.NewPath("metadata", "annotations"), "", apivalidation.TotalAnnotationSizeLimitB)})
+
+		// act
+		tooLarge := isAnnotationsTooLargeError(err)
+
+		assert.True(t, tooLarge)
+	})
+
+	t.Run("ReturnsFalseForOtherInvalidErrors", func(t *testing.T) {
+
+		err := apierrors.NewInvalid(schema.GroupKind{Kind: "Service"}, "myservice", field.ErrorList{field.Invalid(field.NewPath("metadata", "annotations"), "", "invalid key")})
+
+		// act
+		tooLarge := isAnnotationsTooLargeError(err)
+
+		assert.False(t, tooLarge)
+	})
+
+	t.Run("ReturnsFalseForNil", func(t *testing.T) {
+
+		// act
+		tooLarge := isAnnotationsTooLargeError(nil)
+
+		assert.False(t, tooLarge)
+	})
+}
+
+func TestMakeServiceChanges(t *testing.T) {
+
+	t.Run("RemovesStateAnnotationFromServiceInItsOwnNamespaceWhenDnsIsDisabled", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myservice",
+				Namespace: "mynamespace",
+				Annotations: map[string]string{
+					annotationCloudflareState: `{"enabled":"true"}`,
+				},
+			},
+		}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "false"}
+		currentState := CloudflareState{Enabled: "true"}
+
+		// act
+		status, _, err := makeServiceChanges(ctx, nil, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, currentState, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "deleted", status)
+
+		patchActions := 0
+		for _, action := range kubeClientset.Actions() {
+			if action.GetVerb() == "patch" {
+				patchActions++
+				assert.Equal(t, "mynamespace", action.GetNamespace())
+			}
+		}
+		assert.Equal(t, 1, patchActions)
+
+		patchedService, err := kubeClientset.CoreV1().Services("mynamespace").Get(ctx, "myservice", metav1.GetOptions{})
+		assert.Nil(t, err)
+		assert.NotContains(t, patchedService.Annotations, annotationCloudflareState)
+	})
+
+	authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+	zonesResult := []byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com"}], "result_info": {"per_page": 20, "count": 1}}`)
+	noZonesResult := []byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`)
+	noDNSRecordsResult := []byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`)
+	getDNSRecordResult := func(dnsRecordType, dnsRecordName, dnsRecordContent string, proxied bool) []byte {
+		return []byte(fmt.Sprintf(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "%v", "name": "%v", "content": "%v", "proxiable": true, "proxied": %v, "ttl": 1, "zone_id": "023e105f4ecef8ad9ca31a8372d0c353", "zone_name": "example.com"}], "result_info": {"per_page": 20, "count": 1}}`, dnsRecordType, dnsRecordName, dnsRecordContent, proxied))
+	}
+	isDNSRecord := func(dnsRecordType, dnsRecordName, dnsRecordContent string, proxied bool) interface{} {
+		return mock.MatchedBy(func(r DNSRecord) bool {
+			return r.Type == dnsRecordType && r.Name == dnsRecordName && r.Content == dnsRecordContent && r.Proxied == proxied
+		})
+	}
+
+	t.Run("CreatesProxiedCnameRecordToLoadBalancerHostname", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "true", UseOriginRecord: "false", IPAddress: "abc.elb.us-east-1.amazonaws.com", TargetIsHostname: "true"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=CNAME", authentication).Return(noDNSRecordsResult, nil).Once()
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=CNAME", authentication).Return(getDNSRecordResult("CNAME", "www.example.com", "abc.elb.us-east-1.amazonaws.com", true), nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+
+		recorder := record.NewFakeRecorder(10)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		status, changes, err := makeServiceChanges(ctx, cf, kubeClientset, recorder, service, "test", desiredState, CloudflareState{}, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "succeeded", status)
+		assert.Equal(t, 1, changes)
+		fakeRESTClient.AssertCalled(t, "Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", isDNSRecord("CNAME", "www.example.com", "abc.elb.us-east-1.amazonaws.com", true), authentication)
+		fakeRESTClient.AssertNumberOfCalls(t, "Post", 1)
+		fakeRESTClient.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything)
+		if assert.NotEmpty(t, recorder.Events) {
+			assert.Equal(t, "Normal DNSRecordUpserted Upserted dns record www.example.com (CNAME) to abc.elb.us-east-1.amazonaws.com in zone example.com", <-recorder.Events)
+		}
+	})
+
+	t.Run("LeavesDnsRecordWithoutOwnershipMarkerAloneAndDoesNotStoreIt", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "false", UseOriginRecord: "false", IPAddress: "1.2.3.4"}
+		unownedDNSRecordsResult := []byte(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "www.example.com", "content": "5.6.7.8", "comment": "created by hand", "ttl": 1, "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}], "result_info": {"per_page": 20, "count": 1}}`)
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(unownedDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(unownedDNSRecordsResult, nil)
+
+		recorder := record.NewFakeRecorder(10)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+		cf.ownershipMarker = defaultCloudflareComment
+
+		// act
+		status, changes, err := makeServiceChanges(ctx, cf, kubeClientset, recorder, service, "test", desiredState, CloudflareState{}, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "succeeded", status)
+		assert.Equal(t, 0, changes)
+		assert.Empty(t, recorder.Events)
+		fakeRESTClient.AssertNotCalled(t, "Post", mock.Anything, mock.Anything, mock.Anything)
+		fakeRESTClient.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything)
+		fakeRESTClient.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything, mock.Anything)
+
+		patchedService, err := kubeClientset.CoreV1().Services("mynamespace").Get(ctx, "myservice", metav1.GetOptions{})
+		assert.Nil(t, err)
+		var storedState CloudflareState
+		err = decodeStateAnnotation(patchedService.Annotations[annotationCloudflareState], &storedState)
+		assert.Nil(t, err)
+		assert.Empty(t, storedState.Records)
+	})
+
+	t.Run("DoesNotCountUpToDateDnsRecordAsChange", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "false", UseOriginRecord: "false", IPAddress: "1.2.3.4"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(getDNSRecordResult("A", "www.example.com", "1.2.3.4", false), nil)
+
+		recorder := record.NewFakeRecorder(10)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		status, changes, err := makeServiceChanges(ctx, cf, kubeClientset, recorder, service, "test", desiredState, CloudflareState{}, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "succeeded", status)
+		assert.Equal(t, 0, changes)
+		close(recorder.Events)
+		for event := range recorder.Events {
+			assert.NotContains(t, event, "DNSRecordUpserted")
+		}
+		fakeRESTClient.AssertNotCalled(t, "Post", mock.Anything, mock.Anything, mock.Anything)
+		fakeRESTClient.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("EmitsWarningEventWithHostnameAndTypeWhenUpsertingRecordFails", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "false", UseOriginRecord: "false", IPAddress: "1.2.3.4"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return([]byte(nil), errors.New("Service unavailable"))
+		recorder := record.NewFakeRecorder(10)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		status, changes, err := makeServiceChanges(ctx, cf, kubeClientset, recorder, service, "test", desiredState, CloudflareState{}, nil)
+
+		assert.NotNil(t, err)
+		assert.Equal(t, "failed", status)
+		assert.Equal(t, 0, changes)
+		if assert.Equal(t, 1, len(recorder.Events)) {
+			event := <-recorder.Events
+			assert.True(t, strings.HasPrefix(event, "Warning DNSRecordUpsertFailed Upserting dns record www.example.com (A) to 1.2.3.4 failed: "), event)
+			assert.Contains(t, event, "Service unavailable")
+		}
+	})
+
+	t.Run("CreatesARecordPerLoadBalancerIPAddress", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "true", UseOriginRecord: "false", IPAddress: "1.2.3.4", AdditionalIPAddresses: "5.6.7.8"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		status, changes, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, CloudflareState{}, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "succeeded", status)
+		assert.Equal(t, 1, changes)
+		fakeRESTClient.AssertCalled(t, "Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", isDNSRecord("A", "www.example.com", "1.2.3.4", true), authentication)
+		fakeRESTClient.AssertCalled(t, "Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", isDNSRecord("A", "www.example.com", "5.6.7.8", true), authentication)
+		fakeRESTClient.AssertNumberOfCalls(t, "Post", 2)
+		fakeRESTClient.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything, mock.Anything)
+
+		patchedService, err := kubeClientset.CoreV1().Services("mynamespace").Get(ctx, "myservice", metav1.GetOptions{})
+		assert.Nil(t, err)
+		var storedState CloudflareState
+		err = json.Unmarshal([]byte(patchedService.Annotations[annotationCloudflareState]), &storedState)
+		assert.Nil(t, err)
+		assert.Equal(t, "5.6.7.8", storedState.AdditionalIPAddresses)
+		assert.Equal(t, 2, len(storedState.Records))
+	})
+
+	t.Run("SkipsRecordsWithErrorForMalformedIPAddress", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "true", UseOriginRecord: "false", IPAddress: "1.2.3.4.5"}
+
+		fakeRESTClient := new(fakeRESTClient)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		status, changes, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, CloudflareState{}, nil)
+
+		assert.NotNil(t, err)
+		assert.Equal(t, "failed", status)
+		assert.Equal(t, 0, changes)
+		fakeRESTClient.AssertNotCalled(t, "Post", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("UpsertsNormalizedIPAddress", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "true", UseOriginRecord: "false", IPAddress: "fe80::1%eth0"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=AAAA", authentication).Return(noDNSRecordsResult, nil).Once()
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=AAAA", authentication).Return(getDNSRecordResult("AAAA", "www.example.com", "fe80::1", true), nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		_, _, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, CloudflareState{}, nil)
+
+		assert.Nil(t, err)
+		fakeRESTClient.AssertCalled(t, "Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", isDNSRecord("AAAA", "www.example.com", "fe80::1", true), authentication)
+	})
+
+	t.Run("SucceedsWithoutStoringStateWhenAnnotationsExceedTheirSizeLimit", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		kubeClientset.PrependReactor("patch", "services", func(action k8stesting.Action) (bool, apiruntime.Object, error) {
+			return true, nil, apierrors.NewInvalid(schema.GroupKind{Kind: "Service"}, "myservice", field.ErrorList{field.TooLong(field.NewPath("metadata", "annotations"), "", apivalidation.TotalAnnotationSizeLimitB)})
+		})
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "true", UseOriginRecord: "false", IPAddress: "1.2.3.4"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(noDNSRecordsResult, nil).Once()
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(getDNSRecordResult("A", "www.example.com", "1.2.3.4", true), nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		status, changes, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, CloudflareState{}, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "succeeded", status)
+		assert.Equal(t, 1, changes)
+		fakeRESTClient.AssertNumberOfCalls(t, "Post", 1)
+	})
+
+	t.Run("StoresZoneNameOfUpsertedRecordsInState", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "true", UseOriginRecord: "false", IPAddress: "1.2.3.4"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(noDNSRecordsResult, nil).Once()
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(getDNSRecordResult("A", "www.example.com", "1.2.3.4", true), nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		_, _, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, CloudflareState{}, nil)
+
+		assert.Nil(t, err)
+
+		patchedService, err := kubeClientset.CoreV1().Services("mynamespace").Get(ctx, "myservice", metav1.GetOptions{})
+		assert.Nil(t, err)
+		var storedState CloudflareState
+		err = json.Unmarshal([]byte(patchedService.Annotations[annotationCloudflareState]), &storedState)
+		assert.Nil(t, err)
+		assert.Equal(t, "example.com", storedState.ZoneName)
+	})
+
+	t.Run("CreatesUnproxiedOriginCnameRecordToLoadBalancerHostnameAndProxiedCnameRecordToOrigin", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "true", UseOriginRecord: "true", OriginRecordHostname: "origin.example.com", IPAddress: "abc.elb.us-east-1.amazonaws.com", TargetIsHostname: "true"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=origin.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=origin.example.com&type=CNAME", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=origin.example.com", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=CNAME", authentication).Return(noDNSRecordsResult, nil).Once()
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=CNAME", authentication).Return(getDNSRecordResult("CNAME", "www.example.com", "origin.example.com", true), nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		status, changes, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, CloudflareState{}, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "succeeded", status)
+		assert.Equal(t, 2, changes)
+		fakeRESTClient.AssertCalled(t, "Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", isDNSRecord("CNAME", "origin.example.com", "abc.elb.us-east-1.amazonaws.com", false), authentication)
+		fakeRESTClient.AssertCalled(t, "Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", isDNSRecord("CNAME", "www.example.com", "origin.example.com", true), authentication)
+		fakeRESTClient.AssertNumberOfCalls(t, "Post", 2)
+	})
+
+	t.Run("DeletesStoredRecordsOfRemovedHostnamesAndTracksRemainingRecords", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "false", UseOriginRecord: "false", IPAddress: "1.2.3.4"}
+		currentState := CloudflareState{Enabled: "true", Hostnames: "www.example.com,api.example.com", Proxy: "false", UseOriginRecord: "false", IPAddress: "1.2.3.4", Records: []managedRecord{
+			{Name: "www.example.com", Type: "A", Content: "1.2.3.4", TTL: 1},
+			{Name: "api.example.com", Type: "A", Content: "1.2.3.4", TTL: 1},
+		}}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=api.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(getDNSRecordResult("A", "www.example.com", "1.2.3.4", false), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=api.example.com&type=A", authentication).Return(getDNSRecordResult("A", "api.example.com", "1.2.3.4", false), nil)
+		fakeRESTClient.On("Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		_, changes, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, currentState, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, changes)
+		fakeRESTClient.AssertNumberOfCalls(t, "Delete", 1)
+
+		patchedService, err := kubeClientset.CoreV1().Services("mynamespace").Get(ctx, "myservice", metav1.GetOptions{})
+		assert.Nil(t, err)
+		var storedState CloudflareState
+		err = json.Unmarshal([]byte(patchedService.Annotations[annotationCloudflareState]), &storedState)
+		assert.Nil(t, err)
+		assert.Equal(t, []managedRecord{{Name: "www.example.com", Type: "A", Content: "1.2.3.4", TTL: 1, Zone: "example.com"}}, storedState.Records)
+	})
+
+	t.Run("CreatesRecordUnderRecordNameAndDeletesRecordOfHostname", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "cdn.example.com", RecordNames: "www.example.com=cdn.example.com", Proxy: "false", UseOriginRecord: "false", IPAddress: "1.2.3.4"}
+		currentState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "false", UseOriginRecord: "false", IPAddress: "1.2.3.4", Records: []managedRecord{
+			{Name: "www.example.com", Type: "A", Content: "1.2.3.4", TTL: 1},
+		}}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=cdn.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=cdn.example.com&type=A", authentication).Return(noDNSRecordsResult, nil).Once()
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=cdn.example.com", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=cdn.example.com&type=A", authentication).Return(getDNSRecordResult("A", "cdn.example.com", "1.2.3.4", false), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(getDNSRecordResult("A", "www.example.com", "1.2.3.4", false), nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		_, _, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, currentState, nil)
+
+		assert.Nil(t, err)
+		fakeRESTClient.AssertCalled(t, "Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", isDNSRecord("A", "cdn.example.com", "1.2.3.4", false), authentication)
+		fakeRESTClient.AssertNumberOfCalls(t, "Delete", 1)
+
+		patchedService, err := kubeClientset.CoreV1().Services("mynamespace").Get(ctx, "myservice", metav1.GetOptions{})
+		assert.Nil(t, err)
+		var storedState CloudflareState
+		err = json.Unmarshal([]byte(patchedService.Annotations[annotationCloudflareState]), &storedState)
+		assert.Nil(t, err)
+		assert.Equal(t, "www.example.com=cdn.example.com", storedState.RecordNames)
+		assert.Equal(t, []managedRecord{{Name: "cdn.example.com", Type: "A", Content: "1.2.3.4", TTL: 1, Zone: "example.com"}}, storedState.Records)
+	})
+
+	t.Run("DeletesStoredInternalRecordsAndSkipsUpsertingThemWhenInternalDnsIsDisabled", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", InternalHostnames: "internal.example.com", InternalDNS: "false", Proxy: "false", UseOriginRecord: "false", IPAddress: "1.2.3.4", InternalIPAddress: "10.0.0.1"}
+		currentState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", InternalHostnames: "internal.example.com", InternalDNS: "true", Proxy: "false", UseOriginRecord: "false", IPAddress: "1.2.3.4", InternalIPAddress: "10.0.0.1", Records: []managedRecord{
+			{Name: "www.example.com", Type: "A", Content: "1.2.3.4", TTL: 1},
+			{Name: "internal.example.com", Type: "A", Content: "10.0.0.1", TTL: 1},
+		}}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=internal.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=internal.example.com&type=A", authentication).Return(getDNSRecordResult("A", "internal.example.com", "10.0.0.1", false), nil)
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		_, changes, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, currentState, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, changes)
+		fakeRESTClient.AssertNumberOfCalls(t, "Delete", 1)
+		fakeRESTClient.AssertNotCalled(t, "Post", mock.Anything, mock.Anything, mock.Anything)
+		fakeRESTClient.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything)
+
+		patchedService, err := kubeClientset.CoreV1().Services("mynamespace").Get(ctx, "myservice", metav1.GetOptions{})
+		assert.Nil(t, err)
+		var storedState CloudflareState
+		err = json.Unmarshal([]byte(patchedService.Annotations[annotationCloudflareState]), &storedState)
+		assert.Nil(t, err)
+		assert.Equal(t, "false", storedState.InternalDNS)
+		assert.Equal(t, []managedRecord{{Name: "www.example.com", Type: "A", Content: "1.2.3.4", TTL: 1}}, storedState.Records)
+	})
+
+	t.Run("UpsertsHostnamesInMultipleZonesInTheirOwnZoneAndTracksTheZonePerRecord", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "api.foo.com,api.bar.net", Proxy: "false", UseOriginRecord: "false", IPAddress: "1.2.3.4"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=api.foo.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=foo.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "11111111111111111111111111111111", "name": "foo.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=api.bar.net", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=bar.net", authentication).Return([]byte(`{"success": true, "result": [{"id": "22222222222222222222222222222222", "name": "bar.net"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/11111111111111111111111111111111/dns_records/?name=api.foo.com&type=A", authentication).Return([]byte(`{"success": true, "result": [{"id": "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "type": "A", "name": "api.foo.com", "content": "5.6.7.8", "proxiable": true, "proxied": false, "ttl": 1, "zone_id": "11111111111111111111111111111111", "zone_name": "foo.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/22222222222222222222222222222222/dns_records/?name=api.bar.net&type=A", authentication).Return([]byte(`{"success": true, "result": [{"id": "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", "type": "A", "name": "api.bar.net", "content": "5.6.7.8", "proxiable": true, "proxied": false, "ttl": 1, "zone_id": "22222222222222222222222222222222", "zone_name": "bar.net"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Put", "https://api.cloudflare.com/client/v4/zones/11111111111111111111111111111111/dns_records/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", isDNSRecord("A", "api.foo.com", "1.2.3.4", false), authentication).Return([]byte(`{"success": true}`), nil)
+		fakeRESTClient.On("Put", "https://api.cloudflare.com/client/v4/zones/22222222222222222222222222222222/dns_records/bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", isDNSRecord("A", "api.bar.net", "1.2.3.4", false), authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		status, changes, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, CloudflareState{}, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "succeeded", status)
+		assert.Equal(t, 2, changes)
+		fakeRESTClient.AssertNumberOfCalls(t, "Put", 2)
+		fakeRESTClient.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+
+		patchedService, err := kubeClientset.CoreV1().Services("mynamespace").Get(ctx, "myservice", metav1.GetOptions{})
+		assert.Nil(t, err)
+		var storedState CloudflareState
+		err = json.Unmarshal([]byte(patchedService.Annotations[annotationCloudflareState]), &storedState)
+		assert.Nil(t, err)
+		assert.Equal(t, "foo.com,bar.net", storedState.ZoneName)
+		assert.Equal(t, []managedRecord{
+			{Name: "api.foo.com", Type: "A", Content: "1.2.3.4", TTL: 1, Zone: "foo.com"},
+			{Name: "api.bar.net", Type: "A", Content: "1.2.3.4", TTL: 1, Zone: "bar.net"},
+		}, storedState.Records)
+	})
+
+	t.Run("UpdatesTTLOfExistingRecordWhenOnlyTheTTLChanged", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		currentState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "false", UseOriginRecord: "false", IPAddress: "1.2.3.4"}
+		desiredState := currentState
+		desiredState.TTL = "300"
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(getDNSRecordResult("A", "www.example.com", "1.2.3.4", false), nil)
+		fakeRESTClient.On("Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		status, _, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, currentState, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "succeeded", status)
+		fakeRESTClient.AssertCalled(t, "Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", mock.MatchedBy(func(r DNSRecord) bool {
+			return r.Content == "1.2.3.4" && r.TTL == 300
+		}), authentication)
+	})
+
+	t.Run("SetsSSLModeOfZoneOfProxiedRecordWhenAnnotationIsSet", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com,api.example.com", Proxy: "true", UseOriginRecord: "false", IPAddress: "1.2.3.4", SSLMode: "full"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=api.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(getDNSRecordResult("A", "www.example.com", "1.2.3.4", true), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=api.example.com&type=A", authentication).Return(getDNSRecordResult("A", "api.example.com", "1.2.3.4", true), nil)
+		fakeRESTClient.On("Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/settings/ssl", authentication).Return([]byte(`{"success": true, "result": {"id": "ssl", "value": "flexible"}}`), nil)
+		fakeRESTClient.On("Patch", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/settings/ssl", ZoneSetting{Value: "full"}, authentication).Return([]byte(`{"success": true, "result": {"id": "ssl", "value": "full"}}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		status, _, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, CloudflareState{}, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "succeeded", status)
+		fakeRESTClient.AssertNumberOfCalls(t, "Patch", 1)
+	})
+
+	t.Run("LeavesSSLModeOfZoneAloneWhenAnnotationIsNotSet", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "true", UseOriginRecord: "false", IPAddress: "1.2.3.4"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(getDNSRecordResult("A", "www.example.com", "1.2.3.4", true), nil)
+		fakeRESTClient.On("Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		_, _, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, CloudflareState{}, nil)
+
+		assert.Nil(t, err)
+		fakeRESTClient.AssertNotCalled(t, "Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/settings/ssl", authentication)
+		fakeRESTClient.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("AppliesChangedZoneSettingsOncePerZoneWhenAnnotationIsSet", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com,api.example.com", Proxy: "true", UseOriginRecord: "false", IPAddress: "1.2.3.4", ZoneSettings: "browser_cache_ttl=14400,always_use_https=on"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=api.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(getDNSRecordResult("A", "www.example.com", "1.2.3.4", true), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=api.example.com&type=A", authentication).Return(getDNSRecordResult("A", "api.example.com", "1.2.3.4", true), nil)
+		fakeRESTClient.On("Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/settings/browser_cache_ttl", authentication).Return([]byte(`{"success": true, "result": {"id": "browser_cache_ttl", "value": 14400}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/settings/always_use_https", authentication).Return([]byte(`{"success": true, "result": {"id": "always_use_https", "value": "off"}}`), nil)
+		fakeRESTClient.On("Patch", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/settings/always_use_https", ZoneSetting{Value: "on"}, authentication).Return([]byte(`{"success": true, "result": {"id": "always_use_https", "value": "on"}}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		status, _, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, CloudflareState{}, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "succeeded", status)
+		fakeRESTClient.AssertNumberOfCalls(t, "Patch", 1)
+	})
+
+	t.Run("ReturnsErrorForInvalidZoneSettings", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "true", UseOriginRecord: "false", IPAddress: "1.2.3.4", ZoneSettings: "always_use_https"}
+
+		fakeRESTClient := new(fakeRESTClient)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		_, _, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, CloudflareState{}, nil)
+
+		assert.NotNil(t, err)
+		fakeRESTClient.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("KeepsExistingProxiableRecordProxiedWhenProxyIsAuto", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "auto", UseOriginRecord: "false", IPAddress: "5.6.7.8"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(getDNSRecordResult("A", "www.example.com", "1.2.3.4", true), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(getDNSRecordResult("A", "www.example.com", "1.2.3.4", true), nil)
+		fakeRESTClient.On("Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", mock.Anything, authentication).Return([]byte(`{"success": true, "result": {"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "www.example.com", "content": "5.6.7.8", "proxiable": true, "proxied": true}}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		_, _, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, CloudflareState{}, nil)
+
+		assert.Nil(t, err)
+		fakeRESTClient.AssertCalled(t, "Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", isDNSRecord("A", "www.example.com", "5.6.7.8", true), authentication)
+		fakeRESTClient.AssertNumberOfCalls(t, "Put", 1)
+	})
+
+	t.Run("ProxiesNewRecordOnceCloudflareReportsItProxiableWhenProxyIsAuto", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "auto", UseOriginRecord: "false", IPAddress: "1.2.3.4"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(noDNSRecordsResult, nil).Once()
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(getDNSRecordResult("A", "www.example.com", "1.2.3.4", false), nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return([]byte(`{"success": true, "result": {"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "www.example.com", "content": "1.2.3.4", "proxiable": true, "proxied": false}}`), nil)
+		fakeRESTClient.On("Patch", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		_, _, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, CloudflareState{}, nil)
+
+		assert.Nil(t, err)
+		fakeRESTClient.AssertCalled(t, "Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", isDNSRecord("A", "www.example.com", "1.2.3.4", false), authentication)
+		fakeRESTClient.AssertCalled(t, "Patch", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", mock.MatchedBy(func(proxyPatch dnsRecordProxyPatch) bool { return proxyPatch.Proxied }), authentication)
+	})
+
+	t.Run("LeavesNewRecordDnsOnlyIfItIsNotProxiableWhenProxyIsAuto", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "auto", UseOriginRecord: "false", IPAddress: "10.0.0.1"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(noDNSRecordsResult, nil).Once()
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return([]byte(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "www.example.com", "content": "10.0.0.1", "proxiable": false, "proxied": false, "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return([]byte(`{"success": true, "result": {"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "www.example.com", "content": "10.0.0.1", "proxiable": false, "proxied": false}}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		_, _, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, CloudflareState{}, nil)
+
+		assert.Nil(t, err)
+		fakeRESTClient.AssertCalled(t, "Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", isDNSRecord("A", "www.example.com", "10.0.0.1", false), authentication)
+		fakeRESTClient.AssertNotCalled(t, "Put", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("LeavesRecordDnsOnlyWithoutWarningInZoneOnOtherThanProxyPlansWhenProxyIsAuto", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "auto", ProxyPlans: "pro", UseOriginRecord: "false", IPAddress: "1.2.3.4"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com", "plan": {"name": "Free Website", "legacy_id": "free"}}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(getDNSRecordResult("A", "www.example.com", "1.2.3.4", false), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(getDNSRecordResult("A", "www.example.com", "1.2.3.4", false), nil)
+		fakeRESTClient.On("Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", mock.Anything, authentication).Return([]byte(`{"success": true, "result": {"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "www.example.com", "content": "1.2.3.4", "proxiable": true, "proxied": false}}`), nil)
+		recorder := record.NewFakeRecorder(10)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		_, _, err := makeServiceChanges(ctx, cf, kubeClientset, recorder, service, "test", desiredState, CloudflareState{}, nil)
+
+		assert.Nil(t, err)
+		fakeRESTClient.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything, mock.Anything)
+		close(recorder.Events)
+		for event := range recorder.Events {
+			assert.NotContains(t, event, "ProxyDowngraded")
+		}
+	})
+
+	t.Run("CreatesARecordAtZoneApexInsteadOfCnameRecordToOrigin", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "example.com", Proxy: "true", UseOriginRecord: "true", OriginRecordHostname: "origin.example.com", IPAddress: "1.2.3.4"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=origin.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=origin.example.com&type=A", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=origin.example.com", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=A", authentication).Return(noDNSRecordsResult, nil).Once()
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=A", authentication).Return(getDNSRecordResult("A", "example.com", "1.2.3.4", true), nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		status, changes, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, CloudflareState{}, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "succeeded", status)
+		assert.Equal(t, 2, changes)
+		fakeRESTClient.AssertCalled(t, "Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", isDNSRecord("A", "origin.example.com", "1.2.3.4", false), authentication)
+		fakeRESTClient.AssertCalled(t, "Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", isDNSRecord("A", "example.com", "1.2.3.4", true), authentication)
+		fakeRESTClient.AssertNumberOfCalls(t, "Post", 2)
+	})
+
+	t.Run("SkipsUnproxiedCnameRecordAtZoneApex", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "example.com", Proxy: "false", UseOriginRecord: "false", IPAddress: "abc.elb.us-east-1.amazonaws.com", TargetIsHostname: "true"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		_, changes, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, CloudflareState{}, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, changes)
+		fakeRESTClient.AssertNotCalled(t, "Post", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("UpsertsRecordsDespiteMatchingStateAndRemovesAnnotationWhenForceUpdateIsRequested", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myservice",
+				Namespace: "mynamespace",
+				Annotations: map[string]string{
+					annotationCloudflareForceUpdate: "true",
+				},
+			},
+		}
+		kubeClientset := fake.NewSimpleClientset(service)
+		state := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "false", UseOriginRecord: "false", IPAddress: "1.2.3.4"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(noDNSRecordsResult, nil).Once()
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(getDNSRecordResult("A", "www.example.com", "1.2.3.4", false), nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		status, _, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", state, state, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "succeeded", status)
+		fakeRESTClient.AssertCalled(t, "Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", isDNSRecord("A", "www.example.com", "1.2.3.4", false), authentication)
+
+		patchedService, err := kubeClientset.CoreV1().Services("mynamespace").Get(ctx, "myservice", metav1.GetOptions{})
+		assert.Nil(t, err)
+		assert.NotContains(t, patchedService.Annotations, annotationCloudflareForceUpdate)
+		assert.Contains(t, patchedService.Annotations, annotationCloudflareState)
+	})
+
+	t.Run("SkipsRecordsWhenStateMatchesWithoutForceUpdate", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		state := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "false", UseOriginRecord: "false", IPAddress: "1.2.3.4"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		status, _, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", state, state, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "skipped", status)
+		fakeRESTClient.AssertNotCalled(t, "Post", mock.Anything, mock.Anything, mock.Anything)
+	})
+	t.Run("UnproxiesExistingRecordWhenProxyIsDisabledDespiteProxyAnnotation", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myservice",
+				Namespace: "mynamespace",
+				Annotations: map[string]string{
+					"estafette.io/cloudflare-dns":       "true",
+					"estafette.io/cloudflare-hostnames": "www.example.com",
+					"estafette.io/cloudflare-proxy":     "true",
+				},
+			},
+			Spec: v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer},
+			Status: v1.ServiceStatus{
+				LoadBalancer: v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: "1.2.3.4"}}},
+			},
+		}
+		kubeClientset := fake.NewSimpleClientset(service)
+
+		*disableProxy = true
+		defer func() { *disableProxy = false }()
+
+		desiredState := getDesiredServiceState(service)
+		currentState := desiredState
+		currentState.Proxy = "true"
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(getDNSRecordResult("A", "www.example.com", "1.2.3.4", true), nil)
+		fakeRESTClient.On("Put", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+		fakeRESTClient.On("Patch", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		status, _, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, currentState, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "succeeded", status)
+		assert.Equal(t, "false", desiredState.Proxy)
+		fakeRESTClient.AssertCalled(t, "Patch", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", dnsRecordProxyPatch{Proxied: false}, authentication)
+	})
+
+	t.Run("LooksUpZoneOncePerNameForOriginRecordAndHostnames", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com,api.example.com", Proxy: "false", UseOriginRecord: "true", OriginRecordHostname: "origin.example.com", IPAddress: "1.2.3.4"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=origin.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=api.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=origin.example.com&type=A", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=origin.example.com", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=CNAME", authentication).Return(noDNSRecordsResult, nil).Once()
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=CNAME", authentication).Return(getDNSRecordResult("CNAME", "www.example.com", "origin.example.com", false), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=api.example.com&type=CNAME", authentication).Return(noDNSRecordsResult, nil).Once()
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=api.example.com", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=api.example.com&type=CNAME", authentication).Return(getDNSRecordResult("CNAME", "api.example.com", "origin.example.com", false), nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		status, _, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, CloudflareState{}, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "succeeded", status)
+		fakeRESTClient.AssertNumberOfCalls(t, "Post", 3)
+		for _, hostname := range []string{"origin.example.com", "www.example.com", "api.example.com"} {
+			zoneLookups := 0
+			for _, call := range fakeRESTClient.Calls {
+				if call.Method == "Get" && call.Arguments.String(0) == "https://api.cloudflare.com/client/v4/zones/?name="+hostname {
+					zoneLookups++
+				}
+			}
+			assert.Equal(t, 1, zoneLookups, hostname)
+		}
+	})
+
+	t.Run("DeletesAAAAOriginRecordWithPreviousAddressWhenOriginRecordIsNoLongerUsed", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "false", UseOriginRecord: "false", OriginRecordHostname: "origin.example.com", IPAddress: "2001:db8::2"}
+		currentState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "false", UseOriginRecord: "true", OriginRecordHostname: "origin.example.com", IPAddress: "2001:db8::1"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=origin.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=AAAA", authentication).Return(noDNSRecordsResult, nil).Once()
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=AAAA", authentication).Return(getDNSRecordResult("AAAA", "www.example.com", "2001:db8::2", false), nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=origin.example.com&type=AAAA", authentication).Return(getDNSRecordResult("AAAA", "origin.example.com", "2001:db8::1", false), nil).Once()
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=origin.example.com&type=AAAA", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+		recorder := record.NewFakeRecorder(10)
+
+		// act
+		status, _, err := makeServiceChanges(ctx, cf, kubeClientset, recorder, service, "test", desiredState, currentState, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "succeeded", status)
+		fakeRESTClient.AssertNumberOfCalls(t, "Delete", 1)
+		fakeRESTClient.AssertNotCalled(t, "Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=origin.example.com", authentication)
+		close(recorder.Events)
+		deletedEvents := []string{}
+		for event := range recorder.Events {
+			if strings.Contains(event, "DNSRecordDeleted") {
+				deletedEvents = append(deletedEvents, event)
+			}
+		}
+		assert.Equal(t, []string{"Normal DNSRecordDeleted Deleted origin dns record origin.example.com (AAAA) with content 2001:db8::1"}, deletedEvents)
+	})
+
+	t.Run("DoesNotReuseParentZoneForHostnameInSubzone", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "example.com,www.sub.example.com", Proxy: "false", IPAddress: "1.2.3.4"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.sub.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=sub.example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "7c5dae5552338874e5053f2534d2767a", "name": "sub.example.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=A", authentication).Return(noDNSRecordsResult, nil).Once()
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=example.com&type=A", authentication).Return(getDNSRecordResult("A", "example.com", "1.2.3.4", false), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/7c5dae5552338874e5053f2534d2767a/dns_records/?name=www.sub.example.com&type=A", authentication).Return(noDNSRecordsResult, nil).Once()
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/7c5dae5552338874e5053f2534d2767a/dns_records/?name=www.sub.example.com", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/7c5dae5552338874e5053f2534d2767a/dns_records/?name=www.sub.example.com&type=A", authentication).Return(getDNSRecordResult("A", "www.sub.example.com", "1.2.3.4", false), nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/7c5dae5552338874e5053f2534d2767a/dns_records", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		status, _, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, CloudflareState{}, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "succeeded", status)
+		fakeRESTClient.AssertCalled(t, "Post", "https://api.cloudflare.com/client/v4/zones/7c5dae5552338874e5053f2534d2767a/dns_records", mock.MatchedBy(func(r DNSRecord) bool { return r.Name == "www.sub.example.com" }), authentication)
+		fakeRESTClient.AssertNotCalled(t, "Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.MatchedBy(func(r DNSRecord) bool { return r.Name == "www.sub.example.com" }), authentication)
+	})
+
+	t.Run("CreatesRecordDNSOnlyDuringProxyGracePeriod", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "true", UseOriginRecord: "false", IPAddress: "1.2.3.4", ProxyAfter: "30m0s"}
+
+		now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		timeNow = func() time.Time { return now }
+		defer func() { timeNow = time.Now }()
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(noDNSRecordsResult, nil).Once()
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(getDNSRecordResult("A", "www.example.com", "1.2.3.4", false), nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		status, _, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, CloudflareState{}, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "succeeded", status)
+		fakeRESTClient.AssertCalled(t, "Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", isDNSRecord("A", "www.example.com", "1.2.3.4", false), authentication)
+
+		patchedService, err := kubeClientset.CoreV1().Services("mynamespace").Get(ctx, "myservice", metav1.GetOptions{})
+		assert.Nil(t, err)
+		var storedState CloudflareState
+		err = json.Unmarshal([]byte(patchedService.Annotations[annotationCloudflareState]), &storedState)
+		assert.Nil(t, err)
+		assert.Equal(t, "false", storedState.Proxy)
+		assert.Equal(t, "2024-03-01T12:00:00Z", storedState.DNSOnlySince)
+	})
+
+	t.Run("ProxiesRecordOnceProxyGracePeriodHasPassed", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "true", UseOriginRecord: "false", IPAddress: "1.2.3.4", ProxyAfter: "30m0s"}
+		currentState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "false", UseOriginRecord: "false", IPAddress: "1.2.3.4", ProxyAfter: "30m0s", DNSOnlySince: "2024-03-01T12:00:00Z"}
+
+		now := time.Date(2024, 3, 1, 12, 31, 0, 0, time.UTC)
+		timeNow = func() time.Time { return now }
+		defer func() { timeNow = time.Now }()
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(getDNSRecordResult("A", "www.example.com", "1.2.3.4", false), nil)
+		fakeRESTClient.On("Patch", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		status, _, err := makeServiceChanges(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test", desiredState, currentState, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "succeeded", status)
+		fakeRESTClient.AssertNumberOfCalls(t, "Patch", 1)
+
+		patchedService, err := kubeClientset.CoreV1().Services("mynamespace").Get(ctx, "myservice", metav1.GetOptions{})
+		assert.Nil(t, err)
+		var storedState CloudflareState
+		err = json.Unmarshal([]byte(patchedService.Annotations[annotationCloudflareState]), &storedState)
+		assert.Nil(t, err)
+		assert.Equal(t, "true", storedState.Proxy)
+	})
+}
+
+func TestCollectOrphanedRecords(t *testing.T) {
+
+	authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+	ownedDNSRecordsResult := []byte(`{"success": true, "result": [
+		{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "www.example.com", "content": "1.2.3.4", "comment": "managed by estafette-cloudflare-dns"},
+		{"id": "a8f2c5b1d4e3f6a7b8c9d0e1f2a3b4c5", "type": "A", "name": "deleted.example.com", "content": "1.2.3.4", "comment": "managed by estafette-cloudflare-dns"},
+		{"id": "0d1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a", "type": "A", "name": "manual.example.com", "content": "1.2.3.4", "comment": "created by hand"}
+	], "result_info": {"page": 1, "per_page": 50, "count": 3, "total_count": 3}}`)
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "myservice",
+			Namespace: "mynamespace",
+			Annotations: map[string]string{
+				"estafette.io/cloudflare-dns":       "true",
+				"estafette.io/cloudflare-hostnames": "www.example.com",
+			},
+		},
+	}
+
+	newFakeRESTClient := func() *fakeRESTClient {
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?comment.contains=managed+by+estafette-cloudflare-dns&page=1&per_page=50", authentication).Return(ownedDNSRecordsResult, nil)
+		fakeRESTClient.On("Delete", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+		return fakeRESTClient
+	}
+
+	t.Run("DeletesOwnedRecordsWhoseNameIsNotUsedByAnyObject", func(t *testing.T) {
+
+		fakeRESTClient := newFakeRESTClient()
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+		cf.zone = &Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353", Name: "example.com"}
+		cf.ownershipMarker = defaultCloudflareComment
+
+		// act
+		deleted, err := collectOrphanedRecords(context.Background(), cf, fake.NewSimpleClientset(service), nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, deleted)
+		fakeRESTClient.AssertNumberOfCalls(t, "Delete", 1)
+		fakeRESTClient.AssertCalled(t, "Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/a8f2c5b1d4e3f6a7b8c9d0e1f2a3b4c5", authentication)
+	})
+
+	t.Run("KeepsRecordsInStoredStateOfExistingObject", func(t *testing.T) {
+
+		fakeRESTClient := newFakeRESTClient()
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+		cf.zone = &Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353", Name: "example.com"}
+		cf.ownershipMarker = defaultCloudflareComment
+
+		renamedService := service.DeepCopy()
+		renamedService.Annotations[annotationCloudflareState] = `{"enabled":"true","hostnames":"deleted.example.com"}`
+
+		// act
+		deleted, err := collectOrphanedRecords(context.Background(), cf, fake.NewSimpleClientset(renamedService), nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 0, deleted)
+		fakeRESTClient.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("DeletesNothingIfObjectsCannotBeListed", func(t *testing.T) {
+
+		fakeRESTClient := newFakeRESTClient()
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+		cf.zone = &Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353", Name: "example.com"}
+		cf.ownershipMarker = defaultCloudflareComment
+
+		kubeClientset := fake.NewSimpleClientset(service)
+		kubeClientset.PrependReactor("list", "ingresses", func(action k8stesting.Action) (bool, apiruntime.Object, error) {
+			return true, nil, fmt.Errorf("Listing ingresses failed")
+		})
+
+		// act
+		deleted, err := collectOrphanedRecords(context.Background(), cf, kubeClientset, nil)
+
+		assert.NotNil(t, err)
+		assert.Equal(t, 0, deleted)
+		fakeRESTClient.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+}
+
+func TestGetDriftedHostnames(t *testing.T) {
+
+	authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+	state := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "true", UseOriginRecord: "false", IPAddress: "1.2.3.4"}
+
+	newFakeRESTClient := func(dnsRecordsResult string) *fakeRESTClient {
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return([]byte(dnsRecordsResult), nil)
+		return fakeRESTClient
+	}
+
+	t.Run("ReturnsNoHostnamesIfRecordsMatchState", func(t *testing.T) {
+
+		cf := New(authentication)
+		cf.restClient = newFakeRESTClient(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "www.example.com", "content": "1.2.3.4", "proxiable": true, "proxied": true}], "result_info": {"count": 1}}`)
+
+		// act
+		driftedHostnames := getDriftedHostnames(cf, state)
+
+		assert.Empty(t, driftedHostnames)
+	})
+
+	t.Run("ReturnsHostnameIfRecordContentDiffers", func(t *testing.T) {
+
+		cf := New(authentication)
+		cf.restClient = newFakeRESTClient(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "www.example.com", "content": "5.6.7.8", "proxiable": true, "proxied": true}], "result_info": {"count": 1}}`)
+
+		// act
+		driftedHostnames := getDriftedHostnames(cf, state)
+
+		assert.Equal(t, []string{"www.example.com"}, driftedHostnames)
+	})
+
+	t.Run("ReturnsHostnameIfProxySettingDiffers", func(t *testing.T) {
+
+		cf := New(authentication)
+		cf.restClient = newFakeRESTClient(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "www.example.com", "content": "1.2.3.4", "proxiable": true, "proxied": false}], "result_info": {"count": 1}}`)
+
+		// act
+		driftedHostnames := getDriftedHostnames(cf, state)
+
+		assert.Equal(t, []string{"www.example.com"}, driftedHostnames)
+	})
+
+	t.Run("ReturnsHostnameIfRecordIsMissing", func(t *testing.T) {
+
+		cf := New(authentication)
+		cf.restClient = newFakeRESTClient(`{"success": true, "result": [], "result_info": {"count": 0}}`)
+
+		// act
+		driftedHostnames := getDriftedHostnames(cf, state)
+
+		assert.Equal(t, []string{"www.example.com"}, driftedHostnames)
+	})
+
+	t.Run("ReturnsNoHostnamesIfRecordIsNotOwned", func(t *testing.T) {
+
+		cf := New(authentication)
+		cf.restClient = newFakeRESTClient(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "www.example.com", "content": "5.6.7.8", "proxiable": true, "proxied": true, "comment": "created by hand"}], "result_info": {"count": 1}}`)
+		cf.ownershipMarker = defaultCloudflareComment
+
+		// act
+		driftedHostnames := getDriftedHostnames(cf, state)
+
+		assert.Empty(t, driftedHostnames)
+	})
+}
+
+func TestDeleteRecordsFromState(t *testing.T) {
+
+	t.Run("DeletesAAAAOriginRecordForIPv6Address", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace"}}
+		state := CloudflareState{Enabled: "true", UseOriginRecord: "true", OriginRecordHostname: "origin.example.com", IPAddress: "2001:db8::1"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=origin.example.com", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=origin.example.com&type=AAAA", authentication).Return([]byte(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "AAAA", "name": "origin.example.com", "content": "2001:db8::1", "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}], "result_info": {"count": 1}}`), nil)
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		changes, err := deleteRecordsFromState(cf, record.NewFakeRecorder(10), service, "Service", service.Name, service.Namespace, "test", state)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 1, changes)
+		fakeRESTClient.AssertCalled(t, "Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication)
+	})
+}
+
+func TestMakeChangesWhenDnsIsDisabled(t *testing.T) {
+
+	authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+	currentState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "true", UseOriginRecord: "false", IPAddress: "1.2.3.4", Records: []managedRecord{{Name: "www.example.com", Type: "A", Content: "1.2.3.4", Proxied: true}}}
+	stateAnnotation := `{"enabled":"true","hostnames":"www.example.com","proxy":"true","useOriginRecord":"false","ipAddress":"1.2.3.4","records":[{"name":"www.example.com","type":"A","content":"1.2.3.4","proxied":true}]}`
+
+	newFakeRESTClient := func(deleteErr error) *fakeRESTClient {
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return([]byte(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "www.example.com", "content": "1.2.3.4", "proxied": true, "comment": "managed by estafette-cloudflare-dns", "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}], "result_info": {"count": 1}}`), nil)
+		if deleteErr != nil {
+			fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return([]byte(nil), deleteErr)
+		} else {
+			fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return([]byte(`{"success": true}`), nil)
+		}
+		return fakeRESTClient
+	}
+	newCloudflare := func(fakeRESTClient *fakeRESTClient) *Cloudflare {
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+		cf.ownershipMarker = defaultCloudflareComment
+		return cf
+	}
+
+	t.Run("ServiceDeletesStoredRecordsAndRemovesStateAnnotation", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace", Annotations: map[string]string{annotationCloudflareState: stateAnnotation}}}
+		kubeClientset := fake.NewSimpleClientset(service)
+		fakeRESTClient := newFakeRESTClient(nil)
+
+		// act
+		status, changes, err := makeServiceChanges(ctx, newCloudflare(fakeRESTClient), kubeClientset, record.NewFakeRecorder(10), service, "test", CloudflareState{Enabled: "false"}, currentState, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "deleted", status)
+		assert.Equal(t, 1, changes)
+		fakeRESTClient.AssertCalled(t, "Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication)
+
+		patchedService, err := kubeClientset.CoreV1().Services("mynamespace").Get(ctx, "myservice", metav1.GetOptions{})
+		assert.Nil(t, err)
+		assert.NotContains(t, patchedService.Annotations, annotationCloudflareState)
+	})
+
+	t.Run("ServiceKeepsStateAnnotationIfDeletingFails", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "mynamespace", Annotations: map[string]string{annotationCloudflareState: stateAnnotation}}}
+		kubeClientset := fake.NewSimpleClientset(service)
+
+		// act
+		status, changes, err := makeServiceChanges(ctx, newCloudflare(newFakeRESTClient(errors.New("Service unavailable"))), kubeClientset, record.NewFakeRecorder(10), service, "test", CloudflareState{Enabled: "false"}, currentState, nil)
+
+		assert.NotNil(t, err)
+		assert.Equal(t, "failed", status)
+		assert.Equal(t, 0, changes)
+
+		for _, action := range kubeClientset.Actions() {
+			assert.NotEqual(t, "patch", action.GetVerb())
+		}
+		unchangedService, err := kubeClientset.CoreV1().Services("mynamespace").Get(ctx, "myservice", metav1.GetOptions{})
+		assert.Nil(t, err)
+		assert.Equal(t, stateAnnotation, unchangedService.Annotations[annotationCloudflareState])
+	})
+
+	t.Run("IngressDeletesStoredRecordsAndRemovesStateFromConfigMap", func(t *testing.T) {
+
+		ctx := context.Background()
+		stateStore = newConfigMapStateStore(fake.NewSimpleClientset(), "estafette", "estafette-cloudflare-dns-state")
+		defer func() { stateStore = nil }()
+		stateStore.set(ctx, "Ingress", "mynamespace", "myingress", currentState)
+
+		ingress := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "myingress", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(ingress)
+		fakeRESTClient := newFakeRESTClient(nil)
+
+		// act
+		status, changes, err := makeIngressChanges(ctx, newCloudflare(fakeRESTClient), kubeClientset, record.NewFakeRecorder(10), ingress, "test", CloudflareState{Enabled: "false"}, currentState, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "deleted", status)
+		assert.Equal(t, 1, changes)
+		fakeRESTClient.AssertCalled(t, "Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication)
+
+		_, ok := stateStore.get(ctx, "Ingress", "mynamespace", "myingress")
+		assert.False(t, ok)
+	})
+
+	t.Run("IngressKeepsStateInConfigMapIfDeletingFails", func(t *testing.T) {
+
+		ctx := context.Background()
+		stateStore = newConfigMapStateStore(fake.NewSimpleClientset(), "estafette", "estafette-cloudflare-dns-state")
+		defer func() { stateStore = nil }()
+		stateStore.set(ctx, "Ingress", "mynamespace", "myingress", currentState)
+
+		ingress := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "myingress", Namespace: "mynamespace"}}
+		kubeClientset := fake.NewSimpleClientset(ingress)
+
+		// act
+		status, changes, err := makeIngressChanges(ctx, newCloudflare(newFakeRESTClient(errors.New("Service unavailable"))), kubeClientset, record.NewFakeRecorder(10), ingress, "test", CloudflareState{Enabled: "false"}, currentState, nil)
+
+		assert.NotNil(t, err)
+		assert.Equal(t, "failed", status)
+		assert.Equal(t, 0, changes)
+
+		storedState, ok := stateStore.get(ctx, "Ingress", "mynamespace", "myingress")
+		assert.True(t, ok)
+		assert.Equal(t, currentState.Records, storedState.Records)
+	})
+}
+
+func TestServiceFinalizer(t *testing.T) {
+
+	authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+	getService := func(deletionTimestamp *metav1.Time, finalizers []string) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "myservice",
+				Namespace:         "mynamespace",
+				DeletionTimestamp: deletionTimestamp,
+				Finalizers:        finalizers,
+				Annotations: map[string]string{
+					annotationCloudflareDNS:       "true",
+					annotationCloudflareHostnames: "www.example.com",
+					annotationCloudflareProxy:     "false",
+				},
+			},
+			Spec: v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer},
+			Status: v1.ServiceStatus{
+				LoadBalancer: v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: "1.2.3.4"}}},
+			},
+		}
+	}
+
+	t.Run("AddsFinalizerToServiceWithManagedRecords", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := getService(nil, []string{"other.io/finalizer"})
+		kubeClientset := fake.NewSimpleClientset(service)
+
+		// act
+		err := updateServiceFinalizer(ctx, kubeClientset, service, true)
+
+		assert.Nil(t, err)
+		updatedService, _ := kubeClientset.CoreV1().Services("mynamespace").Get(ctx, "myservice", metav1.GetOptions{})
+		assert.Equal(t, []string{"other.io/finalizer", finalizerCloudflareDNS}, updatedService.Finalizers)
+	})
+
+	t.Run("RemovesFinalizerFromServiceWithoutManagedRecords", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := getService(nil, []string{finalizerCloudflareDNS, "other.io/finalizer"})
+		kubeClientset := fake.NewSimpleClientset(service)
+
+		// act
+		err := updateServiceFinalizer(ctx, kubeClientset, service, false)
+
+		assert.Nil(t, err)
+		updatedService, _ := kubeClientset.CoreV1().Services("mynamespace").Get(ctx, "myservice", metav1.GetOptions{})
+		assert.Equal(t, []string{"other.io/finalizer"}, updatedService.Finalizers)
+	})
+
+	t.Run("DeletesRecordsAndRemovesFinalizerOfServiceBeingDeleted", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := getService(&metav1.Time{Time: time.Now()}, []string{finalizerCloudflareDNS})
+		kubeClientset := fake.NewSimpleClientset(service)
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return([]byte(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "www.example.com", "content": "1.2.3.4", "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}], "result_info": {"count": 1}}`), nil)
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		status, changes, err := processService(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "deleted", status)
+		assert.Equal(t, 1, changes)
+		updatedService, _ := kubeClientset.CoreV1().Services("mynamespace").Get(ctx, "myservice", metav1.GetOptions{})
+		assert.Equal(t, 0, len(updatedService.Finalizers))
+		// the delete event that follows is skipped, but only once
+		assert.True(t, wasFinalized("Service", "mynamespace", "myservice"))
+		assert.False(t, wasFinalized("Service", "mynamespace", "myservice"))
+	})
+
+	t.Run("KeepsFinalizerIfDeletingRecordsFails", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := getService(&metav1.Time{Time: time.Now()}, []string{finalizerCloudflareDNS})
+		kubeClientset := fake.NewSimpleClientset(service)
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte{}, errors.New("Service unavailable"))
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		_, _, err := processService(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test")
+
+		assert.NotNil(t, err)
+		updatedService, _ := kubeClientset.CoreV1().Services("mynamespace").Get(ctx, "myservice", metav1.GetOptions{})
+		assert.Equal(t, []string{finalizerCloudflareDNS}, updatedService.Finalizers)
+		assert.False(t, wasFinalized("Service", "mynamespace", "myservice"))
+	})
+
+	t.Run("KeepsFinalizerIfStoredRecordIsLeftBehindInPausedZone", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := getService(&metav1.Time{Time: time.Now()}, []string{finalizerCloudflareDNS})
+		kubeClientset := fake.NewSimpleClientset(service)
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com", "paused": true}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+		cf.skipPausedZones = true
+
+		// act
+		_, _, err := processService(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test")
+
+		assert.NotNil(t, err)
+		updatedService, _ := kubeClientset.CoreV1().Services("mynamespace").Get(ctx, "myservice", metav1.GetOptions{})
+		assert.Equal(t, []string{finalizerCloudflareDNS}, updatedService.Finalizers)
+		assert.False(t, wasFinalized("Service", "mynamespace", "myservice"))
+		fakeRESTClient.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("RemovesFinalizerWithWarningIfCredentialsSecretIsGone", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := getService(&metav1.Time{Time: time.Now()}, []string{finalizerCloudflareDNS})
+		service.Annotations[annotationCloudflareCredentialsSecret] = "team-credentials"
+		kubeClientset := fake.NewSimpleClientset(service)
+		secretLister = corelisters.NewSecretLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}))
+		defer func() { secretLister = nil }()
+
+		fakeRESTClient := new(fakeRESTClient)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+		recorder := record.NewFakeRecorder(10)
+
+		// act
+		status, _, err := processService(ctx, cf, kubeClientset, recorder, service, "test")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "skipped", status)
+		updatedService, _ := kubeClientset.CoreV1().Services("mynamespace").Get(ctx, "myservice", metav1.GetOptions{})
+		assert.Equal(t, 0, len(updatedService.Finalizers))
+		assert.True(t, wasFinalized("Service", "mynamespace", "myservice"))
+		if assert.Equal(t, 1, len(recorder.Events)) {
+			assert.Contains(t, <-recorder.Events, "Warning CredentialsSecretMissing Leaving dns records at Cloudflare")
+		}
+		fakeRESTClient.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+	})
+
+	t.Run("SkipsServiceBeingDeletedWithoutFinalizerIfCredentialsSecretIsGone", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := getService(&metav1.Time{Time: time.Now()}, []string{"other.io/finalizer"})
+		service.Annotations[annotationCloudflareCredentialsSecret] = "team-credentials"
+		kubeClientset := fake.NewSimpleClientset(service)
+		secretLister = corelisters.NewSecretLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}))
+		defer func() { secretLister = nil }()
+
+		cf := New(authentication)
+		cf.restClient = new(fakeRESTClient)
+
+		// act
+		status, _, err := processService(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "skipped", status)
+	})
+
+	t.Run("SkipsServiceBeingDeletedWithoutFinalizer", func(t *testing.T) {
+
+		ctx := context.Background()
+		service := getService(&metav1.Time{Time: time.Now()}, []string{"other.io/finalizer"})
+		kubeClientset := fake.NewSimpleClientset(service)
+
+		fakeRESTClient := new(fakeRESTClient)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		status, _, err := processService(ctx, cf, kubeClientset, record.NewFakeRecorder(10), service, "test")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "skipped", status)
+		fakeRESTClient.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+}
+
+func TestGetRecordsToDelete(t *testing.T) {
+
+	t.Run("ReturnsDesiredAndStoredRecordsWithoutDuplicates", func(t *testing.T) {
+
+		desiredRecords := []managedRecord{{Name: "www.example.com", Type: "A", Content: "1.2.3.4"}, {Name: "www.example.com", Type: "A", Content: "5.6.7.8"}}
+		storedRecords := []managedRecord{{Name: "www.example.com", Type: "A", Content: "1.2.3.4", Zone: "example.com"}, {Name: "old.example.com", Type: "A", Content: "1.2.3.4"}}
+
+		// act
+		records := getRecordsToDelete(desiredRecords, storedRecords)
+
+		assert.Equal(t, []managedRecord{{Name: "www.example.com", Type: "A", Content: "1.2.3.4"}, {Name: "www.example.com", Type: "A", Content: "5.6.7.8"}, {Name: "old.example.com", Type: "A", Content: "1.2.3.4"}}, records)
+	})
+}
+
+func TestRemoveNotOwnedRecords(t *testing.T) {
+
+	t.Run("LeavesOutRecordsWithNotOwnedNames", func(t *testing.T) {
+
+		records := []managedRecord{{Name: "origin.example.com", Type: "A", Content: "1.2.3.4"}, {Name: "www.example.com", Type: "CNAME", Content: "origin.example.com"}}
+
+		// act
+		ownedRecords := removeNotOwnedRecords(records, map[string]bool{"origin.example.com": true})
+
+		assert.Equal(t, []managedRecord{{Name: "www.example.com", Type: "CNAME", Content: "origin.example.com"}}, ownedRecords)
+	})
+}
+
+func TestDeleteService(t *testing.T) {
+
+	t.Run("DeletesRecordsOfHostnamesChangedSinceCreation", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+		service := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myservice",
+				Namespace: "mynamespace",
+				Annotations: map[string]string{
+					annotationCloudflareDNS:       "true",
+					annotationCloudflareHostnames: "new.example.com",
+					annotationCloudflareProxy:     "false",
+					annotationCloudflareState:     `{"enabled":"true","hostnames":"old.example.com","proxy":"false","ipAddress":"1.2.3.4"}`,
+				},
+			},
+			Spec: v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer},
+			Status: v1.ServiceStatus{
+				LoadBalancer: v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: "1.2.3.4"}}},
+			},
+		}
+
+		fakeRESTClient := new(fakeRESTClient)
+		for _, hostname := range []string{"new.example.com", "old.example.com"} {
+			fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name="+hostname, authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+		}
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=new.example.com&type=A", authentication).Return([]byte(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "new.example.com", "content": "1.2.3.4", "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}], "result_info": {"count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=old.example.com&type=A", authentication).Return([]byte(`{"success": true, "result": [{"id": "d6a6ef8c5d5ab3a6d5f3a8c5b5e1e4b2", "type": "A", "name": "old.example.com", "content": "1.2.3.4", "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}], "result_info": {"count": 1}}`), nil)
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return([]byte(`{"success": true}`), nil)
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/d6a6ef8c5d5ab3a6d5f3a8c5b5e1e4b2", authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		status, changes, err := deleteService(context.Background(), cf, fake.NewSimpleClientset(), record.NewFakeRecorder(10), service, "test")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "deleted", status)
+		assert.Equal(t, 2, changes)
+		fakeRESTClient.AssertCalled(t, "Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication)
+		fakeRESTClient.AssertCalled(t, "Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/d6a6ef8c5d5ab3a6d5f3a8c5b5e1e4b2", authentication)
+	})
+
+	t.Run("ReturnsErrorAndKeepsStateIfDeleteFailsAndDeletesRecordOnRetry", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+		service := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myservice",
+				Namespace: "retry-namespace",
+				Annotations: map[string]string{
+					annotationCloudflareDNS:       "true",
+					annotationCloudflareHostnames: "www.example.com",
+					annotationCloudflareProxy:     "false",
+					annotationCloudflareState:     `{"enabled":"true","hostnames":"www.example.com","proxy":"false","ipAddress":"1.2.3.4"}`,
+				},
+			},
+			Spec: v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer},
+			Status: v1.ServiceStatus{
+				LoadBalancer: v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: "1.2.3.4"}}},
+			},
+		}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return([]byte(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "www.example.com", "content": "1.2.3.4", "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}], "result_info": {"count": 1}}`), nil)
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return([]byte{}, errors.New("connection reset")).Once()
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		_, _, err := deleteService(context.Background(), cf, fake.NewSimpleClientset(), record.NewFakeRecorder(10), service, "test")
+
+		assert.NotNil(t, err)
+		assert.Equal(t, float64(1), testutil.ToFloat64(deleteFailuresTotals.With(prometheus.Labels{"namespace": "retry-namespace", "type": "service"})))
+
+		// act
+		status, changes, err := deleteService(context.Background(), cf, fake.NewSimpleClientset(), record.NewFakeRecorder(10), service, "test")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "deleted", status)
+		assert.Equal(t, 1, changes)
+		fakeRESTClient.AssertNumberOfCalls(t, "Delete", 2)
+	})
+
+	t.Run("SucceedsIfRecordIsGoneAlready", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+		service := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myservice",
+				Namespace: "mynamespace",
+				Annotations: map[string]string{
+					annotationCloudflareDNS:       "true",
+					annotationCloudflareHostnames: "www.example.com",
+					annotationCloudflareProxy:     "false",
+				},
+			},
+			Spec: v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer},
+			Status: v1.ServiceStatus{
+				LoadBalancer: v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: "1.2.3.4"}}},
+			},
+		}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"count": 0}}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		_, _, err := deleteService(context.Background(), cf, fake.NewSimpleClientset(), record.NewFakeRecorder(10), service, "test")
+
+		assert.Nil(t, err)
+		fakeRESTClient.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("DeletesRecordsOfAllLoadBalancerIPAddresses", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+		service := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myservice",
+				Namespace: "mynamespace",
+				Annotations: map[string]string{
+					annotationCloudflareDNS:       "true",
+					annotationCloudflareHostnames: "www.example.com",
+					annotationCloudflareProxy:     "false",
+				},
+			},
+			Spec: v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer},
+			Status: v1.ServiceStatus{
+				LoadBalancer: v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: "1.2.3.4"}, {IP: "5.6.7.8"}}},
+			},
+		}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return([]byte(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "www.example.com", "content": "1.2.3.4", "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}, {"id": "d6a6ef8c5d5ab3a6d5f3a8c5b5e1e4b2", "type": "A", "name": "www.example.com", "content": "5.6.7.8", "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}], "result_info": {"count": 2}}`), nil)
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return([]byte(`{"success": true}`), nil)
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/d6a6ef8c5d5ab3a6d5f3a8c5b5e1e4b2", authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		status, changes, err := deleteService(context.Background(), cf, fake.NewSimpleClientset(), record.NewFakeRecorder(10), service, "test")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "deleted", status)
+		assert.Equal(t, 2, changes)
+		fakeRESTClient.AssertCalled(t, "Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication)
+		fakeRESTClient.AssertCalled(t, "Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/d6a6ef8c5d5ab3a6d5f3a8c5b5e1e4b2", authentication)
+	})
+}
+
+func TestDeleteIngress(t *testing.T) {
+
+	t.Run("DeletesHostnameOriginAndInternalRecords", func(t *testing.T) {
+
+		authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+		ingress := &networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "myingress",
+				Namespace: "mynamespace",
+				Annotations: map[string]string{
+					annotationCloudflareDNS:                  "true",
+					annotationCloudflareHostnames:            "www.example.com",
+					annotationCloudflareProxy:                "false",
+					annotationCloudflareUseOriginRecord:      "true",
+					annotationCloudflareOriginRecordHostname: "origin.example.com",
+					annotationCloudflareInternalHostnames:    "internal.example.com",
+					annotationCloudflareInternalIPAddress:    "10.0.0.1",
+				},
+			},
+			Status: networkingv1.IngressStatus{
+				LoadBalancer: v1.LoadBalancerStatus{Ingress: []v1.LoadBalancerIngress{{IP: "1.2.3.4"}}},
+			},
+		}
+
+		fakeRESTClient := new(fakeRESTClient)
+		for _, hostname := range []string{"www.example.com", "origin.example.com", "internal.example.com"} {
+			fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name="+hostname, authentication).Return([]byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`), nil)
+		}
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=CNAME", authentication).Return([]byte(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "CNAME", "name": "www.example.com", "content": "origin.example.com", "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}], "result_info": {"count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=origin.example.com&type=A", authentication).Return([]byte(`{"success": true, "result": [{"id": "d6a6ef8c5d5ab3a6d5f3a8c5b5e1e4b2", "type": "A", "name": "origin.example.com", "content": "1.2.3.4", "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}], "result_info": {"count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=internal.example.com&type=A", authentication).Return([]byte(`{"success": true, "result": [{"id": "9a7806061c88ada191ed06f989cc3dac", "type": "A", "name": "internal.example.com", "content": "10.0.0.1", "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}], "result_info": {"count": 1}}`), nil)
+		fakeRESTClient.On("Delete", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		status, changes, err := deleteIngress(context.Background(), cf, fake.NewSimpleClientset(), record.NewFakeRecorder(10), ingress, "test")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "deleted", status)
+		assert.Equal(t, 3, changes)
+		fakeRESTClient.AssertCalled(t, "Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication)
+		fakeRESTClient.AssertCalled(t, "Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/d6a6ef8c5d5ab3a6d5f3a8c5b5e1e4b2", authentication)
+		fakeRESTClient.AssertCalled(t, "Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/9a7806061c88ada191ed06f989cc3dac", authentication)
+	})
+}
+
+func TestGetDNSRecordSpec(t *testing.T) {
+
+	newDNSRecord := func(spec map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "estafette.io/v1",
+			"kind":       "DNSRecord",
+			"metadata":   map[string]interface{}{"name": "myrecord", "namespace": "mynamespace"},
+			"spec":       spec,
+		}}
+	}
+
+	t.Run("ReturnsSpecWithNormalizedTypeNameAndContent", func(t *testing.T) {
+
+		// act
+		spec, err := getDNSRecordSpec(newDNSRecord(map[string]interface{}{"type": "aaaa", "name": "www.example.com.", "content": "[2001:0db8::1]", "proxied": true, "ttl": int64(300)}))
+
+		assert.Nil(t, err)
+		assert.Equal(t, DNSRecordSpec{Type: "AAAA", Name: "www.example.com", Content: "2001:db8::1", Proxied: true, TTL: 300}, spec)
+	})
+
+	t.Run("ReturnsErrorIfIPAddressDoesNotMatchRecordType", func(t *testing.T) {
+
+		// act
+		_, err := getDNSRecordSpec(newDNSRecord(map[string]interface{}{"type": "A", "name": "www.example.com", "content": "2001:db8::1"}))
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorIfRecordTypeIsNotSupported", func(t *testing.T) {
+
+		// act
+		_, err := getDNSRecordSpec(newDNSRecord(map[string]interface{}{"type": "MX", "name": "example.com", "content": "mail.example.com"}))
+
+		assert.NotNil(t, err)
+	})
+
+	t.Run("ReturnsErrorIfNameIsNotAValidHostname", func(t *testing.T) {
+
+		// act
+		_, err := getDNSRecordSpec(newDNSRecord(map[string]interface{}{"type": "CNAME", "name": "www", "content": "example.com"}))
+
+		assert.NotNil(t, err)
+	})
+}
+
+func TestProcessDNSRecordResource(t *testing.T) {
+
+	authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+	noDNSRecordsResult := []byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`)
+	dnsRecordResult := []byte(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "old.example.com", "content": "1.2.3.4", "comment": "managed by estafette-cloudflare-dns", "zone_id": "023e105f4ecef8ad9ca31a8372d0c353", "zone_name": "example.com"}], "result_info": {"per_page": 20, "count": 1}}`)
+	createResult := []byte(`{"success": true, "result": {"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "www.example.com", "content": "1.2.3.4", "comment": "managed by estafette-cloudflare-dns", "zone_id": "023e105f4ecef8ad9ca31a8372d0c353", "zone_name": "example.com"}}`)
+
+	newDNSRecord := func(status map[string]interface{}) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "estafette.io/v1",
+			"kind":       "DNSRecord",
+			"metadata":   map[string]interface{}{"name": "myrecord", "namespace": "mynamespace"},
+			"spec":       map[string]interface{}{"type": "A", "name": "www.example.com", "content": "1.2.3.4"},
+		}}
+		if status != nil {
+			obj.Object["status"] = status
+		}
+		return obj
+	}
+	newDynamicClient := func(obj *unstructured.Unstructured) *dynamicfake.FakeDynamicClient {
+		return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(apiruntime.NewScheme(), map[schema.GroupVersionResource]string{dnsRecordsResource: "DNSRecordList"}, obj)
+	}
+	newCloudflare := func(fakeRESTClient *fakeRESTClient) *Cloudflare {
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+		cf.zone = &Zone{ID: "023e105f4ecef8ad9ca31a8372d0c353", Name: "example.com"}
+		cf.ownershipMarker = defaultCloudflareComment
+		return cf
+	}
+
+	t.Run("CreatesRecordAndStoresItsIDInStatus", func(t *testing.T) {
+
+		ctx := context.Background()
+		obj := newDNSRecord(nil)
+		dynamicClient := newDynamicClient(obj)
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return(createResult, nil)
+
+		// act
+		status, changes, err := processDNSRecordResource(ctx, newCloudflare(fakeRESTClient), dynamicClient, record.NewFakeRecorder(10), obj, "test")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "succeeded", status)
+		assert.Equal(t, 1, changes)
+		fakeRESTClient.AssertNumberOfCalls(t, "Post", 1)
+
+		updated, err := dynamicClient.Resource(dnsRecordsResource).Namespace("mynamespace").Get(ctx, "myrecord", metav1.GetOptions{})
+		assert.Nil(t, err)
+		assert.Equal(t, DNSRecordStatus{RecordID: "372e67954025e0ba6aaa6d586b9e0b59", ZoneID: "023e105f4ecef8ad9ca31a8372d0c353", ZoneName: "example.com", Name: "www.example.com"}, getDNSRecordStatus(updated))
+	})
+
+	t.Run("SkipsRecordIfStatusIsUpToDate", func(t *testing.T) {
+
+		ctx := context.Background()
+		obj := newDNSRecord(map[string]interface{}{"recordId": "372e67954025e0ba6aaa6d586b9e0b59", "name": "www.example.com"})
+
+		fakeRESTClient := new(fakeRESTClient)
+
+		// act
+		status, changes, err := processDNSRecordResource(ctx, newCloudflare(fakeRESTClient), newDynamicClient(obj), record.NewFakeRecorder(10), obj, "test")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "skipped", status)
+		assert.Equal(t, 0, changes)
+		fakeRESTClient.AssertNotCalled(t, "Get", mock.Anything, mock.Anything)
+	})
+
+	t.Run("DeletesRecordOfPreviousNameAfterRename", func(t *testing.T) {
+
+		ctx := context.Background()
+		obj := newDNSRecord(map[string]interface{}{"recordId": "372e67954025e0ba6aaa6d586b9e0b59", "zoneId": "023e105f4ecef8ad9ca31a8372d0c353", "zoneName": "example.com", "name": "old.example.com"})
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=old.example.com", authentication).Return(dnsRecordResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return([]byte(`{"success": true}`), nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return(createResult, nil)
+
+		// act
+		status, changes, err := processDNSRecordResource(ctx, newCloudflare(fakeRESTClient), newDynamicClient(obj), record.NewFakeRecorder(10), obj, "test")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "succeeded", status)
+		assert.Equal(t, 2, changes)
+		fakeRESTClient.AssertNumberOfCalls(t, "Delete", 1)
+		fakeRESTClient.AssertNumberOfCalls(t, "Post", 1)
+	})
+
+	t.Run("ReturnsErrorForInvalidSpecWithoutCallingCloudflare", func(t *testing.T) {
+
+		ctx := context.Background()
+		obj := newDNSRecord(nil)
+		obj.Object["spec"] = map[string]interface{}{"type": "A", "name": "www.example.com", "content": "not-an-ip"}
+
+		fakeRESTClient := new(fakeRESTClient)
+
+		// act
+		status, _, err := processDNSRecordResource(ctx, newCloudflare(fakeRESTClient), newDynamicClient(obj), record.NewFakeRecorder(10), obj, "test")
+
+		assert.NotNil(t, err)
+		assert.Equal(t, "failed", status)
+		fakeRESTClient.AssertNotCalled(t, "Post", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestDeleteDNSRecordResource(t *testing.T) {
+
+	authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+
+	t.Run("DeletesRecordByNameAndZoneInStatus", func(t *testing.T) {
+
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "estafette.io/v1",
+			"kind":       "DNSRecord",
+			"metadata":   map[string]interface{}{"name": "myrecord", "namespace": "mynamespace"},
+			"spec":       map[string]interface{}{"type": "A", "name": "www.example.com", "content": "1.2.3.4"},
+			"status":     map[string]interface{}{"recordId": "372e67954025e0ba6aaa6d586b9e0b59", "zoneId": "023e105f4ecef8ad9ca31a8372d0c353", "zoneName": "example.com", "name": "www.example.com"},
+		}}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "www.example.com", "content": "1.2.3.4", "comment": "managed by estafette-cloudflare-dns", "zone_id": "023e105f4ecef8ad9ca31a8372d0c353"}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return([]byte(`{"success": true}`), nil)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+		cf.ownershipMarker = defaultCloudflareComment
+
+		// act
+		status, changes, err := deleteDNSRecordResource(context.Background(), cf, record.NewFakeRecorder(10), obj, "test")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "deleted", status)
+		assert.Equal(t, 1, changes)
+		fakeRESTClient.AssertNumberOfCalls(t, "Delete", 1)
+	})
+
+	t.Run("SkipsObjectWhoseRecordNeverGotCreated", func(t *testing.T) {
+
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "estafette.io/v1",
+			"kind":       "DNSRecord",
+			"metadata":   map[string]interface{}{"name": "myrecord", "namespace": "mynamespace"},
+		}}
+
+		fakeRESTClient := new(fakeRESTClient)
+
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+
+		// act
+		status, changes, err := deleteDNSRecordResource(context.Background(), cf, record.NewFakeRecorder(10), obj, "test")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "skipped", status)
+		assert.Equal(t, 0, changes)
+		fakeRESTClient.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+}
+
+func TestHTTPRoutes(t *testing.T) {
+
+	authentication := APIAuthentication{Key: "r2kjepva04hijzv18u3e9ntphs79kctdxxj5w", Email: "name@server.com"}
+	zonesResult := []byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com"}], "result_info": {"per_page": 20, "count": 1}}`)
+	noZonesResult := []byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`)
+	noDNSRecordsResult := []byte(`{"success": true, "result": [], "result_info": {"per_page": 20, "count": 0}}`)
+	dnsRecordResult := []byte(`{"success": true, "result": [{"id": "372e67954025e0ba6aaa6d586b9e0b59", "type": "A", "name": "www.example.com", "content": "1.2.3.4", "proxiable": true, "proxied": false, "ttl": 1, "comment": "managed by estafette-cloudflare-dns", "zone_id": "023e105f4ecef8ad9ca31a8372d0c353", "zone_name": "example.com"}], "result_info": {"per_page": 20, "count": 1}}`)
+
+	newHTTPRoute := func(annotations map[string]interface{}, parentRefs ...interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "gateway.networking.k8s.io/v1",
+			"kind":       "HTTPRoute",
+			"metadata":   map[string]interface{}{"name": "myroute", "namespace": "mynamespace", "annotations": annotations},
+			"spec":       map[string]interface{}{"hostnames": []interface{}{"www.example.com"}, "parentRefs": parentRefs},
+		}}
+	}
+	newGateway := func(name, namespace string, addresses ...interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "gateway.networking.k8s.io/v1",
+			"kind":       "Gateway",
+			"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+			"status":     map[string]interface{}{"addresses": addresses},
+		}}
+	}
+	newDynamicClient := func(objects ...*unstructured.Unstructured) *dynamicfake.FakeDynamicClient {
+		dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(apiruntime.NewScheme(), map[schema.GroupVersionResource]string{httpRoutesResource: "HTTPRouteList", gatewaysResource: "GatewayList"})
+		for _, obj := range objects {
+			// add objects by their resource, because the tracker guesses gateways to be called gatewaies
+			resource := httpRoutesResource
+			if obj.GetKind() == "Gateway" {
+				resource = gatewaysResource
+			}
+			if err := dynamicClient.Tracker().Create(resource, obj, obj.GetNamespace()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dynamicClient
+	}
+	newCloudflare := func(fakeRESTClient *fakeRESTClient) *Cloudflare {
+		cf := New(authentication)
+		cf.restClient = fakeRESTClient
+		cf.ownershipMarker = defaultCloudflareComment
+		return cf
+	}
+	countGatewayGets := func(dynamicClient *dynamicfake.FakeDynamicClient) (gets int) {
+		for _, action := range dynamicClient.Actions() {
+			if action.GetVerb() == "get" && action.GetResource() == gatewaysResource {
+				gets++
+			}
+		}
+		return
+	}
+
+	t.Run("GetHTTPRouteGatewayIPAddressReturnsIPAddressOfGatewayInNamespaceOfRoute", func(t *testing.T) {
+
+		route := newHTTPRoute(nil, map[string]interface{}{"name": "mygateway"})
+		dynamicClient := newDynamicClient(route, newGateway("mygateway", "mynamespace", map[string]interface{}{"type": "Hostname", "value": "gateway.example.com"}, map[string]interface{}{"type": "IPAddress", "value": "1.2.3.4"}))
+
+		// act
+		ipAddress, err := getHTTPRouteGatewayIPAddress(context.Background(), dynamicClient, route)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "1.2.3.4", ipAddress)
+	})
+
+	t.Run("GetHTTPRouteGatewayIPAddressReturnsIPAddressOfGatewayInOtherNamespace", func(t *testing.T) {
+
+		route := newHTTPRoute(nil, map[string]interface{}{"kind": "Service", "name": "mygateway"}, map[string]interface{}{"kind": "Gateway", "name": "sharedgateway", "namespace": "gateways"})
+		dynamicClient := newDynamicClient(route, newGateway("sharedgateway", "gateways", map[string]interface{}{"value": "5.6.7.8"}))
+
+		// act
+		ipAddress, err := getHTTPRouteGatewayIPAddress(context.Background(), dynamicClient, route)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "5.6.7.8", ipAddress)
+	})
+
+	t.Run("GetHTTPRouteGatewayIPAddressReturnsErrorIfGatewayIsMissing", func(t *testing.T) {
+
+		route := newHTTPRoute(nil, map[string]interface{}{"name": "mygateway"})
+		dynamicClient := newDynamicClient(route)
+
+		// act
+		ipAddress, err := getHTTPRouteGatewayIPAddress(context.Background(), dynamicClient, route)
+
+		assert.True(t, apierrors.IsNotFound(err))
+		assert.Equal(t, "", ipAddress)
+	})
+
+	t.Run("GetHTTPRouteGatewayIPAddressReturnsErrorIfGatewayHasNoIPAddress", func(t *testing.T) {
+
+		route := newHTTPRoute(nil, map[string]interface{}{"name": "mygateway"})
+		dynamicClient := newDynamicClient(route, newGateway("mygateway", "mynamespace"))
+
+		// act
+		ipAddress, err := getHTTPRouteGatewayIPAddress(context.Background(), dynamicClient, route)
+
+		assert.NotNil(t, err)
+		assert.Equal(t, "", ipAddress)
+	})
+
+	t.Run("GetDesiredHTTPRouteStateTakesHostnamesFromSpecAndIPAddressFromGateway", func(t *testing.T) {
+
+		route := newHTTPRoute(map[string]interface{}{annotationCloudflareDNS: "true"}, map[string]interface{}{"name": "mygateway"})
+		dynamicClient := newDynamicClient(route, newGateway("mygateway", "mynamespace", map[string]interface{}{"type": "IPAddress", "value": "1.2.3.4"}))
+
+		// act
+		state := getDesiredHTTPRouteState(context.Background(), dynamicClient, route)
+
+		assert.Equal(t, "true", state.Enabled)
+		assert.Equal(t, "www.example.com", state.Hostnames)
+		assert.Equal(t, "1.2.3.4", state.IPAddress)
+		assert.Equal(t, 1, countGatewayGets(dynamicClient))
+	})
+
+	t.Run("GetDesiredHTTPRouteStateLeavesIPAddressEmptyIfGatewayIsMissing", func(t *testing.T) {
+
+		route := newHTTPRoute(map[string]interface{}{annotationCloudflareDNS: "true", annotationCloudflareHostnames: "api.example.com"}, map[string]interface{}{"name": "mygateway"})
+		dynamicClient := newDynamicClient(route)
+
+		// act
+		state := getDesiredHTTPRouteState(context.Background(), dynamicClient, route)
+
+		assert.Equal(t, "true", state.Enabled)
+		assert.Equal(t, "api.example.com", state.Hostnames)
+		assert.Equal(t, "", state.IPAddress)
+	})
+
+	t.Run("ProcessHTTPRouteSkipsRouteWhoseGatewayIsMissing", func(t *testing.T) {
+
+		route := newHTTPRoute(map[string]interface{}{annotationCloudflareDNS: "true"}, map[string]interface{}{"name": "mygateway"})
+		dynamicClient := newDynamicClient(route)
+		fakeRESTClient := new(fakeRESTClient)
+
+		// act
+		status, changes, err := processHTTPRoute(context.Background(), newCloudflare(fakeRESTClient), dynamicClient, record.NewFakeRecorder(10), route, "test")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "skipped", status)
+		assert.Equal(t, 0, changes)
+		fakeRESTClient.AssertNotCalled(t, "Post", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("ProcessHTTPRouteWithDesiredStateDoesNotRetrieveGatewayAgain", func(t *testing.T) {
+
+		route := newHTTPRoute(map[string]interface{}{annotationCloudflareDNS: "true"}, map[string]interface{}{"name": "mygateway"})
+		dynamicClient := newDynamicClient(route, newGateway("mygateway", "mynamespace", map[string]interface{}{"type": "IPAddress", "value": "1.2.3.4"}))
+		desiredState := CloudflareState{Enabled: "false"}
+
+		// act
+		status, _, err := processHTTPRouteWithDesiredState(context.Background(), newCloudflare(new(fakeRESTClient)), dynamicClient, record.NewFakeRecorder(10), route, "test", desiredState, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "skipped", status)
+		assert.Equal(t, 0, countGatewayGets(dynamicClient))
+	})
+
+	t.Run("MakeHTTPRouteChangesUpsertsRecordToGatewayIPAddressAndStoresStateInAnnotation", func(t *testing.T) {
+
+		ctx := context.Background()
+		route := newHTTPRoute(map[string]interface{}{annotationCloudflareDNS: "true"}, map[string]interface{}{"name": "mygateway"})
+		dynamicClient := newDynamicClient(route)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "false", UseOriginRecord: "false", IPAddress: "1.2.3.4"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(noDNSRecordsResult, nil).Once()
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(dnsRecordResult, nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+
+		// act
+		status, changes, err := makeHTTPRouteChanges(ctx, newCloudflare(fakeRESTClient), dynamicClient, record.NewFakeRecorder(10), route, "test", desiredState, CloudflareState{}, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "succeeded", status)
+		assert.Equal(t, 1, changes)
+		fakeRESTClient.AssertNumberOfCalls(t, "Post", 1)
+
+		patchedRoute, err := dynamicClient.Resource(httpRoutesResource).Namespace("mynamespace").Get(ctx, "myroute", metav1.GetOptions{})
+		assert.Nil(t, err)
+		var storedState CloudflareState
+		err = decodeStateAnnotation(patchedRoute.GetAnnotations()[annotationCloudflareState], &storedState)
+		assert.Nil(t, err)
+		assert.Equal(t, "true", storedState.Enabled)
+		assert.Equal(t, "1.2.3.4", storedState.IPAddress)
+	})
+
+	t.Run("MakeHTTPRouteChangesDeletesRecordsAndRemovesStateAnnotationWhenDnsIsDisabled", func(t *testing.T) {
+
+		ctx := context.Background()
+		route := newHTTPRoute(map[string]interface{}{annotationCloudflareDNS: "false", annotationCloudflareState: `{"enabled":"true"}`})
+		dynamicClient := newDynamicClient(route)
+		currentState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "false", UseOriginRecord: "false", IPAddress: "1.2.3.4"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(dnsRecordResult, nil)
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return([]byte(`{"success": true}`), nil)
+
+		// act
+		status, changes, err := makeHTTPRouteChanges(ctx, newCloudflare(fakeRESTClient), dynamicClient, record.NewFakeRecorder(10), route, "test", CloudflareState{Enabled: "false"}, currentState, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "deleted", status)
+		assert.Equal(t, 1, changes)
+		fakeRESTClient.AssertNumberOfCalls(t, "Delete", 1)
+
+		patchedRoute, err := dynamicClient.Resource(httpRoutesResource).Namespace("mynamespace").Get(ctx, "myroute", metav1.GetOptions{})
+		assert.Nil(t, err)
+		assert.NotContains(t, patchedRoute.GetAnnotations(), annotationCloudflareState)
+	})
+
+	t.Run("MakeHTTPRouteChangesKeepsRecordDnsOnlyInZoneOnOtherThanProxyPlans", func(t *testing.T) {
+
+		ctx := context.Background()
+		route := newHTTPRoute(map[string]interface{}{annotationCloudflareDNS: "true"}, map[string]interface{}{"name": "mygateway"})
+		dynamicClient := newDynamicClient(route)
+		desiredState := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "true", ProxyPlans: "pro,business", UseOriginRecord: "false", IPAddress: "1.2.3.4"}
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return([]byte(`{"success": true, "result": [{"id": "023e105f4ecef8ad9ca31a8372d0c353", "name": "example.com", "plan": {"name": "Free Website", "legacy_id": "free"}}], "result_info": {"per_page": 20, "count": 1}}`), nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(noDNSRecordsResult, nil).Once()
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com", authentication).Return(noDNSRecordsResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(dnsRecordResult, nil)
+		fakeRESTClient.On("Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.Anything, authentication).Return([]byte(`{"success": true}`), nil)
+		recorder := record.NewFakeRecorder(10)
+
+		// act
+		status, _, err := makeHTTPRouteChanges(ctx, newCloudflare(fakeRESTClient), dynamicClient, recorder, route, "test", desiredState, CloudflareState{}, nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "succeeded", status)
+		fakeRESTClient.AssertCalled(t, "Post", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records", mock.MatchedBy(func(r DNSRecord) bool { return r.Name == "www.example.com" && !r.Proxied }), authentication)
+		assert.Contains(t, <-recorder.Events, "Warning ProxyDowngraded Zone of dns record www.example.com is on plan free")
+	})
+
+	t.Run("DeleteHTTPRouteDeletesRecordsInStoredStateWithoutRetrievingGateway", func(t *testing.T) {
+
+		route := newHTTPRoute(map[string]interface{}{annotationCloudflareState: `{"enabled":"true","hostnames":"www.example.com","ipAddress":"1.2.3.4"}`}, map[string]interface{}{"name": "mygateway"})
+		dynamicClient := newDynamicClient()
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return(dnsRecordResult, nil)
+		fakeRESTClient.On("Delete", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/372e67954025e0ba6aaa6d586b9e0b59", authentication).Return([]byte(`{"success": true}`), nil)
+
+		// act
+		status, changes, err := deleteHTTPRoute(context.Background(), newCloudflare(fakeRESTClient), record.NewFakeRecorder(10), route, "test")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "deleted", status)
+		assert.Equal(t, 1, changes)
+		fakeRESTClient.AssertNumberOfCalls(t, "Delete", 1)
+		assert.Equal(t, 0, countGatewayGets(dynamicClient))
+	})
+
+	t.Run("DeleteHTTPRouteKeepsStateIfDeletingFails", func(t *testing.T) {
+
+		route := newHTTPRoute(map[string]interface{}{annotationCloudflareState: `{"enabled":"true","hostnames":"www.example.com","ipAddress":"1.2.3.4"}`})
+
+		fakeRESTClient := new(fakeRESTClient)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=www.example.com", authentication).Return(noZonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/?name=example.com", authentication).Return(zonesResult, nil)
+		fakeRESTClient.On("Get", "https://api.cloudflare.com/client/v4/zones/023e105f4ecef8ad9ca31a8372d0c353/dns_records/?name=www.example.com&type=A", authentication).Return([]byte(nil), errors.New("Service unavailable"))
+
+		// act
+		status, changes, err := deleteHTTPRoute(context.Background(), newCloudflare(fakeRESTClient), record.NewFakeRecorder(10), route, "test")
+
+		assert.NotNil(t, err)
+		assert.Equal(t, "failed", status)
+		assert.Equal(t, 0, changes)
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"sort"
+
+	"github.com/rs/zerolog/log"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+// nodeLister is used to look up the external ip address of a node for NodePort services; they don't get an ip address if nil
+var nodeLister corelisters.NodeLister
+
+// initNodeLister starts an informer for the nodes of the cluster, so NodePort services can point at them without an api call per reconcile
+func initNodeLister(ctx context.Context, kubeClientset kubernetes.Interface, stopper chan struct{}) error {
+
+	factory := informers.NewSharedInformerFactory(kubeClientset, 0)
+	nodesInformer := factory.Core().V1().Nodes()
+	nodeLister = nodesInformer.Lister()
+
+	informer := nodesInformer.Informer()
+	factory.Start(stopper)
+
+	return waitForInformerCacheSync(ctx, "node", informer, stopper)
+}
+
+// getNodeExternalIPAddress returns the external ip address of the first ready node, by name so it doesn't change between reconciles
+func getNodeExternalIPAddress() string {
+
+	if nodeLister == nil {
+		return ""
+	}
+
+	nodes, err := nodeLister.List(labels.Everything())
+	if err != nil {
+		log.Error().Err(err).Msg("Listing nodes failed")
+		return ""
+	}
+
+	return getExternalIPAddressOfFirstReadyNode(nodes)
+}
+
+func getExternalIPAddressOfFirstReadyNode(nodes []*v1.Node) string {
+
+	sortedNodes := make([]*v1.Node, len(nodes))
+	copy(sortedNodes, nodes)
+	sort.Slice(sortedNodes, func(i, j int) bool {
+		return sortedNodes[i].Name < sortedNodes[j].Name
+	})
+
+	for _, node := range sortedNodes {
+		if !isNodeReady(node) {
+			continue
+		}
+		for _, address := range node.Status.Addresses {
+			if address.Type == v1.NodeExternalIP && address.Address != "" {
+				return address.Address
+			}
+		}
+	}
+
+	return ""
+}
+
+func isNodeReady(node *v1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+
+	return false
+}
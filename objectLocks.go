@@ -0,0 +1,55 @@
+package main
+
+import (
+	"sync"
+)
+
+// objectLocks makes sure a single object only gets reconciled by one goroutine at a time, for example when an informer event and the poller arrive at once.
+type objectLocks struct {
+	mutex sync.Mutex
+	locks map[string]*objectLock
+}
+
+type objectLock struct {
+	mutex sync.Mutex
+	users int
+}
+
+func newObjectLocks() *objectLocks {
+	return &objectLocks{
+		locks: map[string]*objectLock{},
+	}
+}
+
+func getObjectLockKey(objectType, namespace, name string) string {
+	return objectType + "/" + namespace + "/" + name
+}
+
+// lock waits until no other goroutine holds the lock for the object and returns the function to release it again.
+func (l *objectLocks) lock(objectType, namespace, name string) (unlock func()) {
+
+	key := getObjectLockKey(objectType, namespace, name)
+
+	l.mutex.Lock()
+	lock, ok := l.locks[key]
+	if !ok {
+		lock = &objectLock{}
+		l.locks[key] = lock
+	}
+	lock.users++
+	l.mutex.Unlock()
+
+	lock.mutex.Lock()
+
+	return func() {
+		lock.mutex.Unlock()
+
+		// drop the lock once nobody uses it anymore, so deleted objects don't leave it behind
+		l.mutex.Lock()
+		lock.users--
+		if lock.users == 0 {
+			delete(l.locks, key)
+		}
+		l.mutex.Unlock()
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// maxReconcileRetries is the number of times a failed reconcile gets retried before leaving the object to the poller
+const maxReconcileRetries = 5
+
+// objectQueue hands the keys of changed objects to workers that reconcile them; events for an object that is already queued get merged and failed reconciles are retried with exponential backoff.
+type objectQueue struct {
+	objectType string
+	queue      workqueue.RateLimitingInterface
+	indexer    cache.Indexer
+
+	// deleted objects are gone from the informer cache by the time a worker picks them up, so keep their last known version
+	deletedObjects sync.Map
+
+	reconcile func(obj interface{}) (status string, changes int, err error)
+	delete    func(obj interface{}) (status string, changes int, err error)
+}
+
+func newObjectQueue(objectType string, indexer cache.Indexer, reconcile, delete func(obj interface{}) (string, int, error)) *objectQueue {
+	return &objectQueue{
+		objectType: objectType,
+		queue:      workqueue.NewNamedRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(5*time.Second, 5*time.Minute), objectType+"s"),
+		indexer:    indexer,
+		reconcile:  reconcile,
+		delete:     delete,
+	}
+}
+
+// handlers returns the informer event handlers that add the keys of changed objects to the queue
+func (q *objectQueue) handlers() cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: q.enqueue,
+		UpdateFunc: func(oldObj interface{}, newObj interface{}) {
+			q.enqueue(newObj)
+		},
+		DeleteFunc: q.enqueueDeleted,
+	}
+}
+
+func (q *objectQueue) enqueue(obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Watcher for %vs returns event object without key", q.objectType)
+		return
+	}
+
+	q.queue.Add(key)
+}
+
+func (q *objectQueue) enqueueDeleted(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		log.Warn().Err(err).Msgf("Watcher for %vs returns event object without key", q.objectType)
+		return
+	}
+
+	q.deletedObjects.Store(key, unwrapDeletedObject(obj))
+	q.queue.Add(key)
+}
+
+// run starts the workers and shuts the queue down once the stopper gets closed
+func (q *objectQueue) run(workers int, waitGroup *sync.WaitGroup, stopper chan struct{}) {
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for q.processNextItem(waitGroup) {
+			}
+		}()
+	}
+
+	go func() {
+		<-stopper
+		q.queue.ShutDown()
+	}()
+}
+
+// processNextItem reconciles the next object in the queue and returns false once the queue has been shut down
+func (q *objectQueue) processNextItem(waitGroup *sync.WaitGroup) bool {
+
+	item, shutdown := q.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer q.queue.Done(item)
+
+	waitGroup.Add(1)
+	defer waitGroup.Done()
+
+	key := item.(string)
+
+	err := q.reconcileKey(key)
+	if err != nil {
+		// retry rate limited objects once cloudflare allows it again; that's not the object's fault, so it doesn't count as a retry
+		var rateLimitedErr *rateLimitedError
+		if errors.As(err, &rateLimitedErr) {
+			log.Warn().Err(err).Msgf("Reconciling %v %v got rate limited, retrying after %v...", q.objectType, key, rateLimitedErr.retryAfter)
+			q.queue.AddAfter(key, rateLimitedErr.retryAfter)
+			return true
+		}
+
+		// the poller never sees deleted objects, so keep retrying to delete their records instead of giving up
+		_, deletePending := q.deletedObjects.Load(key)
+		if deletePending || q.queue.NumRequeues(key) < maxReconcileRetries {
+			log.Warn().Err(err).Msgf("Reconciling %v %v failed, retrying with backoff...", q.objectType, key)
+			q.queue.AddRateLimited(key)
+			return true
+		}
+
+		log.Error().Err(err).Msgf("Reconciling %v %v failed %v times, leaving it to the poller", q.objectType, key, maxReconcileRetries+1)
+	}
+
+	q.queue.Forget(key)
+
+	return true
+}
+
+// reconcileKey cleans up the records of a deleted object with that key, then reconciles the current object, if it exists
+func (q *objectQueue) reconcileKey(key string) error {
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	if deletedObj, ok := q.deletedObjects.Load(key); ok {
+		status, changes, err := reconcileObject(q.objectType, name, namespace, func() (string, int, error) {
+			return q.delete(deletedObj)
+		})
+		countDNSRecordsTotals(namespace, status, "watcher", q.objectType, changes)
+		if err != nil {
+			return fmt.Errorf("Deleting %v %v failed: %w", q.objectType, key, err)
+		}
+
+		q.deletedObjects.Delete(key)
+	}
+
+	obj, exists, err := q.indexer.GetByKey(key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+
+	status, changes, err := reconcileObject(q.objectType, name, namespace, func() (string, int, error) {
+		return q.reconcile(obj)
+	})
+	countDNSRecordsTotals(namespace, status, "watcher", q.objectType, changes)
+	if err != nil {
+		return fmt.Errorf("Processing %v %v failed: %w", q.objectType, key, err)
+	}
+
+	return nil
+}
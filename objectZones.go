@@ -0,0 +1,126 @@
+package main
+
+import "strings"
+
+// zoneApexChecker tells whether a dns name is the apex of its zone, which both Cloudflare and objectZones do
+type zoneApexChecker interface {
+	IsZoneApex(dnsName string) bool
+}
+
+// objectZones looks up the zones of the records of a single object once, so its origin record and hostnames in the same zone don't each resolve the zone again for every record operation.
+type objectZones struct {
+	cf    *Cloudflare
+	zones map[string]Zone
+}
+
+func newObjectZones(cf *Cloudflare) *objectZones {
+	return &objectZones{cf: cf, zones: map[string]Zone{}}
+}
+
+// getZone returns the zone looked up before for the exact name or for which the name is the apex, or looks it up otherwise; a cached parent zone isn't reused for other names, since they can be in a separate subzone. Failed lookups aren't remembered, so they get retried for the next name
+func (z *objectZones) getZone(dnsName string) (Zone, error) {
+
+	asciiName := strings.ToLower(toASCIIHostname(dnsName))
+	if zone, ok := z.zones[asciiName]; ok {
+		return zone, nil
+	}
+
+	zone, err := z.cf.GetZoneByDNSName(dnsName)
+	if err != nil {
+		return zone, err
+	}
+
+	if zone.Name != "" {
+		z.zones[asciiName] = zone
+		z.zones[strings.ToLower(zone.Name)] = zone
+	}
+
+	return zone, nil
+}
+
+// IsZoneApex returns true if the dns name is the name of the zone it's in; it returns false if the zone can't be found or its name isn't known
+func (z *objectZones) IsZoneApex(dnsName string) bool {
+
+	zone, err := z.getZone(dnsName)
+	if err != nil || zone.Name == "" {
+		return false
+	}
+
+	return strings.EqualFold(toASCIIHostname(dnsName), zone.Name)
+}
+
+func (z *objectZones) upsertDNSRecord(dnsRecordType, dnsRecordName, dnsRecordContent string, proxy bool, dnsRecordComment, dnsRecordRegion string, ttl int) (DNSRecord, bool, error) {
+
+	zone, err := z.getZone(dnsRecordName)
+	if err != nil {
+		return DNSRecord{}, false, err
+	}
+
+	return z.cf.UpsertDNSRecordByZone(zone, dnsRecordType, dnsRecordName, dnsRecordContent, proxy, dnsRecordComment, dnsRecordRegion, ttl)
+}
+
+func (z *objectZones) upsertDNSRecordSet(dnsRecordType, dnsRecordName string, dnsRecordContents []string, proxy bool, dnsRecordComment, dnsRecordRegion string, ttl int) ([]DNSRecord, bool, error) {
+
+	zone, err := z.getZone(dnsRecordName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return z.cf.UpsertDNSRecordSetByZone(zone, dnsRecordType, dnsRecordName, dnsRecordContents, proxy, dnsRecordComment, dnsRecordRegion, ttl)
+}
+
+func (z *objectZones) updateProxySetting(dnsRecordType, dnsRecordName string, proxy bool) (DNSRecord, error) {
+
+	zone, err := z.getZone(dnsRecordName)
+	if err != nil {
+		return DNSRecord{}, err
+	}
+
+	return z.cf.UpdateProxySettingByZone(zone, dnsRecordType, dnsRecordName, proxy)
+}
+
+// isDNSRecordProxiable returns true if the existing record can be proxied according to cloudflare, and false if it can't or doesn't exist yet
+func (z *objectZones) isDNSRecordProxiable(dnsRecordName string) bool {
+
+	zone, err := z.getZone(dnsRecordName)
+	if err != nil {
+		return false
+	}
+
+	r, err := z.cf.GetDNSRecordByZone(zone, dnsRecordName)
+	if err != nil {
+		return false
+	}
+
+	return r.Proxiable
+}
+
+func (z *objectZones) upsertStructuredDNSRecords(dnsRecordType, dnsRecordName string, dnsRecordsData []interface{}, dnsRecordComment string) ([]DNSRecord, error) {
+
+	zone, err := z.getZone(dnsRecordName)
+	if err != nil {
+		return nil, err
+	}
+
+	return z.cf.UpsertStructuredDNSRecordsByZone(zone, dnsRecordType, dnsRecordName, dnsRecordsData, dnsRecordComment)
+}
+
+func (z *objectZones) upsertCAARecords(dnsRecordName string, caaRecordsData []CAARecordData, dnsRecordComment string) ([]DNSRecord, error) {
+
+	zone, err := z.getZone(dnsRecordName)
+	if err != nil {
+		return nil, err
+	}
+
+	return z.cf.UpsertCAARecordsByZone(zone, dnsRecordName, caaRecordsData, dnsRecordComment)
+}
+
+func (z *objectZones) upsertNSRecords(dnsRecordName string, nameservers []string, dnsRecordComment string) ([]DNSRecord, error) {
+
+	zone, err := z.getZone(dnsRecordName)
+	if err != nil {
+		return nil, err
+	}
+
+	return z.cf.UpsertNSRecordsByZone(zone, dnsRecordName, nameservers, dnsRecordComment)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// rateLimitSlowDownFraction is the fraction of the budget below which requests get spread out over the time until it resets
+	rateLimitSlowDownFraction = 0.2
+
+	// defaultRateLimitWindow is the time until the budget resets if cloudflare doesn't report it, matching its global api rate limit
+	defaultRateLimitWindow = 5 * time.Minute
+
+	// maxRateLimitDelay caps the delay of a single request, so a misreported reset doesn't stall the controller
+	maxRateLimitDelay = time.Minute
+)
+
+// rateLimitedError is returned for requests cloudflare rejects with a 429 because the rate limit has been exceeded, so the object can be retried once it's allowed again instead of at the next poll
+type rateLimitedError struct {
+	retryAfter time.Duration
+}
+
+func (e *rateLimitedError) Error() string {
+	return fmt.Sprintf("Cloudflare api rate limit exceeded, retry after %v", e.retryAfter)
+}
+
+// rateLimitBudget tracks the request budget cloudflare reports in the X-RateLimit headers of its responses, so requests can slow down as the budget runs out instead of getting rejected.
+type rateLimitBudget struct {
+	mutex     sync.Mutex
+	limit     int
+	remaining int
+	reset     time.Time
+	known     bool
+}
+
+func newRateLimitBudget() *rateLimitBudget {
+	return &rateLimitBudget{}
+}
+
+// update takes the budget from the rate limit headers of a response, if it has them, and reflects the remaining budget in the estafette_cloudflare_dns_api_rate_limit_remaining metric
+func (b *rateLimitBudget) update(header http.Header, now time.Time) {
+
+	if b == nil {
+		return
+	}
+
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	limit, err := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	if err != nil {
+		limit = 0
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.limit = limit
+	b.remaining = remaining
+	b.reset = getRateLimitReset(header.Get("X-RateLimit-Reset"), now)
+	b.known = true
+
+	apiRateLimitRemaining.Set(float64(remaining))
+}
+
+// getRateLimitReset returns when the budget resets, from a number of seconds until then or a unix timestamp
+func getRateLimitReset(value string, now time.Time) time.Time {
+
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return now.Add(defaultRateLimitWindow)
+	}
+
+	// a value this large can only be a unix timestamp
+	if seconds > 1000000000 {
+		return time.Unix(seconds, 0)
+	}
+
+	return now.Add(time.Duration(seconds) * time.Second)
+}
+
+// getDelay returns how long to wait before the next request: nothing while plenty of budget is left, the remaining time spread over the remaining requests once it runs low, and the time until the reset once it's used up
+func (b *rateLimitBudget) getDelay(now time.Time) time.Duration {
+
+	if b == nil {
+		return 0
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	// the budget is back in full once the reset has passed
+	if !b.known || !now.Before(b.reset) {
+		return 0
+	}
+
+	untilReset := b.reset.Sub(now)
+
+	var delay time.Duration
+	switch {
+	case b.remaining <= 0:
+		delay = untilReset
+	case b.limit > 0 && float64(b.remaining) < float64(b.limit)*rateLimitSlowDownFraction:
+		delay = untilReset / time.Duration(b.remaining+1)
+	default:
+		return 0
+	}
+
+	if delay > maxRateLimitDelay {
+		delay = maxRateLimitDelay
+	}
+
+	return delay
+}
+
+// wait sleeps for the delay the remaining budget calls for
+func (b *rateLimitBudget) wait() {
+
+	delay := b.getDelay(time.Now())
+	if delay <= 0 {
+		return
+	}
+
+	log.Debug().Msgf("Cloudflare api rate limit budget is running low, delaying request by %v", delay)
+	time.Sleep(delay)
+}
+
+// getRetryAfter returns how long to wait before retrying a rate limited request, from the Retry-After header in seconds or as http date, or else until the budget resets
+func getRetryAfter(header http.Header, now time.Time) time.Duration {
+
+	var retryAt time.Time
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil && seconds >= 0 {
+		retryAt = now.Add(time.Duration(seconds) * time.Second)
+	} else if date, err := http.ParseTime(header.Get("Retry-After")); err == nil {
+		retryAt = date
+	} else {
+		retryAt = getRateLimitReset(header.Get("X-RateLimit-Reset"), now)
+	}
+
+	retryAfter := retryAt.Sub(now)
+	if retryAfter < 0 {
+		retryAfter = 0
+	}
+	if retryAfter > defaultRateLimitWindow {
+		retryAfter = defaultRateLimitWindow
+	}
+
+	return retryAfter
+}
@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"io"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"time"
 )
 
 // restClient is the interface to be able to mock http calls to cloudflare api.
@@ -13,34 +16,91 @@ type restClient interface {
 	Get(string, APIAuthentication) ([]byte, error)
 	Post(string, interface{}, APIAuthentication) ([]byte, error)
 	Put(string, interface{}, APIAuthentication) ([]byte, error)
+	Patch(string, interface{}, APIAuthentication) ([]byte, error)
 	Delete(string, APIAuthentication) ([]byte, error)
+	PostFile(string, map[string]string, string, []byte, APIAuthentication) ([]byte, error)
 }
 
 // realRESTClient is the http client that makes the actual request to cloudflare api.
 type realRESTClient struct {
+	// sent with every request, so calls from this controller can be told apart at Cloudflare
+	userAgent string
+
+	// sends the requests, through an egress proxy if configured; a default client is used if not set
+	httpClient *http.Client
+
+	// the budget cloudflare reports in its rate limit headers, to slow down as it runs out; requests aren't delayed if not set
+	rateLimit *rateLimitBudget
+}
+
+// newRealRESTClient returns a client that sends its requests through the proxy the proxy function returns for them.
+func newRealRESTClient(userAgent string, proxy func(*http.Request) (*url.URL, error)) *realRESTClient {
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+
+	return &realRESTClient{
+		userAgent:  userAgent,
+		httpClient: &http.Client{Transport: transport},
+		rateLimit:  newRateLimitBudget(),
+	}
 }
 
 // Get calls the cloudflare api for given url and using authentication to get access.
 func (r *realRESTClient) Get(cloudflareAPIURL string, authentication APIAuthentication) (body []byte, err error) {
-	return core("GET", cloudflareAPIURL, nil, authentication)
+	return r.core("GET", cloudflareAPIURL, nil, authentication)
 }
 
 // Post calls the cloudflare api for given url and using authentication to get access.
 func (r *realRESTClient) Post(cloudflareAPIURL string, params interface{}, authentication APIAuthentication) (body []byte, err error) {
-	return core("POST", cloudflareAPIURL, params, authentication)
+	return r.core("POST", cloudflareAPIURL, params, authentication)
 }
 
 // Put calls the cloudflare api for given url and using authentication to get access.
 func (r *realRESTClient) Put(cloudflareAPIURL string, params interface{}, authentication APIAuthentication) (body []byte, err error) {
-	return core("PUT", cloudflareAPIURL, params, authentication)
+	return r.core("PUT", cloudflareAPIURL, params, authentication)
+}
+
+// Patch calls the cloudflare api for given url and using authentication to get access.
+func (r *realRESTClient) Patch(cloudflareAPIURL string, params interface{}, authentication APIAuthentication) (body []byte, err error) {
+	return r.core("PATCH", cloudflareAPIURL, params, authentication)
 }
 
 // Delete calls the cloudflare api for given url and using authentication to get access.
 func (r *realRESTClient) Delete(cloudflareAPIURL string, authentication APIAuthentication) (body []byte, err error) {
-	return core("DELETE", cloudflareAPIURL, nil, authentication)
+	return r.core("DELETE", cloudflareAPIURL, nil, authentication)
+}
+
+// PostFile uploads a file to the cloudflare api as multipart form data along with the other form fields, using authentication to get access.
+func (r *realRESTClient) PostFile(cloudflareAPIURL string, fields map[string]string, fileName string, file []byte, authentication APIAuthentication) (body []byte, err error) {
+
+	var requestBody bytes.Buffer
+	writer := multipart.NewWriter(&requestBody)
+	for name, value := range fields {
+		err = writer.WriteField(name, value)
+		if err != nil {
+			return
+		}
+	}
+
+	part, err := writer.CreateFormFile("file", fileName)
+	if err != nil {
+		return
+	}
+	_, err = part.Write(file)
+	if err != nil {
+		return
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return
+	}
+
+	return r.send("POST", cloudflareAPIURL, writer.FormDataContentType(), &requestBody, authentication)
 }
 
-func core(verb, cloudflareAPIURL string, params interface{}, authentication APIAuthentication) (body []byte, err error) {
+func (r *realRESTClient) core(verb, cloudflareAPIURL string, params interface{}, authentication APIAuthentication) (body []byte, err error) {
 
 	// convert params to json if they're present
 	var requestBody io.Reader
@@ -52,17 +112,31 @@ func core(verb, cloudflareAPIURL string, params interface{}, authentication APIA
 		requestBody = bytes.NewReader(data)
 	}
 
+	return r.send(verb, cloudflareAPIURL, "application/json", requestBody, authentication)
+}
+
+func (r *realRESTClient) send(verb, cloudflareAPIURL, contentType string, requestBody io.Reader, authentication APIAuthentication) (body []byte, err error) {
+
 	// create client, in order to add headers
-	client := &http.Client{}
+	client := r.httpClient
+	if client == nil {
+		client = &http.Client{}
+	}
 	request, err := http.NewRequest(verb, cloudflareAPIURL, requestBody)
 	if err != nil {
 		return
 	}
 
 	// add headers
-	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Content-Type", contentType)
 	request.Header.Add("X-Auth-Key", authentication.Key)
 	request.Header.Add("X-Auth-Email", authentication.Email)
+	if r.userAgent != "" {
+		request.Header.Set("User-Agent", r.userAgent)
+	}
+
+	// spread out requests if the rate limit budget runs low
+	r.rateLimit.wait()
 
 	// perform actual request
 	response, err := client.Do(request)
@@ -72,10 +146,17 @@ func core(verb, cloudflareAPIURL string, params interface{}, authentication APIA
 
 	defer response.Body.Close()
 
+	r.rateLimit.update(response.Header, time.Now())
+
 	body, err = ioutil.ReadAll(response.Body)
 	if err != nil {
 		return
 	}
 
+	// let the caller retry the object once the rate limit allows it again
+	if response.StatusCode == http.StatusTooManyRequests {
+		err = &rateLimitedError{retryAfter: getRetryAfter(response.Header, time.Now())}
+	}
+
 	return
 }
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// configMapStateStore stores the state of all managed objects in a single configmap, instead of in the estafette.io/cloudflare-state annotation on each object.
+type configMapStateStore struct {
+	kubeClientset kubernetes.Interface
+	namespace     string
+	name          string
+}
+
+// stateStore is only set if the state is stored in a configmap
+var stateStore *configMapStateStore
+
+func newConfigMapStateStore(kubeClientset kubernetes.Interface, namespace, name string) *configMapStateStore {
+	return &configMapStateStore{
+		kubeClientset: kubeClientset,
+		namespace:     namespace,
+		name:          name,
+	}
+}
+
+// getControllerNamespace returns the namespace the controller runs in, as mounted with its service account token.
+func getControllerNamespace() string {
+
+	namespace, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+	if err != nil {
+		return "default"
+	}
+
+	return strings.TrimSpace(string(namespace))
+}
+
+func getStateConfigMapKey(kind, namespace, name string) string {
+	// namespaces and names can't contain underscores, so this doesn't clash for different objects
+	return strings.ToLower(kind) + "_" + namespace + "_" + name
+}
+
+// get returns the stored state for an object and whether any state has been stored for it.
+func (s *configMapStateStore) get(ctx context.Context, kind, namespace, name string) (state CloudflareState, ok bool) {
+
+	configMap, err := s.kubeClientset.CoreV1().ConfigMaps(s.namespace).Get(ctx, s.name, metav1.GetOptions{})
+	if err != nil {
+		return state, false
+	}
+
+	cloudflareStateString, ok := configMap.Data[getStateConfigMapKey(kind, namespace, name)]
+	if !ok {
+		return state, false
+	}
+
+	if err := json.Unmarshal([]byte(cloudflareStateString), &state); err != nil {
+		// couldn't deserialize, setting to default struct
+		countStateDecodeFailure(kind, namespace, name, cloudflareStateString, err)
+		return CloudflareState{}, true
+	}
+
+	return state, true
+}
+
+// set stores the state for an object, creating the configmap if it doesn't exist yet.
+func (s *configMapStateStore) set(ctx context.Context, kind, namespace, name string, state CloudflareState) error {
+
+	cloudflareStateByteArray, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	cloudflareStateString := string(cloudflareStateByteArray)
+
+	return s.update(ctx, getStateConfigMapKey(kind, namespace, name), &cloudflareStateString)
+}
+
+// remove deletes the stored state for an object.
+func (s *configMapStateStore) remove(ctx context.Context, kind, namespace, name string) error {
+	return s.update(ctx, getStateConfigMapKey(kind, namespace, name), nil)
+}
+
+func (s *configMapStateStore) update(ctx context.Context, key string, value *string) error {
+
+	// objects get processed concurrently by the watchers and poller, so retry if the configmap got modified in the meantime
+	return retry.OnError(retry.DefaultRetry, func(err error) bool {
+		return k8serrors.IsConflict(err) || k8serrors.IsAlreadyExists(err)
+	}, func() error {
+		configMap, err := s.kubeClientset.CoreV1().ConfigMaps(s.namespace).Get(ctx, s.name, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			if value == nil {
+				return nil
+			}
+
+			configMap = &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: s.name, Namespace: s.namespace},
+				Data:       map[string]string{key: *value},
+			}
+			_, err = s.kubeClientset.CoreV1().ConfigMaps(s.namespace).Create(ctx, configMap, metav1.CreateOptions{})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		if value == nil {
+			if _, ok := configMap.Data[key]; !ok {
+				return nil
+			}
+			delete(configMap.Data, key)
+		} else {
+			if configMap.Data == nil {
+				configMap.Data = map[string]string{}
+			}
+			configMap.Data[key] = *value
+		}
+
+		_, err = s.kubeClientset.CoreV1().ConfigMaps(s.namespace).Update(ctx, configMap, metav1.UpdateOptions{})
+		return err
+	})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestConfigMapStateStore(t *testing.T) {
+
+	ctx := context.Background()
+	state := CloudflareState{Enabled: "true", Hostnames: "www.example.com", Proxy: "true", IPAddress: "1.2.3.4"}
+
+	t.Run("CreatesConfigMapWhenSettingFirstState", func(t *testing.T) {
+
+		kubeClientset := fake.NewSimpleClientset()
+		store := newConfigMapStateStore(kubeClientset, "estafette", "estafette-cloudflare-dns-state")
+
+		// act
+		err := store.set(ctx, "Service", "mynamespace", "myservice", state)
+
+		assert.Nil(t, err)
+		configMap, err := kubeClientset.CoreV1().ConfigMaps("estafette").Get(ctx, "estafette-cloudflare-dns-state", metav1.GetOptions{})
+		assert.Nil(t, err)
+		assert.Contains(t, configMap.Data, "service_mynamespace_myservice")
+	})
+
+	t.Run("ReturnsStoredState", func(t *testing.T) {
+
+		kubeClientset := fake.NewSimpleClientset()
+		store := newConfigMapStateStore(kubeClientset, "estafette", "estafette-cloudflare-dns-state")
+		store.set(ctx, "Service", "mynamespace", "myservice", state)
+
+		// act
+		storedState, ok := store.get(ctx, "Service", "mynamespace", "myservice")
+
+		assert.True(t, ok)
+		assert.Equal(t, state, storedState)
+	})
+
+	t.Run("KeepsStateOfObjectsOfDifferentKindsApart", func(t *testing.T) {
+
+		kubeClientset := fake.NewSimpleClientset()
+		store := newConfigMapStateStore(kubeClientset, "estafette", "estafette-cloudflare-dns-state")
+		store.set(ctx, "Service", "mynamespace", "myapplication", state)
+
+		// act
+		_, ok := store.get(ctx, "Ingress", "mynamespace", "myapplication")
+
+		assert.False(t, ok)
+	})
+
+	t.Run("RemovesStoredState", func(t *testing.T) {
+
+		kubeClientset := fake.NewSimpleClientset(&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "estafette-cloudflare-dns-state", Namespace: "estafette"},
+			Data:       map[string]string{"service_mynamespace_myservice": "{}", "ingress_mynamespace_myingress": "{}"},
+		})
+		store := newConfigMapStateStore(kubeClientset, "estafette", "estafette-cloudflare-dns-state")
+
+		// act
+		err := store.remove(ctx, "Service", "mynamespace", "myservice")
+
+		assert.Nil(t, err)
+		configMap, _ := kubeClientset.CoreV1().ConfigMaps("estafette").Get(ctx, "estafette-cloudflare-dns-state", metav1.GetOptions{})
+		assert.NotContains(t, configMap.Data, "service_mynamespace_myservice")
+		assert.Contains(t, configMap.Data, "ingress_mynamespace_myingress")
+	})
+
+	t.Run("RemovingStateDoesNotCreateConfigMap", func(t *testing.T) {
+
+		kubeClientset := fake.NewSimpleClientset()
+		store := newConfigMapStateStore(kubeClientset, "estafette", "estafette-cloudflare-dns-state")
+
+		// act
+		err := store.remove(ctx, "Service", "mynamespace", "myservice")
+
+		assert.Nil(t, err)
+		_, err = kubeClientset.CoreV1().ConfigMaps("estafette").Get(ctx, "estafette-cloudflare-dns-state", metav1.GetOptions{})
+		assert.NotNil(t, err)
+	})
+}
+
+func TestGetCurrentServiceStateFromConfigMap(t *testing.T) {
+
+	ctx := context.Background()
+
+	t.Run("FallsBackToAnnotationWhenConfigMapHasNoState", func(t *testing.T) {
+
+		stateStore = newConfigMapStateStore(fake.NewSimpleClientset(), "estafette", "estafette-cloudflare-dns-state")
+		defer func() { stateStore = nil }()
+
+		service := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "myservice",
+				Namespace:   "mynamespace",
+				Annotations: map[string]string{annotationCloudflareState: `{"enabled":"true","hostnames":"www.example.com","ipAddress":"1.2.3.4"}`},
+			},
+		}
+
+		// act
+		state := getCurrentServiceState(ctx, service)
+
+		assert.Equal(t, "www.example.com", state.Hostnames)
+	})
+
+	t.Run("PrefersStateFromConfigMap", func(t *testing.T) {
+
+		stateStore = newConfigMapStateStore(fake.NewSimpleClientset(), "estafette", "estafette-cloudflare-dns-state")
+		defer func() { stateStore = nil }()
+		stateStore.set(ctx, "Service", "mynamespace", "myservice", CloudflareState{Enabled: "true", Hostnames: "api.example.com"})
+
+		service := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "myservice",
+				Namespace:   "mynamespace",
+				Annotations: map[string]string{annotationCloudflareState: `{"enabled":"true","hostnames":"www.example.com","ipAddress":"1.2.3.4"}`},
+			},
+		}
+
+		// act
+		state := getCurrentServiceState(ctx, service)
+
+		assert.Equal(t, "api.example.com", state.Hostnames)
+	})
+}
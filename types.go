@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -26,6 +27,25 @@ type Zone struct {
 	VanityNS    []string `json:"vanity_name_servers"`
 	Betas       []string `json:"betas"`
 	DeactReason string   `json:"deactivation_reason"`
+	Plan        ZonePlan `json:"plan"`
+}
+
+// ZonePlan represents the plan a zone in Cloudflare is on, which limits the features available to its records.
+type ZonePlan struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`      // like Free Website
+	LegacyID string `json:"legacy_id"` // like free, pro, business or enterprise
+}
+
+// preference ranks zones sharing the same name; active zones rank above inactive ones, full zones above partial ones.
+func (z Zone) preference() (rank int) {
+	if z.Status == "active" && !z.Paused {
+		rank += 2
+	}
+	if z.Type == "full" {
+		rank++
+	}
+	return
 }
 
 // DNSRecord represents a dns record in Cloudflare (https://api.cloudflare.com/#dns-records-for-a-zone-list-dns-records).
@@ -34,6 +54,8 @@ type DNSRecord struct {
 	Type       string      `json:"type,omitempty"`
 	Name       string      `json:"name,omitempty"`
 	Content    string      `json:"content,omitempty"`
+	Comment    string      `json:"comment,omitempty"`
+	Region     string      `json:"region,omitempty"` // regional services region key, like eu
 	Proxiable  bool        `json:"proxiable,omitempty"`
 	Proxied    bool        `json:"proxied,omitempty"`
 	TTL        int         `json:"ttl,omitempty"`
@@ -42,30 +64,90 @@ type DNSRecord struct {
 	ZoneName   string      `json:"zone_name,omitempty"`
 	CreatedOn  time.Time   `json:"created_on,omitempty"`
 	ModifiedOn time.Time   `json:"modified_on,omitempty"`
-	Data       interface{} `json:"data,omitempty"` // data returned by: SRV, LOC
+	Data       interface{} `json:"data,omitempty"` // data returned by: SRV, CAA, LOC
 	Meta       interface{} `json:"meta,omitempty"`
 	Priority   int         `json:"priority,omitempty"`
 }
 
+// SRVRecordData represents the structured data of an srv record in Cloudflare (https://api.cloudflare.com/#dns-records-for-a-zone-create-dns-record).
+type SRVRecordData struct {
+	Priority int    `json:"priority"`
+	Weight   int    `json:"weight"`
+	Port     int    `json:"port"`
+	Target   string `json:"target"`
+}
+
+// CAARecordData represents the structured data of a caa record in Cloudflare (https://api.cloudflare.com/#dns-records-for-a-zone-create-dns-record).
+type CAARecordData struct {
+	Flags int    `json:"flags"`
+	Tag   string `json:"tag"`
+	Value string `json:"value"`
+}
+
+// LOCRecordData represents the structured data of a loc record in Cloudflare (https://api.cloudflare.com/#dns-records-for-a-zone-create-dns-record).
+type LOCRecordData struct {
+	LatDegrees    int     `json:"lat_degrees"`
+	LatMinutes    int     `json:"lat_minutes"`
+	LatSeconds    float64 `json:"lat_seconds"`
+	LatDirection  string  `json:"lat_direction"`
+	LongDegrees   int     `json:"long_degrees"`
+	LongMinutes   int     `json:"long_minutes"`
+	LongSeconds   float64 `json:"long_seconds"`
+	LongDirection string  `json:"long_direction"`
+	Altitude      float64 `json:"altitude"`
+	Size          float64 `json:"size"`
+	PrecisionHorz float64 `json:"precision_horz"`
+	PrecisionVert float64 `json:"precision_vert"`
+}
+
+// dnsRecordProxyPatch holds the fields changed when toggling the proxy setting of a dns record, so the other fields of the record are left as they are (https://api.cloudflare.com/#dns-records-for-a-zone-patch-dns-record).
+type dnsRecordProxyPatch struct {
+	Proxied bool `json:"proxied"`
+	TTL     int  `json:"ttl,omitempty"`
+}
+
+// ZoneSetting represents a setting of a zone in Cloudflare (https://api.cloudflare.com/#zone-settings-get-ssl-setting).
+type ZoneSetting struct {
+	ID       string      `json:"id,omitempty"`
+	Value    interface{} `json:"value"`
+	Editable bool        `json:"editable,omitempty"`
+}
+
+// cloudflareError represents an error returned by the Cloudflare api (https://api.cloudflare.com/#getting-started-responses).
+type cloudflareError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e cloudflareError) String() string {
+	return fmt.Sprintf("%v: %v", e.Code, e.Message)
+}
+
 // APIAuthentication contains the email address and api key to authenticate a request to the cloudflare api.
 type APIAuthentication struct {
 	Key, Email string
 }
 
 type dNSRecordsResult struct {
-	Success    bool        `json:"success"`
-	Errors     interface{} `json:"errors"`
-	Messages   interface{} `json:"messages"`
-	DNSRecords []DNSRecord `json:"result"`
-	ResultInfo resultInfo  `json:"result_info,omitempty"`
+	Success    bool              `json:"success"`
+	Errors     []cloudflareError `json:"errors"`
+	Messages   interface{}       `json:"messages"`
+	DNSRecords []DNSRecord       `json:"result"`
+	ResultInfo resultInfo        `json:"result_info,omitempty"`
+}
+
+type apiResult struct {
+	Success  bool              `json:"success"`
+	Errors   []cloudflareError `json:"errors"`
+	Messages interface{}       `json:"messages"`
 }
 
 type zonesResult struct {
-	Success    bool        `json:"success"`
-	Errors     interface{} `json:"errors"`
-	Messages   interface{} `json:"messages"`
-	Zones      []Zone      `json:"result"`
-	ResultInfo resultInfo  `json:"result_info"`
+	Success    bool              `json:"success"`
+	Errors     []cloudflareError `json:"errors"`
+	Messages   interface{}       `json:"messages"`
+	Zones      []Zone            `json:"result"`
+	ResultInfo resultInfo        `json:"result_info"`
 }
 
 type resultInfo struct {
@@ -75,23 +157,40 @@ type resultInfo struct {
 	TotalCount int `json:"total_count"`
 }
 
+type importResult struct {
+	Success  bool              `json:"success"`
+	Errors   []cloudflareError `json:"errors"`
+	Messages interface{}       `json:"messages"`
+	Result   struct {
+		RecordsAdded       int `json:"recs_added"`
+		TotalRecordsParsed int `json:"total_records_parsed"`
+	} `json:"result"`
+}
+
+type zoneSettingResult struct {
+	Success     bool              `json:"success"`
+	Errors      []cloudflareError `json:"errors"`
+	Messages    interface{}       `json:"messages"`
+	ZoneSetting ZoneSetting       `json:"result"`
+}
+
 type createResult struct {
-	Success   bool        `json:"success"`
-	Errors    interface{} `json:"errors"`
-	Messages  interface{} `json:"messages"`
-	DNSRecord DNSRecord   `json:"result,omitempty"`
+	Success   bool              `json:"success"`
+	Errors    []cloudflareError `json:"errors"`
+	Messages  interface{}       `json:"messages"`
+	DNSRecord DNSRecord         `json:"result,omitempty"`
 }
 
 type updateResult struct {
-	Success   bool        `json:"success"`
-	Errors    interface{} `json:"errors"`
-	Messages  interface{} `json:"messages"`
-	DNSRecord DNSRecord   `json:"result,omitempty"`
+	Success   bool              `json:"success"`
+	Errors    []cloudflareError `json:"errors"`
+	Messages  interface{}       `json:"messages"`
+	DNSRecord DNSRecord         `json:"result,omitempty"`
 }
 
 type deleteResult struct {
-	Success  bool        `json:"success"`
-	Errors   interface{} `json:"errors"`
-	Messages interface{} `json:"messages"`
-	Result   interface{} `json:"result"`
+	Success  bool              `json:"success"`
+	Errors   []cloudflareError `json:"errors"`
+	Messages interface{}       `json:"messages"`
+	Result   interface{}       `json:"result"`
 }
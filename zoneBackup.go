@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io/ioutil"
+
+	"github.com/rs/zerolog/log"
+)
+
+// exportZone writes all records of a zone to a file in BIND format
+func exportZone(cf *Cloudflare, zoneName, file string) error {
+
+	zone, err := cf.GetZoneByDNSName(toASCIIHostname(zoneName))
+	if err != nil {
+		return err
+	}
+
+	log.Info().Msgf("Exporting records of zone %v to %v...", zone.Name, file)
+
+	data, err := cf.ExportZoneRecords(zone)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(file, data, 0600)
+	if err != nil {
+		return err
+	}
+
+	log.Info().Msgf("Exported records of zone %v to %v", zone.Name, file)
+
+	return nil
+}
+
+// importZone creates the records in a file in BIND format in a zone
+func importZone(cf *Cloudflare, zoneName, file string, proxy bool) error {
+
+	zone, err := cf.GetZoneByDNSName(toASCIIHostname(zoneName))
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	log.Info().Msgf("Importing records from %v into zone %v...", file, zone.Name)
+
+	r, err := cf.ImportZoneRecords(zone, data, proxy)
+	if err != nil {
+		return err
+	}
+
+	log.Info().Msgf("Imported %v of %v records from %v into zone %v", r.Result.RecordsAdded, r.Result.TotalRecordsParsed, file, zone.Name)
+
+	return nil
+}